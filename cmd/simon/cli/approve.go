@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/felixgeelhaar/simon/internal/mcp"
+	"github.com/felixgeelhaar/simon/internal/ui"
+)
+
+// uiApprover routes approval requests to an interactive UI such as the TUI.
+func uiApprover(a ui.Approver) mcp.Approver {
+	return mcp.ApproverFunc(func(ctx context.Context, req mcp.ApprovalRequest) bool {
+		if req.Command != "" {
+			return a.RequestApproval(ctx, "run "+req.Tool, commandPreview(req))
+		}
+		title := req.Path
+		if req.Warning != "" {
+			title += " ⚠ " + req.Warning
+		}
+		return a.RequestApproval(ctx, title, req.Diff)
+	})
+}
+
+// promptApprover shows proposed changes on the terminal and reads y/N from in.
+type promptApprover struct {
+	mu  sync.Mutex
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func newPromptApprover(in io.Reader, out io.Writer) *promptApprover {
+	return &promptApprover{in: bufio.NewReader(in), out: out}
+}
+
+func (p *promptApprover) Approve(ctx context.Context, req mcp.ApprovalRequest) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if req.Warning != "" {
+		fmt.Fprintf(p.out, "\n\x1b[33m⚠ %s\x1b[0m", req.Warning)
+	}
+	if req.Command != "" {
+		fmt.Fprintf(p.out, "\nProposed command (%s):\n%s\nRun this command? [y/N] ", req.Tool, commandPreview(req))
+	} else {
+		fmt.Fprintf(p.out, "\nProposed change to %s (%s):\n%s\nApply this change? [y/N] ", req.Path, req.Tool, colorizeDiff(req.Diff))
+	}
+	answer, err := p.in.ReadString('\n')
+	if err != nil && answer == "" {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// commandPreview shows a proposed command as a shell prompt line.
+func commandPreview(req mcp.ApprovalRequest) string {
+	if req.Path != "" {
+		return fmt.Sprintf("%s $ %s", req.Path, req.Command)
+	}
+	return "$ " + req.Command
+}
+
+// colorizeDiff adds ANSI colors to a unified diff for terminal display.
+func colorizeDiff(diff string) string {
+	lines := strings.Split(strings.TrimRight(diff, "\n"), "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			lines[i] = "\x1b[1m" + line + "\x1b[0m"
+		case strings.HasPrefix(line, "+"):
+			lines[i] = "\x1b[32m" + line + "\x1b[0m"
+		case strings.HasPrefix(line, "-"):
+			lines[i] = "\x1b[31m" + line + "\x1b[0m"
+		case strings.HasPrefix(line, "@@"):
+			lines[i] = "\x1b[36m" + line + "\x1b[0m"
+		}
+	}
+	return strings.Join(lines, "\n")
+}
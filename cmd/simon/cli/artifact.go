@@ -0,0 +1,226 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/mcp"
+	"github.com/felixgeelhaar/simon/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	artifactOutput string
+
+	artifactSearchRegex      bool
+	artifactSearchIgnoreCase bool
+	artifactSearchSession    string
+	artifactSearchSince      string
+	artifactSearchLimit      int
+)
+
+var artifactCmd = &cobra.Command{
+	Use:     "artifact",
+	Aliases: []string{"artifacts"},
+	Short:   "List, search, and retrieve stored artifacts",
+	Long: `List, search, and retrieve stored artifacts.
+
+Artifacts hold tool outputs, diffs, plans, and summaries, text or binary.
+Their content is stored once per SHA-256, so identical outputs share storage.
+Artifacts larger than artifacts.max_size bytes (default 64 MiB) are rejected;
+set it with "simon config set artifacts.max_size <bytes>", 0 for no limit.`,
+}
+
+var artifactListCmd = &cobra.Command{
+	Use:               "list <session-id>",
+	Short:             "List a session's artifacts with type, MIME type, and size",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeSessionIDs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		s := getStore()
+		defer s.Close()
+
+		if err := listArtifacts(os.Stdout, s, args[0]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var artifactGetCmd = &cobra.Command{
+	Use:   "get <artifact>",
+	Short: "Print an artifact or save it to a file",
+	Long: `Print an artifact, given by ID or by its path as reported in tool summaries
+(artifacts/<session-id>/<name>). Binary artifacts are not printed; save them
+with --output.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		s := getStore()
+		defer s.Close()
+
+		if err := getArtifact(os.Stdout, s, args[0], artifactOutput); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var artifactSearchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Find the artifacts containing a text or pattern",
+	Long: `Search the content of text artifacts across sessions and print each matching
+line with its session and artifact, oldest first. The query is a substring
+unless --regex is given.
+
+Examples:
+  simon artifact search "connection refused" --since 7d
+  simon artifact search --regex 'panic: .*nil' --session session-1712345678
+  simon artifact search -i timeout --limit 0`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		window, err := parseSince(artifactSearchSince)
+		if err != nil {
+			fmt.Printf("Invalid --since value: %v\n", err)
+			os.Exit(1)
+		}
+		pattern, err := artifactSearchPattern(args[0], artifactSearchRegex, artifactSearchIgnoreCase)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		s := getStore()
+		defer s.Close()
+
+		search := store.ArtifactSearch{Pattern: pattern, SessionID: artifactSearchSession, Limit: artifactSearchLimit}
+		if window > 0 {
+			search.Since = time.Now().Add(-window)
+		}
+		if err := searchArtifacts(os.Stdout, s, search); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+// listArtifacts writes a table of a session's artifacts to out.
+func listArtifacts(out io.Writer, s store.Storage, sessionID string) error {
+	if _, err := s.GetSession(sessionID); err != nil {
+		return err
+	}
+	artifacts, err := s.ListArtifacts(sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to list artifacts: %w", err)
+	}
+	if len(artifacts) == 0 {
+		fmt.Fprintln(out, "No artifacts found.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTYPE\tMIME\tSIZE\tCREATED")
+	for _, a := range artifacts {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", a.ID, a.Type, orDash(a.MIMEType), artifactSize(a), a.CreatedAt.Format("2006-01-02 15:04:05"))
+	}
+	return w.Flush()
+}
+
+// getArtifact writes an artifact to the output file, or prints it to out
+// when output is empty and the artifact is text.
+func getArtifact(out io.Writer, s store.Storage, ref, output string) error {
+	a, content, err := mcp.ResolveArtifact(s, "", ref)
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		if !a.IsText() {
+			return fmt.Errorf("%s is binary (%s, %d bytes); save it with --output <file>", a.ID, a.MIMEType, a.Size)
+		}
+		_, err := out.Write(content)
+		return err
+	}
+	if err := os.WriteFile(output, content, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+	fmt.Fprintf(out, "Wrote %s (%s, %d bytes) to %s\n", a.ID, a.MIMEType, len(content), output)
+	return nil
+}
+
+// artifactSearchPattern compiles a search query: a substring, or a regular
+// expression when regex is set.
+func artifactSearchPattern(query string, regex, ignoreCase bool) (*regexp.Regexp, error) {
+	if query == "" {
+		return nil, fmt.Errorf("empty search query")
+	}
+	if !regex {
+		query = regexp.QuoteMeta(query)
+	}
+	if ignoreCase {
+		query = "(?i)" + query
+	}
+	pattern, err := regexp.Compile(query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression: %w", err)
+	}
+	return pattern, nil
+}
+
+// searchArtifacts writes a table of the artifact lines matching search to out.
+func searchArtifacts(out io.Writer, s store.Storage, search store.ArtifactSearch) error {
+	matches, err := s.SearchArtifacts(search)
+	if err != nil {
+		return fmt.Errorf("failed to search artifacts: %w", err)
+	}
+	if len(matches) == 0 {
+		fmt.Fprintln(out, "No matching artifacts found.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SESSION\tARTIFACT\tLINE\tTEXT")
+	for _, m := range matches {
+		text := strings.TrimSpace(m.Text)
+		if r := []rune(text); len(r) > 120 {
+			text = string(r[:120]) + "..."
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", m.Artifact.SessionID, m.Artifact.ID, m.Line, text)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if search.Limit > 0 && len(matches) == search.Limit {
+		fmt.Fprintf(out, "\nShowing the first %d matches; raise --limit to see more.\n", search.Limit)
+	}
+	return nil
+}
+
+// artifactSize formats an artifact's recorded size; artifacts saved before
+// sizes were recorded show "-", those simon fsck marked missing "missing".
+func artifactSize(a *store.Artifact) string {
+	if a.Missing {
+		return "missing"
+	}
+	if a.Blob == "" {
+		return "-"
+	}
+	return fmt.Sprint(a.Size)
+}
+
+func init() {
+	RootCmd.AddCommand(artifactCmd)
+	artifactCmd.AddCommand(artifactListCmd)
+	artifactCmd.AddCommand(artifactGetCmd)
+	artifactCmd.AddCommand(artifactSearchCmd)
+	artifactGetCmd.Flags().StringVarP(&artifactOutput, "output", "o", "", "Write the artifact to this file instead of printing it")
+	artifactSearchCmd.Flags().BoolVarP(&artifactSearchRegex, "regex", "E", false, "Treat the query as a regular expression")
+	artifactSearchCmd.Flags().BoolVarP(&artifactSearchIgnoreCase, "ignore-case", "i", false, "Match case-insensitively")
+	artifactSearchCmd.Flags().StringVar(&artifactSearchSession, "session", "", "Only search the artifacts of this session")
+	artifactSearchCmd.RegisterFlagCompletionFunc("session", completeSessionFlag)
+	artifactSearchCmd.Flags().StringVar(&artifactSearchSince, "since", "", "Only search artifacts from this window (e.g. 7d, 24h)")
+	artifactSearchCmd.Flags().IntVar(&artifactSearchLimit, "limit", 50, "Maximum number of matching lines (0 for all)")
+}
@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/felixgeelhaar/simon/internal/backup"
+	"github.com/felixgeelhaar/simon/internal/credential"
+	"github.com/spf13/cobra"
+)
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Export or import sessions, artifacts, memories, and config",
+	Long: `Move a store between machines or archive completed work.
+
+A backup is a gzip-compressed tar holding every session with its conversation
+history, all artifacts (each with a SHA-256 digest checked on import), the
+memory archive, and configuration. Credentials are never exported: they are
+encrypted with a key that only exists on this machine, so set them again
+after importing.`,
+}
+
+var backupExportCmd = &cobra.Command{
+	Use:   "export <file.tar.gz>",
+	Short: "Write the active profile's store to a backup file",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		s, err := openStore()
+		if err != nil {
+			fmt.Printf("Failed to init store: %v\n", err)
+			os.Exit(1)
+		}
+		defer s.Close()
+
+		f, err := os.OpenFile(args[0], os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			fmt.Printf("Failed to create backup: %v\n", err)
+			os.Exit(1)
+		}
+		manifest, err := backup.Export(s, f, backup.Options{ExcludeConfig: excludeFromBackup})
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(args[0])
+			fmt.Printf("Export failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Exported %d sessions, %d artifacts, and %d memories to %s\n",
+			manifest.Sessions, len(manifest.Artifacts), manifest.Memories, args[0])
+		if len(manifest.ExcludedConfig) > 0 {
+			fmt.Printf("Credentials not exported: %v\n", manifest.ExcludedConfig)
+		}
+	},
+}
+
+var backupImportCmd = &cobra.Command{
+	Use:   "import <file.tar.gz>",
+	Short: "Restore a backup file into the active profile's store",
+	Long: `Restore a backup into the active profile's store. Sessions that already
+exist, memories with identical content, and config keys that are already set
+are left untouched, so importing the same backup twice is safe.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		s, err := openStore()
+		if err != nil {
+			fmt.Printf("Failed to init store: %v\n", err)
+			os.Exit(1)
+		}
+		defer s.Close()
+
+		f, err := os.Open(args[0])
+		if err != nil {
+			fmt.Printf("Failed to open backup: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+
+		summary, err := backup.Import(s, f)
+		if err != nil {
+			fmt.Printf("Import failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Imported %d sessions (%d already present), %d artifacts, %d memories (%d duplicates), %d config keys (%d already set)\n",
+			summary.Sessions, summary.SkippedSessions, summary.Artifacts,
+			summary.Memories, summary.SkippedMemories, summary.Config, summary.SkippedConfig)
+	},
+}
+
+// excludeFromBackup keeps credentials out of backups.
+func excludeFromBackup(key, value string) bool {
+	return isSensitiveKey(key) || credential.IsEncrypted(value)
+}
+
+func init() {
+	RootCmd.AddCommand(backupCmd)
+	backupCmd.AddCommand(backupExportCmd)
+	backupCmd.AddCommand(backupImportCmd)
+}
@@ -0,0 +1,352 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/coach"
+	"github.com/felixgeelhaar/simon/internal/observe"
+	"github.com/felixgeelhaar/simon/internal/provider"
+	"github.com/felixgeelhaar/simon/internal/schedule"
+	"github.com/felixgeelhaar/simon/internal/store"
+	"github.com/spf13/cobra"
+)
+
+// metadataBatch is the tag grouping the sessions of one `simon batch` run.
+const metadataBatch = "batch"
+
+// batchStateInvalid marks specs of a batch that failed validation and never
+// ran.
+const batchStateInvalid = "invalid"
+
+var (
+	batchWorkers  int
+	batchProvider string
+	batchModel    string
+	batchBudget   string
+	batchTags     []string
+	batchVars     []string
+	batchWait     bool
+	batchForce    bool
+)
+
+var batchCmd = &cobra.Command{
+	Use:   "batch <dir-or-glob>...",
+	Short: "Run many specs and summarize their outcomes",
+	Long: `Run every spec in the given directories (*.yaml, *.yml, and *.json files) or
+matching the given globs as a session of its own, one at a time, then print a
+summary table with each spec's outcome.
+
+Each session gets its own budget: the policy's limits, scaled by --budget.
+Sessions on a provider share its serve.rate.<provider> request limit, and all
+are tagged batch=<batch id> for simon list --tag. Specs that fail validation
+are reported without running. Ctrl-C cancels the running sessions gracefully
+and skips the queued ones.
+
+The batch holds the repository's workspace lock while it runs, so simon run
+sessions don't edit the repository at the same time; --wait queues behind a
+session holding it and --force takes it. Its own sessions all run in that
+repository too, so they run one after another: --workers above 1 is refused.
+
+The exit status is 1 when any spec didn't complete.
+
+Examples:
+  simon batch specs/
+  simon batch 'backlog/*.yaml' --budget small
+  simon batch specs/ -p openai -m gpt-4o --tag run=nightly`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		specs, err := collectSpecs(args)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		tags, err := parseTags(batchTags)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		vars, err := coach.ParseVars(batchVars)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		// Session logs go to their log files; the terminal shows progress
+		logs := io.Discard
+		if verbose {
+			logs = os.Stderr
+		}
+		obs := observe.New(logs, verbose)
+		defer obs.Close()
+
+		s, err := openStore()
+		if err != nil {
+			fmt.Printf("Failed to init store: %v\n", err)
+			os.Exit(1)
+		}
+		defer s.Close()
+
+		d, err := newDaemon(obs, s)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		opts, err := scheduleOptions(s)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if batchWorkers > 1 {
+			fmt.Println("--workers must be 1: the batch's sessions all edit this repository, whose workspace lock admits one at a time")
+			os.Exit(1)
+		}
+		// The sessions share the batch's workspace lock, so they take turns
+		opts.MaxConcurrent = 1
+
+		batchID := fmt.Sprintf("batch-%d", time.Now().Unix())
+		if tags == nil {
+			tags = make(map[string]string)
+		}
+		tags[metadataBatch] = batchID
+		jobs := make([]schedule.Job, len(specs))
+		for i, spec := range specs {
+			jobs[i] = schedule.Job{SpecPath: spec, Provider: batchProvider, Model: batchModel, Budget: batchBudget, Tags: tags, Vars: vars}
+		}
+
+		fmt.Printf("Running %d specs as %s\n", len(specs), batchID)
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		lock, err := workspaceLock{Wait: batchWait, Force: batchForce}.acquire(ctx, obs, func(msg string) { fmt.Println(msg) }, "batch "+batchID)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		results := runBatch(ctx, os.Stdout, d.validate, d.run, opts, jobs)
+		lock.Release()
+		fmt.Println()
+		if failed := printBatchSummary(os.Stdout, s, specs, results); failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+// collectSpecs expands the batch arguments into spec files, in order and
+// without duplicates: the *.yaml, *.yml, and *.json files of a directory
+// (skipping dotfiles such as .simon.yaml), or the files a glob matches.
+func collectSpecs(args []string) ([]string, error) {
+	var specs []string
+	seen := make(map[string]bool)
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			specs = append(specs, path)
+		}
+	}
+	for _, arg := range args {
+		if info, err := os.Stat(arg); err == nil && info.IsDir() {
+			entries, err := os.ReadDir(arg)
+			if err != nil {
+				return nil, err
+			}
+			for _, e := range entries {
+				switch strings.ToLower(filepath.Ext(e.Name())) {
+				case ".yaml", ".yml", ".json":
+					if !e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+						add(filepath.Join(arg, e.Name()))
+					}
+				}
+			}
+			continue
+		}
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", arg, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no specs match %s", arg)
+		}
+		for _, m := range matches {
+			add(m)
+		}
+	}
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("no specs found in %s", strings.Join(args, ", "))
+	}
+	return specs, nil
+}
+
+// runBatch validates the jobs and runs the valid ones through a scheduler
+// until all have finished or ctx is done, reporting progress to out. It
+// returns the jobs in their final state, in order; jobs that failed
+// validation are batchStateInvalid, those never started still queued.
+func runBatch(ctx context.Context, out io.Writer, validate func(*schedule.Job) error, run schedule.RunFunc, opts schedule.Options, jobs []schedule.Job) []schedule.Job {
+	progress := &batchProgress{out: out, total: len(jobs), done: make(chan struct{})}
+	opts.OnChange = progress.update
+	sched := schedule.New(run, opts)
+
+	results := make([]schedule.Job, len(jobs))
+	submitted := make(map[string]int)
+	for i, job := range jobs {
+		err := validate(&job)
+		if err == nil {
+			if job, err = sched.Submit(job); err == nil {
+				results[i] = job
+				submitted[job.ID] = i
+				continue
+			}
+		}
+		job.State, job.Error = batchStateInvalid, err.Error()
+		results[i] = job
+		progress.update(job)
+	}
+
+	schedCtx, cancel := context.WithCancel(ctx)
+	finished := make(chan struct{})
+	go func() {
+		sched.Run(schedCtx)
+		close(finished)
+	}()
+	select {
+	case <-progress.done:
+	case <-ctx.Done():
+		fmt.Fprintln(out, "Interrupted: waiting for running sessions to stop...")
+	}
+	cancel()
+	<-finished
+
+	for id, i := range submitted {
+		if job, err := sched.Job(id); err == nil {
+			results[i] = job
+		}
+	}
+	return results
+}
+
+// batchProgress prints a line per started and finished job of a batch,
+// prefixed with the batch's overall progress, and closes done once every
+// job has finished.
+type batchProgress struct {
+	out   io.Writer
+	total int
+	done  chan struct{}
+
+	mu       sync.Mutex
+	finished int
+	running  int
+}
+
+func (p *batchProgress) update(job schedule.Job) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var line string
+	switch job.State {
+	case schedule.StateRunning:
+		p.running++
+		line = fmt.Sprintf("▶ %s started (%s)", displayPath(job.SpecPath), job.ID)
+	case batchStateInvalid:
+		p.finished++
+		line = fmt.Sprintf("✗ %s is invalid: %s", displayPath(job.SpecPath), job.Error)
+	default:
+		if job.StartedAt != nil {
+			p.running--
+		}
+		p.finished++
+		mark := "✓"
+		if job.State != schedule.StateCompleted {
+			mark = "✗"
+		}
+		line = fmt.Sprintf("%s %s %s in %s", mark, displayPath(job.SpecPath), job.State, jobDuration(job))
+		if job.Error != "" {
+			line += ": " + job.Error
+		}
+	}
+	fmt.Fprintf(p.out, "[%d/%d done, %d running] %s\n", p.finished, p.total, p.running, line)
+	if p.finished == p.total {
+		close(p.done)
+	}
+}
+
+// printBatchSummary prints a table of the batch's specs with their session,
+// its final status and cost, and how long it ran, and returns how many specs
+// didn't complete.
+func printBatchSummary(out io.Writer, s store.Storage, specs []string, results []schedule.Job) int {
+	failed := 0
+	var cost float64
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SPEC\tSESSION\tSTATUS\tCOST\tDURATION\tERROR")
+	for i, job := range results {
+		status, session, costCol, duration := job.State, "-", "-", "-"
+		switch job.State {
+		case schedule.StateQueued:
+			status = "skipped"
+		case batchStateInvalid:
+		default:
+			session, duration = job.ID, jobDuration(job).String()
+			if sess, err := s.GetSession(job.ID); err == nil {
+				status = sess.Status
+				cost += sess.Cost
+				costCol = fmt.Sprintf("$%.4f", sess.Cost)
+			}
+		}
+		if status != "completed" {
+			failed++
+		}
+		errText, _, _ := strings.Cut(job.Error, "\n")
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", specs[i], session, status, costCol, duration, errText)
+	}
+	w.Flush()
+	fmt.Fprintf(out, "\n%d of %d specs completed, estimated cost $%.4f\n", len(results)-failed, len(results), cost)
+	return failed
+}
+
+// jobDuration returns how long a job ran, rounded to the second; zero if it
+// never started.
+func jobDuration(job schedule.Job) time.Duration {
+	if job.StartedAt == nil {
+		return 0
+	}
+	end := time.Now()
+	if job.FinishedAt != nil {
+		end = *job.FinishedAt
+	}
+	return end.Sub(*job.StartedAt).Round(time.Second)
+}
+
+// displayPath returns path relative to the working directory when it is
+// inside it.
+func displayPath(path string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+func init() {
+	RootCmd.AddCommand(batchCmd)
+	batchCmd.Flags().IntVarP(&batchWorkers, "workers", "w", 1, "Sessions run at once; only 1, since they share the repository's workspace lock")
+	batchCmd.Flags().StringVarP(&batchProvider, "provider", "p", "", "AI Provider for every spec (default: each spec's provider, provider.default, or ollama)")
+	batchCmd.Flags().StringVarP(&batchModel, "model", "m", "", "Model name (default: each spec's model, provider.model, or the provider's default)")
+	batchCmd.Flags().StringVar(&batchBudget, "budget", "", "Budget preset each session's limits are scaled by: small, medium, large, or one defined with budget.<name>.* config")
+	batchCmd.Flags().StringArrayVar(&batchTags, "tag", nil, "Tag every session as key=value (repeatable)")
+	batchCmd.Flags().StringArrayVar(&batchVars, "var", nil, "Set a ${NAME} spec variable as NAME=value for every spec (repeatable)")
+	batchCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Print session logs to stderr")
+	batchCmd.Flags().BoolVar(&batchWait, "wait", false, "If another session holds this repository's lock, wait for it to finish instead of failing")
+	batchCmd.Flags().BoolVar(&batchForce, "force", false, "Take this repository's lock even if another session holds it")
+	batchCmd.MarkFlagsMutuallyExclusive("wait", "force")
+	batchCmd.RegisterFlagCompletionFunc("provider", cobra.FixedCompletions(provider.Names, cobra.ShellCompDirectiveNoFileComp))
+	batchCmd.RegisterFlagCompletionFunc("budget", cobra.FixedCompletions([]string{"small", "medium", "large"}, cobra.ShellCompDirectiveNoFileComp))
+}
@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the provider response cache",
+	Long: `Manage the provider response cache.
+
+Enable caching per run with "simon run --cache", or for every run with
+"simon config set cache.enabled true". Identical prompts (same provider,
+model, messages, and tools) are then answered from the cache, which makes
+replays, tests, and repeated dry-runs fast and free.`,
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show cached responses and hit counts",
+	Run: func(cmd *cobra.Command, args []string) {
+		s, err := openStore()
+		if err != nil {
+			fmt.Printf("Failed to init store: %v\n", err)
+			os.Exit(1)
+		}
+		defer s.Close()
+
+		stats, err := s.ResponseCacheStats()
+		if err != nil {
+			fmt.Printf("Failed to read cache stats: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Cached responses: %d\nCache hits:       %d\n", stats.Entries, stats.Hits)
+	},
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Delete all cached responses",
+	Run: func(cmd *cobra.Command, args []string) {
+		s, err := openStore()
+		if err != nil {
+			fmt.Printf("Failed to init store: %v\n", err)
+			os.Exit(1)
+		}
+		defer s.Close()
+
+		n, err := s.ClearResponseCache()
+		if err != nil {
+			fmt.Printf("Failed to clear cache: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Removed %d cached responses.\n", n)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheStatsCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+}
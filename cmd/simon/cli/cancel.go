@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/felixgeelhaar/simon/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var cancelForce bool
+
+var cancelCmd = &cobra.Command{
+	Use:   "cancel <session-id>",
+	Short: "Cancel a running session",
+	Long: `Request cancellation of a running session.
+
+The running loop finishes its current tool call (or interrupts it after a
+grace period), archives a partial summary, and marks the session "cancelled".
+
+Use --force for sessions whose process is gone and that would otherwise stay
+"running" forever; the status is set to "cancelled" immediately.
+
+Examples:
+  simon cancel session-1712345678
+  simon cancel session-1712345678 --force`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeSessionIDs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		s := getStore()
+		defer s.Close()
+
+		if err := cancelSession(s, args[0], cancelForce); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if cancelForce {
+			fmt.Printf("Session %s marked as cancelled.\n", args[0])
+		} else {
+			fmt.Printf("Cancellation requested for session %s.\n", args[0])
+		}
+	},
+}
+
+// cancelSession flags a session for cancellation, or finalizes it directly when forced.
+func cancelSession(s store.Storage, id string, force bool) error {
+	session, err := s.GetSession(id)
+	if err != nil {
+		return err
+	}
+	if store.SessionFinished(session.Status) {
+		return fmt.Errorf("session %s already finished (%s)", id, session.Status)
+	}
+
+	if err := s.RequestCancel(id); err != nil {
+		return fmt.Errorf("failed to request cancellation: %w", err)
+	}
+	if force {
+		session.Status = "cancelled"
+		return s.UpdateSession(session)
+	}
+	return nil
+}
+
+func init() {
+	RootCmd.AddCommand(cancelCmd)
+	cancelCmd.Flags().BoolVar(&cancelForce, "force", false, "Mark the session cancelled without waiting for its process")
+}
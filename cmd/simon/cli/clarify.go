@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/felixgeelhaar/simon/internal/coach"
+	"github.com/felixgeelhaar/simon/internal/provider"
+)
+
+// clarifySpec runs the coach's clarification loop for `simon run --clarify`.
+// When the spec has warnings it asks the provider for questions, prompts for
+// answers on in, and writes the refined spec next to the original. It returns
+// the spec path the session should use.
+func clarifySpec(ctx context.Context, in io.Reader, out io.Writer, p provider.Provider, path string) (string, error) {
+	c := coach.New()
+	spec, err := c.LoadSpec(path)
+	if err != nil {
+		return "", err
+	}
+	res := c.Validate(*spec)
+	if !res.Valid || len(res.Warnings) == 0 {
+		return path, nil
+	}
+
+	fmt.Fprintln(out, "The spec has warnings:")
+	for _, w := range res.Warnings {
+		fmt.Fprintf(out, "  - %s\n", w)
+	}
+	questions, err := c.ClarifyingQuestions(ctx, p, *spec, res.Warnings)
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Fprintln(out, "Answer a few questions to refine it (leave blank to skip):")
+	r := bufio.NewReader(in)
+	answers := make([]coach.Clarification, 0, len(questions))
+	for i, q := range questions {
+		fmt.Fprintf(out, "\n%d. %s\n> ", i+1, q)
+		answer, err := r.ReadString('\n')
+		answers = append(answers, coach.Clarification{Question: q, Answer: strings.TrimSpace(answer)})
+		if err != nil {
+			break
+		}
+	}
+
+	refined, err := c.Refine(ctx, p, *spec, answers)
+	if err != nil {
+		return "", err
+	}
+	if refined.Goal == spec.Goal && refined.DefinitionOfDone == spec.DefinitionOfDone &&
+		strings.Join(refined.Constraints, "\n") == strings.Join(spec.Constraints, "\n") {
+		fmt.Fprintln(out, "\nNo changes; using the original spec.")
+		return path, nil
+	}
+
+	refinedPath := coach.RefinedPath(path)
+	if err := coach.WriteSpec(refinedPath, refined); err != nil {
+		return "", fmt.Errorf("failed to write refined spec: %w", err)
+	}
+	fmt.Fprintf(out, "\nRefined spec written to %s\n", refinedPath)
+	return refinedPath, nil
+}
@@ -4,20 +4,80 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/felixgeelhaar/simon/internal/credential"
+	"github.com/felixgeelhaar/simon/internal/guard"
+	"github.com/felixgeelhaar/simon/internal/setup"
 	"github.com/felixgeelhaar/simon/internal/store"
 )
 
-func getStore() store.Storage {
+// profileName selects an isolated profile under ~/.simon/profiles/<name>.
+// Empty means the default profile rooted at ~/.simon.
+var profileName string
+
+// simonDir returns the root directory for the active profile.
+func simonDir() string {
 	home, _ := os.UserHomeDir()
-	simonDir := filepath.Join(home, ".simon")
-	storeLayer, err := store.NewSQLiteStore(
-		filepath.Join(simonDir, "metadata.db"),
-		filepath.Join(simonDir, "artifacts"),
-	)
+	root := filepath.Join(home, ".simon")
+	if profileName == "" {
+		return root
+	}
+	return filepath.Join(root, "profiles", profileName)
+}
+
+// validateProfileName rejects names that would escape the profiles directory.
+func validateProfileName(name string) error {
+	if name == "" {
+		return nil
+	}
+	if strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return fmt.Errorf("invalid profile name: %q", name)
+	}
+	return nil
+}
+
+// loadProject returns the .simon.yaml of the repository simon runs in, nil
+// outside of one.
+func loadProject() (*setup.Project, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	return setup.FindProject(wd)
+}
+
+// openStore opens the SQLite store for the active profile, decrypting
+// encrypted history transparently. Config keys it doesn't set fall back to
+// the project's.
+func openStore() (*store.SQLiteStore, error) {
+	project, err := loadProject()
+	if err != nil {
+		return nil, err
+	}
+	s, err := setup.OpenStore(simonDir(), project)
+	if err != nil {
+		return nil, err
+	}
+	setup.EncryptHistory(s, func() (*credential.Manager, error) { return credentialManager(s) })
+	return s, nil
+}
+
+func getStore() store.Storage {
+	storeLayer, err := openStore()
 	if err != nil {
 		fmt.Printf("Failed to init store: %v\n", err)
 		os.Exit(1)
 	}
 	return storeLayer
 }
+
+// loadPolicy returns the active profile's policy.yaml if present over the
+// project's policy, falling back to guard.DefaultPolicy.
+func loadPolicy() (guard.Policy, error) {
+	project, err := loadProject()
+	if err != nil {
+		return guard.Policy{}, err
+	}
+	return setup.LoadPolicy(simonDir(), project)
+}
@@ -0,0 +1,257 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/coach"
+	"github.com/felixgeelhaar/simon/internal/mcp"
+	"github.com/felixgeelhaar/simon/internal/provider"
+	"github.com/felixgeelhaar/simon/internal/runtime"
+	"github.com/felixgeelhaar/simon/internal/store"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var compareSpec bool
+
+var compareCmd = &cobra.Command{
+	Use:   "compare <session-a> <session-b>",
+	Short: "Compare two sessions side by side",
+	Long: `Compare two sessions side by side: outcome, iterations, tokens, cost,
+duration, files changed, tool calls, and guard violations, with the change
+from the first to the second. Use it to evaluate prompt or policy changes
+between runs of the same task; --spec adds a diff of the specs they ran with.
+
+Examples:
+  simon compare session-1712345678 session-1712349999
+  simon compare session-1712345678 session-1712349999 --spec`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeSessionIDs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		s := getStore()
+		defer s.Close()
+
+		if err := compareSessions(os.Stdout, s, logDir(), args[0], args[1], compareSpec); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+// sessionStats is what simon compare shows for one session.
+type sessionStats struct {
+	*store.Session
+	Iterations int
+	Files      int // -1 when no change manifest was recorded
+	Tools      map[string]int
+	Violations map[string]int
+}
+
+// loadSessionStats gathers a session's iterations and tool calls from its
+// conversation, changed files from its manifest, and guard violations from
+// its log.
+func loadSessionStats(s store.Storage, logDir, id string) (sessionStats, error) {
+	sess, err := s.GetSession(id)
+	if err != nil {
+		return sessionStats{}, err
+	}
+	stats := sessionStats{Session: sess, Files: -1, Tools: make(map[string]int), Violations: make(map[string]int)}
+
+	messages, err := s.LoadMessages(id)
+	if err != nil {
+		return stats, fmt.Errorf("failed to load messages of %s: %w", id, err)
+	}
+	for _, m := range messages {
+		if m.Role != "assistant" {
+			continue
+		}
+		stats.Iterations++
+		var calls []provider.ToolCall
+		if m.ToolCalls != "" && json.Unmarshal([]byte(m.ToolCalls), &calls) == nil {
+			for _, c := range calls {
+				stats.Tools[c.Name]++
+			}
+		}
+	}
+
+	artifacts, err := s.ListArtifacts(id)
+	if err != nil {
+		return stats, fmt.Errorf("failed to list artifacts of %s: %w", id, err)
+	}
+	for _, a := range artifacts {
+		if a.Type != runtime.ArtifactFileManifest {
+			continue
+		}
+		if _, content, err := s.GetArtifact(a.ID); err == nil {
+			var changes []mcp.FileChange
+			if json.Unmarshal(content, &changes) == nil {
+				stats.Files = len(changes)
+			}
+		}
+	}
+
+	scanSessionLog(sessionLogPath(logDir, id), stats.Violations, make(map[string]*reportTool))
+	return stats, nil
+}
+
+// compareSessions writes a side-by-side summary of sessions a and b to out,
+// followed by a diff of their specs when withSpec is set.
+func compareSessions(out io.Writer, s store.Storage, logDir, idA, idB string, withSpec bool) error {
+	a, err := loadSessionStats(s, logDir, idA)
+	if err != nil {
+		return err
+	}
+	b, err := loadSessionStats(s, logDir, idB)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "\t%s\t%s\t\n", a.ID, b.ID)
+	fmt.Fprintf(w, "Status\t%s\t%s\t\n", a.Status, b.Status)
+	fmt.Fprintf(w, "Spec\t%s\t%s\t\n", orDash(a.Metadata["spec"]), orDash(b.Metadata["spec"]))
+	fmt.Fprintf(w, "Model\t%s\t%s\t\n", modelLabel(a.Session), modelLabel(b.Session))
+	compareInt(w, "Iterations", a.Iterations, b.Iterations)
+	compareInt(w, "Prompt tokens", a.PromptTokens, b.PromptTokens)
+	compareInt(w, "Completion tokens", a.CompletionTokens, b.CompletionTokens)
+	fmt.Fprintf(w, "Cost\t$%.4f\t$%.4f\t%s\n", a.Cost, b.Cost, signed(b.Cost-a.Cost, "$%.4f"))
+	if durA, durB := a.UpdatedAt.Sub(a.CreatedAt), b.UpdatedAt.Sub(b.CreatedAt); durA >= 0 && durB >= 0 {
+		durA, durB = durA.Round(time.Second), durB.Round(time.Second)
+		fmt.Fprintf(w, "Duration\t%s\t%s\t%s\n", durA, durB, signedDuration(durB-durA))
+	}
+	if a.Files >= 0 && b.Files >= 0 {
+		compareInt(w, "Files changed", a.Files, b.Files)
+	}
+
+	for _, section := range []struct {
+		title string
+		a, b  map[string]int
+	}{
+		{"Tool calls", a.Tools, b.Tools},
+		{"Guard violations", a.Violations, b.Violations},
+	} {
+		names := unionKeys(section.a, section.b)
+		if len(names) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t\t\t\n", section.title)
+		for _, name := range names {
+			compareInt(w, "  "+name, section.a[name], section.b[name])
+		}
+	}
+	w.Flush()
+
+	if !withSpec {
+		return nil
+	}
+	specA, errA := sessionSpecText(s, a.Session)
+	specB, errB := sessionSpecText(s, b.Session)
+	switch {
+	case errA != nil:
+		fmt.Fprintf(out, "\nSpec diff unavailable: %v\n", errA)
+	case errB != nil:
+		fmt.Fprintf(out, "\nSpec diff unavailable: %v\n", errB)
+	default:
+		if diff := mcp.UnifiedDiff("spec", specA, specB); diff == "" {
+			fmt.Fprintln(out, "\nSpecs are identical.")
+		} else {
+			fmt.Fprintf(out, "\n%s", diff)
+		}
+	}
+	return nil
+}
+
+// sessionSpecText renders the spec a session ran with as YAML.
+func sessionSpecText(s store.Storage, sess *store.Session) (string, error) {
+	spec, err := sessionSpec(s, sess)
+	if err != nil {
+		return "", err
+	}
+	data, err := yaml.Marshal(spec)
+	return string(data), err
+}
+
+// sessionSpec returns the spec a session ran with: its spec snapshot if one
+// was stored, or else the spec file as it is now.
+func sessionSpec(s store.Storage, sess *store.Session) (coach.TaskSpec, error) {
+	var spec coach.TaskSpec
+	if _, data, err := s.GetArtifact(fmt.Sprintf("art-%s-%s", sess.ID, runtime.ArtifactSpec)); err == nil {
+		if err := json.Unmarshal(data, &spec); err != nil {
+			return spec, fmt.Errorf("invalid spec for session %s: %w", sess.ID, err)
+		}
+		return spec, nil
+	}
+	if path := sess.Metadata["spec"]; path != "" {
+		loaded, err := coach.New().LoadSpec(path)
+		if err != nil {
+			return spec, err
+		}
+		return *loaded, nil
+	}
+	return spec, fmt.Errorf("session %s has no spec", sess.ID)
+}
+
+func compareInt(w io.Writer, label string, a, b int) {
+	fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", label, a, b, signed(float64(b-a), "%.0f"))
+}
+
+// signed formats a difference with its sign, or "" when there is none.
+func signed(d float64, format string) string {
+	switch {
+	case d > 0:
+		return "+" + fmt.Sprintf(format, d)
+	case d < 0:
+		return "-" + fmt.Sprintf(format, -d)
+	}
+	return ""
+}
+
+func signedDuration(d time.Duration) string {
+	switch {
+	case d > 0:
+		return "+" + d.String()
+	case d < 0:
+		return "-" + (-d).String()
+	}
+	return ""
+}
+
+func modelLabel(sess *store.Session) string {
+	if sess.Provider == "" {
+		return "-"
+	}
+	return fmt.Sprintf("%s (%s)", sess.Model, sess.Provider)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func unionKeys(a, b map[string]int) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, m := range []map[string]int{a, b} {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func init() {
+	RootCmd.AddCommand(compareCmd)
+	compareCmd.Flags().BoolVar(&compareSpec, "spec", false, "Also diff the specs the sessions ran with")
+}
@@ -0,0 +1,189 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/felixgeelhaar/simon/internal/notify"
+	"github.com/felixgeelhaar/simon/internal/store"
+	"github.com/spf13/cobra"
+)
+
+// maxSessionCompletions bounds the session IDs offered, newest first.
+const maxSessionCompletions = 100
+
+// knownConfigKeys are the configuration keys simon reads, offered for
+// completion together with the keys already set in the store.
+var knownConfigKeys = []string{
+	"openai.api_key", "openai.base_url", "anthropic.api_key", "anthropic.thinking_budget", "gemini.api_key",
+	"mistral.api_key", "mistral.base_url", "groq.api_key", "groq.base_url", "huggingface.endpoint",
+	"huggingface.embed_endpoint", "huggingface.api_key", "ollama.host",
+	"lmstudio.base_url", "llamacpp.base_url",
+	"provider.default", "provider.model", "provider.plugin.path", "provider.fixture.path", "provider.cli.path",
+	"provider.rate_limit", "provider.retry.attempts", "provider.retry.backoff",
+	"orchestrate.planner.provider", "orchestrate.planner.model",
+	"orchestrate.executor.provider", "orchestrate.executor.model",
+	"orchestrate.reviewer.provider", "orchestrate.reviewer.model",
+	"cache.enabled", "memory.search", "artifacts.max_size", "verify.plugins", "reducer.plugins", "history.encrypt", keyServeConcurrency,
+	notify.KeySlackWebhook, notify.KeyDiscordWebhook, notify.KeySMTPAddr, notify.KeySMTPUsername,
+	notify.KeySMTPPassword, notify.KeyEmailFrom, notify.KeyEmailTo,
+}
+
+var completionCmd = &cobra.Command{
+	Use:   "completion [bash|zsh|fish|powershell]",
+	Short: "Generate a shell completion script",
+	Long: `Generate a completion script for your shell. Besides commands and flags, it
+completes session IDs, config keys, and profile names from the store.
+
+Examples:
+  # Bash (needs the bash-completion package)
+  source <(simon completion bash)
+  simon completion bash > /etc/bash_completion.d/simon
+
+  # Zsh (compinit must be enabled)
+  simon completion zsh > "${fpath[1]}/_simon"
+
+  # Fish
+  simon completion fish > ~/.config/fish/completions/simon.fish
+
+  # PowerShell
+  simon completion powershell | Out-String | Invoke-Expression`,
+	ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+	Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+		switch args[0] {
+		case "bash":
+			err = RootCmd.GenBashCompletionV2(os.Stdout, true)
+		case "zsh":
+			err = RootCmd.GenZshCompletion(os.Stdout)
+		case "fish":
+			err = RootCmd.GenFishCompletion(os.Stdout, true)
+		case "powershell":
+			err = RootCmd.GenPowerShellCompletionWithDesc(os.Stdout)
+		}
+		if err != nil {
+			fmt.Printf("Failed to generate completion: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// completeSessionIDs completes the positional session IDs of a command
+// taking n of them.
+func completeSessionIDs(n int) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) >= n {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return completeSessionFlag(cmd, args, toComplete)
+	}
+}
+
+// completeSessionFlag completes a session ID from the active profile's store.
+func completeSessionFlag(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	s, err := openStore()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	defer s.Close()
+	return sessionCompletions(s, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// sessionCompletions lists the IDs of the newest sessions starting with
+// prefix, described by their status.
+func sessionCompletions(s store.Storage, prefix string) []string {
+	sessions, err := s.ListSessions(store.SessionFilter{Limit: maxSessionCompletions})
+	if err != nil {
+		return nil
+	}
+	var out []string
+	for _, sess := range sessions {
+		if strings.HasPrefix(sess.ID, prefix) {
+			out = append(out, sess.ID+"\t"+sess.Status)
+		}
+	}
+	return out
+}
+
+// completeConfigKey completes the key argument of config get and set.
+func completeConfigKey(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	s, err := openStore()
+	if err != nil {
+		return configKeyCompletions(nil, toComplete), cobra.ShellCompDirectiveNoFileComp
+	}
+	defer s.Close()
+	return configKeyCompletions(s, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// configKeyCompletions lists the known config keys and those set in s
+// (when it can list them) starting with prefix, sorted.
+func configKeyCompletions(s store.Storage, prefix string) []string {
+	keys := make(map[string]bool)
+	for _, key := range knownConfigKeys {
+		keys[key] = true
+	}
+	if lister, ok := s.(interface {
+		ListConfig() (map[string]string, error)
+	}); ok {
+		if config, err := lister.ListConfig(); err == nil {
+			for key := range config {
+				keys[key] = true
+			}
+		}
+	}
+	var out []string
+	for key := range keys {
+		if strings.HasPrefix(key, prefix) {
+			out = append(out, key)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// completeProfile completes profile names for --profile.
+func completeProfile(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	var out []string
+	for _, name := range profileNames() {
+		if strings.HasPrefix(name, toComplete) {
+			out = append(out, name)
+		}
+	}
+	return out, cobra.ShellCompDirectiveNoFileComp
+}
+
+// profileNames lists the profiles under ~/.simon/profiles.
+func profileNames() []string {
+	home, _ := os.UserHomeDir()
+	entries, err := os.ReadDir(filepath.Join(home, ".simon", "profiles"))
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	return names
+}
+
+// completeSpecFile offers YAML and JSON files for a spec argument.
+func completeSpecFile(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return []string{"yaml", "yml", "json"}, cobra.ShellCompDirectiveFilterFileExt
+}
+
+func init() {
+	RootCmd.AddCommand(completionCmd)
+}
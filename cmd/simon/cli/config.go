@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/felixgeelhaar/simon/internal/credential"
+	"github.com/felixgeelhaar/simon/internal/setup"
 	"github.com/spf13/cobra"
 )
 
@@ -15,13 +16,15 @@ var sensitiveKeys = []string{
 	"anthropic_api_key",
 	"gemini_api_key",
 	"api_key",
+	"huggingface.api_key",
 }
 
 // isSensitiveKey checks if a configuration key should be encrypted.
 func isSensitiveKey(key string) bool {
 	keyLower := strings.ToLower(key)
 	for _, sensitive := range sensitiveKeys {
-		if keyLower == sensitive || strings.HasSuffix(keyLower, "_api_key") || strings.HasSuffix(keyLower, "_secret") {
+		if keyLower == sensitive || strings.HasSuffix(keyLower, "_api_key") || strings.HasSuffix(keyLower, "_secret") ||
+			strings.HasSuffix(keyLower, "_password") || strings.HasSuffix(keyLower, "webhook_url") {
 			return true
 		}
 	}
@@ -40,9 +43,20 @@ var configSetCmd = &cobra.Command{
 
 Sensitive keys (automatically encrypted):
   - Any key ending in _api_key
-  - Any key ending in _secret
-  - openai_api_key, anthropic_api_key, gemini_api_key`,
-	Args: cobra.ExactArgs(2),
+  - Any key ending in _secret, _password, or webhook_url
+  - openai_api_key, anthropic_api_key, gemini_api_key
+
+history.encrypt true encrypts, from then on, the conversation messages and
+the request snapshots of each iteration. Artifacts (tool outputs, summaries,
+plans, reasoning, specs, diffs), memories, and session metadata stay in
+plain text, since they are searched and shown without the key.
+
+Examples:
+  simon config set provider.default openai
+  simon config set openai.api_key sk-...
+  simon --profile work config set provider.model gpt-4o`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeConfigKey,
 	Run: func(cmd *cobra.Command, args []string) {
 		key := args[0]
 		value := args[1]
@@ -52,7 +66,7 @@ Sensitive keys (automatically encrypted):
 
 		// Encrypt sensitive values
 		if isSensitiveKey(key) {
-			credMgr, err := credential.NewManager()
+			credMgr, err := credentialManager(s)
 			if err != nil {
 				fmt.Printf("Failed to initialize credential manager: %v\n", err)
 				os.Exit(1)
@@ -72,14 +86,18 @@ Sensitive keys (automatically encrypted):
 			os.Exit(1)
 		}
 		fmt.Printf("Configuration saved: %s\n", key)
+		if key == setup.ConfigHistoryEncrypt && value == "true" {
+			fmt.Println("Messages and snapshots are encrypted from now on; artifacts and memories stay in plain text.")
+		}
 	},
 }
 
 var configGetCmd = &cobra.Command{
-	Use:   "get [key]",
-	Short: "Get a configuration value",
-	Long:  `Get a configuration value. Encrypted values are automatically decrypted.`,
-	Args:  cobra.ExactArgs(1),
+	Use:               "get [key]",
+	Short:             "Get a configuration value",
+	Long:              `Get a configuration value. Encrypted values are automatically decrypted.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeConfigKey,
 	Run: func(cmd *cobra.Command, args []string) {
 		key := args[0]
 
@@ -98,7 +116,7 @@ var configGetCmd = &cobra.Command{
 
 		// Decrypt if encrypted
 		if credential.IsEncrypted(val) {
-			credMgr, err := credential.NewManager()
+			credMgr, err := credentialManager(s)
 			if err != nil {
 				fmt.Printf("Failed to initialize credential manager: %v\n", err)
 				os.Exit(1)
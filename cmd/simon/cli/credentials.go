@@ -0,0 +1,206 @@
+package cli
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+
+	"github.com/felixgeelhaar/simon/internal/credential"
+	"github.com/felixgeelhaar/simon/internal/setup"
+	"github.com/felixgeelhaar/simon/internal/store"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// newPassphraseEnv holds the new passphrase for rotate-key.
+const newPassphraseEnv = "SIMON_NEW_PASSPHRASE"
+
+var (
+	rotateKeyPassphrase bool
+	rotateKeyMachine    bool
+)
+
+// cachedCredentials keeps the passphrase-derived manager so the passphrase
+// is asked once per process.
+var cachedCredentials struct {
+	salt    string
+	manager *credential.Manager
+}
+
+var configRotateKeyCmd = &cobra.Command{
+	Use:   "rotate-key",
+	Short: "Re-encrypt all stored secrets under a new key",
+	Long: `Re-encrypt every encrypted configuration value, and the conversation history
+encrypted with history.encrypt, under a new key, in one transaction.
+
+With --passphrase the new key is derived from a passphrase (PBKDF2-SHA256 with
+a random salt) rather than from this machine, so a ~/.simon synced across
+machines can be decrypted on each of them. simon then asks for the passphrase
+whenever it needs a secret, or reads it from SIMON_PASSPHRASE. Rotating again
+without flags changes the passphrase; --machine switches back to the
+machine-derived key. The new passphrase is asked twice, or read from
+SIMON_NEW_PASSPHRASE.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		s, err := openStore()
+		if err != nil {
+			fmt.Printf("Failed to init store: %v\n", err)
+			os.Exit(1)
+		}
+		defer s.Close()
+
+		mode, _ := s.GetConfig(setup.ConfigCredentialMode)
+		passphrase := rotateKeyPassphrase || (mode == setup.CredentialModePassphrase && !rotateKeyMachine)
+		if !passphrase && mode != setup.CredentialModePassphrase {
+			fmt.Println("Secrets already use the machine-derived key; use --passphrase to switch to a passphrase.")
+			os.Exit(1)
+		}
+
+		current, err := credentialManager(s)
+		if err != nil {
+			fmt.Printf("Failed to load the current key: %v\n", err)
+			os.Exit(1)
+		}
+		var next *credential.Manager
+		var salt []byte
+		if passphrase {
+			if next, salt, err = newPassphraseManager(); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		} else if next, err = credential.NewManager(); err != nil {
+			fmt.Printf("Failed to initialize credential manager: %v\n", err)
+			os.Exit(1)
+		}
+
+		n, history, err := rotateKey(s, current, next, salt)
+		if err != nil {
+			fmt.Printf("Key rotation failed, nothing was changed: %v\n", err)
+			os.Exit(1)
+		}
+		if passphrase {
+			fmt.Printf("Re-encrypted %d secrets under a passphrase-derived key.\n", n)
+		} else {
+			fmt.Printf("Re-encrypted %d secrets under the machine-derived key.\n", n)
+		}
+		if history > 0 {
+			fmt.Printf("Re-encrypted %d conversation history entries.\n", history)
+		}
+	},
+}
+
+// credentialManager returns the manager for the store's secrets: the
+// machine-derived key, or in passphrase mode the key derived from the
+// passphrase, which is read from SIMON_PASSPHRASE or asked for.
+func credentialManager(s store.Storage) (*credential.Manager, error) {
+	mode, _ := s.GetConfig(setup.ConfigCredentialMode)
+	encoded, _ := s.GetConfig(setup.ConfigCredentialSalt)
+	passphrase := mode == setup.CredentialModePassphrase
+	if passphrase && cachedCredentials.manager != nil && cachedCredentials.salt == encoded {
+		return cachedCredentials.manager, nil
+	}
+	m, err := setup.CredentialManager(s, func() (string, error) {
+		return readPassphrase(setup.PassphraseEnv, "Credential passphrase: ")
+	})
+	if err != nil {
+		return nil, err
+	}
+	if passphrase {
+		cachedCredentials.salt, cachedCredentials.manager = encoded, m
+	}
+	return m, nil
+}
+
+// newPassphraseManager asks for a new passphrase twice, or reads it from
+// SIMON_NEW_PASSPHRASE, and derives a key from it with a fresh salt.
+func newPassphraseManager() (*credential.Manager, []byte, error) {
+	passphrase, err := readPassphrase(newPassphraseEnv, "New passphrase: ")
+	if err != nil {
+		return nil, nil, err
+	}
+	if os.Getenv(newPassphraseEnv) == "" {
+		again, err := readPassphrase(newPassphraseEnv, "Repeat the new passphrase: ")
+		if err != nil {
+			return nil, nil, err
+		}
+		if again != passphrase {
+			return nil, nil, fmt.Errorf("passphrases don't match")
+		}
+	}
+	salt, err := credential.NewSalt()
+	if err != nil {
+		return nil, nil, err
+	}
+	m, err := credential.NewPassphraseManager(passphrase, salt)
+	return m, salt, err
+}
+
+// readPassphrase reads a passphrase from envVar, or asks for it on the
+// terminal without echoing it.
+func readPassphrase(envVar, prompt string) (string, error) {
+	if v := os.Getenv(envVar); v != "" {
+		return v, nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("a passphrase is required; set %s", envVar)
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	b, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return string(b), err
+}
+
+// rotateKey re-encrypts every encrypted config value and the encrypted
+// conversation history from current to next, and records next's mode:
+// passphrase when salt is set, machine otherwise. Nothing is written unless
+// every value decrypts. It returns the number of secrets and of history
+// entries re-encrypted.
+func rotateKey(s *store.SQLiteStore, current, next *credential.Manager, salt []byte) (secrets, history int, err error) {
+	config, err := s.ListConfig()
+	if err != nil {
+		return 0, 0, err
+	}
+	updates := make(map[string]string)
+	for key, value := range config {
+		if key == setup.ConfigCredentialCheck || !credential.IsEncrypted(value) {
+			continue
+		}
+		rotated, err := current.Reencrypt(value, next)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to decrypt %s with the current key: %w", key, err)
+		}
+		updates[key] = rotated
+	}
+	secrets = len(updates)
+
+	updates[setup.ConfigCredentialMode], updates[setup.ConfigCredentialSalt], updates[setup.ConfigCredentialCheck] = "", "", ""
+	if salt != nil {
+		check, err := next.NewCheck()
+		if err != nil {
+			return 0, 0, err
+		}
+		updates[setup.ConfigCredentialMode] = setup.CredentialModePassphrase
+		updates[setup.ConfigCredentialSalt] = base64.StdEncoding.EncodeToString(salt)
+		updates[setup.ConfigCredentialCheck] = check
+	}
+	history, err = s.ReencryptHistory(credential.NewHistoryCipher(constKey(current)), credential.NewHistoryCipher(constKey(next)), updates)
+	if err != nil {
+		return 0, 0, err
+	}
+	cachedCredentials.manager = nil
+	return secrets, history, nil
+}
+
+// constKey returns a key function for credential.NewHistoryCipher that
+// always returns m.
+func constKey(m *credential.Manager) func() (*credential.Manager, error) {
+	return func() (*credential.Manager, error) { return m, nil }
+}
+
+func init() {
+	configCmd.AddCommand(configRotateKeyCmd)
+	configRotateKeyCmd.Flags().BoolVar(&rotateKeyPassphrase, "passphrase", false, "Derive the new key from a passphrase instead of this machine")
+	configRotateKeyCmd.Flags().BoolVar(&rotateKeyMachine, "machine", false, "Switch back to the machine-derived key")
+	configRotateKeyCmd.MarkFlagsMutuallyExclusive("passphrase", "machine")
+}
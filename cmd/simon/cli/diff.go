@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/felixgeelhaar/simon/internal/mcp"
+	"github.com/spf13/cobra"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <artifact-a> <artifact-b>",
+	Short: "Show a unified diff between two artifacts",
+	Long: `Show a unified diff between two stored artifacts, e.g. the outputs of a test
+command in different iterations. Artifacts are given by ID or by their path
+as reported in tool summaries (artifacts/<session-id>/<name>).`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		s := getStore()
+		defer s.Close()
+
+		diff, err := mcp.DiffArtifacts(s, "", args[0], args[1])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if diff == "" {
+			fmt.Println("Artifacts are identical.")
+			return
+		}
+		fmt.Print(diff)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(diffCmd)
+}
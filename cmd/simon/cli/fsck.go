@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/felixgeelhaar/simon/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	fsckMarkMissing   bool
+	fsckDeleteOrphans bool
+)
+
+var fsckCmd = &cobra.Command{
+	Use:   "fsck",
+	Short: "Check every artifact's content against its SHA-256",
+	Long: `Read the content of every artifact in the active profile's store and check it
+against the SHA-256 it was stored under, then look for files in the blob store
+that no artifact refers to. Reading an artifact checks it too: simon artifact
+get, read_artifact, and backups fail with a corruption error instead of
+returning altered content.
+
+--mark-missing marks artifacts whose content is missing or corrupt, so that
+reads fail fast and backups skip them, and removes corrupt blobs so that the
+same content saved again is rewritten; a later fsck unmarks artifacts whose
+content is back. --delete-orphans removes the unreferenced files. Files
+written in the last minute are never reported, as a running session may be
+saving them.
+
+The exit status is 1 when problems were found that weren't repaired.
+
+Examples:
+  simon fsck
+  simon fsck --mark-missing --delete-orphans`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		s, err := openStore()
+		if err != nil {
+			fmt.Printf("Failed to init store: %v\n", err)
+			os.Exit(1)
+		}
+		defer s.Close()
+
+		opts := store.FsckOptions{MarkMissing: fsckMarkMissing, DeleteOrphans: fsckDeleteOrphans}
+		report, err := s.CheckArtifacts(opts)
+		if err != nil {
+			fmt.Printf("Check failed: %v\n", err)
+			os.Exit(1)
+		}
+		if !printFsckReport(os.Stdout, report, opts) {
+			os.Exit(1)
+		}
+	},
+}
+
+// printFsckReport writes the problems a check found and the repairs made to
+// out, and reports whether every problem was repaired, artifacts marked by
+// an earlier check counting as repaired.
+func printFsckReport(out io.Writer, report *store.FsckReport, opts store.FsckOptions) bool {
+	unmarked := 0
+	problem := func(kind string, a *store.Artifact) {
+		note := ""
+		if a.Missing {
+			note = ", marked missing"
+		} else {
+			unmarked++
+		}
+		fmt.Fprintf(out, "%-8s %s  %s (session %s%s)\n", kind, a.ID, a.Path, a.SessionID, note)
+	}
+	for _, a := range report.Missing {
+		problem("missing", a)
+	}
+	for _, a := range report.Corrupt {
+		problem("corrupt", a)
+	}
+	for _, path := range report.Orphans {
+		fmt.Fprintf(out, "%-8s %s\n", "orphan", path)
+	}
+
+	fmt.Fprintf(out, "Checked %d artifacts: %d missing, %d corrupt, %d orphaned files.\n",
+		report.Checked, len(report.Missing), len(report.Corrupt), len(report.Orphans))
+	if report.Marked > 0 {
+		fmt.Fprintf(out, "Marked %d artifacts missing.\n", report.Marked)
+	}
+	if report.Restored > 0 {
+		fmt.Fprintf(out, "Unmarked %d artifacts whose content is back.\n", report.Restored)
+	}
+	if report.Deleted > 0 {
+		fmt.Fprintf(out, "Removed %d files.\n", report.Deleted)
+	}
+
+	repaired := true
+	if unmarked > 0 {
+		fmt.Fprintln(out, "Run with --mark-missing to mark the missing and corrupt artifacts.")
+		repaired = false
+	}
+	if len(report.Orphans) > 0 && !opts.DeleteOrphans {
+		fmt.Fprintln(out, "Run with --delete-orphans to remove the orphaned files.")
+		repaired = false
+	}
+	return repaired
+}
+
+func init() {
+	RootCmd.AddCommand(fsckCmd)
+	fsckCmd.Flags().BoolVar(&fsckMarkMissing, "mark-missing", false, "Mark artifacts whose content is missing or corrupt, and remove corrupt blobs")
+	fsckCmd.Flags().BoolVar(&fsckDeleteOrphans, "delete-orphans", false, "Remove blob files no artifact refers to")
+}
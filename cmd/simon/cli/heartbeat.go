@@ -0,0 +1,173 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/runtime"
+	"github.com/felixgeelhaar/simon/internal/schedule"
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+// Config keys for session heartbeats.
+const (
+	keyServeHeartbeat  = "serve.heartbeat_interval"
+	keyServeStaleAfter = "serve.stale_after"
+	keyServeAutoResume = "serve.auto_resume"
+)
+
+const (
+	// defaultHeartbeatInterval is how often a running session sends a
+	// heartbeat when serve.heartbeat_interval is unset.
+	defaultHeartbeatInterval = 15 * time.Second
+	// heartbeatRetry is the wait before retrying a failed heartbeat, such
+	// as one sent before the runner created the session.
+	heartbeatRetry = time.Second
+)
+
+// heartbeatOptions configures session heartbeats and the reaper.
+type heartbeatOptions struct {
+	Interval   time.Duration // How often a running session sends a heartbeat
+	StaleAfter time.Duration // Heartbeat age at which a session is orphaned
+	AutoResume bool          // Queue a session continuing each orphaned one
+}
+
+// heartbeatConfig reads the heartbeat config keys. Sessions are orphaned
+// after four missed heartbeats unless serve.stale_after says otherwise.
+func heartbeatConfig(s store.Storage) (heartbeatOptions, error) {
+	opts := heartbeatOptions{Interval: defaultHeartbeatInterval}
+	if v, _ := s.GetConfig(keyServeHeartbeat); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return opts, fmt.Errorf("invalid %s: %q", keyServeHeartbeat, v)
+		}
+		opts.Interval = d
+	}
+	opts.StaleAfter = 4 * opts.Interval
+	if v, _ := s.GetConfig(keyServeStaleAfter); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= opts.Interval {
+			return opts, fmt.Errorf("invalid %s: %q (must be a duration longer than %s)", keyServeStaleAfter, v, opts.Interval)
+		}
+		opts.StaleAfter = d
+	}
+	v, _ := s.GetConfig(keyServeAutoResume)
+	opts.AutoResume = v == "true"
+	return opts, nil
+}
+
+// heartbeatOwner names this process in the heartbeats it sends.
+func heartbeatOwner() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
+}
+
+// heartbeat sends heartbeats for a session until the returned function is
+// called.
+func (d *daemon) heartbeat(sessionID string) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		timer := time.NewTimer(0)
+		defer timer.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-timer.C:
+			}
+			wait := d.beats.Interval
+			if err := d.store.Heartbeat(sessionID, d.owner); err != nil {
+				wait = min(wait, heartbeatRetry)
+			}
+			timer.Reset(wait)
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// reapEvery runs reap every heartbeat interval until ctx is done.
+func (d *daemon) reapEvery(ctx context.Context, submit func(schedule.Job) (schedule.Job, error)) {
+	ticker := time.NewTicker(d.beats.Interval)
+	defer ticker.Stop()
+	for {
+		d.reap(submit)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reap marks the sessions whose heartbeats stopped, and their unfinished
+// sub-tasks, as orphaned, so they don't stay "running" after their process
+// died. With auto-resume, it submits a job continuing each orphaned
+// top-level session.
+func (d *daemon) reap(submit func(schedule.Job) (schedule.Job, error)) {
+	cutoff := time.Now().Add(-d.beats.StaleAfter)
+	stale, err := d.store.StaleSessions(cutoff)
+	if err != nil {
+		d.obs.Log().Warn().Err(err).Msg("failed to list stale sessions")
+		return
+	}
+	for _, session := range stale {
+		orphaned, err := d.store.OrphanSession(session.ID, cutoff)
+		if err != nil {
+			d.obs.Log().Warn().Str("session", session.ID).Err(err).Msg("failed to orphan session")
+			continue
+		}
+		if !orphaned {
+			continue
+		}
+		d.obs.Log().Warn().Str("session", session.ID).Str("status", session.Status).Msg("session stopped sending heartbeats, marked orphaned")
+		d.orphanSubtasks(session.ID)
+
+		if !d.beats.AutoResume || session.ParentID != "" || session.Metadata["spec"] == "" {
+			continue
+		}
+		job := schedule.Job{
+			SpecPath: session.Metadata["spec"],
+			Provider: session.Provider,
+			Model:    session.Model,
+			Tags:     session.Tags,
+			Vars:     runtime.SessionVars(session),
+			Previous: session.ID,
+		}
+		if err := d.validate(&job); err != nil {
+			d.obs.Log().Warn().Str("session", session.ID).Err(err).Msg("cannot resume orphaned session")
+			continue
+		}
+		queued, err := submit(job)
+		if err != nil {
+			d.obs.Log().Warn().Str("session", session.ID).Err(err).Msg("cannot resume orphaned session")
+			continue
+		}
+		d.obs.Log().Info().Str("session", session.ID).Str("resumed_as", queued.ID).Msg("resuming orphaned session")
+	}
+}
+
+// orphanSubtasks marks the unfinished sub-tasks of an orphaned session as
+// orphaned; they ran in its process and send no heartbeats of their own.
+func (d *daemon) orphanSubtasks(parentID string) {
+	children, err := d.store.ListSessions(store.SessionFilter{ParentID: parentID})
+	if err != nil {
+		d.obs.Log().Warn().Str("session", parentID).Err(err).Msg("failed to list sub-tasks")
+		return
+	}
+	for _, child := range children {
+		if store.SessionFinished(child.Status) {
+			continue
+		}
+		child.Status = store.StatusOrphaned
+		if err := d.store.UpdateSession(child); err != nil {
+			d.obs.Log().Warn().Str("session", child.ID).Err(err).Msg("failed to orphan sub-task")
+		}
+	}
+}
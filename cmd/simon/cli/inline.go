@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/felixgeelhaar/simon/internal/coach"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	inlineGoal     string
+	inlineDoD      string
+	inlineEvidence []string
+	inlineVerify   []string
+)
+
+// stdinSpec is the spec argument that reads the spec from standard input.
+const stdinSpec = "-"
+
+// hasInlineSpec reports whether any of the flags building an ad-hoc spec is set.
+func hasInlineSpec() bool {
+	return inlineGoal != "" || inlineDoD != "" || len(inlineEvidence) > 0 || len(inlineVerify) > 0
+}
+
+// inlineSpec builds a spec from --goal, --dod, --evidence, and --verify.
+// The definition of done defaults to the goal.
+func inlineSpec() (coach.TaskSpec, error) {
+	if inlineGoal == "" {
+		return coach.TaskSpec{}, errors.New("--dod, --evidence, and --verify need --goal")
+	}
+	spec := coach.TaskSpec{
+		Goal:             inlineGoal,
+		DefinitionOfDone: inlineDoD,
+		Constraints:      []string{},
+		Evidence:         inlineEvidence,
+		Verify:           inlineVerify,
+	}
+	if spec.DefinitionOfDone == "" {
+		spec.DefinitionOfDone = spec.Goal
+	}
+	if spec.Evidence == nil {
+		spec.Evidence = []string{}
+	}
+	return spec, nil
+}
+
+// resolveSpecArg returns the spec file for `simon run`: the argument itself,
+// or, for "-" and inline flags, a copy saved under the profile's specs
+// directory. Saved specs are named by content hash, so running the same
+// ad-hoc task again continues from its last failed run like a spec file does.
+func resolveSpecArg(args []string, stdin io.Reader) (string, error) {
+	var arg string
+	if len(args) == 1 {
+		arg = args[0]
+	}
+	switch {
+	case hasInlineSpec():
+		if arg != "" {
+			return "", errors.New("give either a spec file or --goal, not both")
+		}
+		spec, err := inlineSpec()
+		if err != nil {
+			return "", err
+		}
+		data, err := yaml.Marshal(spec)
+		if err != nil {
+			return "", err
+		}
+		return saveSpec(data)
+	case arg == stdinSpec:
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return "", fmt.Errorf("failed to read spec from stdin: %w", err)
+		}
+		if len(data) == 0 {
+			return "", errors.New("no spec on stdin")
+		}
+		return saveSpec(data)
+	}
+	return arg, nil
+}
+
+// saveSpec stores an ad-hoc spec in the profile's specs directory.
+func saveSpec(data []byte) (string, error) {
+	dir := filepath.Join(simonDir(), "specs")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	path := filepath.Join(dir, fmt.Sprintf("inline-%x.yaml", sum[:6]))
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return "", fmt.Errorf("failed to save spec: %w", err)
+	}
+	return path, nil
+}
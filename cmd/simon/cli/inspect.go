@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/felixgeelhaar/simon/internal/provider"
+	"github.com/felixgeelhaar/simon/internal/runtime"
+	"github.com/felixgeelhaar/simon/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	inspectIteration int
+	inspectJSON      bool
+)
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect <session-id>",
+	Short: "Show the context the model saw at an iteration",
+	Long: `Show the state of a session as it was when an iteration's request was sent:
+the exact messages in the model's context, the token totals so far, and the
+response. Without --iteration, lists the recorded iterations.
+
+Examples:
+  simon inspect session-1712345678
+  simon inspect session-1712345678 --iteration 7
+  simon inspect session-1712345678 --iteration 7 --json`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeSessionIDs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		s := getStore()
+		defer s.Close()
+
+		var err error
+		if inspectIteration > 0 {
+			err = inspectSnapshot(os.Stdout, s, args[0], inspectIteration, inspectJSON)
+		} else {
+			err = listSnapshots(os.Stdout, s, args[0])
+		}
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(inspectCmd)
+	inspectCmd.Flags().IntVar(&inspectIteration, "iteration", 0, "Iteration to show, counting from 1")
+	inspectCmd.Flags().BoolVar(&inspectJSON, "json", false, "Print the snapshot as JSON")
+}
+
+// listSnapshots prints one line per recorded iteration of a session.
+func listSnapshots(out io.Writer, s store.Storage, id string) error {
+	if _, err := s.GetSession(id); err != nil {
+		return err
+	}
+	snaps, err := s.ListSnapshots(id)
+	if err != nil {
+		return fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	if len(snaps) == 0 {
+		fmt.Fprintln(out, "No iterations recorded.")
+		return nil
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ITERATION\tTIME\tCONTEXT\tPROMPT\tOUTPUT\tERROR")
+	for _, snap := range snaps {
+		fmt.Fprintf(w, "%d\t%s\t%d\t%d\t%d\t%s\n", snap.Iteration, snap.CreatedAt.Format("15:04:05"),
+			snap.ContextTokens, snap.PromptTokens, snap.OutputTokens, snap.Error)
+	}
+	return w.Flush()
+}
+
+// inspectSnapshot prints the context and response of one iteration.
+func inspectSnapshot(out io.Writer, s store.Storage, id string, iteration int, asJSON bool) error {
+	snap, err := runtime.LoadSnapshot(s, id, iteration)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(snap)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Session:\t%s\n", id)
+	fmt.Fprintf(w, "Iteration:\t%d\n", snap.Iteration)
+	fmt.Fprintf(w, "Sent:\t%s\n", snap.CreatedAt.Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(w, "Status:\t%s\n", snap.Status)
+	fmt.Fprintf(w, "Context:\t%d tokens in %d messages\n", snap.ContextTokens, len(snap.Messages))
+	fmt.Fprintf(w, "Totals before:\t%d prompt, %d output tokens\n", snap.PromptTokens, snap.OutputTokens)
+	w.Flush()
+
+	for i, m := range snap.Messages {
+		fmt.Fprintf(out, "\n--- [%d] %s", i+1, m.Role)
+		if m.ToolCallID != "" {
+			fmt.Fprintf(out, " (result of %s)", m.ToolCallID)
+		}
+		fmt.Fprintln(out, " ---")
+		printMessageBody(out, m.Content, m.ToolCalls)
+	}
+
+	fmt.Fprintln(out, "\n=== Response ===")
+	switch {
+	case snap.Response != nil:
+		printMessageBody(out, snap.Response.Content, snap.Response.ToolCalls)
+		fmt.Fprintf(out, "(%d prompt, %d completion tokens)\n", snap.Response.Usage.PromptTokens, snap.Response.Usage.CompletionTokens)
+	case snap.Error != "":
+		fmt.Fprintf(out, "Request failed: %s\n", snap.Error)
+	default:
+		fmt.Fprintln(out, "No response recorded.")
+	}
+	return nil
+}
+
+func printMessageBody(out io.Writer, content string, calls []provider.ToolCall) {
+	if content != "" {
+		fmt.Fprintln(out, strings.TrimRight(content, "\n"))
+	}
+	for _, c := range calls {
+		fmt.Fprintf(out, "-> %s %s [%s]\n", c.Name, c.Args, c.ID)
+	}
+}
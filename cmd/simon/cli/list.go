@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	listTags  []string
+	listLimit int
+	listSince string
+)
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List past sessions",
+	Long: `List past sessions, newest first.
+
+Examples:
+  simon list
+  simon list --tag ticket=JIRA-123
+  simon list --tag team=payments --since 7d`,
+	Run: func(cmd *cobra.Command, args []string) {
+		tags, err := parseTags(listTags)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		window, err := parseSince(listSince)
+		if err != nil {
+			fmt.Printf("Invalid --since value: %v\n", err)
+			os.Exit(1)
+		}
+
+		s := getStore()
+		defer s.Close()
+
+		filter := store.SessionFilter{Limit: listLimit, Tags: tags}
+		if window > 0 {
+			filter.Since = time.Now().Add(-window)
+		}
+		sessions, err := s.ListSessions(filter)
+		if err != nil {
+			fmt.Printf("Failed to list sessions: %v\n", err)
+			os.Exit(1)
+		}
+		printSessions(os.Stdout, sessions)
+	},
+}
+
+// parseTags turns repeated key=value flags into a tag map.
+func parseTags(pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	tags := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid tag %q: expected key=value", pair)
+		}
+		tags[key] = strings.TrimSpace(value)
+	}
+	return tags, nil
+}
+
+// formatTags renders tags as sorted key=value pairs.
+func formatTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for key, value := range tags {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func printSessions(out io.Writer, sessions []*store.Session) {
+	if len(sessions) == 0 {
+		fmt.Fprintln(out, "No sessions found.")
+		return
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCREATED\tSTATUS\tMODEL\tTAGS")
+	for _, sess := range sessions {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", sess.ID, sess.CreatedAt.Format("2006-01-02 15:04"), sess.Status, sess.Model, formatTags(sess.Tags))
+	}
+	w.Flush()
+}
+
+func init() {
+	RootCmd.AddCommand(listCmd)
+	listCmd.Flags().StringArrayVar(&listTags, "tag", nil, "Only sessions with this key=value tag (repeatable)")
+	listCmd.Flags().IntVar(&listLimit, "limit", 20, "Maximum number of sessions to show (0 for all)")
+	listCmd.Flags().StringVar(&listSince, "since", "", "Only sessions newer than this window (e.g. 24h, 7d)")
+}
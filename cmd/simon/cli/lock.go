@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/felixgeelhaar/simon/internal/observe"
+	"github.com/felixgeelhaar/simon/internal/runtime"
+	"github.com/felixgeelhaar/simon/internal/setup"
+	"github.com/felixgeelhaar/simon/internal/workspace"
+)
+
+// workspaceLock is how a session takes the lock of the repository it runs
+// in, so that two sessions never edit it at once.
+type workspaceLock struct {
+	// Wait queues behind the holder instead of failing.
+	Wait bool
+	// Force steals the lock from its holder, which keeps running.
+	Force bool
+}
+
+// acquire locks the repository of the working directory for owner. Waits
+// and steals are logged as warnings and reported to notify.
+func (wl workspaceLock) acquire(ctx context.Context, obs *observe.Observer, notify func(string), owner string) (*workspace.Lock, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	dir := runtime.ProjectRoot(wd)
+	locker := workspace.Locker{Dir: setup.LocksDir()}
+
+	switch {
+	case wl.Force:
+		lock, previous, err := locker.Steal(dir, owner)
+		if err != nil {
+			return nil, err
+		}
+		if previous != nil {
+			obs.Log().Warn().Str("workspace", dir).Str("holder", previous.Owner).Int("pid", previous.PID).Msg("Stole the workspace lock")
+			notify(fmt.Sprintf("⚠️ Took the workspace lock from %s; both may now edit %s", previous, dir))
+		}
+		return lock, nil
+	case wl.Wait:
+		return locker.Wait(ctx, dir, owner, func(locked *workspace.LockedError) {
+			obs.Log().Warn().Str("workspace", dir).Msg("Waiting for the workspace lock")
+			notify(fmt.Sprintf("⏳ %v, waiting for it to finish", locked))
+		})
+	default:
+		lock, err := locker.TryLock(dir, owner)
+		if err != nil {
+			return nil, fmt.Errorf("%w (use --wait to queue behind it or --force to take the lock)", err)
+		}
+		return lock, nil
+	}
+}
@@ -0,0 +1,158 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var logsFollow bool
+
+// logsPollInterval controls how often --follow checks for new output.
+var logsPollInterval = 500 * time.Millisecond
+
+var logsCmd = &cobra.Command{
+	Use:   "logs <session-id>",
+	Short: "Show the log of a session",
+	Long: `Show observer output and runtime events recorded for a session.
+
+With --follow, new entries are streamed until the session finishes or the
+command is interrupted. Use --ci to print the raw JSON records.
+
+Examples:
+  simon logs session-1712345678
+  simon logs session-1712345678 --follow`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeSessionIDs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		s := getStore()
+		defer s.Close()
+
+		if _, err := s.GetSession(args[0]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		path := sessionLogPath(logDir(), args[0])
+		if err := streamLogs(ctx, s, path, args[0], os.Stdout, logsFollow, ciMode); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+// logDir is where session log files are kept for the active profile.
+func logDir() string {
+	return filepath.Join(simonDir(), "logs")
+}
+
+// sessionLogPath returns the log file of a session within dir.
+func sessionLogPath(dir, sessionID string) string {
+	return filepath.Join(dir, sessionID+".log")
+}
+
+// streamLogs copies a session's log to w. When follow is set it keeps polling
+// for new entries until the session reaches a final status or ctx is done.
+func streamLogs(ctx context.Context, s store.Storage, path, sessionID string, w io.Writer, follow, asJSON bool) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("no logs recorded for session %s", sessionID)
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	var partial string
+	for {
+		chunk, err := reader.ReadString('\n')
+		partial += chunk
+		if err == nil {
+			fmt.Fprintln(w, formatLogLine(strings.TrimRight(partial, "\n"), asJSON))
+			partial = ""
+			continue
+		}
+		if err != io.EOF {
+			return err
+		}
+		if !follow {
+			break
+		}
+
+		// Everything written so far has been read; stop once the session is done
+		if session, err := s.GetSession(sessionID); err == nil && store.SessionFinished(session.Status) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(logsPollInterval):
+		}
+	}
+
+	if strings.TrimSpace(partial) != "" {
+		fmt.Fprintln(w, formatLogLine(partial, asJSON))
+	}
+	return nil
+}
+
+// formatLogLine renders a JSON log record for humans, or passes it through
+// unchanged in JSON mode. Lines that are not JSON are printed as is.
+func formatLogLine(line string, asJSON bool) string {
+	if asJSON {
+		return line
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &record); err != nil {
+		return line
+	}
+
+	var b strings.Builder
+	if ts, ok := record["time"].(string); ok {
+		if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+			b.WriteString(t.Local().Format("15:04:05") + " ")
+		}
+	}
+	fmt.Fprintf(&b, "%-5s %v", strings.ToUpper(fmt.Sprint(record["level"])), record["message"])
+
+	keys := make([]string, 0, len(record))
+	for k := range record {
+		switch k {
+		case "time", "level", "message":
+		default:
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := record[k]
+		if _, ok := v.(string); !ok {
+			data, _ := json.Marshal(v)
+			v = string(data)
+		}
+		fmt.Fprintf(&b, " %s=%v", k, v)
+	}
+	return b.String()
+}
+
+func init() {
+	RootCmd.AddCommand(logsCmd)
+	logsCmd.Flags().BoolVarP(&logsFollow, "follow", "f", false, "Stream new entries until the session finishes")
+	logsCmd.Flags().BoolVar(&ciMode, "ci", false, "CI mode: print raw JSON records")
+}
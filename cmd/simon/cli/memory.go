@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/felixgeelhaar/simon/internal/provider"
+	"github.com/felixgeelhaar/simon/internal/runtime"
+	"github.com/felixgeelhaar/simon/internal/setup"
+	"github.com/felixgeelhaar/simon/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	reindexProvider string
+	reindexModel    string
+	reindexBatch    int
+	adoptFrom       string
+)
+
+var memoryCmd = &cobra.Command{
+	Use:   "memory",
+	Short: "Manage the long-term memory archive",
+}
+
+var memoryReindexCmd = &cobra.Command{
+	Use:   "reindex",
+	Short: "Re-embed all stored memories with the configured embedding model",
+	Long: `Re-embed every stored memory with the current provider's embedding model.
+Vectors from different models can't be compared, so run this after switching
+providers or embedding models. Memories are embedded in batches and all
+vectors are replaced in one transaction, so an interrupted run changes nothing.
+
+The provider and model default to the provider.default and provider.model
+config keys, like simon run.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		s, err := openStore()
+		if err != nil {
+			fmt.Printf("Failed to init store: %v\n", err)
+			os.Exit(1)
+		}
+		defer s.Close()
+
+		if !cmd.Flags().Changed("provider") {
+			if v, _ := s.GetConfig("provider.default"); v != "" {
+				reindexProvider = v
+			}
+		}
+		if !cmd.Flags().Changed("model") {
+			if v, _ := s.GetConfig("provider.model"); v != "" {
+				reindexModel = v
+			}
+		}
+		p, stop, err := setup.NewProvider(s, reindexProvider, reindexModel, setup.ProviderOptions{})
+		if err != nil {
+			fmt.Printf("Failed to initialize provider: %v\n", err)
+			os.Exit(1)
+		}
+		defer stop()
+
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer cancel()
+		if _, err := reindexMemories(ctx, os.Stdout, s, p, reindexBatch); err != nil {
+			fmt.Printf("Reindex failed: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var memoryAdoptCmd = &cobra.Command{
+	Use:   "adopt",
+	Short: "Move memories from before namespacing into this project's namespace",
+	Long: `Move memories into the namespace of the working directory's project (the
+git origin remote, or the repository root without one), which simon run
+retrieves from.
+
+Memories archived before namespacing have no namespace and are only found
+with --global-memory; earlier versions also keyed namespaces on the
+repository's absolute path. By default both are moved; --from moves the
+memories of another namespace instead.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		s, err := openStore()
+		if err != nil {
+			fmt.Printf("Failed to init store: %v\n", err)
+			os.Exit(1)
+		}
+		defer s.Close()
+		wd, err := os.Getwd()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		from := []string{"", runtime.ProjectRoot(wd)}
+		if cmd.Flags().Changed("from") {
+			from = []string{adoptFrom}
+		}
+		if err := adoptMemories(os.Stdout, s, from, runtime.ProjectNamespace(wd)); err != nil {
+			fmt.Printf("Adopt failed: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// adoptMemories moves the memories of the from namespaces to namespace.
+func adoptMemories(out io.Writer, s *store.SQLiteStore, from []string, namespace string) error {
+	total := 0
+	for _, ns := range from {
+		if ns == namespace {
+			continue
+		}
+		n, err := s.MoveMemories(ns, namespace)
+		if err != nil {
+			return err
+		}
+		total += n
+	}
+	fmt.Fprintf(out, "Moved %d memories to %s\n", total, namespace)
+	return nil
+}
+
+// reindexMemories re-embeds every memory with p, batchSize texts per
+// request, and returns how many were updated.
+func reindexMemories(ctx context.Context, out io.Writer, s *store.SQLiteStore, p provider.Provider, batchSize int) (int, error) {
+	if batchSize <= 0 {
+		return 0, fmt.Errorf("batch size must be positive")
+	}
+	records, err := s.ListMemories()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list memories: %w", err)
+	}
+	if len(records) == 0 {
+		fmt.Fprintln(out, "No memories to reindex")
+		return 0, nil
+	}
+
+	vectors := make(map[int64][]float32, len(records))
+	dims := 0
+	for start := 0; start < len(records); start += batchSize {
+		batch := records[start:min(start+batchSize, len(records))]
+		texts := make([]string, len(batch))
+		for i, r := range batch {
+			texts[i] = r.Content
+		}
+		embedded, err := p.EmbedBatch(ctx, texts)
+		if err != nil {
+			return 0, fmt.Errorf("failed to embed memories %d-%d: %w", start+1, start+len(batch), err)
+		}
+		if len(embedded) != len(batch) {
+			return 0, fmt.Errorf("provider returned %d embeddings for %d memories", len(embedded), len(batch))
+		}
+		for i, r := range batch {
+			vectors[r.ID] = embedded[i]
+			dims = len(embedded[i])
+		}
+		fmt.Fprintf(out, "Embedded %d/%d memories\n", start+len(batch), len(records))
+	}
+
+	if err := s.UpdateMemoryVectors(vectors); err != nil {
+		return 0, fmt.Errorf("failed to save embeddings: %w", err)
+	}
+	label := p.Name()
+	if m := p.Model(); m != "" {
+		label += "/" + m
+	}
+	fmt.Fprintf(out, "Reindexed %d memories with %s (%d dimensions)\n", len(records), label, dims)
+	return len(records), nil
+}
+
+func init() {
+	RootCmd.AddCommand(memoryCmd)
+	memoryCmd.AddCommand(memoryReindexCmd)
+	memoryCmd.AddCommand(memoryAdoptCmd)
+	memoryReindexCmd.Flags().StringVarP(&reindexProvider, "provider", "p", "ollama", "Embedding provider (ollama, openai, gemini, mistral, groq, huggingface)")
+	memoryReindexCmd.Flags().StringVarP(&reindexModel, "model", "m", "", "Model name (default depends on provider)")
+	memoryReindexCmd.Flags().IntVar(&reindexBatch, "batch", 32, "Memories embedded per request")
+	memoryAdoptCmd.Flags().StringVar(&adoptFrom, "from", "", "Namespace to move memories from (empty: memories without one)")
+}
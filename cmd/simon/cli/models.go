@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/provider"
+	"github.com/felixgeelhaar/simon/internal/setup"
+	"github.com/felixgeelhaar/simon/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var modelsProvider string
+
+var modelsCmd = &cobra.Command{
+	Use:   "models",
+	Short: "List the models of the configured providers",
+}
+
+var modelsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List available models and their capabilities",
+	Long: `List the models the configured providers serve, with their context window,
+tool and embedding support, and list price per million prompt/completion
+tokens. The configured providers are provider.default, those with an API key,
+and the local ones: Ollama, LM Studio (http://localhost:1234/v1 or
+lmstudio.base_url), and llama.cpp's llama-server (http://localhost:8080/v1 or
+llamacpp.base_url). Providers that can't be reached are reported and skipped.
+
+Context windows and pricing come from simon's tables and show "-" for models
+it doesn't know. Models known to lack tool calling can't run sessions. Run
+with --provider lmstudio or --provider llamacpp without --model to use the
+first model the server lists.
+
+Examples:
+  simon models list
+  simon models list --provider lmstudio`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		s := getStore()
+		defer s.Close()
+
+		names := setup.ConfiguredProviders(s)
+		if modelsProvider != "" {
+			if !slices.Contains(provider.Names, modelsProvider) {
+				fmt.Printf("Unknown provider %q\n", modelsProvider)
+				os.Exit(1)
+			}
+			names = []string{modelsProvider}
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), 30*time.Second)
+		defer cancel()
+		if err := listModels(ctx, os.Stdout, s, names); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+// listModels writes a table of the models each named provider serves and
+// their capabilities to out, followed by the providers that couldn't be
+// queried.
+func listModels(ctx context.Context, out io.Writer, s store.Storage, names []string) error {
+	var failed []string
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROVIDER\tMODEL\tCONTEXT\tTOOLS\tEMBEDDINGS\tPRICE (USD/M)")
+	for _, name := range names {
+		models, err := setup.ListModels(ctx, s, name)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		for _, m := range models {
+			caps := m.Capabilities
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", m.Provider, m.Model, modelContextWindow(caps.ContextWindow), yesNo(caps.Tools), yesNo(caps.Embeddings), modelPrice(caps.Pricing))
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		fmt.Fprintln(out, "\nUnavailable:")
+		for _, f := range failed {
+			fmt.Fprintf(out, "  %s\n", f)
+		}
+	}
+	return nil
+}
+
+func modelContextWindow(tokens int) string {
+	if tokens == 0 {
+		return "-"
+	}
+	return strconv.Itoa(tokens)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func modelPrice(p *provider.Pricing) string {
+	switch {
+	case p == nil:
+		return "-"
+	case *p == provider.Pricing{}:
+		return "free"
+	}
+	return fmt.Sprintf("$%.2f / $%.2f", p.PromptPerMillion, p.CompletionPerMillion)
+}
+
+func init() {
+	RootCmd.AddCommand(modelsCmd)
+	modelsCmd.AddCommand(modelsListCmd)
+	modelsListCmd.Flags().StringVarP(&modelsProvider, "provider", "p", "", "Only list the models of this provider")
+	modelsListCmd.RegisterFlagCompletionFunc("provider", cobra.FixedCompletions(provider.Names, cobra.ShellCompDirectiveNoFileComp))
+}
@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/credential"
+	"github.com/felixgeelhaar/simon/internal/notify"
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+// notifyTimeout bounds how long a finished run waits for pending notifications.
+const notifyTimeout = 15 * time.Second
+
+// loadNotifier builds the notifier from the notify.* config keys, decrypting
+// webhook URLs and SMTP passwords. It returns nil when nothing is configured.
+func loadNotifier(s store.Storage) (*notify.Notifier, error) {
+	var credMgr *credential.Manager
+	var decryptErr error
+	get := func(key string) string {
+		value, _ := s.GetConfig(key)
+		if !credential.IsEncrypted(value) || decryptErr != nil {
+			return value
+		}
+		if credMgr == nil {
+			if credMgr, decryptErr = credentialManager(s); decryptErr != nil {
+				return ""
+			}
+		}
+		decrypted, err := credMgr.Decrypt(value)
+		if err != nil {
+			decryptErr = fmt.Errorf("failed to decrypt %s: %w", key, err)
+			return ""
+		}
+		return decrypted
+	}
+	n, err := notify.FromConfig(get)
+	if decryptErr != nil {
+		return nil, decryptErr
+	}
+	return n, err
+}
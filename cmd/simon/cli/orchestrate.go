@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/felixgeelhaar/simon/internal/orchestrate"
+	"github.com/felixgeelhaar/simon/internal/provider"
+	"github.com/felixgeelhaar/simon/internal/setup"
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+// roleModel is the provider and model of an orchestrated role.
+type roleModel struct {
+	Provider string
+	Model    string
+}
+
+// resolveRole reads a role's orchestrate.<role>.provider and
+// orchestrate.<role>.model config, falling back to base. The base model
+// applies only while the role keeps the base provider; another provider
+// gets its own default model.
+func resolveRole(s store.Storage, role orchestrate.AgentType, base roleModel) roleModel {
+	m := base
+	if v, _ := s.GetConfig(fmt.Sprintf("orchestrate.%s.provider", role)); v != "" && v != base.Provider {
+		m = roleModel{Provider: v}
+	}
+	if v, _ := s.GetConfig(fmt.Sprintf("orchestrate.%s.model", role)); v != "" {
+		m.Model = v
+	}
+	return m
+}
+
+// newOrchestrator creates the planner and reviewer of an orchestrated run
+// from their orchestrate.* config, falling back to the executor's provider
+// and model. Roles sharing a provider and model share one provider. The
+// returned stop function is never nil.
+func newOrchestrator(s store.Storage, executor provider.Provider, executorModel roleModel, opts setup.ProviderOptions) (*orchestrate.MultiAgentOrchestrator, func(), error) {
+	providers := map[roleModel]provider.Provider{executorModel: executor}
+	var stops []func()
+	stop := func() {
+		for _, f := range stops {
+			f()
+		}
+	}
+
+	roles := make(map[orchestrate.AgentType]provider.Provider)
+	for _, role := range []orchestrate.AgentType{orchestrate.AgentTypePlanner, orchestrate.AgentTypeReviewer} {
+		m := resolveRole(s, role, executorModel)
+		p, ok := providers[m]
+		if !ok {
+			var roleStop func()
+			var err error
+			p, roleStop, err = setup.NewProvider(s, m.Provider, m.Model, opts)
+			if err != nil {
+				stop()
+				return nil, func() {}, fmt.Errorf("failed to initialize the %s provider: %w", role, err)
+			}
+			stops = append(stops, roleStop)
+			providers[m] = p
+		}
+		roles[role] = p
+	}
+	return orchestrate.New(roles[orchestrate.AgentTypePlanner], executor, roles[orchestrate.AgentTypeReviewer]), stop, nil
+}
@@ -0,0 +1,179 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/felixgeelhaar/simon/internal/coach"
+	"github.com/felixgeelhaar/simon/internal/guard"
+	"github.com/felixgeelhaar/simon/internal/mcp"
+	"github.com/felixgeelhaar/simon/internal/provider"
+	"github.com/spf13/cobra"
+)
+
+var (
+	policyTestFile string
+	policyTestTool string
+	policyTestPath string
+	policyTestSpec string
+)
+
+var policyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Validate guard policies and test what they allow",
+}
+
+var policyCheckCmd = &cobra.Command{
+	Use:   "check [policy.yaml]",
+	Short: "Check a policy file for mistakes",
+	Long: `Check a policy file for unknown fields, invalid severities and globs, and
+limits that would block every session. Problems are reported as file:line.
+Defaults to the active profile's policy.yaml.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := filepath.Join(simonDir(), "policy.yaml")
+		if len(args) == 1 {
+			path = args[0]
+		}
+		ok, err := checkPolicy(os.Stdout, path)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if !ok {
+			os.Exit(1)
+		}
+	},
+}
+
+var policyTestCmd = &cobra.Command{
+	Use:   `test ["<command>"]`,
+	Short: "Report whether a command, file write, or tool call would be allowed",
+	Long: `Run a tool call through the policy checks without executing it and report
+each check, the rule that decided, and why. Exits non-zero when denied.
+
+  simon policy test "go test ./..."
+  simon policy test --file internal/main.go
+  simon policy test --tool spawn_subtask '{"goal":"..."}'
+  simon policy test --spec task.yaml "cat config/.env"
+
+Uses the active profile's policy unless --policy is given, and applies the
+allowed_commands and denied_file_globs of the task spec given with --spec.
+The call is checked as the first of a new session, so the disk quota and
+the tool call rate are not yet used up. User approval is not considered.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		call, err := policyTestCall(args)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		p, err := loadPolicy()
+		if policyTestPath != "" {
+			p, err = guard.LoadPolicy(policyTestPath)
+		}
+		if err != nil {
+			fmt.Printf("Failed to load policy: %v\n", err)
+			os.Exit(1)
+		}
+		var scope mcp.Scope
+		if policyTestSpec != "" {
+			spec, err := coach.New().LoadSpec(policyTestSpec)
+			if err != nil {
+				fmt.Printf("Failed to load spec: %v\n", err)
+				os.Exit(1)
+			}
+			scope = mcp.Scope{AllowedCommands: spec.AllowedCommands, DeniedFileGlobs: spec.DeniedFileGlobs}
+		}
+		if !testPolicy(os.Stdout, p, scope, call) {
+			os.Exit(1)
+		}
+	},
+}
+
+// checkPolicy writes the problems LintPolicy finds in the file at path to
+// out and reports whether it has no errors.
+func checkPolicy(out io.Writer, path string) (bool, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path is supplied by the user
+	if err != nil {
+		return false, fmt.Errorf("failed to read policy file: %w", err)
+	}
+	issues, err := guard.LintPolicy(data)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", path, err)
+	}
+	ok := true
+	for _, issue := range issues {
+		sep := ":"
+		if issue.Line == 0 {
+			sep = ": "
+		}
+		fmt.Fprintf(out, "%s%s%s\n", path, sep, issue)
+		ok = ok && !issue.Error
+	}
+	if ok {
+		fmt.Fprintf(out, "%s is valid\n", path)
+	}
+	return ok, nil
+}
+
+// policyTestCall builds the tool call `simon policy test` explains.
+func policyTestCall(args []string) (provider.ToolCall, error) {
+	var arg string
+	if len(args) == 1 {
+		arg = args[0]
+	}
+	switch {
+	case policyTestTool != "":
+		if arg == "" {
+			arg = "{}"
+		}
+		if !json.Valid([]byte(arg)) {
+			return provider.ToolCall{}, fmt.Errorf("tool arguments must be a JSON object")
+		}
+		return provider.ToolCall{Name: policyTestTool, Args: arg}, nil
+	case policyTestFile != "":
+		data, _ := json.Marshal(map[string]string{"path": policyTestFile})
+		return provider.ToolCall{Name: "write_file", Args: string(data)}, nil
+	case arg != "":
+		data, _ := json.Marshal(map[string]string{"cmd": arg})
+		return provider.ToolCall{Name: "run_shell", Args: string(data)}, nil
+	}
+	return provider.ToolCall{}, fmt.Errorf("specify a command, --file, or --tool")
+}
+
+// testPolicy writes the explanation of call under policy p and a session's
+// scope to out and reports whether the call is allowed.
+func testPolicy(out io.Writer, p guard.Policy, scope mcp.Scope, call provider.ToolCall) bool {
+	e := mcp.NewProxy(nil, guard.New(p)).Explain(call, scope)
+	for _, c := range e.Checks {
+		mark := "✓"
+		if !c.Passed {
+			mark = "✗"
+		}
+		rule := c.Rule
+		if c.Severity != "" {
+			rule += " (" + string(c.Severity) + ")"
+		}
+		fmt.Fprintf(out, "%s %s: %s\n", mark, rule, c.Detail)
+	}
+	if e.Allowed {
+		fmt.Fprintf(out, "Allowed: %s %s\n", call.Name, call.Args)
+	} else {
+		fmt.Fprintf(out, "Denied: %s %s\n", call.Name, call.Args)
+	}
+	return e.Allowed
+}
+
+func init() {
+	RootCmd.AddCommand(policyCmd)
+	policyCmd.AddCommand(policyCheckCmd)
+	policyCmd.AddCommand(policyTestCmd)
+	policyTestCmd.Flags().StringVar(&policyTestFile, "file", "", "Test a write_file call for this path")
+	policyTestCmd.Flags().StringVar(&policyTestTool, "tool", "", "Test a call to this tool; the argument is its JSON args")
+	policyTestCmd.Flags().StringVar(&policyTestPath, "policy", "", "Policy file to test against (default: the active profile's)")
+	policyTestCmd.Flags().StringVar(&policyTestSpec, "spec", "", "Task spec whose allowed_commands and denied_file_globs apply")
+}
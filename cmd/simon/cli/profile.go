@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/felixgeelhaar/simon/internal/guard"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage configuration profiles",
+	Long: `Profiles isolate provider selection, API keys, policies, and the session store.
+Each profile lives under ~/.simon/profiles/<name> and is selected with --profile
+or the SIMON_PROFILE environment variable.
+
+Profile-scoped settings:
+  provider.default   Provider used when --provider is not given
+  provider.model     Model used when --model is not given
+  policy.yaml        Guard policy file in the profile directory`,
+}
+
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List available profiles",
+	Run: func(cmd *cobra.Command, args []string) {
+		home, _ := os.UserHomeDir()
+		entries, err := os.ReadDir(filepath.Join(home, ".simon", "profiles"))
+		if err != nil && !os.IsNotExist(err) {
+			fmt.Printf("Failed to read profiles: %v\n", err)
+			os.Exit(1)
+		}
+
+		printProfile := func(name, label string) {
+			marker := " "
+			if name == profileName {
+				marker = "*"
+			}
+			fmt.Printf("%s %s\n", marker, label)
+		}
+
+		printProfile("", "(default)")
+		for _, e := range entries {
+			if e.IsDir() {
+				printProfile(e.Name(), e.Name())
+			}
+		}
+	},
+}
+
+var profileCreateCmd = &cobra.Command{
+	Use:   "create [name]",
+	Short: "Create a profile with a default policy file",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		if name == "" {
+			fmt.Println("Profile name is required")
+			os.Exit(1)
+		}
+		if err := validateProfileName(name); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		profileName = name
+		dir := simonDir()
+		if err := os.MkdirAll(dir, 0750); err != nil {
+			fmt.Printf("Failed to create profile: %v\n", err)
+			os.Exit(1)
+		}
+
+		policyPath := filepath.Join(dir, "policy.yaml")
+		if _, err := os.Stat(policyPath); os.IsNotExist(err) {
+			data, err := yaml.Marshal(guard.DefaultPolicy)
+			if err != nil {
+				fmt.Printf("Failed to encode policy: %v\n", err)
+				os.Exit(1)
+			}
+			if err := os.WriteFile(policyPath, data, 0600); err != nil {
+				fmt.Printf("Failed to write policy: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		// Initialize the profile's store so config can be set immediately
+		s := getStore()
+		s.Close()
+
+		fmt.Printf("Profile created: %s (%s)\n", name, dir)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(profileCmd)
+	profileCmd.AddCommand(profileListCmd)
+	profileCmd.AddCommand(profileCreateCmd)
+}
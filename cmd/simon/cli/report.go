@@ -0,0 +1,329 @@
+package cli
+
+import (
+	"bufio"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/store"
+	"github.com/spf13/cobra"
+)
+
+//go:embed report.html
+var reportTemplate string
+
+var (
+	reportSince string
+	reportOut   string
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Render a static HTML dashboard of past sessions",
+	Long: `Render a self-contained HTML dashboard from the store: sessions over time,
+success rate, token and cost trends, top guard violations, and the slowest
+tools. Violations and tool timings are read from the per-session logs.
+
+Examples:
+  simon report
+  simon report --since 7d --out weekly.html`,
+	Run: func(cmd *cobra.Command, args []string) {
+		window, err := parseSince(reportSince)
+		if err != nil {
+			fmt.Printf("Invalid --since value: %v\n", err)
+			os.Exit(1)
+		}
+
+		s := getStore()
+		defer s.Close()
+
+		now := time.Now()
+		filter := store.SessionFilter{}
+		if window > 0 {
+			filter.Since = now.Add(-window)
+		}
+		sessions, err := s.ListSessions(filter)
+		if err != nil {
+			fmt.Printf("Failed to list sessions: %v\n", err)
+			os.Exit(1)
+		}
+
+		data := buildReport(sessions, logDir(), filter.Since, now)
+		f, err := os.Create(reportOut)
+		if err != nil {
+			fmt.Printf("Failed to create report: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		if err := renderReport(f, data); err != nil {
+			fmt.Printf("Failed to render report: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Report for %d sessions written to %s\n", data.Sessions, reportOut)
+	},
+}
+
+// reportData is everything the dashboard template renders.
+type reportData struct {
+	GeneratedAt time.Time
+	Since       time.Time
+	Sessions    int
+	Completed   int
+	Finished    int
+	SuccessRate float64 // Completed share of finished sessions, in percent
+	Tokens      int
+	Cost        float64
+
+	Days       []reportDay
+	Violations []reportCount
+	Tools      []reportTool
+	Recent     []*store.Session
+
+	SessionChart reportChart
+	TokenChart   reportChart
+	CostChart    reportChart
+}
+
+// reportDay aggregates the sessions created on one calendar day.
+type reportDay struct {
+	Date      time.Time
+	Sessions  int
+	Completed int
+	Tokens    int
+	Cost      float64
+}
+
+type reportCount struct {
+	Name  string
+	Count int
+}
+
+// reportTool aggregates the calls of one tool label.
+type reportTool struct {
+	Label  string
+	Calls  int
+	Errors int
+	Total  time.Duration
+	Max    time.Duration
+}
+
+// Avg returns the mean call duration.
+func (t reportTool) Avg() time.Duration {
+	if t.Calls == 0 {
+		return 0
+	}
+	return t.Total / time.Duration(t.Calls)
+}
+
+// reportChart is a precomputed SVG bar chart.
+type reportChart struct {
+	Width, Height int
+	Max           string
+	Bars          []reportBar
+}
+
+type reportBar struct {
+	X, Y, W, H float64
+	Title      string
+}
+
+const (
+	reportChartWidth  = 720
+	reportChartHeight = 160
+	reportMaxDays     = 90
+	reportTopN        = 10
+)
+
+// buildReport aggregates sessions and their logs into dashboard data.
+func buildReport(sessions []*store.Session, logDir string, since, now time.Time) reportData {
+	sessions = topLevelSessions(sessions)
+	data := reportData{GeneratedAt: now, Since: since, Sessions: len(sessions)}
+
+	byDay := make(map[string]*reportDay)
+	violations := make(map[string]int)
+	tools := make(map[string]*reportTool)
+	first := now
+	for _, sess := range sessions {
+		switch {
+		case sess.Status == "completed":
+			data.Completed++
+			data.Finished++
+		case store.SessionFinished(sess.Status):
+			data.Finished++
+		}
+		tokens := sess.PromptTokens + sess.CompletionTokens
+		data.Tokens += tokens
+		data.Cost += sess.Cost
+
+		key := sess.CreatedAt.Local().Format("2006-01-02")
+		day, ok := byDay[key]
+		if !ok {
+			day = &reportDay{}
+			byDay[key] = day
+		}
+		day.Sessions++
+		if sess.Status == "completed" {
+			day.Completed++
+		}
+		day.Tokens += tokens
+		day.Cost += sess.Cost
+		if sess.CreatedAt.Before(first) {
+			first = sess.CreatedAt
+		}
+
+		scanSessionLog(sessionLogPath(logDir, sess.ID), violations, tools)
+	}
+	if data.Finished > 0 {
+		data.SuccessRate = float64(data.Completed) / float64(data.Finished) * 100
+	}
+
+	// One entry per day so gaps show up in the charts
+	start := since
+	if start.IsZero() {
+		start = first
+	}
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.Local)
+	if earliest := now.AddDate(0, 0, -reportMaxDays+1); start.Before(earliest) {
+		start = time.Date(earliest.Year(), earliest.Month(), earliest.Day(), 0, 0, 0, 0, time.Local)
+	}
+	for d := start; !d.After(now); d = d.AddDate(0, 0, 1) {
+		day := reportDay{Date: d}
+		if agg, ok := byDay[d.Format("2006-01-02")]; ok {
+			day.Sessions, day.Completed, day.Tokens, day.Cost = agg.Sessions, agg.Completed, agg.Tokens, agg.Cost
+		}
+		data.Days = append(data.Days, day)
+	}
+
+	for rule, n := range violations {
+		data.Violations = append(data.Violations, reportCount{Name: rule, Count: n})
+	}
+	sort.Slice(data.Violations, func(i, j int) bool {
+		if data.Violations[i].Count != data.Violations[j].Count {
+			return data.Violations[i].Count > data.Violations[j].Count
+		}
+		return data.Violations[i].Name < data.Violations[j].Name
+	})
+	if len(data.Violations) > reportTopN {
+		data.Violations = data.Violations[:reportTopN]
+	}
+
+	for _, t := range tools {
+		data.Tools = append(data.Tools, *t)
+	}
+	sort.Slice(data.Tools, func(i, j int) bool {
+		if data.Tools[i].Avg() != data.Tools[j].Avg() {
+			return data.Tools[i].Avg() > data.Tools[j].Avg()
+		}
+		return data.Tools[i].Label < data.Tools[j].Label
+	})
+	if len(data.Tools) > reportTopN {
+		data.Tools = data.Tools[:reportTopN]
+	}
+
+	data.Recent = sessions
+	if len(data.Recent) > 20 {
+		data.Recent = data.Recent[:20]
+	}
+
+	data.SessionChart = barChart(data.Days, func(d reportDay) float64 { return float64(d.Sessions) }, "%.0f sessions")
+	data.TokenChart = barChart(data.Days, func(d reportDay) float64 { return float64(d.Tokens) }, "%.0f tokens")
+	data.CostChart = barChart(data.Days, func(d reportDay) float64 { return d.Cost }, "$%.4f")
+	return data
+}
+
+// scanSessionLog counts guard violations and tool timings recorded as
+// runtime events in a session log. Missing logs are skipped.
+func scanSessionLog(path string, violations map[string]int, tools map[string]*reportTool) {
+	f, err := os.Open(path) // #nosec G304 -- path is built from the log directory and a session ID
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record struct {
+			Event string `json:"event"`
+			Data  struct {
+				Rule       string `json:"rule"`
+				Label      string `json:"label"`
+				DurationMS int64  `json:"duration_ms"`
+				Error      bool   `json:"error"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		switch record.Event {
+		case "guard_violation":
+			violations[record.Data.Rule]++
+		case "tool_call_end":
+			t, ok := tools[record.Data.Label]
+			if !ok {
+				t = &reportTool{Label: record.Data.Label}
+				tools[record.Data.Label] = t
+			}
+			d := time.Duration(record.Data.DurationMS) * time.Millisecond
+			t.Calls++
+			t.Total += d
+			if d > t.Max {
+				t.Max = d
+			}
+			if record.Data.Error {
+				t.Errors++
+			}
+		}
+	}
+}
+
+// barChart lays out one bar per day, scaled to the largest value.
+func barChart(days []reportDay, value func(reportDay) float64, format string) reportChart {
+	chart := reportChart{Width: reportChartWidth, Height: reportChartHeight}
+	var peak float64
+	for _, d := range days {
+		if v := value(d); v > peak {
+			peak = v
+		}
+	}
+	chart.Max = fmt.Sprintf(format, peak)
+	if len(days) == 0 || peak == 0 {
+		return chart
+	}
+
+	slot := float64(reportChartWidth) / float64(len(days))
+	for i, d := range days {
+		v := value(d)
+		h := v / peak * float64(reportChartHeight)
+		chart.Bars = append(chart.Bars, reportBar{
+			X:     float64(i)*slot + slot*0.1,
+			Y:     float64(reportChartHeight) - h,
+			W:     slot * 0.8,
+			H:     h,
+			Title: d.Date.Format("Jan 2") + ": " + fmt.Sprintf(format, v),
+		})
+	}
+	return chart
+}
+
+func renderReport(w io.Writer, data reportData) error {
+	tmpl, err := template.New("report").Funcs(template.FuncMap{
+		"tags": formatTags,
+	}).Parse(reportTemplate)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, data)
+}
+
+func init() {
+	RootCmd.AddCommand(reportCmd)
+	reportCmd.Flags().StringVar(&reportSince, "since", "30d", "Only sessions newer than this window (e.g. 7d, 2w; empty for all)")
+	reportCmd.Flags().StringVarP(&reportOut, "out", "o", "simon-report.html", "Output file")
+}
@@ -2,18 +2,23 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
-	"path/filepath"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/felixgeelhaar/simon/internal/coach"
 	"github.com/felixgeelhaar/simon/internal/guard"
 	"github.com/felixgeelhaar/simon/internal/observe"
+	"github.com/felixgeelhaar/simon/internal/orchestrate"
 	"github.com/felixgeelhaar/simon/internal/provider"
+	"github.com/felixgeelhaar/simon/internal/runtime"
+	"github.com/felixgeelhaar/simon/internal/setup"
 	"github.com/felixgeelhaar/simon/internal/store"
 	"github.com/felixgeelhaar/simon/internal/ui"
 	"github.com/felixgeelhaar/simon/internal/ui/tui"
+	"github.com/felixgeelhaar/simon/internal/verifyreport"
 	"github.com/spf13/cobra"
 )
 
@@ -26,6 +31,23 @@ var (
 	useAPI       bool
 	ciMode       bool
 	interactive  bool
+	approveMode  bool
+	runTags      []string
+	runVars      []string
+	runReports   []string
+	recordDir    string
+	fixturePath  string
+	cacheMode    bool
+	clarifyMode  bool
+	watchMode    bool
+	sandboxMode  string
+	budgetName   string
+	resumeID     string
+	freshMode    bool
+	globalMemory bool
+	orchestrated bool
+	lockWait     bool
+	lockForce    bool
 )
 
 // RootCmd represents the base command when called without any subcommands
@@ -34,23 +56,57 @@ var RootCmd = &cobra.Command{
 	Short: "AI Agent Governance Runtime",
 	Long: `Simon enforces clarity, discipline, and resource limits on AI agent execution.
 It acts as a runtime layer between your intent and the AI provider.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return validateProfileName(profileName)
+	},
 }
 
 var runCmd = &cobra.Command{
-	Use:   "run [spec-file]",
+	Use:   "run [spec-file | -]",
 	Short: "Execute a task defined in a spec file",
-	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		specPath = args[0]
-		runSession()
-	},
-}
+	Long: `Execute a task defined in a spec file.
+
+Use - to read the spec from stdin, or build an ad-hoc spec with --goal,
+--dod, --evidence, and --verify instead of a file. Either way the spec is
+saved under the profile's specs directory, named by its content.
 
-var listCmd = &cobra.Command{
-	Use:   "list",
-	Short: "List past sessions",
+When the last run of the same spec failed, halted, ran out of verification
+retries, or was cancelled, the new session continues from it: that session's
+final summary, archived memory, plan, and changed files are added to the
+initial prompt. Use --fresh to start over, or --resume <session-id> to
+continue from a specific session (its spec is used when no spec file is
+given).
+
+A session holds a lock on its repository (the git root of the working
+directory) while it runs, so another session can't edit the same files at
+the same time. When the lock is held, run fails naming its holder; --wait
+queues behind the holder and --force takes the lock with a warning.
+
+Examples:
+  simon run task.yaml
+  simon run task.yaml --provider openai --model gpt-4o --interactive
+  simon run --goal "Add a /healthz endpoint" --evidence internal/api/health.go --verify "go test ./..."
+  simon run task.yaml --var TICKET=OPS-42 --tag ticket=OPS-42 --report junit=verify.xml
+  simon run --resume session-1712345678
+  simon run task.yaml --wait`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeSpecFile,
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("Listing sessions feature pending...")
+		path, err := resolveSpecArg(args, os.Stdin)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if path == "" && resumeID == "" {
+			fmt.Println("Specify a spec file, - for stdin, --goal, or --resume <session-id>")
+			os.Exit(1)
+		}
+		if len(args) == 1 && args[0] == stdinSpec && (approveMode || clarifyMode || interactive) {
+			fmt.Println("--approve, --clarify, and -i read the terminal and cannot be used with a spec from stdin")
+			os.Exit(1)
+		}
+		specPath = path
+		runSession(cmd)
 	},
 }
 
@@ -62,18 +118,62 @@ func Execute() {
 }
 
 func init() {
+	RootCmd.PersistentFlags().StringVar(&profileName, "profile", os.Getenv("SIMON_PROFILE"), "Configuration profile to use (default: SIMON_PROFILE or the base profile)")
 	RootCmd.AddCommand(runCmd)
-	RootCmd.AddCommand(listCmd)
 	runCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose logging")
-	runCmd.Flags().StringVarP(&providerType, "provider", "p", "ollama", "AI Provider (ollama, openai, gemini, anthropic)")
-	runCmd.Flags().StringVarP(&modelName, "model", "m", "", "Model name (default depends on provider)")
+	runCmd.Flags().StringVarP(&providerType, "provider", "p", "", "AI Provider: ollama, openai, gemini, anthropic, mistral, groq, huggingface, lmstudio, llamacpp, plugin, or fixture (default: the spec's provider, provider.default, or ollama)")
+	runCmd.Flags().StringVarP(&modelName, "model", "m", "", "Model name (default: the spec's model, provider.model, or the provider's default)")
 	runCmd.Flags().BoolVar(&useCLI, "cli", false, "Use local CLI tool as provider if available")
 	runCmd.Flags().BoolVar(&useAPI, "api", false, "Use direct API integration (default)")
 	runCmd.Flags().BoolVar(&ciMode, "ci", false, "CI mode: JSON output, non-interactive")
 	runCmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "Start interactive TUI")
+	runCmd.Flags().BoolVar(&approveMode, "approve", false, "Review a diff of every file change and confirm every shell command before it runs")
+	runCmd.Flags().StringArrayVar(&runTags, "tag", nil, "Tag the session as key=value (repeatable)")
+	runCmd.Flags().StringArrayVar(&runVars, "var", nil, "Set a ${NAME} spec variable as NAME=value (repeatable; overrides the environment and the spec's vars block)")
+	runCmd.Flags().StringVar(&inlineGoal, "goal", "", "Run an ad-hoc spec with this goal instead of a spec file")
+	runCmd.Flags().StringVar(&inlineDoD, "dod", "", "Definition of done of the --goal spec (default: the goal)")
+	runCmd.Flags().StringArrayVar(&inlineEvidence, "evidence", nil, "Evidence file of the --goal spec (repeatable)")
+	runCmd.Flags().StringArrayVar(&inlineVerify, "verify", nil, "Verify command of the --goal spec (repeatable)")
+	runCmd.Flags().StringArrayVar(&runReports, "report", nil, "Write the verification outcome as format=path, format being sarif or junit (repeatable)")
+	runCmd.Flags().StringVar(&sandboxMode, "sandbox", "", "Confine shell tools: none, auto, firejail, or sandbox-exec (default: policy sandbox)")
+	runCmd.Flags().StringVar(&budgetName, "budget", "", "Budget preset scaling iterations, tokens, cost, and time: small, medium, large, or one defined with budget.<name>.* config")
+	runCmd.Flags().BoolVar(&clarifyMode, "clarify", false, "If the spec has warnings, answer the coach's questions and run a refined copy (<spec>.refined.yaml)")
+	runCmd.Flags().BoolVar(&watchMode, "watch-evidence", false, "Verify as soon as all evidence files exist instead of waiting for the agent to claim completion")
+	runCmd.Flags().StringVar(&recordDir, "record", "", "Record every provider call, sanitized, as a JSON fixture in this directory")
+	runCmd.Flags().StringVar(&fixturePath, "fixture", "", "Play back a fixture recorded with --record instead of calling a provider")
+	runCmd.Flags().BoolVar(&cacheMode, "cache", false, "Answer identical prompts from the response cache (default: cache.enabled)")
+	runCmd.Flags().StringVar(&resumeID, "resume", "", "Continue from this session, adding its summary, plan, and changed files to the prompt")
+	runCmd.Flags().BoolVar(&freshMode, "fresh", false, "Don't continue from the last failed run of the same spec")
+	runCmd.Flags().BoolVar(&globalMemory, "global-memory", false, "Retrieve memories from every project, not just this one")
+	runCmd.Flags().BoolVar(&orchestrated, "orchestrated", false, "Split the session between a planner, an executor, and a reviewer model (orchestrate.<role>.provider and .model config)")
+	runCmd.Flags().BoolVar(&lockWait, "wait", false, "If another session holds this repository's lock, wait for it to finish instead of failing")
+	runCmd.Flags().BoolVar(&lockForce, "force", false, "Take this repository's lock even if another session holds it")
+	runCmd.MarkFlagsMutuallyExclusive("wait", "force")
+
+	RootCmd.RegisterFlagCompletionFunc("profile", completeProfile)
+	runCmd.RegisterFlagCompletionFunc("resume", completeSessionFlag)
+	runCmd.RegisterFlagCompletionFunc("provider", cobra.FixedCompletions(provider.Names, cobra.ShellCompDirectiveNoFileComp))
+	runCmd.RegisterFlagCompletionFunc("sandbox", cobra.FixedCompletions([]string{"none", "auto", "firejail", "sandbox-exec"}, cobra.ShellCompDirectiveNoFileComp))
+	runCmd.RegisterFlagCompletionFunc("budget", cobra.FixedCompletions([]string{"small", "medium", "large"}, cobra.ShellCompDirectiveNoFileComp))
 }
 
-func runSession() {
+func runSession(cmd *cobra.Command) {
+	tags, err := parseTags(runTags)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	vars, err := coach.ParseVars(runVars)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	reports, err := verifyreport.ParseTargets(runReports)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
 	// Initialize Observer
 	var obs *observe.Observer
 	if ciMode {
@@ -84,42 +184,115 @@ func runSession() {
 	defer obs.Close()
 
 	// Initialize Store
-	home, _ := os.UserHomeDir()
-	simonDir := filepath.Join(home, ".simon")
-	storeLayer, err := store.NewSQLiteStore(
-		filepath.Join(simonDir, "metadata.db"),
-		filepath.Join(simonDir, "artifacts"),
-	)
+	storeLayer, err := openStore()
 	if err != nil {
 		obs.Log().Fatal().Err(err).Msg("Failed to init store")
 	}
 	defer storeLayer.Close()
 
+	policy, err := loadPolicy()
+	if err != nil {
+		obs.Log().Fatal().Err(err).Msg("Failed to load policy")
+	}
+
+	if cmd.Flags().Changed("sandbox") {
+		policy.Sandbox = sandboxMode
+	}
+	if budgetName != "" {
+		budget, err := guard.ResolveBudget(budgetName, func(key string) string {
+			v, _ := storeLayer.GetConfig(key)
+			return v
+		})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		policy = policy.WithBudget(budget)
+	}
+
+	previous, err := previousSession(storeLayer, resumeID, specPath, freshMode)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if previous != nil {
+		if specPath == "" {
+			specPath = previous.Metadata["spec"]
+		}
+		// A continued session keeps the previous one's variables unless overridden
+		for name, value := range runtime.SessionVars(previous) {
+			if _, ok := vars[name]; !ok {
+				vars[name] = value
+			}
+		}
+		obs.Log().Info().Str("previous", previous.ID).Str("status", previous.Status).Msg("Continuing from previous session")
+	}
+
+	// The flags choose the provider and model, then the spec, then the
+	// profile defaults. A spec that fails to load is reported by the runner.
+	var flagProvider, flagModel string
+	if cmd.Flags().Changed("provider") {
+		flagProvider = providerType
+	}
+	if cmd.Flags().Changed("model") {
+		flagModel = modelName
+	}
+	spec, err := coach.New().LoadSpecWithVars(specPath, vars)
+	if err != nil {
+		spec = nil
+	}
+	providerType, modelName = setup.ResolveProvider(storeLayer, spec, flagProvider, flagModel)
+
+	if !cmd.Flags().Changed("cache") {
+		if v, _ := storeLayer.GetConfig("cache.enabled"); v == "true" {
+			cacheMode = true
+		}
+	}
+
+	// An orchestrated run's provider is its executor
+	if orchestrated {
+		if useCLI || fixturePath != "" {
+			fmt.Println("--orchestrated creates its providers from config and cannot be used with --cli or --fixture")
+			os.Exit(1)
+		}
+		executor := resolveRole(storeLayer, orchestrate.AgentTypeExecutor, roleModel{Provider: providerType, Model: modelName})
+		providerType, modelName = executor.Provider, executor.Model
+	}
+
 	// Initialize Provider
 	var p provider.Provider
 	var pErr error
+	opts := setup.ProviderOptions{Cache: cacheMode, Log: obs.Log(), Record: recordDir}
+	if spec != nil {
+		opts.Secrets = spec.Secrets()
+	}
+	if fixturePath != "" || providerType == "fixture" {
+		// Answering from the cache would skip recorded responses
+		opts.Cache = false
+	}
 
-	if useCLI {
+	if fixturePath != "" {
+		p, pErr = setup.NewFixtureProvider(fixturePath)
+		var stop func()
+		if pErr == nil {
+			if p, stop, pErr = setup.WrapProvider(storeLayer, p, func() {}, opts); pErr == nil {
+				defer stop()
+			}
+		}
+	} else if useCLI {
 		p, pErr = detectCLIProvider(storeLayer)
 		if pErr != nil {
 			obs.Log().Fatal().Err(pErr).Msg("Failed to initialize CLI provider")
 		}
+		var stop func()
+		if p, stop, pErr = setup.WrapProvider(storeLayer, p, func() {}, opts); pErr == nil {
+			defer stop()
+		}
 	} else {
-		switch providerType {
-		case "openai":
-			apiKey, _ := storeLayer.GetConfig("openai.api_key")
-			baseURL, _ := storeLayer.GetConfig("openai.base_url")
-			p, pErr = provider.NewOpenAIProvider(apiKey, baseURL, modelName)
-		case "ollama":
-			p, pErr = provider.NewOllamaProvider(modelName)
-		case "gemini":
-			apiKey, _ := storeLayer.GetConfig("gemini.api_key")
-			p, pErr = provider.NewGeminiProvider(apiKey, modelName)
-		case "anthropic":
-			apiKey, _ := storeLayer.GetConfig("anthropic.api_key")
-			p, pErr = provider.NewAnthropicProvider(apiKey, modelName)
-		default:
-			obs.Log().Fatal().Str("provider", providerType).Msg("Unknown provider")
+		var stop func()
+		p, stop, pErr = setup.NewProvider(storeLayer, providerType, modelName, opts)
+		if pErr == nil {
+			defer stop()
 		}
 	}
 
@@ -127,25 +300,111 @@ func runSession() {
 		obs.Log().Fatal().Err(pErr).Msg("Failed to initialize provider")
 	}
 
+	var orch *orchestrate.MultiAgentOrchestrator
+	if orchestrated {
+		var stop func()
+		orch, stop, err = newOrchestrator(storeLayer, p, roleModel{Provider: providerType, Model: modelName}, opts)
+		if err != nil {
+			obs.Log().Fatal().Err(err).Msg("Failed to initialize orchestrator")
+		}
+		defer stop()
+	}
+
+	notifier, err := loadNotifier(storeLayer)
+	if err != nil {
+		fmt.Printf("Invalid notification config: %v\n", err)
+		os.Exit(1)
+	}
+	if notifier != nil {
+		notifier.OnError(func(channel string, err error) {
+			obs.Log().Warn().Str("channel", channel).Err(err).Msg("notification failed")
+		})
+	}
+
+	if approveMode && ciMode {
+		fmt.Println("--approve needs an interactive terminal and cannot be used with --ci")
+		os.Exit(1)
+	}
+	if clarifyMode {
+		if ciMode {
+			fmt.Println("--clarify needs an interactive terminal and cannot be used with --ci")
+			os.Exit(1)
+		}
+		refined, err := clarifySpec(context.Background(), os.Stdin, os.Stdout, p, specPath)
+		if err != nil {
+			fmt.Printf("Clarification failed: %v\n", err)
+			os.Exit(1)
+		}
+		specPath = refined
+	}
+
 	var u ui.UI
 	if interactive {
-		model := tui.NewModel("Simon execution", guard.DefaultPolicy.MaxIterations)
+		model := tui.NewModel("Simon execution", policy.MaxIterations)
+		// Agent output shown in the log may echo credentials
+		model.Redactor = setup.Redactor(storeLayer, opts.Secrets...)
 		program := tea.NewProgram(model)
-		u = tui.NewTUI(program)
-		
+		t := tui.NewTUI(program)
+		u = t
+
+		// Quitting the TUI aborts the session; waiting for the runner to
+		// return releases the workspace lock before the process exits
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
 		go func() {
+			defer close(done)
 			runner := NewRunner(obs, storeLayer, p, specPath, u)
-			_ = runner.Run(context.Background())
+			runner.Policy = policy
+			runner.LogDir = logDir()
+			runner.Tags = tags
+			runner.Vars = vars
+			runner.Reports = reports
+			runner.Notifier = notifier
+			runner.WatchEvidence = watchMode
+			runner.GlobalMemory = globalMemory
+			runner.Orchestrator = orch
+			runner.ProviderFactory = providerFactory(storeLayer, opts)
+			runner.Workspace = &workspaceLock{Wait: lockWait, Force: lockForce}
+			if previous != nil {
+				runner.Previous = previous.ID
+			}
+			if approveMode {
+				runner.Approver = uiApprover(t)
+			}
+			_ = runner.Run(ctx)
 			program.Quit()
 		}()
 
-		if _, err := program.Run(); err != nil {
+		_, err := program.Run()
+		cancel()
+		<-done
+		if err != nil {
 			fmt.Printf("Alas, there's been an error: %v", err)
 			os.Exit(1)
 		}
 	} else {
 		runner := NewRunner(obs, storeLayer, p, specPath, nil)
+		runner.Policy = policy
+		runner.LogDir = logDir()
+		runner.Tags = tags
+		runner.Vars = vars
+		runner.Reports = reports
+		runner.Notifier = notifier
+		runner.WatchEvidence = watchMode
+		runner.GlobalMemory = globalMemory
+		runner.Orchestrator = orch
+		runner.ProviderFactory = providerFactory(storeLayer, opts)
+		runner.Workspace = &workspaceLock{Wait: lockWait, Force: lockForce}
+		if previous != nil {
+			runner.Previous = previous.ID
+		}
+		if approveMode {
+			runner.Approver = newPromptApprover(os.Stdin, os.Stdout)
+		}
 		if err := runner.Run(context.Background()); err != nil {
+			if errors.Is(err, errInterrupted) {
+				os.Exit(130)
+			}
 			os.Exit(1)
 		}
 	}
@@ -2,72 +2,196 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/felixgeelhaar/simon/internal/coach"
 	"github.com/felixgeelhaar/simon/internal/guard"
 	"github.com/felixgeelhaar/simon/internal/mcp"
+	"github.com/felixgeelhaar/simon/internal/notify"
 	"github.com/felixgeelhaar/simon/internal/observe"
+	"github.com/felixgeelhaar/simon/internal/orchestrate"
 	"github.com/felixgeelhaar/simon/internal/provider"
 	"github.com/felixgeelhaar/simon/internal/runtime"
+	"github.com/felixgeelhaar/simon/internal/setup"
 	"github.com/felixgeelhaar/simon/internal/store"
 	"github.com/felixgeelhaar/simon/internal/ui"
+	"github.com/felixgeelhaar/simon/internal/verifyreport"
 )
 
+// errInterrupted is returned by Run when the session was stopped by a signal.
+var errInterrupted = errors.New("session interrupted")
+
 type Runner struct {
 	Observer *observe.Observer
 	Store    store.Storage
 	Provider provider.Provider
 	SpecPath string
 	UI       ui.UI
+	Policy   guard.Policy
+	// LogDir, when set, receives a JSON log file per session for `simon logs`.
+	LogDir string
+	// Approver, when set, must approve file changes before they are applied.
+	Approver mcp.Approver
+	// Tags are key=value labels recorded with the session for `simon list --tag`.
+	Tags map[string]string
+	// Vars resolve ${NAME} placeholders in the spec and are recorded with
+	// the session, so the runtime and later resumes see the same values.
+	Vars map[string]string
+	// Notifier, when set, forwards runtime events to the configured channels.
+	Notifier *notify.Notifier
+	// SessionID, when set, is used instead of a timestamp-based ID.
+	SessionID string
+	// WatchEvidence verifies the session as soon as all evidence files exist.
+	WatchEvidence bool
+	// Previous, when set, is an earlier session of the same task whose
+	// outcome is added to the initial prompt.
+	Previous string
+	// GlobalMemory retrieves memories from every project instead of the
+	// session's memory namespace.
+	GlobalMemory bool
+	// Reports are written with the session's verification outcome once the
+	// run ends, whether or not it succeeded.
+	Reports []verifyreport.Target
+	// Orchestrator, when set, plans the session with its planner and has
+	// its reviewer approve the verified work; Provider is the executor.
+	Orchestrator *orchestrate.MultiAgentOrchestrator
+	// ProviderFactory creates the providers the session switches to, on
+	// `simon steer --provider/--model` or a spec's escalate.
+	ProviderFactory runtime.ProviderFactory
+	// SpecDefaults are the project's conventions added to the spec.
+	SpecDefaults coach.SpecDefaults
+	// Workspace, when set, locks the repository for the session so that
+	// no other session edits it at the same time.
+	Workspace *workspaceLock
 }
 
 func (r *Runner) Run(ctx context.Context) error {
 	r.UI.UpdateStatus("Starting Simon...")
 	r.Observer.Log().Info().Msg("Simon: AI Agent Governance Runtime (Initialized)")
 
-	g := guard.New(guard.DefaultPolicy)
+	sessID := r.SessionID
+	if sessID == "" {
+		sessID = fmt.Sprintf("session-%d", time.Now().Unix())
+	}
+
+	obs := r.Observer
+	if r.LogDir != "" {
+		f, err := openSessionLog(r.LogDir, sessID)
+		if err != nil {
+			r.Observer.Log().Warn().Err(err).Msg("Failed to open session log")
+		} else {
+			defer f.Close()
+			obs = r.Observer.WithSessionLog(f)
+		}
+	}
+
+	if r.Workspace != nil {
+		lock, err := r.Workspace.acquire(ctx, obs, r.UI.Log, "session "+sessID)
+		if err != nil {
+			obs.Log().Error().Err(err).Msg("Failed to lock the workspace")
+			return err
+		}
+		defer lock.Release()
+	}
+
+	g := guard.New(r.Policy)
 	c := coach.New()
-	mp := mcp.NewProxy(r.Store, g)
-	rt := runtime.New(r.Store, g, c, r.Observer, r.Provider, mp)
+	c.SetDefaults(r.SpecDefaults)
+	mp, stopPlugins, err := setup.NewProxy(r.Store, g, obs.Log())
+	if err != nil {
+		return err
+	}
+	defer stopPlugins()
+	rt := runtime.New(r.Store, g, c, obs, r.Provider, mp)
+	mp.SetSubtaskRunner(rt)
+	rt.SetWatchEvidence(r.WatchEvidence)
+	if r.Orchestrator != nil {
+		rt.SetOrchestrator(r.Orchestrator)
+	}
+	rt.SetProviderFactory(r.ProviderFactory)
 	rt.SetUI(r.UI)
+	if r.Approver != nil {
+		rt.SetApprover(r.Approver)
+	}
+	if r.Notifier != nil {
+		r.Notifier.Subscribe(rt.EventBus())
+		defer r.Notifier.Wait(notifyTimeout)
+	}
 
 	// Create session
-	sessID := fmt.Sprintf("session-%d", time.Now().Unix())
 	session := &store.Session{
 		ID:        sessID,
 		CreatedAt: time.Now(),
 		Status:    "initialized",
 		Metadata:  map[string]string{"env": "dev", "spec": r.SpecPath},
+		Tags:      r.Tags,
+	}
+
+	if r.Previous != "" {
+		session.Metadata[runtime.MetadataPreviousSession] = r.Previous
+	}
+	if r.GlobalMemory {
+		session.Metadata[runtime.MetadataGlobalMemory] = "true"
+	}
+	for name, value := range r.Vars {
+		session.Metadata[runtime.MetadataVarPrefix+name] = value
 	}
 
 	if err := r.Store.CreateSession(session); err != nil {
-		r.Observer.Log().Error().Err(err).Msg("Failed to create session")
+		obs.Log().Error().Err(err).Msg("Failed to create session")
 		return err
 	}
+	if len(r.Reports) > 0 {
+		defer r.writeReports(obs, sessID)
+	}
+	if c, ok := r.UI.(ui.Controllable); ok {
+		c.SetController(sessionControl{Runtime: rt, store: r.Store, sessionID: sessID})
+	}
 
 	// Validate spec
 	r.UI.UpdateStatus("Loading Spec...")
-	r.Observer.Log().Info().Str("path", r.SpecPath).Msg("loading spec")
-	spec, err := c.LoadSpec(r.SpecPath)
+	obs.Log().Info().Str("path", r.SpecPath).Msg("loading spec")
+	spec, err := c.LoadSpecWithVars(r.SpecPath, r.Vars)
 	if err != nil {
-		r.Observer.Log().Error().Err(err).Msg("Failed to load spec")
+		obs.Log().Error().Err(err).Msg("Failed to load spec")
 		return err
 	}
 
 	validation := c.Validate(*spec)
 	if !validation.Valid {
-		r.Observer.Log().Error().Str("errors", strings.Join(validation.Errors, ", ")).Msg("Invalid spec")
+		obs.Log().Error().Str("errors", strings.Join(validation.Errors, ", ")).Msg("Invalid spec")
 		return fmt.Errorf("invalid spec")
 	}
 
 	r.UI.UpdateStatus("Executing Session...")
+	ctx, interrupted, stop := r.handleInterrupts(ctx, sessID)
+	defer stop()
+	if cp, ok := provider.Find[*provider.CachingProvider](r.Provider); ok {
+		defer func() { r.recordCacheStats(obs, sessID, cp.Stats()) }()
+	}
+
 	// Run
 	if err := rt.ExecuteSession(ctx, sessID); err != nil {
+		if interrupted.Load() {
+			r.UI.UpdateStatus("Interrupted")
+			return r.finishInterrupted(obs, sessID)
+		}
+		if errors.Is(err, runtime.ErrSessionCancelled) {
+			fmt.Printf("Session %s cancelled.\n", sessID)
+			return err
+		}
 		r.UI.UpdateStatus("Execution Failed")
-		r.Observer.Log().Error().Err(err).Msg("Execution failed")
+		obs.Log().Error().Err(err).Msg("Execution failed")
+		r.markFailed(sessID)
 		return err
 	}
 
@@ -76,15 +200,237 @@ func (r *Runner) Run(ctx context.Context) error {
 	return nil
 }
 
+// markFailed records a session that stopped on an error so it is not left "running".
+func (r *Runner) markFailed(sessionID string) {
+	session, err := r.Store.GetSession(sessionID)
+	if err != nil || store.SessionFinished(session.Status) {
+		return
+	}
+	session.Status = "failed"
+	if err := r.Store.UpdateSession(session); err != nil {
+		r.Observer.Log().Warn().Err(err).Msg("Failed to mark session as failed")
+	}
+}
+
+// finishInterrupted records a session stopped by a signal as "interrupted" and
+// makes sure a partial summary artifact exists. The runtime writes one when it
+// cancels gracefully; after a hard abort the last assistant message is used.
+func (r *Runner) finishInterrupted(obs *observe.Observer, sessionID string) error {
+	session, err := r.Store.GetSession(sessionID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errInterrupted, err)
+	}
+	session.Status = "interrupted"
+	if err := r.Store.UpdateSession(session); err != nil {
+		obs.Log().Warn().Err(err).Msg("Failed to mark session as interrupted")
+	}
+
+	if !hasSummary(r.Store, sessionID) {
+		summary := "Partial progress (session interrupted): no assistant output was recorded."
+		if messages, err := r.Store.LoadMessages(sessionID); err == nil {
+			for i := len(messages) - 1; i >= 0; i-- {
+				if messages[i].Role == "assistant" && messages[i].Content != "" {
+					summary = "Partial progress (session interrupted), last response: " + messages[i].Content
+					break
+				}
+			}
+		}
+		artifact := &store.Artifact{
+			ID:        fmt.Sprintf("art-%s-summary", sessionID),
+			SessionID: sessionID,
+			Path:      fmt.Sprintf("artifacts/%s/summary.txt", sessionID),
+			Type:      "summary",
+			CreatedAt: time.Now(),
+		}
+		if err := r.Store.SaveArtifact(artifact, []byte(summary)); err != nil {
+			obs.Log().Warn().Err(err).Msg("Failed to save partial summary")
+		}
+	}
+
+	obs.Log().Info().Str("session", sessionID).Msg("session interrupted")
+	fmt.Printf("Session %s interrupted. Partial summary saved; review it with `simon show %s` and re-run `simon run %s` to continue.\n",
+		sessionID, sessionID, r.SpecPath)
+	return errInterrupted
+}
+
+func hasSummary(s store.Storage, sessionID string) bool {
+	artifacts, err := s.ListArtifacts(sessionID)
+	if err != nil {
+		return false
+	}
+	for _, a := range artifacts {
+		if a.Type == "summary" {
+			return true
+		}
+	}
+	return false
+}
+
+// handleInterrupts turns the first SIGINT/SIGTERM into a graceful cancellation
+// request for the session. A second signal cancels the context immediately.
+// The returned flag reports whether any signal was received.
+func (r *Runner) handleInterrupts(ctx context.Context, sessionID string) (context.Context, *atomic.Bool, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	interrupted := &atomic.Bool{}
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		requested := false
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sigCh:
+				interrupted.Store(true)
+				if requested {
+					r.Observer.Log().Warn().Msg("second interrupt, aborting")
+					cancel()
+					return
+				}
+				requested = true
+				if err := r.Store.RequestCancel(sessionID); err != nil {
+					r.Observer.Log().Error().Err(err).Msg("failed to request cancellation")
+					cancel()
+					return
+				}
+				r.UI.Log("🛑 Interrupt received, cancelling session (press Ctrl+C again to abort)")
+			}
+		}
+	}()
+
+	return ctx, interrupted, func() {
+		signal.Stop(sigCh)
+		cancel()
+	}
+}
+
+// openSessionLog creates the log file that `simon logs` reads for a session.
+func openSessionLog(dir, sessionID string) (*os.File, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(sessionLogPath(dir, sessionID), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+}
+
+// writeReports writes the session's last verification outcome to each
+// requested report file.
+func (r *Runner) writeReports(obs *observe.Observer, sessionID string) {
+	session, err := r.Store.GetSession(sessionID)
+	if err != nil {
+		obs.Log().Warn().Err(err).Msg("Failed to load session for reports")
+		return
+	}
+	report, err := runtime.LatestVerification(r.Store, sessionID)
+	if err != nil {
+		obs.Log().Warn().Err(err).Msg("Failed to load verification report")
+	}
+	sessions := []verifyreport.Session{{ID: session.ID, Spec: r.SpecPath, Status: session.Status, Report: report}}
+	for _, target := range r.Reports {
+		if err := target.WriteFile(sessions); err != nil {
+			obs.Log().Warn().Err(err).Str("path", target.Path).Msg("Failed to write report")
+			continue
+		}
+		obs.Log().Info().Str("format", target.Format).Str("path", target.Path).Msg("verification report written")
+	}
+}
+
+// recordCacheStats reports response cache hits and keeps them in the session metadata.
+func (r *Runner) recordCacheStats(obs *observe.Observer, sessionID string, stats provider.CacheStats) {
+	obs.Log().Info().Int64("hits", stats.Hits).Int64("misses", stats.Misses).Msg("provider response cache")
+	r.UI.Log(fmt.Sprintf("💾 Response cache: %d hits, %d misses", stats.Hits, stats.Misses))
+
+	session, err := r.Store.GetSession(sessionID)
+	if err != nil {
+		return
+	}
+	if session.Metadata == nil {
+		session.Metadata = make(map[string]string)
+	}
+	session.Metadata["cache_hits"] = strconv.FormatInt(stats.Hits, 10)
+	session.Metadata["cache_misses"] = strconv.FormatInt(stats.Misses, 10)
+	if err := r.Store.UpdateSession(session); err != nil {
+		obs.Log().Warn().Err(err).Msg("Failed to record cache stats")
+	}
+}
+
 func NewRunner(obs *observe.Observer, s store.Storage, p provider.Provider, specPath string, u ui.UI) *Runner {
 	if u == nil {
 		u = ui.SilentUI{}
 	}
 	return &Runner{
-		Observer: obs,
-		Store:    s,
-		Provider: p,
-		SpecPath: specPath,
-		UI:       u,
+		Observer:        obs,
+		Store:           s,
+		Provider:        p,
+		SpecPath:        specPath,
+		UI:              u,
+		Policy:          guard.DefaultPolicy,
+		ProviderFactory: providerFactory(s, setup.ProviderOptions{Log: obs.Log()}),
+		SpecDefaults:    projectSpecDefaults(),
 	}
-}
\ No newline at end of file
+}
+
+// projectSpecDefaults returns the spec conventions of the project simon
+// runs in. openStore has already refused an invalid project file.
+func projectSpecDefaults() coach.SpecDefaults {
+	project, _ := loadProject()
+	return project.SpecDefaults()
+}
+
+// providerFactory creates switched-to providers from the store's config,
+// with the same middleware as the session's first provider.
+func providerFactory(s store.Storage, opts setup.ProviderOptions) runtime.ProviderFactory {
+	return func(name, model string) (provider.Provider, func(), error) {
+		return setup.NewProvider(s, name, model, opts)
+	}
+}
+
+// sessionControl lets an interactive UI pause, steer, and cancel the session.
+type sessionControl struct {
+	*runtime.Runtime
+	store     store.Storage
+	sessionID string
+}
+
+func (c sessionControl) Cancel() {
+	_ = c.store.RequestCancel(c.sessionID)
+}
+
+// previousSession returns the session a run continues from: the one named by
+// resumeID, or else, unless fresh is set, the latest top-level session of
+// the same spec if it ended without completing. It returns nil for none.
+func previousSession(s store.Storage, resumeID, specPath string, fresh bool) (*store.Session, error) {
+	if resumeID != "" {
+		prev, err := s.GetSession(resumeID)
+		if err != nil {
+			return nil, fmt.Errorf("cannot resume %s: %w", resumeID, err)
+		}
+		if specPath == "" && prev.Metadata["spec"] == "" {
+			return nil, fmt.Errorf("session %s has no spec file; pass one to simon run", resumeID)
+		}
+		return prev, nil
+	}
+	if fresh {
+		return nil, nil
+	}
+
+	want, err := filepath.Abs(specPath)
+	if err != nil {
+		return nil, nil
+	}
+	sessions, err := s.ListSessions(store.SessionFilter{})
+	if err != nil {
+		return nil, nil
+	}
+	for _, sess := range topLevelSessions(sessions) {
+		if path, err := filepath.Abs(sess.Metadata["spec"]); err != nil || sess.Metadata["spec"] == "" || path != want {
+			continue
+		}
+		// Only the latest run of the spec counts
+		if store.SessionFinished(sess.Status) && sess.Status != "completed" {
+			return sess, nil
+		}
+		return nil, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,302 @@
+package cli
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/coach"
+	"github.com/felixgeelhaar/simon/internal/credential"
+	"github.com/felixgeelhaar/simon/internal/guard"
+	"github.com/felixgeelhaar/simon/internal/observe"
+	"github.com/felixgeelhaar/simon/internal/provider"
+	"github.com/felixgeelhaar/simon/internal/schedule"
+	"github.com/felixgeelhaar/simon/internal/setup"
+	"github.com/felixgeelhaar/simon/internal/store"
+	"github.com/spf13/cobra"
+)
+
+// Config keys for the serve daemon.
+const (
+	keyServeConcurrency = "serve.concurrency"
+	keyServeRatePrefix  = "serve.rate."
+	keyServeSecret      = "serve.api_secret"
+)
+
+var (
+	serveAddr        string
+	serveConcurrency int
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run a daemon that queues submitted sessions",
+	Long: `Run a daemon that accepts specs over HTTP and runs them as sessions, highest
+priority first, with at most --concurrency sessions at a time. All sessions on a
+provider share one request rate limit.
+
+API:
+  GET    /api/queue           Queue stats and jobs
+  POST   /api/sessions        {"spec": "task.yaml", "provider": "openai", "model": "", "priority": 0, "budget": "", "tags": {}}
+  GET    /api/sessions/<id>   Job state
+  DELETE /api/sessions/<id>   Cancel a queued job, or gracefully cancel a running one
+
+Config:
+  serve.concurrency        Sessions run at once (default 2)
+  serve.rate.<provider>    Provider requests per minute across all sessions
+  serve.api_secret         Bearer token required by the API (encrypted)
+  serve.heartbeat_interval How often running sessions send a heartbeat (default 15s)
+  serve.stale_after        Heartbeat age at which a session is orphaned (default 4 intervals)
+  serve.auto_resume        Queue a session continuing each orphaned one (true/false)
+
+Spec paths are resolved against the daemon's working directory, and every
+session takes the workspace lock of its repository, waiting for the session
+or simon run holding it, so sessions never edit it at the same time. A session
+whose process stops sending heartbeats, for instance because an earlier
+daemon crashed, is marked "orphaned" instead of staying "running".`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		obs := observe.New(os.Stdout, verbose)
+		defer obs.Close()
+
+		s, err := openStore()
+		if err != nil {
+			fmt.Printf("Failed to init store: %v\n", err)
+			os.Exit(1)
+		}
+		defer s.Close()
+
+		d, err := newDaemon(obs, s)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		// Sessions queue behind whoever edits the working directory's
+		// repository, including each other
+		d.lock = &workspaceLock{Wait: true}
+		opts, err := scheduleOptions(s)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if cmd.Flags().Changed("concurrency") {
+			opts.MaxConcurrent = serveConcurrency
+		}
+		secret, err := setup.SecretConfig(s, keyServeSecret, func() (*credential.Manager, error) { return credentialManager(s) })
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		sched := schedule.New(d.run, opts)
+		srv := &http.Server{
+			Addr:              serveAddr,
+			Handler:           requireToken(secret, schedule.Handler(sched, d.validate)),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		done := make(chan struct{})
+		go func() {
+			sched.Run(ctx)
+			close(done)
+		}()
+		go d.reapEvery(ctx, sched.Submit)
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = srv.Shutdown(shutdownCtx)
+		}()
+
+		fmt.Printf("Serving on %s (%d concurrent sessions)\n", serveAddr, sched.Stats().MaxConcurrent)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("Server failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Waiting for running sessions to stop...")
+		<-done
+	},
+}
+
+// daemon runs scheduled jobs as sessions.
+type daemon struct {
+	obs     *observe.Observer
+	store   *store.SQLiteStore
+	policy  guard.Policy
+	cache   bool
+	workDir string
+	beats   heartbeatOptions
+	owner   string
+	// lock, when set, is how each session takes the workspace lock
+	lock *workspaceLock
+}
+
+func newDaemon(obs *observe.Observer, s *store.SQLiteStore) (*daemon, error) {
+	policy, err := loadPolicy()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load policy: %w", err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	beats, err := heartbeatConfig(s)
+	if err != nil {
+		return nil, err
+	}
+	cache, _ := s.GetConfig("cache.enabled")
+	return &daemon{obs: obs, store: s, policy: policy, cache: cache == "true", workDir: wd, beats: beats, owner: heartbeatOwner()}, nil
+}
+
+// validate resolves a submission's spec path and its provider and model
+// (the request's, then the spec's, then the profile defaults) and rejects
+// specs that fail the coach's validation and unknown budget presets.
+func (d *daemon) validate(job *schedule.Job) error {
+	if job.SpecPath == "" {
+		return fmt.Errorf("spec is required")
+	}
+	if !filepath.IsAbs(job.SpecPath) {
+		job.SpecPath = filepath.Join(d.workDir, job.SpecPath)
+	}
+	spec, err := coach.New().LoadSpecWithVars(job.SpecPath, job.Vars)
+	if err != nil {
+		return err
+	}
+	if res := coach.New().Validate(*spec); !res.Valid {
+		return fmt.Errorf("invalid spec: %s", strings.Join(res.Errors, ", "))
+	}
+
+	job.Provider, job.Model = setup.ResolveProvider(d.store, spec, job.Provider, job.Model)
+	if !slices.Contains(provider.Names, job.Provider) {
+		return fmt.Errorf("unknown provider %q", job.Provider)
+	}
+	_, err = d.jobPolicy(*job)
+	return err
+}
+
+// jobPolicy returns the daemon's policy scaled by the job's budget preset.
+func (d *daemon) jobPolicy(job schedule.Job) (guard.Policy, error) {
+	if job.Budget == "" {
+		return d.policy, nil
+	}
+	budget, err := guard.ResolveBudget(job.Budget, func(key string) string {
+		v, _ := d.store.GetConfig(key)
+		return v
+	})
+	if err != nil {
+		return d.policy, err
+	}
+	return d.policy.WithBudget(budget), nil
+}
+
+// run executes a job as a session, sending heartbeats while it runs.
+// Cancelling ctx requests a graceful cancellation, as `simon cancel` does,
+// instead of cutting the session off.
+func (d *daemon) run(ctx context.Context, job schedule.Job, limiter *guard.RateLimiter) error {
+	policy, err := d.jobPolicy(job)
+	if err != nil {
+		return err
+	}
+	opts := setup.ProviderOptions{Cache: d.cache, Log: d.obs.Log()}
+	// The job's sessions share its provider's serve.rate.<provider> limit
+	jobOpts := opts
+	jobOpts.RateLimiter = limiter
+	p, stop, err := setup.NewProvider(d.store, job.Provider, job.Model, jobOpts)
+	if err != nil {
+		return err
+	}
+	defer stop()
+	notifier, err := loadNotifier(d.store)
+	if err != nil {
+		return err
+	}
+	if d.lock != nil {
+		// Cancelling the job stops its wait for the lock
+		lock, err := d.lock.acquire(ctx, d.obs, func(string) {}, "session "+job.ID)
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+	}
+
+	stopCancel := context.AfterFunc(ctx, func() {
+		if err := d.store.RequestCancel(job.ID); err != nil {
+			d.obs.Log().Warn().Str("session", job.ID).Err(err).Msg("failed to request cancellation")
+		}
+	})
+	defer stopCancel()
+	defer d.heartbeat(job.ID)()
+
+	runner := NewRunner(d.obs, d.store, p, job.SpecPath, nil)
+	runner.Policy = policy
+	runner.LogDir = logDir()
+	runner.Tags = job.Tags
+	runner.Vars = job.Vars
+	runner.Notifier = notifier
+	runner.SessionID = job.ID
+	runner.Previous = job.Previous
+	runner.ProviderFactory = providerFactory(d.store, opts)
+	return runner.Run(context.WithoutCancel(ctx))
+}
+
+// scheduleOptions reads the serve.* config keys.
+func scheduleOptions(s store.Storage) (schedule.Options, error) {
+	var opts schedule.Options
+	if v, _ := s.GetConfig(keyServeConcurrency); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return opts, fmt.Errorf("invalid %s: %q", keyServeConcurrency, v)
+		}
+		opts.MaxConcurrent = n
+	}
+	for _, name := range provider.Names {
+		v, _ := s.GetConfig(keyServeRatePrefix + name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return opts, fmt.Errorf("invalid %s%s: %q", keyServeRatePrefix, name, v)
+		}
+		if opts.ProviderRates == nil {
+			opts.ProviderRates = make(map[string]int)
+		}
+		opts.ProviderRates[name] = n
+	}
+	return opts, nil
+}
+
+// requireToken rejects requests without the bearer token; an empty token
+// leaves the API open.
+func requireToken(token string, next http.Handler) http.Handler {
+	if token == "" {
+		return next
+	}
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func init() {
+	RootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().StringVar(&serveAddr, "addr", "127.0.0.1:7777", "Address to listen on")
+	serveCmd.Flags().IntVar(&serveConcurrency, "concurrency", 0, "Sessions run at once (default: serve.concurrency or 2)")
+	serveCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose logging")
+}
@@ -0,0 +1,262 @@
+package cli
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/coach"
+	"github.com/felixgeelhaar/simon/internal/mcp"
+	"github.com/felixgeelhaar/simon/internal/provider"
+	"github.com/felixgeelhaar/simon/internal/runtime"
+	"github.com/felixgeelhaar/simon/internal/setup"
+	"github.com/felixgeelhaar/simon/internal/store"
+	"github.com/spf13/cobra"
+)
+
+//go:embed share.html
+var shareTemplate string
+
+var (
+	shareOut    string
+	shareRedact []string
+)
+
+var shareCmd = &cobra.Command{
+	Use:   "share <session-id>",
+	Short: "Write a redacted, self-contained HTML page of a session",
+	Long: `Write a single HTML file with a session's transcript, the diffs of the files
+it changed, and its verification results, for attaching to an issue or sending
+to a reviewer. The file has no external resources.
+
+Configured API keys, the values of the spec's env and of ${NAME} placeholders
+resolved from the environment, values matching well-known
+credential formats (OpenAI, Anthropic, Google, Groq, GitHub, Slack, AWS, bearer
+tokens), and every --redact string are replaced by [REDACTED], and the home
+directory by ~. Other secrets are not detected, so read the file before
+sharing it.
+
+Examples:
+  simon share session-1712345678
+  simon share session-1712345678 --redact internal.example.com -o review.html`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeSessionIDs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		s := getStore()
+		defer s.Close()
+
+		out := shareOut
+		if out == "" {
+			out = args[0] + ".html"
+		}
+		data, err := buildShare(s, args[0], shareRedact)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		f, err := os.Create(out)
+		if err != nil {
+			fmt.Printf("Failed to create %s: %v\n", out, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		if err := renderShare(f, data); err != nil {
+			fmt.Printf("Failed to render session: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Session %s written to %s (%d redactions); review it before sharing.\n", args[0], out, data.Redactions)
+	},
+}
+
+// shareData is everything the share template renders. All text in it is
+// redacted.
+type shareData struct {
+	GeneratedAt time.Time
+	Session     *store.Session
+	Spec        string
+	Goal        string
+	Summary     string
+	Transcript  []shareMessage
+	Changes     []mcp.FileChange
+	Diff        []shareDiffLine
+	// ToolStats is the session's tool usage, most called first.
+	ToolStats []runtime.ToolStat
+	// Verification is the session's last verification, nil if its
+	// evidence was never checked.
+	Verification *mcp.EvidenceReport
+	Redactions   int
+}
+
+// shareMessage is one message of the transcript.
+type shareMessage struct {
+	Role      string
+	Content   string
+	ToolCalls []provider.ToolCall
+}
+
+// shareDiffLine is one line of the workspace diff, classed for coloring.
+type shareDiffLine struct {
+	Class string
+	Text  string
+}
+
+// shareRedaction replaces secrets in text, counting the replacements.
+type shareRedaction struct {
+	sanitizer *provider.Sanitizer
+	extra     []string
+	count     int
+}
+
+func (r *shareRedaction) String(text string) string {
+	before := strings.Count(text, "[REDACTED]")
+	for _, secret := range r.extra {
+		text = strings.ReplaceAll(text, secret, "[REDACTED]")
+	}
+	text = r.sanitizer.String(text)
+	r.count += strings.Count(text, "[REDACTED]") - before
+	return text
+}
+
+// buildShare gathers a session's transcript, changes, and verification,
+// redacting the configured API keys, the spec's secrets (env values and
+// placeholders resolved from the environment), and extra.
+func buildShare(s store.Storage, id string, extra []string) (shareData, error) {
+	sess, err := s.GetSession(id)
+	if err != nil {
+		return shareData{}, err
+	}
+
+	var secrets []string
+	spec, err := sessionSpec(s, sess)
+	if err == nil {
+		secrets = spec.Secrets()
+	}
+	// The stored spec has its ${NAME} placeholders resolved; resolving the
+	// spec file again finds the values taken from the environment
+	if path := sess.Metadata["spec"]; path != "" {
+		if source, err := coach.New().LoadSpec(path); err == nil {
+			source.Resolve(runtime.SessionVars(sess))
+			secrets = append(secrets, source.Secrets()...)
+		}
+	}
+	var literal []string
+	for _, e := range extra {
+		if e != "" {
+			literal = append(literal, e)
+		}
+	}
+	// Longer secrets first, so one containing another is redacted whole
+	sort.Slice(literal, func(i, j int) bool { return len(literal[i]) > len(literal[j]) })
+	r := &shareRedaction{sanitizer: setup.Sanitizer(s, secrets...), extra: literal}
+
+	redacted := *sess
+	redacted.Tags = make(map[string]string, len(sess.Tags))
+	for k, v := range sess.Tags {
+		redacted.Tags[k] = r.String(v)
+	}
+	redacted.Metadata = nil
+	data := shareData{GeneratedAt: time.Now(), Session: &redacted, Spec: r.String(sess.Metadata["spec"]), Goal: r.String(spec.Goal)}
+	data.ToolStats = runtime.ToolStats(sess)
+	for i := range data.ToolStats {
+		data.ToolStats[i].Tool = r.String(data.ToolStats[i].Tool)
+	}
+
+	messages, err := s.LoadMessages(id)
+	if err != nil {
+		return data, fmt.Errorf("failed to load messages: %w", err)
+	}
+	for _, m := range messages {
+		msg := shareMessage{Role: m.Role, Content: r.String(m.Content)}
+		if m.ToolCalls != "" && json.Unmarshal([]byte(m.ToolCalls), &msg.ToolCalls) == nil {
+			for i := range msg.ToolCalls {
+				msg.ToolCalls[i].Args = r.String(msg.ToolCalls[i].Args)
+			}
+		}
+		data.Transcript = append(data.Transcript, msg)
+	}
+
+	artifacts, err := s.ListArtifacts(id)
+	if err != nil {
+		return data, fmt.Errorf("failed to list artifacts: %w", err)
+	}
+	for _, a := range artifacts {
+		_, content, err := s.GetArtifact(a.ID)
+		if err != nil {
+			continue
+		}
+		switch a.Type {
+		case "summary":
+			data.Summary = r.String(runtime.StripToolUsage(string(content)))
+		case runtime.ArtifactFileManifest:
+			if json.Unmarshal(content, &data.Changes) == nil {
+				for i := range data.Changes {
+					data.Changes[i].Path = r.String(data.Changes[i].Path)
+				}
+			}
+		case runtime.ArtifactWorkspaceDiff:
+			data.Diff = diffLines(r.String(string(content)))
+		}
+	}
+
+	report, err := runtime.LatestVerification(s, id)
+	if err != nil {
+		return data, fmt.Errorf("failed to load verification: %w", err)
+	}
+	if report != nil {
+		for i := range report.Items {
+			item := &report.Items[i]
+			item.Item, item.Detail = r.String(item.Item), r.String(item.Detail)
+		}
+		data.Verification = report
+	}
+	data.Redactions = r.count
+	return data, nil
+}
+
+// diffLines splits a unified diff into lines classed by their prefix.
+func diffLines(diff string) []shareDiffLine {
+	var lines []shareDiffLine
+	for _, line := range strings.Split(strings.TrimRight(diff, "\n"), "\n") {
+		class := ""
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			class = "file"
+		case strings.HasPrefix(line, "@@"):
+			class = "hunk"
+		case strings.HasPrefix(line, "+"):
+			class = "add"
+		case strings.HasPrefix(line, "-"):
+			class = "del"
+		}
+		lines = append(lines, shareDiffLine{Class: class, Text: line})
+	}
+	return lines
+}
+
+func renderShare(w io.Writer, data shareData) error {
+	tmpl, err := template.New("share").Funcs(template.FuncMap{
+		"tags": formatTags,
+		"percent": func(f float64) string {
+			return fmt.Sprintf("%.0f%%", f*100)
+		},
+		"duration": func(d time.Duration) string {
+			return d.Round(time.Millisecond).String()
+		},
+	}).Parse(shareTemplate)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, data)
+}
+
+func init() {
+	RootCmd.AddCommand(shareCmd)
+	shareCmd.Flags().StringVarP(&shareOut, "out", "o", "", "Output file (default <session-id>.html)")
+	shareCmd.Flags().StringArrayVar(&shareRedact, "redact", nil, "Also redact this string (repeatable)")
+}
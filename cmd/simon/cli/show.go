@@ -0,0 +1,173 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/felixgeelhaar/simon/internal/mcp"
+	"github.com/felixgeelhaar/simon/internal/runtime"
+	"github.com/felixgeelhaar/simon/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var showDiff bool
+
+var showCmd = &cobra.Command{
+	Use:   "show <session-id>",
+	Short: "Show a session's details and the files it changed",
+	Long: `Show a session's status, usage, and summary, plus the manifest of files the
+agent created, modified, or deleted. Use --diff to print the full diffs.
+
+Sessions that failed show their post-mortem: why they stopped, the guard
+violations and checks that never passed, the artifacts to read first, and
+recommendations with a revised spec for the next attempt.
+
+Examples:
+  simon show session-1712345678
+  simon show session-1712345678 --diff`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeSessionIDs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		s := getStore()
+		defer s.Close()
+
+		if err := showSession(os.Stdout, s, args[0], showDiff); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+// showSession prints a session and its change manifest to out.
+func showSession(out io.Writer, s store.Storage, id string, withDiff bool) error {
+	sess, err := s.GetSession(id)
+	if err != nil {
+		return err
+	}
+	artifacts, err := s.ListArtifacts(id)
+	if err != nil {
+		return fmt.Errorf("failed to list artifacts: %w", err)
+	}
+	byType := make(map[string]*store.Artifact)
+	for _, a := range artifacts {
+		byType[a.Type] = a
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Session:\t%s\n", sess.ID)
+	fmt.Fprintf(w, "Status:\t%s\n", sess.Status)
+	if sess.ParentID != "" {
+		fmt.Fprintf(w, "Parent:\t%s\n", sess.ParentID)
+	}
+	fmt.Fprintf(w, "Created:\t%s\n", sess.CreatedAt.Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(w, "Spec:\t%s\n", sess.Metadata["spec"])
+	if sess.Provider != "" {
+		fmt.Fprintf(w, "Model:\t%s (%s)\n", sess.Model, sess.Provider)
+	}
+	fmt.Fprintf(w, "Tokens:\t%d prompt, %d completion\n", sess.PromptTokens, sess.CompletionTokens)
+	fmt.Fprintf(w, "Cost:\t$%.4f\n", sess.Cost)
+	if len(sess.Tags) > 0 {
+		fmt.Fprintf(w, "Tags:\t%s\n", formatTags(sess.Tags))
+	}
+	w.Flush()
+
+	if children, err := s.ListSessions(store.SessionFilter{ParentID: id}); err == nil && len(children) > 0 {
+		fmt.Fprintf(out, "\nSub-tasks (%d, included in the usage above):\n", len(children))
+		w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		for _, c := range children {
+			fmt.Fprintf(w, "  %s\t%s\t$%.4f\n", c.ID, c.Status, c.Cost)
+		}
+		w.Flush()
+	}
+
+	if a := byType["summary"]; a != nil {
+		if _, content, err := s.GetArtifact(a.ID); err == nil {
+			fmt.Fprintf(out, "\nSummary:\n%s\n", runtime.StripToolUsage(string(content)))
+		}
+	}
+	if stats := runtime.ToolStats(sess); len(stats) > 0 {
+		fmt.Fprintf(out, "\nTool usage:\n%s\n", runtime.FormatToolStats(stats))
+	}
+	if pm, err := runtime.LoadPostMortem(s, id); err != nil {
+		fmt.Fprintf(out, "\nPost-mortem unavailable: %v\n", err)
+	} else if pm != nil {
+		printPostMortem(out, pm)
+	}
+
+	a := byType[runtime.ArtifactFileManifest]
+	if a == nil {
+		fmt.Fprintln(out, "\nNo file change manifest recorded.")
+		return nil
+	}
+	_, content, err := s.GetArtifact(a.ID)
+	if err != nil {
+		return fmt.Errorf("failed to read change manifest: %w", err)
+	}
+	var changes []mcp.FileChange
+	if err := json.Unmarshal(content, &changes); err != nil {
+		return fmt.Errorf("invalid change manifest: %w", err)
+	}
+	if len(changes) == 0 {
+		fmt.Fprintln(out, "\nNo files changed.")
+		return nil
+	}
+	fmt.Fprintf(out, "\nChanged files (%d):\n", len(changes))
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, c := range changes {
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", c.Action, c.Path, c.Source)
+	}
+	w.Flush()
+
+	if withDiff {
+		if a := byType[runtime.ArtifactWorkspaceDiff]; a != nil {
+			if _, diff, err := s.GetArtifact(a.ID); err == nil {
+				fmt.Fprintf(out, "\n%s", diff)
+			}
+		}
+	}
+	return nil
+}
+
+// printPostMortem prints the post-mortem of a failed session.
+func printPostMortem(out io.Writer, pm *runtime.PostMortem) {
+	fmt.Fprintln(out, "\nPost-mortem:")
+	reason, _, _ := strings.Cut(pm.Reason, "\n")
+	fmt.Fprintf(out, "  Stopped: %s\n", reason)
+	if len(pm.Plan) > 0 {
+		done := 0
+		for _, step := range pm.Plan {
+			if step.Status == runtime.StepDone {
+				done++
+			}
+		}
+		fmt.Fprintf(out, "  Plan: %d of %d steps done\n", done, len(pm.Plan))
+	}
+	if len(pm.Violations) > 0 {
+		fmt.Fprintln(out, "  Violations:")
+		for _, v := range pm.Violations {
+			fmt.Fprintf(out, "    %s (%s): %s\n", v.Rule, v.Severity, v.Message)
+		}
+	}
+	if len(pm.Unverified) > 0 {
+		fmt.Fprintln(out, "  Never verified:")
+		for _, item := range pm.Unverified {
+			fmt.Fprintf(out, "    %s %s (%s)\n", item.Kind, item.Item, item.Status)
+		}
+	}
+	if len(pm.Artifacts) > 0 {
+		fmt.Fprintf(out, "  Artifacts: %s\n", strings.Join(pm.Artifacts, ", "))
+	}
+	fmt.Fprintln(out, "  Recommendations:")
+	for _, rec := range pm.Recommendations {
+		fmt.Fprintf(out, "    - %s\n", rec)
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(showCmd)
+	showCmd.Flags().BoolVar(&showDiff, "diff", false, "Print the diff of every changed text file")
+}
@@ -0,0 +1,227 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/coach"
+	"github.com/felixgeelhaar/simon/internal/guard"
+	"github.com/felixgeelhaar/simon/internal/mcp"
+	"github.com/felixgeelhaar/simon/internal/runtime"
+	"github.com/felixgeelhaar/simon/internal/store"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var specFromSessionOutput string
+
+var specCmd = &cobra.Command{
+	Use:   "spec",
+	Short: "Validate task specs, print their JSON Schema, and derive them from sessions",
+}
+
+var specValidateCmd = &cobra.Command{
+	Use:   "validate <file>",
+	Short: "Check a spec against the TaskSpec schema",
+	Long: `Check a YAML or JSON spec against the TaskSpec JSON Schema and report each
+problem as file:line:column. Coach warnings are printed for valid specs.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeSpecFile,
+	Run: func(cmd *cobra.Command, args []string) {
+		ok, err := validateSpec(os.Stdout, args[0])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if !ok {
+			os.Exit(1)
+		}
+	},
+}
+
+var specSchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the TaskSpec JSON Schema",
+	Long: `Print the TaskSpec JSON Schema. Editors with a YAML language server can use
+the published copy by starting a spec with:
+
+  # yaml-language-server: $schema=` + coach.SchemaURL,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		os.Stdout.Write(coach.Schema)
+	},
+}
+
+var specFromSessionCmd = &cobra.Command{
+	Use:   "from-session <session-id>",
+	Short: "Turn a completed session into a reusable spec",
+	Long: `Reconstruct a spec from a completed session, to repeat an ad-hoc run that
+worked: its goal, definition of done, constraints, evidence, and checks as it
+ran with them. A session whose spec listed no evidence or checks gets the
+files it created as evidence. The budget the session actually used is noted
+in a comment, with the smallest --budget preset that covers it.
+
+The spec is printed as YAML, or written to --output as YAML or JSON by its
+extension.
+
+Examples:
+  simon spec from-session session-1712345678
+  simon spec from-session session-1712345678 -o specs/add-endpoint.yaml`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeSessionIDs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		s := getStore()
+		defer s.Close()
+
+		if err := specFromSession(os.Stdout, s, args[0], specFromSessionOutput); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+// specFromSession writes the spec reconstructed from a completed session to
+// output, or as YAML to out when output is empty.
+func specFromSession(out io.Writer, s store.Storage, id, output string) error {
+	sess, err := s.GetSession(id)
+	if err != nil {
+		return err
+	}
+	if sess.Status != "completed" {
+		return fmt.Errorf("session %s is %s; only completed sessions can become specs", id, sess.Status)
+	}
+	spec, err := sessionSpec(s, sess)
+	if err != nil {
+		return err
+	}
+	if len(spec.Evidence) == 0 && len(spec.Verify) == 0 && len(spec.Checks) == 0 && len(spec.Steps) == 0 {
+		spec.Evidence = createdFiles(s, id)
+	}
+	note, err := budgetNote(s, sess)
+	if err != nil {
+		return err
+	}
+
+	if strings.EqualFold(filepath.Ext(output), ".json") {
+		if err := coach.WriteSpec(output, spec); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Wrote %s\n%s\n", output, note)
+		return nil
+	}
+	data, err := yaml.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	header := fmt.Sprintf("# yaml-language-server: $schema=%s\n# Reconstructed from session %s.\n# %s\n", coach.SchemaURL, id, note)
+	data = append([]byte(header), data...)
+	switch strings.ToLower(filepath.Ext(output)) {
+	case "":
+		_, err = out.Write(data)
+		return err
+	case ".yaml", ".yml":
+		if err := os.WriteFile(output, data, 0600); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Wrote %s\n%s\n", output, note)
+		return nil
+	}
+	return fmt.Errorf("unsupported spec format: %s (use .json or .yaml)", filepath.Ext(output))
+}
+
+// createdFiles lists the files a session's change manifest records as
+// created, or nil without a manifest.
+func createdFiles(s store.Storage, id string) []string {
+	artifacts, err := s.ListArtifacts(id)
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, a := range artifacts {
+		if a.Type != runtime.ArtifactFileManifest {
+			continue
+		}
+		_, data, err := s.GetArtifact(a.ID)
+		if err != nil {
+			continue
+		}
+		var changes []mcp.FileChange
+		if json.Unmarshal(data, &changes) != nil {
+			continue
+		}
+		for _, c := range changes {
+			if c.Action == "created" {
+				files = append(files, c.Path)
+			}
+		}
+	}
+	return files
+}
+
+// budgetNote describes what a session spent and the smallest built-in
+// budget preset that would have allowed it.
+func budgetNote(s store.Storage, sess *store.Session) (string, error) {
+	messages, err := s.LoadMessages(sess.ID)
+	if err != nil {
+		return "", fmt.Errorf("failed to load messages of %s: %w", sess.ID, err)
+	}
+	used := guard.Budget{
+		MaxPromptTokens: sess.PromptTokens,
+		MaxOutputTokens: sess.CompletionTokens,
+		MaxCost:         sess.Cost,
+		MaxDuration:     sess.UpdatedAt.Sub(sess.CreatedAt).Round(time.Second),
+	}
+	for _, m := range messages {
+		if m.Role == "assistant" {
+			used.MaxIterations++
+		}
+	}
+
+	note := fmt.Sprintf("Budget used: %d iterations, %d prompt tokens, %d completion tokens, $%.4f, %s",
+		used.MaxIterations, used.MaxPromptTokens, used.MaxOutputTokens, used.MaxCost, used.MaxDuration)
+	for _, name := range guard.BudgetNames() {
+		if guard.BudgetPresets[name].Covers(used) {
+			return note + " (fits --budget " + name + ")", nil
+		}
+	}
+	return note + " (more than any built-in --budget preset)", nil
+}
+
+// validateSpec writes schema violations, or coach warnings for a valid spec,
+// to out and reports whether the spec is valid.
+func validateSpec(out io.Writer, path string) (bool, error) {
+	errs, err := coach.ValidateSchemaFile(path)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, e := range errs {
+		fmt.Fprintf(out, "%s:%s\n", path, e.Error())
+	}
+	if len(errs) > 0 {
+		return false, nil
+	}
+
+	spec, err := coach.New().LoadSpec(path)
+	if err != nil {
+		return false, err
+	}
+	for _, w := range coach.New().Validate(*spec).Warnings {
+		fmt.Fprintf(out, "%s: warning: %s\n", path, w)
+	}
+	fmt.Fprintf(out, "%s is valid\n", path)
+	return true, nil
+}
+
+func init() {
+	RootCmd.AddCommand(specCmd)
+	specCmd.AddCommand(specValidateCmd)
+	specCmd.AddCommand(specSchemaCmd)
+	specCmd.AddCommand(specFromSessionCmd)
+
+	specFromSessionCmd.Flags().StringVarP(&specFromSessionOutput, "output", "o", "", "Write the spec to this .yaml or .json file instead of printing it")
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/felixgeelhaar/simon/internal/provider"
+	"github.com/felixgeelhaar/simon/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	steerProvider string
+	steerModel    string
+)
+
+var steerCmd = &cobra.Command{
+	Use:   "steer <session-id> [message]",
+	Short: "Steer a running session",
+	Long: `Send a message to a running session, or switch its provider or model.
+
+The running loop takes the request before its next iteration: the message is
+added to the conversation before the next provider call, and --provider or
+--model moves the session onto another provider or model, keeping its
+conversation. Without --provider, --model switches the session's current
+provider to another model.
+
+Examples:
+  simon steer session-1712345678 "Use the existing helpers in util.go"
+  simon steer session-1712345678 --provider anthropic --model claude-sonnet-4-5
+  simon steer session-1712345678 --model gpt-4o "Try a different approach"`,
+	Args:              cobra.RangeArgs(1, 2),
+	ValidArgsFunction: completeSessionIDs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		s := getStore()
+		defer s.Close()
+
+		req := &store.SteeringRequest{SessionID: args[0], Provider: steerProvider, Model: steerModel}
+		if len(args) > 1 {
+			req.Message = args[1]
+		}
+		if err := steerSession(s, req); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("Steering queued for session %s; it applies before the next iteration.\n", args[0])
+	},
+}
+
+// steerer is implemented by stores that queue steering requests.
+type steerer interface {
+	SteerSession(req *store.SteeringRequest) error
+}
+
+// steerSession queues req for its session's running loop.
+func steerSession(s store.Storage, req *store.SteeringRequest) error {
+	if req.Message == "" && req.Provider == "" && req.Model == "" {
+		return errors.New("nothing to steer: give a message, --provider, or --model")
+	}
+	if req.Provider != "" && !slices.Contains(provider.Names, req.Provider) {
+		return fmt.Errorf("unknown provider %q", req.Provider)
+	}
+	session, err := s.GetSession(req.SessionID)
+	if err != nil {
+		return err
+	}
+	if store.SessionFinished(session.Status) {
+		return fmt.Errorf("session %s already finished (%s)", req.SessionID, session.Status)
+	}
+
+	st, ok := s.(steerer)
+	if !ok {
+		return errors.New("this store does not support steering")
+	}
+	if err := st.SteerSession(req); err != nil {
+		return fmt.Errorf("failed to queue steering: %w", err)
+	}
+	return nil
+}
+
+func init() {
+	RootCmd.AddCommand(steerCmd)
+	steerCmd.Flags().StringVar(&steerProvider, "provider", "", "Switch the session to this provider")
+	steerCmd.Flags().StringVar(&steerModel, "model", "", "Switch the session to this model")
+	steerCmd.RegisterFlagCompletionFunc("provider", cobra.FixedCompletions(provider.Names, cobra.ShellCompDirectiveNoFileComp))
+}
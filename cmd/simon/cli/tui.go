@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/felixgeelhaar/simon/internal/ui/tui"
+	"github.com/spf13/cobra"
+)
+
+var tuiLimit int
+
+var tuiCmd = &cobra.Command{
+	Use:   "tui",
+	Short: "Browse past sessions and artifacts interactively",
+	Long: `Open a multi-pane terminal browser over stored sessions.
+
+The left pane lists sessions, the right pane shows status, usage, evidence,
+and artifacts for the selected session. Press enter on an artifact to open
+it in a scrollable viewer.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		s := getStore()
+		defer s.Close()
+
+		program := tea.NewProgram(tui.NewBrowserModel(s, tuiLimit), tea.WithAltScreen())
+		if _, err := program.Run(); err != nil {
+			fmt.Printf("Alas, there's been an error: %v", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(tuiCmd)
+	tuiCmd.Flags().IntVar(&tuiLimit, "limit", 200, "Maximum number of sessions to load")
+}
@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var usageSince string
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Aggregate token usage and estimated cost across sessions",
+	Long: `Aggregate token usage and estimated cost across sessions, grouped by provider and model.
+
+Examples:
+  simon usage --since 7d
+  simon usage --since 24h`,
+	Run: func(cmd *cobra.Command, args []string) {
+		window, err := parseSince(usageSince)
+		if err != nil {
+			fmt.Printf("Invalid --since value: %v\n", err)
+			os.Exit(1)
+		}
+
+		s := getStore()
+		defer s.Close()
+
+		filter := store.SessionFilter{}
+		if window > 0 {
+			filter.Since = time.Now().Add(-window)
+		}
+		sessions, err := s.ListSessions(filter)
+		if err != nil {
+			fmt.Printf("Failed to list sessions: %v\n", err)
+			os.Exit(1)
+		}
+
+		rows := aggregateUsage(sessions)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PROVIDER\tMODEL\tSESSIONS\tPROMPT\tCOMPLETION\tCOST (USD)")
+		var total usageRow
+		for _, row := range rows {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t$%.4f\n", row.Provider, row.Model, row.Sessions, row.PromptTokens, row.CompletionTokens, row.Cost)
+			total.Sessions += row.Sessions
+			total.PromptTokens += row.PromptTokens
+			total.CompletionTokens += row.CompletionTokens
+			total.Cost += row.Cost
+		}
+		fmt.Fprintf(w, "TOTAL\t\t%d\t%d\t%d\t$%.4f\n", total.Sessions, total.PromptTokens, total.CompletionTokens, total.Cost)
+		w.Flush()
+	},
+}
+
+// usageRow is the aggregated usage for a single provider/model pair.
+type usageRow struct {
+	Provider         string
+	Model            string
+	Sessions         int
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64
+}
+
+// aggregateUsage groups sessions by provider and model, ordered by cost descending.
+func aggregateUsage(sessions []*store.Session) []usageRow {
+	byKey := make(map[string]*usageRow)
+	for _, sess := range topLevelSessions(sessions) {
+		providerName := sess.Provider
+		if providerName == "" {
+			providerName = "unknown"
+		}
+		key := providerName + "/" + sess.Model
+		row, ok := byKey[key]
+		if !ok {
+			row = &usageRow{Provider: providerName, Model: sess.Model}
+			byKey[key] = row
+		}
+		row.Sessions++
+		row.PromptTokens += sess.PromptTokens
+		row.CompletionTokens += sess.CompletionTokens
+		row.Cost += sess.Cost
+	}
+
+	rows := make([]usageRow, 0, len(byKey))
+	for _, row := range byKey {
+		rows = append(rows, *row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Cost != rows[j].Cost {
+			return rows[i].Cost > rows[j].Cost
+		}
+		return rows[i].Provider+rows[i].Model < rows[j].Provider+rows[j].Model
+	})
+	return rows
+}
+
+// parseSince parses a lookback window such as "7d", "2w" or any time.ParseDuration value.
+// An empty string means no limit.
+func parseSince(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	units := map[string]time.Duration{
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+	}
+	for suffix, unit := range units {
+		if strings.HasSuffix(s, suffix) {
+			n, err := strconv.Atoi(strings.TrimSuffix(s, suffix))
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("invalid duration %q", s)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+	return time.ParseDuration(s)
+}
+
+func init() {
+	RootCmd.AddCommand(usageCmd)
+	usageCmd.Flags().StringVar(&usageSince, "since", "", "Only include sessions from this window (e.g. 7d, 24h)")
+}
+
+// topLevelSessions drops sub-task sessions, whose usage is already rolled up
+// into their parent's totals, and `simon watch` records, which aren't agent
+// sessions.
+func topLevelSessions(sessions []*store.Session) []*store.Session {
+	var top []*store.Session
+	for _, sess := range sessions {
+		if sess.ParentID == "" && sess.Metadata[metadataWatch] == "" {
+			top = append(top, sess)
+		}
+	}
+	return top
+}
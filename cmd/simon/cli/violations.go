@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	violationsSince   string
+	violationsSession string
+	violationsTop     int
+)
+
+var violationsCmd = &cobra.Command{
+	Use:   "violations",
+	Short: "Aggregate guard violations by rule, command, and session",
+	Long: `Aggregate the guard violations recorded by past sessions by rule, by the
+command or file path at fault, and by session. Commands or paths blocked
+across many sessions are candidates for allowed_commands or
+allowed_file_globs; rules that only warn may deserve a lower severity.
+
+Examples:
+  simon violations --since 30d
+  simon violations --session session-1712345678 --top 0`,
+	Run: func(cmd *cobra.Command, args []string) {
+		window, err := parseSince(violationsSince)
+		if err != nil {
+			fmt.Printf("Invalid --since value: %v\n", err)
+			os.Exit(1)
+		}
+
+		s := getStore()
+		defer s.Close()
+
+		filter := store.ViolationFilter{SessionID: violationsSession}
+		if window > 0 {
+			filter.Since = time.Now().Add(-window)
+		}
+		records, err := s.ListViolations(filter)
+		if err != nil {
+			fmt.Printf("Failed to list violations: %v\n", err)
+			os.Exit(1)
+		}
+		if len(records) == 0 {
+			fmt.Println("No guard violations recorded.")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "RULE\tTOTAL\tWARN\tBLOCK\tHALT\tSESSIONS")
+		for _, row := range topRows(aggregateViolations(records, func(v *store.ViolationRecord) string { return v.Rule }), violationsTop) {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\n", row.Key, row.Total, row.Warn, row.Block, row.Halt, row.Sessions)
+		}
+		w.Flush()
+
+		subjects := aggregateViolations(records, func(v *store.ViolationRecord) string { return v.Subject })
+		if len(subjects) > 0 {
+			fmt.Println()
+			w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "COMMAND / PATH\tRULE\tTOTAL\tSESSIONS\tLAST SEEN")
+			for _, row := range topRows(subjects, violationsTop) {
+				fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n", row.Key, row.Rule, row.Total, row.Sessions, row.Last.Format("2006-01-02 15:04"))
+			}
+			w.Flush()
+		}
+
+		fmt.Println()
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SESSION\tTOTAL\tWARN\tBLOCK\tHALT\tLAST RULE")
+		for _, row := range topRows(aggregateViolations(records, func(v *store.ViolationRecord) string { return v.SessionID }), violationsTop) {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%s\n", row.Key, row.Total, row.Warn, row.Block, row.Halt, row.Rule)
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(violationsCmd)
+	violationsCmd.Flags().StringVar(&violationsSince, "since", "", "Only include violations from this window (e.g. 30d, 24h)")
+	violationsCmd.Flags().StringVar(&violationsSession, "session", "", "Only include violations of this session")
+	violationsCmd.RegisterFlagCompletionFunc("session", completeSessionFlag)
+	violationsCmd.Flags().IntVar(&violationsTop, "top", 10, "Rows per table (0 for all)")
+}
+
+// violationRow is the aggregate of the violations sharing a key.
+type violationRow struct {
+	Key               string
+	Rule              string // Rule of the most recent violation
+	Total             int
+	Warn, Block, Halt int
+	Sessions          int
+	Last              time.Time
+}
+
+// aggregateViolations groups records by key, skipping records with an empty
+// key, ordered by count descending.
+func aggregateViolations(records []*store.ViolationRecord, key func(*store.ViolationRecord) string) []violationRow {
+	byKey := make(map[string]*violationRow)
+	sessions := make(map[string]map[string]bool)
+	for _, v := range records {
+		k := key(v)
+		if k == "" {
+			continue
+		}
+		row, ok := byKey[k]
+		if !ok {
+			row = &violationRow{Key: k}
+			byKey[k] = row
+			sessions[k] = make(map[string]bool)
+		}
+		row.Total++
+		switch v.Severity {
+		case "warn":
+			row.Warn++
+		case "block":
+			row.Block++
+		default:
+			row.Halt++
+		}
+		if !v.CreatedAt.Before(row.Last) {
+			row.Last, row.Rule = v.CreatedAt, v.Rule
+		}
+		sessions[k][v.SessionID] = true
+	}
+
+	rows := make([]violationRow, 0, len(byKey))
+	for k, row := range byKey {
+		row.Sessions = len(sessions[k])
+		rows = append(rows, *row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Total != rows[j].Total {
+			return rows[i].Total > rows[j].Total
+		}
+		return rows[i].Key < rows[j].Key
+	})
+	return rows
+}
+
+// topRows keeps the first n rows; n <= 0 keeps all.
+func topRows(rows []violationRow, n int) []violationRow {
+	if n > 0 && len(rows) > n {
+		return rows[:n]
+	}
+	return rows
+}
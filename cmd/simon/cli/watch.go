@@ -0,0 +1,342 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/felixgeelhaar/bolt/v3"
+	"github.com/felixgeelhaar/simon/internal/coach"
+	"github.com/felixgeelhaar/simon/internal/guard"
+	"github.com/felixgeelhaar/simon/internal/mcp"
+	"github.com/felixgeelhaar/simon/internal/observe"
+	"github.com/felixgeelhaar/simon/internal/provider"
+	"github.com/felixgeelhaar/simon/internal/setup"
+	"github.com/felixgeelhaar/simon/internal/store"
+	"github.com/spf13/cobra"
+)
+
+// metadataWatch marks the record a `simon watch` run stores its
+// verifications under. It is not an agent session, so usage and reports skip it.
+const metadataWatch = "watch"
+
+var (
+	watchProvider    string
+	watchModel       string
+	watchInterval    time.Duration
+	watchVerifyOnly  bool
+	watchMaxSessions int
+	watchVars        []string
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch <spec-file>",
+	Short: "Keep a spec's definition of done satisfied as the workspace changes",
+	Long: `Verify a spec's evidence files and verify commands, then again whenever a
+file in the working directory changes. While everything passes nothing else
+happens; when a check fails, an agent session is started on the spec to
+restore it. Checks that passed before and now fail are reported as regressions.
+
+A session is started at most once per change: if the checks still fail after
+it, simon waits for the next change. A session that didn't complete is
+continued by the next one, as with simon run. Verification outputs are
+stored under a watch-<timestamp> record (simon artifact list), and sessions
+are tagged watch=<that id>.
+
+Examples:
+  simon watch task.yaml
+  simon watch task.yaml --verify-only --interval 5s
+  simon watch task.yaml --provider openai --max-sessions 3`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeSpecFile,
+	Run: func(cmd *cobra.Command, args []string) {
+		obs := observe.New(os.Stdout, verbose)
+		defer obs.Close()
+		s, err := openStore()
+		if err != nil {
+			fmt.Printf("Failed to init store: %v\n", err)
+			os.Exit(1)
+		}
+		defer s.Close()
+		policy, err := loadPolicy()
+		if err != nil {
+			fmt.Printf("Failed to load policy: %v\n", err)
+			os.Exit(1)
+		}
+
+		vars, err := coach.ParseVars(watchVars)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		var p provider.Provider
+		if !watchVerifyOnly {
+			// The flags choose the provider and model, then the spec, then
+			// the profile defaults
+			var flagProvider, flagModel string
+			if cmd.Flags().Changed("provider") {
+				flagProvider = watchProvider
+			}
+			if cmd.Flags().Changed("model") {
+				flagModel = watchModel
+			}
+			spec, err := coach.New().LoadSpecWithVars(args[0], vars)
+			if err != nil {
+				spec = nil
+			}
+			name, model := setup.ResolveProvider(s, spec, flagProvider, flagModel)
+			var stop func()
+			if p, stop, err = setup.NewProvider(s, name, model, setup.ProviderOptions{Log: obs.Log()}); err != nil {
+				fmt.Printf("Failed to initialize provider: %v\n", err)
+				os.Exit(1)
+			}
+			defer stop()
+		}
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer cancel()
+		w, err := newSpecWatcher(s, guard.New(policy), obs.Log(), os.Stdout, args[0], vars)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer w.close()
+		if p != nil {
+			w.maxSessions = watchMaxSessions
+			w.launch = func(ctx context.Context, previous string) (string, error) {
+				notifier, err := loadNotifier(s)
+				if err != nil {
+					return "", err
+				}
+				runner := NewRunner(obs, s, p, w.specPath, nil)
+				runner.Policy = policy
+				runner.LogDir = logDir()
+				runner.Notifier = notifier
+				runner.Tags = map[string]string{metadataWatch: w.id}
+				runner.Vars = w.vars
+				runner.SessionID = fmt.Sprintf("session-%d", time.Now().Unix())
+				runner.Previous = previous
+				// A change made while another session edits the repository
+				// is acted on once it is done
+				runner.Workspace = &workspaceLock{Wait: true}
+				return runner.SessionID, runner.Run(ctx)
+			}
+		}
+
+		if err := w.run(ctx, watchInterval); err != nil && !errors.Is(err, errInterrupted) {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+// specWatcher re-verifies a spec when the workspace changes and starts an
+// agent session when verification fails.
+type specWatcher struct {
+	store    store.Storage
+	out      io.Writer
+	specPath string
+	vars     map[string]string
+	root     string
+	// id is the watch record the verifications are stored under.
+	id    string
+	proxy *mcp.Proxy
+	// stopPlugins stops the proxy's plugins.
+	stopPlugins func()
+
+	// launch runs an agent session on the spec, continuing from previous
+	// when set, and returns its ID. Nil only verifies.
+	launch      func(ctx context.Context, previous string) (string, error)
+	maxSessions int // 0 means unlimited
+
+	passed   map[string]bool // Checks that passed at the last verification
+	sessions int
+	retry    string // The last session started, when it didn't complete
+}
+
+// newSpecWatcher loads the spec with vars, validates it, and creates the
+// watch record. Its checks run through a proxy set up as a session's is,
+// logging to log (may be nil).
+func newSpecWatcher(s store.Storage, g *guard.Guard, log *bolt.Logger, out io.Writer, specPath string, vars map[string]string) (*specWatcher, error) {
+	c := coach.New()
+	spec, err := c.LoadSpecWithVars(specPath, vars)
+	if err != nil {
+		return nil, err
+	}
+	if res := c.Validate(*spec); !res.Valid {
+		return nil, fmt.Errorf("invalid spec: %s", strings.Join(res.Errors, ", "))
+	}
+	if len(spec.CompletionChecks()) == 0 {
+		return nil, fmt.Errorf("%s has no evidence, verify commands, or checks to watch", specPath)
+	}
+	root, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	if abs, err := filepath.Abs(specPath); err == nil {
+		specPath = abs
+	}
+
+	w := &specWatcher{
+		store:    s,
+		out:      out,
+		specPath: specPath,
+		vars:     vars,
+		root:     root,
+		id:       fmt.Sprintf("watch-%d", time.Now().UnixNano()),
+		passed:   make(map[string]bool),
+	}
+	if w.proxy, w.stopPlugins, err = setup.NewProxy(s, g, log); err != nil {
+		return nil, err
+	}
+	if err := s.CreateSession(&store.Session{
+		ID:        w.id,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		Status:    "watching",
+		Metadata:  map[string]string{"spec": specPath, metadataWatch: "true"},
+	}); err != nil {
+		w.stopPlugins()
+		return nil, err
+	}
+	w.proxy.SetScope(w.id, mcp.Scope{Env: spec.Env, Evidence: spec.Evidence, Verify: spec.Verify, Checks: spec.Checks})
+	return w, nil
+}
+
+// close marks the watch record stopped and stops the proxy's plugins.
+func (w *specWatcher) close() {
+	w.stopPlugins()
+	if sess, err := w.store.GetSession(w.id); err == nil {
+		sess.Status = "stopped"
+		sess.UpdatedAt = time.Now()
+		w.store.UpdateSession(sess)
+	}
+}
+
+// run verifies once, then polls the workspace every interval until ctx ends.
+// A change is acted on once the workspace has been stable for one interval,
+// so a burst of saves leads to one verification.
+func (w *specWatcher) run(ctx context.Context, interval time.Duration) error {
+	fmt.Fprintf(w.out, "Watching %s for %s (Ctrl+C to stop)\n", w.root, w.specPath)
+	if err := w.step(ctx); err != nil {
+		return err
+	}
+	baseline, err := mcp.WorkspaceFingerprint(w.root)
+	if err != nil {
+		return err
+	}
+	seen := baseline
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		current, err := mcp.WorkspaceFingerprint(w.root)
+		if err != nil {
+			return err
+		}
+		if current == baseline {
+			seen = current
+			continue
+		}
+		if current != seen {
+			seen = current // Still changing
+			continue
+		}
+		if err := w.step(ctx); err != nil {
+			return err
+		}
+		// Files written by verification or by the agent don't count as a change
+		if baseline, err = mcp.WorkspaceFingerprint(w.root); err != nil {
+			return err
+		}
+		seen = baseline
+	}
+}
+
+// step verifies the spec and, when that fails, starts one agent session and
+// verifies again.
+func (w *specWatcher) step(ctx context.Context) error {
+	report, err := w.verify(ctx)
+	if err != nil || report.Passed || w.launch == nil {
+		return err
+	}
+	if w.maxSessions > 0 && w.sessions >= w.maxSessions {
+		fmt.Fprintf(w.out, "Session limit of %d reached; only verifying.\n", w.maxSessions)
+		return nil
+	}
+
+	w.sessions++
+	fmt.Fprintln(w.out, "Starting an agent session to restore the definition of done...")
+	id, err := w.launch(ctx, w.retry)
+	if errors.Is(err, errInterrupted) || ctx.Err() != nil {
+		return errInterrupted
+	}
+	if sess, getErr := w.store.GetSession(id); getErr == nil && sess.Status == "completed" {
+		w.retry = ""
+		fmt.Fprintf(w.out, "Session %s completed.\n", id)
+	} else {
+		w.retry = id
+		fmt.Fprintf(w.out, "Session %s did not complete; the next one continues from it.\n", id)
+	}
+	_, err = w.verify(ctx)
+	return err
+}
+
+// verify checks the spec and prints the outcome, marking checks that passed
+// at the previous verification and fail now as regressions.
+func (w *specWatcher) verify(ctx context.Context) (*mcp.EvidenceReport, error) {
+	report, err := w.proxy.CheckEvidence(ctx, w.id, func(*guard.Violation) {})
+	if err != nil {
+		return nil, err
+	}
+
+	var failing []string
+	for _, item := range report.Items {
+		key := item.Kind + " " + item.Item
+		if item.Status == mcp.EvidencePass {
+			w.passed[key] = true
+			continue
+		}
+		desc := fmt.Sprintf("%s %q (%s", item.Kind, item.Item, item.Status)
+		if item.Kind != "verify" && item.Detail != "" {
+			desc += ": " + item.Detail
+		}
+		if w.passed[key] {
+			desc += ", regressed"
+		}
+		failing = append(failing, desc+")")
+		w.passed[key] = false
+	}
+
+	stamp := time.Now().Format("15:04:05")
+	if report.Passed {
+		fmt.Fprintf(w.out, "[%s] All %d checks pass.\n", stamp, len(report.Items))
+	} else {
+		fmt.Fprintf(w.out, "[%s] %d of %d checks fail: %s\n", stamp, len(failing), len(report.Items), strings.Join(failing, "; "))
+	}
+	return report, nil
+}
+
+func init() {
+	RootCmd.AddCommand(watchCmd)
+	watchCmd.Flags().StringVarP(&watchProvider, "provider", "p", "", "AI Provider: ollama, openai, gemini, anthropic, mistral, groq, huggingface, lmstudio, llamacpp, or plugin (default: the spec's provider, provider.default, or ollama)")
+	watchCmd.Flags().StringVarP(&watchModel, "model", "m", "", "Model name (default: the spec's model, provider.model, or the provider's default)")
+	watchCmd.Flags().DurationVar(&watchInterval, "interval", 2*time.Second, "How often the workspace is checked for changes")
+	watchCmd.Flags().BoolVar(&watchVerifyOnly, "verify-only", false, "Only report verification results; never start an agent session")
+	watchCmd.Flags().IntVar(&watchMaxSessions, "max-sessions", 0, "Stop starting agent sessions after this many (0 means unlimited)")
+	watchCmd.Flags().StringArrayVar(&watchVars, "var", nil, "Set a ${NAME} spec variable as NAME=value (repeatable)")
+	watchCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose logging")
+	watchCmd.RegisterFlagCompletionFunc("provider", cobra.FixedCompletions(provider.Names, cobra.ShellCompDirectiveNoFileComp))
+}
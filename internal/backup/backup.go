@@ -0,0 +1,314 @@
+// Package backup bundles sessions, conversation history, artifacts, memories,
+// and configuration into a single compressed tar archive that can be imported
+// into another store.
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+// FormatVersion identifies the bundle layout; Import rejects newer bundles.
+const FormatVersion = 1
+
+// ErrIntegrity is returned when an artifact does not match its recorded digest.
+var ErrIntegrity = errors.New("backup integrity check failed")
+
+const (
+	manifestEntry = "manifest.json"
+	configEntry   = "config.json"
+	sessionsEntry = "sessions.json"
+	memoriesEntry = "memories.json"
+	filesPrefix   = "files/"
+)
+
+// Manifest describes a bundle and carries a SHA-256 digest per artifact.
+type Manifest struct {
+	Version        int             `json:"version"`
+	CreatedAt      time.Time       `json:"created_at"`
+	SchemaVersion  int             `json:"schema_version"`
+	Sessions       int             `json:"sessions"`
+	Memories       int             `json:"memories"`
+	Artifacts      []ArtifactEntry `json:"artifacts"`
+	ExcludedConfig []string        `json:"excluded_config,omitempty"`
+}
+
+// ArtifactEntry is an artifact's metadata plus the digest of its content.
+type ArtifactEntry struct {
+	store.Artifact
+	SHA256 string `json:"sha256"`
+}
+
+// sessionRecord is a session with its conversation history.
+type sessionRecord struct {
+	Session  *store.Session   `json:"session"`
+	Messages []*store.Message `json:"messages,omitempty"`
+}
+
+// Options controls what Export includes.
+type Options struct {
+	// ExcludeConfig reports config entries to leave out, e.g. credentials,
+	// which are encrypted with a machine-specific key.
+	ExcludeConfig func(key, value string) bool
+}
+
+// Summary counts what Import restored.
+type Summary struct {
+	Sessions        int
+	SkippedSessions int // Already present in the target store
+	Artifacts       int
+	Memories        int
+	SkippedMemories int // Identical content already present
+	Config          int
+	SkippedConfig   int // Keys already set in the target store
+}
+
+// Export writes every session, message, artifact, memory, and config entry to w.
+func Export(s *store.SQLiteStore, w io.Writer, opts Options) (*Manifest, error) {
+	version, err := s.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{Version: FormatVersion, CreatedAt: time.Now(), SchemaVersion: version}
+
+	config, err := s.ListConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	for key, value := range config {
+		if opts.ExcludeConfig != nil && opts.ExcludeConfig(key, value) {
+			delete(config, key)
+			manifest.ExcludedConfig = append(manifest.ExcludedConfig, key)
+		}
+	}
+	sort.Strings(manifest.ExcludedConfig)
+
+	sessions, err := s.ListSessions(store.SessionFilter{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	records := make([]sessionRecord, 0, len(sessions))
+	files := make(map[string][]byte)
+	for _, sess := range sessions {
+		messages, err := s.LoadMessages(sess.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load history of %s: %w", sess.ID, err)
+		}
+		records = append(records, sessionRecord{Session: sess, Messages: messages})
+
+		artifacts, err := s.ListArtifacts(sess.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list artifacts of %s: %w", sess.ID, err)
+		}
+		for _, a := range artifacts {
+			if a.Missing {
+				// simon fsck found its content gone; there is nothing to export
+				continue
+			}
+			_, content, err := s.GetArtifact(a.ID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read artifact %s: %w", a.ID, err)
+			}
+			manifest.Artifacts = append(manifest.Artifacts, ArtifactEntry{Artifact: *a, SHA256: digest(content)})
+			files[a.ID] = content
+		}
+	}
+	manifest.Sessions = len(records)
+
+	memories, err := s.ListMemories()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list memories: %w", err)
+	}
+	manifest.Memories = len(memories)
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, entry := range []struct {
+		name  string
+		value interface{}
+	}{
+		{manifestEntry, manifest},
+		{configEntry, config},
+		{sessionsEntry, records},
+		{memoriesEntry, memories},
+	} {
+		data, err := json.MarshalIndent(entry.value, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := writeEntry(tw, entry.name, data, manifest.CreatedAt); err != nil {
+			return nil, err
+		}
+	}
+	for _, a := range manifest.Artifacts {
+		if err := writeEntry(tw, filesPrefix+a.ID, files[a.ID], a.CreatedAt); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// Import restores a bundle into s. Every artifact is verified against the
+// manifest before anything is written. Existing sessions, identical
+// memories, and config keys already set in s are left untouched.
+func Import(s *store.SQLiteStore, r io.Reader) (*Summary, error) {
+	entries, err := readEntries(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest Manifest
+	if err := decodeEntry(entries, manifestEntry, &manifest); err != nil {
+		return nil, err
+	}
+	if manifest.Version > FormatVersion {
+		return nil, fmt.Errorf("backup format %d is newer than this version of simon supports (%d)", manifest.Version, FormatVersion)
+	}
+	var config map[string]string
+	var records []sessionRecord
+	var memories []store.MemoryRecord
+	for name, dest := range map[string]interface{}{configEntry: &config, sessionsEntry: &records, memoriesEntry: &memories} {
+		if err := decodeEntry(entries, name, dest); err != nil {
+			return nil, err
+		}
+	}
+	for _, a := range manifest.Artifacts {
+		content, ok := entries[filesPrefix+a.ID]
+		if !ok {
+			return nil, fmt.Errorf("%w: artifact %s is missing", ErrIntegrity, a.ID)
+		}
+		if digest(content) != a.SHA256 {
+			return nil, fmt.Errorf("%w: artifact %s does not match its digest", ErrIntegrity, a.ID)
+		}
+	}
+
+	summary := &Summary{}
+	imported := make(map[string]bool)
+	for _, rec := range records {
+		if _, err := s.GetSession(rec.Session.ID); err == nil {
+			summary.SkippedSessions++
+			continue
+		}
+		if err := s.CreateSession(rec.Session); err != nil {
+			return summary, fmt.Errorf("failed to import session %s: %w", rec.Session.ID, err)
+		}
+		if err := s.AppendMessages(rec.Session.ID, rec.Messages); err != nil {
+			return summary, fmt.Errorf("failed to import history of %s: %w", rec.Session.ID, err)
+		}
+		imported[rec.Session.ID] = true
+		summary.Sessions++
+	}
+
+	for _, a := range manifest.Artifacts {
+		if !imported[a.SessionID] {
+			continue
+		}
+		artifact := a.Artifact
+		if err := s.SaveArtifact(&artifact, entries[filesPrefix+a.ID]); err != nil {
+			return summary, fmt.Errorf("failed to import artifact %s: %w", a.ID, err)
+		}
+		summary.Artifacts++
+	}
+
+	existing, err := s.ListMemories()
+	if err != nil {
+		return summary, err
+	}
+	known := make(map[string]bool, len(existing))
+	for _, m := range existing {
+		known[m.Content] = true
+	}
+	for _, m := range memories {
+		if known[m.Content] {
+			summary.SkippedMemories++
+			continue
+		}
+		if err := s.AddMemory(m.Content, m.Vector, m.Metadata); err != nil {
+			return summary, fmt.Errorf("failed to import memory: %w", err)
+		}
+		known[m.Content] = true
+		summary.Memories++
+	}
+
+	for key, value := range config {
+		if current, _ := s.GetConfig(key); current != "" {
+			summary.SkippedConfig++
+			continue
+		}
+		if err := s.SetConfig(key, value); err != nil {
+			return summary, fmt.Errorf("failed to import config %s: %w", key, err)
+		}
+		summary.Config++
+	}
+	return summary, nil
+}
+
+func writeEntry(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(data)), ModTime: modTime}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// readEntries loads every regular file of a bundle into memory, keyed by name.
+func readEntries(r io.Reader) (map[string][]byte, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a simon backup: %w", err)
+	}
+	defer gz.Close()
+
+	entries := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read backup: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", hdr.Name, err)
+		}
+		entries[path.Clean(hdr.Name)] = data
+	}
+}
+
+func decodeEntry(entries map[string][]byte, name string, dest interface{}) error {
+	data, ok := entries[name]
+	if !ok {
+		return fmt.Errorf("not a simon backup: %s is missing", name)
+	}
+	if err := json.Unmarshal(data, dest); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", name, err)
+	}
+	return nil
+}
+
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,152 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+func newStore(t *testing.T) *store.SQLiteStore {
+	t.Helper()
+	dir := t.TempDir()
+	s, err := store.NewSQLiteStore(filepath.Join(dir, "meta.db"), filepath.Join(dir, "artifacts"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func seed(t *testing.T, s *store.SQLiteStore) {
+	t.Helper()
+	s.CreateSession(&store.Session{ID: "sess-1", CreatedAt: time.Now(), Status: "completed",
+		Metadata: map[string]string{"spec": "task.yaml"}, Tags: map[string]string{"team": "payments"}, Cost: 0.25})
+	s.AppendMessages("sess-1", []*store.Message{{Role: "user", Content: "Goal: test"}, {Role: "assistant", Content: "Task complete."}})
+	s.SaveArtifact(&store.Artifact{ID: "art-1", SessionID: "sess-1", Path: "artifacts/sess-1/out.txt", Type: "tool_output", CreatedAt: time.Now()},
+		[]byte("hello from the tool"))
+	s.AddMemory("Built a CLI", []float32{0.1, 0.2}, map[string]string{"session_id": "sess-1"})
+	s.SetConfig("provider.default", "openai")
+	s.SetConfig("openai.api_key", "enc:secret")
+}
+
+func TestExportImport(t *testing.T) {
+	src := newStore(t)
+	seed(t, src)
+
+	var buf bytes.Buffer
+	manifest, err := Export(src, &buf, Options{ExcludeConfig: func(key, _ string) bool {
+		return strings.HasSuffix(key, "_api_key") || strings.HasSuffix(key, ".api_key")
+	}})
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if manifest.Sessions != 1 || manifest.Memories != 1 || len(manifest.Artifacts) != 1 {
+		t.Errorf("Unexpected manifest: %+v", manifest)
+	}
+	if manifest.Artifacts[0].SHA256 == "" || len(manifest.ExcludedConfig) != 1 {
+		t.Errorf("Expected a digest and the API key excluded, got %+v", manifest)
+	}
+	bundle := buf.Bytes()
+
+	dst := newStore(t)
+	summary, err := Import(dst, bytes.NewReader(bundle))
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if summary.Sessions != 1 || summary.Artifacts != 1 || summary.Memories != 1 || summary.Config != 1 {
+		t.Errorf("Unexpected summary: %+v", summary)
+	}
+
+	sess, err := dst.GetSession("sess-1")
+	if err != nil || sess.Tags["team"] != "payments" || sess.Cost != 0.25 {
+		t.Errorf("Session not restored: %+v (%v)", sess, err)
+	}
+	if messages, _ := dst.LoadMessages("sess-1"); len(messages) != 2 {
+		t.Errorf("Expected 2 messages, got %d", len(messages))
+	}
+	if _, content, err := dst.GetArtifact("art-1"); err != nil || string(content) != "hello from the tool" {
+		t.Errorf("Artifact not restored: %q (%v)", content, err)
+	}
+	if key, _ := dst.GetConfig("openai.api_key"); key != "" {
+		t.Error("Expected credentials to stay out of the backup")
+	}
+
+	again, err := Import(dst, bytes.NewReader(bundle))
+	if err != nil {
+		t.Fatalf("Second import failed: %v", err)
+	}
+	if again.Sessions != 0 || again.SkippedSessions != 1 || again.SkippedMemories != 1 || again.SkippedConfig != 1 {
+		t.Errorf("Expected a repeated import to change nothing, got %+v", again)
+	}
+}
+
+func TestImport_Integrity(t *testing.T) {
+	src := newStore(t)
+	seed(t, src)
+	var buf bytes.Buffer
+	if _, err := Export(src, &buf, Options{}); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	// Rewrite the bundle with tampered artifact content
+	entries, err := readEntries(&buf)
+	if err != nil {
+		t.Fatalf("readEntries failed: %v", err)
+	}
+	entries[filesPrefix+"art-1"] = []byte("tampered")
+	var tampered bytes.Buffer
+	gz := gzip.NewWriter(&tampered)
+	tw := tar.NewWriter(gz)
+	for name, data := range entries {
+		writeEntry(tw, name, data, time.Now())
+	}
+	tw.Close()
+	gz.Close()
+
+	dst := newStore(t)
+	if _, err := Import(dst, &tampered); !errors.Is(err, ErrIntegrity) {
+		t.Fatalf("Expected ErrIntegrity, got %v", err)
+	}
+	if _, err := dst.GetSession("sess-1"); err == nil {
+		t.Error("Expected nothing to be imported from a corrupt backup")
+	}
+
+	if _, err := Import(dst, strings.NewReader("not a backup")); err == nil {
+		t.Error("Expected error for a non-backup file")
+	}
+}
+
+func TestExport_MissingArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	src, err := store.NewSQLiteStore(filepath.Join(dir, "meta.db"), filepath.Join(dir, "artifacts"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer src.Close()
+	seed(t, src)
+	lost := &store.Artifact{ID: "art-2", SessionID: "sess-1", Path: "artifacts/sess-1/lost.txt", Type: "tool_output", CreatedAt: time.Now()}
+	src.SaveArtifact(lost, []byte("gone soon"))
+	os.Remove(filepath.Join(dir, "artifacts", "blobs", lost.Blob[:2], lost.Blob))
+
+	if _, err := Export(src, &bytes.Buffer{}, Options{}); !errors.Is(err, store.ErrArtifactMissing) {
+		t.Fatalf("Expected the missing artifact to fail the export, got %v", err)
+	}
+	if _, err := src.CheckArtifacts(store.FsckOptions{MarkMissing: true}); err != nil {
+		t.Fatalf("CheckArtifacts failed: %v", err)
+	}
+	manifest, err := Export(src, &bytes.Buffer{}, Options{})
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if len(manifest.Artifacts) != 1 || manifest.Artifacts[0].ID != "art-1" {
+		t.Errorf("Expected the marked artifact to be skipped, got %+v", manifest.Artifacts)
+	}
+}
@@ -0,0 +1,161 @@
+package coach
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/felixgeelhaar/simon/internal/provider"
+	"gopkg.in/yaml.v3"
+)
+
+// MaxQuestions caps the clarification questions asked about a spec.
+const MaxQuestions = 5
+
+// Clarification is a question the coach asked about a spec and the user's answer.
+type Clarification struct {
+	Question string
+	Answer   string
+}
+
+// ClarifyingQuestions asks the provider what it would need to know to resolve
+// the spec's validation warnings. It returns at most MaxQuestions questions.
+func (c *Coach) ClarifyingQuestions(ctx context.Context, p provider.Provider, spec TaskSpec, warnings []string) ([]string, error) {
+	prompt := fmt.Sprintf(`A task specification for an autonomous coding agent has these problems:
+- %s
+
+Specification:
+%s
+Ask the user up to %d short questions whose answers would fix these problems (a more specific goal, constraints the agent must respect). Reply with only a JSON array of question strings.`,
+		strings.Join(warnings, "\n- "), specText(spec), MaxQuestions)
+
+	resp, err := p.Chat(ctx, []provider.Message{{Role: "user", Content: prompt}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate questions: %w", err)
+	}
+
+	var questions []string
+	if raw := between(resp.Content, "[", "]"); raw == "" || json.Unmarshal([]byte(raw), &questions) != nil {
+		// Fall back to one question per line
+		questions = nil
+		for _, line := range strings.Split(resp.Content, "\n") {
+			line = strings.TrimLeft(strings.TrimSpace(line), "-*0123456789. ")
+			if strings.HasSuffix(line, "?") {
+				questions = append(questions, line)
+			}
+		}
+	}
+
+	var out []string
+	for _, q := range questions {
+		if q = strings.TrimSpace(q); q != "" && len(out) < MaxQuestions {
+			out = append(out, q)
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("provider returned no questions")
+	}
+	return out, nil
+}
+
+// Refine asks the provider to rewrite the spec's goal, definition of done,
+// and constraints using the user's answers. Other fields are kept as they
+// are, and unanswered questions are ignored.
+func (c *Coach) Refine(ctx context.Context, p provider.Provider, spec TaskSpec, answers []Clarification) (TaskSpec, error) {
+	var qa strings.Builder
+	for _, a := range answers {
+		if strings.TrimSpace(a.Answer) != "" {
+			fmt.Fprintf(&qa, "Q: %s\nA: %s\n", a.Question, strings.TrimSpace(a.Answer))
+		}
+	}
+	if qa.Len() == 0 {
+		return spec, nil
+	}
+
+	prompt := fmt.Sprintf(`Rewrite this task specification for an autonomous coding agent using the user's answers below. Keep everything the original says; make the goal specific and turn stated limits into constraints.
+
+Specification:
+%s
+Answers:
+%s
+Reply with only a JSON object with the keys "goal", "definition_of_done", and "constraints" (an array of strings).`,
+		specText(spec), qa.String())
+
+	resp, err := p.Chat(ctx, []provider.Message{{Role: "user", Content: prompt}})
+	if err != nil {
+		return spec, fmt.Errorf("failed to refine spec: %w", err)
+	}
+	var rewrite struct {
+		Goal             string   `json:"goal"`
+		DefinitionOfDone string   `json:"definition_of_done"`
+		Constraints      []string `json:"constraints"`
+	}
+	if err := json.Unmarshal([]byte(between(resp.Content, "{", "}")), &rewrite); err != nil {
+		return spec, fmt.Errorf("provider returned an unreadable spec: %w", err)
+	}
+
+	refined := spec
+	if g := strings.TrimSpace(rewrite.Goal); g != "" {
+		refined.Goal = g
+	}
+	if d := strings.TrimSpace(rewrite.DefinitionOfDone); d != "" {
+		refined.DefinitionOfDone = d
+	}
+	if len(rewrite.Constraints) > 0 {
+		refined.Constraints = nil
+		for _, con := range rewrite.Constraints {
+			if con = strings.TrimSpace(con); con != "" {
+				refined.Constraints = append(refined.Constraints, con)
+			}
+		}
+	}
+	if res := c.Validate(refined); !res.Valid {
+		return spec, fmt.Errorf("refined spec is invalid: %s", strings.Join(res.Errors, ", "))
+	}
+	return refined, nil
+}
+
+// RefinedPath returns where the refined version of a spec is written:
+// task.yaml becomes task.refined.yaml next to it.
+func RefinedPath(path string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + ".refined" + ext
+}
+
+// WriteSpec saves a spec as JSON or YAML, chosen by the path's extension.
+func WriteSpec(path string, spec TaskSpec) error {
+	var data []byte
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		data, err = json.MarshalIndent(spec, "", "  ")
+		data = append(data, '\n')
+	case ".yaml", ".yml":
+		data, err = yaml.Marshal(spec)
+		data = append([]byte("# yaml-language-server: $schema="+SchemaURL+"\n"), data...)
+	default:
+		return fmt.Errorf("unsupported spec format: %s (use .json or .yaml)", filepath.Ext(path))
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// specText renders a spec for a prompt.
+func specText(spec TaskSpec) string {
+	data, _ := yaml.Marshal(spec)
+	return string(data)
+}
+
+// between returns s from the first open to the last close, or "" if absent.
+func between(s, open, close string) string {
+	start, end := strings.Index(s, open), strings.LastIndex(s, close)
+	if start < 0 || end < start {
+		return ""
+	}
+	return s[start : end+1]
+}
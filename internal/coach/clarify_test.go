@@ -0,0 +1,113 @@
+package coach
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/felixgeelhaar/simon/internal/provider"
+)
+
+// scriptedProvider replies with canned content and records the prompts.
+type scriptedProvider struct {
+	replies []string
+	prompts []string
+}
+
+func (s *scriptedProvider) Chat(ctx context.Context, messages []provider.Message) (*provider.Response, error) {
+	s.prompts = append(s.prompts, messages[len(messages)-1].Content)
+	reply := s.replies[0]
+	s.replies = s.replies[1:]
+	return &provider.Response{Content: reply}, nil
+}
+
+func (s *scriptedProvider) Embed(ctx context.Context, text string) ([]float32, error) {
+	return nil, nil
+}
+func (s *scriptedProvider) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	return nil, nil
+}
+func (s *scriptedProvider) Name() string  { return "scripted" }
+func (s *scriptedProvider) Model() string { return "" }
+func (s *scriptedProvider) CountTokens(ctx context.Context, messages []provider.Message) (int, error) {
+	return 0, nil
+}
+
+func TestClarify(t *testing.T) {
+	c := New()
+	spec := TaskSpec{Goal: "Fix it", DefinitionOfDone: "Tests pass", Verify: []string{"go test ./..."}, Env: map[string]string{"CGO_ENABLED": "0"}}
+	warnings := c.Validate(spec).Warnings
+
+	t.Run("Questions", func(t *testing.T) {
+		p := &scriptedProvider{replies: []string{"Sure:\n```json\n[\"Which bug?\", \"\", \"Which files may change?\"]\n```"}}
+		questions, err := c.ClarifyingQuestions(context.Background(), p, spec, warnings)
+		if err != nil {
+			t.Fatalf("ClarifyingQuestions failed: %v", err)
+		}
+		if strings.Join(questions, "|") != "Which bug?|Which files may change?" {
+			t.Errorf("Unexpected questions %q", questions)
+		}
+		if !strings.Contains(p.prompts[0], "Goal is very short") || !strings.Contains(p.prompts[0], "goal: Fix it") {
+			t.Errorf("Expected the warnings and spec in the prompt, got:\n%s", p.prompts[0])
+		}
+
+		p = &scriptedProvider{replies: []string{"1. Which bug?\n2. Any limits?\nThanks"}}
+		if questions, _ := c.ClarifyingQuestions(context.Background(), p, spec, warnings); len(questions) != 2 {
+			t.Errorf("Expected questions parsed from lines, got %q", questions)
+		}
+		p = &scriptedProvider{replies: []string{"Looks fine."}}
+		if _, err := c.ClarifyingQuestions(context.Background(), p, spec, warnings); err == nil {
+			t.Error("Expected an error without questions")
+		}
+	})
+
+	t.Run("Refine", func(t *testing.T) {
+		p := &scriptedProvider{replies: []string{`{"goal": "Fix the nil pointer panic in the parser", "definition_of_done": "", "constraints": ["Only touch parser.go", " "]}`}}
+		answers := []Clarification{{Question: "Which bug?", Answer: "the parser panic"}, {Question: "Limits?", Answer: ""}}
+		refined, err := c.Refine(context.Background(), p, spec, answers)
+		if err != nil {
+			t.Fatalf("Refine failed: %v", err)
+		}
+		if refined.Goal != "Fix the nil pointer panic in the parser" || refined.DefinitionOfDone != "Tests pass" ||
+			strings.Join(refined.Constraints, "|") != "Only touch parser.go" || refined.Env["CGO_ENABLED"] != "0" {
+			t.Errorf("Unexpected refined spec %+v", refined)
+		}
+		if strings.Contains(p.prompts[0], "Limits?") {
+			t.Error("Expected unanswered questions to be left out of the prompt")
+		}
+
+		// Nothing answered: no provider call
+		if same, err := c.Refine(context.Background(), &scriptedProvider{}, spec, answers[1:]); err != nil || same.Goal != spec.Goal {
+			t.Errorf("Expected the spec unchanged, got %+v, %v", same, err)
+		}
+		if _, err := c.Refine(context.Background(), &scriptedProvider{replies: []string{"no"}}, spec, answers); err == nil {
+			t.Error("Expected an error for an unreadable reply")
+		}
+	})
+
+	t.Run("Write", func(t *testing.T) {
+		dir := t.TempDir()
+		path := RefinedPath(filepath.Join(dir, "task.yaml"))
+		if filepath.Base(path) != "task.refined.yaml" {
+			t.Errorf("Unexpected refined path %s", path)
+		}
+		if err := WriteSpec(path, spec); err != nil {
+			t.Fatalf("WriteSpec failed: %v", err)
+		}
+		if errs, err := ValidateSchemaFile(path); err != nil || len(errs) != 0 {
+			t.Errorf("Expected the written spec to match the schema, got %v, %v", errs, err)
+		}
+		loaded, err := c.LoadSpec(path)
+		if err != nil || loaded.Goal != spec.Goal || loaded.Verify[0] != "go test ./..." {
+			t.Errorf("Expected the spec to round-trip, got %+v, %v", loaded, err)
+		}
+		if err := WriteSpec(filepath.Join(dir, "task.txt"), spec); err == nil {
+			t.Error("Expected an unsupported format error")
+		}
+		if _, err := os.Stat(filepath.Join(dir, "task.txt")); err == nil {
+			t.Error("Expected nothing written for an unsupported format")
+		}
+	})
+}
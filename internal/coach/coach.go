@@ -6,8 +6,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 
+	"github.com/felixgeelhaar/simon/internal/provider"
 	"gopkg.in/yaml.v3"
 )
 
@@ -16,9 +20,238 @@ type TaskSpec struct {
 	Goal             string   `json:"goal" yaml:"goal"`
 	DefinitionOfDone string   `json:"definition_of_done" yaml:"definition_of_done"`
 	Constraints      []string `json:"constraints" yaml:"constraints"`
-	Evidence         []string `json:"evidence" yaml:"evidence"` // Paths or commands to verify completion
+	Evidence         []string `json:"evidence" yaml:"evidence"` // Paths that must exist on completion
+	// Verify lists commands the verifier runs itself to confirm completion (e.g. "go test ./...").
+	Verify []string `json:"verify,omitempty" yaml:"verify,omitempty"`
+	// Checks are further completion checks, each run by the verifier named
+	// by its type (e.g. an http check of a staging health endpoint).
+	Checks []Check `json:"checks,omitempty" yaml:"checks,omitempty"`
+
+	// Env is passed to tool execution on top of the sandboxed base environment.
+	Env map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+	// AllowedCommands narrows the global policy for this task; empty means no extra restriction.
+	AllowedCommands []string `json:"allowed_commands,omitempty" yaml:"allowed_commands,omitempty"`
+	// DeniedFileGlobs are files no tool may read or write during this task
+	// (e.g. "**/.env"), whatever the policy's allowed_file_globs permit.
+	DeniedFileGlobs []string `json:"denied_file_globs,omitempty" yaml:"denied_file_globs,omitempty"`
+
+	// ReminderInterval re-injects the constraints every N iterations; 0 uses
+	// DefaultReminderInterval and a negative value disables periodic reminders.
+	ReminderInterval int `json:"reminder_interval,omitempty" yaml:"reminder_interval,omitempty"`
+
+	// MemoryNamespace scopes memory retrieval and archiving; empty uses the
+	// project directory (the git root above the working directory, if any).
+	MemoryNamespace string `json:"memory_namespace,omitempty" yaml:"memory_namespace,omitempty"`
+
+	// Provider and Model select what the session runs on (e.g. a
+	// long-context model for a large refactor), overriding the profile's
+	// provider.default and provider.model; command-line flags still win.
+	// Sub-tasks and mission steps run on the same provider.
+	Provider string `json:"provider,omitempty" yaml:"provider,omitempty"`
+	Model    string `json:"model,omitempty" yaml:"model,omitempty"`
+	// Escalate switches a stuck session to a stronger provider or model
+	// without restarting it.
+	Escalate Escalation `json:"escalate,omitempty" yaml:"escalate,omitempty"`
+	// Params set the sampling parameters (temperature, top_p, max_tokens,
+	// stop) of the session's requests, per phase, over the policy's params.
+	Params provider.PhaseParams `json:"params,omitempty" yaml:"params,omitempty"`
+
+	// Vars are defaults for ${NAME} placeholders in the other string
+	// fields; see Resolve.
+	Vars map[string]string `json:"vars,omitempty" yaml:"vars,omitempty"`
+
+	// Hooks are commands run at points of the session's lifecycle.
+	Hooks Hooks `json:"hooks,omitempty" yaml:"hooks,omitempty"`
+
+	// Steps splits the goal into sub-goals run one after another, each as a
+	// sub-task with its own budget. Evidence and Verify then check the
+	// mission as a whole once every step is done, and may be empty.
+	Steps []Step `json:"steps,omitempty" yaml:"steps,omitempty"`
+
+	// The values Resolve took from the environment (see Secrets)
+	envValues []string
+}
+
+// Step is one sub-goal of a multi-step mission.
+type Step struct {
+	Goal string `json:"goal" yaml:"goal"`
+	// DefinitionOfDone defaults to the step's goal.
+	DefinitionOfDone string   `json:"definition_of_done,omitempty" yaml:"definition_of_done,omitempty"`
+	Evidence         []string `json:"evidence,omitempty" yaml:"evidence,omitempty"`
+	Verify           []string `json:"verify,omitempty" yaml:"verify,omitempty"`
+	Checks           []Check  `json:"checks,omitempty" yaml:"checks,omitempty"`
+	// MaxIterations is the step's iteration budget, capped by the policy;
+	// 0 uses the sub-task default.
+	MaxIterations int `json:"max_iterations,omitempty" yaml:"max_iterations,omitempty"`
+}
+
+// StepSpec returns the spec step i runs with: the step's goal, definition
+// of done, and checks under the mission's constraints, environment, and
+// post_iteration hooks.
+func (s TaskSpec) StepSpec(i int) TaskSpec {
+	step := s.Steps[i]
+	spec := TaskSpec{
+		Goal:             step.Goal,
+		DefinitionOfDone: step.DefinitionOfDone,
+		Constraints: append([]string{fmt.Sprintf("This is step %d of %d of the mission %q; do only this step, the others run separately.",
+			i+1, len(s.Steps), s.Goal)}, s.Constraints...),
+		Evidence:         step.Evidence,
+		Verify:           step.Verify,
+		Checks:           step.Checks,
+		Env:              s.Env,
+		AllowedCommands:  s.AllowedCommands,
+		DeniedFileGlobs:  s.DeniedFileGlobs,
+		ReminderInterval: s.ReminderInterval,
+		MemoryNamespace:  s.MemoryNamespace,
+		Params:           s.Params,
+		Hooks:            Hooks{PostIteration: s.Hooks.PostIteration},
+	}
+	if spec.DefinitionOfDone == "" {
+		spec.DefinitionOfDone = step.Goal
+	}
+	return spec
+}
+
+// Check types of the built-in verifiers. Any other type names a verifier
+// plugin (see the verify.plugins config key).
+const (
+	CheckFile    = "file"
+	CheckCommand = "command"
+	CheckContent = "content"
+	CheckHTTP    = "http"
+	// CheckCoverage runs `go test -cover` on the target packages.
+	CheckCoverage = "coverage"
+)
+
+// Check is a completion check run by the verifier registered for its type.
+type Check struct {
+	Type string `json:"type" yaml:"type"`
+	// Target is what the check inspects: a path, a command, a URL, or the
+	// package patterns of a coverage check.
+	Target string `json:"target" yaml:"target"`
+	// Expect is the regular expression a content check's file must match,
+	// the status code an http check's URL must answer with (default any 2xx),
+	// or the minimum statement coverage in percent of every package a
+	// coverage check tests.
+	Expect string `json:"expect,omitempty" yaml:"expect,omitempty"`
+	// Params are passed to plugin verifiers as they are.
+	Params map[string]string `json:"params,omitempty" yaml:"params,omitempty"`
+}
+
+// CompletionChecks lists evidence paths as file checks and verify commands
+// as command checks, followed by checks.
+func CompletionChecks(evidence, verify []string, checks []Check) []Check {
+	all := make([]Check, 0, len(evidence)+len(verify)+len(checks))
+	for _, e := range evidence {
+		all = append(all, Check{Type: CheckFile, Target: e})
+	}
+	for _, command := range verify {
+		all = append(all, Check{Type: CheckCommand, Target: command})
+	}
+	return append(all, checks...)
+}
+
+// CompletionChecks lists every check verification runs for the spec.
+func (s TaskSpec) CompletionChecks() []Check {
+	return CompletionChecks(s.Evidence, s.Verify, s.Checks)
+}
+
+// Hooks lists commands run at lifecycle points of a session, under the same
+// guard checks and scope as the agent's tool calls. Failures are fed back to
+// the agent.
+type Hooks struct {
+	// PreRun runs before the first iteration, e.g. to install dependencies.
+	PreRun []string `json:"pre_run,omitempty" yaml:"pre_run,omitempty"`
+	// PostIteration runs after every iteration in which the agent called tools (e.g. "go vet ./...").
+	PostIteration []string `json:"post_iteration,omitempty" yaml:"post_iteration,omitempty"`
+	// OnComplete runs once verification passes; a failure keeps the session going.
+	OnComplete []string `json:"on_complete,omitempty" yaml:"on_complete,omitempty"`
 }
 
+// Escalation names the provider and model a session switches to once it
+// is stuck: after After consecutive failed verifications, or at once when
+// a provider call fails for good. It happens at most once per session.
+type Escalation struct {
+	// Provider defaults to the session's current provider.
+	Provider string `json:"provider,omitempty" yaml:"provider,omitempty"`
+	// Model defaults to the provider's default model.
+	Model string `json:"model,omitempty" yaml:"model,omitempty"`
+	// After is the number of consecutive failed verifications that trigger
+	// the switch; 0 uses DefaultEscalateAfter.
+	After int `json:"after,omitempty" yaml:"after,omitempty"`
+}
+
+// DefaultEscalateAfter is how many failed verifications in a row escalate
+// a session when the spec doesn't say.
+const DefaultEscalateAfter = 2
+
+// Enabled reports whether the spec asks for escalation at all.
+func (e Escalation) Enabled() bool {
+	return e.Provider != "" || e.Model != ""
+}
+
+// Threshold returns the effective number of failed verifications.
+func (e Escalation) Threshold() int {
+	if e.After > 0 {
+		return e.After
+	}
+	return DefaultEscalateAfter
+}
+
+// Hook points, as named in the spec.
+const (
+	HookPreRun        = "pre_run"
+	HookPostIteration = "post_iteration"
+	HookOnComplete    = "on_complete"
+)
+
+// HookCommands are the commands declared for one hook point.
+type HookCommands struct {
+	Point    string
+	Commands []string
+}
+
+// Points lists the hook points in lifecycle order.
+func (h Hooks) Points() []HookCommands {
+	return []HookCommands{
+		{HookPreRun, h.PreRun},
+		{HookPostIteration, h.PostIteration},
+		{HookOnComplete, h.OnComplete},
+	}
+}
+
+// DefaultReminderInterval is how often constraints are restated when the spec doesn't say.
+const DefaultReminderInterval = 5
+
+// ReminderEvery returns the effective reminder interval; 0 means never.
+func (s TaskSpec) ReminderEvery() int {
+	switch {
+	case s.ReminderInterval < 0:
+		return 0
+	case s.ReminderInterval == 0:
+		return DefaultReminderInterval
+	}
+	return s.ReminderInterval
+}
+
+// ConstraintReminder restates the spec's constraints for re-injection into a
+// long conversation. It is empty when the spec has no constraints.
+func ConstraintReminder(spec TaskSpec) string {
+	if len(spec.Constraints) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("[System reminder] These constraints still apply and take precedence over earlier progress notes:\n")
+	for _, c := range spec.Constraints {
+		b.WriteString("- " + c + "\n")
+	}
+	b.WriteString("Goal: " + spec.Goal)
+	return b.String()
+}
+
+// envNamePattern matches portable environment variable names.
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 // ValidationResult represents the outcome of a linting pass.
 type ValidationResult struct {
 	Valid    bool
@@ -27,13 +260,16 @@ type ValidationResult struct {
 }
 
 // Coach provides the logic to validate and refine task specifications.
-type Coach struct{}
+type Coach struct {
+	defaults SpecDefaults // Added to loaded specs; see SetDefaults
+}
 
 func New() *Coach {
 	return &Coach{}
 }
 
-// LoadSpec reads a task specification from a file (JSON or YAML).
+// LoadSpec reads a task specification from a file (JSON or YAML) and adds
+// the Coach's defaults to it.
 func (c *Coach) LoadSpec(path string) (*TaskSpec, error) {
 	data, err := os.ReadFile(path) // #nosec G304
 	if err != nil {
@@ -56,6 +292,7 @@ func (c *Coach) LoadSpec(path string) (*TaskSpec, error) {
 		return nil, fmt.Errorf("unsupported spec format: %s (use .json or .yaml)", ext)
 	}
 
+	c.defaults.apply(&spec)
 	return &spec, nil
 }
 
@@ -83,14 +320,150 @@ func (c *Coach) Validate(spec TaskSpec) ValidationResult {
 		res.Warnings = append(res.Warnings, "No constraints specified. Are there really no limits?")
 	}
 
-	if len(spec.Evidence) == 0 {
+	if len(spec.Evidence) == 0 && len(spec.Verify) == 0 && len(spec.Checks) == 0 && len(spec.Steps) == 0 {
 		res.Valid = false
 		res.Errors = append(res.Errors, "Evidence (verification steps) is required")
 	}
 
+	for _, cmd := range spec.Verify {
+		if strings.TrimSpace(cmd) == "" {
+			res.Valid = false
+			res.Errors = append(res.Errors, "Verify commands must not contain empty entries")
+			break
+		}
+	}
+
+	for i, check := range spec.Checks {
+		if err := validateCheck(check); err != nil {
+			res.Valid = false
+			res.Errors = append(res.Errors, fmt.Sprintf("Check %d: %v", i+1, err))
+		}
+	}
+
+	for i, step := range spec.Steps {
+		if strings.TrimSpace(step.Goal) == "" {
+			res.Valid = false
+			res.Errors = append(res.Errors, fmt.Sprintf("Step %d: goal is required", i+1))
+		}
+		if len(step.Evidence) == 0 && len(step.Verify) == 0 && len(step.Checks) == 0 {
+			res.Valid = false
+			res.Errors = append(res.Errors, fmt.Sprintf("Step %d: evidence, verify commands, or checks are required", i+1))
+		}
+		for _, cmd := range step.Verify {
+			if strings.TrimSpace(cmd) == "" {
+				res.Valid = false
+				res.Errors = append(res.Errors, fmt.Sprintf("Step %d: verify commands must not contain empty entries", i+1))
+				break
+			}
+		}
+		for j, check := range step.Checks {
+			if err := validateCheck(check); err != nil {
+				res.Valid = false
+				res.Errors = append(res.Errors, fmt.Sprintf("Step %d: check %d: %v", i+1, j+1, err))
+			}
+		}
+		if step.MaxIterations < 0 {
+			res.Valid = false
+			res.Errors = append(res.Errors, fmt.Sprintf("Step %d: max_iterations must not be negative", i+1))
+		}
+	}
+
+	if spec.Provider != "" && !slices.Contains(provider.Names, spec.Provider) {
+		res.Valid = false
+		res.Errors = append(res.Errors, fmt.Sprintf("Unknown provider %q (use one of %s)", spec.Provider, strings.Join(provider.Names, ", ")))
+	}
+	if spec.Model != "" && strings.TrimSpace(spec.Model) == "" {
+		res.Valid = false
+		res.Errors = append(res.Errors, "Model must not be blank")
+	}
+
+	if p := spec.Escalate.Provider; p != "" && !slices.Contains(provider.Names, p) {
+		res.Valid = false
+		res.Errors = append(res.Errors, fmt.Sprintf("Escalate: unknown provider %q (use one of %s)", p, strings.Join(provider.Names, ", ")))
+	}
+	if spec.Escalate.Model != "" && strings.TrimSpace(spec.Escalate.Model) == "" {
+		res.Valid = false
+		res.Errors = append(res.Errors, "Escalate: model must not be blank")
+	}
+	if spec.Escalate.After < 0 {
+		res.Valid = false
+		res.Errors = append(res.Errors, "Escalate: after must not be negative")
+	}
+	if spec.Escalate.After > 0 && !spec.Escalate.Enabled() {
+		res.Valid = false
+		res.Errors = append(res.Errors, "Escalate: a provider or model is required")
+	}
+
+	if err := spec.Params.Validate(); err != nil {
+		res.Valid = false
+		res.Errors = append(res.Errors, fmt.Sprintf("Params: %v", err))
+	}
+
+	for name := range spec.Env {
+		if !envNamePattern.MatchString(name) {
+			res.Valid = false
+			res.Errors = append(res.Errors, fmt.Sprintf("Invalid environment variable name: %q", name))
+		}
+	}
+
+	for _, cmd := range spec.AllowedCommands {
+		if strings.TrimSpace(cmd) == "" {
+			res.Valid = false
+			res.Errors = append(res.Errors, "Allowed commands must not contain empty entries")
+			break
+		}
+	}
+
+	for _, glob := range spec.DeniedFileGlobs {
+		if strings.TrimSpace(glob) == "" {
+			res.Valid = false
+			res.Errors = append(res.Errors, "Denied file globs must not contain empty entries")
+			break
+		}
+	}
+
+	for _, hook := range spec.Hooks.Points() {
+		for _, cmd := range hook.Commands {
+			if strings.TrimSpace(cmd) == "" {
+				res.Valid = false
+				res.Errors = append(res.Errors, fmt.Sprintf("Hooks %s must not contain empty entries", hook.Point))
+				break
+			}
+		}
+	}
+
 	return res
 }
 
+// validateCheck rejects checks without a type or target and expectations
+// their verifier cannot use.
+func validateCheck(check Check) error {
+	if strings.TrimSpace(check.Type) == "" {
+		return errors.New("type is required")
+	}
+	if strings.TrimSpace(check.Target) == "" {
+		return errors.New("target is required")
+	}
+	switch check.Type {
+	case CheckContent:
+		if _, err := regexp.Compile(check.Expect); err != nil {
+			return fmt.Errorf("invalid expect pattern: %w", err)
+		}
+	case CheckHTTP:
+		if check.Expect == "" {
+			return nil
+		}
+		if code, err := strconv.Atoi(check.Expect); err != nil || code < 100 || code > 599 {
+			return fmt.Errorf("expect must be an HTTP status code, got %q", check.Expect)
+		}
+	case CheckCoverage:
+		if min, err := strconv.ParseFloat(check.Expect, 64); err != nil || min < 0 || min > 100 {
+			return fmt.Errorf("expect must be the minimum coverage in percent, got %q", check.Expect)
+		}
+	}
+	return nil
+}
+
 // LintPrompt provides a simple check for raw text prompts (future use).
 func (c *Coach) LintPrompt(prompt string) error {
 	if prompt == "" {
@@ -3,7 +3,10 @@ package coach
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/felixgeelhaar/simon/internal/provider"
 )
 
 func TestCoach_LoadSpec(t *testing.T) {
@@ -44,6 +47,31 @@ func TestCoach_LoadSpec(t *testing.T) {
 			t.Error("Expected error for .txt extension")
 		}
 	})
+
+	t.Run("Defaults", func(t *testing.T) {
+		d := New()
+		d.SetDefaults(SpecDefaults{
+			Constraints:     []string{"c1", "keep the public API"},
+			Evidence:        []string{"CHANGELOG.md"},
+			Verify:          []string{"make test"},
+			Checks:          []Check{{Type: CheckCommand, Target: "make lint"}},
+			Env:             map[string]string{"GOFLAGS": "-mod=mod"},
+			MemoryNamespace: "team",
+		})
+		spec, err := d.LoadSpec(yamlPath)
+		if err != nil {
+			t.Fatalf("Failed to load YAML: %v", err)
+		}
+		if strings.Join(spec.Constraints, ",") != "c1,keep the public API" || strings.Join(spec.Evidence, ",") != "e1,CHANGELOG.md" {
+			t.Errorf("Expected the defaults after the spec's own, got %v and %v", spec.Constraints, spec.Evidence)
+		}
+		if len(spec.Verify) != 1 || len(spec.Checks) != 1 || spec.Env["GOFLAGS"] != "-mod=mod" || spec.MemoryNamespace != "team" {
+			t.Errorf("Expected the defaults to apply, got %+v", spec)
+		}
+		if spec, _ := c.LoadSpec(yamlPath); len(spec.Verify) != 0 {
+			t.Errorf("Expected no defaults without SetDefaults, got %v", spec.Verify)
+		}
+	})
 }
 
 func TestCoach_Validate(t *testing.T) {
@@ -75,6 +103,143 @@ func TestCoach_Validate(t *testing.T) {
 		}
 	})
 
+	t.Run("Env and Commands", func(t *testing.T) {
+		spec := TaskSpec{
+			Goal:             "Execute a complex refactor",
+			DefinitionOfDone: "Code compiles",
+			Evidence:         []string{"tests"},
+			Env:              map[string]string{"GOFLAGS": "-mod=mod", "BAD-NAME": "x"},
+			AllowedCommands:  []string{"go", " "},
+		}
+		res := c.Validate(spec)
+		if res.Valid {
+			t.Error("Expected invalid spec")
+		}
+		if len(res.Errors) != 2 {
+			t.Errorf("Expected 2 errors (env name, empty command), got %v", res.Errors)
+		}
+	})
+
+	t.Run("Verify Commands", func(t *testing.T) {
+		spec := TaskSpec{Goal: "Fix failing tests", DefinitionOfDone: "Tests pass", Verify: []string{"go test ./..."}}
+		if res := c.Validate(spec); !res.Valid {
+			t.Errorf("Expected verify commands to satisfy evidence, got %v", res.Errors)
+		}
+		spec.Verify = append(spec.Verify, " ")
+		if res := c.Validate(spec); res.Valid {
+			t.Error("Expected empty verify command to be rejected")
+		}
+	})
+
+	t.Run("Checks", func(t *testing.T) {
+		spec := TaskSpec{Goal: "Deploy the API to staging", DefinitionOfDone: "Staging is healthy", Checks: []Check{
+			{Type: CheckHTTP, Target: "https://staging.example.com/healthz", Expect: "200"},
+			{Type: "staging-health", Target: "api", Params: map[string]string{"namespace": "staging"}},
+		}}
+		if res := c.Validate(spec); !res.Valid {
+			t.Errorf("Expected checks to satisfy evidence, got %v", res.Errors)
+		}
+		spec.Checks = append(spec.Checks,
+			Check{Type: CheckContent, Target: "CHANGELOG.md", Expect: "v1.("},
+			Check{Type: CheckHTTP, Target: "https://staging.example.com", Expect: "ok"},
+			Check{Type: CheckFile})
+		res := c.Validate(spec)
+		if res.Valid || len(res.Errors) != 3 || !strings.HasPrefix(res.Errors[0], "Check 3:") {
+			t.Errorf("Expected errors for checks 3 to 5, got %v", res.Errors)
+		}
+
+		coverage := TaskSpec{Goal: "Test the storage layer", DefinitionOfDone: "Covered", Checks: []Check{{Type: CheckCoverage, Target: "./...", Expect: "80"}}}
+		if res := c.Validate(coverage); !res.Valid {
+			t.Errorf("Expected a coverage check to be accepted, got %v", res.Errors)
+		}
+		coverage.Checks[0].Expect = "120"
+		if res := c.Validate(coverage); res.Valid || !strings.Contains(res.Errors[0], "minimum coverage") {
+			t.Errorf("Expected a coverage above 100%% to be rejected, got %v", res.Errors)
+		}
+
+		all := TaskSpec{Evidence: []string{"main.go"}, Verify: []string{"go test ./..."}, Checks: spec.Checks[:1]}.CompletionChecks()
+		if len(all) != 3 || all[0].Type != CheckFile || all[1].Type != CheckCommand || all[2].Type != CheckHTTP {
+			t.Errorf("Expected file, command, then http checks, got %+v", all)
+		}
+	})
+
+	t.Run("Hooks", func(t *testing.T) {
+		spec := TaskSpec{Goal: "Fix failing tests", DefinitionOfDone: "Tests pass", Verify: []string{"go test ./..."},
+			Hooks: Hooks{PostIteration: []string{"go vet ./..."}, OnComplete: []string{""}}}
+		res := c.Validate(spec)
+		if res.Valid || len(res.Errors) != 1 || !strings.Contains(res.Errors[0], "on_complete") {
+			t.Errorf("Expected the empty on_complete hook to be rejected, got %v", res.Errors)
+		}
+	})
+
+	t.Run("Provider", func(t *testing.T) {
+		spec := TaskSpec{Goal: "Refactor the storage layer", DefinitionOfDone: "Tests pass", Verify: []string{"go test ./..."},
+			Provider: "anthropic", Model: "claude-sonnet-4-5"}
+		if res := c.Validate(spec); !res.Valid {
+			t.Errorf("Expected a known provider to be accepted, got %v", res.Errors)
+		}
+		spec.Provider, spec.Model = "openia", " "
+		res := c.Validate(spec)
+		if res.Valid || len(res.Errors) != 2 || !strings.Contains(res.Errors[0], `Unknown provider "openia"`) {
+			t.Errorf("Expected the unknown provider and blank model to be rejected, got %v", res.Errors)
+		}
+	})
+
+	t.Run("Escalate", func(t *testing.T) {
+		spec := TaskSpec{Goal: "Refactor the storage layer", DefinitionOfDone: "Tests pass", Verify: []string{"go test ./..."},
+			Escalate: Escalation{Model: "gpt-4o"}}
+		if res := c.Validate(spec); !res.Valid || spec.Escalate.Threshold() != DefaultEscalateAfter {
+			t.Errorf("Expected a model alone to escalate after %d failures, got %v", DefaultEscalateAfter, res.Errors)
+		}
+		spec.Escalate = Escalation{Provider: "openia", After: 3}
+		if res := c.Validate(spec); res.Valid || len(res.Errors) != 1 || !strings.Contains(res.Errors[0], `unknown provider "openia"`) {
+			t.Errorf("Expected the unknown provider to be rejected, got %v", res.Errors)
+		}
+		spec.Escalate = Escalation{After: 3}
+		if res := c.Validate(spec); res.Valid || len(res.Errors) != 1 || !strings.Contains(res.Errors[0], "provider or model is required") {
+			t.Errorf("Expected escalate without a target to be rejected, got %v", res.Errors)
+		}
+	})
+
+	t.Run("Params", func(t *testing.T) {
+		cold, hot := 0.0, 3.0
+		spec := TaskSpec{Goal: "Refactor the storage layer", DefinitionOfDone: "Tests pass", Verify: []string{"go test ./..."},
+			Params: provider.PhaseParams{Default: provider.Params{Temperature: &cold}}}
+		if res := c.Validate(spec); !res.Valid {
+			t.Errorf("Expected a zero temperature to be valid, got %v", res.Errors)
+		}
+		spec.Params.Execution.Temperature = &hot
+		if res := c.Validate(spec); res.Valid || len(res.Errors) != 1 || !strings.Contains(res.Errors[0], "execution: temperature") {
+			t.Errorf("Expected the execution temperature to be rejected, got %v", res.Errors)
+		}
+		if step := (TaskSpec{Params: spec.Params, Steps: []Step{{Goal: "a"}}}).StepSpec(0); step.Params.Execution.Temperature != &hot {
+			t.Error("Expected steps to sample like their mission")
+		}
+	})
+
+	t.Run("Steps", func(t *testing.T) {
+		spec := TaskSpec{Goal: "Ship the release", DefinitionOfDone: "Tagged", Steps: []Step{
+			{Goal: "Write the changelog", Evidence: []string{"CHANGELOG.md"}},
+			{Goal: "Bump the version", Verify: []string{"make check-version"}, MaxIterations: 5},
+		}}
+		if res := c.Validate(spec); !res.Valid {
+			t.Errorf("Expected steps to stand in for evidence, got %v", res.Errors)
+		}
+		spec.Steps = append(spec.Steps, Step{Goal: " ", MaxIterations: -1})
+		res := c.Validate(spec)
+		if res.Valid || len(res.Errors) != 3 || !strings.HasPrefix(res.Errors[0], "Step 3:") {
+			t.Errorf("Expected 3 errors for step 3, got %v", res.Errors)
+		}
+
+		step := spec.StepSpec(1)
+		if step.Goal != "Bump the version" || step.DefinitionOfDone != "Bump the version" || len(step.Verify) != 1 {
+			t.Errorf("Unexpected step spec: %+v", step)
+		}
+		if len(step.Constraints) == 0 || !strings.Contains(step.Constraints[0], `step 2 of 3 of the mission "Ship the release"`) {
+			t.Errorf("Expected the step's place in the mission as a constraint, got %v", step.Constraints)
+		}
+	})
+
 	t.Run("Missing Fields", func(t *testing.T) {
 		spec := TaskSpec{}
 		res := c.Validate(spec)
@@ -95,4 +260,24 @@ func TestCoach_LintPrompt(t *testing.T) {
 	if err := c.LintPrompt("Valid"); err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
-}
\ No newline at end of file
+}
+func TestConstraintReminder(t *testing.T) {
+	spec := TaskSpec{Goal: "Build the CLI", Constraints: []string{"No new dependencies", "Keep tests green"}}
+
+	reminder := ConstraintReminder(spec)
+	for _, want := range []string{"- No new dependencies", "- Keep tests green", "Goal: Build the CLI"} {
+		if !strings.Contains(reminder, want) {
+			t.Errorf("Reminder missing %q: %s", want, reminder)
+		}
+	}
+	if ConstraintReminder(TaskSpec{Goal: "x"}) != "" {
+		t.Error("Expected no reminder without constraints")
+	}
+
+	cases := map[int]int{0: DefaultReminderInterval, 3: 3, -1: 0}
+	for in, want := range cases {
+		if got := (TaskSpec{ReminderInterval: in}).ReminderEvery(); got != want {
+			t.Errorf("ReminderEvery(%d) = %d, want %d", in, got, want)
+		}
+	}
+}
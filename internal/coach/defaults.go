@@ -0,0 +1,61 @@
+package coach
+
+import "slices"
+
+// SpecDefaults are conventions added to every spec a Coach loads, such as a
+// project's verify commands and the evidence its tasks must leave behind.
+type SpecDefaults struct {
+	// Constraints, Evidence, Verify, Checks, and DeniedFileGlobs are added
+	// after the spec's own, skipping entries it already has.
+	Constraints     []string `json:"constraints,omitempty" yaml:"constraints,omitempty"`
+	Evidence        []string `json:"evidence,omitempty" yaml:"evidence,omitempty"`
+	Verify          []string `json:"verify,omitempty" yaml:"verify,omitempty"`
+	Checks          []Check  `json:"checks,omitempty" yaml:"checks,omitempty"`
+	DeniedFileGlobs []string `json:"denied_file_globs,omitempty" yaml:"denied_file_globs,omitempty"`
+	// Env sets the variables the spec doesn't.
+	Env map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+	// MemoryNamespace applies to specs without one.
+	MemoryNamespace string `json:"memory_namespace,omitempty" yaml:"memory_namespace,omitempty"`
+}
+
+// SetDefaults sets the conventions LoadSpec adds to the specs it loads.
+func (c *Coach) SetDefaults(d SpecDefaults) {
+	c.defaults = d
+}
+
+// apply adds the defaults to spec.
+func (d SpecDefaults) apply(spec *TaskSpec) {
+	spec.Constraints = appendMissing(spec.Constraints, d.Constraints)
+	spec.Evidence = appendMissing(spec.Evidence, d.Evidence)
+	spec.Verify = appendMissing(spec.Verify, d.Verify)
+	spec.DeniedFileGlobs = appendMissing(spec.DeniedFileGlobs, d.DeniedFileGlobs)
+	for _, check := range d.Checks {
+		if !slices.ContainsFunc(spec.Checks, func(c Check) bool {
+			return c.Type == check.Type && c.Target == check.Target
+		}) {
+			spec.Checks = append(spec.Checks, check)
+		}
+	}
+	for name, value := range d.Env {
+		if _, ok := spec.Env[name]; ok {
+			continue
+		}
+		if spec.Env == nil {
+			spec.Env = make(map[string]string)
+		}
+		spec.Env[name] = value
+	}
+	if spec.MemoryNamespace == "" {
+		spec.MemoryNamespace = d.MemoryNamespace
+	}
+}
+
+// appendMissing appends the entries of extra not in list.
+func appendMissing(list, extra []string) []string {
+	for _, e := range extra {
+		if !slices.Contains(list, e) {
+			list = append(list, e)
+		}
+	}
+	return list
+}
@@ -0,0 +1,384 @@
+package coach
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/felixgeelhaar/simon/internal/provider"
+	"gopkg.in/yaml.v3"
+)
+
+//go:generate go run ./schemagen taskspec.schema.json ../../website/public/schema/taskspec.schema.json
+
+// SchemaURL is where the TaskSpec schema is published, for editors' YAML
+// language servers:
+//
+//	# yaml-language-server: $schema=https://felixgeelhaar.github.io/simon/schema/taskspec.schema.json
+const SchemaURL = "https://felixgeelhaar.github.io/simon/schema/taskspec.schema.json"
+
+// Schema is the generated JSON Schema for TaskSpec. Regenerate it with
+// `go generate ./internal/coach` after changing TaskSpec.
+//
+//go:embed taskspec.schema.json
+var Schema []byte
+
+// specFieldDocs describes each TaskSpec field in the schema.
+var specFieldDocs = map[string]string{
+	"goal":                     "What the agent must achieve.",
+	"definition_of_done":       "How to tell the task is finished.",
+	"constraints":              "Rules the agent must follow; restated periodically in long sessions.",
+	"evidence":                 "Paths that must exist on completion.",
+	"verify":                   "Commands the verifier runs itself to confirm completion, e.g. \"go test ./...\".",
+	"checks":                   "Further completion checks, each run by the verifier named by its type.",
+	"checks.type":              "The verifier: file, command, content, http, coverage, or the name of a verifier plugin from the verify.plugins config key.",
+	"checks.target":            "What the check inspects: a path (file, content), a command, a URL (http), or the packages to test (coverage, e.g. ./...).",
+	"checks.expect":            "For content checks, a regular expression the file must match; for http checks, the expected status code (default any 2xx); for coverage checks, the minimum statement coverage in percent of every package.",
+	"checks.params":            "Settings passed to a plugin verifier as they are.",
+	"env":                      "Environment variables passed to tool execution on top of the sandboxed base environment.",
+	"allowed_commands":         "Narrows the global command policy for this task; empty means no extra restriction.",
+	"denied_file_globs":        "Files no tool may read or write during this task, e.g. \"**/.env\"; they win over the policy's allowed_file_globs.",
+	"reminder_interval":        "Restate the constraints every N iterations; 0 uses the default and a negative value disables reminders.",
+	"memory_namespace":         "Project whose memories are retrieved and which new memories belong to; defaults to the git origin remote of the working directory (e.g. github.com/owner/repo), or the repository root without one.",
+	"provider":                 "The provider the session runs on, overriding the provider.default config key; simon run --provider takes precedence.",
+	"model":                    "The model the session runs on, e.g. a long-context model for a large refactor; overrides provider.model, and simon run --model takes precedence.",
+	"escalate":                 "Switches a stuck session to a stronger provider or model without restarting it; at most once per session.",
+	"escalate.provider":        "The provider to escalate to; defaults to the current one.",
+	"escalate.model":           "The model to escalate to; defaults to the provider's default model.",
+	"escalate.after":           "Consecutive failed verifications that trigger the switch (default 2); a provider call that fails for good escalates at once.",
+	"vars":                     "Default values for ${NAME} placeholders in the other fields; simon run --var and the environment take precedence. Write $${NAME} for a literal ${NAME}.",
+	"hooks":                    "Commands run at points of the session's lifecycle, under the same guard checks as tool calls.",
+	"hooks.pre_run":            "Commands run before the first iteration; failures are reported in the initial prompt.",
+	"hooks.post_iteration":     "Commands run after every iteration with tool calls, e.g. \"go vet ./...\"; failures are fed back to the agent.",
+	"hooks.on_complete":        "Commands run once verification passes; a failure is fed back and the session continues.",
+	"steps":                    "Sub-goals run one after another as sub-tasks with their own budgets; evidence and verify then check the whole mission.",
+	"steps.goal":               "What this step must achieve.",
+	"steps.definition_of_done": "How to tell the step is finished; defaults to its goal.",
+	"steps.evidence":           "Paths that must exist when the step is done.",
+	"steps.verify":             "Commands the verifier runs to confirm the step is done.",
+	"steps.checks":             "Further checks run to confirm the step is done, like the spec's checks.",
+	"steps.max_iterations":     "The step's iteration budget, capped by the policy; 0 uses the sub-task default.",
+	"params":                   "Sampling parameters of the session's requests, over the policy's params; unset ones keep the provider's defaults.",
+	"params.default":           "Parameters of every phase's requests.",
+	"params.planning":          "Parameters of the planner's requests, over the defaults.",
+	"params.execution":         "Parameters of the requests working on the task, over the defaults.",
+	"params.summary":           "Parameters of the requests summarizing the history or the finished session, over the defaults.",
+}
+
+// paramDocs describes the sampling parameters of each phase in params.
+var paramDocs = map[string]string{
+	"temperature": "Sampling temperature, from 0 (deterministic) to 2.",
+	"top_p":       "Nucleus sampling: sample from the most likely tokens whose probabilities add up to top_p (above 0, at most 1).",
+	"max_tokens":  "The most tokens an answer may have; 0 uses the provider's default.",
+	"stop":        "Sequences that end the answer when generated.",
+}
+
+// JSONSchema is the subset of JSON Schema (draft-07) used for TaskSpec.
+// AdditionalProperties is either a bool or a *JSONSchema.
+type JSONSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	ID                   string                 `json:"$id,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties interface{}            `json:"additionalProperties,omitempty"`
+	PropertyNames        *JSONSchema            `json:"propertyNames,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	MinItems             int                    `json:"minItems,omitempty"`
+	MinLength            int                    `json:"minLength,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	AnyOf                []*JSONSchema          `json:"anyOf,omitempty"`
+}
+
+// nonBlank matches strings with at least one non-space character.
+const nonBlank = `\S`
+
+// TaskSpecSchema derives the schema from TaskSpec's fields, adding the rules
+// Validate enforces as errors.
+func TaskSpecSchema() *JSONSchema {
+	s := &JSONSchema{
+		Schema:               "http://json-schema.org/draft-07/schema#",
+		ID:                   SchemaURL,
+		Title:                "Simon task spec",
+		Description:          "The structured input of a Simon session (simon run --spec).",
+		Type:                 "object",
+		Properties:           make(map[string]*JSONSchema),
+		Required:             []string{"goal", "definition_of_done"},
+		AdditionalProperties: false,
+		AnyOf: append(evidenceRequired(),
+			&JSONSchema{Required: []string{"steps"}, Properties: map[string]*JSONSchema{"steps": {MinItems: 1}}}),
+	}
+
+	s.Properties = fieldSchema(reflect.TypeOf(TaskSpec{})).Properties
+	for name, doc := range specFieldDocs {
+		prop := s.Properties
+		path := strings.Split(name, ".")
+		for _, parent := range path[:len(path)-1] {
+			if items := prop[parent].Items; items != nil {
+				prop = items.Properties
+			} else {
+				prop = prop[parent].Properties
+			}
+		}
+		prop[path[len(path)-1]].Description = doc
+	}
+
+	s.Properties["goal"].MinLength = 1
+	s.Properties["definition_of_done"].MinLength = 1
+	s.Properties["verify"].Items.Pattern = nonBlank
+	s.Properties["allowed_commands"].Items.Pattern = nonBlank
+	s.Properties["denied_file_globs"].Items.Pattern = nonBlank
+	s.Properties["provider"].Enum = provider.Names
+	s.Properties["model"].Pattern = nonBlank
+	s.Properties["escalate"].Properties["provider"].Enum = provider.Names
+	s.Properties["escalate"].Properties["model"].Pattern = nonBlank
+	check := s.Properties["checks"].Items
+	check.Required = []string{"type", "target"}
+	check.Properties["type"].Pattern = nonBlank
+	check.Properties["target"].Pattern = nonBlank
+	s.Properties["env"].PropertyNames = &JSONSchema{Pattern: envNamePattern.String()}
+	s.Properties["vars"].PropertyNames = &JSONSchema{Pattern: envNamePattern.String()}
+	for _, hook := range s.Properties["hooks"].Properties {
+		hook.Items.Pattern = nonBlank
+	}
+	for _, phase := range s.Properties["params"].Properties {
+		for name, doc := range paramDocs {
+			phase.Properties[name].Description = doc
+		}
+		phase.Properties["stop"].Items.MinLength = 1
+	}
+
+	step := s.Properties["steps"].Items
+	step.Required = []string{"goal"}
+	step.AnyOf = evidenceRequired()
+	step.Properties["goal"].MinLength = 1
+	step.Properties["verify"].Items.Pattern = nonBlank
+	step.Properties["checks"].Items = check
+	return s
+}
+
+// evidenceRequired requires at least one evidence path, verify command, or
+// check.
+func evidenceRequired() []*JSONSchema {
+	return []*JSONSchema{
+		{Required: []string{"evidence"}, Properties: map[string]*JSONSchema{"evidence": {MinItems: 1}}},
+		{Required: []string{"verify"}, Properties: map[string]*JSONSchema{"verify": {MinItems: 1}}},
+		{Required: []string{"checks"}, Properties: map[string]*JSONSchema{"checks": {MinItems: 1}}},
+	}
+}
+
+// fieldSchema maps a TaskSpec field type to its schema; pointers map to
+// their element's. Struct fields are
+// named by their json tags.
+func fieldSchema(t reflect.Type) *JSONSchema {
+	switch t.Kind() {
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Int:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	case reflect.Pointer:
+		return fieldSchema(t.Elem())
+	case reflect.Slice:
+		return &JSONSchema{Type: "array", Items: fieldSchema(t.Elem())}
+	case reflect.Map:
+		return &JSONSchema{Type: "object", AdditionalProperties: fieldSchema(t.Elem())}
+	case reflect.Struct:
+		s := &JSONSchema{Type: "object", Properties: make(map[string]*JSONSchema), AdditionalProperties: false}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			s.Properties[strings.Split(f.Tag.Get("json"), ",")[0]] = fieldSchema(f.Type)
+		}
+		return s
+	default:
+		panic(fmt.Sprintf("coach: no schema mapping for %s", t))
+	}
+}
+
+// GenerateSchema renders TaskSpecSchema as indented JSON.
+func GenerateSchema() ([]byte, error) {
+	data, err := json.MarshalIndent(TaskSpecSchema(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// SchemaError is a schema violation at a position in the spec file.
+type SchemaError struct {
+	Line   int
+	Column int
+	// Field is the dotted path to the offending value; empty for the document.
+	Field   string
+	Message string
+}
+
+func (e SchemaError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+	}
+	return fmt.Sprintf("%d:%d: %s: %s", e.Line, e.Column, e.Field, e.Message)
+}
+
+// ValidateSchema checks a YAML or JSON spec document against the TaskSpec
+// schema. Syntax errors are returned as err; violations are returned sorted
+// by position.
+func ValidateSchema(data []byte) ([]SchemaError, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return []SchemaError{{Line: 1, Column: 1, Message: "spec is empty"}}, nil
+	}
+	errs := validateNode(TaskSpecSchema(), doc.Content[0], "")
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].Line != errs[j].Line {
+			return errs[i].Line < errs[j].Line
+		}
+		return errs[i].Column < errs[j].Column
+	})
+	return errs, nil
+}
+
+// ValidateSchemaFile reads path and validates it with ValidateSchema.
+func ValidateSchemaFile(path string) ([]SchemaError, error) {
+	data, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spec file: %w", err)
+	}
+	return ValidateSchema(data)
+}
+
+// validateNode checks n against s; field is n's dotted path.
+func validateNode(s *JSONSchema, n *yaml.Node, field string) []SchemaError {
+	if n.Kind == yaml.AliasNode && n.Alias != nil {
+		n = n.Alias
+	}
+	fail := func(format string, args ...interface{}) []SchemaError {
+		return []SchemaError{{Line: n.Line, Column: n.Column, Field: field, Message: fmt.Sprintf(format, args...)}}
+	}
+
+	// Integers are numbers too
+	if s.Type != "" && nodeType(n) != s.Type && !(s.Type == "number" && nodeType(n) == "integer") {
+		return fail("expected %s, got %s", s.Type, nodeType(n))
+	}
+
+	var errs []SchemaError
+	switch n.Kind {
+	case yaml.MappingNode:
+		present := make(map[string]bool)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			present[key.Value] = true
+			path := joinField(field, key.Value)
+			if s.PropertyNames != nil && s.PropertyNames.Pattern != "" && !regexp.MustCompile(s.PropertyNames.Pattern).MatchString(key.Value) {
+				errs = append(errs, SchemaError{Line: key.Line, Column: key.Column, Field: path, Message: fmt.Sprintf("invalid name %q", key.Value)})
+			}
+			if prop, ok := s.Properties[key.Value]; ok {
+				errs = append(errs, validateNode(prop, value, path)...)
+				continue
+			}
+			switch extra := s.AdditionalProperties.(type) {
+			case bool:
+				if !extra {
+					errs = append(errs, SchemaError{Line: key.Line, Column: key.Column, Field: path, Message: "unknown field"})
+				}
+			case *JSONSchema:
+				errs = append(errs, validateNode(extra, value, path)...)
+			}
+		}
+		for _, name := range s.Required {
+			if !present[name] {
+				errs = append(errs, fail("missing required field %q", name)...)
+			}
+		}
+	case yaml.SequenceNode:
+		if len(n.Content) < s.MinItems {
+			errs = append(errs, fail("must have at least %d item(s)", s.MinItems)...)
+		}
+		if s.Items != nil {
+			for i, item := range n.Content {
+				errs = append(errs, validateNode(s.Items, item, fmt.Sprintf("%s[%d]", field, i))...)
+			}
+		}
+	case yaml.ScalarNode:
+		if len(n.Value) < s.MinLength {
+			errs = append(errs, fail("must not be empty")...)
+		}
+		if s.Pattern != "" && !regexp.MustCompile(s.Pattern).MatchString(n.Value) {
+			if s.Pattern == nonBlank {
+				errs = append(errs, fail("must not be blank")...)
+			} else {
+				errs = append(errs, fail("must match %s", s.Pattern)...)
+			}
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, n.Value) {
+			errs = append(errs, fail("must be one of %s", strings.Join(s.Enum, ", "))...)
+		}
+	}
+
+	if len(s.AnyOf) > 0 {
+		var alternatives []string
+		for _, alt := range s.AnyOf {
+			altErrs := validateNode(alt, n, field)
+			if len(altErrs) == 0 {
+				alternatives = nil
+				break
+			}
+			msg := altErrs[0].Message
+			if altErrs[0].Field != field {
+				msg = altErrs[0].Field + " " + msg
+			}
+			alternatives = append(alternatives, msg)
+		}
+		if len(alternatives) > 0 {
+			errs = append(errs, fail("%s", strings.Join(alternatives, ", or "))...)
+		}
+	}
+	return errs
+}
+
+// nodeType names a YAML node's JSON Schema type.
+func nodeType(n *yaml.Node) string {
+	switch n.Kind {
+	case yaml.MappingNode:
+		return "object"
+	case yaml.SequenceNode:
+		return "array"
+	case yaml.ScalarNode:
+		switch n.Tag {
+		case "!!str":
+			return "string"
+		case "!!int":
+			return "integer"
+		case "!!float":
+			return "number"
+		case "!!bool":
+			return "boolean"
+		case "!!null":
+			return "null"
+		}
+	}
+	return "unknown"
+}
+
+func joinField(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
@@ -0,0 +1,94 @@
+package coach
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSchemaUpToDate(t *testing.T) {
+	generated, err := GenerateSchema()
+	if err != nil {
+		t.Fatalf("GenerateSchema failed: %v", err)
+	}
+	if !bytes.Equal(Schema, generated) {
+		t.Error("Embedded schema is stale; run go generate ./internal/coach")
+	}
+	published, err := os.ReadFile("../../website/public/schema/taskspec.schema.json")
+	if err != nil || !bytes.Equal(published, generated) {
+		t.Errorf("Published schema is missing or stale (%v); run go generate ./internal/coach", err)
+	}
+
+	for name, prop := range TaskSpecSchema().Properties {
+		if prop.Description == "" {
+			t.Errorf("TaskSpec field %s has no schema description", name)
+		}
+	}
+}
+
+func TestValidateSchema(t *testing.T) {
+	valid := "goal: Build the CLI\ndefinition_of_done: It runs\nverify:\n  - go test ./...\nenv:\n  GOFLAGS: -mod=mod\n"
+	if errs, err := ValidateSchema([]byte(valid)); err != nil || len(errs) != 0 {
+		t.Errorf("Expected a valid spec, got %v, %v", errs, err)
+	}
+
+	invalid := strings.Join([]string{
+		"goal: Build the CLI",
+		"definiton_of_done: typo",
+		"evidence: []",
+		"constraints: none",
+		"reminder_interval: 3",
+	}, "\n")
+	errs, err := ValidateSchema([]byte(invalid))
+	if err != nil {
+		t.Fatalf("ValidateSchema failed: %v", err)
+	}
+	var got []string
+	for _, e := range errs {
+		got = append(got, e.Error())
+	}
+	want := []string{
+		`1:1: missing required field "definition_of_done"`,
+		`1:1: evidence must have at least 1 item(s), or missing required field "verify", or missing required field "checks", or missing required field "steps"`,
+		"2:1: definiton_of_done: unknown field",
+		"4:14: constraints: expected array, got string",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected errors:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// JSON specs are validated with positions too
+	errs, _ = ValidateSchema([]byte("{\"goal\": \"x\",\n \"definition_of_done\": \"\", \"verify\": [\"make\"]}"))
+	if len(errs) != 1 || errs[0].Line != 2 || errs[0].Field != "definition_of_done" {
+		t.Errorf("Expected an empty definition_of_done on line 2, got %v", errs)
+	}
+
+	// Hooks are checked field by field
+	errs, _ = ValidateSchema([]byte("goal: x\ndefinition_of_done: y\nverify: [make]\nhooks:\n  post_iteration: [go vet ./...]\n  on_finish: [make]\n"))
+	if len(errs) != 1 || errs[0].Field != "hooks.on_finish" {
+		t.Errorf("Expected an unknown hooks.on_finish, got %v", errs)
+	}
+
+	// Steps stand in for evidence, but each one needs its own
+	errs, _ = ValidateSchema([]byte("goal: x\ndefinition_of_done: y\nsteps:\n  - goal: a\n    verify: [make]\n  - goal: b\n"))
+	if len(errs) != 1 || errs[0].Field != "steps[1]" || errs[0].Line != 6 {
+		t.Errorf("Expected step 2 to need evidence, got %v", errs)
+	}
+
+	// The provider must be one simon knows
+	errs, _ = ValidateSchema([]byte("goal: x\ndefinition_of_done: y\nverify: [make]\nprovider: openia\nmodel: gpt-4o\n"))
+	if len(errs) != 1 || errs[0].Field != "provider" || !strings.Contains(errs[0].Message, "must be one of ollama, openai") {
+		t.Errorf("Expected an unknown provider, got %v", errs)
+	}
+
+	// Sampling params take integers where numbers are expected
+	errs, _ = ValidateSchema([]byte("goal: x\ndefinition_of_done: y\nverify: [make]\nparams:\n  default:\n    temperature: 1\n  summary:\n    top_p: high\n"))
+	if len(errs) != 1 || errs[0].Field != "params.summary.top_p" {
+		t.Errorf("Expected only the non-numeric top_p, got %v", errs)
+	}
+
+	if _, err := ValidateSchema([]byte("goal: [x\n")); err == nil {
+		t.Error("Expected a syntax error")
+	}
+}
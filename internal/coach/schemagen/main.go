@@ -0,0 +1,23 @@
+// Command schemagen writes the TaskSpec JSON Schema to each path given.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/felixgeelhaar/simon/internal/coach"
+)
+
+func main() {
+	data, err := coach.GenerateSchema()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for _, path := range os.Args[1:] {
+		if err := os.WriteFile(path, data, 0644); err != nil { // #nosec G306 -- published schema
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+}
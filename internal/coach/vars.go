@@ -0,0 +1,130 @@
+package coach
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// varPattern matches ${NAME} placeholders and their $${NAME} escapes.
+var varPattern = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Resolve replaces ${NAME} placeholders in the spec's string fields, steps
+// included. A name is looked up in vars (simon run --var), then the
+// environment, then the spec's vars block; $${NAME} is kept as a literal
+// ${NAME}. Placeholders that resolve nowhere are reported together. Values
+// from the environment are kept for Secrets.
+func (s *TaskSpec) Resolve(vars map[string]string) error {
+	lookup := func(name string) (string, bool) {
+		if v, ok := vars[name]; ok {
+			return v, true
+		}
+		if v, ok := os.LookupEnv(name); ok {
+			if v != "" && !slices.Contains(s.envValues, v) {
+				s.envValues = append(s.envValues, v)
+			}
+			return v, true
+		}
+		v, ok := s.Vars[name]
+		return v, ok
+	}
+
+	undefined := make(map[string]bool)
+	expand := func(text string) string {
+		return varPattern.ReplaceAllStringFunc(text, func(m string) string {
+			if strings.HasPrefix(m, "$$") {
+				return m[1:]
+			}
+			name := m[2 : len(m)-1]
+			v, ok := lookup(name)
+			if !ok {
+				undefined[name] = true
+				return m
+			}
+			return v
+		})
+	}
+
+	v := reflect.ValueOf(s).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if f := v.Type().Field(i); f.IsExported() && f.Name != "Vars" {
+			expandValue(v.Field(i), expand)
+		}
+	}
+
+	if len(undefined) > 0 {
+		names := make([]string, 0, len(undefined))
+		for name := range undefined {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("undefined spec variables ${%s}: set them with --var, the environment, or the spec's vars block", strings.Join(names, "}, ${"))
+	}
+	return nil
+}
+
+// Secrets returns the spec's values that may be credentials, to redact
+// wherever the spec or the session's output is shown: its env values and
+// the ${NAME} placeholder values Resolve took from the environment.
+func (s *TaskSpec) Secrets() []string {
+	secrets := slices.Clone(s.envValues)
+	for _, v := range s.Env {
+		if v != "" && !slices.Contains(secrets, v) {
+			secrets = append(secrets, v)
+		}
+	}
+	return secrets
+}
+
+// expandValue applies expand to every string reachable from v.
+func expandValue(v reflect.Value, expand func(string) string) {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(expand(v.String()))
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			expandValue(v.Index(i), expand)
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(key))
+			expandValue(elem, expand)
+			v.SetMapIndex(key, elem)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			expandValue(v.Field(i), expand)
+		}
+	}
+}
+
+// ParseVars parses NAME=value pairs, as given to --var.
+func ParseVars(pairs []string) (map[string]string, error) {
+	vars := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || !envNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid variable %q: expected NAME=value", pair)
+		}
+		vars[name] = value
+	}
+	return vars, nil
+}
+
+// LoadSpecWithVars loads a spec and resolves its placeholders (see
+// TaskSpec.Resolve).
+func (c *Coach) LoadSpecWithVars(path string, vars map[string]string) (*TaskSpec, error) {
+	spec, err := c.LoadSpec(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := spec.Resolve(vars); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
@@ -0,0 +1,66 @@
+package coach
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	t.Setenv("SIMON_TEST_BRANCH", "release/2.0")
+	spec := TaskSpec{
+		Goal:             "Fix ${TICKET} in ${SERVICE}",
+		DefinitionOfDone: "Tests pass on ${SIMON_TEST_BRANCH}",
+		Verify:           []string{"go test ./services/${SERVICE}/...", `echo $${HOME}`},
+		Env:              map[string]string{"SERVICE_NAME": "${SERVICE}"},
+		Steps:            []Step{{Goal: "Reproduce ${TICKET}"}},
+		Vars:             map[string]string{"SERVICE": "billing", "TICKET": "OPS-1"},
+	}
+	if err := spec.Resolve(map[string]string{"TICKET": "OPS-42"}); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if spec.Goal != "Fix OPS-42 in billing" {
+		t.Errorf("Expected --var to override the vars block, got %q", spec.Goal)
+	}
+	if spec.DefinitionOfDone != "Tests pass on release/2.0" {
+		t.Errorf("Expected the environment to resolve, got %q", spec.DefinitionOfDone)
+	}
+	if spec.Verify[0] != "go test ./services/billing/..." || spec.Verify[1] != "echo ${HOME}" {
+		t.Errorf("Unexpected verify commands: %q", spec.Verify)
+	}
+	if spec.Env["SERVICE_NAME"] != "billing" || spec.Steps[0].Goal != "Reproduce OPS-42" {
+		t.Errorf("Expected env values and steps to resolve, got %v, %q", spec.Env, spec.Steps[0].Goal)
+	}
+	if secrets := spec.Secrets(); !slices.Equal(secrets, []string{"release/2.0", "billing"}) {
+		t.Errorf("Expected the environment and env values as secrets, got %q", secrets)
+	}
+
+	missing := TaskSpec{Goal: "Deploy ${SIMON_TEST_UNSET} to ${SIMON_TEST_UNSET_TOO}", Evidence: []string{"${SIMON_TEST_UNSET}"}}
+	err := missing.Resolve(nil)
+	if err == nil || !strings.Contains(err.Error(), "${SIMON_TEST_UNSET}, ${SIMON_TEST_UNSET_TOO}") {
+		t.Errorf("Expected both undefined variables reported, got %v", err)
+	}
+}
+
+func TestLoadSpecWithVars(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spec.yaml")
+	os.WriteFile(path, []byte("goal: Deploy ${SERVICE}\ndefinition_of_done: done\nevidence:\n  - deploy-${SERVICE}.log\nvars:\n  SERVICE: api\n"), 0600)
+
+	spec, err := New().LoadSpecWithVars(path, nil)
+	if err != nil {
+		t.Fatalf("LoadSpecWithVars failed: %v", err)
+	}
+	if spec.Goal != "Deploy api" || spec.Evidence[0] != "deploy-api.log" {
+		t.Errorf("Unexpected spec: %+v", spec)
+	}
+
+	vars, err := ParseVars([]string{"SERVICE=web", "QUERY=a=b"})
+	if err != nil || vars["SERVICE"] != "web" || vars["QUERY"] != "a=b" {
+		t.Errorf("Unexpected vars %v, %v", vars, err)
+	}
+	if _, err := ParseVars([]string{"bad-name=x"}); err == nil {
+		t.Error("Expected an invalid variable name to be rejected")
+	}
+}
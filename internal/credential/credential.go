@@ -1,11 +1,13 @@
 // Package credential provides secure storage for sensitive data like API keys.
-// It uses AES-256-GCM encryption with a machine-derived key to encrypt credentials
-// before storing them in the database.
+// It uses AES-256-GCM encryption to encrypt credentials before storing them in
+// the database, with either a machine-derived key or a key derived from a user
+// passphrase (for stores synced across machines).
 package credential
 
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/pbkdf2"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
@@ -20,11 +22,22 @@ import (
 const (
 	// EncryptedPrefix marks values as encrypted in storage
 	EncryptedPrefix = "enc:v1:"
+
+	// PassphraseIterations is the PBKDF2-SHA256 work factor for passphrase-derived keys.
+	PassphraseIterations = 600000
+	// SaltSize is the length of the random salt for passphrase-derived keys.
+	SaltSize = 16
+
+	// checkPlaintext is encrypted into a check value that tells a wrong key
+	// from a right one before any credential is decrypted.
+	checkPlaintext = "simon-credential-check"
 )
 
 var (
 	ErrDecryptionFailed = errors.New("decryption failed")
 	ErrInvalidFormat    = errors.New("invalid encrypted format")
+	ErrEmptyPassphrase  = errors.New("passphrase must not be empty")
+	ErrWrongKey         = errors.New("wrong passphrase or key")
 )
 
 // Manager handles secure storage and retrieval of credentials.
@@ -43,6 +56,60 @@ func NewManager() (*Manager, error) {
 	return &Manager{key: key}, nil
 }
 
+// NewPassphraseManager creates a credential manager whose key is derived from
+// a passphrase and salt with PBKDF2-SHA256, so credentials can be decrypted on
+// any machine that knows the passphrase.
+func NewPassphraseManager(passphrase string, salt []byte) (*Manager, error) {
+	if passphrase == "" {
+		return nil, ErrEmptyPassphrase
+	}
+	if len(salt) < SaltSize {
+		return nil, fmt.Errorf("salt must be at least %d bytes", SaltSize)
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, PassphraseIterations, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive encryption key: %w", err)
+	}
+	return &Manager{key: key}, nil
+}
+
+// NewSalt returns a random salt for NewPassphraseManager.
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, SaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	return salt, nil
+}
+
+// NewCheck returns a value to store alongside the credentials; Verify
+// accepts it only under the same key.
+func (m *Manager) NewCheck() (string, error) {
+	return m.Encrypt(checkPlaintext)
+}
+
+// Verify returns ErrWrongKey unless check was created by NewCheck with this key.
+func (m *Manager) Verify(check string) error {
+	plaintext, err := m.Decrypt(check)
+	if err != nil || plaintext != checkPlaintext {
+		return ErrWrongKey
+	}
+	return nil
+}
+
+// Reencrypt decrypts a stored value with m and encrypts it with next.
+// Values that aren't encrypted are returned unchanged.
+func (m *Manager) Reencrypt(stored string, next *Manager) (string, error) {
+	if !IsEncrypted(stored) {
+		return stored, nil
+	}
+	plaintext, err := m.Decrypt(stored)
+	if err != nil {
+		return "", err
+	}
+	return next.Encrypt(plaintext)
+}
+
 // Encrypt encrypts a plaintext value and returns a storable string.
 func (m *Manager) Encrypt(plaintext string) (string, error) {
 	if plaintext == "" {
@@ -0,0 +1,63 @@
+package credential
+
+import (
+	"crypto/hkdf"
+	"crypto/sha256"
+	"fmt"
+	"sync"
+)
+
+// historyInfo prefixes the session ID in the HKDF info of history keys.
+const historyInfo = "simon-history:"
+
+// HistoryCipher encrypts conversation history at rest, each session under
+// a key of its own derived with HKDF-SHA256 from a credential key. The
+// credential key is obtained on first use, so a passphrase is only asked
+// for once encrypted history is written or read. store.SQLiteStore uses it
+// through SetMessageCipher.
+type HistoryCipher struct {
+	key  func() (*Manager, error)
+	once sync.Once
+	m    *Manager
+	err  error
+}
+
+// NewHistoryCipher returns a cipher for history keyed by the manager key returns.
+func NewHistoryCipher(key func() (*Manager, error)) *HistoryCipher {
+	return &HistoryCipher{key: key}
+}
+
+// forSession returns the manager for one session's history.
+func (c *HistoryCipher) forSession(sessionID string) (*Manager, error) {
+	c.once.Do(func() { c.m, c.err = c.key() })
+	if c.err != nil {
+		return nil, c.err
+	}
+	key, err := hkdf.Key(sha256.New, c.m.key, nil, historyInfo+sessionID, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive history key: %w", err)
+	}
+	return &Manager{key: key}, nil
+}
+
+// Encrypt encrypts history content of a session.
+func (c *HistoryCipher) Encrypt(sessionID, plaintext string) (string, error) {
+	m, err := c.forSession(sessionID)
+	if err != nil {
+		return "", err
+	}
+	return m.Encrypt(plaintext)
+}
+
+// Decrypt decrypts history content of a session; content that isn't
+// encrypted is returned unchanged.
+func (c *HistoryCipher) Decrypt(sessionID, stored string) (string, error) {
+	if !IsEncrypted(stored) {
+		return stored, nil
+	}
+	m, err := c.forSession(sessionID)
+	if err != nil {
+		return "", err
+	}
+	return m.Decrypt(stored)
+}
@@ -0,0 +1,63 @@
+package credential
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// SecretPatterns match credentials of common providers and services that
+// can end up in prompts, tool output, or logs.
+var SecretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`sk-[A-Za-z0-9_-]{16,}`),                 // OpenAI, Anthropic
+	regexp.MustCompile(`AIza[0-9A-Za-z_-]{35}`),                 // Google
+	regexp.MustCompile(`gsk_[A-Za-z0-9]{20,}`),                  // Groq
+	regexp.MustCompile(`gh[pousr]_[A-Za-z0-9]{36,}`),            // GitHub
+	regexp.MustCompile(`xox[abprs]-[A-Za-z0-9-]{10,}`),          // Slack
+	regexp.MustCompile(`AKIA[0-9A-Z]{16}`),                      // AWS access key
+	regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9._~+/-]{16,}=*`), // Authorization headers
+}
+
+// ContainsSecret reports whether text contains a value matching one of
+// SecretPatterns.
+func ContainsSecret(text string) bool {
+	for _, pattern := range SecretPatterns {
+		if pattern.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redactor masks secrets in text for display, as MaskSecret does: the
+// secrets it was given, such as configured API keys, and values matching
+// SecretPatterns.
+type Redactor struct {
+	secrets []string
+}
+
+// NewRedactor returns a Redactor for the given secrets in addition to
+// SecretPatterns. Secrets shorter than 8 bytes are ignored, as they would
+// mask ordinary words.
+func NewRedactor(secrets ...string) *Redactor {
+	r := &Redactor{}
+	for _, secret := range secrets {
+		if len(secret) >= 8 {
+			r.secrets = append(r.secrets, secret)
+		}
+	}
+	// Longer secrets first, so one containing another is masked whole
+	slices.SortFunc(r.secrets, func(a, b string) int { return len(b) - len(a) })
+	return r
+}
+
+// String returns text with every secret replaced by its mask.
+func (r *Redactor) String(text string) string {
+	for _, secret := range r.secrets {
+		text = strings.ReplaceAll(text, secret, MaskSecret(secret))
+	}
+	for _, pattern := range SecretPatterns {
+		text = pattern.ReplaceAllStringFunc(text, MaskSecret)
+	}
+	return text
+}
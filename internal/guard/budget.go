@@ -0,0 +1,119 @@
+package guard
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Budget is the set of limits a preset scales together.
+type Budget struct {
+	MaxIterations   int
+	MaxPromptTokens int
+	MaxOutputTokens int
+	MaxCost         float64
+	MaxDuration     time.Duration
+}
+
+// BudgetPresets are the named budgets selectable with `simon run --budget`.
+var BudgetPresets = map[string]Budget{
+	"small":  {MaxIterations: 10, MaxPromptTokens: 20000, MaxOutputTokens: 5000, MaxCost: 0.25, MaxDuration: 10 * time.Minute},
+	"medium": {MaxIterations: 25, MaxPromptTokens: 100000, MaxOutputTokens: 20000, MaxCost: 1, MaxDuration: 30 * time.Minute},
+	"large":  {MaxIterations: 60, MaxPromptTokens: 400000, MaxOutputTokens: 80000, MaxCost: 5, MaxDuration: 2 * time.Hour},
+}
+
+// BudgetConfigPrefix starts the config keys that override preset limits,
+// e.g. budget.large.max_cost. Keys for an unknown name define a new preset.
+const BudgetConfigPrefix = "budget."
+
+// budgetKeys are the per-preset config keys.
+var budgetKeys = []string{"max_iterations", "max_prompt_tokens", "max_output_tokens", "max_cost", "max_duration"}
+
+// BudgetNames lists the built-in presets in ascending size.
+func BudgetNames() []string {
+	names := make([]string, 0, len(BudgetPresets))
+	for name := range BudgetPresets {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return BudgetPresets[names[i]].MaxCost < BudgetPresets[names[j]].MaxCost
+	})
+	return names
+}
+
+// ResolveBudget returns the named preset with any budget.<name>.* overrides
+// from get applied. Names that are neither built in nor configured fail.
+func ResolveBudget(name string, get func(key string) string) (Budget, error) {
+	b, known := BudgetPresets[name]
+	for _, key := range budgetKeys {
+		value := strings.TrimSpace(get(BudgetConfigPrefix + name + "." + key))
+		if value == "" {
+			continue
+		}
+		known = true
+		if err := b.set(key, value); err != nil {
+			return b, fmt.Errorf("invalid %s%s.%s: %w", BudgetConfigPrefix, name, key, err)
+		}
+	}
+	if !known {
+		return b, fmt.Errorf("unknown budget %q (built in: %s)", name, strings.Join(BudgetNames(), ", "))
+	}
+	return b, nil
+}
+
+func (b *Budget) set(key, value string) error {
+	var err error
+	switch key {
+	case "max_iterations":
+		b.MaxIterations, err = strconv.Atoi(value)
+	case "max_prompt_tokens":
+		b.MaxPromptTokens, err = strconv.Atoi(value)
+	case "max_output_tokens":
+		b.MaxOutputTokens, err = strconv.Atoi(value)
+	case "max_cost":
+		b.MaxCost, err = strconv.ParseFloat(strings.TrimPrefix(value, "$"), 64)
+	case "max_duration":
+		b.MaxDuration, err = time.ParseDuration(value)
+	}
+	return err
+}
+
+// Covers reports whether usage, given as the amounts a session spent, fits
+// within every limit of b. Unset limits of b cover any amount.
+func (b Budget) Covers(usage Budget) bool {
+	within := func(used, limit float64) bool { return limit <= 0 || used <= limit }
+	return within(float64(usage.MaxIterations), float64(b.MaxIterations)) &&
+		within(float64(usage.MaxPromptTokens), float64(b.MaxPromptTokens)) &&
+		within(float64(usage.MaxOutputTokens), float64(b.MaxOutputTokens)) &&
+		within(usage.MaxCost, b.MaxCost) &&
+		within(float64(usage.MaxDuration), float64(b.MaxDuration))
+}
+
+// WithBudget returns the policy with its budget limits replaced by b.
+func (p Policy) WithBudget(b Budget) Policy {
+	p.MaxIterations = b.MaxIterations
+	p.MaxPromptTokens = b.MaxPromptTokens
+	p.MaxOutputTokens = b.MaxOutputTokens
+	p.MaxCost = b.MaxCost
+	p.MaxDuration = b.MaxDuration
+	return p
+}
+
+// CheckCost verifies the session's estimated cost is within MaxCost.
+func (g *Guard) CheckCost(cost float64) *Violation {
+	if g.policy.MaxCost > 0 && cost > g.policy.MaxCost {
+		return g.violation("max_cost", fmt.Sprintf("Cost budget exceeded ($%.4f of $%.2f)", cost, g.policy.MaxCost))
+	}
+	return nil
+}
+
+// CheckThinking verifies the session's extended thinking tokens are within
+// MaxThinkingTokens.
+func (g *Guard) CheckThinking(tokens int) *Violation {
+	if g.policy.MaxThinkingTokens > 0 && tokens > g.policy.MaxThinkingTokens {
+		return g.violation("max_thinking_tokens", fmt.Sprintf("Thinking token budget exceeded (%d of %d)", tokens, g.policy.MaxThinkingTokens))
+	}
+	return nil
+}
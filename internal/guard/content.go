@@ -0,0 +1,127 @@
+package guard
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/felixgeelhaar/simon/internal/provider"
+)
+
+// defaultMaxBase64Bytes is the longest run of base64 text written content
+// may contain when ContentPolicy.Builtins is on and MaxBase64Bytes unset.
+const defaultMaxBase64Bytes = 8 << 10
+
+// ContentPolicy configures the checks on what write_file is about to write,
+// so the agent cannot write a dangerous script and then run it with an
+// allowed interpreter. Every check is opt-in, as legitimate code such as an
+// install script or a test fixture can trip them. Only write_file content
+// is scanned: a shell command writing a file, by redirect or otherwise,
+// bypasses the checks.
+type ContentPolicy struct {
+	// Builtins turns on the built-in checks (installers piped into a shell,
+	// reverse shells, destructive commands, credentials, and base64 runs
+	// over 8 KiB unless MaxBase64Bytes says otherwise).
+	Builtins bool `json:"builtins,omitempty" yaml:"builtins,omitempty"`
+	// Deny lists regular expressions written content must not match.
+	Deny []string `json:"deny,omitempty" yaml:"deny,omitempty"`
+	// MaxBase64Bytes is the longest run of base64 text allowed, which could
+	// hide a binary payload; 0 uses 8 KiB with Builtins and allows any
+	// without, and a negative value allows any.
+	MaxBase64Bytes int `json:"max_base64_bytes,omitempty" yaml:"max_base64_bytes,omitempty"`
+	// Approve asks the user to approve flagged content (simon run
+	// --approve) instead of refusing it; without an approver it is refused.
+	Approve bool `json:"approve,omitempty" yaml:"approve,omitempty"`
+}
+
+// contentCheck is a named pattern flagging written content.
+type contentCheck struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+// builtinContentChecks flag content that downloads and runs code, opens a
+// shell to another host, or destroys data.
+var builtinContentChecks = []contentCheck{
+	{"download piped into a shell", regexp.MustCompile(`(?i)\b(curl|wget)\b[^\n|]*\|\s*(sudo\s+)?(ba|z|k|da)?sh\b`)},
+	{"download piped into an interpreter", regexp.MustCompile(`(?i)\b(curl|wget)\b[^\n|]*\|\s*(sudo\s+)?(python[0-9.]*|perl|ruby|node)\b`)},
+	{"reverse shell", regexp.MustCompile(`/dev/(tcp|udp)/|\bnc(at)?\b[^\n]*\s-[a-z]*e\s|\bsocat\b[^\n]*\bexec:`)},
+	{"recursive delete of the filesystem root or home", regexp.MustCompile(`\brm\s+(-[a-zA-Z]*[rR][a-zA-Z]*\s+)+(--no-preserve-root\s+)?(/|~|\$HOME)(\s|$|/\*)`)},
+	{"fork bomb", regexp.MustCompile(`:\(\)\s*\{\s*:\s*\|\s*:\s*&\s*\}\s*;\s*:`)},
+	{"write to a raw disk", regexp.MustCompile(`\b(mkfs(\.[a-z0-9]+)?|dd\b[^\n]*\bof=/dev/(sd|nvme|hd|disk))`)},
+}
+
+// privateKey matches PEM-encoded private keys.
+var privateKey = regexp.MustCompile(`-----BEGIN ([A-Z]+ )?PRIVATE KEY-----`)
+
+// base64Run matches runs of base64 text, which may be wrapped over lines.
+var base64Run = regexp.MustCompile(`[A-Za-z0-9+/\r\n]{64,}={0,2}`)
+
+// validate rejects Deny patterns that aren't regular expressions.
+func (c ContentPolicy) validate() error {
+	for _, expr := range c.Deny {
+		if _, err := regexp.Compile(expr); err != nil {
+			return fmt.Errorf("invalid content.deny pattern %q: %w", expr, err)
+		}
+	}
+	return nil
+}
+
+// compileDenyChecks compiles the Deny patterns. LoadPolicy rejects invalid
+// ones; those of policies built in code are skipped.
+func compileDenyChecks(deny []string) []contentCheck {
+	var checks []contentCheck
+	for _, expr := range deny {
+		if re, err := regexp.Compile(expr); err == nil {
+			checks = append(checks, contentCheck{"matches " + expr, re})
+		}
+	}
+	return checks
+}
+
+// ScanContent returns why content is flagged by the content policy, one
+// reason per check it fails, or nil.
+func (g *Guard) ScanContent(content string) []string {
+	var reasons []string
+	policy := g.policy.Content
+	if policy.Builtins {
+		for _, c := range builtinContentChecks {
+			if c.pattern.MatchString(content) {
+				reasons = append(reasons, c.name)
+			}
+		}
+		if privateKey.MatchString(content) || provider.ContainsCredential(content) {
+			reasons = append(reasons, "looks like it contains a credential")
+		}
+	}
+	for _, c := range g.contentDeny {
+		if c.pattern.MatchString(content) {
+			reasons = append(reasons, c.name)
+		}
+	}
+	limit := policy.MaxBase64Bytes
+	if limit == 0 && policy.Builtins {
+		limit = defaultMaxBase64Bytes
+	}
+	if limit > 0 {
+		for _, run := range base64Run.FindAllString(content, -1) {
+			if n := len(run) - strings.Count(run, "\n") - strings.Count(run, "\r"); n > limit {
+				reasons = append(reasons, fmt.Sprintf("base64 blob of %d bytes (limit %d)", n, limit))
+				break
+			}
+		}
+	}
+	return reasons
+}
+
+// CheckContent verifies that content written to path passes the content
+// policy.
+func (g *Guard) CheckContent(path, content string) *Violation {
+	reasons := g.ScanContent(content)
+	if len(reasons) == 0 {
+		return nil
+	}
+	v := g.violation("dangerous_content", fmt.Sprintf("Content written to %s is flagged: %s", path, strings.Join(reasons, "; ")))
+	v.Subject = path
+	return v
+}
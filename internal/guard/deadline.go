@@ -0,0 +1,37 @@
+package guard
+
+import (
+	"fmt"
+	"time"
+)
+
+// CheckIteration verifies that a single iteration finished within the
+// policy's per-iteration deadline.
+func (g *Guard) CheckIteration(elapsed time.Duration) *Violation {
+	if g.policy.MaxIterationDuration > 0 && elapsed > g.policy.MaxIterationDuration {
+		return g.violation("max_iteration_duration",
+			fmt.Sprintf("Iteration exceeded its deadline (%s of %s)", elapsed.Round(time.Second), g.policy.MaxIterationDuration))
+	}
+	return nil
+}
+
+// IterationTimeout returns how long the next iteration may run given the
+// session's elapsed time: the per-iteration deadline, capped by what is left
+// of the session budget. Limits at warn severity are reported but never cut
+// calls short. Zero means no deadline.
+func (g *Guard) IterationTimeout(elapsed time.Duration) time.Duration {
+	var timeout time.Duration
+	if g.policy.MaxIterationDuration > 0 && g.severity("max_iteration_duration") != SeverityWarn {
+		timeout = g.policy.MaxIterationDuration
+	}
+	if g.policy.MaxDuration > 0 && g.severity("max_duration") != SeverityWarn {
+		remaining := g.policy.MaxDuration - elapsed
+		if remaining <= 0 {
+			remaining = time.Nanosecond
+		}
+		if timeout == 0 || remaining < timeout {
+			timeout = remaining
+		}
+	}
+	return timeout
+}
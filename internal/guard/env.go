@@ -0,0 +1,70 @@
+package guard
+
+import (
+	"path"
+	"strings"
+)
+
+// EnvPolicy selects which variables of simon's own environment reach tool
+// processes. Patterns are shell globs over variable names (GO*, *_TOKEN).
+type EnvPolicy struct {
+	// Allow lists the variables passed through; empty passes none.
+	Allow []string `json:"allow,omitempty" yaml:"allow,omitempty"`
+	// Deny overrides Allow, keeping credentials out even when "*" is allowed.
+	Deny []string `json:"deny,omitempty" yaml:"deny,omitempty"`
+	// Path lists directories added in front of the tool processes' PATH.
+	Path []string `json:"path,omitempty" yaml:"path,omitempty"`
+}
+
+// DefaultEnvPolicy passes through what common toolchains need to build and
+// keeps well-known credential variables out.
+var DefaultEnvPolicy = EnvPolicy{
+	Allow: []string{
+		"PATH", "LANG", "LC_*", "TERM", "TZ", "TMPDIR", "USER",
+		"GO*", "CGO_*", "CC", "CXX",
+		"CARGO_HOME", "RUSTUP_HOME", "NODE_PATH", "NPM_CONFIG_*", "PYTHONPATH", "VIRTUAL_ENV", "JAVA_HOME",
+	},
+	Deny: []string{
+		"AWS_*", "AZURE_*", "GOOGLE_APPLICATION_CREDENTIALS",
+		"*_TOKEN", "*_SECRET", "*_SECRET_*", "*_PASSWORD", "*_API_KEY", "*_PRIVATE_KEY", "*_ACCESS_KEY*",
+		"SIMON_*",
+	},
+}
+
+// Allows reports whether the variable name may be passed through.
+func (e EnvPolicy) Allows(name string) bool {
+	return matchEnv(e.Allow, name) && !matchEnv(e.Deny, name)
+}
+
+// Filter returns the entries of environ ("KEY=value") the policy allows.
+func (e EnvPolicy) Filter(environ []string) map[string]string {
+	env := make(map[string]string)
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if ok && name != "" && e.Allows(name) {
+			env[name] = value
+		}
+	}
+	return env
+}
+
+// InvalidPatterns returns the patterns in Allow and Deny that aren't
+// valid globs.
+func (e EnvPolicy) InvalidPatterns() []string {
+	var invalid []string
+	for _, pattern := range append(append([]string{}, e.Allow...), e.Deny...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			invalid = append(invalid, pattern)
+		}
+	}
+	return invalid
+}
+
+func matchEnv(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package guard
+
+import "path"
+
+// GitPolicy restricts what the git tools, and git run through run_shell,
+// may do to the repository.
+type GitPolicy struct {
+	// AllowPush permits git push; pushing is left to the user by default.
+	AllowPush bool `json:"allow_push,omitempty" yaml:"allow_push,omitempty"`
+	// AllowCommit permits creating commits.
+	AllowCommit bool `json:"allow_commit" yaml:"allow_commit"`
+	// ProtectedBranches are branch names, or globs (release/*), that may not
+	// be committed to; work happens on a branch of its own instead.
+	ProtectedBranches []string `json:"protected_branches,omitempty" yaml:"protected_branches,omitempty"`
+}
+
+// DefaultGitPolicy allows local commits and never pushes. No branch is
+// protected unless the policy lists it, e.g. protected_branches: [main, master].
+var DefaultGitPolicy = GitPolicy{
+	AllowCommit: true,
+}
+
+// CheckGitPush verifies that pushing is allowed.
+func (g *Guard) CheckGitPush() *Violation {
+	if g.policy.Git.AllowPush {
+		return nil
+	}
+	v := g.violation("git_push", "git push is not allowed")
+	v.Subject = "git push"
+	return v
+}
+
+// CheckGitCommit verifies that a commit may be created on branch.
+func (g *Guard) CheckGitCommit(branch string) *Violation {
+	return g.CheckGitUpdate("commit", branch)
+}
+
+// CheckGitUpdate verifies that git op may move branch: ops other than reset
+// create commits (commit, merge, cherry-pick, rebase, am, revert, pull) and
+// need AllowCommit, and no op may move a protected branch.
+func (g *Guard) CheckGitUpdate(op, branch string) *Violation {
+	if op != "reset" && !g.policy.Git.AllowCommit {
+		v := g.violation("git_commit", "git "+op+" is not allowed (it creates commits)")
+		v.Subject = "git " + op
+		return v
+	}
+	if protected, ok := g.ProtectedBranch(branch); ok {
+		v := g.violation("git_protected_branch", "git "+op+" on protected branch "+branch+" is not allowed (matches "+protected+")")
+		v.Subject = branch
+		return v
+	}
+	return nil
+}
+
+// ProtectedBranch returns the ProtectedBranches entry matching branch.
+func (g *Guard) ProtectedBranch(branch string) (string, bool) {
+	for _, pattern := range g.policy.Git.ProtectedBranches {
+		if ok, _ := path.Match(pattern, branch); ok || pattern == branch {
+			return pattern, true
+		}
+	}
+	return "", false
+}
@@ -1,41 +1,178 @@
 package guard
 
 import (
+	"fmt"
+	"maps"
+	"os"
 	"path/filepath"
+	"time"
 
-	"github.com/bmatcuk/doublestar/v4"
+	"github.com/felixgeelhaar/simon/internal/provider"
+	"gopkg.in/yaml.v3"
 )
 
 // Policy defines the limits and scopes for an execution session.
 type Policy struct {
-	MaxIterations     int      `json:"max_iterations"`
-	MaxPromptTokens   int      `json:"max_prompt_tokens"`
-	MaxOutputTokens   int      `json:"max_output_tokens"`
-	AllowedCommands   []string `json:"allowed_commands"`
-	AllowedFileGlobs  []string `json:"allowed_file_globs"`
-	BlockDangerousCmd bool     `json:"block_dangerous_cmd"`
+	MaxIterations     int      `json:"max_iterations" yaml:"max_iterations"`
+	MaxPromptTokens   int      `json:"max_prompt_tokens" yaml:"max_prompt_tokens"`
+	MaxOutputTokens   int      `json:"max_output_tokens" yaml:"max_output_tokens"`
+	AllowedCommands   []string `json:"allowed_commands" yaml:"allowed_commands"`
+	AllowedFileGlobs  []string `json:"allowed_file_globs" yaml:"allowed_file_globs"`
+	BlockDangerousCmd bool     `json:"block_dangerous_cmd" yaml:"block_dangerous_cmd"`
+	MaxDigestTokens   int      `json:"max_digest_tokens" yaml:"max_digest_tokens"` // Token budget for tool output digests
+
+	// DeniedFileGlobs are never read or written by tools, even when
+	// AllowedFileGlobs match them (e.g. "**/.env", "**/secrets/**").
+	DeniedFileGlobs []string `json:"denied_file_globs,omitempty" yaml:"denied_file_globs,omitempty"`
+
+	// MaxArtifactReadBytes caps how much of its stored outputs a session may
+	// read back with read_artifact; 0 means unlimited.
+	MaxArtifactReadBytes int `json:"max_artifact_read_bytes" yaml:"max_artifact_read_bytes"`
+
+	// MaxWriteBytes caps what a single tool call may write: the content of a
+	// write_file call, or the workspace growth of a run_shell command; 0
+	// means unlimited.
+	MaxWriteBytes int64 `json:"max_write_bytes,omitempty" yaml:"max_write_bytes,omitempty"`
+	// MaxSessionWriteBytes is the session's disk quota, the total workspace
+	// growth its tool calls may cause; 0 means unlimited.
+	MaxSessionWriteBytes int64 `json:"max_session_write_bytes,omitempty" yaml:"max_session_write_bytes,omitempty"`
+
+	// MaxRequestsPerMinute caps provider calls; 0 disables rate limiting.
+	MaxRequestsPerMinute int `json:"max_requests_per_minute" yaml:"max_requests_per_minute"`
+	// MaxToolCallsPerMinute caps each session's tool calls; 0 means
+	// unlimited.
+	MaxToolCallsPerMinute int `json:"max_tool_calls_per_minute,omitempty" yaml:"max_tool_calls_per_minute,omitempty"`
+
+	// MaxCost caps the session's estimated cost in USD; 0 means unlimited.
+	MaxCost float64 `json:"max_cost,omitempty" yaml:"max_cost,omitempty"`
+
+	// MaxThinkingTokens caps the tokens the session's model spends on
+	// extended thinking, which don't count toward MaxOutputTokens; 0 means
+	// unlimited.
+	MaxThinkingTokens int `json:"max_thinking_tokens,omitempty" yaml:"max_thinking_tokens,omitempty"`
+
+	// Params are the default sampling parameters of sessions' requests, per
+	// phase; a spec's params override them.
+	Params provider.PhaseParams `json:"params,omitempty" yaml:"params,omitempty"`
+
+	// MaxDuration bounds the session's wall-clock time (e.g. "30m"); 0 means unlimited.
+	MaxDuration time.Duration `json:"max_duration" yaml:"max_duration"`
+	// MaxIterationDuration is the deadline for a single iteration's provider
+	// and tool calls; 0 means unlimited.
+	MaxIterationDuration time.Duration `json:"max_iteration_duration" yaml:"max_iteration_duration"`
+
+	// MaxVerificationRetries caps how many times a completion claim may fail
+	// verification; 0 means unlimited. When set, iterations ending in a
+	// failed verification are charged to this budget instead of
+	// MaxIterations.
+	MaxVerificationRetries int `json:"max_verification_retries,omitempty" yaml:"max_verification_retries,omitempty"`
+
+	// Sandbox confines shell tools at the OS level: "none" (default), "auto",
+	// "firejail" (Linux), or "sandbox-exec" (macOS). Only the paths matched by
+	// AllowedFileGlobs are writable inside the sandbox.
+	Sandbox string `json:"sandbox,omitempty" yaml:"sandbox,omitempty"`
+	// SandboxNetwork allows network access inside the sandbox.
+	SandboxNetwork bool `json:"sandbox_network,omitempty" yaml:"sandbox_network,omitempty"`
+
+	// Env selects the variables passed from simon's environment to tools.
+	Env EnvPolicy `json:"env" yaml:"env"`
+
+	// Git restricts what git may do to the repository.
+	Git GitPolicy `json:"git" yaml:"git"`
+
+	// Content checks what write_file is about to write.
+	Content ContentPolicy `json:"content,omitempty" yaml:"content,omitempty"`
+
+	// Severities overrides the reaction per rule (warn, block, halt).
+	Severities map[string]Severity `json:"severities,omitempty" yaml:"severities,omitempty"`
+}
+
+// LoadPolicy reads a YAML policy file. Fields not set in the file keep
+// their DefaultPolicy values.
+func LoadPolicy(path string) (Policy, error) {
+	return LoadPolicyOver(DefaultPolicy, path)
+}
+
+// LoadPolicyOver reads a YAML policy file over base: fields not set in the
+// file keep base's values.
+func LoadPolicyOver(base Policy, path string) (Policy, error) {
+	data, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		return base, fmt.Errorf("failed to read policy file: %w", err)
+	}
+	return ParsePolicy(base, data)
 }
 
-// CheckFile verifies if a file path is within allowed globs.
+// ParsePolicy decodes YAML policy data over base and validates the result.
+func ParsePolicy(base Policy, data []byte) (Policy, error) {
+	p := base
+	p.Severities = maps.Clone(base.Severities) // decoding adds to the map
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return p, fmt.Errorf("failed to unmarshal policy: %w", err)
+	}
+	if err := p.validateSeverities(); err != nil {
+		return p, err
+	}
+	if err := p.Content.validate(); err != nil {
+		return p, err
+	}
+	if err := p.Params.Validate(); err != nil {
+		return p, fmt.Errorf("invalid params: %w", err)
+	}
+	return p, nil
+}
+
+// CheckFile verifies if a file path is within allowed globs and not denied.
 func (g *Guard) CheckFile(path string) *Violation {
 	// If it's an absolute path, we might want to be more restrictive.
 	// For now, let's assume relative to project root or absolute matches.
 
-	allowed := false
-	for _, pattern := range g.policy.AllowedFileGlobs {
-		match, err := doublestar.Match(pattern, path)
-		if err == nil && match {
-			allowed = true
-			break
-		}
+	if v := g.CheckDeniedFile(path, nil); v != nil {
+		return v
 	}
+	return g.CheckAllowedFile(path)
+}
 
-	if !allowed {
-		return &Violation{Rule: "allowed_file_globs", Message: "File access not allowed: " + path, Fatal: true}
+// CheckAllowedFile verifies if a file path is within allowed globs, without
+// the deny check of CheckFile.
+func (g *Guard) CheckAllowedFile(path string) *Violation {
+	if _, allowed := g.MatchFile(path); !allowed {
+		v := g.violation("allowed_file_globs", "File access not allowed: "+path)
+		v.Subject = path
+		return v
 	}
 	return nil
 }
 
+// MatchFile returns the first AllowedFileGlobs pattern matching path.
+func (g *Guard) MatchFile(path string) (string, bool) {
+	return g.fileDecisions.lookup(path, func(path string) (string, bool) {
+		for _, glob := range g.globs {
+			if glob.match(path) {
+				return glob.pattern, true
+			}
+		}
+		return "", false
+	})
+}
+
+// CheckDeniedFile verifies that no DeniedFileGlobs pattern, nor one of
+// extra (a task's own denied globs, may be nil), matches path. It applies to
+// reads as well as writes.
+func (g *Guard) CheckDeniedFile(path string, extra *GlobMatcher) *Violation {
+	clean := CleanPath(path)
+	pattern, denied := g.denied.Match(clean)
+	if !denied {
+		pattern, denied = extra.Match(clean)
+	}
+	if !denied {
+		return nil
+	}
+	v := g.violation("denied_file_globs", fmt.Sprintf("File access denied by %q: %s", pattern, path))
+	v.Subject = path
+	return v
+}
+
 // CheckDangerousPath prevents common escaping patterns.
 func (g *Guard) CheckDangerousPath(path string) *Violation {
 	if !g.policy.BlockDangerousCmd {
@@ -57,24 +194,45 @@ var DefaultPolicy = Policy{
 	MaxPromptTokens:   8000,
 	MaxOutputTokens:   4000,
 	AllowedCommands:   []string{"ls", "cat", "grep", "git", "go", "mkdir", "echo"},
-	AllowedFileGlobs:  []string{"*"},
+	AllowedFileGlobs:  []string{"**"},
 	BlockDangerousCmd: true,
+	MaxDigestTokens:   200,
+	Env:               DefaultEnvPolicy,
+	Git:               DefaultGitPolicy,
+
+	MaxArtifactReadBytes: 64 << 10,
 }
 
 // Violation represents a specific breach of policy.
 type Violation struct {
-	Rule    string
-	Message string
-	Fatal   bool
+	Rule     string
+	Message  string
+	Severity Severity
+	Fatal    bool   // True when Severity is halt
+	Subject  string // The command or file path at fault; empty for budget rules
 }
 
 // Guard enforces the policy.
 type Guard struct {
 	policy Policy
+
+	// The allow lists, compiled once, and the decisions made with them
+	commands         *CommandMatcher
+	globs            []globMatcher
+	denied           *GlobMatcher
+	contentDeny      []contentCheck
+	commandDecisions decisionCache
+	fileDecisions    decisionCache
 }
 
 func New(p Policy) *Guard {
-	return &Guard{policy: p}
+	g := &Guard{policy: p, commands: NewCommandMatcher(p.AllowedCommands)}
+	for _, pattern := range p.AllowedFileGlobs {
+		g.globs = append(g.globs, compileGlob(pattern))
+	}
+	g.denied = NewGlobMatcher(p.DeniedFileGlobs)
+	g.contentDeny = compileDenyChecks(p.Content.Deny)
+	return g
 }
 
 // Policy returns the guard's current policy configuration.
@@ -82,16 +240,71 @@ func (g *Guard) Policy() Policy {
 	return g.policy
 }
 
-// CheckBudget verifies if the usage is within limits.
-func (g *Guard) CheckBudget(iterations, promptTokens, outputTokens int) *Violation {
+// CheckBudget verifies if the usage and elapsed wall-clock time are within limits.
+// When several limits are exceeded, the most severe violation is returned.
+func (g *Guard) CheckBudget(iterations, promptTokens, outputTokens int, elapsed time.Duration) *Violation {
+	var violations []*Violation
 	if iterations > g.policy.MaxIterations {
-		return &Violation{Rule: "max_iterations", Message: "Iteration limit exceeded", Fatal: true}
+		violations = append(violations, g.violation("max_iterations", "Iteration limit exceeded"))
 	}
 	if promptTokens > g.policy.MaxPromptTokens {
-		return &Violation{Rule: "max_prompt_tokens", Message: "Prompt token budget exceeded", Fatal: true}
+		violations = append(violations, g.violation("max_prompt_tokens", "Prompt token budget exceeded"))
 	}
 	if outputTokens > g.policy.MaxOutputTokens {
-		return &Violation{Rule: "max_output_tokens", Message: "Output token budget exceeded", Fatal: true}
+		violations = append(violations, g.violation("max_output_tokens", "Output token budget exceeded"))
+	}
+	if g.policy.MaxDuration > 0 && elapsed > g.policy.MaxDuration {
+		violations = append(violations, g.violation("max_duration",
+			fmt.Sprintf("Session time budget exceeded (%s of %s)", elapsed.Round(time.Second), g.policy.MaxDuration)))
+	}
+	return mostSevere(violations)
+}
+
+// CheckVerificationRetries verifies that failures, the completion claims
+// that failed verification so far, are within MaxVerificationRetries.
+func (g *Guard) CheckVerificationRetries(failures int) *Violation {
+	if limit := g.policy.MaxVerificationRetries; limit > 0 && failures > limit {
+		return g.violation("max_verification_retries", fmt.Sprintf("Verification retry budget exhausted (%d failed verifications, %d retries allowed)",
+			failures, limit))
+	}
+	return nil
+}
+
+// CheckPromptSize verifies, before a request is sent, that its prompt of
+// promptTokens would keep the session's usedTokens within MaxPromptTokens.
+func (g *Guard) CheckPromptSize(usedTokens, promptTokens int) *Violation {
+	if usedTokens+promptTokens > g.policy.MaxPromptTokens {
+		return g.violation("max_prompt_tokens", fmt.Sprintf("Request of %d prompt tokens would exceed the prompt token budget (%d of %d used)",
+			promptTokens, usedTokens, g.policy.MaxPromptTokens))
+	}
+	return nil
+}
+
+// CheckArtifactRead verifies that reading n more bytes of artifacts keeps the
+// session within its artifact read budget, of which used bytes are spent.
+func (g *Guard) CheckArtifactRead(used, n int) *Violation {
+	if limit := g.policy.MaxArtifactReadBytes; limit > 0 && used+n > limit {
+		return g.violation("max_artifact_read_bytes", fmt.Sprintf("Reading %d bytes would exceed the artifact read budget (%d of %d bytes used)",
+			n, used, limit))
+	}
+	return nil
+}
+
+// CheckWrite verifies that a tool call writing n bytes stays within the
+// per-call write limit.
+func (g *Guard) CheckWrite(n int64) *Violation {
+	if limit := g.policy.MaxWriteBytes; limit > 0 && n > limit {
+		return g.violation("max_write_bytes", fmt.Sprintf("Writing %d bytes exceeds the limit of %d bytes per tool call", n, limit))
+	}
+	return nil
+}
+
+// CheckDiskQuota verifies that growing the workspace by grow bytes keeps the
+// session within its disk quota, of which used bytes are spent.
+func (g *Guard) CheckDiskQuota(used, grow int64) *Violation {
+	if limit := g.policy.MaxSessionWriteBytes; limit > 0 && used+grow > limit {
+		return g.violation("max_session_write_bytes", fmt.Sprintf("Writing %d more bytes would exceed the session's disk quota (%d of %d bytes used)",
+			grow, used, limit))
 	}
 	return nil
 }
@@ -99,21 +312,29 @@ func (g *Guard) CheckBudget(iterations, promptTokens, outputTokens int) *Violati
 // CheckCommand verifies if a command is allowed.
 // A real implementation would parse the command structure more deeply.
 func (g *Guard) CheckCommand(cmd string) *Violation {
-	allowed := false
-	for _, allow := range g.policy.AllowedCommands {
-		if allow == "*" || allow == cmd {
-			allowed = true
-			break
-		}
-		// Prefix check for simplicity (e.g. "go test" allowed by "go")
-		if len(cmd) >= len(allow) && cmd[:len(allow)] == allow {
-			allowed = true
-			break
-		}
-	}
-
-	if !allowed {
-		return &Violation{Rule: "allowed_commands", Message: "Command not allowed: " + cmd, Fatal: true}
+	if _, ok := g.MatchCommand(cmd); !ok {
+		v := g.violation("allowed_commands", "Command not allowed: "+cmd)
+		v.Subject = cmd
+		return v
 	}
 	return nil
 }
+
+// MatchCommand returns the AllowedCommands entry permitting cmd.
+func (g *Guard) MatchCommand(cmd string) (string, bool) {
+	return g.commandDecisions.lookup(cmd, g.commands.Match)
+}
+
+// MatchCommand reports whether cmd is permitted by an allow list.
+// Entries match exactly, by prefix (e.g. "go test" allowed by "go"), or via "*".
+func MatchCommand(allowed []string, cmd string) bool {
+	_, ok := MatchingCommand(allowed, cmd)
+	return ok
+}
+
+// MatchingCommand returns the first allow list entry permitting cmd.
+// Callers checking many commands should compile the list once with
+// NewCommandMatcher.
+func MatchingCommand(allowed []string, cmd string) (string, bool) {
+	return NewCommandMatcher(allowed).Match(cmd)
+}
@@ -0,0 +1,220 @@
+package guard
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"gopkg.in/yaml.v3"
+)
+
+// PolicyIssue is a problem found by LintPolicy. Line is 0 when the issue
+// concerns a value the file doesn't set.
+type PolicyIssue struct {
+	Line    int
+	Error   bool // false for warnings
+	Message string
+}
+
+func (i PolicyIssue) String() string {
+	level := "warning"
+	if i.Error {
+		level = "error"
+	}
+	if i.Line > 0 {
+		return fmt.Sprintf("%d: %s: %s", i.Line, level, i.Message)
+	}
+	return fmt.Sprintf("%s: %s", level, i.Message)
+}
+
+// sandboxModes are the accepted values of the sandbox setting.
+var sandboxModes = []string{"", "none", "auto", "firejail", "sandbox-exec"}
+
+// LintPolicy checks a policy file's syntax and values: unknown fields,
+// invalid severities and globs, and limits that would block every session.
+// The returned error is set only when the YAML can't be parsed at all.
+func LintPolicy(data []byte) ([]PolicyIssue, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+
+	var issues []PolicyIssue
+	add := func(key string, isErr bool, format string, args ...interface{}) {
+		issues = append(issues, PolicyIssue{Line: keyLine(&root, strings.Split(key, ".")...), Error: isErr, Message: fmt.Sprintf(format, args...)})
+	}
+
+	p := DefaultPolicy
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&p); err != nil {
+		var te *yaml.TypeError
+		if !errors.As(err, &te) {
+			if errors.Is(err, io.EOF) {
+				return issues, nil // empty file
+			}
+			return nil, err
+		}
+		for _, msg := range te.Errors {
+			var line int
+			if n, _ := fmt.Sscanf(msg, "line %d:", &line); n == 1 {
+				msg = strings.TrimSpace(msg[strings.Index(msg, ":")+1:])
+			}
+			issues = append(issues, PolicyIssue{Line: line, Error: true, Message: strings.ReplaceAll(msg, "guard.Policy", "policy")})
+		}
+	}
+
+	for _, limit := range []struct {
+		key   string
+		value int
+	}{
+		{"max_iterations", p.MaxIterations},
+		{"max_prompt_tokens", p.MaxPromptTokens},
+		{"max_output_tokens", p.MaxOutputTokens},
+	} {
+		if limit.value <= 0 {
+			add(limit.key, true, "%s must be positive; no session could start", limit.key)
+		}
+	}
+	if p.MaxDigestTokens < 0 {
+		add("max_digest_tokens", true, "max_digest_tokens must not be negative")
+	}
+	if p.MaxArtifactReadBytes < 0 {
+		add("max_artifact_read_bytes", true, "max_artifact_read_bytes must not be negative (0 means unlimited)")
+	}
+	if p.MaxWriteBytes < 0 {
+		add("max_write_bytes", true, "max_write_bytes must not be negative (0 means unlimited)")
+	}
+	if p.MaxSessionWriteBytes < 0 {
+		add("max_session_write_bytes", true, "max_session_write_bytes must not be negative (0 means unlimited)")
+	}
+	if p.MaxWriteBytes > 0 && p.MaxSessionWriteBytes > 0 && p.MaxWriteBytes > p.MaxSessionWriteBytes {
+		add("max_write_bytes", false, "max_write_bytes is larger than max_session_write_bytes, which caps every write first")
+	}
+	if p.MaxVerificationRetries < 0 {
+		add("max_verification_retries", true, "max_verification_retries must not be negative (0 means unlimited)")
+	}
+	if p.MaxRequestsPerMinute < 0 {
+		add("max_requests_per_minute", true, "max_requests_per_minute must not be negative (0 disables rate limiting)")
+	}
+	if p.MaxToolCallsPerMinute < 0 {
+		add("max_tool_calls_per_minute", true, "max_tool_calls_per_minute must not be negative (0 means unlimited)")
+	}
+	if p.MaxCost < 0 {
+		add("max_cost", true, "max_cost must not be negative (0 means unlimited)")
+	}
+	if p.MaxThinkingTokens < 0 {
+		add("max_thinking_tokens", true, "max_thinking_tokens must not be negative (0 means unlimited)")
+	}
+	if p.MaxDuration < 0 {
+		add("max_duration", true, "max_duration must not be negative (0 means unlimited)")
+	}
+	if p.MaxIterationDuration < 0 {
+		add("max_iteration_duration", true, "max_iteration_duration must not be negative (0 means unlimited)")
+	}
+
+	if len(p.AllowedCommands) == 0 {
+		add("allowed_commands", false, "allowed_commands is empty; every run_shell call will be blocked")
+	}
+	for _, cmd := range p.AllowedCommands {
+		switch strings.TrimSpace(cmd) {
+		case "":
+			add("allowed_commands", true, "allowed_commands contains an empty entry, which allows every command")
+		case "*":
+			add("allowed_commands", false, `allowed_commands contains "*", which allows every command`)
+		}
+	}
+
+	if len(p.AllowedFileGlobs) == 0 {
+		add("allowed_file_globs", false, "allowed_file_globs is empty; every file write will be blocked")
+	}
+	for _, glob := range p.AllowedFileGlobs {
+		if !doublestar.ValidatePattern(glob) {
+			add("allowed_file_globs", true, "invalid glob %q", glob)
+		}
+	}
+
+	for _, glob := range p.DeniedFileGlobs {
+		if !doublestar.ValidatePattern(glob) {
+			add("denied_file_globs", true, "invalid glob %q", glob)
+		}
+	}
+
+	for _, pattern := range p.Env.InvalidPatterns() {
+		add("env", true, "invalid environment variable pattern %q", pattern)
+	}
+	for _, branch := range p.Git.ProtectedBranches {
+		if _, err := path.Match(branch, ""); err != nil {
+			add("git.protected_branches", true, "invalid protected branch pattern %q", branch)
+		}
+	}
+
+	if err := p.Params.Validate(); err != nil {
+		add("params", true, "params.%v", err)
+	}
+
+	valid := false
+	for _, mode := range sandboxModes {
+		valid = valid || p.Sandbox == mode
+	}
+	if !valid {
+		add("sandbox", true, "unknown sandbox %q (use none, auto, firejail, or sandbox-exec)", p.Sandbox)
+	}
+
+	rules := make([]string, 0, len(p.Severities))
+	for rule := range p.Severities {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+	for _, rule := range rules {
+		if sev := p.Severities[rule]; !sev.Valid() {
+			add("severities."+rule, true, "invalid severity %q for rule %s (use warn, block, or halt)", sev, rule)
+		}
+		if _, known := defaultSeverities[rule]; !known {
+			add("severities."+rule, false, "unknown rule %q in severities (known: %s)", rule, strings.Join(RuleNames(), ", "))
+		}
+	}
+
+	sort.SliceStable(issues, func(a, b int) bool { return issues[a].Line < issues[b].Line })
+	return issues, nil
+}
+
+// RuleNames lists the rules whose severity a policy can set.
+func RuleNames() []string {
+	names := make([]string, 0, len(defaultSeverities))
+	for rule := range defaultSeverities {
+		names = append(names, rule)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// keyLine returns the line of a key in a YAML document, following nested
+// mappings for each key in path, or 0 if it isn't set.
+func keyLine(root *yaml.Node, path ...string) int {
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 {
+		return 0
+	}
+	node, line := root.Content[0], 0
+	for _, key := range path {
+		if node.Kind != yaml.MappingNode {
+			return line
+		}
+		found := false
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				line, node, found = node.Content[i].Line, node.Content[i+1], true
+				break
+			}
+		}
+		if !found {
+			return line
+		}
+	}
+	return line
+}
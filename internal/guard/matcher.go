@@ -0,0 +1,200 @@
+package guard
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// CommandMatcher is an allow list compiled into a trie, so a lookup costs
+// one walk over the command instead of a scan of every entry. It follows
+// MatchCommand: entries match exactly, by prefix, or via "*".
+type CommandMatcher struct {
+	root    trieNode
+	entries []string
+	star    int // Index of the first "*" entry, or -1
+}
+
+type trieNode struct {
+	children map[byte]*trieNode
+	entry    int // Index of the first entry ending here, or -1
+}
+
+// NewCommandMatcher compiles an allow list.
+func NewCommandMatcher(allowed []string) *CommandMatcher {
+	m := &CommandMatcher{root: trieNode{entry: -1}, entries: allowed, star: -1}
+	for i, allow := range allowed {
+		if allow == "*" {
+			if m.star < 0 {
+				m.star = i
+			}
+			continue
+		}
+		node := &m.root
+		for j := 0; j < len(allow); j++ {
+			next, ok := node.children[allow[j]]
+			if !ok {
+				if node.children == nil {
+					node.children = make(map[byte]*trieNode)
+				}
+				next = &trieNode{entry: -1}
+				node.children[allow[j]] = next
+			}
+			node = next
+		}
+		if node.entry < 0 {
+			node.entry = i
+		}
+	}
+	return m
+}
+
+// Match returns the allow list entry permitting cmd; when several do, the
+// one listed first.
+func (m *CommandMatcher) Match(cmd string) (string, bool) {
+	best := m.star
+	node := &m.root
+	for i := 0; ; i++ {
+		if node.entry >= 0 && (best < 0 || node.entry < best) {
+			best = node.entry
+		}
+		if i == len(cmd) {
+			break
+		}
+		if node = node.children[cmd[i]]; node == nil {
+			break
+		}
+	}
+	if best < 0 {
+		return "", false
+	}
+	return m.entries[best], true
+}
+
+// globMatcher is an AllowedFileGlobs pattern with fast paths for the common
+// shapes; the rest go to doublestar.
+type globMatcher struct {
+	pattern string
+	kind    globKind
+	literal string
+}
+
+type globKind int
+
+const (
+	globAny     globKind = iota // "**"
+	globLiteral                 // no wildcards
+	globDir                     // "dir/**": dir and everything below it
+	globGeneral
+)
+
+func compileGlob(pattern string) globMatcher {
+	switch {
+	case pattern == "**":
+		return globMatcher{pattern: pattern, kind: globAny}
+	case !strings.ContainsAny(pattern, `*?[{\`):
+		return globMatcher{pattern: pattern, kind: globLiteral, literal: pattern}
+	case strings.HasSuffix(pattern, "/**") && !strings.ContainsAny(strings.TrimSuffix(pattern, "/**"), `*?[{\`):
+		return globMatcher{pattern: pattern, kind: globDir, literal: strings.TrimSuffix(pattern, "/**")}
+	}
+	return globMatcher{pattern: pattern, kind: globGeneral}
+}
+
+func (g globMatcher) match(path string) bool {
+	switch g.kind {
+	case globAny:
+		return true
+	case globLiteral:
+		return path == g.literal
+	case globDir:
+		return path == g.literal || strings.HasPrefix(path, g.literal+"/")
+	}
+	ok, err := doublestar.Match(g.pattern, path)
+	return err == nil && ok
+}
+
+// GlobMatcher is a list of file globs compiled once, such as a task's
+// denied_file_globs.
+type GlobMatcher struct {
+	globs []globMatcher
+}
+
+// NewGlobMatcher compiles patterns.
+func NewGlobMatcher(patterns []string) *GlobMatcher {
+	m := &GlobMatcher{}
+	for _, pattern := range patterns {
+		m.globs = append(m.globs, compileGlob(pattern))
+	}
+	return m
+}
+
+// Empty reports whether m has no patterns; a nil matcher has none.
+func (m *GlobMatcher) Empty() bool {
+	return m == nil || len(m.globs) == 0
+}
+
+// Match returns the first pattern matching path, which should be cleaned
+// with CleanPath. A nil matcher matches nothing.
+func (m *GlobMatcher) Match(path string) (string, bool) {
+	if m == nil {
+		return "", false
+	}
+	for _, glob := range m.globs {
+		if glob.match(path) {
+			return glob.pattern, true
+		}
+	}
+	return "", false
+}
+
+// CleanPath normalizes a path for glob matching: slash-separated, without
+// "." and ".." elements, and relative to the working directory when it is
+// an absolute path below it.
+func CleanPath(path string) string {
+	path = filepath.Clean(path)
+	if filepath.IsAbs(path) {
+		if wd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(wd, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				path = rel
+			}
+		}
+	}
+	return filepath.ToSlash(path)
+}
+
+// maxCachedDecisions bounds each decision cache; a full cache is cleared.
+const maxCachedDecisions = 4096
+
+// decisionCache remembers allow list lookups. A Guard's policy never
+// changes, so a decision holds for the Guard's lifetime; sessions get their
+// own Guard, which makes the cache per session.
+type decisionCache struct {
+	mu        sync.Mutex
+	decisions map[string]decision
+}
+
+type decision struct {
+	match string // The entry or glob that allowed the input
+	ok    bool
+}
+
+func (c *decisionCache) lookup(key string, decide func(string) (string, bool)) (string, bool) {
+	c.mu.Lock()
+	d, found := c.decisions[key]
+	c.mu.Unlock()
+	if found {
+		return d.match, d.ok
+	}
+
+	d.match, d.ok = decide(key)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.decisions == nil || len(c.decisions) >= maxCachedDecisions {
+		c.decisions = make(map[string]decision)
+	}
+	c.decisions[key] = d
+	return d.match, d.ok
+}
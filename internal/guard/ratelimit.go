@@ -0,0 +1,110 @@
+package guard
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket that allows bursts up to the per-minute limit
+// and refills continuously at limit/60 tokens per second.
+type RateLimiter struct {
+	mu       sync.Mutex
+	capacity float64
+	tokens   float64
+	rate     float64 // tokens per second
+	last     time.Time
+	now      func() time.Time
+}
+
+// NewRateLimiter creates a limiter for perMinute requests. A non-positive
+// limit returns nil, which never throttles.
+func NewRateLimiter(perMinute int) *RateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	l := &RateLimiter{
+		capacity: float64(perMinute),
+		tokens:   float64(perMinute),
+		rate:     float64(perMinute) / 60,
+		now:      time.Now,
+	}
+	l.last = l.now()
+	return l
+}
+
+// Reserve takes one token and returns how long the caller must wait before
+// using it. Zero means the request may proceed immediately.
+func (l *RateLimiter) Reserve() time.Duration {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill()
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// Cancel gives back a token taken by Reserve whose request was dropped, so
+// it doesn't delay the requests after it.
+func (l *RateLimiter) Cancel() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill()
+	l.tokens = min(l.tokens+1, l.capacity)
+}
+
+// Take takes one token if one is available and returns zero; otherwise it
+// takes nothing and returns how long until a token is available.
+func (l *RateLimiter) Take() time.Duration {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill()
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
+
+// refill adds the tokens accrued since the last call, up to capacity.
+func (l *RateLimiter) refill() {
+	now := l.now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.capacity {
+		l.tokens = l.capacity
+	}
+	l.last = now
+}
+
+// CheckThrottle returns the violation for a provider request that must
+// wait for its rate limit slot, or nil when wait is zero.
+func (g *Guard) CheckThrottle(wait time.Duration) *Violation {
+	if wait <= 0 {
+		return nil
+	}
+	return g.violation("max_requests_per_minute", "Provider request rate exceeded, throttling for "+wait.Round(time.Millisecond).String())
+}
+
+// CheckToolRate takes a token from limiter, the session's tool call limiter,
+// and returns the violation for a call over max_tool_calls_per_minute.
+func (g *Guard) CheckToolRate(limiter *RateLimiter) *Violation {
+	wait := limiter.Take()
+	if wait <= 0 {
+		return nil
+	}
+	return g.violation("max_tool_calls_per_minute", fmt.Sprintf("Tool call rate of %d per minute exceeded, retry in %s", g.policy.MaxToolCallsPerMinute, wait.Round(time.Second)))
+}
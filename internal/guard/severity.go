@@ -0,0 +1,126 @@
+package guard
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Severity controls how the runtime reacts to a violation.
+type Severity string
+
+const (
+	// SeverityWarn logs the violation and lets the action proceed.
+	SeverityWarn Severity = "warn"
+	// SeverityBlock rejects the offending action but lets the session continue.
+	// Budget rules have no single action to reject, so block behaves like halt for them.
+	SeverityBlock Severity = "block"
+	// SeverityHalt stops the session immediately.
+	SeverityHalt Severity = "halt"
+)
+
+// defaultSeverities reflects the historical behavior: budgets halt the
+// session, while scope violations reject the individual tool call.
+var defaultSeverities = map[string]Severity{
+	"max_iterations":         SeverityHalt,
+	"max_prompt_tokens":      SeverityHalt,
+	"max_output_tokens":      SeverityHalt,
+	"max_cost":               SeverityHalt,
+	"max_thinking_tokens":    SeverityHalt,
+	"max_duration":           SeverityHalt,
+	"max_iteration_duration": SeverityHalt,
+	"allowed_commands":       SeverityBlock,
+	"allowed_file_globs":     SeverityBlock,
+	"denied_file_globs":      SeverityBlock,
+	// An exhausted read budget rejects the read; the digest is still there
+	"max_artifact_read_bytes": SeverityBlock,
+	// An oversized write_file is refused; a shell command has already run,
+	// so only its result is turned into an error
+	"max_write_bytes":         SeverityBlock,
+	"max_session_write_bytes": SeverityBlock,
+	// Flagged content is not written; the agent can write something else
+	"dangerous_content": SeverityBlock,
+	// Git operations are refused; the changes stay in the working tree
+	"git_push":             SeverityBlock,
+	"git_commit":           SeverityBlock,
+	"git_protected_branch": SeverityBlock,
+	// Verification that keeps failing ends the session as verification_exhausted
+	"max_verification_retries": SeverityHalt,
+	// Throttling delays the request rather than rejecting it
+	"max_requests_per_minute": SeverityWarn,
+	// A tool call over the rate is refused; the agent can retry it later
+	"max_tool_calls_per_minute": SeverityBlock,
+}
+
+// Valid reports whether s is a known severity level.
+func (s Severity) Valid() bool {
+	switch s {
+	case SeverityWarn, SeverityBlock, SeverityHalt:
+		return true
+	}
+	return false
+}
+
+// validateSeverities rejects unknown severity levels in the policy.
+func (p Policy) validateSeverities() error {
+	for rule, sev := range p.Severities {
+		if !sev.Valid() {
+			return fmt.Errorf("invalid severity %q for rule %s (use warn, block, or halt)", sev, rule)
+		}
+	}
+	return nil
+}
+
+// severity returns the configured severity for a rule, falling back to the default.
+func (g *Guard) severity(rule string) Severity {
+	if sev, ok := g.policy.Severities[rule]; ok && sev.Valid() {
+		return sev
+	}
+	if sev, ok := defaultSeverities[rule]; ok {
+		return sev
+	}
+	return SeverityHalt
+}
+
+// violation builds a Violation carrying the rule's configured severity.
+func (g *Guard) violation(rule, message string) *Violation {
+	sev := g.severity(rule)
+	return &Violation{Rule: rule, Message: message, Severity: sev, Fatal: sev == SeverityHalt}
+}
+
+// rank orders severities from least to most severe.
+func (s Severity) rank() int {
+	switch s {
+	case SeverityWarn:
+		return 0
+	case SeverityBlock:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// mostSevere returns the highest-severity violation, preferring the first on ties.
+func mostSevere(violations []*Violation) *Violation {
+	var worst *Violation
+	for _, v := range violations {
+		if worst == nil || v.Severity.rank() > worst.Severity.rank() {
+			worst = v
+		}
+	}
+	return worst
+}
+
+// ViolationError wraps a violation that must halt the session.
+type ViolationError struct {
+	Violation *Violation
+}
+
+func (e *ViolationError) Error() string {
+	return fmt.Sprintf("guard violation (%s): %s", e.Violation.Rule, e.Violation.Message)
+}
+
+// IsHalt reports whether err carries a halting guard violation.
+func IsHalt(err error) bool {
+	var ve *ViolationError
+	return errors.As(err, &ve)
+}
@@ -0,0 +1,122 @@
+package guard
+
+import (
+	"maps"
+	"slices"
+	"time"
+)
+
+// Tighten returns p restricted by q, a policy decoded over p (so the keys q
+// doesn't set equal p's): no rule of the result is looser than in p. Allow
+// lists keep what both permit, deny lists and protected branches add up,
+// blocking flags stay set when either sets them, limits take the lower
+// one, and severities the higher one. The result keeps p's Params and
+// Env.Path, which only q's owner could use to loosen it.
+func (p Policy) Tighten(q Policy) Policy {
+	t := p
+	t.MaxIterations = lowerLimit(p.MaxIterations, q.MaxIterations)
+	t.MaxPromptTokens = lowerLimit(p.MaxPromptTokens, q.MaxPromptTokens)
+	t.MaxOutputTokens = lowerLimit(p.MaxOutputTokens, q.MaxOutputTokens)
+	t.MaxDigestTokens = lowerLimit(p.MaxDigestTokens, q.MaxDigestTokens)
+	t.MaxArtifactReadBytes = lowerLimit(p.MaxArtifactReadBytes, q.MaxArtifactReadBytes)
+	t.MaxWriteBytes = lowerLimit(p.MaxWriteBytes, q.MaxWriteBytes)
+	t.MaxSessionWriteBytes = lowerLimit(p.MaxSessionWriteBytes, q.MaxSessionWriteBytes)
+	t.MaxRequestsPerMinute = lowerLimit(p.MaxRequestsPerMinute, q.MaxRequestsPerMinute)
+	t.MaxToolCallsPerMinute = lowerLimit(p.MaxToolCallsPerMinute, q.MaxToolCallsPerMinute)
+	t.MaxCost = lowerLimit(p.MaxCost, q.MaxCost)
+	t.MaxThinkingTokens = lowerLimit(p.MaxThinkingTokens, q.MaxThinkingTokens)
+	t.MaxDuration = lowerLimit(p.MaxDuration, q.MaxDuration)
+	t.MaxIterationDuration = lowerLimit(p.MaxIterationDuration, q.MaxIterationDuration)
+	t.MaxVerificationRetries = lowerLimit(p.MaxVerificationRetries, q.MaxVerificationRetries)
+
+	commands := NewCommandMatcher(p.AllowedCommands)
+	qCommands := NewCommandMatcher(q.AllowedCommands)
+	t.AllowedCommands = intersect(p.AllowedCommands, q.AllowedCommands,
+		func(c string) bool { _, ok := commands.Match(c); return ok },
+		func(c string) bool { _, ok := qCommands.Match(c); return ok })
+	files := NewGlobMatcher(p.AllowedFileGlobs)
+	qFiles := NewGlobMatcher(q.AllowedFileGlobs)
+	t.AllowedFileGlobs = intersect(p.AllowedFileGlobs, q.AllowedFileGlobs,
+		func(g string) bool { _, ok := files.Match(g); return ok },
+		func(g string) bool { _, ok := qFiles.Match(g); return ok })
+	t.DeniedFileGlobs = union(p.DeniedFileGlobs, q.DeniedFileGlobs)
+	t.BlockDangerousCmd = p.BlockDangerousCmd || q.BlockDangerousCmd
+
+	if p.Sandbox == "" || p.Sandbox == "none" {
+		t.Sandbox = q.Sandbox
+	}
+	t.SandboxNetwork = p.SandboxNetwork && q.SandboxNetwork
+
+	t.Env.Allow = intersect(p.Env.Allow, q.Env.Allow,
+		func(name string) bool { return matchEnv(p.Env.Allow, name) },
+		func(name string) bool { return matchEnv(q.Env.Allow, name) })
+	t.Env.Deny = union(p.Env.Deny, q.Env.Deny)
+
+	t.Git.AllowPush = p.Git.AllowPush && q.Git.AllowPush
+	t.Git.AllowCommit = p.Git.AllowCommit && q.Git.AllowCommit
+	t.Git.ProtectedBranches = union(p.Git.ProtectedBranches, q.Git.ProtectedBranches)
+
+	t.Content.Builtins = p.Content.Builtins || q.Content.Builtins
+	t.Content.Deny = union(p.Content.Deny, q.Content.Deny)
+	t.Content.Approve = p.Content.Approve && q.Content.Approve
+	switch {
+	case p.Content.MaxBase64Bytes > 0 && q.Content.MaxBase64Bytes > 0:
+		t.Content.MaxBase64Bytes = min(p.Content.MaxBase64Bytes, q.Content.MaxBase64Bytes)
+	case p.Content.MaxBase64Bytes <= 0 && q.Content.MaxBase64Bytes > 0:
+		t.Content.MaxBase64Bytes = q.Content.MaxBase64Bytes
+	case p.Content.MaxBase64Bytes < 0 && q.Content.MaxBase64Bytes == 0:
+		t.Content.MaxBase64Bytes = 0
+	}
+
+	t.Severities = maps.Clone(p.Severities)
+	for rule, sev := range q.Severities {
+		if sev.rank() > policySeverity(p, rule).rank() {
+			if t.Severities == nil {
+				t.Severities = make(map[string]Severity)
+			}
+			t.Severities[rule] = sev
+		}
+	}
+	return t
+}
+
+// lowerLimit returns the stricter of two limits where 0 means unlimited.
+func lowerLimit[T int | int64 | float64 | time.Duration](a, b T) T {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// intersect returns the entries of a that b permits followed by those of b
+// that a permits, without duplicates.
+func intersect(a, b []string, aPermits, bPermits func(string) bool) []string {
+	out := []string{}
+	for _, entry := range a {
+		if bPermits(entry) && !slices.Contains(out, entry) {
+			out = append(out, entry)
+		}
+	}
+	for _, entry := range b {
+		if aPermits(entry) && !slices.Contains(out, entry) {
+			out = append(out, entry)
+		}
+	}
+	return out
+}
+
+// union returns the entries of a followed by those of b not in a.
+func union(a, b []string) []string {
+	out := slices.Clone(a)
+	for _, entry := range b {
+		if !slices.Contains(out, entry) {
+			out = append(out, entry)
+		}
+	}
+	return out
+}
+
+// policySeverity returns the severity p gives rule, as Guard.severity does.
+func policySeverity(p Policy, rule string) Severity {
+	return (&Guard{policy: p}).severity(rule)
+}
@@ -0,0 +1,352 @@
+package guard
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/felixgeelhaar/simon/internal/provider"
+)
+
+// SessionState is what CheckToolCall needs to know about the session a
+// call belongs to.
+type SessionState struct {
+	// DeniedFiles are the session's own denied globs, on top of the
+	// policy's; nil adds none.
+	DeniedFiles *GlobMatcher
+	// WrittenBytes is the workspace growth already charged to the session's
+	// disk quota.
+	WrittenBytes int64
+	// ToolCalls paces the session's calls by max_tool_calls_per_minute
+	// (NewRateLimiter(policy.MaxToolCallsPerMinute)); nil doesn't limit them.
+	ToolCalls *RateLimiter
+}
+
+// CheckToolCall evaluates every rule that applies to call before it runs:
+// the session's tool call rate, which takes a token from state.ToolCalls,
+// for every tool; the command, the paths in its arguments, and, while files
+// are denied, the files it reaches without naming them for run_shell; the
+// paths, the write limit, the disk quota, and the content policy for
+// write_file; and the paths given to the git tools. It returns all
+// violations, in the order found; the caller decides from their severities
+// whether the call proceeds.
+//
+// Rules that depend on a call's outcome, such as the workspace growth of a
+// shell command or the range read_artifact returns, and those that depend on
+// the repository's state, such as protected branches, are checked by the
+// tools themselves. Calls whose arguments don't parse pass the per-tool
+// rules; the tool reports them.
+func (g *Guard) CheckToolCall(call provider.ToolCall, state SessionState) []*Violation {
+	var violations []*Violation
+	add := func(v *Violation) {
+		if v != nil {
+			violations = append(violations, v)
+		}
+	}
+
+	add(g.CheckToolRate(state.ToolCalls))
+
+	switch call.Name {
+	case "run_shell":
+		var args struct {
+			Cmd interface{} `json:"cmd"`
+			Dir string      `json:"dir"`
+		}
+		if err := json.Unmarshal([]byte(call.Args), &args); err != nil {
+			return violations
+		}
+		var cmdStr string
+		switch v := args.Cmd.(type) {
+		case string:
+			cmdStr = v
+		case []interface{}:
+			var parts []string
+			for _, s := range v {
+				parts = append(parts, fmt.Sprint(s))
+			}
+			cmdStr = strings.Join(parts, " ")
+		}
+		words, err := SplitCommand(cmdStr)
+		if err != nil {
+			return violations
+		}
+		add(g.CheckCommand(words[0]))
+		// Arguments naming denied files are refused, so a command cannot
+		// read or change what write_file may not
+		for _, arg := range words[1:] {
+			if strings.HasPrefix(arg, "-") || arg == ">" || arg == "<" {
+				continue
+			}
+			add(g.CheckDeniedFile(strings.TrimLeft(arg, "<>"), state.DeniedFiles))
+		}
+		if args.Dir != "" {
+			add(g.CheckDeniedFile(args.Dir, state.DeniedFiles))
+		}
+		if !g.denied.Empty() || !state.DeniedFiles.Empty() {
+			add(g.checkShellPaths(words, args.Dir, state.DeniedFiles))
+		}
+
+	case "write_file":
+		var args struct {
+			Path    string `json:"path"`
+			Content string `json:"content"`
+		}
+		if err := json.Unmarshal([]byte(call.Args), &args); err != nil || args.Path == "" {
+			return violations
+		}
+		add(g.CheckDeniedFile(args.Path, state.DeniedFiles))
+		add(g.CheckAllowedFile(args.Path))
+		add(g.CheckWrite(int64(len(args.Content))))
+		// Only growth counts toward the disk quota, so rewriting a file in
+		// place doesn't use it up
+		grow := int64(len(args.Content))
+		if info, err := os.Stat(args.Path); err == nil {
+			grow -= info.Size()
+		}
+		add(g.CheckDiskQuota(state.WrittenBytes, max(grow, 0)))
+		add(g.CheckContent(args.Path, args.Content))
+
+	case "git_diff":
+		var args struct {
+			Path string `json:"path"`
+		}
+		if err := json.Unmarshal([]byte(call.Args), &args); err == nil && args.Path != "" {
+			add(g.CheckDeniedFile(args.Path, state.DeniedFiles))
+		}
+
+	case "git_commit":
+		var args struct {
+			Paths string `json:"paths"`
+		}
+		if err := json.Unmarshal([]byte(call.Args), &args); err != nil {
+			return violations
+		}
+		for _, path := range strings.Split(args.Paths, ",") {
+			if path = strings.TrimSpace(path); path != "" {
+				add(g.CheckDeniedFile(path, state.DeniedFiles))
+			}
+		}
+	}
+	return violations
+}
+
+// checkShellPaths covers, while denied globs are set, the files a shell
+// command reaches without naming them as arguments: the path of a git
+// rev:path, the files a glob expands to, and whole directories walked by
+// recursive commands, which are refused. Command substitution never reaches
+// a command (it is blocked under bash and left unexpanded otherwise).
+func (g *Guard) checkShellPaths(words []string, dir string, extra *GlobMatcher) *Violation {
+	for _, arg := range words[1:] {
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		if _, path, ok := strings.Cut(arg, ":"); ok && path != "" && !strings.Contains(arg, "://") {
+			if v := g.CheckDeniedFile(path, extra); v != nil {
+				return v
+			}
+		}
+		if strings.ContainsAny(arg, "*?[") {
+			pattern := strings.TrimLeft(arg, "<>")
+			if dir != "" && !filepath.IsAbs(pattern) {
+				pattern = filepath.Join(dir, pattern)
+			}
+			matches, _ := filepath.Glob(pattern)
+			for _, match := range matches {
+				if v := g.CheckDeniedFile(match, extra); v != nil {
+					return v
+				}
+			}
+		}
+	}
+
+	reason := walksTree(words, dir)
+	if reason == "" {
+		return nil
+	}
+	cmd := strings.Join(words, " ")
+	v := g.violation("denied_file_globs", fmt.Sprintf("%s %s, which may include files denied by denied_file_globs; name the files instead", cmd, reason))
+	v.Subject = cmd
+	return v
+}
+
+// walksTree returns how a command reaches files it does not name, such as
+// "searches directories recursively", or "" when it only touches its
+// arguments.
+func walksTree(words []string, dir string) string {
+	args := words[1:]
+	switch filepath.Base(words[0]) {
+	case "grep", "egrep", "fgrep":
+		if hasFlag(args, "rR", "--recursive", "--dereference-recursive") {
+			return "searches directories recursively"
+		}
+	case "cp", "scp":
+		if hasFlag(args, "rRa", "--recursive", "--archive") {
+			return "copies directories recursively"
+		}
+	case "find", "rg", "ag", "ack", "tar", "zip", "rsync":
+		return "walks directories"
+	case "git":
+		return gitWalksTree(args, dir)
+	}
+	return ""
+}
+
+// gitWalksTree is walksTree for git: the subcommands that show or stage
+// file contents reach the whole tree unless they are given files (or
+// rev:path blobs), and git_diff and git_commit are the way to do so
+// without denied files.
+func gitWalksTree(args []string, dir string) string {
+	i := 0
+	for ; i < len(args) && strings.HasPrefix(args[i], "-"); i++ {
+		switch args[i] {
+		case "-C", "-c", "--git-dir", "--work-tree", "--namespace":
+			i++
+		}
+	}
+	if i >= len(args) {
+		return ""
+	}
+	sub, rest := args[i], args[i+1:]
+	const tools = " (git_diff and git_commit leave denied files out)"
+	switch sub {
+	case "add":
+		if hasFlag(rest, "Au", "--all", "--update") || !namesFiles(rest, dir) {
+			return "stages whole directories" + tools
+		}
+	case "commit":
+		if hasFlag(rest, "a", "--all") {
+			return "commits every changed file" + tools
+		}
+	case "log":
+		if hasFlag(rest, "p", "--patch") && !namesFiles(rest, dir) {
+			return "shows every changed file" + tools
+		}
+	case "diff", "show", "grep", "archive", "whatchanged", "format-patch":
+		if !namesFiles(rest, dir) {
+			return "shows every changed file" + tools
+		}
+	}
+	return ""
+}
+
+// namesFiles reports whether the operands of a git subcommand name files:
+// the pathspecs after "--", or else the operands that are existing files
+// or rev:path blobs. "." and directories name whole trees.
+func namesFiles(args []string, dir string) bool {
+	operands, pathspecs := args, false
+	for i, arg := range args {
+		if arg == "--" {
+			operands, pathspecs = args[i+1:], true
+			break
+		}
+	}
+	named := false
+	for _, arg := range operands {
+		if strings.HasPrefix(arg, "-") && !pathspecs {
+			continue
+		}
+		if strings.Contains(arg, ":") && !pathspecs {
+			named = true
+			continue
+		}
+		path := arg
+		if dir != "" && !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		info, err := os.Stat(path)
+		switch {
+		case filepath.Clean(arg) == "." || err == nil && info.IsDir():
+			return false
+		case err == nil || pathspecs:
+			named = true
+		}
+	}
+	return named
+}
+
+// hasFlag reports whether args, up to "--", set one of the single-letter
+// options in short (also within a group such as -rn) or one of long.
+func hasFlag(args []string, short string, long ...string) bool {
+	for _, arg := range args {
+		switch {
+		case arg == "--":
+			return false
+		case strings.HasPrefix(arg, "--"):
+			name, _, _ := strings.Cut(arg, "=")
+			if slices.Contains(long, name) {
+				return true
+			}
+		case strings.HasPrefix(arg, "-") && strings.ContainsAny(arg[1:], short):
+			return true
+		}
+	}
+	return false
+}
+
+// SplitCommand splits a command line into words the way a shell would for
+// a simple command: quotes group words and a backslash escapes the next
+// character, but nothing is expanded.
+func SplitCommand(cmdStr string) ([]string, error) {
+	cmdStr = strings.TrimSpace(cmdStr)
+	if cmdStr == "" {
+		return nil, fmt.Errorf("empty command")
+	}
+
+	var words []string
+	var current strings.Builder
+	inSingleQuote := false
+	inDoubleQuote := false
+	escaped := false
+
+	for i := 0; i < len(cmdStr); i++ {
+		c := cmdStr[i]
+
+		if escaped {
+			current.WriteByte(c)
+			escaped = false
+			continue
+		}
+
+		if c == '\\' && !inSingleQuote {
+			escaped = true
+			continue
+		}
+
+		if c == '\'' && !inDoubleQuote {
+			inSingleQuote = !inSingleQuote
+			continue
+		}
+
+		if c == '"' && !inSingleQuote {
+			inDoubleQuote = !inDoubleQuote
+			continue
+		}
+
+		if c == ' ' && !inSingleQuote && !inDoubleQuote {
+			if current.Len() > 0 {
+				words = append(words, current.String())
+				current.Reset()
+			}
+			continue
+		}
+
+		current.WriteByte(c)
+	}
+
+	if current.Len() > 0 {
+		words = append(words, current.String())
+	}
+
+	if inSingleQuote || inDoubleQuote {
+		return nil, fmt.Errorf("unclosed quote in command")
+	}
+
+	if len(words) == 0 {
+		return nil, fmt.Errorf("no command specified")
+	}
+
+	return words, nil
+}
@@ -0,0 +1,141 @@
+package mcp
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+// artifactQueueSize bounds the tool outputs waiting to be written; once it
+// is full, storing another one waits for the writer.
+const artifactQueueSize = 64
+
+// artifactWriter stores the tool outputs of one session in the background,
+// so a slow disk doesn't add to the latency of every tool call. Writes
+// happen in the order they were queued. FlushArtifacts waits for them and
+// reports the session's failures; the runtime calls it at the end of every
+// iteration, before persisting the history that refers to the outputs, and
+// CloseArtifacts when the session ends, which stops the writer.
+type artifactWriter struct {
+	store store.Storage
+	queue chan queuedArtifact
+	start sync.Once
+	exit  chan struct{} // closed when run returns
+
+	mu      sync.Mutex
+	done    *sync.Cond
+	pending int   // queued writes not yet finished
+	err     error // first failure since the last flush
+}
+
+type queuedArtifact struct {
+	artifact *store.Artifact
+	content  []byte
+}
+
+func newArtifactWriter(s store.Storage) *artifactWriter {
+	w := &artifactWriter{store: s, queue: make(chan queuedArtifact, artifactQueueSize), exit: make(chan struct{})}
+	w.done = sync.NewCond(&w.mu)
+	return w
+}
+
+// save queues an artifact for writing.
+func (w *artifactWriter) save(artifact *store.Artifact, content []byte) {
+	w.start.Do(func() { go w.run() })
+	w.mu.Lock()
+	w.pending++
+	w.mu.Unlock()
+	w.queue <- queuedArtifact{artifact: artifact, content: content}
+}
+
+func (w *artifactWriter) run() {
+	defer close(w.exit)
+	for q := range w.queue {
+		err := w.store.SaveArtifact(q.artifact, q.content)
+		w.mu.Lock()
+		if err != nil && w.err == nil {
+			w.err = fmt.Errorf("failed to save artifact %s: %w", q.artifact.ID, err)
+		}
+		w.pending--
+		if w.pending == 0 {
+			w.done.Broadcast()
+		}
+		w.mu.Unlock()
+	}
+}
+
+// wait blocks until every queued write has finished.
+func (w *artifactWriter) wait() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for w.pending > 0 {
+		w.done.Wait()
+	}
+}
+
+// flush waits for the queued writes and returns the first failure since the
+// previous flush.
+func (w *artifactWriter) flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for w.pending > 0 {
+		w.done.Wait()
+	}
+	err := w.err
+	w.err = nil
+	return err
+}
+
+// close writes what is queued, stops the writer, and returns the first
+// failure since the previous flush. Nothing may be saved after it.
+func (w *artifactWriter) close() error {
+	close(w.queue)
+	started := true
+	w.start.Do(func() { started = false })
+	if started {
+		<-w.exit
+	}
+	return w.flush()
+}
+
+// artifactWriter returns the writer of the session's tool outputs, starting
+// one when the session has none.
+func (p *Proxy) artifactWriter(sessionID string) *artifactWriter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	w, ok := p.artifacts[sessionID]
+	if !ok {
+		w = newArtifactWriter(p.store)
+		p.artifacts[sessionID] = w
+	}
+	return w
+}
+
+// FlushArtifacts waits until the session's tool outputs of HandleToolCalls
+// are stored and returns the first of its writes that failed since the
+// previous call.
+func (p *Proxy) FlushArtifacts(sessionID string) error {
+	p.mu.RLock()
+	w, ok := p.artifacts[sessionID]
+	p.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+	return w.flush()
+}
+
+// CloseArtifacts stores the session's remaining tool outputs, stops their
+// writer, and returns the first write that failed since the previous
+// flush. Call it when the session ends; a later call of the session's
+// starts a new writer.
+func (p *Proxy) CloseArtifacts(sessionID string) error {
+	p.mu.Lock()
+	w, ok := p.artifacts[sessionID]
+	delete(p.artifacts, sessionID)
+	p.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return w.close()
+}
@@ -0,0 +1,200 @@
+package mcp
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/felixgeelhaar/simon/internal/guard"
+)
+
+// maxAllowedListed bounds the alternatives listed in a corrective message.
+const maxAllowedListed = 20
+
+// BlockedError is returned for a tool call refused by the policy, a
+// dangerous-pattern check, or the task spec. Its message is written for the
+// model: the rule, what it permits, and how to get the work done without
+// repeating the call.
+type BlockedError struct {
+	Rule       string   // e.g. allowed_commands, dangerous_pattern, allowed_file_globs
+	Reason     string   // What was refused
+	Allowed    []string // What the rule permits, when it is a list
+	Suggestion string
+}
+
+func (e *BlockedError) Error() string {
+	var b strings.Builder
+	b.WriteString("blocked by " + e.Rule + ": " + e.Reason)
+	if len(e.Allowed) > 0 {
+		allowed := e.Allowed
+		more := ""
+		if len(allowed) > maxAllowedListed {
+			more = ", ..."
+			allowed = allowed[:maxAllowedListed]
+		}
+		b.WriteString("\nAllowed: " + strings.Join(allowed, ", ") + more)
+	}
+	if e.Suggestion != "" {
+		b.WriteString("\nSuggestion: " + e.Suggestion)
+	}
+	b.WriteString("\nThe same call will be blocked again; change the approach instead of retrying it.")
+	return b.String()
+}
+
+// IsBlocked reports whether err is a refused tool call.
+func IsBlocked(err error) bool {
+	var be *BlockedError
+	return errors.As(err, &be)
+}
+
+// commandAlternatives are commands, or tools, that do the job of commonly
+// blocked ones.
+var commandAlternatives = map[string][]string{
+	"find":  {"ls", "git ls-files", "grep -r"},
+	"head":  {"cat"},
+	"tail":  {"cat"},
+	"less":  {"cat"},
+	"more":  {"cat"},
+	"rg":    {"grep -r"},
+	"ag":    {"grep -r"},
+	"ack":   {"grep -r"},
+	"sed":   {"write_file"},
+	"awk":   {"grep"},
+	"touch": {"write_file"},
+	"tee":   {"write_file"},
+	"cp":    {"cat", "write_file"},
+	"mv":    {"cat", "write_file", "git mv"},
+	"vi":    {"write_file"},
+	"vim":   {"write_file"},
+	"nano":  {"write_file"},
+	"make":  {"go build", "go test"},
+	"pwd":   {"ls"},
+}
+
+// commandBlocked describes a command refused by an allow list. The allowed
+// list is what the rule permits; alternatives to the command are suggested
+// when allowed passes them.
+func commandBlocked(rule, cmdName string, allowed []string, allow func(string) bool) *BlockedError {
+	e := &BlockedError{
+		Rule:    rule,
+		Reason:  "the command " + cmdName + " is not allowed",
+		Allowed: sortedCopy(allowed),
+	}
+	var usable []string
+	for _, alt := range commandAlternatives[cmdName] {
+		if alt == "write_file" || allow(strings.Fields(alt)[0]) {
+			usable = append(usable, alt)
+		}
+	}
+	if len(usable) > 0 {
+		e.Suggestion = "use " + strings.Join(usable, " or ") + " instead of " + cmdName + "."
+	} else {
+		e.Suggestion = "do this with one of the allowed commands, or with write_file for creating and editing files."
+	}
+	return e
+}
+
+// guardBlocked describes a block-severity guard violation. Rules with an
+// allow list name what it permits.
+func (p *Proxy) guardBlocked(v *guard.Violation, scope Scope) *BlockedError {
+	policy := p.guard.Policy()
+	switch v.Rule {
+	case "allowed_commands":
+		return commandBlocked(v.Rule, v.Subject, policy.AllowedCommands, func(cmd string) bool {
+			if _, ok := p.guard.MatchCommand(cmd); !ok {
+				return false
+			}
+			if scope.commands != nil {
+				_, ok := scope.commands.Match(cmd)
+				return ok
+			}
+			return true
+		})
+	case "allowed_file_globs":
+		return &BlockedError{
+			Rule:       v.Rule,
+			Reason:     "writing " + v.Subject + " is not allowed",
+			Allowed:    policy.AllowedFileGlobs,
+			Suggestion: "write to a path matching one of the allowed globs, or report that the change needs a file outside them.",
+		}
+	case "denied_file_globs":
+		return &BlockedError{
+			Rule:       v.Rule,
+			Reason:     v.Message,
+			Suggestion: "leave this file alone; it is off-limits to every tool. If the task cannot be done without it, report that instead.",
+		}
+	case "git_push":
+		return &BlockedError{
+			Rule:       v.Rule,
+			Reason:     v.Message,
+			Suggestion: "keep the commits local; pushing is left to the user.",
+		}
+	case "git_commit":
+		return &BlockedError{
+			Rule:       v.Rule,
+			Reason:     v.Message,
+			Suggestion: "leave the changes uncommitted for the user to review; git_diff shows them.",
+		}
+	case "git_protected_branch":
+		return &BlockedError{
+			Rule:       v.Rule,
+			Reason:     v.Message,
+			Allowed:    []string{"any branch but " + strings.Join(policy.Git.ProtectedBranches, ", ")},
+			Suggestion: "create a branch of your own with git_branch (create \"true\") and commit there.",
+		}
+	case "max_artifact_read_bytes":
+		return &BlockedError{
+			Rule:       v.Rule,
+			Reason:     v.Message,
+			Suggestion: "read a smaller line range, or work from the digest you already have.",
+		}
+	case "max_write_bytes":
+		return &BlockedError{
+			Rule:       v.Rule,
+			Reason:     v.Message,
+			Suggestion: "split the change into smaller files, and leave large generated output to the build instead of writing it into the workspace.",
+		}
+	case "dangerous_content":
+		return &BlockedError{
+			Rule:       v.Rule,
+			Reason:     v.Message,
+			Suggestion: "write the file without the flagged content; if the task needs it, report that to the user instead of working around the check.",
+		}
+	case "max_session_write_bytes":
+		return &BlockedError{
+			Rule:       v.Rule,
+			Reason:     v.Message,
+			Suggestion: "stop creating files; finish with the changes already made, or report that the task needs more disk space than the policy allows.",
+		}
+	case "max_tool_calls_per_minute":
+		return &BlockedError{
+			Rule:       v.Rule,
+			Reason:     v.Message,
+			Suggestion: "make fewer, larger calls: work from the digests you have, and batch related changes into one write_file.",
+		}
+	}
+	return &BlockedError{Rule: v.Rule, Reason: v.Message}
+}
+
+// patternBlocked describes a command refused by a dangerous-pattern check.
+func patternBlocked(rule, cmdStr string, pattern *regexp.Regexp) *BlockedError {
+	e := &BlockedError{Rule: rule, Reason: "the command " + cmdStr + " matches the blocked pattern " + pattern.String()}
+	switch pattern.String() {
+	case `;\s*\w`, `\|[^|]`, `\|\|`, `&&`:
+		e.Suggestion = "run each command as its own run_shell call instead of chaining or piping them, and filter output with grep as a separate call."
+	case `>>`:
+		e.Suggestion = "use write_file with the complete new content instead of appending with a redirect."
+	case `\$\(`, "`", `\$\{`, `<\(`:
+		e.Suggestion = "run the inner command on its own first, then pass its output literally."
+	default:
+		e.Suggestion = "achieve the same result with plain commands from the allowed list, or with write_file for file changes."
+	}
+	return e
+}
+
+func sortedCopy(list []string) []string {
+	out := append([]string(nil), list...)
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,88 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/felixgeelhaar/simon/internal/provider"
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+// maxArtifactDiffSize bounds the artifacts diff_artifacts will compare.
+const maxArtifactDiffSize = 1 << 20
+
+// ResolveArtifact finds an artifact by ID or by the storage path that tool
+// digests report (artifacts/<session>/<name>). A non-empty sessionID limits
+// the lookup to that session's artifacts.
+func ResolveArtifact(s store.Storage, sessionID, ref string) (*store.Artifact, []byte, error) {
+	if a, content, err := s.GetArtifact(ref); err == nil {
+		if sessionID != "" && a.SessionID != sessionID {
+			return nil, nil, fmt.Errorf("artifact %s belongs to another session", ref)
+		}
+		return a, content, nil
+	}
+
+	ref = path.Clean(ref)
+	owner := sessionID
+	if owner == "" {
+		parts := strings.SplitN(ref, "/", 3)
+		if len(parts) < 3 || parts[0] != "artifacts" {
+			return nil, nil, fmt.Errorf("artifact not found: %s", ref)
+		}
+		owner = parts[1]
+	}
+	artifacts, err := s.ListArtifacts(owner)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, a := range artifacts {
+		if a.Path == ref {
+			return s.GetArtifact(a.ID)
+		}
+	}
+	return nil, nil, fmt.Errorf("artifact not found: %s", ref)
+}
+
+// DiffArtifacts returns a unified diff between two artifacts, or an empty
+// string when their contents are identical.
+func DiffArtifacts(s store.Storage, sessionID, from, to string) (string, error) {
+	a, before, err := ResolveArtifact(s, sessionID, from)
+	if err != nil {
+		return "", err
+	}
+	b, after, err := ResolveArtifact(s, sessionID, to)
+	if err != nil {
+		return "", err
+	}
+	if !a.IsText() || !b.IsText() {
+		return "", fmt.Errorf("binary artifacts cannot be diffed")
+	}
+	if len(before) > maxArtifactDiffSize || len(after) > maxArtifactDiffSize {
+		return "", fmt.Errorf("artifacts larger than %d bytes cannot be diffed", maxArtifactDiffSize)
+	}
+	return LabeledDiff(a.Path, b.Path, string(before), string(after)), nil
+}
+
+// diffArtifacts handles the diff_artifacts tool, limited to the session's own artifacts.
+func (p *Proxy) diffArtifacts(sessionID string, call provider.ToolCall) (string, error) {
+	var args struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+	if err := json.Unmarshal([]byte(call.Args), &args); err != nil {
+		return "", fmt.Errorf("invalid args: %w", err)
+	}
+	if args.From == "" || args.To == "" {
+		return "", fmt.Errorf("missing from or to argument")
+	}
+	diff, err := DiffArtifacts(p.store, sessionID, args.From, args.To)
+	if err != nil {
+		return "", err
+	}
+	if diff == "" {
+		return fmt.Sprintf("%s and %s are identical", args.From, args.To), nil
+	}
+	return diff, nil
+}
@@ -0,0 +1,277 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/felixgeelhaar/simon/internal/guard"
+	"github.com/felixgeelhaar/simon/internal/provider"
+)
+
+// Check is one step of the validation a tool call goes through.
+type Check struct {
+	Rule   string
+	Passed bool
+	// Severity is set for failed guard rules; warn-level failures don't deny the call.
+	Severity guard.Severity
+	Detail   string
+}
+
+// Explanation reports whether a tool call would be allowed and which checks
+// decided it. The proxy's own checks stop at the first one that denies the
+// call; the guard's rules are then all reported.
+type Explanation struct {
+	Tool    string
+	Allowed bool
+	Checks  []Check
+}
+
+// guardRule is a rule of Guard.CheckToolCall that applies to a call;
+// passed describes it when the call doesn't violate it.
+type guardRule struct {
+	rule   string
+	passed func() string
+}
+
+// Explain runs the policy checks of a tool call without executing it, as
+// `simon policy test` does. scope holds the task spec restrictions to apply
+// (its AllowedCommands and DeniedFileGlobs); the guard's rules are those
+// Guard.CheckToolCall applies, for a session that hasn't written anything
+// or called a tool yet. User approval is not considered.
+func (p *Proxy) Explain(call provider.ToolCall, scope Scope) Explanation {
+	e := Explanation{Tool: call.Name, Allowed: true}
+	scope = scope.compile()
+	var rules []guardRule
+	switch call.Name {
+	case "run_shell":
+		rules = p.explainShell(&e, call.Args, scope)
+	case "write_file":
+		rules = p.explainWrite(&e, call.Args, scope)
+	case "read_artifact":
+		if limit := p.guard.Policy().MaxArtifactReadBytes; limit > 0 {
+			e.pass("max_artifact_read_bytes", fmt.Sprintf("reads are allowed until the session has read %d bytes", limit))
+		} else {
+			e.pass("max_artifact_read_bytes", "reads are not limited")
+		}
+	case "git_diff":
+		rules = p.explainPaths("path", scope)
+	case "git_commit":
+		p.explainCommit(&e)
+		rules = p.explainPaths("paths", scope)
+	case "diff_artifacts", "spawn_subtask", "verify_evidence", "git_status", "git_branch":
+	default:
+		e.fail("tool", "", "unknown tool: "+call.Name)
+		return e
+	}
+	if !e.Allowed {
+		return e
+	}
+	if limit := p.guard.Policy().MaxToolCallsPerMinute; limit > 0 {
+		rules = append(rules, guardRule{"max_tool_calls_per_minute", func() string {
+			return fmt.Sprintf("a session may call %d tools per minute", limit)
+		}})
+	}
+	if len(rules) == 0 && len(e.Checks) == 0 {
+		e.pass("tool", "no policy rules apply to "+call.Name)
+		return e
+	}
+	p.explainGuard(&e, call, scope, rules)
+	return e
+}
+
+// explainGuard records the outcome of the guard's rules for call: every
+// violation CheckToolCall returns, and a pass for each of rules it doesn't
+// violate.
+func (p *Proxy) explainGuard(e *Explanation, call provider.ToolCall, scope Scope, rules []guardRule) {
+	violations := p.guard.CheckToolCall(call, guard.SessionState{DeniedFiles: scope.denied})
+	for _, r := range rules {
+		violated := false
+		for _, v := range violations {
+			if v.Rule == r.rule {
+				e.fail(v.Rule, v.Severity, v.Message)
+				violated = true
+			}
+		}
+		if !violated {
+			e.pass(r.rule, r.passed())
+		}
+	}
+}
+
+// denying reports whether any file is denied to calls within scope.
+func (p *Proxy) denying(scope Scope) bool {
+	return len(p.guard.Policy().DeniedFileGlobs) > 0 || len(scope.DeniedFileGlobs) > 0
+}
+
+func (p *Proxy) explainShell(e *Explanation, rawArgs string, scope Scope) []guardRule {
+	var args struct {
+		Cmd interface{} `json:"cmd"`
+		Dir string      `json:"dir"`
+	}
+	if err := json.Unmarshal([]byte(rawArgs), &args); err != nil {
+		e.fail("args", "", "invalid args: "+err.Error())
+		return nil
+	}
+	var cmdStr string
+	switch v := args.Cmd.(type) {
+	case string:
+		cmdStr = v
+	case []interface{}:
+		var parts []string
+		for _, s := range v {
+			parts = append(parts, fmt.Sprint(s))
+		}
+		cmdStr = strings.Join(parts, " ")
+	default:
+		e.fail("args", "", "cmd must be a string or array of strings")
+		return nil
+	}
+
+	if args.Dir != "" {
+		if _, err := p.sanitizeWorkDir(args.Dir); err != nil {
+			e.fail("working_directory", "", err.Error())
+			return nil
+		}
+		e.pass("working_directory", args.Dir+" is inside the working directory")
+	}
+	if !e.patterns("dangerous_pattern", dangerousPatterns, cmdStr) {
+		return nil
+	}
+	cmdName, _, err := p.parseCommand(cmdStr)
+	if err != nil {
+		e.fail("parse", "", err.Error())
+		return nil
+	}
+	if scope.commands != nil {
+		if allow, ok := scope.commands.Match(cmdName); ok {
+			e.pass("task spec allowed_commands", fmt.Sprintf("%s is allowed by entry %q", cmdName, allow))
+		} else {
+			e.fail("task spec allowed_commands", "", cmdName+" is not in the task spec's allowed_commands")
+			return nil
+		}
+	}
+	if strings.ContainsAny(cmdStr, "><") && !e.patterns("shell_pattern", shellDangerPatterns, cmdStr) {
+		return nil
+	}
+
+	rules := []guardRule{{"allowed_commands", func() string {
+		allow, _ := p.guard.MatchCommand(cmdName)
+		return fmt.Sprintf("%s is allowed by entry %q", cmdName, allow)
+	}}}
+	if p.denying(scope) {
+		rules = append(rules, guardRule{"denied_file_globs", func() string {
+			return "the command names and reaches no denied file"
+		}})
+	}
+	return rules
+}
+
+func (p *Proxy) explainWrite(e *Explanation, rawArgs string, scope Scope) []guardRule {
+	var args struct {
+		Path    string `json:"path"`
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal([]byte(rawArgs), &args); err != nil {
+		e.fail("args", "", "invalid args: "+err.Error())
+		return nil
+	}
+	if args.Path == "" {
+		e.fail("args", "", "missing path argument")
+		return nil
+	}
+	if _, err := p.sanitizeFilePath(args.Path); err != nil {
+		e.fail("working_directory", "", err.Error())
+		return nil
+	}
+	e.pass("working_directory", args.Path+" is inside the working directory")
+
+	policy := p.guard.Policy()
+	var rules []guardRule
+	if p.denying(scope) {
+		rules = append(rules, guardRule{"denied_file_globs", func() string {
+			return args.Path + " matches no denied glob"
+		}})
+	}
+	rules = append(rules, guardRule{"allowed_file_globs", func() string {
+		glob, _ := p.guard.MatchFile(args.Path)
+		return fmt.Sprintf("%s matches %q", args.Path, glob)
+	}})
+	if policy.MaxWriteBytes > 0 {
+		rules = append(rules, guardRule{"max_write_bytes", func() string {
+			return fmt.Sprintf("%d bytes are within the limit of %d per call", len(args.Content), policy.MaxWriteBytes)
+		}})
+	}
+	if policy.MaxSessionWriteBytes > 0 {
+		rules = append(rules, guardRule{"max_session_write_bytes", func() string {
+			return fmt.Sprintf("the write fits a new session's disk quota of %d bytes", policy.MaxSessionWriteBytes)
+		}})
+	}
+	if args.Content != "" {
+		rules = append(rules, guardRule{"dangerous_content", func() string {
+			return "the content passes the content policy"
+		}})
+	}
+	return rules
+}
+
+// explainPaths returns the rules for the paths a git tool is given in the
+// named argument.
+func (p *Proxy) explainPaths(arg string, scope Scope) []guardRule {
+	if !p.denying(scope) {
+		return nil
+	}
+	return []guardRule{{"denied_file_globs", func() string {
+		return "no path in " + arg + " is denied"
+	}}}
+}
+
+// explainCommit checks the git policy; protected branches depend on the
+// branch checked out when the call runs.
+func (p *Proxy) explainCommit(e *Explanation) {
+	policy := p.guard.Policy().Git
+	if !e.guard("git_commit", p.guard.CheckGitCommit(""), func() string { return "commits are allowed" }) {
+		return
+	}
+	if len(policy.ProtectedBranches) > 0 {
+		e.pass("git_protected_branch", "commits are refused on "+strings.Join(policy.ProtectedBranches, ", "))
+	} else {
+		e.pass("git_protected_branch", "no branches are protected")
+	}
+}
+
+func (e *Explanation) pass(rule, detail string) {
+	e.Checks = append(e.Checks, Check{Rule: rule, Passed: true, Detail: detail})
+}
+
+func (e *Explanation) fail(rule string, sev guard.Severity, detail string) {
+	e.Checks = append(e.Checks, Check{Rule: rule, Severity: sev, Detail: detail})
+	if sev != guard.SeverityWarn {
+		e.Allowed = false
+	}
+}
+
+// guard records a guard rule's outcome; matched describes a pass. It reports
+// whether the call is still allowed.
+func (e *Explanation) guard(rule string, v *guard.Violation, matched func() string) bool {
+	if v == nil {
+		e.pass(rule, matched())
+		return true
+	}
+	e.fail(rule, v.Severity, v.Message)
+	return e.Allowed
+}
+
+// patterns fails the check on the first matching pattern. It reports
+// whether the call is still allowed.
+func (e *Explanation) patterns(rule string, patterns []*regexp.Regexp, cmdStr string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(cmdStr) {
+			e.fail(rule, "", "matches blocked pattern "+pattern.String())
+			return false
+		}
+	}
+	e.pass(rule, "no blocked pattern matches")
+	return true
+}
@@ -0,0 +1,535 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/guard"
+	"github.com/felixgeelhaar/simon/internal/provider"
+)
+
+// GitFile is a changed path in the working tree or index. Status is git's
+// one-letter code: M, A, D, R, C, T, or U.
+type GitFile struct {
+	Path     string `json:"path"`
+	Status   string `json:"status"`
+	OrigPath string `json:"orig_path,omitempty"` // Source of a rename or copy
+}
+
+// GitStatus is the parsed result of git_status.
+type GitStatus struct {
+	Branch    string    `json:"branch"`
+	Upstream  string    `json:"upstream,omitempty"`
+	Ahead     int       `json:"ahead,omitempty"`
+	Behind    int       `json:"behind,omitempty"`
+	Staged    []GitFile `json:"staged"`
+	Unstaged  []GitFile `json:"unstaged"`
+	Untracked []string  `json:"untracked"`
+	Clean     bool      `json:"clean"`
+}
+
+// GitDiffFile is the line count of one file in a diff; binary files have
+// no line counts.
+type GitDiffFile struct {
+	Path    string `json:"path"`
+	Added   int    `json:"added"`
+	Deleted int    `json:"deleted"`
+	Binary  bool   `json:"binary,omitempty"`
+}
+
+// GitDiff is the parsed result of git_diff.
+type GitDiff struct {
+	Staged bool          `json:"staged"`
+	Files  []GitDiffFile `json:"files"`
+	Patch  string        `json:"patch"`
+}
+
+// GitCommit is the result of git_commit.
+type GitCommit struct {
+	Commit  string    `json:"commit"`
+	Branch  string    `json:"branch"`
+	Message string    `json:"message"`
+	Files   []GitFile `json:"files"`
+}
+
+// GitBranches is the result of git_branch.
+type GitBranches struct {
+	Current  string   `json:"current"`
+	Branches []string `json:"branches,omitempty"`
+	Created  bool     `json:"created,omitempty"`
+}
+
+// runGit runs git with args in the working directory, with the sandboxed
+// environment run_shell uses. Nothing passes through a shell. A failing git
+// command is returned as an error carrying its stderr.
+func (p *Proxy) runGit(ctx context.Context, scope Scope, args ...string) (string, error) {
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		return "", fmt.Errorf("command not found: git")
+	}
+
+	execCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	execName, execArgs := gitPath, args
+	p.mu.RLock()
+	sb := p.sandbox
+	p.mu.RUnlock()
+	if sb != nil {
+		execName, execArgs = sb.Wrap(execName, execArgs)
+	}
+	cmd := exec.CommandContext(execCtx, execName, execArgs...)
+	killTree(cmd)
+	cmd.Env = buildEnv(p.guard.Policy().Env, os.Environ(), scope.Env)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if execCtx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("git %s timed out", args[0])
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = strings.TrimSpace(stdout.String())
+		}
+		return "", fmt.Errorf("git %s failed: %v: %s", args[0], err, msg)
+	}
+	return stdout.String(), nil
+}
+
+// enforce reports a guard violation and turns it into the error the call
+// fails with: none for warn, a corrective message for block, and a
+// *guard.ViolationError for halt.
+func (p *Proxy) enforce(v *guard.Violation, scope Scope, report func(*guard.Violation)) error {
+	if v == nil {
+		return nil
+	}
+	report(v)
+	switch v.Severity {
+	case guard.SeverityWarn:
+		return nil
+	case guard.SeverityHalt:
+		return &guard.ViolationError{Violation: v}
+	default:
+		return p.guardBlocked(v, scope)
+	}
+}
+
+// gitBranchUpdates are the git subcommands that move the current branch,
+// held to guard.CheckGitUpdate.
+var gitBranchUpdates = map[string]bool{
+	"commit": true, "merge": true, "cherry-pick": true, "rebase": true, "am": true, "revert": true, "pull": true, "reset": true,
+}
+
+// gitBuiltins are common git subcommands that neither push nor move the
+// current branch. git never lets an alias shadow a builtin, so their names
+// aren't looked up as aliases.
+var gitBuiltins = map[string]bool{
+	"status": true, "log": true, "diff": true, "show": true, "branch": true, "checkout": true, "switch": true, "add": true,
+	"rm": true, "mv": true, "fetch": true, "stash": true, "tag": true, "init": true, "clone": true, "config": true,
+	"rev-parse": true, "ls-files": true, "blame": true, "grep": true, "restore": true, "remote": true,
+}
+
+// maxGitAliasDepth bounds alias expansion, since aliases may use aliases.
+const maxGitAliasDepth = 5
+
+// gitInvocation is a git subcommand and its arguments.
+type gitInvocation struct {
+	name string
+	args []string
+}
+
+// checkGitCommand applies the git policy to a git command run through
+// run_shell, so the structured tools can't be bypassed by running git
+// directly or through an alias.
+func (p *Proxy) checkGitCommand(ctx context.Context, scope Scope, args []string, report func(*guard.Violation)) error {
+	for _, inv := range p.gitSubcommands(ctx, scope, args, 0) {
+		var v *guard.Violation
+		switch {
+		case inv.name == "push":
+			v = p.guard.CheckGitPush()
+		case gitBranchUpdates[inv.name] && (inv.name != "reset" || resetMovesBranch(inv.args)):
+			branch, err := p.currentBranch(ctx, scope)
+			if err != nil {
+				return err
+			}
+			v = p.guard.CheckGitUpdate(inv.name, branch)
+		}
+		if err := p.enforce(v, scope, report); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gitSubcommands returns the subcommands a git command line runs, with
+// aliases defined by -c alias.<name>=<value> or the repository's config
+// expanded. A shell alias ("!...") yields the git commands it runs.
+func (p *Proxy) gitSubcommands(ctx context.Context, scope Scope, args []string, depth int) []gitInvocation {
+	inv, aliases := parseGit(args, nil)
+	for ; depth < maxGitAliasDepth && inv.name != "" && !gitBranchUpdates[inv.name] && inv.name != "push" && !gitBuiltins[inv.name]; depth++ {
+		value, ok := aliases[inv.name]
+		if !ok {
+			out, err := p.runGit(ctx, scope, "config", "--get", "alias."+inv.name)
+			if err != nil {
+				break // Not an alias
+			}
+			value = strings.TrimSpace(out)
+		}
+		if shell, ok := strings.CutPrefix(value, "!"); ok {
+			var invs []gitInvocation
+			words := strings.FieldsFunc(shell, func(r rune) bool { return strings.ContainsRune(" \t\n;&|()", r) })
+			for i, word := range words {
+				if word == "git" {
+					invs = append(invs, p.gitSubcommands(ctx, scope, words[i+1:], depth+1)...)
+				}
+			}
+			return invs
+		}
+		inv, aliases = parseGit(append(strings.Fields(value), inv.args...), aliases)
+	}
+	return []gitInvocation{inv}
+}
+
+// parseGit returns the git subcommand in args and its arguments, skipping
+// global options, and aliases with those defined by -c options added.
+func parseGit(args []string, aliases map[string]string) (gitInvocation, map[string]string) {
+	for i := 0; i < len(args); i++ {
+		switch a := args[i]; {
+		case a == "-c" && i+1 < len(args):
+			if key, value, ok := strings.Cut(args[i+1], "="); ok && strings.HasPrefix(key, "alias.") {
+				aliases = maps.Clone(aliases)
+				if aliases == nil {
+					aliases = make(map[string]string)
+				}
+				aliases[strings.TrimPrefix(key, "alias.")] = value
+			}
+			i++
+		case a == "-C" || a == "--git-dir" || a == "--work-tree" || a == "--namespace":
+			i++ // The option's value
+		case strings.HasPrefix(a, "-"):
+		default:
+			return gitInvocation{name: a, args: args[i+1:]}, aliases
+		}
+	}
+	return gitInvocation{}, aliases
+}
+
+// resetMovesBranch reports whether git reset with args may move the current
+// branch. Only `git reset`, `git reset --patch`, and `git reset -- <paths>`
+// are known to just unstage; `git reset <path>` counts, since a path can't be
+// told from a commit.
+func resetMovesBranch(args []string) bool {
+	for _, a := range args {
+		switch a {
+		case "--":
+			return false
+		case "-q", "--quiet", "-p", "--patch":
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// currentBranch returns the checked-out branch, or "HEAD" when detached.
+func (p *Proxy) currentBranch(ctx context.Context, scope Scope) (string, error) {
+	out, err := p.runGit(ctx, scope, "symbolic-ref", "--quiet", "--short", "HEAD")
+	if err != nil {
+		if _, revErr := p.runGit(ctx, scope, "rev-parse", "--verify", "--quiet", "HEAD"); revErr == nil {
+			return "HEAD", nil
+		}
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// gitStatus handles the git_status tool.
+func (p *Proxy) gitStatus(ctx context.Context, sessionID string) (string, error) {
+	out, err := p.runGit(ctx, p.scope(sessionID), "status", "--porcelain=v1", "--branch", "--untracked-files=all")
+	if err != nil {
+		return "", err
+	}
+	return marshalGit(parseGitStatus(out))
+}
+
+// parseGitStatus parses `git status --porcelain=v1 --branch`.
+func parseGitStatus(out string) GitStatus {
+	s := GitStatus{Staged: []GitFile{}, Unstaged: []GitFile{}, Untracked: []string{}}
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "## ") {
+			parseGitBranchLine(&s, line[3:])
+			continue
+		}
+		if len(line) < 4 {
+			continue
+		}
+		x, y, path := line[0], line[1], unquoteGitPath(line[3:])
+		var orig string
+		if from, to, ok := strings.Cut(path, " -> "); ok {
+			orig, path = unquoteGitPath(from), unquoteGitPath(to)
+		}
+		switch {
+		case x == '?' && y == '?':
+			s.Untracked = append(s.Untracked, path)
+		case x == '!' && y == '!':
+		default:
+			if x != ' ' {
+				s.Staged = append(s.Staged, GitFile{Path: path, Status: string(x), OrigPath: orig})
+			}
+			if y != ' ' {
+				s.Unstaged = append(s.Unstaged, GitFile{Path: path, Status: string(y)})
+			}
+		}
+	}
+	s.Clean = len(s.Staged) == 0 && len(s.Unstaged) == 0 && len(s.Untracked) == 0
+	return s
+}
+
+// parseGitBranchLine parses the header of `git status --branch`, e.g.
+// "main...origin/main [ahead 1, behind 2]" or "No commits yet on main".
+func parseGitBranchLine(s *GitStatus, line string) {
+	if branch, ok := strings.CutPrefix(line, "No commits yet on "); ok {
+		s.Branch = branch
+		return
+	}
+	if strings.HasPrefix(line, "HEAD (no branch)") {
+		s.Branch = "HEAD"
+		return
+	}
+	line, tracking, _ := strings.Cut(line, " [")
+	s.Branch, s.Upstream, _ = strings.Cut(line, "...")
+	for _, part := range strings.Split(strings.TrimSuffix(tracking, "]"), ", ") {
+		key, n, _ := strings.Cut(part, " ")
+		count, _ := strconv.Atoi(n)
+		switch key {
+		case "ahead":
+			s.Ahead = count
+		case "behind":
+			s.Behind = count
+		}
+	}
+}
+
+// unquoteGitPath undoes git's C-style quoting of unusual paths.
+func unquoteGitPath(path string) string {
+	if strings.HasPrefix(path, `"`) {
+		if unquoted, err := strconv.Unquote(path); err == nil {
+			return unquoted
+		}
+	}
+	return path
+}
+
+// gitDiff handles the git_diff tool.
+func (p *Proxy) gitDiff(ctx context.Context, sessionID string, call provider.ToolCall) (string, error) {
+	var args struct {
+		Staged string `json:"staged"`
+		Path   string `json:"path"`
+	}
+	if err := json.Unmarshal([]byte(call.Args), &args); err != nil {
+		return "", fmt.Errorf("invalid args: %w", err)
+	}
+	staged := args.Staged == "true"
+
+	diffArgs := []string{"diff", "--no-color", "--no-ext-diff"}
+	if staged {
+		diffArgs = append(diffArgs, "--cached")
+	}
+	scope := p.scope(sessionID)
+	paths := []string{"--"}
+	if args.Path != "" {
+		if _, err := p.sanitizeFilePath(args.Path); err != nil {
+			return "", err
+		}
+		paths = append(paths, args.Path)
+	}
+	paths = append(paths, p.deniedPathspecs(scope)...)
+	numstat, err := p.runGit(ctx, scope, append(append(append([]string{}, diffArgs...), "--numstat"), paths...)...)
+	if err != nil {
+		return "", err
+	}
+	patch, err := p.runGit(ctx, scope, append(diffArgs, paths...)...)
+	if err != nil {
+		return "", err
+	}
+	return marshalGit(GitDiff{Staged: staged, Files: parseGitNumstat(numstat), Patch: patch})
+}
+
+// deniedPathspecs excludes the policy's and the session's denied_file_globs
+// from a git command, so whole-tree diffs and commits leave those files out.
+func (p *Proxy) deniedPathspecs(scope Scope) []string {
+	var specs []string
+	for _, glob := range append(append([]string{}, p.guard.Policy().DeniedFileGlobs...), scope.DeniedFileGlobs...) {
+		specs = append(specs, ":(exclude,glob)"+glob)
+	}
+	return specs
+}
+
+// parseGitNumstat parses `git diff --numstat`; binary files show "-" counts.
+func parseGitNumstat(out string) []GitDiffFile {
+	files := []GitDiffFile{}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		f := GitDiffFile{Path: unquoteGitPath(fields[2])}
+		if fields[0] == "-" && fields[1] == "-" {
+			f.Binary = true
+		} else {
+			f.Added, _ = strconv.Atoi(fields[0])
+			f.Deleted, _ = strconv.Atoi(fields[1])
+		}
+		files = append(files, f)
+	}
+	return files
+}
+
+// gitCommit handles the git_commit tool: it stages the given paths, or all
+// changes, and commits them on the current branch. The git policy is checked
+// before anything is staged.
+func (p *Proxy) gitCommit(ctx context.Context, sessionID string, call provider.ToolCall, report func(*guard.Violation)) (string, error) {
+	var args struct {
+		Message string `json:"message"`
+		Paths   string `json:"paths"`
+	}
+	if err := json.Unmarshal([]byte(call.Args), &args); err != nil {
+		return "", fmt.Errorf("invalid args: %w", err)
+	}
+	if strings.TrimSpace(args.Message) == "" {
+		return "", fmt.Errorf("missing message argument")
+	}
+	scope := p.scope(sessionID)
+	addArgs := []string{"add", "--all", "--"}
+	for _, path := range strings.Split(args.Paths, ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		if _, err := p.sanitizeFilePath(path); err != nil {
+			return "", err
+		}
+		addArgs = append(addArgs, path)
+	}
+	addArgs = append(addArgs, p.deniedPathspecs(scope)...)
+
+	branch, err := p.currentBranch(ctx, scope)
+	if err != nil {
+		return "", err
+	}
+	if err := p.enforce(p.guard.CheckGitCommit(branch), scope, report); err != nil {
+		return "", err
+	}
+
+	if approver := p.currentApprover(); approver != nil {
+		req := ApprovalRequest{SessionID: sessionID, Tool: call.Name, Command: fmt.Sprintf("git commit on %s: %s", branch, args.Message)}
+		if !approver.Approve(ctx, req) {
+			return "", fmt.Errorf("commit on %s rejected by user", branch)
+		}
+	}
+
+	if _, err := p.runGit(ctx, scope, addArgs...); err != nil {
+		return "", err
+	}
+	if _, err := p.runGit(ctx, scope, "diff", "--cached", "--quiet"); err == nil {
+		return "", errors.New("nothing to commit; the working tree matches the last commit")
+	}
+	if _, err := p.runGit(ctx, scope, "commit", "--quiet", "--message", args.Message); err != nil {
+		return "", err
+	}
+
+	sha, err := p.runGit(ctx, scope, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	changed, err := p.runGit(ctx, scope, "show", "--name-status", "--format=", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	c := GitCommit{Commit: strings.TrimSpace(sha), Branch: branch, Message: args.Message, Files: []GitFile{}}
+	for _, line := range strings.Split(strings.TrimSpace(changed), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 || fields[0] == "" {
+			continue
+		}
+		f := GitFile{Status: fields[0][:1], Path: unquoteGitPath(fields[len(fields)-1])}
+		if len(fields) == 3 {
+			f.OrigPath = unquoteGitPath(fields[1])
+		}
+		c.Files = append(c.Files, f)
+	}
+	return marshalGit(c)
+}
+
+// gitBranch handles the git_branch tool: without a name it lists the local
+// branches, otherwise it switches to the branch, creating it when asked.
+func (p *Proxy) gitBranch(ctx context.Context, sessionID string, call provider.ToolCall) (string, error) {
+	var args struct {
+		Name   string `json:"name"`
+		Create string `json:"create"`
+	}
+	if err := json.Unmarshal([]byte(call.Args), &args); err != nil {
+		return "", fmt.Errorf("invalid args: %w", err)
+	}
+	scope := p.scope(sessionID)
+
+	if args.Name == "" {
+		current, err := p.currentBranch(ctx, scope)
+		if err != nil {
+			return "", err
+		}
+		out, err := p.runGit(ctx, scope, "branch", "--list", "--format=%(refname:short)")
+		if err != nil {
+			return "", err
+		}
+		b := GitBranches{Current: current, Branches: []string{}}
+		for _, name := range strings.Split(strings.TrimSpace(out), "\n") {
+			if name != "" {
+				b.Branches = append(b.Branches, name)
+			}
+		}
+		return marshalGit(b)
+	}
+
+	if strings.HasPrefix(args.Name, "-") {
+		return "", fmt.Errorf("invalid branch name: %s", args.Name)
+	}
+	if _, err := p.runGit(ctx, scope, "check-ref-format", "--branch", args.Name); err != nil {
+		return "", fmt.Errorf("invalid branch name: %s", args.Name)
+	}
+	create := args.Create == "true"
+	if approver := p.currentApprover(); approver != nil {
+		action := "switch to branch " + args.Name
+		if create {
+			action = "create branch " + args.Name
+		}
+		if !approver.Approve(ctx, ApprovalRequest{SessionID: sessionID, Tool: call.Name, Command: action}) {
+			return "", fmt.Errorf("%s rejected by user", action)
+		}
+	}
+	switchArgs := []string{"switch", args.Name}
+	if create {
+		switchArgs = []string{"switch", "--create", args.Name}
+	}
+	if _, err := p.runGit(ctx, scope, switchArgs...); err != nil {
+		return "", err
+	}
+	return marshalGit(GitBranches{Current: args.Name, Created: create})
+}
+
+func marshalGit(v interface{}) (string, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,207 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/guard"
+	"github.com/felixgeelhaar/simon/internal/provider"
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+func TestParseGitStatus(t *testing.T) {
+	s := parseGitStatus("## feature...origin/feature [ahead 2, behind 1]\nM  staged.go\n M edited.go\nR  old.go -> new.go\n?? \"with space.txt\"\n")
+	if s.Branch != "feature" || s.Upstream != "origin/feature" || s.Ahead != 2 || s.Behind != 1 {
+		t.Errorf("Unexpected branch info: %+v", s)
+	}
+	if len(s.Staged) != 2 || s.Staged[1].Path != "new.go" || s.Staged[1].OrigPath != "old.go" || s.Staged[1].Status != "R" {
+		t.Errorf("Unexpected staged files: %+v", s.Staged)
+	}
+	if len(s.Unstaged) != 1 || s.Unstaged[0].Path != "edited.go" {
+		t.Errorf("Unexpected unstaged files: %+v", s.Unstaged)
+	}
+	if len(s.Untracked) != 1 || s.Untracked[0] != "with space.txt" || s.Clean {
+		t.Errorf("Unexpected untracked files: %+v", s.Untracked)
+	}
+
+	if s := parseGitStatus("## No commits yet on main\n"); s.Branch != "main" || !s.Clean {
+		t.Errorf("Expected a clean new repository on main, got %+v", s)
+	}
+}
+
+func TestParseGit(t *testing.T) {
+	for args, want := range map[string]string{
+		"push origin main":           "push",
+		"-C sub -c user.name=x push": "push",
+		"--no-pager commit -m x":     "commit",
+		"--version":                  "",
+	} {
+		if inv, _ := parseGit(strings.Fields(args), nil); inv.name != want {
+			t.Errorf("parseGit(%q) = %q, want %q", args, inv.name, want)
+		}
+	}
+
+	inv, aliases := parseGit(strings.Fields("-c alias.up=push -c core.pager=less up origin"), nil)
+	if inv.name != "up" || strings.Join(inv.args, " ") != "origin" || len(aliases) != 1 || aliases["up"] != "push" {
+		t.Errorf("Expected the alias collected, got %+v %v", inv, aliases)
+	}
+
+	for args, want := range map[string]bool{
+		"":                     false,
+		"-q":                   false,
+		"-- main.go":           false,
+		"--hard HEAD~1":        true,
+		"HEAD~1":               true,
+		"--soft -- irrelevant": true,
+	} {
+		if got := resetMovesBranch(strings.Fields(args)); got != want {
+			t.Errorf("resetMovesBranch(%q) = %v, want %v", args, got, want)
+		}
+	}
+}
+
+func TestProxy_Git(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	tmpDir := t.TempDir()
+	s, _ := store.NewSQLiteStore(filepath.Join(tmpDir, "db"), filepath.Join(tmpDir, "artifacts"))
+	defer s.Close()
+	s.CreateSession(&store.Session{ID: "sess-git", CreatedAt: time.Now()})
+
+	work := filepath.Join(tmpDir, "work")
+	os.MkdirAll(work, 0750)
+	t.Chdir(work)
+	for _, args := range [][]string{
+		{"init", "--quiet", "--initial-branch=main"},
+		{"config", "user.name", "Test"},
+		{"config", "user.email", "test@example.com"},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	os.WriteFile("main.go", []byte("package main\n"), 0600)
+
+	policy := guard.DefaultPolicy
+	policy.Git.ProtectedBranches = []string{"main", "master"}
+	p := NewProxy(s, guard.New(policy))
+	var violations []*guard.Violation
+	call := func(name, args string) (string, error) {
+		return p.execute(context.Background(), "sess-git", provider.ToolCall{ID: "call-" + name, Name: name, Args: args}, func(v *guard.Violation) {
+			violations = append(violations, v)
+		})
+	}
+
+	t.Run("Status", func(t *testing.T) {
+		out, err := call("git_status", `{}`)
+		if err != nil {
+			t.Fatalf("git_status failed: %v", err)
+		}
+		var status GitStatus
+		if err := json.Unmarshal([]byte(out), &status); err != nil {
+			t.Fatalf("Expected JSON, got %s", out)
+		}
+		if status.Branch != "main" || len(status.Untracked) != 1 || status.Untracked[0] != "main.go" {
+			t.Errorf("Unexpected status: %+v", status)
+		}
+	})
+
+	t.Run("Protected Branch", func(t *testing.T) {
+		_, err := call("git_commit", `{"message": "Add main"}`)
+		if !IsBlocked(err) || !strings.Contains(err.Error(), "git_branch") {
+			t.Fatalf("Expected a commit on main to be blocked with a branch suggestion, got %v", err)
+		}
+		if len(violations) != 1 || violations[0].Rule != "git_protected_branch" {
+			t.Errorf("Expected a git_protected_branch violation, got %v", violations)
+		}
+	})
+
+	t.Run("Branch And Commit", func(t *testing.T) {
+		if _, err := call("git_branch", `{"name": "feature", "create": "true"}`); err != nil {
+			t.Fatalf("git_branch failed: %v", err)
+		}
+		out, err := call("git_commit", `{"message": "Add main"}`)
+		if err != nil {
+			t.Fatalf("git_commit failed: %v", err)
+		}
+		var commit GitCommit
+		json.Unmarshal([]byte(out), &commit)
+		if commit.Branch != "feature" || len(commit.Commit) != 40 || len(commit.Files) != 1 || commit.Files[0].Status != "A" {
+			t.Errorf("Unexpected commit: %s", out)
+		}
+
+		if _, err := call("git_commit", `{"message": "Again"}`); err == nil || !strings.Contains(err.Error(), "nothing to commit") {
+			t.Errorf("Expected nothing to commit, got %v", err)
+		}
+
+		out, _ = call("git_branch", `{}`)
+		var branches GitBranches
+		json.Unmarshal([]byte(out), &branches)
+		if branches.Current != "feature" || len(branches.Branches) != 1 {
+			t.Errorf("Unexpected branches: %s", out)
+		}
+		if _, err := call("git_branch", `{"name": "--orphan"}`); err == nil {
+			t.Error("Expected an option-like branch name to be rejected")
+		}
+	})
+
+	t.Run("Diff", func(t *testing.T) {
+		os.WriteFile("main.go", []byte("package main\n\nfunc main() {}\n"), 0600)
+		out, err := call("git_diff", `{"path": "main.go"}`)
+		if err != nil {
+			t.Fatalf("git_diff failed: %v", err)
+		}
+		var diff GitDiff
+		json.Unmarshal([]byte(out), &diff)
+		if len(diff.Files) != 1 || diff.Files[0].Added != 2 || !strings.Contains(diff.Patch, "+func main() {}") {
+			t.Errorf("Unexpected diff: %s", out)
+		}
+		if _, err := call("git_diff", `{"path": "../outside"}`); err == nil {
+			t.Error("Expected a path outside the working directory to be refused")
+		}
+	})
+
+	t.Run("Push Through Shell", func(t *testing.T) {
+		violations = nil
+		_, err := p.runCommand(context.Background(), Scope{}, "git push origin feature", "", func(v *guard.Violation) {
+			violations = append(violations, v)
+		})
+		if !IsBlocked(err) || len(violations) != 1 || violations[0].Rule != "git_push" {
+			t.Errorf("Expected git push to be blocked, got %v (%v)", err, violations)
+		}
+	})
+
+	t.Run("Aliases And Branch Updates Through Shell", func(t *testing.T) {
+		if out, err := exec.Command("git", "config", "alias.ship", "!git fetch && git push").CombinedOutput(); err != nil {
+			t.Fatalf("git config failed: %v\n%s", err, out)
+		}
+		if out, err := exec.Command("git", "checkout", "--quiet", "-b", "master").CombinedOutput(); err != nil {
+			t.Fatalf("git checkout failed: %v\n%s", err, out)
+		}
+		for cmd, rule := range map[string]string{
+			"git -c alias.up=push up origin": "git_push",
+			"git ship":                       "git_push",
+			"git merge feature":              "git_protected_branch",
+			"git cherry-pick feature":        "git_protected_branch",
+			"git reset --hard feature":       "git_protected_branch",
+		} {
+			violations = nil
+			_, err := p.runCommand(context.Background(), Scope{}, cmd, "", func(v *guard.Violation) {
+				violations = append(violations, v)
+			})
+			if !IsBlocked(err) || len(violations) != 1 || violations[0].Rule != rule {
+				t.Errorf("Expected %q to be blocked by %s, got %v (%v)", cmd, rule, err, violations)
+			}
+		}
+		if _, err := p.runCommand(context.Background(), Scope{}, "git reset -- main.go", "", func(*guard.Violation) {}); err != nil {
+			t.Errorf("Expected unstaging on main to pass, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package mcp
+
+import "os/exec"
+
+// killTree bounds the wait for the output pipes once cmd's context ends.
+// Without process groups only cmd itself is killed; WaitDelay keeps a
+// grandchild holding the pipes from blocking the call past the deadline.
+func killTree(cmd *exec.Cmd) {
+	cmd.WaitDelay = killWaitDelay
+}
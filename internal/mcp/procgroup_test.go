@@ -0,0 +1,27 @@
+//go:build unix
+
+package mcp
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestKillTree(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	// The backgrounded sleep inherits the output pipe; killing only the
+	// shell would leave the call waiting on it
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", "sleep 60 & wait")
+	killTree(cmd)
+	started := time.Now()
+	if _, err := cmd.CombinedOutput(); err == nil {
+		t.Fatal("Expected the command to be killed")
+	}
+	if elapsed := time.Since(started); elapsed >= killWaitDelay {
+		t.Errorf("Expected the process group to be killed at the deadline, took %v", elapsed)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build unix
+
+package mcp
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killTree runs cmd in a process group of its own and, when its context
+// ends, kills the whole group instead of cmd alone, so grandchildren such
+// as the test binaries of go test don't outlive the deadline. WaitDelay
+// bounds the wait for anything that left the group and still holds the
+// output pipes.
+func killTree(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = killWaitDelay
+}
@@ -5,13 +5,18 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/felixgeelhaar/simon/internal/coach"
 	"github.com/felixgeelhaar/simon/internal/guard"
 	"github.com/felixgeelhaar/simon/internal/provider"
 	"github.com/felixgeelhaar/simon/internal/store"
@@ -38,12 +43,101 @@ var dangerousPatterns = []*regexp.Regexp{
 }
 
 type Proxy struct {
-	store store.Storage
-	guard *guard.Guard
+	store   store.Storage
+	guard   *guard.Guard
+	reducer *Pipeline
+
+	mu       sync.RWMutex
+	scopes   map[string]Scope
+	approver Approver
+	written  map[string]map[string]bool    // session -> paths changed by write tools
+	read     map[string]int                // session -> artifact bytes returned by read_artifact
+	wrote    map[string]int64              // session -> workspace growth charged to its disk quota
+	calls    map[string]*guard.RateLimiter // session -> limiter of its tool calls
+	sandbox  Sandbox
+	subtasks SubtaskRunner
+
+	verifiers map[string]Verifier        // by check type, see RegisterVerifier
+	artifacts map[string]*artifactWriter // session -> writer of its tool outputs, see FlushArtifacts
+	tools     ToolSet                    // runs the tools when set, see SetTools
+}
+
+// Scope carries per-session execution settings derived from the task spec.
+type Scope struct {
+	// Env is added to the sandboxed base environment of every tool process.
+	Env map[string]string
+	// AllowedCommands further restricts the Guard policy; empty means no extra restriction.
+	AllowedCommands []string
+	// Evidence, Verify, and Checks are the spec's completion checks, run
+	// by verify_evidence.
+	Evidence []string
+	Verify   []string
+	Checks   []coach.Check
+	// DeniedFileGlobs are paths no tool may touch, in addition to the
+	// policy's denied_file_globs.
+	DeniedFileGlobs []string
+
+	commands *guard.CommandMatcher // AllowedCommands, compiled by SetScope
+	denied   *guard.GlobMatcher    // DeniedFileGlobs, compiled by SetScope
 }
 
 func NewProxy(s store.Storage, g *guard.Guard) *Proxy {
-	return &Proxy{store: s, guard: g}
+	p := &Proxy{store: s, guard: g, reducer: DefaultPipeline(), scopes: make(map[string]Scope), written: make(map[string]map[string]bool), read: make(map[string]int), wrote: make(map[string]int64), calls: make(map[string]*guard.RateLimiter)}
+	p.verifiers = builtinVerifiers(p)
+	p.artifacts = make(map[string]*artifactWriter)
+	return p
+}
+
+// UseReducer registers a reducer that runs before the built-in digest heuristics.
+func (p *Proxy) UseReducer(r Reducer) {
+	p.reducer.Use(r)
+}
+
+// SetSandbox runs every shell command inside sb; nil disables sandboxing.
+func (p *Proxy) SetSandbox(sb Sandbox) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sandbox = sb
+}
+
+// SetScope configures the execution scope for a session.
+func (p *Proxy) SetScope(sessionID string, scope Scope) {
+	scope = scope.compile()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.scopes[sessionID] = scope
+}
+
+// compile returns s with its allowed commands and denied globs compiled.
+func (s Scope) compile() Scope {
+	if len(s.AllowedCommands) > 0 {
+		s.commands = guard.NewCommandMatcher(s.AllowedCommands)
+	}
+	if len(s.DeniedFileGlobs) > 0 {
+		s.denied = guard.NewGlobMatcher(s.DeniedFileGlobs)
+	}
+	return s
+}
+
+func (p *Proxy) scope(sessionID string) Scope {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.scopes[sessionID]
+}
+
+// SessionState returns what guard.Guard.CheckToolCall needs to know about a
+// session: its task's denied globs, the disk quota it has used, and the
+// limiter pacing its tool calls. Calls outside a session, such as verify
+// commands, are not paced.
+func (p *Proxy) SessionState(sessionID string) guard.SessionState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	limiter, ok := p.calls[sessionID]
+	if !ok && sessionID != "" {
+		limiter = guard.NewRateLimiter(p.guard.Policy().MaxToolCallsPerMinute)
+		p.calls[sessionID] = limiter
+	}
+	return guard.SessionState{DeniedFiles: p.scopes[sessionID].denied, WrittenBytes: p.wrote[sessionID], ToolCalls: limiter}
 }
 
 // ToolResult represents the processed outcome of a tool call.
@@ -52,6 +146,20 @@ type ToolResult struct {
 	Name       string
 	Digest     string
 	IsError    bool
+	Duration   time.Duration // Time spent executing the tool
+	// Violations lists guard violations raised while handling the call,
+	// including warn-level ones that did not prevent execution.
+	Violations []*guard.Violation
+	// Ref is a one-line reference to the stored output, with its size and
+	// outcome, that can stand in for Digest once the result is old. It is
+	// empty when the output could not be stored.
+	Ref string
+	// Failed reports a call that errored, was refused, or ran a command
+	// that exited non-zero.
+	Failed bool
+	// Reason is the line of a failed call's output that best explains the
+	// failure.
+	Reason string
 }
 
 // HandleToolCalls processes a batch of tool calls, executing them,
@@ -61,7 +169,15 @@ func (p *Proxy) HandleToolCalls(ctx context.Context, sessionID string, calls []p
 
 	for _, call := range calls {
 		// 1. Execute
-		rawOutput, err := p.execute(ctx, call)
+		var violations []*guard.Violation
+		started := time.Now()
+		rawOutput, err := p.execute(ctx, sessionID, call, func(v *guard.Violation) {
+			violations = append(violations, v)
+		})
+		duration := time.Since(started)
+		if guard.IsHalt(err) {
+			return results, err
+		}
 		isError := false
 		if err != nil {
 			rawOutput = fmt.Sprintf("Error executing tool: %v\n%s", err, rawOutput)
@@ -82,60 +198,169 @@ func (p *Proxy) HandleToolCalls(ctx context.Context, sessionID string, calls []p
 			Digest:    digestStr,
 		}
 
-		if err := p.store.SaveArtifact(artifact, []byte(rawOutput)); err != nil {
-			return nil, fmt.Errorf("failed to save artifact: %w", err)
+		stored := "Output stored at " + artifactPath
+		ended := outcome(call.Name, rawOutput, isError)
+		ref := fmt.Sprintf("Tool %s output stored at %s (%s, %s)", call.Name, artifactPath, formatSize(len(rawOutput)), ended)
+		if p.fitsArtifact(len(rawOutput)) {
+			p.artifactWriter(sessionID).save(artifact, []byte(rawOutput))
+		} else {
+			// An oversized output still reaches the agent through its digest
+			stored = "Output too large to store"
+			ref = ""
 		}
 
-		// 3. Create Digest for Context
+		// 3. Create Digest for Context. A read_artifact range is already bounded
+		// and was asked for to get past the digest, and the verify_evidence
+		// report is bounded per item and useless once items are dropped, so
+		// both are passed on whole, as is the corrective message of a refused
+		// call.
 		displayDigest := rawOutput
-		if len(displayDigest) > 200 {
-			displayDigest = displayDigest[:197] + "..."
+		if (!undigested[call.Name] || isError) && !IsBlocked(err) {
+			displayDigest = p.reducer.Reduce(ctx, rawOutput, p.guard.Policy().MaxDigestTokens)
+		}
+
+		failed := ended != "ok" && ended != "exit 0"
+		var reason string
+		if failed {
+			reason = failureReason(rawOutput)
 		}
-		
 		results = append(results, ToolResult{
 			ToolCallID: call.ID,
 			Name:       call.Name,
-			Digest:     fmt.Sprintf("Tool %s executed. Output stored at %s. Summary: %s", call.Name, artifactPath, displayDigest),
+			Digest:     fmt.Sprintf("Tool %s executed. %s. Summary: %s", call.Name, stored, displayDigest),
 			IsError:    isError,
+			Duration:   duration,
+			Violations: violations,
+			Ref:        ref,
+			Failed:     failed,
+			Reason:     reason,
 		})
 	}
 
 	return results, nil
 }
 
+// fitsArtifact reports whether the store accepts an artifact of n bytes,
+// which is known before the output is written in the background.
+func (p *Proxy) fitsArtifact(n int) bool {
+	limited, ok := p.store.(interface{ MaxArtifactSize() int64 })
+	if !ok {
+		return true
+	}
+	limit := limited.MaxArtifactSize()
+	return limit <= 0 || int64(n) <= limit
+}
+
+// shellExitPattern matches the line run_shell appends to the output of a
+// command that exited with a non-zero status.
+var shellExitPattern = regexp.MustCompile(`\n\[ERROR\] exit status (\d+)$`)
+
+// outcome describes how a tool call ended for its Ref: the exit status of
+// a shell command, or whether any other tool failed.
+func outcome(tool, output string, isError bool) string {
+	switch {
+	case isError:
+		return "error"
+	case tool != "run_shell":
+		return "ok"
+	}
+	if m := shellExitPattern.FindStringSubmatch(output); m != nil {
+		return "exit " + m[1]
+	}
+	if strings.Contains(output, "\n[ERROR] ") {
+		return "failed"
+	}
+	return "exit 0"
+}
+
+// undigested are the tools whose output reaches the model whole rather than
+// through the reducer pipeline.
+var undigested = map[string]bool{"read_artifact": true, "verify_evidence": true}
+
+// failureLine matches output lines that state an error.
+var failureLine = regexp.MustCompile(`(?i)\b(error|fail(ed|ure)?|panic|fatal|cannot|undefined|not found|denied)\b`)
+
+// maxReason bounds the length of a failure reason, in runes.
+const maxReason = 200
+
+// failureReason returns the line of a failed call's output that best
+// explains the failure: the first one stating an error, or else the last
+// one before the exit status.
+func failureReason(output string) string {
+	var exit string
+	if m := shellExitPattern.FindStringSubmatch(output); m != nil {
+		exit = "exit status " + m[1]
+		output = strings.TrimSuffix(output, m[0])
+	}
+	var last string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if failureLine.MatchString(line) {
+			return truncateRunes(line, maxReason)
+		}
+		last = line
+	}
+	if last == "" {
+		return exit
+	}
+	return truncateRunes(last, maxReason)
+}
+
+// truncateRunes shortens s to at most n runes, marking the cut with "...".
+func truncateRunes(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n]) + "..."
+	}
+	return s
+}
+
+// formatSize renders a byte count as B, KB, or MB with one decimal.
+func formatSize(n int) string {
+	switch {
+	case n < 1024:
+		return fmt.Sprintf("%dB", n)
+	case n < 1024*1024:
+		return fmt.Sprintf("%.1fKB", float64(n)/1024)
+	}
+	return fmt.Sprintf("%.1fMB", float64(n)/(1024*1024))
+}
+
 // validateCommand checks for dangerous command patterns that could lead to injection
 func (p *Proxy) validateCommand(cmdStr string) error {
 	for _, pattern := range dangerousPatterns {
 		if pattern.MatchString(cmdStr) {
-			return fmt.Errorf("potentially dangerous command pattern detected: %s", pattern.String())
+			return patternBlocked("dangerous_pattern", cmdStr, pattern)
 		}
 	}
 	return nil
 }
 
+// shellDangerPatterns are blocked even for commands that run through bash.
+var shellDangerPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\beval\s`),             // eval command
+	regexp.MustCompile(`(?i)\bsource\s`),           // source command
+	regexp.MustCompile("`"),                        // Backtick substitution
+	regexp.MustCompile(`\$\(`),                     // Command substitution
+	regexp.MustCompile(`(?i)\bcurl\b.*\|\s*sh`),    // curl pipe to sh
+	regexp.MustCompile(`(?i)\bwget\b.*\|\s*sh`),    // wget pipe to sh
+	regexp.MustCompile(`(?i)\b(bash|sh|zsh)\s+-c`), // Nested shell execution
+	regexp.MustCompile(`(?i)\bsudo\s`),             // sudo command
+	regexp.MustCompile(`(?i)\bchmod\s+[0-7]*7`),    // Making files world-writable
+	regexp.MustCompile(`(?i)/etc/passwd`),          // Accessing passwd file
+	regexp.MustCompile(`(?i)/etc/shadow`),          // Accessing shadow file
+	regexp.MustCompile(`(?i)~/.ssh`),               // Accessing SSH keys
+	regexp.MustCompile(`(?i)rm\s+-rf\s+/`),         // Dangerous rm command
+}
+
 // validateShellCommand performs additional validation for commands executed through bash
 // This is more permissive than validateCommand since shell features are expected
 func (p *Proxy) validateShellCommand(cmdStr string) error {
-	// Block the most dangerous patterns even in shell mode
-	shellDangerPatterns := []*regexp.Regexp{
-		regexp.MustCompile(`(?i)\beval\s`),             // eval command
-		regexp.MustCompile(`(?i)\bsource\s`),           // source command
-		regexp.MustCompile("`"),                        // Backtick substitution
-		regexp.MustCompile(`\$\(`),                     // Command substitution
-		regexp.MustCompile(`(?i)\bcurl\b.*\|\s*sh`),    // curl pipe to sh
-		regexp.MustCompile(`(?i)\bwget\b.*\|\s*sh`),    // wget pipe to sh
-		regexp.MustCompile(`(?i)\b(bash|sh|zsh)\s+-c`), // Nested shell execution
-		regexp.MustCompile(`(?i)\bsudo\s`),             // sudo command
-		regexp.MustCompile(`(?i)\bchmod\s+[0-7]*7`),    // Making files world-writable
-		regexp.MustCompile(`(?i)/etc/passwd`),          // Accessing passwd file
-		regexp.MustCompile(`(?i)/etc/shadow`),          // Accessing shadow file
-		regexp.MustCompile(`(?i)~/.ssh`),               // Accessing SSH keys
-		regexp.MustCompile(`(?i)rm\s+-rf\s+/`),         // Dangerous rm command
-	}
-
 	for _, pattern := range shellDangerPatterns {
 		if pattern.MatchString(cmdStr) {
-			return fmt.Errorf("dangerous shell command pattern blocked: %s", pattern.String())
+			return patternBlocked("shell_pattern", cmdStr, pattern)
 		}
 	}
 
@@ -145,67 +370,11 @@ func (p *Proxy) validateShellCommand(cmdStr string) error {
 // parseCommand safely parses a command string into executable and arguments
 // Returns the command name and its arguments separately
 func (p *Proxy) parseCommand(cmdStr string) (string, []string, error) {
-	// Trim whitespace
-	cmdStr = strings.TrimSpace(cmdStr)
-	if cmdStr == "" {
-		return "", nil, fmt.Errorf("empty command")
-	}
-
-	// Use a simple shell-like parsing that respects quotes
-	var args []string
-	var current strings.Builder
-	inSingleQuote := false
-	inDoubleQuote := false
-	escaped := false
-
-	for i := 0; i < len(cmdStr); i++ {
-		c := cmdStr[i]
-
-		if escaped {
-			current.WriteByte(c)
-			escaped = false
-			continue
-		}
-
-		if c == '\\' && !inSingleQuote {
-			escaped = true
-			continue
-		}
-
-		if c == '\'' && !inDoubleQuote {
-			inSingleQuote = !inSingleQuote
-			continue
-		}
-
-		if c == '"' && !inSingleQuote {
-			inDoubleQuote = !inDoubleQuote
-			continue
-		}
-
-		if c == ' ' && !inSingleQuote && !inDoubleQuote {
-			if current.Len() > 0 {
-				args = append(args, current.String())
-				current.Reset()
-			}
-			continue
-		}
-
-		current.WriteByte(c)
-	}
-
-	if current.Len() > 0 {
-		args = append(args, current.String())
-	}
-
-	if inSingleQuote || inDoubleQuote {
-		return "", nil, fmt.Errorf("unclosed quote in command")
-	}
-
-	if len(args) == 0 {
-		return "", nil, fmt.Errorf("no command specified")
+	words, err := guard.SplitCommand(cmdStr)
+	if err != nil {
+		return "", nil, err
 	}
-
-	return args[0], args[1:], nil
+	return words[0], words[1:], nil
 }
 
 // sanitizeWorkDir validates and sanitizes the working directory path
@@ -231,119 +400,263 @@ func (p *Proxy) sanitizeWorkDir(dir string) (string, error) {
 	return absPath, nil
 }
 
-func (p *Proxy) execute(ctx context.Context, call provider.ToolCall) (string, error) {
-	switch call.Name {
-	case "run_shell":
-		var args map[string]interface{}
-		if err := json.Unmarshal([]byte(call.Args), &args); err != nil {
-			return "", fmt.Errorf("invalid args: %w", err)
-		}
-		cmdVal, ok := args["cmd"]
-		if !ok {
-			return "", fmt.Errorf("missing cmd argument")
-		}
+// execute runs a single tool call. Every guard violation is passed to report;
+// warn-level violations let execution continue, block-level ones fail the call,
+// and halt-level ones are returned as a *guard.ViolationError.
+func (p *Proxy) execute(ctx context.Context, sessionID string, call provider.ToolCall, report func(*guard.Violation)) (string, error) {
+	scope := p.scope(sessionID)
+	if err := p.checkToolCall(sessionID, call, scope, report); err != nil {
+		return "", err
+	}
 
-		var cmdStr string
-		switch v := cmdVal.(type) {
-		case string:
-			cmdStr = v
-		default:
-			// Handle array of strings (e.g., ["ls", "-l"])
-			if slice, ok := v.([]interface{}); ok {
-				var parts []string
-				for _, s := range slice {
-					parts = append(parts, fmt.Sprint(s))
-				}
-				cmdStr = strings.Join(parts, " ")
-			} else {
-				return "", fmt.Errorf("cmd must be a string or array of strings")
-			}
-		}
+	run, ok := p.executor(call.Name)
+	if !ok {
+		return "Unknown tool", fmt.Errorf("unknown tool: %s", call.Name)
+	}
+	return run(context.WithValue(ctx, reportKey{}, report), sessionID, call)
+}
+
+// runShell handles the run_shell tool.
+func (p *Proxy) runShell(ctx context.Context, sessionID string, call provider.ToolCall) (string, error) {
+	scope := p.scope(sessionID)
+	report := reporter(ctx)
+
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(call.Args), &args); err != nil {
+		return "", fmt.Errorf("invalid args: %w", err)
+	}
+	cmdVal, ok := args["cmd"]
+	if !ok {
+		return "", fmt.Errorf("missing cmd argument")
+	}
 
-		// 1. Validate command for dangerous patterns
-		if err := p.validateCommand(cmdStr); err != nil {
-			return "", fmt.Errorf("command validation failed: %w", err)
+	var cmdStr string
+	switch v := cmdVal.(type) {
+	case string:
+		cmdStr = v
+	default:
+		// Handle array of strings (e.g., ["ls", "-l"])
+		if slice, ok := v.([]interface{}); ok {
+			var parts []string
+			for _, s := range slice {
+				parts = append(parts, fmt.Sprint(s))
+			}
+			cmdStr = strings.Join(parts, " ")
+		} else {
+			return "", fmt.Errorf("cmd must be a string or array of strings")
 		}
+	}
 
-		// 2. Parse command into executable and arguments
-		cmdName, cmdArgs, err := p.parseCommand(cmdStr)
+	// 1. Sanitize working directory if provided
+	var dirStr string
+	if dirVal, ok := args["dir"].(string); ok {
+		var err error
+		dirStr, err = p.sanitizeWorkDir(dirVal)
 		if err != nil {
-			return "", fmt.Errorf("failed to parse command: %w", err)
+			return "", fmt.Errorf("invalid working directory: %w", err)
 		}
+	}
 
-		// 3. Guard Check on the command name
-		if v := p.guard.CheckCommand(cmdName); v != nil {
-			return "", fmt.Errorf("guard violation: %s", v.Message)
+	if approver := p.currentApprover(); approver != nil {
+		dir, _ := args["dir"].(string)
+		req := ApprovalRequest{SessionID: sessionID, Tool: call.Name, Path: dir, Command: cmdStr}
+		if !approver.Approve(ctx, req) {
+			return "", fmt.Errorf("command %q rejected by user", cmdStr)
 		}
+	}
 
-		// 4. Sanitize working directory if provided
-		dirVal, hasDir := args["dir"]
-		var dirStr string
-		if hasDir {
-			if s, ok := dirVal.(string); ok {
-				dirStr, err = p.sanitizeWorkDir(s)
-				if err != nil {
-					return "", fmt.Errorf("invalid working directory: %w", err)
-				}
+	// The workspace is measured around the command when writes are
+	// limited, since what a command writes is only known afterwards
+	policy := p.guard.Policy()
+	measure := policy.MaxWriteBytes > 0 || policy.MaxSessionWriteBytes > 0
+	var sizeBefore int64
+	if measure {
+		sizeBefore, _ = DiskUsage(".")
+	}
+	output, err := p.execCommand(ctx, scope, cmdStr, dirStr, report)
+	if measure {
+		if sizeAfter, serr := DiskUsage("."); serr == nil {
+			if werr := p.chargeShellWrite(sessionID, sizeAfter-sizeBefore, scope, report); werr != nil {
+				return output, werr
 			}
 		}
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// A failing command is a result for the model, not a tool error
+		return output + fmt.Sprintf("\n[ERROR] %v", err), nil
+	}
+	return output, err
+}
 
-		// 5. Determine execution mode based on command complexity
-		// If command contains shell features (redirection), use bash with strict validation
-		// Otherwise use direct exec for maximum safety
-		needsShell := strings.ContainsAny(cmdStr, "><")
-
-		// 6. Real Execution with Timeout
-		execCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-		defer cancel()
-
-		var cmd *exec.Cmd
-		if needsShell {
-			// Use bash for commands requiring shell features, but validate first
-			// Additional validation for shell mode
-			if err := p.validateShellCommand(cmdStr); err != nil {
-				cancel()
-				return "", fmt.Errorf("shell command validation failed: %w", err)
-			}
-			cmd = exec.CommandContext(execCtx, "/bin/bash", "-c", cmdStr)
-		} else {
-			// Direct execution for simple commands (safer)
-			cmdPath, err := exec.LookPath(cmdName)
-			if err != nil {
-				cancel()
-				return "", fmt.Errorf("command not found: %s", cmdName)
-			}
-			cmd = exec.CommandContext(execCtx, cmdPath, cmdArgs...)
-		}
+// killWaitDelay is how long the output pipes of a killed tool process may
+// stay open before the call returns anyway.
+const killWaitDelay = 5 * time.Second
+
+// CheckToolCall applies the guard's pre-execution rules to a call of the
+// session's, as HandleToolCalls does, for tools run without the proxy such
+// as those a caller runs through the runtime's ToolRegistry. Every
+// violation is passed to report; the error is the one the call fails with:
+// a *guard.ViolationError for a halting violation, or else a *BlockedError
+// for a blocking one.
+func (p *Proxy) CheckToolCall(sessionID string, call provider.ToolCall, report func(*guard.Violation)) error {
+	return p.checkToolCall(sessionID, call, p.scope(sessionID), report)
+}
 
-		if dirStr != "" {
-			cmd.Dir = dirStr
+// checkToolCall applies the guard's pre-execution rules to call (see
+// guard.Guard.CheckToolCall). Every violation is reported; the call fails
+// with the first halting one, or else the first blocking one.
+func (p *Proxy) checkToolCall(sessionID string, call provider.ToolCall, scope Scope, report func(*guard.Violation)) error {
+	state := p.SessionState(sessionID)
+	state.DeniedFiles = scope.denied
+
+	var blocked error
+	for _, v := range p.guard.CheckToolCall(call, state) {
+		if v.Rule == "dangerous_content" && p.guard.Policy().Content.Approve && p.currentApprover() != nil {
+			// writeFile asks the user instead, showing what was flagged
+			report(v)
+			continue
+		}
+		err := p.enforce(v, scope, report)
+		if guard.IsHalt(err) {
+			return err
 		}
+		if blocked == nil {
+			blocked = err
+		}
+	}
+	return blocked
+}
+
+// runCommand runs cmdStr as a run_shell call would, guard checks included,
+// for commands that don't come from a tool call such as verify commands.
+func (p *Proxy) runCommand(ctx context.Context, scope Scope, cmdStr, dir string, report func(*guard.Violation)) (string, error) {
+	args, err := json.Marshal(map[string]string{"cmd": cmdStr, "dir": dir})
+	if err != nil {
+		return "", err
+	}
+	if err := p.checkToolCall("", provider.ToolCall{Name: "run_shell", Args: string(args)}, scope, report); err != nil {
+		return "", err
+	}
+	return p.execCommand(ctx, scope, cmdStr, dir, report)
+}
+
+// execCommand validates cmdStr against the session scope, then runs it with
+// the sandboxed environment; the guard's pre-execution rules must already
+// have passed. A non-zero exit status is returned as an *exec.ExitError
+// alongside the combined output.
+func (p *Proxy) execCommand(ctx context.Context, scope Scope, cmdStr, dir string, report func(*guard.Violation)) (string, error) {
+	// 1. Validate command for dangerous patterns
+	if err := p.validateCommand(cmdStr); err != nil {
+		return "", err
+	}
 
-		// Set a clean environment to prevent environment variable injection
-		cmd.Env = []string{
-			"PATH=/usr/local/bin:/usr/bin:/bin",
-			"HOME=" + getHomeDir(),
-			"LANG=en_US.UTF-8",
+	// 2. Parse command into executable and arguments
+	cmdName, cmdArgs, err := p.parseCommand(cmdStr)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse command: %w", err)
+	}
+
+	// 3. The spec's own allow list narrows the policy's
+	if scope.commands != nil {
+		if _, ok := scope.commands.Match(cmdName); !ok {
+			return "", commandBlocked("task spec allowed_commands", cmdName, scope.AllowedCommands, func(cmd string) bool {
+				_, spec := scope.commands.Match(cmd)
+				_, policy := p.guard.MatchCommand(cmd)
+				return spec && policy
+			})
 		}
+	}
 
-		output, err := cmd.CombinedOutput()
+	// Git run directly is held to the same policy as the git tools
+	if cmdName == "git" {
+		if err := p.checkGitCommand(ctx, scope, cmdArgs, report); err != nil {
+			return "", err
+		}
+	}
 
-		result := string(output)
+	// 4. Determine execution mode based on command complexity
+	// If command contains shell features (redirection), use bash with strict validation
+	// Otherwise use direct exec for maximum safety
+	needsShell := strings.ContainsAny(cmdStr, "><")
+
+	// 5. Real Execution with Timeout
+	execCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var execName string
+	var execArgs []string
+	if needsShell {
+		// Use bash for commands requiring shell features, but validate first
+		if err := p.validateShellCommand(cmdStr); err != nil {
+			return "", err
+		}
+		execName, execArgs = "/bin/bash", []string{"-c", cmdStr}
+	} else {
+		// Direct execution for simple commands (safer)
+		cmdPath, err := exec.LookPath(cmdName)
 		if err != nil {
-			if execCtx.Err() == context.DeadlineExceeded {
-				return result + "\n[ERROR] Command timed out", fmt.Errorf("command timed out")
-			}
-			return result + fmt.Sprintf("\n[ERROR] %v", err), nil
+			return "", fmt.Errorf("command not found: %s", cmdName)
 		}
-		return result, nil
+		execName, execArgs = cmdPath, cmdArgs
+	}
 
-	default:
-		return "Unknown tool", fmt.Errorf("unknown tool: %s", call.Name)
+	// OS-level confinement, when configured, wraps the resolved command
+	p.mu.RLock()
+	sb := p.sandbox
+	p.mu.RUnlock()
+	if sb != nil {
+		execName, execArgs = sb.Wrap(execName, execArgs)
+	}
+	cmd := exec.CommandContext(execCtx, execName, execArgs...)
+	killTree(cmd)
+
+	if dir != "" {
+		cmd.Dir = dir
+	}
+
+	// Pass through only what the policy allows, so credentials stay out
+	cmd.Env = buildEnv(p.guard.Policy().Env, os.Environ(), scope.Env)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil && execCtx.Err() == context.DeadlineExceeded {
+		return string(output) + "\n[ERROR] Command timed out", fmt.Errorf("command timed out")
+	}
+	return string(output), err
+}
+
+// buildEnv returns the tool environment: the variables of environ the
+// policy allows over a minimal base, with the policy's PATH entries in front
+// and spec-declared variables applied on top. Spec values override the rest.
+func buildEnv(policy guard.EnvPolicy, environ []string, extra map[string]string) []string {
+	base := map[string]string{
+		"PATH": "/usr/local/bin:/usr/bin:/bin",
+		"HOME": getHomeDir(),
+		"LANG": "en_US.UTF-8",
+	}
+	for k, v := range policy.Filter(environ) {
+		base[k] = v
+	}
+	if len(policy.Path) > 0 {
+		base["PATH"] = strings.Join(append(append([]string{}, policy.Path...), base["PATH"]), string(os.PathListSeparator))
+	}
+	for k, v := range extra {
+		base[k] = v
+	}
+
+	keys := make([]string, 0, len(base))
+	for k := range base {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	env := make([]string, 0, len(keys))
+	for _, k := range keys {
+		env = append(env, k+"="+base[k])
 	}
+	return env
 }
 
-// getHomeDir returns the user's home directory or a safe default
 func getHomeDir() string {
 	if home, err := filepath.Abs("."); err == nil {
 		return home
@@ -2,8 +2,10 @@ package mcp
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -21,6 +23,7 @@ func TestProxy_HandleToolCalls(t *testing.T) {
 		AllowedCommands: []string{"echo"},
 	})
 	p := NewProxy(s, g)
+	defer p.CloseArtifacts("sess-mcp")
 
 	session := &store.Session{ID: "sess-mcp", CreatedAt: time.Now()}
 	s.CreateSession(session)
@@ -38,9 +41,12 @@ func TestProxy_HandleToolCalls(t *testing.T) {
 		if len(results) != 1 {
 			t.Errorf("Expected 1 result, got %d", len(results))
 		}
-		if results[0].IsError {
+		if results[0].IsError || results[0].Failed {
 			t.Errorf("Expected no error, got error: %s", results[0].Digest)
 		}
+		if ref := results[0].Ref; !strings.HasPrefix(ref, "Tool run_shell output stored at artifacts/sess-mcp/run_shell_call-1-") || !strings.HasSuffix(ref, " (6B, exit 0)") {
+			t.Errorf("Unexpected artifact reference: %s", ref)
+		}
 	})
 
 	t.Run("Blocked Command", func(t *testing.T) {
@@ -53,8 +59,53 @@ func TestProxy_HandleToolCalls(t *testing.T) {
 			t.Fatalf("HandleToolCalls failed: %v", err)
 		}
 
-		if !results[0].IsError {
-			t.Error("Expected error for blocked command")
+		if !results[0].IsError || !results[0].Failed || !strings.Contains(results[0].Reason, "rm is not allowed") {
+			t.Errorf("Expected a failed call explaining the refusal, got %+v", results[0])
+		}
+		for _, want := range []string{"blocked by allowed_commands: the command rm is not allowed", "Allowed: echo", "Suggestion:"} {
+			if !strings.Contains(results[0].Digest, want) {
+				t.Errorf("Expected %q in the corrective message, got %s", want, results[0].Digest)
+			}
+		}
+	})
+
+	t.Run("Blocked Pattern", func(t *testing.T) {
+		calls := []provider.ToolCall{
+			{ID: "call-2b", Name: "run_shell", Args: `{"cmd": "echo a && echo b"}`},
+		}
+
+		results, _ := p.HandleToolCalls(context.Background(), "sess-mcp", calls)
+		if !results[0].IsError || !strings.Contains(results[0].Digest, "own run_shell call") {
+			t.Errorf("Expected a suggestion to split the command, got %s", results[0].Digest)
+		}
+	})
+
+	t.Run("Outcome", func(t *testing.T) {
+		if got := outcome("run_shell", "missing\n[ERROR] exit status 2", false); got != "exit 2" {
+			t.Errorf("Expected the exit status, got %s", got)
+		}
+		if got := outcome("run_shell", "\n[ERROR] signal: killed", false); got != "failed" {
+			t.Errorf("Expected a failure, got %s", got)
+		}
+		if got := outcome("write_file", "Error executing tool: denied", true); got != "error" {
+			t.Errorf("Expected an error, got %s", got)
+		}
+		if got := formatSize(2150); got != "2.1KB" {
+			t.Errorf("Expected 2.1KB, got %s", got)
+		}
+	})
+
+	t.Run("Failure Reason", func(t *testing.T) {
+		cases := map[string]string{
+			"# app\n./main.go:12:5: undefined: serve\n[ERROR] exit status 1": "./main.go:12:5: undefined: serve",
+			"checking\nstill wrong\n[ERROR] exit status 3":                   "still wrong",
+			"\n[ERROR] exit status 2":                                        "exit status 2",
+			"Error executing tool: denied\n":                                 "Error executing tool: denied",
+		}
+		for output, want := range cases {
+			if got := failureReason(output); got != want {
+				t.Errorf("failureReason(%q) = %q, want %q", output, got, want)
+			}
 		}
 	})
 
@@ -73,6 +124,57 @@ func TestProxy_HandleToolCalls(t *testing.T) {
 		}
 	})
 
+	t.Run("Background Writes", func(t *testing.T) {
+		calls := []provider.ToolCall{{ID: "call-5", Name: "run_shell", Args: `{"cmd": "echo stored"}`}}
+		if _, err := p.HandleToolCalls(context.Background(), "sess-mcp", calls); err != nil {
+			t.Fatalf("HandleToolCalls failed: %v", err)
+		}
+		if err := p.FlushArtifacts("sess-mcp"); err != nil {
+			t.Fatalf("FlushArtifacts failed: %v", err)
+		}
+		artifacts, _ := s.ListArtifacts("sess-mcp")
+		found := false
+		for _, a := range artifacts {
+			found = found || strings.Contains(a.Path, "run_shell_call-5-")
+		}
+		if !found {
+			t.Error("Expected the output stored once flushed")
+		}
+
+		// The size limit is applied before queuing, so the digest tells the agent
+		s.SetMaxArtifactSize(3)
+		defer s.SetMaxArtifactSize(store.DefaultMaxArtifactSize)
+		results, _ := p.HandleToolCalls(context.Background(), "sess-mcp", calls)
+		if !strings.Contains(results[0].Digest, "Output too large to store") || results[0].Ref != "" {
+			t.Errorf("Expected the oversized output left unstored, got %+v", results[0])
+		}
+
+		failing := NewProxy(failingStore{s}, g)
+		if _, err := failing.HandleToolCalls(context.Background(), "sess-mcp", calls); err != nil {
+			t.Fatalf("HandleToolCalls failed: %v", err)
+		}
+		if err := failing.FlushArtifacts("sess-other"); err != nil {
+			t.Errorf("Expected another session's flush to miss the failure, got %v", err)
+		}
+		if err := failing.FlushArtifacts("sess-mcp"); err == nil || !strings.Contains(err.Error(), "disk full") {
+			t.Errorf("Expected the failed write reported on flush, got %v", err)
+		}
+		if err := failing.FlushArtifacts("sess-mcp"); err != nil {
+			t.Errorf("Expected the failure reported once, got %v", err)
+		}
+
+		// Closing stores what is queued and reports what wasn't flushed
+		if _, err := failing.HandleToolCalls(context.Background(), "sess-mcp", calls); err != nil {
+			t.Fatalf("HandleToolCalls failed: %v", err)
+		}
+		if err := failing.CloseArtifacts("sess-mcp"); err == nil || !strings.Contains(err.Error(), "disk full") {
+			t.Errorf("Expected the failed write reported on close, got %v", err)
+		}
+		if err := failing.CloseArtifacts("sess-mcp"); err != nil {
+			t.Errorf("Expected a closed session to have nothing left, got %v", err)
+		}
+	})
+
 	t.Run("Unknown Tool", func(t *testing.T) {
 		calls := []provider.ToolCall{
 			{ID: "call-4", Name: "unknown", Args: `{}`},
@@ -87,4 +189,244 @@ func TestProxy_HandleToolCalls(t *testing.T) {
 			t.Error("Expected error for unknown tool")
 		}
 	})
-}
\ No newline at end of file
+}
+
+// failingStore fails every artifact write.
+type failingStore struct {
+	store.Storage
+}
+
+func (failingStore) SaveArtifact(*store.Artifact, []byte) error {
+	return fmt.Errorf("disk full")
+}
+
+func TestBlockedError(t *testing.T) {
+	allowed := map[string]bool{"cat": true, "grep": true}
+	e := commandBlocked("allowed_commands", "head", []string{"grep", "cat"}, func(cmd string) bool { return allowed[cmd] })
+	msg := e.Error()
+	for _, want := range []string{"Allowed: cat, grep", "use cat instead of head", "change the approach"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("Expected %q in %q", want, msg)
+		}
+	}
+
+	e = commandBlocked("allowed_commands", "find", nil, func(string) bool { return false })
+	if strings.Contains(e.Error(), "Allowed:") || !strings.Contains(e.Suggestion, "write_file") {
+		t.Errorf("Expected no alternatives beyond the generic suggestion, got %q", e.Error())
+	}
+	if !IsBlocked(fmt.Errorf("wrapped: %w", e)) {
+		t.Error("Expected IsBlocked to see through wrapping")
+	}
+}
+
+func TestProxy_Scope(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "mcp-test-*")
+	defer os.RemoveAll(tmpDir)
+
+	s, _ := store.NewSQLiteStore(filepath.Join(tmpDir, "db"), filepath.Join(tmpDir, "artifacts"))
+	g := guard.New(guard.Policy{
+		AllowedCommands: []string{"echo", "ls", "env"},
+	})
+	p := NewProxy(s, g)
+	defer p.CloseArtifacts("sess-scope")
+	s.CreateSession(&store.Session{ID: "sess-scope", CreatedAt: time.Now()})
+
+	p.SetScope("sess-scope", Scope{
+		Env:             map[string]string{"CI": "true"},
+		AllowedCommands: []string{"echo", "env"},
+	})
+
+	t.Run("Spec Narrows Policy", func(t *testing.T) {
+		calls := []provider.ToolCall{{ID: "call-1", Name: "run_shell", Args: `{"cmd": "ls"}`}}
+		results, _ := p.HandleToolCalls(context.Background(), "sess-scope", calls)
+		if !results[0].IsError {
+			t.Error("Expected ls to be blocked by spec whitelist")
+		}
+		if !strings.Contains(results[0].Digest, "Allowed: echo, env") {
+			t.Errorf("Expected the spec's commands as alternatives, got %s", results[0].Digest)
+		}
+	})
+
+	t.Run("Spec Env Passed", func(t *testing.T) {
+		calls := []provider.ToolCall{{ID: "call-2", Name: "run_shell", Args: `{"cmd": "env"}`}}
+		results, _ := p.HandleToolCalls(context.Background(), "sess-scope", calls)
+		if results[0].IsError {
+			t.Fatalf("Unexpected error: %s", results[0].Digest)
+		}
+		if !strings.Contains(results[0].Digest, "CI=true") {
+			t.Errorf("Expected CI=true in env output, got %s", results[0].Digest)
+		}
+	})
+}
+
+// toolMap is a ToolSet of fixed executors.
+type toolMap map[string]ToolFunc
+
+func (m toolMap) Executor(name string) (ToolFunc, bool) {
+	run, ok := m[name]
+	return run, ok
+}
+
+func TestProxy_SetTools(t *testing.T) {
+	tmpDir := t.TempDir()
+	s, _ := store.NewSQLiteStore(filepath.Join(tmpDir, "db"), filepath.Join(tmpDir, "artifacts"))
+	defer s.Close()
+	p := NewProxy(s, guard.New(guard.Policy{AllowedCommands: []string{"echo"}}))
+	defer p.CloseArtifacts("sess-tools")
+	s.CreateSession(&store.Session{ID: "sess-tools", CreatedAt: time.Now()})
+
+	tools := toolMap{"run_shell": p.BuiltinTools()["run_shell"]}
+	tools["lookup"] = func(ctx context.Context, sessionID string, call provider.ToolCall) (string, error) {
+		return "found " + call.Args + " for " + sessionID, nil
+	}
+	p.SetTools(tools)
+
+	results, err := p.HandleToolCalls(context.Background(), "sess-tools", []provider.ToolCall{
+		{ID: "call-1", Name: "lookup", Args: "users"},
+		{ID: "call-2", Name: "run_shell", Args: `{"cmd": "echo hi"}`},
+		{ID: "call-3", Name: "run_shell", Args: `{"cmd": "rm -rf /"}`},
+		{ID: "call-4", Name: "git_status"},
+	})
+	if err != nil {
+		t.Fatalf("HandleToolCalls failed: %v", err)
+	}
+	if results[0].IsError || !strings.Contains(results[0].Digest, "found users for sess-tools") || results[0].Ref == "" {
+		t.Errorf("Expected the registered tool run and its output stored, got %+v", results[0])
+	}
+	if results[1].IsError || !strings.Contains(results[1].Digest, "hi") {
+		t.Errorf("Expected the built-in run through the tool set, got %s", results[1].Digest)
+	}
+	if !results[2].IsError || len(results[2].Violations) == 0 {
+		t.Errorf("Expected the guard to check calls of the tool set, got %+v", results[2])
+	}
+	if !results[3].IsError || !strings.Contains(results[3].Digest, "unknown tool") {
+		t.Errorf("Expected tools missing from the set to be unknown, got %s", results[3].Digest)
+	}
+}
+
+func TestProxy_Severities(t *testing.T) {
+	tmpDir := t.TempDir()
+	s, _ := store.NewSQLiteStore(filepath.Join(tmpDir, "db"), filepath.Join(tmpDir, "artifacts"))
+	s.CreateSession(&store.Session{ID: "sess-sev", CreatedAt: time.Now()})
+
+	t.Run("Warn Executes", func(t *testing.T) {
+		p := NewProxy(s, guard.New(guard.Policy{
+			AllowedCommands: []string{"ls"},
+			Severities:      map[string]guard.Severity{"allowed_commands": guard.SeverityWarn},
+		}))
+		defer p.CloseArtifacts("sess-sev")
+		calls := []provider.ToolCall{{ID: "call-1", Name: "run_shell", Args: `{"cmd": "echo warned"}`}}
+		results, err := p.HandleToolCalls(context.Background(), "sess-sev", calls)
+		if err != nil {
+			t.Fatalf("HandleToolCalls failed: %v", err)
+		}
+		if results[0].IsError || !strings.Contains(results[0].Digest, "warned") {
+			t.Errorf("Expected command to run, got %s", results[0].Digest)
+		}
+		if len(results[0].Violations) != 1 || results[0].Violations[0].Severity != guard.SeverityWarn {
+			t.Errorf("Expected one warn violation, got %v", results[0].Violations)
+		}
+	})
+
+	t.Run("Halt Aborts", func(t *testing.T) {
+		p := NewProxy(s, guard.New(guard.Policy{
+			AllowedCommands: []string{"ls"},
+			Severities:      map[string]guard.Severity{"allowed_commands": guard.SeverityHalt},
+		}))
+		defer p.CloseArtifacts("sess-sev")
+		calls := []provider.ToolCall{{ID: "call-2", Name: "run_shell", Args: `{"cmd": "echo nope"}`}}
+		if _, err := p.HandleToolCalls(context.Background(), "sess-sev", calls); !guard.IsHalt(err) {
+			t.Errorf("Expected halting violation error, got %v", err)
+		}
+	})
+}
+
+func TestBuildEnv(t *testing.T) {
+	t.Run("Spec Overrides", func(t *testing.T) {
+		env := buildEnv(guard.EnvPolicy{}, nil, map[string]string{"PATH": "/opt/go/bin:/usr/bin", "GOFLAGS": "-mod=mod"})
+		joined := strings.Join(env, "\n")
+		if !strings.Contains(joined, "PATH=/opt/go/bin:/usr/bin") {
+			t.Errorf("Expected spec PATH to override base, got %v", env)
+		}
+		if !strings.Contains(joined, "GOFLAGS=-mod=mod") || !strings.Contains(joined, "LANG=") {
+			t.Errorf("Expected merged environment, got %v", env)
+		}
+	})
+
+	t.Run("Policy Passthrough", func(t *testing.T) {
+		environ := []string{"GOCACHE=/cache/go", "GITHUB_TOKEN=secret", "AWS_REGION=eu-west-1", "EDITOR=vim", "PATH=/home/me/bin:/usr/bin"}
+		env := buildEnv(guard.DefaultEnvPolicy, environ, nil)
+		joined := strings.Join(env, "\n")
+		if !strings.Contains(joined, "GOCACHE=/cache/go") || !strings.Contains(joined, "PATH=/home/me/bin:/usr/bin") {
+			t.Errorf("Expected allowed variables to pass through, got %v", env)
+		}
+		for _, name := range []string{"GITHUB_TOKEN", "AWS_REGION", "EDITOR"} {
+			if strings.Contains(joined, name+"=") {
+				t.Errorf("Expected %s to be filtered, got %v", name, env)
+			}
+		}
+	})
+
+	t.Run("Path Extensions", func(t *testing.T) {
+		env := buildEnv(guard.EnvPolicy{Path: []string{"/opt/tools/bin"}}, []string{"PATH=/home/me/bin"}, nil)
+		if !strings.Contains(strings.Join(env, "\n"), "PATH=/opt/tools/bin:/usr/local/bin:/usr/bin:/bin") {
+			t.Errorf("Expected the policy PATH entries in front of the base PATH, got %v", env)
+		}
+	})
+}
+
+func TestProxy_Explain(t *testing.T) {
+	p := NewProxy(nil, guard.New(guard.Policy{
+		AllowedCommands:       []string{"go", "ls", "git", "cat"},
+		AllowedFileGlobs:      []string{"internal/**"},
+		DeniedFileGlobs:       []string{"**/.env"},
+		MaxSessionWriteBytes:  10,
+		MaxToolCallsPerMinute: 30,
+		Git:                   guard.GitPolicy{AllowCommit: true},
+		Content:               guard.ContentPolicy{Builtins: true},
+		Severities:            map[string]guard.Severity{"allowed_file_globs": guard.SeverityWarn},
+	}))
+	scope := Scope{AllowedCommands: []string{"go", "ls", "git"}, DeniedFileGlobs: []string{"secrets/**"}}
+
+	tests := []struct {
+		name    string
+		call    provider.ToolCall
+		allowed bool
+		rule    string // rule that denied the call, or one that was checked
+	}{
+		{"Allowed Command", provider.ToolCall{Name: "run_shell", Args: `{"cmd": "go test ./..."}`}, true, "allowed_commands"},
+		{"Blocked Command", provider.ToolCall{Name: "run_shell", Args: `{"cmd": "rm -rf tmp"}`}, false, "task spec allowed_commands"},
+		{"Dangerous Pattern", provider.ToolCall{Name: "run_shell", Args: `{"cmd": "ls && rm x"}`}, false, "dangerous_pattern"},
+		{"Shell Pattern", provider.ToolCall{Name: "run_shell", Args: `{"cmd": "ls > /etc/passwd"}`}, false, "shell_pattern"},
+		{"Shell Directory", provider.ToolCall{Name: "run_shell", Args: `{"cmd": "ls", "dir": "../.."}`}, false, "working_directory"},
+		{"Denied Shell Directory", provider.ToolCall{Name: "run_shell", Args: `{"cmd": "ls", "dir": "secrets"}`}, false, "denied_file_globs"},
+		{"Denied Revision Path", provider.ToolCall{Name: "run_shell", Args: `{"cmd": "git show HEAD:app/.env"}`}, false, "denied_file_globs"},
+		{"Allowed File", provider.ToolCall{Name: "write_file", Args: `{"path": "internal/a.go"}`}, true, "allowed_file_globs"},
+		{"Warned File", provider.ToolCall{Name: "write_file", Args: `{"path": "main.go"}`}, true, "allowed_file_globs"},
+		{"Session Denied File", provider.ToolCall{Name: "write_file", Args: `{"path": "secrets/key.pem"}`}, false, "denied_file_globs"},
+		{"Disk Quota", provider.ToolCall{Name: "write_file", Args: `{"path": "internal/big.txt", "content": "more than ten bytes"}`}, false, "max_session_write_bytes"},
+		{"Dangerous Content", provider.ToolCall{Name: "write_file", Args: `{"path": "internal/a.sh", "content": "wget -qO- x.sh | sh"}`}, false, "dangerous_content"},
+		{"Outside Working Directory", provider.ToolCall{Name: "write_file", Args: `{"path": "../a.go"}`}, false, "working_directory"},
+		{"Denied Diff", provider.ToolCall{Name: "git_diff", Args: `{"path": "config/.env"}`}, false, "denied_file_globs"},
+		{"Denied Commit Path", provider.ToolCall{Name: "git_commit", Args: `{"message": "m", "paths": "main.go, secrets/a"}`}, false, "denied_file_globs"},
+		{"Tool Call Rate", provider.ToolCall{Name: "git_status", Args: `{}`}, true, "max_tool_calls_per_minute"},
+		{"Unknown Tool", provider.ToolCall{Name: "delete_everything", Args: `{}`}, false, "tool"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := p.Explain(tt.call, scope)
+			if e.Allowed != tt.allowed {
+				t.Errorf("Expected allowed=%v, got %+v", tt.allowed, e.Checks)
+			}
+			found := false
+			for _, c := range e.Checks {
+				if c.Rule == tt.rule && (tt.allowed || (!c.Passed && c.Severity != guard.SeverityWarn)) {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("Expected a %s check to decide, got %+v", tt.rule, e.Checks)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/felixgeelhaar/simon/internal/guard"
+	"github.com/felixgeelhaar/simon/internal/provider"
+)
+
+// maxArtifactRead bounds a single read_artifact result, whatever range was asked for.
+const maxArtifactRead = 16 << 10
+
+// readArtifact handles the read_artifact tool: a line or byte range of one
+// of the session's own text artifacts, charged to the session's artifact
+// read budget.
+func (p *Proxy) readArtifact(sessionID string, call provider.ToolCall, report func(*guard.Violation)) (string, error) {
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(call.Args), &args); err != nil {
+		return "", fmt.Errorf("invalid args: %w", err)
+	}
+	ref, _ := args["artifact"].(string)
+	if ref == "" {
+		return "", fmt.Errorf("missing artifact argument")
+	}
+	var nums [4]int
+	for i, name := range []string{"start_line", "end_line", "offset", "length"} {
+		n, err := intArg(args, name)
+		if err != nil {
+			return "", err
+		}
+		nums[i] = n
+	}
+	startLine, endLine, offset, length := nums[0], nums[1], nums[2], nums[3]
+
+	a, content, err := ResolveArtifact(p.store, sessionID, ref)
+	if err != nil {
+		return "", err
+	}
+	if !a.IsText() {
+		return "", fmt.Errorf("%s is binary (%s) and cannot be read", a.Path, a.MIMEType)
+	}
+
+	// Select the range; lines unless a byte offset or length was given
+	var start, end int
+	var header string
+	if offset > 0 || length > 0 {
+		start = min(offset, len(content))
+		end = len(content)
+		if length > 0 {
+			end = min(start+length, len(content))
+		}
+		end = min(end, start+maxArtifactRead)
+		header = fmt.Sprintf("%s bytes %d-%d of %d", a.Path, start, end, len(content))
+	} else {
+		lines := strings.SplitAfter(string(content), "\n")
+		if lines[len(lines)-1] == "" {
+			lines = lines[:len(lines)-1]
+		}
+		first := max(startLine, 1)
+		last := len(lines)
+		if endLine > 0 {
+			last = min(endLine, len(lines))
+		}
+		for i := 0; i < first-1 && i < len(lines); i++ {
+			start += len(lines[i])
+		}
+		end = start
+		for i := first - 1; i < last; i++ {
+			if end+len(lines[i])-start > maxArtifactRead {
+				last = i
+				break
+			}
+			end += len(lines[i])
+		}
+		if first > last {
+			header = fmt.Sprintf("%s has %d lines; nothing in range", a.Path, len(lines))
+		} else {
+			header = fmt.Sprintf("%s lines %d-%d of %d", a.Path, first, last, len(lines))
+		}
+	}
+	chunk := content[start:end]
+
+	p.mu.Lock()
+	used := p.read[sessionID]
+	p.mu.Unlock()
+	if v := p.guard.CheckArtifactRead(used, len(chunk)); v != nil {
+		report(v)
+		switch v.Severity {
+		case guard.SeverityWarn:
+		case guard.SeverityHalt:
+			return "", &guard.ViolationError{Violation: v}
+		default:
+			return "", p.guardBlocked(v, Scope{})
+		}
+	}
+	p.mu.Lock()
+	p.read[sessionID] += len(chunk)
+	p.mu.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s]\n%s", header, chunk)
+	if end < len(content) {
+		fmt.Fprintf(&b, "\n[%d more bytes; continue with offset %d]", len(content)-end, end)
+	}
+	return b.String(), nil
+}
+
+// intArg reads an optional non-negative integer argument, given as a JSON
+// number or a string.
+func intArg(args map[string]interface{}, name string) (int, error) {
+	var n int
+	switch v := args[name].(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		n = int(v)
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return 0, nil
+		}
+		var err error
+		if n, err = strconv.Atoi(strings.TrimSpace(v)); err != nil {
+			return 0, fmt.Errorf("%s must be a number", name)
+		}
+	default:
+		return 0, fmt.Errorf("%s must be a number", name)
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("%s must not be negative", name)
+	}
+	return n, nil
+}
@@ -0,0 +1,226 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/felixgeelhaar/simon/internal/provider"
+)
+
+// defaultDigestTokens is used when the policy does not set max_digest_tokens.
+const defaultDigestTokens = 200
+
+// Reducer condenses raw tool output into a digest that fits within budget tokens.
+// It returns ok=false when it does not recognize the output, so the next reducer is tried.
+type Reducer interface {
+	Reduce(ctx context.Context, output string, budget int) (digest string, ok bool)
+}
+
+// ReducerFunc adapts a function to the Reducer interface.
+type ReducerFunc func(ctx context.Context, output string, budget int) (string, bool)
+
+func (f ReducerFunc) Reduce(ctx context.Context, output string, budget int) (string, bool) {
+	return f(ctx, output, budget)
+}
+
+// ContentReducer matches the Reduce method of plugin.ReducerPlugin.
+type ContentReducer interface {
+	Reduce(ctx context.Context, content []byte) (string, error)
+}
+
+// FromContentReducer wraps an external reducer (e.g. a ReducerPlugin).
+// Errors and empty digests fall through to the next reducer.
+func FromContentReducer(r ContentReducer) Reducer {
+	return ReducerFunc(func(ctx context.Context, output string, budget int) (string, bool) {
+		digest, err := r.Reduce(ctx, []byte(output))
+		if err != nil || strings.TrimSpace(digest) == "" {
+			return "", false
+		}
+		return digest, true
+	})
+}
+
+// Pipeline runs reducers in order and falls back to a head/tail excerpt.
+type Pipeline struct {
+	reducers []Reducer
+}
+
+// NewPipeline creates a pipeline from the given reducers.
+func NewPipeline(reducers ...Reducer) *Pipeline {
+	return &Pipeline{reducers: reducers}
+}
+
+// DefaultPipeline returns the built-in heuristics: test failure extraction, then JSON slicing.
+func DefaultPipeline() *Pipeline {
+	return NewPipeline(ReducerFunc(reduceTestFailures), ReducerFunc(reduceJSON))
+}
+
+// Use adds a reducer ahead of the existing ones.
+func (p *Pipeline) Use(r Reducer) {
+	p.reducers = append([]Reducer{r}, p.reducers...)
+}
+
+// Reduce produces a digest of output sized to budget tokens.
+// Output that already fits is returned unchanged.
+func (p *Pipeline) Reduce(ctx context.Context, output string, budget int) string {
+	if budget <= 0 {
+		budget = defaultDigestTokens
+	}
+	if provider.EstimateTokens(output) <= budget {
+		return output
+	}
+	for _, r := range p.reducers {
+		if digest, ok := r.Reduce(ctx, output, budget); ok {
+			return fitBudget(digest, budget)
+		}
+	}
+	return reduceTail(output, budget)
+}
+
+// failureMarkers identify lines worth keeping from test runner output.
+var failureMarkers = []string{"--- FAIL", "FAIL\t", "FAIL:", "panic:", "FAILED", "AssertionError", "Error Trace:"}
+
+func isFailureLine(line string) bool {
+	for _, m := range failureMarkers {
+		if strings.Contains(line, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// reduceTestFailures keeps failing test names with the lines that follow them,
+// plus the final summary line.
+func reduceTestFailures(_ context.Context, output string, _ int) (string, bool) {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	keep := make(map[int]bool)
+	failures := 0
+	for i, line := range lines {
+		if !isFailureLine(line) {
+			continue
+		}
+		if strings.Contains(line, "--- FAIL") {
+			failures++
+		}
+		keep[i] = true
+		// Assertion messages and stack frames follow the marker
+		span := 4
+		if strings.HasPrefix(strings.TrimSpace(line), "panic:") {
+			span = 8
+		}
+		for j := i + 1; j < len(lines) && j <= i+span; j++ {
+			if strings.HasPrefix(lines[j], "=== RUN") || strings.HasPrefix(lines[j], "--- PASS") {
+				break
+			}
+			keep[j] = true
+		}
+	}
+	if len(keep) == 0 {
+		return "", false
+	}
+	keep[len(lines)-1] = true
+
+	var b strings.Builder
+	if failures > 0 {
+		fmt.Fprintf(&b, "%d failing test(s):\n", failures)
+	}
+	last := -1
+	for i, line := range lines {
+		if !keep[i] {
+			continue
+		}
+		if last >= 0 && i > last+1 {
+			b.WriteString("...\n")
+		}
+		b.WriteString(line + "\n")
+		last = i
+	}
+	return strings.TrimRight(b.String(), "\n"), true
+}
+
+// reduceJSON pretty-prints JSON output and slices it at a line boundary.
+func reduceJSON(_ context.Context, output string, budget int) (string, bool) {
+	trimmed := strings.TrimSpace(output)
+	if !(strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")) || !json.Valid([]byte(trimmed)) {
+		return "", false
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(trimmed), &v); err != nil {
+		return "", false
+	}
+	pretty, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", false
+	}
+
+	lines := strings.Split(string(pretty), "\n")
+	limit := budget * 4
+	var b strings.Builder
+	for i, line := range lines {
+		if b.Len()+len(line)+1 > limit-40 {
+			fmt.Fprintf(&b, "... (%d more lines)", len(lines)-i)
+			break
+		}
+		b.WriteString(line + "\n")
+	}
+	return strings.TrimRight(b.String(), "\n"), true
+}
+
+// reduceTail keeps a short head and a longer tail, since errors usually
+// appear at the end of command output.
+func reduceTail(output string, budget int) string {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	limit := budget*4 - 40 // Leave room for the omission marker
+	headLimit := limit / 5
+	tailLimit := limit - headLimit
+
+	var head []string
+	size := 0
+	for _, line := range lines {
+		if size+len(line)+1 > headLimit {
+			break
+		}
+		head = append(head, line)
+		size += len(line) + 1
+	}
+
+	var tail []string
+	size = 0
+	for i := len(lines) - 1; i >= len(head); i-- {
+		if size+len(lines[i])+1 > tailLimit {
+			break
+		}
+		tail = append([]string{lines[i]}, tail...)
+		size += len(lines[i]) + 1
+	}
+
+	// A single oversized line leaves nothing to keep line-wise
+	if len(head) == 0 && len(tail) == 0 {
+		return fitBudget(output, budget)
+	}
+
+	omitted := len(lines) - len(head) - len(tail)
+	parts := head
+	if omitted > 0 {
+		parts = append(parts, fmt.Sprintf("... [%d lines omitted] ...", omitted))
+	}
+	return fitBudget(strings.Join(append(parts, tail...), "\n"), budget)
+}
+
+// fitBudget hard-truncates digest to roughly budget tokens.
+func fitBudget(digest string, budget int) string {
+	if provider.EstimateTokens(digest) <= budget {
+		return digest
+	}
+	r := []rune(digest)
+	limit := budget * 4
+	if limit > len(r) {
+		limit = len(r)
+	}
+	if limit <= 3 {
+		return string(r[:limit])
+	}
+	return string(r[:limit-3]) + "..."
+}
@@ -0,0 +1,87 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/felixgeelhaar/simon/internal/provider"
+)
+
+type staticReducer struct {
+	digest string
+	err    error
+}
+
+func (r staticReducer) Reduce(ctx context.Context, content []byte) (string, error) {
+	return r.digest, r.err
+}
+
+func TestPipeline_Reduce(t *testing.T) {
+	ctx := context.Background()
+	p := DefaultPipeline()
+
+	t.Run("Short Output Unchanged", func(t *testing.T) {
+		if got := p.Reduce(ctx, "hello", 50); got != "hello" {
+			t.Errorf("Expected unchanged output, got %q", got)
+		}
+	})
+
+	t.Run("Test Failures", func(t *testing.T) {
+		var b strings.Builder
+		for i := 0; i < 100; i++ {
+			fmt.Fprintf(&b, "=== RUN   TestOK%d\n--- PASS: TestOK%d (0.00s)\n", i, i)
+		}
+		b.WriteString("=== RUN   TestBroken\n--- FAIL: TestBroken (0.00s)\n    broken_test.go:12: expected 1, got 2\n")
+		b.WriteString("FAIL\nFAIL\tgithub.com/example/pkg\t0.012s\n")
+
+		got := p.Reduce(ctx, b.String(), 100)
+		if !strings.Contains(got, "1 failing test(s)") || !strings.Contains(got, "expected 1, got 2") {
+			t.Errorf("Expected failure details in digest, got:\n%s", got)
+		}
+		if strings.Contains(got, "TestOK") {
+			t.Errorf("Expected passing tests to be dropped, got:\n%s", got)
+		}
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		items := make([]string, 200)
+		for i := range items {
+			items[i] = fmt.Sprintf(`{"id":%d}`, i)
+		}
+		got := p.Reduce(ctx, "["+strings.Join(items, ",")+"]", 60)
+		if !strings.HasPrefix(got, "[\n  {\n    \"id\": 0") || !strings.Contains(got, "more lines)") {
+			t.Errorf("Expected pretty-printed slice, got:\n%s", got)
+		}
+	})
+
+	t.Run("Tail Kept", func(t *testing.T) {
+		var b strings.Builder
+		for i := 0; i < 500; i++ {
+			fmt.Fprintf(&b, "compiling unit %d\n", i)
+		}
+		b.WriteString("main.go:42: undefined: frobnicate\n")
+		got := p.Reduce(ctx, b.String(), 80)
+		if !strings.Contains(got, "undefined: frobnicate") || !strings.Contains(got, "lines omitted") {
+			t.Errorf("Expected tail with error and omission marker, got:\n%s", got)
+		}
+		if provider.EstimateTokens(got) > 80 {
+			t.Errorf("Digest exceeds budget: %d tokens", provider.EstimateTokens(got))
+		}
+	})
+
+	t.Run("External Reducer", func(t *testing.T) {
+		long := strings.Repeat("noise ", 500)
+		custom := DefaultPipeline()
+		custom.Use(FromContentReducer(staticReducer{err: errors.New("unavailable")}))
+		if got := custom.Reduce(ctx, long, 50); strings.Contains(got, "custom") {
+			t.Errorf("Expected failing reducer to fall through, got %q", got)
+		}
+		custom.Use(FromContentReducer(staticReducer{digest: "custom digest"}))
+		if got := custom.Reduce(ctx, long, 50); got != "custom digest" {
+			t.Errorf("Expected external reducer to win, got %q", got)
+		}
+	})
+}
@@ -0,0 +1,163 @@
+package mcp
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	goruntime "runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/felixgeelhaar/simon/internal/guard"
+)
+
+// Sandbox backends selectable with the policy's sandbox setting.
+const (
+	SandboxNone        = "none"
+	SandboxAuto        = "auto"
+	SandboxFirejail    = "firejail"
+	SandboxSandboxExec = "sandbox-exec"
+)
+
+// Sandbox confines tool processes at the OS level. Wrap rewrites a command
+// line so it runs inside the sandbox.
+type Sandbox interface {
+	Name() string
+	Wrap(name string, args []string) (string, []string)
+}
+
+// SandboxProfile is what a sandbox allows: writing below WritablePaths (plus
+// a private temp directory) and, optionally, network access.
+type SandboxProfile struct {
+	WritablePaths []string
+	Network       bool
+}
+
+// ProfileFromPolicy derives a profile from the Guard policy. Writable paths
+// are the static prefixes of AllowedFileGlobs, resolved against workDir; a
+// glob such as "**" makes the whole working directory writable.
+func ProfileFromPolicy(p guard.Policy, workDir string) SandboxProfile {
+	paths := make(map[string]bool)
+	for _, glob := range p.AllowedFileGlobs {
+		prefix := glob
+		if i := strings.IndexAny(glob, "*?[{"); i >= 0 {
+			prefix = glob[:i]
+			if j := strings.LastIndex(prefix, "/"); j >= 0 {
+				prefix = prefix[:j]
+			} else {
+				prefix = ""
+			}
+		}
+		if !filepath.IsAbs(prefix) {
+			prefix = filepath.Join(workDir, prefix)
+		}
+		// Sandboxes match resolved paths, e.g. /private/tmp rather than /tmp on macOS
+		if resolved, err := filepath.EvalSymlinks(prefix); err == nil {
+			prefix = resolved
+		}
+		paths[filepath.Clean(prefix)] = true
+	}
+
+	profile := SandboxProfile{Network: p.SandboxNetwork}
+	for path := range paths {
+		// Paths below another writable path are already covered
+		covered := false
+		for other := range paths {
+			if other != path && strings.HasPrefix(path, other+string(filepath.Separator)) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			profile.WritablePaths = append(profile.WritablePaths, path)
+		}
+	}
+	sort.Strings(profile.WritablePaths)
+	return profile
+}
+
+// NewSandbox returns the backend for kind. "none" and "" disable sandboxing
+// and return nil. "auto" picks firejail on Linux or sandbox-exec on macOS and
+// returns nil when neither is available.
+func NewSandbox(kind string, profile SandboxProfile) (Sandbox, error) {
+	switch kind {
+	case "", SandboxNone:
+		return nil, nil
+	case SandboxAuto:
+		candidate := SandboxFirejail
+		if goruntime.GOOS == "darwin" {
+			candidate = SandboxSandboxExec
+		}
+		if _, err := exec.LookPath(candidate); err != nil {
+			return nil, nil
+		}
+		return NewSandbox(candidate, profile)
+	case SandboxFirejail, SandboxSandboxExec:
+		path, err := exec.LookPath(kind)
+		if err != nil {
+			return nil, fmt.Errorf("sandbox %s is not installed", kind)
+		}
+		if kind == SandboxFirejail {
+			return &firejail{path: path, profile: profile}, nil
+		}
+		return &sandboxExec{path: path, profile: profile}, nil
+	default:
+		return nil, fmt.Errorf("unknown sandbox %q (want none, auto, firejail, or sandbox-exec)", kind)
+	}
+}
+
+// firejail runs commands with a read-only root filesystem, writable
+// profile paths, a private /tmp, no capabilities, and no network.
+type firejail struct {
+	path    string
+	profile SandboxProfile
+}
+
+func (f *firejail) Name() string { return SandboxFirejail }
+
+func (f *firejail) Wrap(name string, args []string) (string, []string) {
+	return f.path, append(FirejailArgs(f.profile), append([]string{"--", name}, args...)...)
+}
+
+// FirejailArgs renders a profile as firejail options.
+func FirejailArgs(profile SandboxProfile) []string {
+	args := []string{"--quiet", "--noprofile", "--private-tmp", "--caps.drop=all", "--nonewprivs", "--seccomp", "--read-only=/"}
+	for _, path := range profile.WritablePaths {
+		args = append(args, "--read-write="+path)
+	}
+	if !profile.Network {
+		args = append(args, "--net=none")
+	}
+	return args
+}
+
+// sandboxExec runs commands under a macOS Seatbelt profile.
+type sandboxExec struct {
+	path    string
+	profile SandboxProfile
+}
+
+func (s *sandboxExec) Name() string { return SandboxSandboxExec }
+
+func (s *sandboxExec) Wrap(name string, args []string) (string, []string) {
+	return s.path, append([]string{"-p", SeatbeltProfile(s.profile), name}, args...)
+}
+
+// SeatbeltProfile renders a profile in sandbox-exec's policy language:
+// everything is allowed except writes outside the writable and temp
+// directories and, unless enabled, network access.
+func SeatbeltProfile(profile SandboxProfile) string {
+	var b strings.Builder
+	b.WriteString("(version 1)\n(allow default)\n(deny file-write*)\n(allow file-write*\n")
+	b.WriteString("  (literal \"/dev/null\") (literal \"/dev/tty\")\n")
+	b.WriteString("  (subpath \"/private/tmp\") (subpath \"/private/var/folders\")\n")
+	for _, path := range profile.WritablePaths {
+		fmt.Fprintf(&b, "  (subpath %s)\n", strconv.Quote(path))
+	}
+	b.WriteString(")\n")
+	if !profile.Network {
+		b.WriteString("(deny network*)\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,92 @@
+package mcp
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/felixgeelhaar/simon/internal/guard"
+)
+
+// echoSandbox "runs" commands by printing them, to observe the wrapping.
+type echoSandbox struct{}
+
+func (echoSandbox) Name() string { return "echo" }
+
+func (echoSandbox) Wrap(name string, args []string) (string, []string) {
+	return "/bin/echo", append([]string{"sandboxed", filepath.Base(name)}, args...)
+}
+
+func TestProfileFromPolicy(t *testing.T) {
+	wd := t.TempDir()
+	resolved, _ := filepath.EvalSymlinks(wd)
+
+	profile := ProfileFromPolicy(guard.Policy{AllowedFileGlobs: []string{"src/**/*.go", "docs/*.md", "src/internal/*"}}, wd)
+	want := []string{filepath.Join(resolved, "docs"), filepath.Join(resolved, "src")}
+	if strings.Join(profile.WritablePaths, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected %v, got %v", want, profile.WritablePaths)
+	}
+	if profile.Network {
+		t.Error("Expected network to be off by default")
+	}
+
+	profile = ProfileFromPolicy(guard.Policy{AllowedFileGlobs: []string{"**", "build/**"}, SandboxNetwork: true}, wd)
+	if len(profile.WritablePaths) != 1 || profile.WritablePaths[0] != resolved || !profile.Network {
+		t.Errorf("Expected the whole workspace with network, got %+v", profile)
+	}
+}
+
+func TestSandboxProfiles(t *testing.T) {
+	profile := SandboxProfile{WritablePaths: []string{"/work/project"}}
+
+	args := strings.Join(FirejailArgs(profile), " ")
+	for _, want := range []string{"--read-only=/", "--read-write=/work/project", "--net=none", "--private-tmp"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("Expected %s in firejail args: %s", want, args)
+		}
+	}
+
+	sbpl := SeatbeltProfile(profile)
+	if !strings.Contains(sbpl, `(subpath "/work/project")`) || !strings.Contains(sbpl, "(deny network*)") {
+		t.Errorf("Unexpected Seatbelt profile:\n%s", sbpl)
+	}
+	profile.Network = true
+	if strings.Contains(SeatbeltProfile(profile), "network") || strings.Contains(strings.Join(FirejailArgs(profile), " "), "--net=none") {
+		t.Error("Expected network to be allowed")
+	}
+}
+
+func TestNewSandbox(t *testing.T) {
+	for _, kind := range []string{"", SandboxNone} {
+		if sb, err := NewSandbox(kind, SandboxProfile{}); sb != nil || err != nil {
+			t.Errorf("Expected no sandbox for %q, got %v, %v", kind, sb, err)
+		}
+	}
+	if _, err := NewSandbox("docker", SandboxProfile{}); err == nil {
+		t.Error("Expected error for an unknown sandbox")
+	}
+	// auto never fails; it returns nil when no backend is installed
+	if _, err := NewSandbox(SandboxAuto, SandboxProfile{}); err != nil {
+		t.Errorf("Expected auto to fall back, got %v", err)
+	}
+}
+
+func TestProxy_Sandbox(t *testing.T) {
+	policy := guard.DefaultPolicy
+	p := NewProxy(nil, guard.New(policy))
+	p.SetSandbox(echoSandbox{})
+
+	output, err := p.runCommand(context.Background(), Scope{}, "ls -la", "", func(*guard.Violation) {})
+	if err != nil {
+		t.Fatalf("runCommand failed: %v", err)
+	}
+	if strings.TrimSpace(output) != "sandboxed ls -la" {
+		t.Errorf("Expected the command to run through the sandbox, got %q", output)
+	}
+
+	// Guard checks still apply to the original command, not the sandbox binary
+	if _, err := p.runCommand(context.Background(), Scope{}, "rm -rf /", "", func(*guard.Violation) {}); err == nil {
+		t.Error("Expected blocked command to stay blocked inside a sandbox")
+	}
+}
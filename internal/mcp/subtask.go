@@ -0,0 +1,110 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/felixgeelhaar/simon/internal/guard"
+	"github.com/felixgeelhaar/simon/internal/provider"
+)
+
+// SubtaskRequest is a spawn_subtask call: a narrower task delegated to a
+// child session.
+type SubtaskRequest struct {
+	Goal             string
+	DefinitionOfDone string
+	Constraints      []string
+	Evidence         []string
+	Verify           []string
+	// MaxIterations caps the child's iterations; 0 uses the runner's default.
+	MaxIterations int
+}
+
+// SubtaskRunner runs a sub-task on behalf of a parent session and returns
+// the report handed back to the model.
+type SubtaskRunner interface {
+	RunSubtask(ctx context.Context, parentID string, req SubtaskRequest) (string, error)
+}
+
+// SetSubtaskRunner enables the spawn_subtask tool; nil disables it.
+func (p *Proxy) SetSubtaskRunner(sr SubtaskRunner) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.subtasks = sr
+}
+
+// Child returns a proxy for a sub-task session governed by g. It shares the
+// store, reducers, sandbox, and approver, but not the sub-task runner, so
+// children cannot spawn sub-tasks of their own.
+func (p *Proxy) Child(g *guard.Guard) *Proxy {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	child := NewProxy(p.store, g)
+	child.reducer = p.reducer
+	child.sandbox = p.sandbox
+	child.approver = p.approver
+	return child
+}
+
+// spawnSubtask handles the spawn_subtask tool.
+func (p *Proxy) spawnSubtask(ctx context.Context, sessionID string, call provider.ToolCall) (string, error) {
+	p.mu.RLock()
+	runner := p.subtasks
+	p.mu.RUnlock()
+	if runner == nil {
+		return "", fmt.Errorf("spawn_subtask is not available in this session")
+	}
+
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(call.Args), &args); err != nil {
+		return "", fmt.Errorf("invalid args: %w", err)
+	}
+	goal, _ := args["goal"].(string)
+	if strings.TrimSpace(goal) == "" {
+		return "", fmt.Errorf("missing goal argument")
+	}
+	req := SubtaskRequest{
+		Goal:        goal,
+		Constraints: stringList(args["constraints"], "\n"),
+		Evidence:    stringList(args["evidence"], ","),
+		Verify:      stringList(args["verify"], "\n"),
+	}
+	req.DefinitionOfDone, _ = args["definition_of_done"].(string)
+	switch v := args["max_iterations"].(type) {
+	case float64:
+		req.MaxIterations = int(v)
+	case string:
+		if v != "" {
+			n, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil {
+				return "", fmt.Errorf("max_iterations must be a number")
+			}
+			req.MaxIterations = n
+		}
+	}
+	return runner.RunSubtask(ctx, sessionID, req)
+}
+
+// stringList accepts a list argument as a JSON array or as a string of items
+// separated by sep; blank items are dropped.
+func stringList(v interface{}, sep string) []string {
+	var items []string
+	switch v := v.(type) {
+	case string:
+		items = strings.Split(v, sep)
+	case []interface{}:
+		for _, item := range v {
+			items = append(items, fmt.Sprint(item))
+		}
+	}
+	var list []string
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
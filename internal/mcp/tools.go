@@ -0,0 +1,81 @@
+package mcp
+
+import (
+	"context"
+
+	"github.com/felixgeelhaar/simon/internal/guard"
+	"github.com/felixgeelhaar/simon/internal/provider"
+)
+
+// ToolFunc executes a tool call and returns its raw output.
+type ToolFunc func(ctx context.Context, sessionID string, call provider.ToolCall) (string, error)
+
+// ToolSet supplies the executors of the tools a session may call, such as
+// the runtime's tool registry: the proxy's built-in tools and any others
+// registered with it.
+type ToolSet interface {
+	Executor(name string) (ToolFunc, bool)
+}
+
+// SetTools makes the proxy run tool calls with the executors of ts, which
+// should include BuiltinTools; nil runs the built-in tools only. Calls are
+// still guard-checked, stored, and digested by the proxy.
+func (p *Proxy) SetTools(ts ToolSet) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tools = ts
+}
+
+// executor returns the function running the named tool.
+func (p *Proxy) executor(name string) (ToolFunc, bool) {
+	p.mu.RLock()
+	ts := p.tools
+	p.mu.RUnlock()
+	if ts != nil {
+		return ts.Executor(name)
+	}
+	run, ok := p.BuiltinTools()[name]
+	return run, ok
+}
+
+// BuiltinTools returns the executors of the proxy's own tools, by name, for
+// every tool of provider.Tools. They expect the guard's pre-execution checks
+// to have passed, as HandleToolCalls runs them before any executor.
+func (p *Proxy) BuiltinTools() map[string]ToolFunc {
+	return map[string]ToolFunc{
+		"run_shell":  p.runShell,
+		"write_file": p.writeFile,
+		"diff_artifacts": func(ctx context.Context, sessionID string, call provider.ToolCall) (string, error) {
+			// The outputs compared may still be queued
+			p.artifactWriter(sessionID).wait()
+			return p.diffArtifacts(sessionID, call)
+		},
+		"read_artifact": func(ctx context.Context, sessionID string, call provider.ToolCall) (string, error) {
+			p.artifactWriter(sessionID).wait()
+			return p.readArtifact(sessionID, call, reporter(ctx))
+		},
+		"spawn_subtask": p.spawnSubtask,
+		"verify_evidence": func(ctx context.Context, sessionID string, call provider.ToolCall) (string, error) {
+			return p.verifyEvidence(ctx, sessionID, reporter(ctx))
+		},
+		"git_status": func(ctx context.Context, sessionID string, call provider.ToolCall) (string, error) {
+			return p.gitStatus(ctx, sessionID)
+		},
+		"git_diff": p.gitDiff,
+		"git_commit": func(ctx context.Context, sessionID string, call provider.ToolCall) (string, error) {
+			return p.gitCommit(ctx, sessionID, call, reporter(ctx))
+		},
+		"git_branch": p.gitBranch,
+	}
+}
+
+type reportKey struct{}
+
+// reporter returns the function guard violations of the tool call running
+// with ctx are reported to; it drops them outside of HandleToolCalls.
+func reporter(ctx context.Context) func(*guard.Violation) {
+	if report, ok := ctx.Value(reportKey{}).(func(*guard.Violation)); ok {
+		return report
+	}
+	return func(*guard.Violation) {}
+}
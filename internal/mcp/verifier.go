@@ -0,0 +1,234 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/coach"
+	"github.com/felixgeelhaar/simon/internal/guard"
+)
+
+// Verifier runs one type of completion check. The file, command, content,
+// http, and coverage verifiers are built in; plugins add others with
+// RegisterVerifier.
+type Verifier interface {
+	// Name is the check type the verifier handles.
+	Name() string
+	// Check reports the outcome as the item's status. Only a halt-level
+	// guard violation is returned as an error.
+	Check(ctx context.Context, req CheckRequest) (EvidenceStatus, error)
+}
+
+// CheckRequest is one check to run for a session.
+type CheckRequest struct {
+	SessionID string
+	Check     coach.Check
+	// Report receives guard violations raised while checking.
+	Report func(*guard.Violation)
+}
+
+// httpCheckTimeout bounds each request of an http check.
+const httpCheckTimeout = 30 * time.Second
+
+// RegisterVerifier adds v for checks of its type, replacing a built-in or
+// earlier verifier of the same name.
+func (p *Proxy) RegisterVerifier(v Verifier) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.verifiers[v.Name()] = v
+}
+
+// builtinVerifiers returns the verifiers every proxy starts with.
+func builtinVerifiers(p *Proxy) map[string]Verifier {
+	verifiers := make(map[string]Verifier)
+	for _, v := range []Verifier{fileVerifier{}, commandVerifier{p}, contentVerifier{}, httpVerifier{client: &http.Client{Timeout: httpCheckTimeout}}, coverageVerifier{p}} {
+		verifiers[v.Name()] = v
+	}
+	return verifiers
+}
+
+// CheckKind is the report kind of a check type: file checks are reported as
+// "evidence" and command checks as "verify", as their spec lists are named.
+func CheckKind(checkType string) string {
+	switch checkType {
+	case coach.CheckFile:
+		return "evidence"
+	case coach.CheckCommand:
+		return "verify"
+	default:
+		return checkType
+	}
+}
+
+// RunCheck runs a check with the verifier registered for its type. A type
+// with no verifier is reported as an error item.
+func (p *Proxy) RunCheck(ctx context.Context, sessionID string, check coach.Check, report func(*guard.Violation)) (EvidenceStatus, error) {
+	p.mu.RLock()
+	v, ok := p.verifiers[check.Type]
+	p.mu.RUnlock()
+	if !ok {
+		return EvidenceStatus{Kind: CheckKind(check.Type), Item: check.Target, Status: EvidenceError,
+			Detail: fmt.Sprintf("no verifier for check type %q", check.Type)}, nil
+	}
+	item, err := v.Check(ctx, CheckRequest{SessionID: sessionID, Check: check, Report: report})
+	item.Kind, item.Item = CheckKind(check.Type), check.Target
+	return item, err
+}
+
+// fileVerifier passes when the target path exists.
+type fileVerifier struct{}
+
+func (fileVerifier) Name() string { return coach.CheckFile }
+
+func (fileVerifier) Check(ctx context.Context, req CheckRequest) (EvidenceStatus, error) {
+	item := EvidenceStatus{Status: EvidencePass}
+	if _, err := os.Stat(req.Check.Target); os.IsNotExist(err) {
+		item.Status, item.Detail = EvidenceFail, "missing"
+	} else if err != nil {
+		item.Status, item.Detail = EvidenceError, err.Error()
+	}
+	return item, nil
+}
+
+// commandVerifier runs the target command with Verify and passes when it
+// exits zero.
+type commandVerifier struct {
+	p *Proxy
+}
+
+func (commandVerifier) Name() string { return coach.CheckCommand }
+
+func (c commandVerifier) Check(ctx context.Context, req CheckRequest) (EvidenceStatus, error) {
+	vr, err := c.p.Verify(ctx, req.SessionID, req.Check.Target)
+	for _, v := range vr.Violations {
+		req.Report(v)
+	}
+	item := VerifyStatus(req.Check.Target, vr, err)
+	if guard.IsHalt(err) {
+		return item, err
+	}
+	return item, nil
+}
+
+// contentVerifier passes when the target file matches the Expect pattern.
+type contentVerifier struct{}
+
+func (contentVerifier) Name() string { return coach.CheckContent }
+
+func (contentVerifier) Check(ctx context.Context, req CheckRequest) (EvidenceStatus, error) {
+	pattern, err := regexp.Compile(req.Check.Expect)
+	if err != nil {
+		return EvidenceStatus{Status: EvidenceError, Detail: fmt.Sprintf("invalid expect pattern: %v", err)}, nil
+	}
+	data, err := os.ReadFile(req.Check.Target)
+	switch {
+	case os.IsNotExist(err):
+		return EvidenceStatus{Status: EvidenceFail, Detail: "missing"}, nil
+	case err != nil:
+		return EvidenceStatus{Status: EvidenceError, Detail: err.Error()}, nil
+	case !pattern.Match(data):
+		return EvidenceStatus{Status: EvidenceFail, Detail: fmt.Sprintf("no match for %q", req.Check.Expect)}, nil
+	}
+	return EvidenceStatus{Status: EvidencePass}, nil
+}
+
+// httpVerifier passes when a GET of the target URL answers with the Expect
+// status code, or any 2xx when Expect is empty.
+type httpVerifier struct {
+	client *http.Client
+}
+
+func (httpVerifier) Name() string { return coach.CheckHTTP }
+
+func (h httpVerifier) Check(ctx context.Context, req CheckRequest) (EvidenceStatus, error) {
+	want := 0
+	if req.Check.Expect != "" {
+		code, err := strconv.Atoi(req.Check.Expect)
+		if err != nil {
+			return EvidenceStatus{Status: EvidenceError, Detail: fmt.Sprintf("expect must be a status code, got %q", req.Check.Expect)}, nil
+		}
+		want = code
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, req.Check.Target, nil)
+	if err != nil {
+		return EvidenceStatus{Status: EvidenceError, Detail: err.Error()}, nil
+	}
+	resp, err := h.client.Do(httpReq)
+	if err != nil {
+		return EvidenceStatus{Status: EvidenceFail, Detail: lastChars(err.Error(), maxEvidenceDetail)}, nil
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+
+	ok := resp.StatusCode >= 200 && resp.StatusCode < 300
+	if want != 0 {
+		ok = resp.StatusCode == want
+	}
+	if !ok {
+		return EvidenceStatus{Status: EvidenceFail, Detail: fmt.Sprintf("status %s", resp.Status)}, nil
+	}
+	return EvidenceStatus{Status: EvidencePass}, nil
+}
+
+// coverageVerifier runs `go test -cover` on the target packages with Verify
+// and passes when the tests pass and every package that reports coverage
+// reaches the Expect percentage. Packages without test files report 0%
+// coverage, so they fail any minimum above zero.
+type coverageVerifier struct {
+	p *Proxy
+}
+
+func (coverageVerifier) Name() string { return coach.CheckCoverage }
+
+// coverageLine matches a package's result in `go test -cover` output, such
+// as "ok  	example.com/pkg	0.01s	coverage: 81.2% of statements".
+var coverageLine = regexp.MustCompile(`(?m)^\s*(?:ok\s+)?(\S+)\s.*coverage: (\d+(?:\.\d+)?)% of statements`)
+
+func (c coverageVerifier) Check(ctx context.Context, req CheckRequest) (EvidenceStatus, error) {
+	min, err := strconv.ParseFloat(req.Check.Expect, 64)
+	if err != nil {
+		return EvidenceStatus{Status: EvidenceError, Detail: fmt.Sprintf("expect must be a percentage, got %q", req.Check.Expect)}, nil
+	}
+	command := "go test -cover " + req.Check.Target
+	vr, err := c.p.Verify(ctx, req.SessionID, command)
+	for _, v := range vr.Violations {
+		req.Report(v)
+	}
+	if err != nil || !vr.Passed {
+		item := VerifyStatus(command, vr, err)
+		if guard.IsHalt(err) {
+			return item, err
+		}
+		return item, nil
+	}
+
+	return coverageStatus(vr.Output, min, vr.ArtifactPath), nil
+}
+
+// coverageStatus judges `go test -cover` output against min, naming the
+// packages below it.
+func coverageStatus(output string, min float64, artifactPath string) EvidenceStatus {
+	matches := coverageLine.FindAllStringSubmatch(output, -1)
+	if len(matches) == 0 {
+		return EvidenceStatus{Status: EvidenceError, Detail: "no coverage reported", Output: artifactPath}
+	}
+	var below []string
+	for _, m := range matches {
+		if pct, _ := strconv.ParseFloat(m[2], 64); pct < min {
+			below = append(below, fmt.Sprintf("%s %s%%", m[1], m[2]))
+		}
+	}
+	if len(below) > 0 {
+		detail := fmt.Sprintf("below %g%%: %s", min, strings.Join(below, ", "))
+		return EvidenceStatus{Status: EvidenceFail, Detail: lastChars(detail, maxEvidenceDetail), Output: artifactPath}
+	}
+	return EvidenceStatus{Status: EvidencePass, Output: artifactPath}
+}
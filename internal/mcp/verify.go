@@ -0,0 +1,173 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/coach"
+	"github.com/felixgeelhaar/simon/internal/guard"
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+// VerificationResult is the outcome of a command run by the verifier.
+type VerificationResult struct {
+	Command string
+	Passed  bool
+	// Output is the command's full output, as stored in the artifact.
+	Output string
+	// Excerpt is a digest of the output suitable for a corrective prompt.
+	Excerpt      string
+	ArtifactPath string
+	Violations   []*guard.Violation
+}
+
+// Verify runs a verification command from the task spec on behalf of the
+// verifier, under the same guard checks and session scope as agent tool calls.
+// The full output is stored as a "verification" artifact. A command that exits
+// non-zero or times out yields Passed=false; a command that cannot run at all
+// (e.g. rejected by the guard) returns an error.
+func (p *Proxy) Verify(ctx context.Context, sessionID, command string) (*VerificationResult, error) {
+	return p.runRecorded(ctx, sessionID, command, "verification", "verify")
+}
+
+// RunHook runs a lifecycle hook from the task spec like Verify, storing its
+// output as a "hook" artifact named after the hook point (e.g. post_iteration).
+func (p *Proxy) RunHook(ctx context.Context, sessionID, point, command string) (*VerificationResult, error) {
+	return p.runRecorded(ctx, sessionID, command, "hook", point)
+}
+
+// runRecorded runs a command on behalf of simon rather than the agent and
+// stores its output as an artifact of the given type.
+func (p *Proxy) runRecorded(ctx context.Context, sessionID, command, artifactType, name string) (*VerificationResult, error) {
+	res := &VerificationResult{Command: command}
+	output, err := p.runCommand(ctx, p.scope(sessionID), command, "", func(v *guard.Violation) {
+		res.Violations = append(res.Violations, v)
+	})
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		res.Passed = true
+	case errors.As(err, &exitErr):
+		output += fmt.Sprintf("\n[ERROR] %v", err)
+	case output == "":
+		return res, err
+	}
+	// Timeouts keep their partial output and count as a failure
+
+	uniqueID := fmt.Sprintf("%s-%d", name, time.Now().UnixNano())
+	res.ArtifactPath = fmt.Sprintf("artifacts/%s/%s_%s.txt", sessionID, artifactType, uniqueID)
+	artifact := &store.Artifact{
+		ID:        fmt.Sprintf("art-%s-%s", sessionID, uniqueID),
+		SessionID: sessionID,
+		Path:      res.ArtifactPath,
+		Type:      artifactType,
+		CreatedAt: time.Now(),
+		Digest:    p.hash(output),
+	}
+	if err := p.store.SaveArtifact(artifact, []byte("$ "+command+"\n"+output)); err != nil {
+		return res, fmt.Errorf("failed to save artifact: %w", err)
+	}
+
+	res.Output = output
+	res.Excerpt = p.reducer.Reduce(ctx, output, p.guard.Policy().MaxDigestTokens)
+	return res, nil
+}
+
+// Evidence check statuses reported by verify_evidence.
+const (
+	EvidencePass  = "pass"
+	EvidenceFail  = "fail"
+	EvidenceError = "error"
+	// EvidenceSkipped marks a check not run because an earlier check
+	// already failed.
+	EvidenceSkipped = "skipped"
+)
+
+// maxEvidenceDetail bounds each item's detail so a report of several items
+// still fits in a tool digest.
+const maxEvidenceDetail = 160
+
+// EvidenceStatus is the state of one completion check.
+type EvidenceStatus struct {
+	Kind   string `json:"kind"` // "evidence", "verify", or the check type (see CheckKind)
+	Item   string `json:"item"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// Output is the artifact holding a verify command's full output.
+	Output string `json:"output,omitempty"`
+	// Excerpt is the digest of a failed verify command's output, for the
+	// corrective prompt.
+	Excerpt string `json:"-"`
+}
+
+// EvidenceReport is the result of checking a session's completion criteria.
+type EvidenceReport struct {
+	Passed bool             `json:"passed"`
+	Items  []EvidenceStatus `json:"items"`
+}
+
+// Add appends an item; any status but pass fails the report.
+func (r *EvidenceReport) Add(item EvidenceStatus) {
+	if item.Status != EvidencePass {
+		r.Passed = false
+	}
+	r.Items = append(r.Items, item)
+}
+
+// VerifyStatus describes the outcome of running a verify command with Verify.
+func VerifyStatus(command string, vr *VerificationResult, err error) EvidenceStatus {
+	item := EvidenceStatus{Kind: "verify", Item: command, Status: EvidencePass}
+	switch {
+	case err != nil:
+		item.Status, item.Detail = EvidenceError, err.Error()
+	case !vr.Passed:
+		item.Status, item.Detail, item.Output = EvidenceFail, lastChars(vr.Excerpt, maxEvidenceDetail), vr.ArtifactPath
+		item.Excerpt = vr.Excerpt
+	}
+	return item
+}
+
+// CheckEvidence runs every completion check in the session's scope, without
+// stopping at the first failure. Only a halt-level guard violation is
+// returned as an error.
+func (p *Proxy) CheckEvidence(ctx context.Context, sessionID string, report func(*guard.Violation)) (*EvidenceReport, error) {
+	scope := p.scope(sessionID)
+	res := &EvidenceReport{Passed: true, Items: []EvidenceStatus{}}
+	for _, check := range coach.CompletionChecks(scope.Evidence, scope.Verify, scope.Checks) {
+		item, err := p.RunCheck(ctx, sessionID, check, report)
+		if guard.IsHalt(err) {
+			return res, err
+		}
+		res.Add(item)
+	}
+	return res, nil
+}
+
+// verifyEvidence handles the verify_evidence tool.
+func (p *Proxy) verifyEvidence(ctx context.Context, sessionID string, report func(*guard.Violation)) (string, error) {
+	res, err := p.CheckEvidence(ctx, sessionID, report)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(res)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// lastChars keeps the end of s, where command errors usually are.
+func lastChars(s string, n int) string {
+	s = strings.TrimSpace(s)
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return "..." + string(r[len(r)-n:])
+}
@@ -0,0 +1,169 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/coach"
+	"github.com/felixgeelhaar/simon/internal/guard"
+	"github.com/felixgeelhaar/simon/internal/provider"
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+func TestProxy_Verify(t *testing.T) {
+	tmpDir := t.TempDir()
+	s, _ := store.NewSQLiteStore(filepath.Join(tmpDir, "db"), filepath.Join(tmpDir, "artifacts"))
+	defer s.Close()
+	p := NewProxy(s, guard.New(guard.Policy{AllowedCommands: []string{"echo", "ls"}}))
+	defer p.CloseArtifacts("sess-verify")
+	s.CreateSession(&store.Session{ID: "sess-verify", CreatedAt: time.Now()})
+
+	t.Run("Passing Command", func(t *testing.T) {
+		res, err := p.Verify(context.Background(), "sess-verify", "echo ok")
+		if err != nil {
+			t.Fatalf("Verify failed: %v", err)
+		}
+		if !res.Passed || !strings.Contains(res.Excerpt, "ok") {
+			t.Errorf("Expected passing verification, got %+v", res)
+		}
+	})
+
+	t.Run("Failing Command", func(t *testing.T) {
+		res, err := p.Verify(context.Background(), "sess-verify", "ls "+filepath.Join(tmpDir, "missing"))
+		if err != nil {
+			t.Fatalf("Verify failed: %v", err)
+		}
+		if res.Passed {
+			t.Fatal("Expected failing verification")
+		}
+		if !strings.Contains(res.Excerpt, "missing") || !strings.Contains(res.Excerpt, "exit status") {
+			t.Errorf("Expected failure excerpt, got %q", res.Excerpt)
+		}
+
+		artifacts, _ := s.ListArtifacts("sess-verify")
+		found := false
+		for _, a := range artifacts {
+			if a.Type == "verification" && a.Path == res.ArtifactPath {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expected verification artifact at %s", res.ArtifactPath)
+		}
+	})
+
+	t.Run("Guard Rejected", func(t *testing.T) {
+		res, err := p.Verify(context.Background(), "sess-verify", "rm -rf /")
+		if err == nil {
+			t.Fatal("Expected guard to reject the command")
+		}
+		if len(res.Violations) != 1 || res.Violations[0].Rule != "allowed_commands" {
+			t.Errorf("Expected allowed_commands violation, got %+v", res.Violations)
+		}
+	})
+	t.Run("Verify Evidence Tool", func(t *testing.T) {
+		present := filepath.Join(tmpDir, "present.txt")
+		os.WriteFile(present, []byte("ok"), 0600)
+		p.SetScope("sess-verify", Scope{
+			Evidence: []string{present, filepath.Join(tmpDir, "absent.txt")},
+			Verify:   []string{"echo ok", "ls " + filepath.Join(tmpDir, "missing"), "rm -rf /"},
+		})
+		defer p.SetScope("sess-verify", Scope{})
+
+		results, err := p.HandleToolCalls(context.Background(), "sess-verify", []provider.ToolCall{{ID: "call_v", Name: "verify_evidence", Args: "{}"}})
+		if err != nil || len(results) != 1 || results[0].IsError {
+			t.Fatalf("verify_evidence failed: %v, %+v", err, results)
+		}
+
+		_, digest, _ := strings.Cut(results[0].Digest, "Summary: ")
+		var whole EvidenceReport
+		if err := json.Unmarshal([]byte(digest), &whole); err != nil || len(whole.Items) != 5 {
+			t.Errorf("Expected the whole report in the digest, got %q (%v)", digest, err)
+		}
+
+		res, _ := p.CheckEvidence(context.Background(), "sess-verify", func(*guard.Violation) {})
+		var got []string
+		for _, item := range res.Items {
+			got = append(got, item.Kind+" "+item.Status)
+		}
+		want := "evidence pass,evidence fail,verify pass,verify fail,verify error"
+		if res.Passed || strings.Join(got, ",") != want {
+			t.Errorf("Expected %s, got %v (passed=%v)", want, got, res.Passed)
+		}
+		if res.Items[3].Output == "" || !strings.Contains(res.Items[3].Detail, "exit status") {
+			t.Errorf("Expected the failing command's output and excerpt, got %+v", res.Items[3])
+		}
+	})
+	t.Run("Checks", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/healthz" {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		defer server.Close()
+		changelog := filepath.Join(tmpDir, "CHANGELOG.md")
+		os.WriteFile(changelog, []byte("## v1.2.0\n"), 0600)
+		p.RegisterVerifier(stubVerifier{})
+		p.SetScope("sess-verify", Scope{Checks: []coach.Check{
+			{Type: coach.CheckContent, Target: changelog, Expect: `v1\.2`},
+			{Type: coach.CheckContent, Target: changelog, Expect: `v2\.0`},
+			{Type: coach.CheckHTTP, Target: server.URL + "/healthz"},
+			{Type: coach.CheckHTTP, Target: server.URL + "/ready", Expect: "200"},
+			{Type: "staging-health", Target: "api"},
+			{Type: "unknown", Target: "x"},
+			{Type: coach.CheckCoverage, Target: "./...", Expect: "80"},
+		}})
+		defer p.SetScope("sess-verify", Scope{})
+
+		res, err := p.CheckEvidence(context.Background(), "sess-verify", func(*guard.Violation) {})
+		if err != nil {
+			t.Fatalf("CheckEvidence failed: %v", err)
+		}
+		var got []string
+		for _, item := range res.Items {
+			got = append(got, item.Kind+" "+item.Status)
+		}
+		// go is not an allowed command, so the coverage check can't run
+		want := "content pass,content fail,http pass,http fail,staging-health fail,unknown error,coverage error"
+		if res.Passed || strings.Join(got, ",") != want {
+			t.Errorf("Expected %s, got %v (passed=%v)", want, got, res.Passed)
+		}
+		if !strings.Contains(res.Items[3].Detail, "503") || res.Items[4].Detail != "api is unhealthy" {
+			t.Errorf("Expected failure details, got %+v", res.Items)
+		}
+	})
+}
+
+func TestCoverageStatus(t *testing.T) {
+	output := "ok  \texample.com/app/store\t0.012s\tcoverage: 84.2% of statements\n" +
+		"ok  \texample.com/app/api\t(cached)\tcoverage: 61.5% of statements\n" +
+		"\texample.com/app/cmd\t\tcoverage: 0.0% of statements\n" +
+		"ok  \texample.com/app/types\t0.002s\tcoverage: [no statements]\n"
+
+	item := coverageStatus(output, 80, "artifacts/s/verification.txt")
+	if item.Status != EvidenceFail || item.Detail != "below 80%: example.com/app/api 61.5%, example.com/app/cmd 0.0%" {
+		t.Errorf("Expected the packages below 80%% to fail the check, got %+v", item)
+	}
+	if item := coverageStatus(output, 0, ""); item.Status != EvidencePass {
+		t.Errorf("Expected a zero minimum to pass, got %+v", item)
+	}
+	if item := coverageStatus("ok  \texample.com/app\t0.01s\n", 80, ""); item.Status != EvidenceError {
+		t.Errorf("Expected output without coverage to be an error, got %+v", item)
+	}
+}
+
+// stubVerifier fails every check of type staging-health.
+type stubVerifier struct{}
+
+func (stubVerifier) Name() string { return "staging-health" }
+
+func (stubVerifier) Check(ctx context.Context, req CheckRequest) (EvidenceStatus, error) {
+	return EvidenceStatus{Status: EvidenceFail, Detail: req.Check.Target + " is unhealthy"}, nil
+}
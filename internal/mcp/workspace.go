@@ -0,0 +1,221 @@
+package mcp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Snapshot limits keep the pre-session scan cheap in large workspaces.
+var (
+	// maxSnapshotFiles stops the scan; beyond it only write tools are tracked.
+	maxSnapshotFiles = 20000
+	// maxSnapshotFileSize is the largest file whose content is kept for diffs.
+	maxSnapshotFileSize int64 = 256 * 1024
+	// maxSnapshotBytes caps the total content kept in memory.
+	maxSnapshotBytes int64 = 32 * 1024 * 1024
+)
+
+// skippedDirs are never scanned for changes.
+var skippedDirs = map[string]bool{".git": true, ".hg": true, ".svn": true, "node_modules": true}
+
+// FileChange is one entry of a session's file change manifest.
+type FileChange struct {
+	Path   string `json:"path"`
+	Action string `json:"action"` // created, modified, or deleted
+	// Source is "write_file" when the agent used a write tool on the path,
+	// "workspace" when the change was only found by comparing snapshots
+	// (e.g. made by a shell command).
+	Source string `json:"source"`
+	Diff   string `json:"diff,omitempty"`
+}
+
+type snapshotEntry struct {
+	size    int64
+	modTime time.Time
+	content []byte // nil when the file is binary or too large
+}
+
+// Snapshot records the state of a workspace so changes can be listed later.
+type Snapshot struct {
+	root      string
+	files     map[string]snapshotEntry
+	truncated bool
+}
+
+// SnapshotWorkspace scans root, keeping the content of small text files.
+func SnapshotWorkspace(root string) (*Snapshot, error) {
+	s := &Snapshot{root: root, files: make(map[string]snapshotEntry)}
+	var kept int64
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Unreadable entries are skipped, not fatal
+		}
+		if d.IsDir() {
+			if path != root && skippedDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if len(s.files) >= maxSnapshotFiles {
+			s.truncated = true
+			return filepath.SkipAll
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		entry := snapshotEntry{size: info.Size(), modTime: info.ModTime()}
+		if info.Size() <= maxSnapshotFileSize && kept+info.Size() <= maxSnapshotBytes {
+			if data, err := os.ReadFile(path); err == nil && isText(data) { // #nosec G304 -- path comes from walking the workspace
+				entry.content = data
+				kept += int64(len(data))
+			}
+		}
+		s.files[filepath.ToSlash(rel)] = entry
+		return nil
+	})
+	return s, err
+}
+
+// WorkspaceFingerprint summarizes the names, sizes, and modification times of
+// the files under root, skipping the same directories as SnapshotWorkspace,
+// so that polling can tell when anything changed without reading contents.
+func WorkspaceFingerprint(root string) (string, error) {
+	h := sha256.New()
+	files := 0
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != root && skippedDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if files++; files > maxSnapshotFiles {
+			return filepath.SkipAll
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%d\n", path, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	return hex.EncodeToString(h.Sum(nil)), err
+}
+
+// DiskUsage totals the sizes of every regular file under root. Unlike
+// SnapshotWorkspace it skips no directory and has no file limit, since
+// what run_shell writes into .git or node_modules counts toward the disk
+// quota too; run_shell calls are charged the growth between two
+// measurements.
+func DiskUsage(root string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total, err
+}
+
+// Truncated reports whether the workspace was too large to scan completely.
+func (s *Snapshot) Truncated() bool {
+	return s.truncated
+}
+
+// Changes compares the workspace against the snapshot. Paths in written
+// (relative, slash-separated) are attributed to write tools and listed even
+// when the scan could not see them.
+func (s *Snapshot) Changes(written map[string]bool) ([]FileChange, error) {
+	after, err := SnapshotWorkspace(s.root)
+	if err != nil {
+		return nil, err
+	}
+
+	source := func(path string) string {
+		if written[path] {
+			return "write_file"
+		}
+		return "workspace"
+	}
+	var changes []FileChange
+	seen := make(map[string]bool)
+	for path, now := range after.files {
+		seen[path] = true
+		before, existed := s.files[path]
+		switch {
+		case !existed && s.truncated && !written[path]:
+			// Not in a partial snapshot; it may well be an old file
+		case !existed:
+			changes = append(changes, FileChange{Path: path, Action: "created", Source: source(path), Diff: diffEntry(path, nil, now)})
+		case before.size != now.size || !before.modTime.Equal(now.modTime):
+			if before.content != nil && now.content != nil && bytes.Equal(before.content, now.content) {
+				continue
+			}
+			changes = append(changes, FileChange{Path: path, Action: "modified", Source: source(path), Diff: diffEntry(path, &before, now)})
+		}
+	}
+	for path, before := range s.files {
+		if seen[path] || after.truncated {
+			continue
+		}
+		seen[path] = true
+		changes = append(changes, FileChange{Path: path, Action: "deleted", Source: source(path), Diff: diffEntry(path, &before, snapshotEntry{content: []byte{}})})
+	}
+	for path := range written {
+		if !seen[path] {
+			changes = append(changes, FileChange{Path: path, Action: "modified", Source: "write_file"})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// diffEntry renders a diff when both sides have text content; a nil before
+// means the file is new.
+func diffEntry(path string, before *snapshotEntry, after snapshotEntry) string {
+	var old []byte
+	if before != nil {
+		if before.content == nil {
+			return ""
+		}
+		old = before.content
+	}
+	if after.content == nil {
+		return ""
+	}
+	return UnifiedDiff(path, string(old), string(after.content))
+}
+
+// isText treats content without NUL bytes in its first 8KB as text.
+func isText(data []byte) bool {
+	if len(data) > 8192 {
+		data = data[:8192]
+	}
+	return bytes.IndexByte(data, 0) < 0
+}
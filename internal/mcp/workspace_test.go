@@ -0,0 +1,109 @@
+package mcp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshot_Changes(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, content string) {
+		path := filepath.Join(root, name)
+		os.MkdirAll(filepath.Dir(path), 0750)
+		os.WriteFile(path, []byte(content), 0600)
+	}
+	write("keep.txt", "unchanged\n")
+	write("edit.txt", "old\n")
+	write("remove.txt", "bye\n")
+	write("image.bin", "\x00\x01")
+	write(".git/HEAD", "ref: refs/heads/main\n")
+
+	snap, err := SnapshotWorkspace(root)
+	if err != nil {
+		t.Fatalf("SnapshotWorkspace failed: %v", err)
+	}
+
+	// Make sure modification times differ on coarse-grained filesystems
+	later := time.Now().Add(time.Second)
+	write("edit.txt", "new\n")
+	os.Chtimes(filepath.Join(root, "edit.txt"), later, later)
+	write("src/new.go", "package src\n")
+	write("image.bin", "\x00\x02")
+	os.Chtimes(filepath.Join(root, "image.bin"), later, later)
+	os.Chtimes(filepath.Join(root, "keep.txt"), later, later) // Touched, same content
+	os.Remove(filepath.Join(root, "remove.txt"))
+	write(".git/HEAD", "ref: refs/heads/other\n")
+
+	changes, err := snap.Changes(map[string]bool{"src/new.go": true})
+	if err != nil {
+		t.Fatalf("Changes failed: %v", err)
+	}
+	got := make(map[string]FileChange)
+	for _, c := range changes {
+		got[c.Path] = c
+	}
+	if len(changes) != 4 {
+		t.Fatalf("Expected 4 changes, got %+v", changes)
+	}
+	if c := got["edit.txt"]; c.Action != "modified" || c.Source != "workspace" || !strings.Contains(c.Diff, "+new") {
+		t.Errorf("Unexpected change for edit.txt: %+v", c)
+	}
+	if c := got["src/new.go"]; c.Action != "created" || c.Source != "write_file" || !strings.Contains(c.Diff, "+package src") {
+		t.Errorf("Unexpected change for src/new.go: %+v", c)
+	}
+	if c := got["remove.txt"]; c.Action != "deleted" || !strings.Contains(c.Diff, "-bye") {
+		t.Errorf("Unexpected change for remove.txt: %+v", c)
+	}
+	if c := got["image.bin"]; c.Action != "modified" || c.Diff != "" {
+		t.Errorf("Expected binary change without diff, got %+v", c)
+	}
+	if changes[0].Path != "edit.txt" {
+		t.Errorf("Expected changes sorted by path, got %s first", changes[0].Path)
+	}
+}
+
+func TestWorkspaceFingerprint(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, ".git"), 0750)
+	os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n"), 0600)
+	fingerprint := func() string {
+		fp, err := WorkspaceFingerprint(root)
+		if err != nil {
+			t.Fatalf("WorkspaceFingerprint failed: %v", err)
+		}
+		return fp
+	}
+
+	before := fingerprint()
+	if fingerprint() != before {
+		t.Fatal("Expected the fingerprint to be stable")
+	}
+	os.WriteFile(filepath.Join(root, ".git", "index"), []byte("ignored"), 0600)
+	if fingerprint() != before {
+		t.Error("Expected changes in skipped directories to be ignored")
+	}
+	os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0600)
+	if fingerprint() == before {
+		t.Error("Expected a modified file to change the fingerprint")
+	}
+}
+
+func TestDiskUsage(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, ".git", "objects"), 0750)
+	os.MkdirAll(filepath.Join(root, "node_modules", "pkg"), 0750)
+	os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n"), 0600)
+	os.WriteFile(filepath.Join(root, ".git", "objects", "ab"), make([]byte, 100), 0600)
+	os.WriteFile(filepath.Join(root, "node_modules", "pkg", "index.js"), make([]byte, 1000), 0600)
+
+	size, err := DiskUsage(root)
+	if err != nil {
+		t.Fatalf("DiskUsage failed: %v", err)
+	}
+	if size != 13+100+1000 {
+		t.Errorf("Expected every file to count, including skipped directories, got %d bytes", size)
+	}
+}
@@ -0,0 +1,217 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/guard"
+	"github.com/felixgeelhaar/simon/internal/provider"
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+// ApprovalRequest describes a proposed file change or shell command awaiting
+// user approval.
+type ApprovalRequest struct {
+	SessionID string
+	Tool      string
+	Path      string
+	// Diff is a unified diff of the proposed change.
+	Diff string
+	// Command is the shell command a run_shell call wants to run; Path is
+	// then its working directory, if one was given.
+	Command string
+	// Warning says why the change needs a closer look, such as content the
+	// guard's content policy flagged; empty for routine approvals.
+	Warning string
+}
+
+// Approver decides whether a proposed change may be applied.
+type Approver interface {
+	Approve(ctx context.Context, req ApprovalRequest) bool
+}
+
+// ApproverFunc adapts a function to the Approver interface.
+type ApproverFunc func(ctx context.Context, req ApprovalRequest) bool
+
+func (f ApproverFunc) Approve(ctx context.Context, req ApprovalRequest) bool {
+	return f(ctx, req)
+}
+
+// SetApprover enables approval mode: file-writing tools send their diff to the
+// approver and only apply it once approved, and run_shell commands only run
+// once approved. A nil approver disables approval.
+func (p *Proxy) SetApprover(a Approver) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.approver = a
+}
+
+func (p *Proxy) currentApprover() Approver {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.approver
+}
+
+// writeFile handles the write_file tool, once execute has checked its path
+// and size against the guard. The proposed change is stored as a diff
+// artifact before it is applied, and the written content afterwards.
+func (p *Proxy) writeFile(ctx context.Context, sessionID string, call provider.ToolCall) (string, error) {
+	var args struct {
+		Path    string `json:"path"`
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal([]byte(call.Args), &args); err != nil {
+		return "", fmt.Errorf("invalid args: %w", err)
+	}
+	if args.Path == "" {
+		return "", fmt.Errorf("missing path argument")
+	}
+	path, err := p.sanitizeFilePath(args.Path)
+	if err != nil {
+		return "", err
+	}
+
+	before, err := os.ReadFile(path) // #nosec G304 -- path is confined to the working directory
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("failed to read %s: %w", args.Path, err)
+	}
+	diff := UnifiedDiff(filepath.ToSlash(args.Path), string(before), args.Content)
+	if diff == "" {
+		return fmt.Sprintf("%s is unchanged", args.Path), nil
+	}
+
+	grow := int64(len(args.Content) - len(before))
+
+	uniqueID := fmt.Sprintf("%s-%d", call.ID, time.Now().UnixNano())
+	if err := p.saveChangeArtifact(sessionID, uniqueID, "proposed_change", "diff", diff); err != nil {
+		return "", err
+	}
+
+	if approver := p.currentApprover(); approver != nil {
+		req := ApprovalRequest{SessionID: sessionID, Tool: call.Name, Path: args.Path, Diff: diff}
+		if reasons := p.guard.ScanContent(args.Content); len(reasons) > 0 {
+			req.Warning = "flagged content: " + strings.Join(reasons, "; ")
+		}
+		if !approver.Approve(ctx, req) {
+			return diff, fmt.Errorf("change to %s rejected by user", args.Path)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(args.Content), 0600); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", args.Path, err)
+	}
+	p.recordWrite(sessionID, path)
+	p.chargeWrite(sessionID, grow)
+	if err := p.saveChangeArtifact(sessionID, uniqueID, "applied_change", "txt", args.Content); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Wrote %d bytes to %s\n%s", len(args.Content), args.Path, diff), nil
+}
+
+// checkWrite applies the write limits to a call writing n bytes that grows
+// the workspace by grow bytes; only growth counts toward the disk quota, so
+// rewriting a file in place doesn't use it up.
+func (p *Proxy) checkWrite(sessionID string, n, grow int64, scope Scope, report func(*guard.Violation)) error {
+	if err := p.enforce(p.guard.CheckWrite(n), scope, report); err != nil {
+		return err
+	}
+	p.mu.RLock()
+	used := p.wrote[sessionID]
+	p.mu.RUnlock()
+	return p.enforce(p.guard.CheckDiskQuota(used, max(grow, 0)), scope, report)
+}
+
+// chargeWrite adds workspace growth to the session's disk quota; shrinking
+// the workspace gives nothing back.
+func (p *Proxy) chargeWrite(sessionID string, grow int64) {
+	if grow <= 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.wrote[sessionID] += grow
+}
+
+// chargeShellWrite applies the write limits to the workspace growth of a
+// run_shell command once it has run. The growth is charged whatever the
+// outcome, since the bytes are already on disk; a violation can only turn
+// the call's result into an error.
+func (p *Proxy) chargeShellWrite(sessionID string, grow int64, scope Scope, report func(*guard.Violation)) error {
+	if grow <= 0 {
+		return nil
+	}
+	err := p.checkWrite(sessionID, grow, grow, scope, report)
+	p.chargeWrite(sessionID, grow)
+	return err
+}
+
+// recordWrite remembers a file changed by a write tool, relative to the
+// working directory, for the session's change manifest.
+func (p *Proxy) recordWrite(sessionID, path string) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return
+	}
+	rel, err := filepath.Rel(wd, path)
+	if err != nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.written[sessionID] == nil {
+		p.written[sessionID] = make(map[string]bool)
+	}
+	p.written[sessionID][filepath.ToSlash(rel)] = true
+}
+
+// WrittenFiles returns the paths changed by write tools during a session.
+func (p *Proxy) WrittenFiles(sessionID string) map[string]bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	files := make(map[string]bool, len(p.written[sessionID]))
+	for path := range p.written[sessionID] {
+		files[path] = true
+	}
+	return files
+}
+
+func (p *Proxy) saveChangeArtifact(sessionID, uniqueID, kind, ext, content string) error {
+	artifact := &store.Artifact{
+		ID:        fmt.Sprintf("art-%s-%s-%s", sessionID, kind, uniqueID),
+		SessionID: sessionID,
+		Path:      fmt.Sprintf("artifacts/%s/%s_%s.%s", sessionID, kind, uniqueID, ext),
+		Type:      kind,
+		CreatedAt: time.Now(),
+		Digest:    p.hash(content),
+	}
+	if err := p.store.SaveArtifact(artifact, []byte(content)); err != nil {
+		return fmt.Errorf("failed to save artifact: %w", err)
+	}
+	return nil
+}
+
+// sanitizeFilePath resolves a tool-supplied path and keeps it inside the working directory.
+func (p *Proxy) sanitizeFilePath(path string) (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	abs := path
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(wd, path)
+	}
+	abs = filepath.Clean(abs)
+	if rel, err := filepath.Rel(wd, abs); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path outside the working directory not allowed: %s", path)
+	}
+	return abs, nil
+}
@@ -0,0 +1,74 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/felixgeelhaar/simon/internal/runtime"
+)
+
+// Configuration keys read by FromConfig.
+const (
+	KeySlackWebhook   = "notify.slack.webhook_url"
+	KeyDiscordWebhook = "notify.discord.webhook_url"
+	KeySMTPAddr       = "notify.email.smtp_addr"
+	KeySMTPUsername   = "notify.email.username"
+	KeySMTPPassword   = "notify.email.smtp_password"
+	KeyEmailFrom      = "notify.email.from"
+	KeyEmailTo        = "notify.email.to"
+
+	// RoutePrefix plus an event type (e.g. notify.route.guard_violation) holds
+	// a comma-separated list of channel names, or "none".
+	RoutePrefix = "notify.route."
+)
+
+// FromConfig builds a notifier from configuration values. It returns nil when
+// no channel is configured. Without any route keys, every event in Events is
+// sent to every configured channel.
+func FromConfig(get func(key string) string) (*Notifier, error) {
+	var channels []Channel
+	if url := get(KeySlackWebhook); url != "" {
+		channels = append(channels, &Slack{WebhookURL: url})
+	}
+	if url := get(KeyDiscordWebhook); url != "" {
+		channels = append(channels, &Discord{WebhookURL: url})
+	}
+	if addr := get(KeySMTPAddr); addr != "" {
+		email := &Email{
+			Addr:     addr,
+			From:     get(KeyEmailFrom),
+			To:       ParseRoute(get(KeyEmailTo)),
+			Username: get(KeySMTPUsername),
+			Password: get(KeySMTPPassword),
+		}
+		if email.From == "" || len(email.To) == 0 {
+			return nil, fmt.Errorf("%s requires %s and %s", KeySMTPAddr, KeyEmailFrom, KeyEmailTo)
+		}
+		channels = append(channels, email)
+	}
+	if len(channels) == 0 {
+		return nil, nil
+	}
+
+	routes := make(map[runtime.EventType][]string)
+	configured := false
+	for _, event := range Events {
+		value := get(RoutePrefix + string(event))
+		if value == "" {
+			continue
+		}
+		configured = true
+		if strings.TrimSpace(value) == "none" {
+			continue
+		}
+		routes[event] = ParseRoute(value)
+	}
+	if !configured {
+		for _, event := range Events {
+			for _, c := range channels {
+				routes[event] = append(routes[event], c.Name())
+			}
+		}
+	}
+	return New(channels, routes)
+}
@@ -0,0 +1,209 @@
+// Package notify forwards runtime events such as session completion, guard
+// violations, and approval requests to Slack, Discord, or email.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/runtime"
+)
+
+// Events lists the runtime events that can be routed to channels.
+var Events = []runtime.EventType{
+	runtime.EventSessionComplete,
+	runtime.EventSessionError,
+	runtime.EventGuardViolation,
+	runtime.EventApprovalRequested,
+}
+
+// sendTimeout bounds a single delivery.
+var sendTimeout = 10 * time.Second
+
+// Message is a rendered notification.
+type Message struct {
+	Event     runtime.EventType
+	SessionID string
+	Title     string
+	Body      string
+	// Tags are the session's key=value labels, set for session outcomes.
+	Tags map[string]string
+}
+
+// Text renders the message as plain text.
+func (m Message) Text() string {
+	if m.Body == "" {
+		return m.Title
+	}
+	return m.Title + "\n" + m.Body
+}
+
+// Channel delivers messages to one destination.
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, msg Message) error
+}
+
+// Notifier routes runtime events to channels. Deliveries run in the
+// background so a slow webhook never blocks the runtime loop.
+type Notifier struct {
+	channels map[string]Channel
+	routes   map[runtime.EventType][]string
+
+	mu      sync.Mutex
+	onError func(channel string, err error)
+	wg      sync.WaitGroup
+}
+
+// New creates a notifier. Routes map an event type to channel names; events
+// without a route are not sent. Routes to unknown channels are an error.
+func New(channels []Channel, routes map[runtime.EventType][]string) (*Notifier, error) {
+	n := &Notifier{channels: make(map[string]Channel), routes: routes}
+	for _, c := range channels {
+		n.channels[c.Name()] = c
+	}
+	for event, names := range routes {
+		for _, name := range names {
+			if _, ok := n.channels[name]; !ok {
+				return nil, fmt.Errorf("route for %s uses unconfigured channel %q", event, name)
+			}
+		}
+	}
+	return n, nil
+}
+
+// OnError registers a callback for failed deliveries.
+func (n *Notifier) OnError(f func(channel string, err error)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.onError = f
+}
+
+// Subscribe registers the notifier for every routed event on bus.
+func (n *Notifier) Subscribe(bus *runtime.EventBus) {
+	for event := range n.routes {
+		bus.Subscribe(event, n.Handle)
+	}
+}
+
+// Handle formats an event and sends it to the channels routed for its type.
+func (n *Notifier) Handle(e runtime.Event) {
+	msg, ok := Format(e)
+	if !ok {
+		return
+	}
+	for _, name := range n.routes[e.Type] {
+		c := n.channels[name]
+		n.wg.Add(1)
+		go func() {
+			defer n.wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+			defer cancel()
+			if err := c.Send(ctx, msg); err != nil {
+				n.mu.Lock()
+				onError := n.onError
+				n.mu.Unlock()
+				if onError != nil {
+					onError(c.Name(), err)
+				}
+			}
+		}()
+	}
+}
+
+// Wait blocks until pending deliveries finish or the timeout elapses.
+func (n *Notifier) Wait(timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	}
+}
+
+// Format renders an event as a message. Warn-level guard violations are
+// skipped since they do not interrupt the session.
+func Format(e runtime.Event) (Message, bool) {
+	msg := Message{Event: e.Type, SessionID: e.SessionID}
+	switch e.Type {
+	case runtime.EventSessionComplete, runtime.EventSessionError:
+		status := str(e.Data, "status")
+		if e.Type == runtime.EventSessionError {
+			status = "failed"
+		}
+		msg.Title = fmt.Sprintf("Simon session %s %s", e.SessionID, status)
+		msg.Body = fields(e.Data, "spec", "tags", "error", "prompt_tokens", "completion_tokens", "cost")
+		msg.Tags, _ = e.Data["tags"].(map[string]string)
+	case runtime.EventGuardViolation:
+		if str(e.Data, "severity") == "warn" {
+			return msg, false
+		}
+		msg.Title = fmt.Sprintf("Simon session %s: guard %s (%s)", e.SessionID, str(e.Data, "severity"), str(e.Data, "rule"))
+		msg.Body = str(e.Data, "message")
+	case runtime.EventApprovalRequested:
+		msg.Title = fmt.Sprintf("Simon session %s is waiting for approval", e.SessionID)
+		msg.Body = fields(e.Data, "tool", "path", "diff_lines")
+	default:
+		return msg, false
+	}
+	return msg, true
+}
+
+func str(data map[string]interface{}, key string) string {
+	if v, ok := data[key]; ok && v != nil {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+// fields renders the given keys present in data as "key: value" lines.
+func fields(data map[string]interface{}, keys ...string) string {
+	var lines []string
+	for _, key := range keys {
+		v := str(data, key)
+		if v == "" {
+			continue
+		}
+		switch key {
+		case "cost":
+			if f, ok := data[key].(float64); ok {
+				v = fmt.Sprintf("$%.4f", f)
+			}
+		case "tags":
+			if tags, ok := data[key].(map[string]string); ok {
+				v = formatTags(tags)
+			}
+		}
+		lines = append(lines, key+": "+v)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatTags renders tags as sorted key=value pairs.
+func formatTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for k, v := range tags {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
+
+// ParseRoute splits a comma-separated list of channel names.
+func ParseRoute(value string) []string {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,166 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/runtime"
+)
+
+func TestNotifier_Routing(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string][]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		received[r.URL.Path] = append(received[r.URL.Path], payload["text"]+payload["content"])
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	n, err := New(
+		[]Channel{&Slack{WebhookURL: server.URL + "/slack"}, &Discord{WebhookURL: server.URL + "/discord"}},
+		map[runtime.EventType][]string{
+			runtime.EventSessionComplete: {"slack", "discord"},
+			runtime.EventGuardViolation:  {"discord"},
+		},
+	)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	bus := runtime.NewEventBus()
+	n.Subscribe(bus)
+
+	bus.PublishWithData(runtime.EventSessionComplete, "sess-1", map[string]interface{}{"status": "completed", "cost": 0.5, "tags": map[string]string{"team": "core", "env": "ci"}})
+	bus.PublishWithData(runtime.EventGuardViolation, "sess-1", map[string]interface{}{"rule": "max_iterations", "severity": "halt"})
+	bus.PublishWithData(runtime.EventGuardViolation, "sess-1", map[string]interface{}{"rule": "rate_limit", "severity": "warn"})
+	bus.PublishWithData(runtime.EventApprovalRequested, "sess-1", map[string]interface{}{"path": "main.go"})
+	n.Wait(5 * time.Second)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received["/slack"]) != 1 || !strings.Contains(received["/slack"][0], "sess-1 completed") {
+		t.Errorf("Expected one completion on slack, got %v", received["/slack"])
+	}
+	if !strings.Contains(received["/slack"][0], "tags: env=ci, team=core") {
+		t.Errorf("Expected tags in the message, got %q", received["/slack"][0])
+	}
+	if !strings.Contains(received["/slack"][0], "cost: $0.5000") {
+		t.Errorf("Expected cost in the message, got %q", received["/slack"][0])
+	}
+	if len(received["/discord"]) != 2 {
+		t.Errorf("Expected completion and halt violation on discord, got %v", received["/discord"])
+	}
+
+	if _, err := New(nil, map[runtime.EventType][]string{runtime.EventSessionComplete: {"slack"}}); err == nil {
+		t.Error("Expected error for a route to an unconfigured channel")
+	}
+}
+
+func TestNotifier_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid_token", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	n, _ := New([]Channel{&Slack{WebhookURL: server.URL}}, map[runtime.EventType][]string{runtime.EventSessionError: {"slack"}})
+	var mu sync.Mutex
+	var failures []string
+	n.OnError(func(channel string, err error) {
+		mu.Lock()
+		failures = append(failures, channel+": "+err.Error())
+		mu.Unlock()
+	})
+	n.Handle(runtime.Event{Type: runtime.EventSessionError, SessionID: "sess-2", Data: map[string]interface{}{"error": "boom"}})
+	n.Wait(5 * time.Second)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(failures) != 1 || !strings.Contains(failures[0], "invalid_token") {
+		t.Errorf("Expected the webhook error to be reported, got %v", failures)
+	}
+}
+
+func TestEmail_Send(t *testing.T) {
+	var gotAddr string
+	var gotTo []string
+	var gotMsg string
+	e := &Email{Addr: "smtp.example.com:587", From: "simon@example.com", To: []string{"a@example.com", "b@example.com"},
+		Username: "simon", Password: "pw",
+		sendMail: func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+			gotAddr, gotTo, gotMsg = addr, to, string(msg)
+			if a == nil {
+				t.Error("Expected SMTP auth")
+			}
+			return nil
+		},
+	}
+	msg := Message{Title: "Simon session s1 halted", Body: "error: budget exceeded"}
+	if err := e.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if gotAddr != "smtp.example.com:587" || len(gotTo) != 2 {
+		t.Errorf("Unexpected envelope: %s %v", gotAddr, gotTo)
+	}
+	if !strings.Contains(gotMsg, "Subject: Simon session s1 halted\r\n") || !strings.Contains(gotMsg, "error: budget exceeded") {
+		t.Errorf("Unexpected message:\n%s", gotMsg)
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	config := map[string]string{}
+	get := func(key string) string { return config[key] }
+
+	if n, err := FromConfig(get); n != nil || err != nil {
+		t.Fatalf("Expected no notifier without channels, got %v, %v", n, err)
+	}
+
+	config[KeySlackWebhook] = "https://hooks.slack.com/services/x"
+	config[KeyDiscordWebhook] = "https://discord.com/api/webhooks/x"
+	n, err := FromConfig(get)
+	if err != nil {
+		t.Fatalf("FromConfig failed: %v", err)
+	}
+	if got := n.routes[runtime.EventApprovalRequested]; len(got) != 2 {
+		t.Errorf("Expected default routes to every channel, got %v", got)
+	}
+
+	config[RoutePrefix+"guard_violation"] = "discord"
+	config[RoutePrefix+"session_complete"] = "slack, discord"
+	config[RoutePrefix+"approval_requested"] = "none"
+	n, err = FromConfig(get)
+	if err != nil {
+		t.Fatalf("FromConfig failed: %v", err)
+	}
+	if got := n.routes[runtime.EventGuardViolation]; len(got) != 1 || got[0] != "discord" {
+		t.Errorf("Unexpected guard_violation route: %v", got)
+	}
+	if got := n.routes[runtime.EventSessionComplete]; len(got) != 2 {
+		t.Errorf("Unexpected session_complete route: %v", got)
+	}
+	if _, ok := n.routes[runtime.EventApprovalRequested]; ok {
+		t.Error("Expected approval_requested to be disabled")
+	}
+
+	config[RoutePrefix+"guard_violation"] = "email"
+	if _, err := FromConfig(get); err == nil {
+		t.Error("Expected error for a route to an unconfigured channel")
+	}
+	config[KeySMTPAddr] = "localhost:25"
+	if _, err := FromConfig(get); err == nil {
+		t.Error("Expected error for email without from/to")
+	}
+	config[KeyEmailFrom] = "simon@example.com"
+	config[KeyEmailTo] = "ops@example.com"
+	if _, err := FromConfig(get); err != nil {
+		t.Errorf("FromConfig with email failed: %v", err)
+	}
+}
@@ -13,7 +13,9 @@ var tracer = otel.Tracer("simon")
 
 // Observer handles logging and tracing
 type Observer struct {
-	log *bolt.Logger
+	log     *bolt.Logger
+	handler bolt.Handler
+	level   bolt.Level
 }
 
 // New creates a new Observer with console output.
@@ -22,12 +24,16 @@ func New(out io.Writer, verbose bool) *Observer {
 	handler := bolt.NewConsoleHandler(out)
 	l := bolt.New(handler)
 
+	level := bolt.TRACE
 	if !verbose {
-		l.SetLevel(bolt.WARN)
+		level = bolt.WARN
 	}
+	l.SetLevel(level)
 
 	return &Observer{
-		log: l,
+		log:     l,
+		handler: handler,
+		level:   level,
 	}
 }
 
@@ -37,12 +43,16 @@ func NewJSON(out io.Writer, verbose bool) *Observer {
 	handler := bolt.NewJSONHandler(out)
 	l := bolt.New(handler)
 
+	level := bolt.TRACE
 	if !verbose {
-		l.SetLevel(bolt.WARN)
+		level = bolt.WARN
 	}
+	l.SetLevel(level)
 
 	return &Observer{
-		log: l,
+		log:     l,
+		handler: handler,
+		level:   level,
 	}
 }
 
@@ -128,3 +128,27 @@ func TestObserver_LogWithFields(t *testing.T) {
 		t.Errorf("expected output to contain 'iteration complete', got %q", output)
 	}
 }
+
+func TestObserver_WithSessionLog(t *testing.T) {
+	console := &bytes.Buffer{}
+	file := &bytes.Buffer{}
+	obs := New(console, false).WithSessionLog(file)
+
+	obs.Log().Debug().Str("event", "iteration_start").Msg("runtime event")
+	obs.Log().Warn().Msg("budget close")
+
+	if strings.Contains(console.String(), "runtime event") {
+		t.Errorf("expected debug record to stay out of the console, got %q", console.String())
+	}
+	if !strings.Contains(console.String(), "budget close") {
+		t.Errorf("expected warning on the console, got %q", console.String())
+	}
+
+	lines := strings.Split(strings.TrimSpace(file.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 records in session log, got %d: %q", len(lines), file.String())
+	}
+	if !strings.HasPrefix(lines[0], `{"time":"`) || !strings.Contains(lines[0], `"level":"debug"`) {
+		t.Errorf("expected timestamped debug record, got %q", lines[0])
+	}
+}
@@ -0,0 +1,84 @@
+package observe
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/felixgeelhaar/bolt/v3"
+)
+
+// WithSessionLog returns an observer that keeps logging to o's output and
+// additionally writes every record, including debug records, as a JSON line
+// to w. Each line carries a "time" field so it can be replayed later.
+func (o *Observer) WithSessionLog(w io.Writer) *Observer {
+	h := &teeHandler{
+		primary:      o.handler,
+		primaryLevel: o.level,
+		out:          w,
+	}
+	h.json = bolt.NewJSONHandler(&h.line)
+	l := bolt.New(h)
+	l.SetLevel(bolt.DEBUG)
+	return &Observer{log: l, handler: h, level: bolt.DEBUG}
+}
+
+// teeHandler writes every event to out and forwards events at or above
+// primaryLevel to the original handler.
+type teeHandler struct {
+	mu           sync.Mutex
+	primary      bolt.Handler
+	primaryLevel bolt.Level
+	json         bolt.Handler
+	line         bytes.Buffer
+	out          io.Writer
+}
+
+func (h *teeHandler) Write(e *bolt.Event) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// Handlers cannot inspect events directly, so render the JSON line first
+	h.line.Reset()
+	if err := h.json.Write(e); err != nil {
+		return err
+	}
+	line := h.line.Bytes()
+
+	_, err := h.out.Write(stampLine(line, time.Now()))
+	if levelOf(line) >= h.primaryLevel {
+		if perr := h.primary.Write(e); perr != nil {
+			return perr
+		}
+	}
+	return err
+}
+
+// levelPrefix is how every bolt JSON record starts.
+var levelPrefix = []byte(`{"level":"`)
+
+// levelOf reads the level from a bolt JSON record.
+func levelOf(line []byte) bolt.Level {
+	if !bytes.HasPrefix(line, levelPrefix) {
+		return bolt.INFO
+	}
+	rest := line[len(levelPrefix):]
+	end := bytes.IndexByte(rest, '"')
+	if end < 0 {
+		return bolt.INFO
+	}
+	return bolt.ParseLevel(string(rest[:end]))
+}
+
+// stampLine inserts a "time" field at the start of a JSON record.
+func stampLine(line []byte, t time.Time) []byte {
+	if len(line) == 0 || line[0] != '{' {
+		return line
+	}
+	out := make([]byte, 0, len(line)+48)
+	out = append(out, `{"time":"`...)
+	out = t.UTC().AppendFormat(out, time.RFC3339Nano)
+	out = append(out, `",`...)
+	return append(out, line[1:]...)
+}
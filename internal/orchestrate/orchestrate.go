@@ -3,7 +3,9 @@ package orchestrate
 import (
 	"context"
 	"fmt"
+	"strings"
 
+	"github.com/felixgeelhaar/simon/internal/coach"
 	"github.com/felixgeelhaar/simon/internal/provider"
 )
 
@@ -15,6 +17,10 @@ const (
 	AgentTypeReviewer AgentType = "reviewer"
 )
 
+// MultiAgentOrchestrator splits a session between three models: the planner
+// writes the step plan, the executor carries it out with tools (in the
+// runtime's loop), and the reviewer must approve the verified result before
+// the session completes.
 type MultiAgentOrchestrator struct {
 	Planner  provider.Provider
 	Executor provider.Provider
@@ -29,18 +35,110 @@ func New(p, e, r provider.Provider) *MultiAgentOrchestrator {
 	}
 }
 
-func (o *MultiAgentOrchestrator) Execute(ctx context.Context, goal string) error {
-	// 1. Plan
-	fmt.Println("Agent [Planner]: Creating strategy...")
-	// ... logic to call Planner ...
+// plannerPrompt asks for a plan in the ```plan block format the runtime
+// tracks step progress with.
+const plannerPrompt = `You are the planner of a coding agent. Another model will carry out your plan with tools (shell commands, file reads and writes); do not carry out the task yourself.
+Break the task into a short sequence of concrete, verifiable steps and answer with them as a numbered list inside a ` + "```plan" + ` fenced block. After the block, note risks or pitfalls the executor should watch for, if any.`
 
-	// 2. Execute
-	fmt.Println("Agent [Executor]: Implementing...")
-	// ... logic to call Executor ...
+// reviewerPrompt makes anything but an explicit approval a rejection.
+const reviewerPrompt = `You are a strict reviewer. Another model claims to have finished the task below, and its evidence passed automated verification. Decide whether the work really meets the goal and the definition of done and respects every constraint. Reject incomplete work, placeholder code, disabled or weakened tests, and changes unrelated to the goal.
+Answer with APPROVED on the first line if the work can be accepted as it is. Otherwise answer with CHANGES REQUESTED on the first line, followed by a specific list of what must change.`
 
-	// 3. Review
-	fmt.Println("Agent [Reviewer]: Verifying...")
-	// ... logic to call Reviewer ...
+// Plan asks the planner for a step plan of spec. Background, such as
+// relevant memories or the outcome of a previous attempt, is included when
+// not empty. A response format attached to ctx (see
+// provider.WithResponseFormat) takes precedence over the ```plan block.
+func (o *MultiAgentOrchestrator) Plan(ctx context.Context, spec coach.TaskSpec, background string) (*provider.Response, error) {
+	if o.Planner == nil {
+		return nil, fmt.Errorf("no planner configured")
+	}
+	var b strings.Builder
+	b.WriteString(plannerPrompt + "\n\n")
+	writeTask(&b, spec)
+	if background = strings.TrimSpace(background); background != "" {
+		b.WriteString("\n" + background + "\n")
+	}
+	resp, err := o.Planner.Chat(ctx, []provider.Message{{Role: "user", Content: b.String()}})
+	if err != nil {
+		return nil, fmt.Errorf("planner failed: %w", err)
+	}
+	return resp, nil
+}
+
+// Work is what the reviewer is shown of a session that passed verification.
+type Work struct {
+	// Report is the executor's final message.
+	Report string
+	// Changes lists the files changed in the workspace.
+	Changes string
+	// Diff is the combined diff of the changes, possibly truncated.
+	Diff string
+}
+
+// Review is the reviewer's verdict on a session's work.
+type Review struct {
+	Approved bool
+	// Feedback is what the reviewer asked to change; empty when approved.
+	Feedback string
+	Usage    provider.Usage
+}
+
+// Review asks the reviewer whether work meets spec.
+func (o *MultiAgentOrchestrator) Review(ctx context.Context, spec coach.TaskSpec, work Work) (*Review, error) {
+	if o.Reviewer == nil {
+		return nil, fmt.Errorf("no reviewer configured")
+	}
+	var b strings.Builder
+	b.WriteString(reviewerPrompt + "\n\n")
+	writeTask(&b, spec)
+	fmt.Fprintf(&b, "\nExecutor's report:\n%s\n\n%s\n", strings.TrimSpace(work.Report), work.Changes)
+	if work.Diff != "" {
+		fmt.Fprintf(&b, "\nDiff:\n```diff\n%s\n```\n", strings.TrimRight(work.Diff, "\n"))
+	}
+	resp, err := o.Reviewer.Chat(ctx, []provider.Message{{Role: "user", Content: b.String()}})
+	if err != nil {
+		return nil, fmt.Errorf("reviewer failed: %w", err)
+	}
+	approved, feedback := ParseVerdict(resp.Content)
+	return &Review{Approved: approved, Feedback: feedback, Usage: resp.Usage}, nil
+}
 
-	return nil
+// ParseVerdict reads a reviewer's answer. Only an answer whose first line
+// starts with APPROVED approves; the rest of a rejection is its feedback.
+func ParseVerdict(content string) (approved bool, feedback string) {
+	content = strings.TrimSpace(content)
+	first, rest, _ := strings.Cut(content, "\n")
+	verdict := strings.ToUpper(strings.Trim(strings.TrimSpace(first), "*#:. "))
+	if strings.HasPrefix(verdict, "APPROVED") {
+		return true, ""
+	}
+	if strings.HasPrefix(verdict, "CHANGES REQUESTED") {
+		content = strings.TrimSpace(rest)
+	}
+	if content == "" {
+		content = "The reviewer rejected the work without giving a reason."
+	}
+	return false, content
+}
+
+func writeTask(b *strings.Builder, spec coach.TaskSpec) {
+	fmt.Fprintf(b, "Goal: %s\nDefinition of done: %s\n", spec.Goal, spec.DefinitionOfDone)
+	if len(spec.Constraints) > 0 {
+		b.WriteString("Constraints:\n")
+		for _, c := range spec.Constraints {
+			fmt.Fprintf(b, "- %s\n", c)
+		}
+	}
+	if len(spec.Evidence) > 0 {
+		fmt.Fprintf(b, "Evidence files: %s\n", strings.Join(spec.Evidence, ", "))
+	}
+	if len(spec.Verify) > 0 {
+		fmt.Fprintf(b, "Verify commands: %s\n", strings.Join(spec.Verify, "; "))
+	}
+	if len(spec.Checks) > 0 {
+		b.WriteString("Checks:\n")
+		for _, c := range spec.Checks {
+			fmt.Fprintf(b, "- %s: %s\n", c.Type, c.Target)
+		}
+	}
 }
@@ -2,19 +2,31 @@ package orchestrate
 
 import (
 	"context"
+	"strings"
 	"testing"
 
+	"github.com/felixgeelhaar/simon/internal/coach"
 	"github.com/felixgeelhaar/simon/internal/provider"
 )
 
 // mockProvider implements provider.Provider for testing
 type mockProvider struct {
-	name string
+	name    string
+	content string // Response content; defaults to "mock response from <name>"
+	prompts []string
 }
 
 func (m *mockProvider) Chat(ctx context.Context, messages []provider.Message) (*provider.Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.prompts = append(m.prompts, messages[len(messages)-1].Content)
+	content := m.content
+	if content == "" {
+		content = "mock response from " + m.name
+	}
 	return &provider.Response{
-		Content: "mock response from " + m.name,
+		Content: content,
 		Usage:   provider.Usage{TotalTokens: 10},
 	}, nil
 }
@@ -23,10 +35,22 @@ func (m *mockProvider) Embed(ctx context.Context, text string) ([]float32, error
 	return []float32{0.1, 0.2, 0.3}, nil
 }
 
+func (m *mockProvider) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	return provider.EmbedEach(ctx, m, texts)
+}
+
 func (m *mockProvider) Name() string {
 	return m.name
 }
 
+func (m *mockProvider) Model() string {
+	return "mock-model"
+}
+
+func (m *mockProvider) CountTokens(ctx context.Context, messages []provider.Message) (int, error) {
+	return provider.EstimateMessagesTokens(messages), nil
+}
+
 func TestAgentType_Constants(t *testing.T) {
 	testCases := []struct {
 		agentType AgentType
@@ -65,35 +89,67 @@ func TestNew(t *testing.T) {
 	}
 }
 
-func TestMultiAgentOrchestrator_Execute(t *testing.T) {
-	planner := &mockProvider{name: "planner"}
-	executor := &mockProvider{name: "executor"}
-	reviewer := &mockProvider{name: "reviewer"}
-
-	orch := New(planner, executor, reviewer)
-	ctx := context.Background()
+func TestMultiAgentOrchestrator_Plan(t *testing.T) {
+	planner := &mockProvider{name: "planner", content: "```plan\n1. Write main.go\n```"}
+	orch := New(planner, &mockProvider{name: "executor"}, &mockProvider{name: "reviewer"})
+	spec := coach.TaskSpec{Goal: "build a CLI", DefinitionOfDone: "main.go exists", Constraints: []string{"no cgo"}, Evidence: []string{"main.go"}}
 
-	err := orch.Execute(ctx, "test goal")
+	resp, err := orch.Plan(context.Background(), spec, "Relevant past experiences:\n- use cobra\n")
 	if err != nil {
-		t.Errorf("expected nil error, got %v", err)
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if resp.Content != planner.content {
+		t.Errorf("expected the planner's response, got %q", resp.Content)
+	}
+	prompt := planner.prompts[0]
+	for _, want := range []string{"```plan", "Goal: build a CLI", "- no cgo", "Evidence files: main.go", "use cobra"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("expected the planner prompt to contain %q, got:\n%s", want, prompt)
+		}
 	}
 }
 
-func TestMultiAgentOrchestrator_ExecuteWithContext(t *testing.T) {
-	planner := &mockProvider{name: "planner"}
-	executor := &mockProvider{name: "executor"}
-	reviewer := &mockProvider{name: "reviewer"}
+func TestMultiAgentOrchestrator_Review(t *testing.T) {
+	reviewer := &mockProvider{name: "reviewer", content: "CHANGES REQUESTED\n- add a test for the flag parsing"}
+	orch := New(&mockProvider{name: "planner"}, &mockProvider{name: "executor"}, reviewer)
+	spec := coach.TaskSpec{Goal: "build a CLI", DefinitionOfDone: "main.go exists"}
+	work := Work{Report: "Task complete.", Changes: "Files changed: main.go (created)", Diff: "+package main\n"}
 
-	orch := New(planner, executor, reviewer)
+	review, err := orch.Review(context.Background(), spec, work)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if review.Approved || review.Feedback != "- add a test for the flag parsing" || review.Usage.TotalTokens != 10 {
+		t.Errorf("expected a rejection with feedback, got %+v", review)
+	}
+	if !strings.Contains(reviewer.prompts[0], "+package main") || !strings.Contains(reviewer.prompts[0], "main.go (created)") {
+		t.Errorf("expected the reviewer to see the changes, got:\n%s", reviewer.prompts[0])
+	}
 
-	// Test with cancelled context
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
+	if _, err := orch.Review(ctx, spec, work); err == nil {
+		t.Error("expected a failed reviewer call to be an error")
+	}
+}
 
-	// Execute should still work (current implementation doesn't check context)
-	err := orch.Execute(ctx, "test goal")
-	if err != nil {
-		t.Errorf("expected nil error, got %v", err)
+func TestParseVerdict(t *testing.T) {
+	testCases := []struct {
+		content  string
+		approved bool
+		feedback string
+	}{
+		{"APPROVED", true, ""},
+		{"**Approved.**\nLooks good.", true, ""},
+		{"CHANGES REQUESTED:\n- handle errors", false, "- handle errors"},
+		{"The tests are disabled.", false, "The tests are disabled."},
+		{"", false, "The reviewer rejected the work without giving a reason."},
+	}
+	for _, tc := range testCases {
+		approved, feedback := ParseVerdict(tc.content)
+		if approved != tc.approved || feedback != tc.feedback {
+			t.Errorf("ParseVerdict(%q) = %v, %q; want %v, %q", tc.content, approved, feedback, tc.approved, tc.feedback)
+		}
 	}
 }
 
@@ -11,7 +11,10 @@ import (
 
 // PluginMap is the map of plugins we can dispense.
 var PluginMap = map[string]hcplugin.Plugin{
-	"coach": &CoachGRPCPlugin{},
+	"coach":    &CoachGRPCPlugin{},
+	"provider": &ProviderGRPCPlugin{},
+	"reducer":  &ReducerGRPCPlugin{},
+	"verifier": &VerifierGRPCPlugin{},
 }
 
 // CoachGRPCPlugin is the implementation of hcplugin.GRPCPlugin so we can serve/consume this.
@@ -0,0 +1,185 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+
+	"github.com/felixgeelhaar/simon/internal/plugin/proto"
+	"github.com/felixgeelhaar/simon/internal/provider"
+	hcplugin "github.com/hashicorp/go-plugin"
+	"google.golang.org/grpc"
+)
+
+// ProviderGRPCPlugin is the implementation of hcplugin.GRPCPlugin for model backends.
+type ProviderGRPCPlugin struct {
+	hcplugin.Plugin
+	Impl ProviderPlugin
+}
+
+func (p *ProviderGRPCPlugin) GRPCServer(broker *hcplugin.GRPCBroker, s *grpc.Server) error {
+	proto.RegisterProviderServer(s, &ProviderGRPCServer{Impl: p.Impl})
+	return nil
+}
+
+func (p *ProviderGRPCPlugin) GRPCClient(ctx context.Context, broker *hcplugin.GRPCBroker, c *grpc.ClientConn) (interface{}, error) {
+	return &ProviderGRPCClient{client: proto.NewProviderClient(c)}, nil
+}
+
+type modelKey struct{}
+
+// WithModel attaches the requested model to a plugin call context.
+func WithModel(ctx context.Context, model string) context.Context {
+	return context.WithValue(ctx, modelKey{}, model)
+}
+
+// ModelFromContext returns the model requested by the host, if any.
+func ModelFromContext(ctx context.Context) string {
+	model, _ := ctx.Value(modelKey{}).(string)
+	return model
+}
+
+// ProviderGRPCClient is an implementation of ProviderPlugin that talks over RPC.
+// It also satisfies provider.Provider so the runtime can use it directly.
+type ProviderGRPCClient struct {
+	client proto.ProviderClient
+	model  string
+}
+
+func (m *ProviderGRPCClient) Name() string     { return "plugin" }
+func (m *ProviderGRPCClient) Version() string  { return "1.0" }
+func (m *ProviderGRPCClient) Type() PluginType { return PluginTypeProvider }
+func (m *ProviderGRPCClient) Model() string    { return m.model }
+
+func (m *ProviderGRPCClient) Chat(ctx context.Context, messages []provider.Message) (*provider.Response, error) {
+	resp, err := m.client.Chat(ctx, &proto.ChatRequest{
+		Messages: toProtoMessages(messages),
+		Model:    m.model,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &provider.Response{
+		Content:   resp.Content,
+		ToolCalls: fromProtoToolCalls(resp.ToolCalls),
+		Usage: provider.Usage{
+			PromptTokens:     int(resp.GetUsage().GetPromptTokens()),
+			CompletionTokens: int(resp.GetUsage().GetCompletionTokens()),
+			TotalTokens:      int(resp.GetUsage().GetTotalTokens()),
+		},
+	}, nil
+}
+
+// CountTokens estimates the prompt size; the plugin protocol has no token
+// counting call.
+func (m *ProviderGRPCClient) CountTokens(ctx context.Context, messages []provider.Message) (int, error) {
+	return provider.EstimateMessagesTokens(messages), nil
+}
+
+// Embed is not part of the plugin protocol yet.
+func (m *ProviderGRPCClient) Embed(ctx context.Context, text string) ([]float32, error) {
+	return nil, fmt.Errorf("embeddings not supported by provider plugins")
+}
+
+func (m *ProviderGRPCClient) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	return nil, fmt.Errorf("embeddings not supported by provider plugins")
+}
+
+// ProviderGRPCServer is the gRPC server that calls the local implementation.
+type ProviderGRPCServer struct {
+	proto.UnimplementedProviderServer
+	Impl ProviderPlugin
+}
+
+func (m *ProviderGRPCServer) Chat(ctx context.Context, req *proto.ChatRequest) (*proto.ChatResponse, error) {
+	res, err := m.Impl.Chat(WithModel(ctx, req.Model), fromProtoMessages(req.Messages))
+	if err != nil {
+		return nil, err
+	}
+	return &proto.ChatResponse{
+		Content:   res.Content,
+		ToolCalls: toProtoToolCalls(res.ToolCalls),
+		Usage: &proto.Usage{
+			PromptTokens:     int32(res.Usage.PromptTokens),     // #nosec G115
+			CompletionTokens: int32(res.Usage.CompletionTokens), // #nosec G115
+			TotalTokens:      int32(res.Usage.TotalTokens),      // #nosec G115
+		},
+	}, nil
+}
+
+// LoadProvider starts the plugin binary at path and dispenses its provider.
+// The returned function stops the plugin process and must be called when done.
+func LoadProvider(path, model string) (*ProviderGRPCClient, func(), error) {
+	client := hcplugin.NewClient(&hcplugin.ClientConfig{
+		HandshakeConfig:  HandshakeConfig,
+		Plugins:          PluginMap,
+		Cmd:              exec.Command(path), // #nosec G204
+		AllowedProtocols: []hcplugin.Protocol{hcplugin.ProtocolGRPC},
+	})
+
+	rpcClient, err := client.Client()
+	if err != nil {
+		client.Kill()
+		return nil, nil, fmt.Errorf("failed to start provider plugin: %w", err)
+	}
+	raw, err := rpcClient.Dispense(string(PluginTypeProvider))
+	if err != nil {
+		client.Kill()
+		return nil, nil, fmt.Errorf("failed to dispense provider plugin: %w", err)
+	}
+	p, ok := raw.(*ProviderGRPCClient)
+	if !ok {
+		client.Kill()
+		return nil, nil, fmt.Errorf("plugin %s does not implement a provider", path)
+	}
+	p.model = model
+	return p, client.Kill, nil
+}
+
+func toProtoMessages(messages []provider.Message) []*proto.Message {
+	out := make([]*proto.Message, 0, len(messages))
+	for _, msg := range messages {
+		out = append(out, &proto.Message{
+			Role:       msg.Role,
+			Content:    msg.Content,
+			ToolCalls:  toProtoToolCalls(msg.ToolCalls),
+			ToolCallId: msg.ToolCallID,
+		})
+	}
+	return out
+}
+
+func fromProtoMessages(messages []*proto.Message) []provider.Message {
+	out := make([]provider.Message, 0, len(messages))
+	for _, msg := range messages {
+		out = append(out, provider.Message{
+			Role:       msg.Role,
+			Content:    msg.Content,
+			ToolCalls:  fromProtoToolCalls(msg.ToolCalls),
+			ToolCallID: msg.ToolCallId,
+		})
+	}
+	return out
+}
+
+func toProtoToolCalls(calls []provider.ToolCall) []*proto.ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	out := make([]*proto.ToolCall, 0, len(calls))
+	for _, tc := range calls {
+		out = append(out, &proto.ToolCall{Id: tc.ID, Name: tc.Name, Args: tc.Args})
+	}
+	return out
+}
+
+func fromProtoToolCalls(calls []*proto.ToolCall) []provider.ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	out := make([]provider.ToolCall, 0, len(calls))
+	for _, tc := range calls {
+		out = append(out, provider.ToolCall{ID: tc.Id, Name: tc.Name, Args: tc.Args})
+	}
+	return out
+}
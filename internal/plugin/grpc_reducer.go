@@ -0,0 +1,92 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/felixgeelhaar/simon/internal/plugin/proto"
+	hcplugin "github.com/hashicorp/go-plugin"
+	"google.golang.org/grpc"
+)
+
+// ReducerGRPCPlugin is the implementation of hcplugin.GRPCPlugin for tool
+// output digests.
+type ReducerGRPCPlugin struct {
+	hcplugin.Plugin
+	Impl ReducerPlugin
+}
+
+func (p *ReducerGRPCPlugin) GRPCServer(broker *hcplugin.GRPCBroker, s *grpc.Server) error {
+	proto.RegisterReducerServer(s, &ReducerGRPCServer{Impl: p.Impl})
+	return nil
+}
+
+func (p *ReducerGRPCPlugin) GRPCClient(ctx context.Context, broker *hcplugin.GRPCBroker, c *grpc.ClientConn) (interface{}, error) {
+	return &ReducerGRPCClient{client: proto.NewReducerClient(c)}, nil
+}
+
+// ReducerGRPCClient is an implementation of ReducerPlugin that talks over
+// RPC. It also satisfies mcp.ContentReducer, so mcp.FromContentReducer
+// adds it to the proxy's digest pipeline.
+type ReducerGRPCClient struct {
+	client proto.ReducerClient
+	name   string
+}
+
+func (m *ReducerGRPCClient) Name() string     { return m.name }
+func (m *ReducerGRPCClient) Version() string  { return "1.0" }
+func (m *ReducerGRPCClient) Type() PluginType { return PluginTypeReducer }
+
+func (m *ReducerGRPCClient) Reduce(ctx context.Context, content []byte) (string, error) {
+	resp, err := m.client.Reduce(ctx, &proto.ReduceRequest{Content: content})
+	if err != nil {
+		return "", err
+	}
+	return resp.Digest, nil
+}
+
+// ReducerGRPCServer is the gRPC server that calls the local implementation.
+type ReducerGRPCServer struct {
+	proto.UnimplementedReducerServer
+	Impl ReducerPlugin
+}
+
+func (m *ReducerGRPCServer) Reduce(ctx context.Context, req *proto.ReduceRequest) (*proto.ReduceResponse, error) {
+	digest, err := m.Impl.Reduce(ctx, req.Content)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.ReduceResponse{Digest: digest}, nil
+}
+
+// LoadReducer starts the plugin binary at path and dispenses its reducer,
+// named after the binary. The returned function stops the plugin process and
+// must be called when done.
+func LoadReducer(path string) (*ReducerGRPCClient, func(), error) {
+	client := hcplugin.NewClient(&hcplugin.ClientConfig{
+		HandshakeConfig:  HandshakeConfig,
+		Plugins:          PluginMap,
+		Cmd:              exec.Command(path), // #nosec G204
+		AllowedProtocols: []hcplugin.Protocol{hcplugin.ProtocolGRPC},
+	})
+
+	rpcClient, err := client.Client()
+	if err != nil {
+		client.Kill()
+		return nil, nil, fmt.Errorf("failed to start reducer plugin %s: %w", path, err)
+	}
+	raw, err := rpcClient.Dispense(string(PluginTypeReducer))
+	if err != nil {
+		client.Kill()
+		return nil, nil, fmt.Errorf("failed to dispense reducer plugin %s: %w", path, err)
+	}
+	r, ok := raw.(*ReducerGRPCClient)
+	if !ok {
+		client.Kill()
+		return nil, nil, fmt.Errorf("plugin %s does not implement a reducer", path)
+	}
+	r.name = filepath.Base(path)
+	return r, client.Kill, nil
+}
@@ -0,0 +1,115 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+
+	"github.com/felixgeelhaar/simon/internal/coach"
+	"github.com/felixgeelhaar/simon/internal/mcp"
+	"github.com/felixgeelhaar/simon/internal/plugin/proto"
+	hcplugin "github.com/hashicorp/go-plugin"
+	"google.golang.org/grpc"
+)
+
+// VerifierGRPCPlugin is the implementation of hcplugin.GRPCPlugin for custom checks.
+type VerifierGRPCPlugin struct {
+	hcplugin.Plugin
+	Impl VerifierPlugin
+}
+
+func (p *VerifierGRPCPlugin) GRPCServer(broker *hcplugin.GRPCBroker, s *grpc.Server) error {
+	proto.RegisterVerifierServer(s, &VerifierGRPCServer{Impl: p.Impl})
+	return nil
+}
+
+func (p *VerifierGRPCPlugin) GRPCClient(ctx context.Context, broker *hcplugin.GRPCBroker, c *grpc.ClientConn) (interface{}, error) {
+	return &VerifierGRPCClient{client: proto.NewVerifierClient(c)}, nil
+}
+
+// VerifierGRPCClient is an implementation of VerifierPlugin that talks over RPC.
+// It also satisfies mcp.Verifier so the proxy can run its checks; it is
+// named after the check type it is registered for.
+type VerifierGRPCClient struct {
+	client proto.VerifierClient
+	name   string
+}
+
+func (m *VerifierGRPCClient) Name() string     { return m.name }
+func (m *VerifierGRPCClient) Version() string  { return "1.0" }
+func (m *VerifierGRPCClient) Type() PluginType { return PluginTypeVerifier }
+
+func (m *VerifierGRPCClient) Verify(ctx context.Context, check coach.Check) (VerifyResult, error) {
+	resp, err := m.client.Verify(ctx, &proto.VerifyRequest{
+		Type:   check.Type,
+		Target: check.Target,
+		Expect: check.Expect,
+		Params: check.Params,
+	})
+	if err != nil {
+		return VerifyResult{}, err
+	}
+	return VerifyResult{Passed: resp.Passed, Detail: resp.Detail}, nil
+}
+
+// Check runs the check in the plugin; a failed call is reported as an
+// error item rather than stopping verification.
+func (m *VerifierGRPCClient) Check(ctx context.Context, req mcp.CheckRequest) (mcp.EvidenceStatus, error) {
+	res, err := m.Verify(ctx, req.Check)
+	switch {
+	case err != nil:
+		return mcp.EvidenceStatus{Status: mcp.EvidenceError, Detail: err.Error()}, nil
+	case !res.Passed:
+		return mcp.EvidenceStatus{Status: mcp.EvidenceFail, Detail: res.Detail}, nil
+	}
+	return mcp.EvidenceStatus{Status: mcp.EvidencePass}, nil
+}
+
+// VerifierGRPCServer is the gRPC server that calls the local implementation.
+type VerifierGRPCServer struct {
+	proto.UnimplementedVerifierServer
+	Impl VerifierPlugin
+}
+
+func (m *VerifierGRPCServer) Verify(ctx context.Context, req *proto.VerifyRequest) (*proto.VerifyResponse, error) {
+	res, err := m.Impl.Verify(ctx, coach.Check{
+		Type:   req.Type,
+		Target: req.Target,
+		Expect: req.Expect,
+		Params: req.Params,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &proto.VerifyResponse{Passed: res.Passed, Detail: res.Detail}, nil
+}
+
+// LoadVerifier starts the plugin binary at path and dispenses its verifier
+// for checks of the named type. The returned function stops the plugin
+// process and must be called when done.
+func LoadVerifier(path, name string) (*VerifierGRPCClient, func(), error) {
+	client := hcplugin.NewClient(&hcplugin.ClientConfig{
+		HandshakeConfig:  HandshakeConfig,
+		Plugins:          PluginMap,
+		Cmd:              exec.Command(path), // #nosec G204
+		AllowedProtocols: []hcplugin.Protocol{hcplugin.ProtocolGRPC},
+	})
+
+	rpcClient, err := client.Client()
+	if err != nil {
+		client.Kill()
+		return nil, nil, fmt.Errorf("failed to start verifier plugin %s: %w", name, err)
+	}
+	raw, err := rpcClient.Dispense(string(PluginTypeVerifier))
+	if err != nil {
+		client.Kill()
+		return nil, nil, fmt.Errorf("failed to dispense verifier plugin %s: %w", name, err)
+	}
+	v, ok := raw.(*VerifierGRPCClient)
+	if !ok {
+		client.Kill()
+		return nil, nil, fmt.Errorf("plugin %s does not implement a verifier", path)
+	}
+	v.name = name
+	return v, client.Kill, nil
+}
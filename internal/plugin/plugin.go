@@ -30,6 +30,7 @@ const (
 	PluginTypeGuard    PluginType = "guard"
 	PluginTypeProvider PluginType = "provider"
 	PluginTypeReducer  PluginType = "reducer"
+	PluginTypeVerifier PluginType = "verifier"
 )
 
 // CoachPlugin allows external validation logic.
@@ -51,6 +52,20 @@ type ProviderPlugin interface {
 	Chat(ctx context.Context, messages []provider.Message) (*provider.Response, error)
 }
 
+// VerifierPlugin allows custom completion checks, run for the task spec's
+// checks of the type it is registered for.
+type VerifierPlugin interface {
+	Plugin
+	Verify(ctx context.Context, check coach.Check) (VerifyResult, error)
+}
+
+// VerifyResult is the outcome of a plugin check.
+type VerifyResult struct {
+	Passed bool
+	// Detail explains a failure.
+	Detail string
+}
+
 // ReducerPlugin allows summarizing/digesting tool outputs (Artifacts).
 type ReducerPlugin interface {
 	Plugin
@@ -3,18 +3,21 @@ package plugin
 import (
 	"context"
 	"net"
+	"strings"
 	"testing"
 
 	"github.com/felixgeelhaar/simon/internal/coach"
+	"github.com/felixgeelhaar/simon/internal/mcp"
 	"github.com/felixgeelhaar/simon/internal/plugin/proto"
+	"github.com/felixgeelhaar/simon/internal/provider"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/test/bufconn"
 )
 
 type MockCoach struct{}
 
-func (m *MockCoach) Name() string { return "mock" }
-func (m *MockCoach) Version() string { return "0.1" }
+func (m *MockCoach) Name() string     { return "mock" }
+func (m *MockCoach) Version() string  { return "0.1" }
 func (m *MockCoach) Type() PluginType { return PluginTypeCoach }
 func (m *MockCoach) Validate(ctx context.Context, spec coach.TaskSpec) (coach.ValidationResult, error) {
 	if spec.Goal == "fail" {
@@ -27,7 +30,7 @@ func TestCoachGRPC(t *testing.T) {
 	lis := bufconn.Listen(1024 * 1024)
 	s := grpc.NewServer()
 	proto.RegisterCoachServer(s, &CoachGRPCServer{Impl: &MockCoach{}})
-	
+
 	go func() {
 		if err := s.Serve(lis); err != nil {
 			panic(err)
@@ -67,3 +70,159 @@ func TestCoachGRPC(t *testing.T) {
 		t.Errorf("Expected 'failed' error, got %v", res.Errors)
 	}
 }
+
+type MockProvider struct{}
+
+func (m *MockProvider) Name() string     { return "mock" }
+func (m *MockProvider) Version() string  { return "0.1" }
+func (m *MockProvider) Type() PluginType { return PluginTypeProvider }
+func (m *MockProvider) Chat(ctx context.Context, messages []provider.Message) (*provider.Response, error) {
+	last := messages[len(messages)-1]
+	if last.Role == "tool" {
+		return &provider.Response{Content: "done after " + last.ToolCallID + " on " + ModelFromContext(ctx)}, nil
+	}
+	return &provider.Response{
+		ToolCalls: []provider.ToolCall{{ID: "call-1", Name: "run_shell", Args: `{"cmd":"ls"}`}},
+		Usage:     provider.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+	}, nil
+}
+
+func TestProviderGRPC(t *testing.T) {
+	lis := bufconn.Listen(1024 * 1024)
+	s := grpc.NewServer()
+	proto.RegisterProviderServer(s, &ProviderGRPCServer{Impl: &MockProvider{}})
+
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			panic(err)
+		}
+	}()
+
+	dialer := func(context.Context, string) (net.Conn, error) {
+		return lis.Dial()
+	}
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet", grpc.WithContextDialer(dialer), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("Failed to dial bufnet: %v", err)
+	}
+	defer conn.Close()
+
+	var client provider.Provider = &ProviderGRPCClient{client: proto.NewProviderClient(conn), model: "custom-1"}
+
+	resp, err := client.Chat(context.Background(), []provider.Message{{Role: "user", Content: "go"}})
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Args != `{"cmd":"ls"}` {
+		t.Errorf("Expected tool call round-trip, got %+v", resp.ToolCalls)
+	}
+	if resp.Usage.TotalTokens != 15 || resp.Usage.PromptTokens != 10 {
+		t.Errorf("Expected usage round-trip, got %+v", resp.Usage)
+	}
+
+	resp, err = client.Chat(context.Background(), []provider.Message{
+		{Role: "assistant", ToolCalls: resp.ToolCalls},
+		{Role: "tool", Content: "ok", ToolCallID: "call-1"},
+	})
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if resp.Content != "done after call-1 on custom-1" {
+		t.Errorf("Unexpected content: %q", resp.Content)
+	}
+}
+
+type MockVerifier struct{}
+
+func (m *MockVerifier) Name() string     { return "mock" }
+func (m *MockVerifier) Version() string  { return "0.1" }
+func (m *MockVerifier) Type() PluginType { return PluginTypeVerifier }
+func (m *MockVerifier) Verify(ctx context.Context, check coach.Check) (VerifyResult, error) {
+	if check.Params["env"] != "staging" {
+		return VerifyResult{Detail: check.Target + " not deployed to " + check.Params["env"]}, nil
+	}
+	return VerifyResult{Passed: true}, nil
+}
+
+func TestVerifierGRPC(t *testing.T) {
+	lis := bufconn.Listen(1024 * 1024)
+	s := grpc.NewServer()
+	proto.RegisterVerifierServer(s, &VerifierGRPCServer{Impl: &MockVerifier{}})
+
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			panic(err)
+		}
+	}()
+
+	dialer := func(context.Context, string) (net.Conn, error) {
+		return lis.Dial()
+	}
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet", grpc.WithContextDialer(dialer), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("Failed to dial bufnet: %v", err)
+	}
+	defer conn.Close()
+
+	var client mcp.Verifier = &VerifierGRPCClient{client: proto.NewVerifierClient(conn), name: "deployment"}
+	if client.Name() != "deployment" {
+		t.Errorf("Expected the verifier to be named after its check type, got %q", client.Name())
+	}
+
+	item, err := client.Check(context.Background(), mcp.CheckRequest{Check: coach.Check{Type: "deployment", Target: "api", Params: map[string]string{"env": "staging"}}})
+	if err != nil || item.Status != mcp.EvidencePass {
+		t.Errorf("Expected passing check, got %+v, %v", item, err)
+	}
+
+	item, err = client.Check(context.Background(), mcp.CheckRequest{Check: coach.Check{Type: "deployment", Target: "api", Params: map[string]string{"env": "prod"}}})
+	if err != nil || item.Status != mcp.EvidenceFail || item.Detail != "api not deployed to prod" {
+		t.Errorf("Expected failing check with detail, got %+v, %v", item, err)
+	}
+}
+
+type MockReducer struct{}
+
+func (m *MockReducer) Name() string     { return "mock" }
+func (m *MockReducer) Version() string  { return "0.1" }
+func (m *MockReducer) Type() PluginType { return PluginTypeReducer }
+func (m *MockReducer) Reduce(ctx context.Context, content []byte) (string, error) {
+	if !strings.HasPrefix(string(content), "lint:") {
+		return "", nil
+	}
+	return "lint: 2 issues", nil
+}
+
+func TestReducerGRPC(t *testing.T) {
+	lis := bufconn.Listen(1024 * 1024)
+	s := grpc.NewServer()
+	proto.RegisterReducerServer(s, &ReducerGRPCServer{Impl: &MockReducer{}})
+
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			panic(err)
+		}
+	}()
+
+	dialer := func(context.Context, string) (net.Conn, error) {
+		return lis.Dial()
+	}
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet", grpc.WithContextDialer(dialer), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("Failed to dial bufnet: %v", err)
+	}
+	defer conn.Close()
+
+	pipeline := mcp.NewPipeline()
+	pipeline.Use(mcp.FromContentReducer(&ReducerGRPCClient{client: proto.NewReducerClient(conn), name: "lint"}))
+
+	long := strings.Repeat("a line of linter output\n", 200)
+	if got := pipeline.Reduce(context.Background(), "lint:\n"+long, 50); got != "lint: 2 issues" {
+		t.Errorf("Expected the plugin's digest, got %q", got)
+	}
+	if got := pipeline.Reduce(context.Background(), long, 50); got == "lint: 2 issues" || got == "" {
+		t.Errorf("Expected output the plugin doesn't recognize to fall through, got %q", got)
+	}
+}
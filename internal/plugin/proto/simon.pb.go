@@ -264,6 +264,7 @@ func (x *CheckResponse) GetViolationMessage() string {
 type ChatRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Messages      []*Message             `protobuf:"bytes,1,rep,name=messages,proto3" json:"messages,omitempty"`
+	Model         string                 `protobuf:"bytes,2,opt,name=model,proto3" json:"model,omitempty"` // Requested model, may be empty
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -305,10 +306,19 @@ func (x *ChatRequest) GetMessages() []*Message {
 	return nil
 }
 
+func (x *ChatRequest) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
 type Message struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Role          string                 `protobuf:"bytes,1,opt,name=role,proto3" json:"role,omitempty"`
-	Content       string                 `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"` // Simplified for proto
+	Content       string                 `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+	ToolCalls     []*ToolCall            `protobuf:"bytes,3,rep,name=tool_calls,json=toolCalls,proto3" json:"tool_calls,omitempty"`      // Set on assistant messages
+	ToolCallId    string                 `protobuf:"bytes,4,opt,name=tool_call_id,json=toolCallId,proto3" json:"tool_call_id,omitempty"` // Set on tool result messages
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -357,17 +367,92 @@ func (x *Message) GetContent() string {
 	return ""
 }
 
+func (x *Message) GetToolCalls() []*ToolCall {
+	if x != nil {
+		return x.ToolCalls
+	}
+	return nil
+}
+
+func (x *Message) GetToolCallId() string {
+	if x != nil {
+		return x.ToolCallId
+	}
+	return ""
+}
+
+type ToolCall struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Args          string                 `protobuf:"bytes,3,opt,name=args,proto3" json:"args,omitempty"` // JSON-encoded arguments
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ToolCall) Reset() {
+	*x = ToolCall{}
+	mi := &file_internal_plugin_proto_simon_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ToolCall) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ToolCall) ProtoMessage() {}
+
+func (x *ToolCall) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_plugin_proto_simon_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ToolCall.ProtoReflect.Descriptor instead.
+func (*ToolCall) Descriptor() ([]byte, []int) {
+	return file_internal_plugin_proto_simon_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ToolCall) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ToolCall) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ToolCall) GetArgs() string {
+	if x != nil {
+		return x.Args
+	}
+	return ""
+}
+
 type ChatResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Content       string                 `protobuf:"bytes,1,opt,name=content,proto3" json:"content,omitempty"`
 	Usage         *Usage                 `protobuf:"bytes,2,opt,name=usage,proto3" json:"usage,omitempty"`
+	ToolCalls     []*ToolCall            `protobuf:"bytes,3,rep,name=tool_calls,json=toolCalls,proto3" json:"tool_calls,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ChatResponse) Reset() {
 	*x = ChatResponse{}
-	mi := &file_internal_plugin_proto_simon_proto_msgTypes[6]
+	mi := &file_internal_plugin_proto_simon_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -379,7 +464,7 @@ func (x *ChatResponse) String() string {
 func (*ChatResponse) ProtoMessage() {}
 
 func (x *ChatResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_plugin_proto_simon_proto_msgTypes[6]
+	mi := &file_internal_plugin_proto_simon_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -392,7 +477,7 @@ func (x *ChatResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ChatResponse.ProtoReflect.Descriptor instead.
 func (*ChatResponse) Descriptor() ([]byte, []int) {
-	return file_internal_plugin_proto_simon_proto_rawDescGZIP(), []int{6}
+	return file_internal_plugin_proto_simon_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *ChatResponse) GetContent() string {
@@ -409,6 +494,13 @@ func (x *ChatResponse) GetUsage() *Usage {
 	return nil
 }
 
+func (x *ChatResponse) GetToolCalls() []*ToolCall {
+	if x != nil {
+		return x.ToolCalls
+	}
+	return nil
+}
+
 type Usage struct {
 	state            protoimpl.MessageState `protogen:"open.v1"`
 	PromptTokens     int32                  `protobuf:"varint,1,opt,name=prompt_tokens,json=promptTokens,proto3" json:"prompt_tokens,omitempty"`
@@ -420,7 +512,7 @@ type Usage struct {
 
 func (x *Usage) Reset() {
 	*x = Usage{}
-	mi := &file_internal_plugin_proto_simon_proto_msgTypes[7]
+	mi := &file_internal_plugin_proto_simon_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -432,7 +524,7 @@ func (x *Usage) String() string {
 func (*Usage) ProtoMessage() {}
 
 func (x *Usage) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_plugin_proto_simon_proto_msgTypes[7]
+	mi := &file_internal_plugin_proto_simon_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -445,7 +537,7 @@ func (x *Usage) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Usage.ProtoReflect.Descriptor instead.
 func (*Usage) Descriptor() ([]byte, []int) {
-	return file_internal_plugin_proto_simon_proto_rawDescGZIP(), []int{7}
+	return file_internal_plugin_proto_simon_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *Usage) GetPromptTokens() int32 {
@@ -469,6 +561,214 @@ func (x *Usage) GetTotalTokens() int32 {
 	return 0
 }
 
+type VerifyRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"` // Check type the plugin is registered for
+	Target        string                 `protobuf:"bytes,2,opt,name=target,proto3" json:"target,omitempty"`
+	Expect        string                 `protobuf:"bytes,3,opt,name=expect,proto3" json:"expect,omitempty"`
+	Params        map[string]string      `protobuf:"bytes,4,rep,name=params,proto3" json:"params,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // The check's params from the task spec
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VerifyRequest) Reset() {
+	*x = VerifyRequest{}
+	mi := &file_internal_plugin_proto_simon_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VerifyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VerifyRequest) ProtoMessage() {}
+
+func (x *VerifyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_plugin_proto_simon_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VerifyRequest.ProtoReflect.Descriptor instead.
+func (*VerifyRequest) Descriptor() ([]byte, []int) {
+	return file_internal_plugin_proto_simon_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *VerifyRequest) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *VerifyRequest) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
+func (x *VerifyRequest) GetExpect() string {
+	if x != nil {
+		return x.Expect
+	}
+	return ""
+}
+
+func (x *VerifyRequest) GetParams() map[string]string {
+	if x != nil {
+		return x.Params
+	}
+	return nil
+}
+
+type VerifyResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Passed        bool                   `protobuf:"varint,1,opt,name=passed,proto3" json:"passed,omitempty"`
+	Detail        string                 `protobuf:"bytes,2,opt,name=detail,proto3" json:"detail,omitempty"` // Why the check failed
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VerifyResponse) Reset() {
+	*x = VerifyResponse{}
+	mi := &file_internal_plugin_proto_simon_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VerifyResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VerifyResponse) ProtoMessage() {}
+
+func (x *VerifyResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_plugin_proto_simon_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VerifyResponse.ProtoReflect.Descriptor instead.
+func (*VerifyResponse) Descriptor() ([]byte, []int) {
+	return file_internal_plugin_proto_simon_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *VerifyResponse) GetPassed() bool {
+	if x != nil {
+		return x.Passed
+	}
+	return false
+}
+
+func (x *VerifyResponse) GetDetail() string {
+	if x != nil {
+		return x.Detail
+	}
+	return ""
+}
+
+type ReduceRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Content       []byte                 `protobuf:"bytes,1,opt,name=content,proto3" json:"content,omitempty"` // Raw tool output
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReduceRequest) Reset() {
+	*x = ReduceRequest{}
+	mi := &file_internal_plugin_proto_simon_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReduceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReduceRequest) ProtoMessage() {}
+
+func (x *ReduceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_plugin_proto_simon_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReduceRequest.ProtoReflect.Descriptor instead.
+func (*ReduceRequest) Descriptor() ([]byte, []int) {
+	return file_internal_plugin_proto_simon_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ReduceRequest) GetContent() []byte {
+	if x != nil {
+		return x.Content
+	}
+	return nil
+}
+
+type ReduceResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Digest        string                 `protobuf:"bytes,1,opt,name=digest,proto3" json:"digest,omitempty"` // Empty when the plugin doesn't recognize the output
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReduceResponse) Reset() {
+	*x = ReduceResponse{}
+	mi := &file_internal_plugin_proto_simon_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReduceResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReduceResponse) ProtoMessage() {}
+
+func (x *ReduceResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_plugin_proto_simon_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReduceResponse.ProtoReflect.Descriptor instead.
+func (*ReduceResponse) Descriptor() ([]byte, []int) {
+	return file_internal_plugin_proto_simon_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ReduceResponse) GetDigest() string {
+	if x != nil {
+		return x.Digest
+	}
+	return ""
+}
+
 var File_internal_plugin_proto_simon_proto protoreflect.FileDescriptor
 
 const file_internal_plugin_proto_simon_proto_rawDesc = "" +
@@ -492,25 +792,55 @@ const file_internal_plugin_proto_simon_proto_rawDesc = "" +
 	"\rCheckResponse\x12\x18\n" +
 	"\aallowed\x18\x01 \x01(\bR\aallowed\x12%\n" +
 	"\x0eviolation_rule\x18\x02 \x01(\tR\rviolationRule\x12+\n" +
-	"\x11violation_message\x18\x03 \x01(\tR\x10violationMessage\"9\n" +
+	"\x11violation_message\x18\x03 \x01(\tR\x10violationMessage\"O\n" +
 	"\vChatRequest\x12*\n" +
-	"\bmessages\x18\x01 \x03(\v2\x0e.proto.MessageR\bmessages\"7\n" +
+	"\bmessages\x18\x01 \x03(\v2\x0e.proto.MessageR\bmessages\x12\x14\n" +
+	"\x05model\x18\x02 \x01(\tR\x05model\"\x89\x01\n" +
 	"\aMessage\x12\x12\n" +
 	"\x04role\x18\x01 \x01(\tR\x04role\x12\x18\n" +
-	"\acontent\x18\x02 \x01(\tR\acontent\"L\n" +
+	"\acontent\x18\x02 \x01(\tR\acontent\x12.\n" +
+	"\n" +
+	"tool_calls\x18\x03 \x03(\v2\x0f.proto.ToolCallR\ttoolCalls\x12 \n" +
+	"\ftool_call_id\x18\x04 \x01(\tR\n" +
+	"toolCallId\"B\n" +
+	"\bToolCall\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x12\n" +
+	"\x04args\x18\x03 \x01(\tR\x04args\"|\n" +
 	"\fChatResponse\x12\x18\n" +
 	"\acontent\x18\x01 \x01(\tR\acontent\x12\"\n" +
-	"\x05usage\x18\x02 \x01(\v2\f.proto.UsageR\x05usage\"|\n" +
+	"\x05usage\x18\x02 \x01(\v2\f.proto.UsageR\x05usage\x12.\n" +
+	"\n" +
+	"tool_calls\x18\x03 \x03(\v2\x0f.proto.ToolCallR\ttoolCalls\"|\n" +
 	"\x05Usage\x12#\n" +
 	"\rprompt_tokens\x18\x01 \x01(\x05R\fpromptTokens\x12+\n" +
 	"\x11completion_tokens\x18\x02 \x01(\x05R\x10completionTokens\x12!\n" +
-	"\ftotal_tokens\x18\x03 \x01(\x05R\vtotalTokens2D\n" +
+	"\ftotal_tokens\x18\x03 \x01(\x05R\vtotalTokens\"\xc8\x01\n" +
+	"\rVerifyRequest\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12\x16\n" +
+	"\x06target\x18\x02 \x01(\tR\x06target\x12\x16\n" +
+	"\x06expect\x18\x03 \x01(\tR\x06expect\x128\n" +
+	"\x06params\x18\x04 \x03(\v2 .proto.VerifyRequest.ParamsEntryR\x06params\x1a9\n" +
+	"\vParamsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"@\n" +
+	"\x0eVerifyResponse\x12\x16\n" +
+	"\x06passed\x18\x01 \x01(\bR\x06passed\x12\x16\n" +
+	"\x06detail\x18\x02 \x01(\tR\x06detail\")\n" +
+	"\rReduceRequest\x12\x18\n" +
+	"\acontent\x18\x01 \x01(\fR\acontent\"(\n" +
+	"\x0eReduceResponse\x12\x16\n" +
+	"\x06digest\x18\x01 \x01(\tR\x06digest2D\n" +
 	"\x05Coach\x12;\n" +
 	"\bValidate\x12\x16.proto.ValidateRequest\x1a\x17.proto.ValidateResponse2;\n" +
 	"\x05Guard\x122\n" +
 	"\x05Check\x12\x13.proto.CheckRequest\x1a\x14.proto.CheckResponse2;\n" +
 	"\bProvider\x12/\n" +
-	"\x04Chat\x12\x12.proto.ChatRequest\x1a\x13.proto.ChatResponseB6Z4github.com/felixgeelhaar/simon/internal/plugin/protob\x06proto3"
+	"\x04Chat\x12\x12.proto.ChatRequest\x1a\x13.proto.ChatResponse2A\n" +
+	"\bVerifier\x125\n" +
+	"\x06Verify\x12\x14.proto.VerifyRequest\x1a\x15.proto.VerifyResponse2@\n" +
+	"\aReducer\x125\n" +
+	"\x06Reduce\x12\x14.proto.ReduceRequest\x1a\x15.proto.ReduceResponseB6Z4github.com/felixgeelhaar/simon/internal/plugin/protob\x06proto3"
 
 var (
 	file_internal_plugin_proto_simon_proto_rawDescOnce sync.Once
@@ -524,7 +854,7 @@ func file_internal_plugin_proto_simon_proto_rawDescGZIP() []byte {
 	return file_internal_plugin_proto_simon_proto_rawDescData
 }
 
-var file_internal_plugin_proto_simon_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_internal_plugin_proto_simon_proto_msgTypes = make([]protoimpl.MessageInfo, 15)
 var file_internal_plugin_proto_simon_proto_goTypes = []any{
 	(*ValidateRequest)(nil),  // 0: proto.ValidateRequest
 	(*ValidateResponse)(nil), // 1: proto.ValidateResponse
@@ -532,25 +862,38 @@ var file_internal_plugin_proto_simon_proto_goTypes = []any{
 	(*CheckResponse)(nil),    // 3: proto.CheckResponse
 	(*ChatRequest)(nil),      // 4: proto.ChatRequest
 	(*Message)(nil),          // 5: proto.Message
-	(*ChatResponse)(nil),     // 6: proto.ChatResponse
-	(*Usage)(nil),            // 7: proto.Usage
-	nil,                      // 8: proto.CheckRequest.ContextEntry
+	(*ToolCall)(nil),         // 6: proto.ToolCall
+	(*ChatResponse)(nil),     // 7: proto.ChatResponse
+	(*Usage)(nil),            // 8: proto.Usage
+	(*VerifyRequest)(nil),    // 9: proto.VerifyRequest
+	(*VerifyResponse)(nil),   // 10: proto.VerifyResponse
+	(*ReduceRequest)(nil),    // 11: proto.ReduceRequest
+	(*ReduceResponse)(nil),   // 12: proto.ReduceResponse
+	nil,                      // 13: proto.CheckRequest.ContextEntry
+	nil,                      // 14: proto.VerifyRequest.ParamsEntry
 }
 var file_internal_plugin_proto_simon_proto_depIdxs = []int32{
-	8, // 0: proto.CheckRequest.context:type_name -> proto.CheckRequest.ContextEntry
-	5, // 1: proto.ChatRequest.messages:type_name -> proto.Message
-	7, // 2: proto.ChatResponse.usage:type_name -> proto.Usage
-	0, // 3: proto.Coach.Validate:input_type -> proto.ValidateRequest
-	2, // 4: proto.Guard.Check:input_type -> proto.CheckRequest
-	4, // 5: proto.Provider.Chat:input_type -> proto.ChatRequest
-	1, // 6: proto.Coach.Validate:output_type -> proto.ValidateResponse
-	3, // 7: proto.Guard.Check:output_type -> proto.CheckResponse
-	6, // 8: proto.Provider.Chat:output_type -> proto.ChatResponse
-	6, // [6:9] is the sub-list for method output_type
-	3, // [3:6] is the sub-list for method input_type
-	3, // [3:3] is the sub-list for extension type_name
-	3, // [3:3] is the sub-list for extension extendee
-	0, // [0:3] is the sub-list for field type_name
+	13, // 0: proto.CheckRequest.context:type_name -> proto.CheckRequest.ContextEntry
+	5,  // 1: proto.ChatRequest.messages:type_name -> proto.Message
+	6,  // 2: proto.Message.tool_calls:type_name -> proto.ToolCall
+	8,  // 3: proto.ChatResponse.usage:type_name -> proto.Usage
+	6,  // 4: proto.ChatResponse.tool_calls:type_name -> proto.ToolCall
+	14, // 5: proto.VerifyRequest.params:type_name -> proto.VerifyRequest.ParamsEntry
+	0,  // 6: proto.Coach.Validate:input_type -> proto.ValidateRequest
+	2,  // 7: proto.Guard.Check:input_type -> proto.CheckRequest
+	4,  // 8: proto.Provider.Chat:input_type -> proto.ChatRequest
+	9,  // 9: proto.Verifier.Verify:input_type -> proto.VerifyRequest
+	11, // 10: proto.Reducer.Reduce:input_type -> proto.ReduceRequest
+	1,  // 11: proto.Coach.Validate:output_type -> proto.ValidateResponse
+	3,  // 12: proto.Guard.Check:output_type -> proto.CheckResponse
+	7,  // 13: proto.Provider.Chat:output_type -> proto.ChatResponse
+	10, // 14: proto.Verifier.Verify:output_type -> proto.VerifyResponse
+	12, // 15: proto.Reducer.Reduce:output_type -> proto.ReduceResponse
+	11, // [11:16] is the sub-list for method output_type
+	6,  // [6:11] is the sub-list for method input_type
+	6,  // [6:6] is the sub-list for extension type_name
+	6,  // [6:6] is the sub-list for extension extendee
+	0,  // [0:6] is the sub-list for field type_name
 }
 
 func init() { file_internal_plugin_proto_simon_proto_init() }
@@ -564,9 +907,9 @@ func file_internal_plugin_proto_simon_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_internal_plugin_proto_simon_proto_rawDesc), len(file_internal_plugin_proto_simon_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   9,
+			NumMessages:   15,
 			NumExtensions: 0,
-			NumServices:   3,
+			NumServices:   5,
 		},
 		GoTypes:           file_internal_plugin_proto_simon_proto_goTypes,
 		DependencyIndexes: file_internal_plugin_proto_simon_proto_depIdxs,
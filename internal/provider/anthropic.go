@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
+	"sync"
 )
 
 type AnthropicProvider struct {
@@ -15,8 +17,24 @@ type AnthropicProvider struct {
 	model   string
 	baseURL string
 	client  *http.Client
+
+	// thinkingBudget enables extended thinking with this many tokens; 0
+	// disables it.
+	thinkingBudget int
+	// thinking holds the thinking blocks of responses with tool calls, by
+	// the first call's ID: the API wants them back with the tool results,
+	// while the history only keeps the visible content.
+	thinkingMu sync.Mutex
+	thinking   map[string][]anthropicContentBlock
 }
 
+// MinThinkingBudget is the smallest extended thinking budget the API
+// accepts.
+const MinThinkingBudget = 1024
+
+// maxThinkingTurns bounds the thinking blocks kept for tool results.
+const maxThinkingTurns = 32
+
 func NewAnthropicProvider(apiKey, model string) (*AnthropicProvider, error) {
 	if apiKey == "" {
 		return nil, errors.New("API key is required")
@@ -38,6 +56,26 @@ func (p *AnthropicProvider) Name() string {
 	return "anthropic"
 }
 
+func (p *AnthropicProvider) Model() string {
+	return p.model
+}
+
+// Capabilities reports the model's capabilities; Anthropic has no embeddings.
+func (p *AnthropicProvider) Capabilities() Capabilities {
+	return ModelCapabilities("anthropic", p.model)
+}
+
+// SetThinkingBudget enables extended thinking with a budget of tokens, at
+// least MinThinkingBudget; 0 disables it. Responses then carry the thinking
+// as Reasoning.
+func (p *AnthropicProvider) SetThinkingBudget(tokens int) error {
+	if tokens != 0 && tokens < MinThinkingBudget {
+		return fmt.Errorf("thinking budget must be at least %d tokens, got %d", MinThinkingBudget, tokens)
+	}
+	p.thinkingBudget = tokens
+	return nil
+}
+
 // Anthropic types for request/response
 type anthropicMessage struct {
 	Role    string                  `json:"role"`
@@ -45,10 +83,19 @@ type anthropicMessage struct {
 }
 
 type anthropicRequest struct {
-	Model     string             `json:"model"`
-	Messages  []anthropicMessage `json:"messages"`
-	MaxTokens int                `json:"max_tokens"`
-	Tools     []anthropicTool    `json:"tools,omitempty"`
+	Model         string             `json:"model"`
+	Messages      []anthropicMessage `json:"messages"`
+	MaxTokens     int                `json:"max_tokens,omitempty"`
+	Tools         []anthropicTool    `json:"tools,omitempty"`
+	Thinking      *anthropicThinking `json:"thinking,omitempty"`
+	Temperature   *float64           `json:"temperature,omitempty"`
+	TopP          *float64           `json:"top_p,omitempty"`
+	StopSequences []string           `json:"stop_sequences,omitempty"`
+}
+
+type anthropicThinking struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens"`
 }
 
 type anthropicTool struct {
@@ -73,6 +120,9 @@ type anthropicContentBlock struct {
 	ID        string          `json:"id,omitempty"`         // For tool use
 	ToolUseID string          `json:"tool_use_id,omitempty"` // For tool result
 	Content   string          `json:"content,omitempty"`     // For tool result
+	Thinking  string          `json:"thinking,omitempty"`    // For thinking
+	Signature string          `json:"signature,omitempty"`   // For thinking
+	Data      string          `json:"data,omitempty"`        // For redacted thinking
 }
 
 type anthropicUsage struct {
@@ -91,8 +141,136 @@ func (p *AnthropicProvider) SetBaseURL(url string) {
 }
 
 func (p *AnthropicProvider) Chat(ctx context.Context, messages []Message) (*Response, error) {
-	url := p.baseURL
+	reqBody := p.request(ctx, messages)
+	params := ParamsFromContext(ctx)
+	reqBody.MaxTokens = 4096
+	if params.MaxTokens > 0 {
+		reqBody.MaxTokens = params.MaxTokens
+	}
+	if reqBody.Thinking != nil {
+		// max_tokens covers the thinking as well as the answer
+		reqBody.MaxTokens += reqBody.Thinking.BudgetTokens
+	} else {
+		// Extended thinking only runs at the default temperature and top_p
+		reqBody.Temperature = params.Temperature
+		reqBody.TopP = params.TopP
+		if t := params.Temperature; t != nil && *t > 1 {
+			// The API accepts temperatures up to 1, not the 2 of the others
+			highest := 1.0
+			reqBody.Temperature = &highest
+		}
+	}
+	reqBody.StopSequences = params.Stop
+
+	body, err := p.post(ctx, p.baseURL, reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	var anthropicResp anthropicResponse
+	if err := json.Unmarshal(body, &anthropicResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	if anthropicResp.Error != nil {
+		return nil, fmt.Errorf("anthropic error: %s", anthropicResp.Error.Message)
+	}
+
+	var contentStr string
+	var toolCalls []ToolCall
+	var thinking []anthropicContentBlock
+	var reasoning []string
+
+	for _, block := range anthropicResp.Content {
+		switch block.Type {
+		case "text":
+			contentStr += block.Text
+		case "tool_use":
+			toolCalls = append(toolCalls, ToolCall{
+				ID:   block.ID,
+				Name: block.Name,
+				Args: string(block.Input),
+			})
+		case "thinking":
+			thinking = append(thinking, block)
+			reasoning = append(reasoning, block.Thinking)
+		case "redacted_thinking":
+			thinking = append(thinking, block)
+		}
+	}
+	if len(thinking) > 0 && len(toolCalls) > 0 {
+		p.keepThinking(toolCalls[0].ID, thinking)
+	}
+
+	// The API doesn't report thinking tokens apart, so they are estimated
+	thinkingTokens := min(EstimateTokens(strings.Join(reasoning, "\n")), anthropicResp.Usage.OutputTokens)
+	return &Response{
+		Content:   contentStr,
+		ToolCalls: toolCalls,
+		Reasoning: strings.Join(reasoning, "\n\n"),
+		Usage: Usage{
+			PromptTokens:     anthropicResp.Usage.InputTokens,
+			CompletionTokens: anthropicResp.Usage.OutputTokens,
+			TotalTokens:      anthropicResp.Usage.InputTokens + anthropicResp.Usage.OutputTokens,
+			ThinkingTokens:   thinkingTokens,
+		},
+	}, nil
+}
+
+// keepThinking remembers the thinking blocks of a response whose first tool
+// call has the given ID.
+func (p *AnthropicProvider) keepThinking(toolCallID string, blocks []anthropicContentBlock) {
+	p.thinkingMu.Lock()
+	defer p.thinkingMu.Unlock()
+	if p.thinking == nil || len(p.thinking) >= maxThinkingTurns {
+		p.thinking = make(map[string][]anthropicContentBlock)
+	}
+	p.thinking[toolCallID] = blocks
+}
+
+// thinkingFor returns the thinking blocks kept for an assistant message
+// with tool calls, or nil.
+func (p *AnthropicProvider) thinkingFor(m Message) []anthropicContentBlock {
+	if len(m.ToolCalls) == 0 {
+		return nil
+	}
+	p.thinkingMu.Lock()
+	defer p.thinkingMu.Unlock()
+	return p.thinking[m.ToolCalls[0].ID]
+}
 
+// canThink reports whether a request of messages may enable thinking: the
+// API wants the thinking of the last assistant turn back when it called
+// tools, which isn't known for histories of another process or provider.
+func (p *AnthropicProvider) canThink(messages []Message) bool {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "assistant" {
+			return len(messages[i].ToolCalls) == 0 || p.thinkingFor(messages[i]) != nil
+		}
+	}
+	return true
+}
+
+// CountTokens asks the token counting endpoint for the prompt size of
+// messages, including the tool definitions sent with every request.
+func (p *AnthropicProvider) CountTokens(ctx context.Context, messages []Message) (int, error) {
+	body, err := p.post(ctx, p.baseURL+"/count_tokens", p.request(ctx, messages))
+	if err != nil {
+		return 0, err
+	}
+	var count struct {
+		InputTokens int `json:"input_tokens"`
+	}
+	if err := json.Unmarshal(body, &count); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return count.InputTokens, nil
+}
+
+// request converts messages and the tool definitions into a request body,
+// with thinking enabled when it is configured and possible.
+func (p *AnthropicProvider) request(ctx context.Context, messages []Message) anthropicRequest {
+	think := p.thinkingBudget > 0 && p.canThink(messages)
 	var anthropicMsgs []anthropicMessage
 	for _, m := range messages {
 		role := m.Role
@@ -108,6 +286,9 @@ func (p *AnthropicProvider) Chat(ctx context.Context, messages []Message) (*Resp
 				Content:   m.Content,
 			})
 		} else {
+			if think && m.Role == "assistant" {
+				content = append(content, p.thinkingFor(m)...)
+			}
 			if m.Content != "" {
 				content = append(content, anthropicContentBlock{
 					Type: "text",
@@ -116,9 +297,9 @@ func (p *AnthropicProvider) Chat(ctx context.Context, messages []Message) (*Resp
 			}
 			for _, tc := range m.ToolCalls {
 				content = append(content, anthropicContentBlock{
-					Type: "tool_use",
-					ID:   tc.ID,
-					Name: tc.Name,
+					Type:  "tool_use",
+					ID:    tc.ID,
+					Name:  tc.Name,
 					Input: json.RawMessage(tc.Args),
 				})
 			}
@@ -130,35 +311,29 @@ func (p *AnthropicProvider) Chat(ctx context.Context, messages []Message) (*Resp
 		})
 	}
 
-	// Tool definition for run_shell
-	tools := []anthropicTool{
-		{
-			Name:        "run_shell",
-			Description: "Execute a shell command",
-			InputSchema: map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"cmd": map[string]interface{}{
-						"type":        "string",
-						"description": "The command to run",
-					},
-					"dir": map[string]interface{}{
-						"type":        "string",
-						"description": "The directory to run the command in",
-					},
-				},
-				"required": []string{"cmd"},
-			},
-		},
+	specs := ToolsFromContext(ctx)
+	tools := make([]anthropicTool, len(specs))
+	for i, t := range specs {
+		tools[i] = anthropicTool{
+			Name:        t.Name,
+			Description: t.Description,
+			InputSchema: t.JSONSchema(),
+		}
 	}
 
-	reqBody := anthropicRequest{
-		Model:     p.model,
-		Messages:  anthropicMsgs,
-		MaxTokens: 4096,
-		Tools:     tools,
+	req := anthropicRequest{
+		Model:    p.model,
+		Messages: anthropicMsgs,
+		Tools:    tools,
+	}
+	if think {
+		req.Thinking = &anthropicThinking{Type: "enabled", BudgetTokens: p.thinkingBudget}
 	}
+	return req
+}
 
+// post sends a JSON request to the API and returns the response body.
+func (p *AnthropicProvider) post(ctx context.Context, url string, reqBody interface{}) ([]byte, error) {
 	jsonBody, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, err
@@ -169,9 +344,14 @@ func (p *AnthropicProvider) Chat(ctx context.Context, messages []Message) (*Resp
 		return nil, err
 	}
 
+	req.Header.Set("content-type", "application/json")
+	return p.do(req)
+}
+
+// do sends an authenticated API request and returns the response body.
+func (p *AnthropicProvider) do(req *http.Request) ([]byte, error) {
 	req.Header.Set("x-api-key", p.apiKey)
 	req.Header.Set("anthropic-version", "2023-06-01")
-	req.Header.Set("content-type", "application/json")
 
 	resp, err := p.client.Do(req)
 	if err != nil {
@@ -182,44 +362,41 @@ func (p *AnthropicProvider) Chat(ctx context.Context, messages []Message) (*Resp
 	body, _ := io.ReadAll(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("anthropic api error: %s", string(body))
+		return nil, &StatusError{Provider: "anthropic", StatusCode: resp.StatusCode, Body: string(body)}
 	}
+	return body, nil
+}
 
-	var anthropicResp anthropicResponse
-	if err := json.Unmarshal(body, &anthropicResp); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+// ListModels returns the IDs of the models the API key can use.
+func (p *AnthropicProvider) ListModels(ctx context.Context) ([]string, error) {
+	url := strings.TrimSuffix(p.baseURL, "/messages") + "/models?limit=1000"
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
 	}
-
-	if anthropicResp.Error != nil {
-		return nil, fmt.Errorf("anthropic error: %s", anthropicResp.Error.Message)
+	body, err := p.do(req)
+	if err != nil {
+		return nil, err
 	}
-
-	var contentStr string
-	var toolCalls []ToolCall
-
-	for _, block := range anthropicResp.Content {
-		if block.Type == "text" {
-			contentStr += block.Text
-		} else if block.Type == "tool_use" {
-			toolCalls = append(toolCalls, ToolCall{
-				ID:   block.ID,
-				Name: block.Name,
-				Args: string(block.Input),
-			})
-		}
+	var list struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
 	}
-
-	return &Response{
-		Content:   contentStr,
-		ToolCalls: toolCalls,
-		Usage: Usage{
-			PromptTokens:     anthropicResp.Usage.InputTokens,
-			CompletionTokens: anthropicResp.Usage.OutputTokens,
-			TotalTokens:      anthropicResp.Usage.InputTokens + anthropicResp.Usage.OutputTokens,
-		},
-	}, nil
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	ids := make([]string, len(list.Data))
+	for i, m := range list.Data {
+		ids[i] = m.ID
+	}
+	return ids, nil
 }
 
 func (p *AnthropicProvider) Embed(ctx context.Context, text string) ([]float32, error) {
 	return nil, fmt.Errorf("embeddings not supported by Anthropic provider")
+}
+
+func (p *AnthropicProvider) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	return nil, fmt.Errorf("embeddings not supported by Anthropic provider")
 }
\ No newline at end of file
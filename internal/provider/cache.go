@@ -0,0 +1,107 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync/atomic"
+)
+
+// ResponseCache stores serialized provider responses by key.
+// store.SQLiteStore implements it.
+type ResponseCache interface {
+	GetCachedResponse(key string) ([]byte, bool, error)
+	PutCachedResponse(key string, response []byte) error
+}
+
+// CacheStats counts cache lookups made by a CachingProvider.
+type CacheStats struct {
+	Hits   int64
+	Misses int64
+}
+
+// CachingProvider returns stored responses for prompts it has already seen,
+// keyed by provider, model, messages, tool definitions, response format, and
+// sampling parameters.
+// Cached responses report zero usage since they cost nothing. Embeddings are
+// not cached.
+type CachingProvider struct {
+	Provider
+	cache  ResponseCache
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// NewCachingProvider wraps p with a response cache.
+func NewCachingProvider(p Provider, cache ResponseCache) *CachingProvider {
+	return &CachingProvider{Provider: p, cache: cache}
+}
+
+// Chat answers from the cache when possible and stores successful responses.
+// Cache errors are not fatal; the request falls through to the provider.
+func (c *CachingProvider) Chat(ctx context.Context, messages []Message) (*Response, error) {
+	var format *ResponseFormat
+	if f, ok := ResponseFormatFromContext(ctx); ok {
+		format = &f
+	}
+	key, err := cacheKey(c.Name(), c.Model(), messages, ToolsFromContext(ctx), format, ParamsFromContext(ctx))
+	if err != nil {
+		return c.Provider.Chat(ctx, messages)
+	}
+
+	if data, ok, err := c.cache.GetCachedResponse(key); err == nil && ok {
+		var resp Response
+		if err := json.Unmarshal(data, &resp); err == nil {
+			c.hits.Add(1)
+			resp.Usage = Usage{}
+			return &resp, nil
+		}
+	}
+
+	c.misses.Add(1)
+	resp, err := c.Provider.Chat(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+	if data, err := json.Marshal(resp); err == nil {
+		_ = c.cache.PutCachedResponse(key, data)
+	}
+	return resp, nil
+}
+
+// Unwrap returns the cached provider.
+func (c *CachingProvider) Unwrap() Provider { return c.Provider }
+
+// Stats returns the hit and miss counts since the provider was created.
+func (c *CachingProvider) Stats() CacheStats {
+	return CacheStats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
+
+// CacheKey hashes everything that determines a provider's answer.
+func CacheKey(providerName, model string, messages []Message, tools []ToolSpec) (string, error) {
+	return cacheKey(providerName, model, messages, tools, nil, Params{})
+}
+
+// cacheKey is CacheKey for a request that may ask for a response format or
+// set sampling parameters; other requests keep the keys they had before
+// formats and parameters existed.
+func cacheKey(providerName, model string, messages []Message, tools []ToolSpec, format *ResponseFormat, params Params) (string, error) {
+	var sampling *Params
+	if !params.IsZero() {
+		sampling = &params
+	}
+	data, err := json.Marshal(struct {
+		Provider string          `json:"provider"`
+		Model    string          `json:"model"`
+		Messages []Message       `json:"messages"`
+		Tools    []ToolSpec      `json:"tools"`
+		Format   *ResponseFormat `json:"format,omitempty"`
+		Params   *Params         `json:"params,omitempty"`
+	}{providerName, model, messages, tools, format, sampling})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,103 @@
+package provider
+
+import "strings"
+
+// Capabilities describes what a provider's model supports.
+type Capabilities struct {
+	// ContextWindow is the model's context size in tokens, 0 if unknown.
+	ContextWindow int
+	// Tools reports whether the model accepts tool definitions and returns
+	// tool calls. Models not known to lack them are assumed to have them.
+	Tools bool
+	// Embeddings reports whether Embed and EmbedBatch work.
+	Embeddings bool
+	// Params reports whether chat requests honour the sampling parameters
+	// of WithParams. Providers not known to ignore them are assumed to.
+	Params bool
+	// Pricing is the model's list price, nil if unknown; zero for local
+	// providers.
+	Pricing *Pricing
+}
+
+// CapabilityReporter is implemented by providers that describe their
+// model's capabilities.
+type CapabilityReporter interface {
+	Provider
+	Capabilities() Capabilities
+}
+
+// modelContextWindows maps model name prefixes to their context size in
+// tokens, matched by longest prefix like modelPricing.
+var modelContextWindows = map[string]int{
+	"gpt-4o":            128_000,
+	"gpt-4-turbo":       128_000,
+	"gpt-4-1106":        128_000,
+	"gpt-4-0125":        128_000,
+	"gpt-4":             8_192,
+	"gpt-3.5-turbo":     16_385,
+	"claude-3":          200_000,
+	"claude-sonnet-4":   200_000,
+	"claude-opus-4":     200_000,
+	"claude-haiku-4":    200_000,
+	"gemini-1.5-pro":    2_097_152,
+	"gemini-1.5-flash":  1_048_576,
+	"gemini-2":          1_048_576,
+	"mistral-large":     128_000,
+	"mistral-small":     32_000,
+	"codestral":         256_000,
+	"open-mistral-nemo": 128_000,
+	"llama-3.3-70b":     128_000,
+	"llama-3.1-8b":      128_000,
+	"llama3.1":          128_000,
+	"llama3.2":          128_000,
+	"mixtral-8x7b":      32_768,
+	"qwen2.5-coder":     32_768,
+}
+
+// modelsWithoutTools are model name prefixes known not to support tool
+// calling.
+var modelsWithoutTools = []string{
+	"o1-mini", "o1-preview", "gpt-3.5-turbo-instruct",
+	"text-embedding", "nomic-embed", "mxbai-embed",
+	"llama2", "codellama", "gemma", "deepseek-r1", "phi3",
+}
+
+// ModelCapabilities returns what is known about the named provider's model:
+// its context window and pricing from simon's tables, and tool support
+// unless the model is known to lack it. Embeddings are left to the
+// provider, which knows whether it has an embedding model.
+func ModelCapabilities(providerName, model string) Capabilities {
+	lower := strings.ToLower(model)
+	caps := Capabilities{Tools: true, Params: true}
+	best := ""
+	for prefix, window := range modelContextWindows {
+		if strings.HasPrefix(lower, prefix) && len(prefix) > len(best) {
+			best, caps.ContextWindow = prefix, window
+		}
+	}
+	for _, prefix := range modelsWithoutTools {
+		if strings.HasPrefix(lower, prefix) {
+			caps.Tools = false
+		}
+	}
+	if freeProviders[providerName] {
+		caps.Pricing = &Pricing{}
+	} else if pricing, ok := LookupPricing(model); ok {
+		caps.Pricing = &pricing
+	}
+	return caps
+}
+
+// CapabilitiesOf returns the capabilities p reports, looking through its
+// middleware. Providers that don't report theirs, such as plugins, get
+// ModelCapabilities for their name and model, with embeddings assumed and
+// no sampling parameters, which the plugin protocol doesn't carry.
+func CapabilitiesOf(p Provider) Capabilities {
+	if r, ok := Find[CapabilityReporter](p); ok {
+		return r.Capabilities()
+	}
+	caps := ModelCapabilities(p.Name(), p.Model())
+	caps.Embeddings = true
+	caps.Params = false
+	return caps
+}
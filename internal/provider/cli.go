@@ -4,7 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os/exec"
-	"strings"
+	"path/filepath"
 	"time"
 )
 
@@ -27,6 +27,16 @@ func (p *CLIProvider) Name() string {
 	return "cli-" + p.binaryPath
 }
 
+func (p *CLIProvider) Model() string {
+	return filepath.Base(p.binaryPath)
+}
+
+// Capabilities reports tool support, which the CLI handles itself, no
+// embeddings, and no sampling parameters, since only the prompt is passed.
+func (p *CLIProvider) Capabilities() Capabilities {
+	return Capabilities{Tools: true}
+}
+
 func (p *CLIProvider) Chat(ctx context.Context, messages []Message) (*Response, error) {
 	var prompt string
 	if len(messages) > 0 {
@@ -50,14 +60,28 @@ func (p *CLIProvider) Chat(ctx context.Context, messages []Message) (*Response,
 		return nil, fmt.Errorf("cli agent failed: %w\nOutput: %s", err, result)
 	}
 
+	// CLI agents don't report usage, so estimate from what was sent and received
+	promptTokens := EstimateTokens(prompt)
+	completionTokens := EstimateTokens(result)
 	return &Response{
 		Content: result,
 		Usage: Usage{
-			TotalTokens: len(strings.Fields(result)), 
+			PromptTokens:     promptTokens,
+			CompletionTokens: completionTokens,
+			TotalTokens:      promptTokens + completionTokens,
 		},
 	}, nil
 }
 
+// CountTokens estimates the size of the prompt passed to the CLI tool.
+func (p *CLIProvider) CountTokens(ctx context.Context, messages []Message) (int, error) {
+	return estimateCount(ctx, messages, false)
+}
+
 func (p *CLIProvider) Embed(ctx context.Context, text string) ([]float32, error) {
 	return nil, fmt.Errorf("embeddings not supported by CLI provider")
 }
+
+func (p *CLIProvider) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	return nil, fmt.Errorf("embeddings not supported by CLI provider")
+}
@@ -0,0 +1,341 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/credential"
+)
+
+// Exchange kinds recorded in a fixture.
+const (
+	ExchangeChat        = "chat"
+	ExchangeEmbed       = "embed"
+	ExchangeCountTokens = "count_tokens"
+)
+
+// Exchange is one recorded provider call. Embeddings of a batch are
+// recorded as one embed exchange per text.
+type Exchange struct {
+	Kind     string    `json:"kind"`
+	Messages []Message `json:"messages,omitempty"` // Request of a chat call
+	Text     string    `json:"text,omitempty"`     // Request of an embed call
+	Response *Response `json:"response,omitempty"`
+	Vector   []float32 `json:"vector,omitempty"`
+	Tokens   int       `json:"tokens,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Fixture is a recorded provider transcript, written by RecordingProvider
+// and played back by FixtureProvider.
+type Fixture struct {
+	Provider   string     `json:"provider"`
+	Model      string     `json:"model"`
+	RecordedAt time.Time  `json:"recorded_at"`
+	Exchanges  []Exchange `json:"exchanges"`
+}
+
+// LoadFixture reads a fixture file.
+func LoadFixture(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- the fixture path is chosen by the user
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	}
+	var f Fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid fixture %s: %w", path, err)
+	}
+	return &f, nil
+}
+
+// ContainsCredential reports whether text contains a value matching a
+// well-known credential format.
+func ContainsCredential(text string) bool {
+	return credential.ContainsSecret(text)
+}
+
+// Sanitizer removes credentials and the user's home directory from recorded
+// text, so fixtures can be committed.
+type Sanitizer struct {
+	secrets []string
+	home    string
+}
+
+// NewSanitizer redacts the given secrets, such as configured API keys, in
+// addition to values matching well-known credential formats.
+func NewSanitizer(secrets ...string) *Sanitizer {
+	s := &Sanitizer{}
+	for _, secret := range secrets {
+		if len(secret) >= 8 {
+			s.secrets = append(s.secrets, secret)
+		}
+	}
+	if home, err := os.UserHomeDir(); err == nil && len(home) > 1 {
+		s.home = home
+	}
+	return s
+}
+
+// String returns text with secrets replaced by [REDACTED] and the home
+// directory by ~.
+func (s *Sanitizer) String(text string) string {
+	for _, secret := range s.secrets {
+		text = strings.ReplaceAll(text, secret, "[REDACTED]")
+	}
+	for _, pattern := range credential.SecretPatterns {
+		text = pattern.ReplaceAllString(text, "[REDACTED]")
+	}
+	if s.home != "" {
+		text = strings.ReplaceAll(text, s.home, "~")
+	}
+	return text
+}
+
+func (s *Sanitizer) messages(messages []Message) []Message {
+	out := make([]Message, len(messages))
+	for i, m := range messages {
+		m.Content = s.String(m.Content)
+		m.ToolCalls = s.toolCalls(m.ToolCalls)
+		out[i] = m
+	}
+	return out
+}
+
+func (s *Sanitizer) toolCalls(calls []ToolCall) []ToolCall {
+	if calls == nil {
+		return nil
+	}
+	out := make([]ToolCall, len(calls))
+	for i, c := range calls {
+		c.Args = s.String(c.Args)
+		out[i] = c
+	}
+	return out
+}
+
+// RecordingProvider writes every call of the provider it wraps, sanitized,
+// to a fixture file. The file is rewritten after each call, so an
+// interrupted session still leaves a usable transcript.
+type RecordingProvider struct {
+	Provider
+	path      string
+	sanitizer *Sanitizer
+
+	mu      sync.Mutex
+	fixture Fixture
+}
+
+// WithRecording records the provider's calls to a new fixture file in dir;
+// see RecordingProvider.
+func WithRecording(dir string, sanitizer *Sanitizer) Middleware {
+	return func(p Provider) Provider { return NewRecordingProvider(p, dir, sanitizer) }
+}
+
+// NewRecordingProvider wraps p, recording to <dir>/<provider>-<time>.json.
+func NewRecordingProvider(p Provider, dir string, sanitizer *Sanitizer) *RecordingProvider {
+	now := time.Now()
+	name := fmt.Sprintf("%s-%s.json", p.Name(), now.Format("20060102-150405.000"))
+	return &RecordingProvider{
+		Provider:  p,
+		path:      filepath.Join(dir, name),
+		sanitizer: sanitizer,
+		fixture:   Fixture{Provider: p.Name(), Model: p.Model(), RecordedAt: now, Exchanges: []Exchange{}},
+	}
+}
+
+// Unwrap returns the recorded provider.
+func (r *RecordingProvider) Unwrap() Provider { return r.Provider }
+
+// Path returns the fixture file being written.
+func (r *RecordingProvider) Path() string { return r.path }
+
+func (r *RecordingProvider) Chat(ctx context.Context, messages []Message) (*Response, error) {
+	resp, err := r.Provider.Chat(ctx, messages)
+	e := Exchange{Kind: ExchangeChat, Messages: r.sanitizer.messages(messages)}
+	if resp != nil {
+		sanitized := *resp
+		sanitized.Content = r.sanitizer.String(resp.Content)
+		sanitized.ToolCalls = r.sanitizer.toolCalls(resp.ToolCalls)
+		e.Response = &sanitized
+	}
+	return resp, r.record(e, err)
+}
+
+func (r *RecordingProvider) Embed(ctx context.Context, text string) ([]float32, error) {
+	vec, err := r.Provider.Embed(ctx, text)
+	return vec, r.record(Exchange{Kind: ExchangeEmbed, Text: r.sanitizer.String(text), Vector: vec}, err)
+}
+
+func (r *RecordingProvider) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors, err := r.Provider.EmbedBatch(ctx, texts)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, text := range texts {
+		e := Exchange{Kind: ExchangeEmbed, Text: r.sanitizer.String(text)}
+		if err != nil {
+			e.Error = r.sanitizer.String(err.Error())
+		} else if i < len(vectors) {
+			e.Vector = vectors[i]
+		}
+		r.fixture.Exchanges = append(r.fixture.Exchanges, e)
+	}
+	if werr := r.write(); werr != nil && err == nil {
+		return vectors, werr
+	}
+	return vectors, err
+}
+
+func (r *RecordingProvider) CountTokens(ctx context.Context, messages []Message) (int, error) {
+	n, err := r.Provider.CountTokens(ctx, messages)
+	return n, r.record(Exchange{Kind: ExchangeCountTokens, Tokens: n}, err)
+}
+
+// record appends e and rewrites the fixture. It returns the call's error,
+// or the write error when the call succeeded.
+func (r *RecordingProvider) record(e Exchange, err error) error {
+	if err != nil {
+		e.Error = r.sanitizer.String(err.Error())
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fixture.Exchanges = append(r.fixture.Exchanges, e)
+	if werr := r.write(); werr != nil && err == nil {
+		return werr
+	}
+	return err
+}
+
+func (r *RecordingProvider) write() error {
+	data, err := json.MarshalIndent(r.fixture, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(r.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+	return nil
+}
+
+// FixtureProvider plays back a recorded fixture: chat and count_tokens
+// calls get the recorded answers in order, and embeddings are looked up by
+// their sanitized text. Requests are not compared with the recording, so a
+// run diverging from it only shows in its outcome. Recorded errors are
+// returned as errors.
+type FixtureProvider struct {
+	fixture   *Fixture
+	sanitizer *Sanitizer
+
+	mu      sync.Mutex
+	chats   []Exchange
+	counts  []Exchange
+	vectors map[string]Exchange
+	played  int
+}
+
+// NewFixtureProvider plays back f.
+func NewFixtureProvider(f *Fixture) *FixtureProvider {
+	p := &FixtureProvider{fixture: f, sanitizer: NewSanitizer(), vectors: make(map[string]Exchange)}
+	for _, e := range f.Exchanges {
+		switch e.Kind {
+		case ExchangeChat:
+			p.chats = append(p.chats, e)
+		case ExchangeCountTokens:
+			p.counts = append(p.counts, e)
+		case ExchangeEmbed:
+			if _, ok := p.vectors[e.Text]; !ok {
+				p.vectors[e.Text] = e
+			}
+		}
+	}
+	return p
+}
+
+// ErrFixtureExhausted is returned by Chat once every recorded response has
+// been played back.
+var ErrFixtureExhausted = errors.New("fixture has no more recorded chat responses")
+
+func (p *FixtureProvider) Chat(ctx context.Context, messages []Message) (*Response, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.chats) == 0 {
+		return nil, fmt.Errorf("%w (played %d)", ErrFixtureExhausted, p.played)
+	}
+	e := p.chats[0]
+	p.chats = p.chats[1:]
+	p.played++
+	if e.Error != "" {
+		return nil, errors.New(e.Error)
+	}
+	if e.Response == nil {
+		return &Response{}, nil
+	}
+	resp := *e.Response
+	return &resp, nil
+}
+
+// CountTokens returns the recorded counts in order, estimating once they
+// run out.
+func (p *FixtureProvider) CountTokens(ctx context.Context, messages []Message) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.counts) == 0 {
+		return EstimateMessagesTokens(messages), nil
+	}
+	e := p.counts[0]
+	p.counts = p.counts[1:]
+	if e.Error != "" {
+		return 0, errors.New(e.Error)
+	}
+	return e.Tokens, nil
+}
+
+func (p *FixtureProvider) Embed(ctx context.Context, text string) ([]float32, error) {
+	p.mu.Lock()
+	e, ok := p.vectors[p.sanitizer.String(text)]
+	p.mu.Unlock()
+	if !ok {
+		if len(text) > 40 {
+			text = text[:40] + "..."
+		}
+		return nil, fmt.Errorf("fixture has no embedding recorded for %q", text)
+	}
+	if e.Error != "" {
+		return nil, errors.New(e.Error)
+	}
+	return e.Vector, nil
+}
+
+func (p *FixtureProvider) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	return EmbedEach(ctx, p, texts)
+}
+
+func (p *FixtureProvider) Name() string {
+	return "fixture"
+}
+
+// Model returns the recorded model.
+func (p *FixtureProvider) Model() string {
+	return p.fixture.Model
+}
+
+// Capabilities reports the recorded model's capabilities; embeddings are
+// played back.
+func (p *FixtureProvider) Capabilities() Capabilities {
+	caps := ModelCapabilities(p.Name(), p.Model())
+	caps.Embeddings = true
+	return caps
+}
+
+// Remaining returns the number of chat responses not yet played back.
+func (p *FixtureProvider) Remaining() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.chats)
+}
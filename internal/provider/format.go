@@ -0,0 +1,34 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// ResponseFormat asks for a chat response that is a JSON document matching
+// Schema. Providers with structured outputs (the OpenAI-compatible ones)
+// enforce it; the others ignore it and answer in free text, so callers must
+// still accept a response that is not JSON.
+type ResponseFormat struct {
+	// Name identifies the schema to the API, e.g. "plan".
+	Name string `json:"name"`
+	// Schema is a JSON schema in the strict subset OpenAI accepts: every
+	// property required and no additional properties.
+	Schema json.RawMessage `json:"schema"`
+}
+
+type responseFormatKey struct{}
+
+// WithResponseFormat attaches a response format to a chat request context.
+// Passing it through the context keeps middleware such as retries and
+// usage tracking in the path of structured requests.
+func WithResponseFormat(ctx context.Context, format ResponseFormat) context.Context {
+	return context.WithValue(ctx, responseFormatKey{}, format)
+}
+
+// ResponseFormatFromContext returns the response format requested for a
+// chat call, if any.
+func ResponseFormatFromContext(ctx context.Context) (ResponseFormat, bool) {
+	format, ok := ctx.Value(responseFormatKey{}).(ResponseFormat)
+	return format, ok
+}
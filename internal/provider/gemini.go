@@ -5,8 +5,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"slices"
+	"strings"
 
 	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
 
@@ -40,33 +44,59 @@ func (p *GeminiProvider) Name() string {
 	return "gemini"
 }
 
+func (p *GeminiProvider) Model() string {
+	return p.model
+}
+
+// Capabilities reports the model's capabilities; embeddings use
+// text-embedding-004.
+func (p *GeminiProvider) Capabilities() Capabilities {
+	caps := ModelCapabilities("gemini", p.model)
+	caps.Embeddings = true
+	return caps
+}
+
+// ListModels returns the models that generate content, without the
+// "models/" prefix of their resource names.
+func (p *GeminiProvider) ListModels(ctx context.Context) ([]string, error) {
+	var names []string
+	it := p.client.ListModels(ctx)
+	for {
+		m, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			return names, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if slices.Contains(m.SupportedGenerationMethods, "generateContent") {
+			names = append(names, strings.TrimPrefix(m.Name, "models/"))
+		}
+	}
+}
+
 func (p *GeminiProvider) Chat(ctx context.Context, messages []Message) (*Response, error) {
 	geminiModel := p.client.GenerativeModel(p.model)
 	
-	geminiModel.Tools = []*genai.Tool{
-		{
-			FunctionDeclarations: []*genai.FunctionDeclaration{
-				{
-					Name:        "run_shell",
-					Description: "Execute a shell command",
-					Parameters: &genai.Schema{
-						Type: genai.TypeObject,
-						Properties: map[string]*genai.Schema{
-							"cmd": {
-								Type:        genai.TypeString,
-								Description: "The command to run",
-							},
-							"dir": {
-								Type:        genai.TypeString,
-								Description: "The directory to run the command in",
-							},
-						},
-						Required: []string{"cmd"},
-					},
-				},
+	specs := ToolsFromContext(ctx)
+	decls := make([]*genai.FunctionDeclaration, len(specs))
+	for i, t := range specs {
+		props := make(map[string]*genai.Schema, len(t.Params))
+		for _, param := range t.Params {
+			props[param.Name] = &genai.Schema{Type: genai.TypeString, Description: param.Description}
+		}
+		decls[i] = &genai.FunctionDeclaration{
+			Name:        t.Name,
+			Description: t.Description,
+			Parameters: &genai.Schema{
+				Type:       genai.TypeObject,
+				Properties: props,
+				Required:   t.Required(),
 			},
-		},
+		}
 	}
+	geminiModel.Tools = []*genai.Tool{{FunctionDeclarations: decls}}
+	setGeminiParams(geminiModel, ParamsFromContext(ctx))
 
 	cs := geminiModel.StartChat()
 	
@@ -134,11 +164,7 @@ func (p *GeminiProvider) Chat(ctx context.Context, messages []Message) (*Respons
 		}
 	}
 
-	usage := Usage{
-		PromptTokens:     int(resp.UsageMetadata.PromptTokenCount),
-		CompletionTokens: int(resp.UsageMetadata.CandidatesTokenCount),
-		TotalTokens:      int(resp.UsageMetadata.TotalTokenCount),
-	}
+	usage := p.usage(ctx, geminiModel, messages, contentStr, resp.UsageMetadata)
 
 	return &Response{
 		Content:   contentStr,
@@ -147,6 +173,73 @@ func (p *GeminiProvider) Chat(ctx context.Context, messages []Message) (*Respons
 	}, nil
 }
 
+// usage derives token accounting from the response metadata. When the API
+// omits metadata (some streaming and proxy setups do), prompt tokens are
+// counted with the countTokens endpoint and completion tokens are estimated.
+// setGeminiParams sets the sampling parameters of a model's requests.
+func setGeminiParams(model *genai.GenerativeModel, params Params) {
+	if params.Temperature != nil {
+		model.SetTemperature(float32(*params.Temperature))
+	}
+	if params.TopP != nil {
+		model.SetTopP(float32(*params.TopP))
+	}
+	if params.MaxTokens > 0 {
+		model.SetMaxOutputTokens(int32(min(params.MaxTokens, math.MaxInt32)))
+	}
+	model.StopSequences = params.Stop
+}
+
+func (p *GeminiProvider) usage(ctx context.Context, model *genai.GenerativeModel, messages []Message, content string, meta *genai.UsageMetadata) Usage {
+	var promptTokens, completionTokens int
+	if meta != nil {
+		promptTokens = int(meta.PromptTokenCount)
+		completionTokens = int(meta.CandidatesTokenCount)
+	}
+
+	if promptTokens == 0 {
+		if n, err := p.countTokens(ctx, model, messages); err == nil {
+			promptTokens = n
+		} else {
+			promptTokens = EstimateMessagesTokens(messages)
+		}
+	}
+	if completionTokens == 0 {
+		completionTokens = EstimateTokens(content)
+	}
+
+	return Usage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      promptTokens + completionTokens,
+	}
+}
+
+// CountTokens asks the countTokens endpoint for the prompt size of messages.
+func (p *GeminiProvider) CountTokens(ctx context.Context, messages []Message) (int, error) {
+	return p.countTokens(ctx, p.client.GenerativeModel(p.model), messages)
+}
+
+func (p *GeminiProvider) countTokens(ctx context.Context, model *genai.GenerativeModel, messages []Message) (int, error) {
+	var parts []genai.Part
+	for _, m := range messages {
+		if m.Content != "" {
+			parts = append(parts, genai.Text(m.Content))
+		}
+		for _, tc := range m.ToolCalls {
+			parts = append(parts, genai.Text(tc.Name+" "+tc.Args))
+		}
+	}
+	if len(parts) == 0 {
+		return 0, nil
+	}
+	res, err := model.CountTokens(ctx, parts...)
+	if err != nil {
+		return 0, fmt.Errorf("gemini token count failed: %w", err)
+	}
+	return int(res.TotalTokens), nil
+}
+
 func (p *GeminiProvider) Embed(ctx context.Context, text string) ([]float32, error) {
 	em := p.client.EmbeddingModel("text-embedding-004")
 	res, err := em.EmbedContent(ctx, genai.Text(text))
@@ -158,3 +251,26 @@ func (p *GeminiProvider) Embed(ctx context.Context, text string) ([]float32, err
 	}
 	return res.Embedding.Values, nil
 }
+
+func (p *GeminiProvider) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	em := p.client.EmbeddingModel("text-embedding-004")
+	batch := em.NewBatch()
+	for _, text := range texts {
+		batch.AddContent(genai.Text(text))
+	}
+	res, err := em.BatchEmbedContents(ctx, batch)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(res.Embeddings))
+	}
+	vectors := make([][]float32, len(texts))
+	for i, e := range res.Embeddings {
+		vectors[i] = e.Values
+	}
+	return vectors, nil
+}
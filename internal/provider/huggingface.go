@@ -0,0 +1,151 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	// huggingFaceDefaultModel is the model name text-generation-inference
+	// accepts for the one model it serves.
+	huggingFaceDefaultModel = "tgi"
+	// maxFeatureResponse bounds a feature-extraction response body.
+	maxFeatureResponse = 64 << 20
+)
+
+// HuggingFaceProvider serves chat through the Messages API of a Hugging Face
+// Inference Endpoint or text-generation-inference (TGI) server, which is
+// OpenAI-compatible, and embeddings through a feature-extraction endpoint,
+// such as a sentence-transformers Inference Endpoint or a
+// text-embeddings-inference server.
+type HuggingFaceProvider struct {
+	*OpenAIProvider
+	embedURL string
+	token    string
+}
+
+// NewHuggingFaceProvider creates a provider for the endpoint at endpoint,
+// with token sent as a bearer token when set. An empty embedEndpoint leaves
+// the provider without embeddings; an empty model uses the one model a TGI
+// server serves.
+func NewHuggingFaceProvider(endpoint, token, embedEndpoint, model string) (*HuggingFaceProvider, error) {
+	if endpoint == "" {
+		return nil, errors.New("endpoint URL is required")
+	}
+	if model == "" {
+		model = huggingFaceDefaultModel
+	}
+	return &HuggingFaceProvider{
+		OpenAIProvider: newOpenAIClient("huggingface", token, messagesURL(endpoint), model, ""),
+		embedURL:       embedEndpoint,
+		token:          token,
+	}, nil
+}
+
+// messagesURL returns the base URL of the Messages API of an endpoint,
+// which serves it under /v1.
+func messagesURL(endpoint string) string {
+	endpoint = strings.TrimRight(endpoint, "/")
+	if strings.HasSuffix(endpoint, "/v1") {
+		return endpoint
+	}
+	return endpoint + "/v1"
+}
+
+// Capabilities reports embeddings only with a feature-extraction endpoint.
+func (p *HuggingFaceProvider) Capabilities() Capabilities {
+	caps := ModelCapabilities(p.name, p.model)
+	caps.Embeddings = p.embedURL != ""
+	return caps
+}
+
+func (p *HuggingFaceProvider) Embed(ctx context.Context, text string) ([]float32, error) {
+	vectors, err := p.EmbedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+// EmbedBatch embeds texts in one feature-extraction request. Models that
+// return a vector per token are mean-pooled into one vector per text.
+func (p *HuggingFaceProvider) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	if p.embedURL == "" {
+		return nil, fmt.Errorf("%s does not support embeddings without an embedding endpoint", p.name)
+	}
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	body, err := json.Marshal(map[string]interface{}{"inputs": texts})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.embedURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s feature extraction failed: %w", p.name, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFeatureResponse))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s feature extraction failed: %s: %s", p.name, resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	vectors, err := decodeFeatures(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s feature extraction returned %w", p.name, err)
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(vectors))
+	}
+	return vectors, nil
+}
+
+// decodeFeatures reads a feature-extraction response: a vector per input,
+// or a vector per token of each input, which is mean-pooled.
+func decodeFeatures(data []byte) ([][]float32, error) {
+	var pooled [][]float32
+	if err := json.Unmarshal(data, &pooled); err == nil {
+		return pooled, nil
+	}
+	var tokens [][][]float32
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("an unexpected response: %w", err)
+	}
+	vectors := make([][]float32, len(tokens))
+	for i, tokenVectors := range tokens {
+		if len(tokenVectors) == 0 {
+			return nil, fmt.Errorf("no features for input %d", i)
+		}
+		mean := make([]float32, len(tokenVectors[0]))
+		for _, v := range tokenVectors {
+			if len(v) != len(mean) {
+				return nil, fmt.Errorf("features of different sizes for input %d", i)
+			}
+			for j, x := range v {
+				mean[j] += x
+			}
+		}
+		for j := range mean {
+			mean[j] /= float32(len(tokenVectors))
+		}
+		vectors[i] = mean
+	}
+	return vectors, nil
+}
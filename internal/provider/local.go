@@ -0,0 +1,79 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// LocalProviders are the presets for OpenAI-compatible local servers, which
+// need no API key and report their models at /v1/models.
+var LocalProviders = []string{"lmstudio", "llamacpp"}
+
+// localBaseURLs are the default addresses of the local servers: LM Studio
+// listens on 1234 and llama.cpp's llama-server on 8080.
+var localBaseURLs = map[string]string{
+	"lmstudio": "http://localhost:1234/v1",
+	"llamacpp": "http://localhost:8080/v1",
+}
+
+// modelDiscoveryTimeout bounds the request for a local server's models.
+const modelDiscoveryTimeout = 5 * time.Second
+
+// ModelLister is implemented by providers that can list the models their
+// API serves.
+type ModelLister interface {
+	ListModels(ctx context.Context) ([]string, error)
+}
+
+// NewLMStudioProvider creates a provider for LM Studio's local server. An
+// empty baseURL uses LM Studio's default port; an empty model uses the first
+// model the server lists.
+func NewLMStudioProvider(baseURL, model string) (*OpenAIProvider, error) {
+	return newLocalProvider("lmstudio", baseURL, model)
+}
+
+// NewLlamaCppProvider creates a provider for llama.cpp's llama-server, which
+// serves the one model it was started with. An empty baseURL uses
+// llama-server's default port; an empty model uses the model the server
+// lists.
+func NewLlamaCppProvider(baseURL, model string) (*OpenAIProvider, error) {
+	return newLocalProvider("llamacpp", baseURL, model)
+}
+
+// ListLocalModels returns the models a local server serves. An empty baseURL
+// uses the preset's default address.
+func ListLocalModels(ctx context.Context, name, baseURL string) ([]string, error) {
+	if _, ok := localBaseURLs[name]; !ok {
+		return nil, fmt.Errorf("unknown local provider %q", name)
+	}
+	return newOpenAIClient(name, "", localBaseURL(name, baseURL), "", "").ListModels(ctx)
+}
+
+func localBaseURL(name, baseURL string) string {
+	if baseURL == "" {
+		return localBaseURLs[name]
+	}
+	return baseURL
+}
+
+// newLocalProvider creates an OpenAI-compatible provider for a local server
+// without embeddings, discovering the model when none is given.
+func newLocalProvider(name, baseURL, model string) (*OpenAIProvider, error) {
+	baseURL = localBaseURL(name, baseURL)
+	p := newOpenAIClient(name, "", baseURL, model, "")
+	if model != "" {
+		return p, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), modelDiscoveryTimeout)
+	defer cancel()
+	models, err := p.ListModels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("no model given and listing the models at %s failed: %w", baseURL, err)
+	}
+	if len(models) == 0 {
+		return nil, fmt.Errorf("no model given and %s has no models loaded", baseURL)
+	}
+	p.model = models[0]
+	return p, nil
+}
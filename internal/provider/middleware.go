@@ -0,0 +1,335 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/felixgeelhaar/bolt/v3"
+	ollama "github.com/ollama/ollama/api"
+	openai "github.com/sashabaranov/go-openai"
+	"google.golang.org/api/googleapi"
+)
+
+// Middleware adds a cross-cutting feature, such as retries or caching, to
+// any Provider by wrapping it.
+type Middleware func(Provider) Provider
+
+// Chain wraps p with the middlewares; the first one is the outermost.
+func Chain(p Provider, middlewares ...Middleware) Provider {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		p = middlewares[i](p)
+	}
+	return p
+}
+
+// Unwrapper is implemented by middleware to expose the provider it wraps.
+type Unwrapper interface {
+	Unwrap() Provider
+}
+
+// Find returns the first provider of type T in p's middleware chain,
+// including p itself.
+func Find[T Provider](p Provider) (T, bool) {
+	for p != nil {
+		if t, ok := p.(T); ok {
+			return t, true
+		}
+		u, ok := p.(Unwrapper)
+		if !ok {
+			break
+		}
+		p = u.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
+
+// WithCache answers repeated prompts from cache; see CachingProvider.
+func WithCache(cache ResponseCache) Middleware {
+	return func(p Provider) Provider { return NewCachingProvider(p, cache) }
+}
+
+// StatusError is an HTTP error response from a provider API.
+type StatusError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s api error (%d): %s", e.Provider, e.StatusCode, e.Body)
+}
+
+// IsRetryable reports whether a failed request may succeed when repeated:
+// network errors, timeouts reported by the API, rate limiting (429), and
+// server errors (5xx). Cancellation and the caller's deadline are final.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	status := 0
+	var statusErr *StatusError
+	var apiErr *openai.APIError
+	var reqErr *openai.RequestError
+	var ollamaErr ollama.StatusError
+	var googleErr *googleapi.Error
+	switch {
+	case errors.As(err, &statusErr):
+		status = statusErr.StatusCode
+	case errors.As(err, &apiErr):
+		status = apiErr.HTTPStatusCode
+	case errors.As(err, &reqErr):
+		status = reqErr.HTTPStatusCode
+	case errors.As(err, &ollamaErr):
+		status = ollamaErr.StatusCode
+	case errors.As(err, &googleErr):
+		status = googleErr.Code
+	}
+	if status != 0 {
+		return status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= 500
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// RetryPolicy configures WithRetry.
+type RetryPolicy struct {
+	// Attempts is the total number of tries; 1 or less disables retries.
+	Attempts int
+	// Backoff is the wait before the first retry, doubling for each further
+	// one up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// OnRetry, when set, is called before each wait.
+	OnRetry func(attempt int, err error, wait time.Duration)
+}
+
+// WithRetry repeats chat and embedding requests that fail with a retryable
+// error (see IsRetryable).
+func WithRetry(policy RetryPolicy) Middleware {
+	return func(p Provider) Provider { return &retryProvider{Provider: p, policy: policy} }
+}
+
+type retryProvider struct {
+	Provider
+	policy RetryPolicy
+}
+
+func (r *retryProvider) Unwrap() Provider { return r.Provider }
+
+func (r *retryProvider) Chat(ctx context.Context, messages []Message) (*Response, error) {
+	return retry(ctx, r.policy, func() (*Response, error) { return r.Provider.Chat(ctx, messages) })
+}
+
+func (r *retryProvider) Embed(ctx context.Context, text string) ([]float32, error) {
+	return retry(ctx, r.policy, func() ([]float32, error) { return r.Provider.Embed(ctx, text) })
+}
+
+func (r *retryProvider) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	return retry(ctx, r.policy, func() ([][]float32, error) { return r.Provider.EmbedBatch(ctx, texts) })
+}
+
+func retry[T any](ctx context.Context, policy RetryPolicy, call func() (T, error)) (T, error) {
+	wait := policy.Backoff
+	for attempt := 1; ; attempt++ {
+		result, err := call()
+		if err == nil || attempt >= policy.Attempts || !IsRetryable(err) {
+			return result, err
+		}
+		if policy.OnRetry != nil {
+			policy.OnRetry(attempt, err, wait)
+		}
+		select {
+		case <-ctx.Done():
+			return result, err
+		case <-time.After(wait):
+		}
+		if wait *= 2; policy.MaxBackoff > 0 && wait > policy.MaxBackoff {
+			wait = policy.MaxBackoff
+		}
+	}
+}
+
+// Limiter paces requests; guard.RateLimiter implements it.
+type Limiter interface {
+	// Reserve takes a request slot and returns how long to wait before using it.
+	Reserve() time.Duration
+	// Cancel gives back a slot taken by Reserve that won't be used.
+	Cancel()
+}
+
+// WithRateLimit waits for the limiter before each chat and embedding request.
+func WithRateLimit(l Limiter) Middleware {
+	return WithThrottledRateLimit(l, nil)
+}
+
+// WithThrottledRateLimit is WithRateLimit, calling onThrottle, when set,
+// before a request waits for its slot. An error from onThrottle fails the
+// request instead of waiting; the slot is given back then, as it is when
+// the context ends during the wait.
+func WithThrottledRateLimit(l Limiter, onThrottle func(wait time.Duration) error) Middleware {
+	return func(p Provider) Provider { return &rateLimitProvider{Provider: p, limiter: l, onThrottle: onThrottle} }
+}
+
+type rateLimitProvider struct {
+	Provider
+	limiter    Limiter
+	onThrottle func(wait time.Duration) error
+}
+
+func (r *rateLimitProvider) Unwrap() Provider { return r.Provider }
+
+func (r *rateLimitProvider) wait(ctx context.Context) error {
+	wait := r.limiter.Reserve()
+	if wait <= 0 {
+		return nil
+	}
+	if r.onThrottle != nil {
+		if err := r.onThrottle(wait); err != nil {
+			r.limiter.Cancel()
+			return err
+		}
+	}
+	select {
+	case <-ctx.Done():
+		r.limiter.Cancel()
+		return ctx.Err()
+	case <-time.After(wait):
+		return nil
+	}
+}
+
+func (r *rateLimitProvider) Chat(ctx context.Context, messages []Message) (*Response, error) {
+	if err := r.wait(ctx); err != nil {
+		return nil, err
+	}
+	return r.Provider.Chat(ctx, messages)
+}
+
+func (r *rateLimitProvider) Embed(ctx context.Context, text string) ([]float32, error) {
+	if err := r.wait(ctx); err != nil {
+		return nil, err
+	}
+	return r.Provider.Embed(ctx, text)
+}
+
+func (r *rateLimitProvider) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	if err := r.wait(ctx); err != nil {
+		return nil, err
+	}
+	return r.Provider.EmbedBatch(ctx, texts)
+}
+
+// WithUsageEstimate fills in token counts a provider didn't report from
+// tokenizer estimates, so guard budgets stay meaningful.
+func WithUsageEstimate() Middleware {
+	return func(p Provider) Provider { return &usageEstimateProvider{Provider: p} }
+}
+
+type usageEstimateProvider struct {
+	Provider
+}
+
+func (u *usageEstimateProvider) Unwrap() Provider { return u.Provider }
+
+func (u *usageEstimateProvider) Chat(ctx context.Context, messages []Message) (*Response, error) {
+	resp, err := u.Provider.Chat(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Usage.PromptTokens == 0 {
+		resp.Usage.PromptTokens = EstimatePromptTokens(messages)
+	}
+	if resp.Usage.CompletionTokens == 0 && resp.Content != "" {
+		resp.Usage.CompletionTokens = EstimateTokens(resp.Content)
+	}
+	resp.Usage.TotalTokens = resp.Usage.PromptTokens + resp.Usage.CompletionTokens
+	return resp, nil
+}
+
+// CountTokens falls back to an estimate when the provider's count fails.
+func (u *usageEstimateProvider) CountTokens(ctx context.Context, messages []Message) (int, error) {
+	if n, err := u.Provider.CountTokens(ctx, messages); err == nil {
+		return n, nil
+	}
+	return estimateCount(ctx, messages, true)
+}
+
+// UsageTracker totals the calls, tokens, and estimated cost of the chat
+// requests made through WithUsageTracking.
+type UsageTracker struct {
+	mu    sync.Mutex
+	calls int
+	usage Usage
+	cost  float64
+}
+
+// Totals returns the number of chat calls, their summed usage, and their
+// estimated cost in USD.
+func (t *UsageTracker) Totals() (calls int, usage Usage, cost float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.calls, t.usage, t.cost
+}
+
+// WithUsageTracking adds every chat response's usage and cost to t.
+func WithUsageTracking(t *UsageTracker) Middleware {
+	return func(p Provider) Provider { return &usageTrackingProvider{Provider: p, tracker: t} }
+}
+
+type usageTrackingProvider struct {
+	Provider
+	tracker *UsageTracker
+}
+
+func (u *usageTrackingProvider) Unwrap() Provider { return u.Provider }
+
+func (u *usageTrackingProvider) Chat(ctx context.Context, messages []Message) (*Response, error) {
+	resp, err := u.Provider.Chat(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+	t := u.tracker
+	t.mu.Lock()
+	t.calls++
+	t.usage.PromptTokens += resp.Usage.PromptTokens
+	t.usage.CompletionTokens += resp.Usage.CompletionTokens
+	t.usage.TotalTokens += resp.Usage.TotalTokens
+	t.cost += EstimateCost(u.Name(), u.Model(), resp.Usage)
+	t.mu.Unlock()
+	return resp, nil
+}
+
+// WithLogging logs every chat request at debug level with its duration and
+// usage, and failures at warn level.
+func WithLogging(log *bolt.Logger) Middleware {
+	return func(p Provider) Provider { return &loggingProvider{Provider: p, log: log} }
+}
+
+type loggingProvider struct {
+	Provider
+	log *bolt.Logger
+}
+
+func (l *loggingProvider) Unwrap() Provider { return l.Provider }
+
+func (l *loggingProvider) Chat(ctx context.Context, messages []Message) (*Response, error) {
+	start := time.Now()
+	resp, err := l.Provider.Chat(ctx, messages)
+	if err != nil {
+		l.log.Warn().Str("provider", l.Name()).Str("model", l.Model()).Dur("duration", time.Since(start)).Err(err).Msg("provider request failed")
+		return nil, err
+	}
+	l.log.Debug().Str("provider", l.Name()).Str("model", l.Model()).Dur("duration", time.Since(start)).
+		Int("messages", len(messages)).Int("prompt_tokens", resp.Usage.PromptTokens).Int("completion_tokens", resp.Usage.CompletionTokens).
+		Msg("provider request")
+	return resp, nil
+}
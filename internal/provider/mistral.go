@@ -0,0 +1,36 @@
+package provider
+
+const (
+	mistralBaseURL      = "https://api.mistral.ai/v1"
+	mistralDefaultModel = "mistral-large-latest"
+	mistralEmbedModel   = "mistral-embed"
+
+	groqBaseURL      = "https://api.groq.com/openai/v1"
+	groqDefaultModel = "llama-3.3-70b-versatile"
+)
+
+// NewMistralProvider creates a provider for Mistral's chat API, which is
+// OpenAI-compatible for chat, tool calling, and embeddings.
+// An empty baseURL uses Mistral's public endpoint.
+func NewMistralProvider(apiKey, baseURL, model string) (*OpenAIProvider, error) {
+	if baseURL == "" {
+		baseURL = mistralBaseURL
+	}
+	if model == "" {
+		model = mistralDefaultModel
+	}
+	return newOpenAICompatible("mistral", apiKey, baseURL, model, mistralEmbedModel)
+}
+
+// NewGroqProvider creates a provider for Groq's OpenAI-compatible endpoint.
+// Groq serves chat and tool calling but no embeddings, so memory search
+// falls back as it does for CLI providers.
+func NewGroqProvider(apiKey, baseURL, model string) (*OpenAIProvider, error) {
+	if baseURL == "" {
+		baseURL = groqBaseURL
+	}
+	if model == "" {
+		model = groqDefaultModel
+	}
+	return newOpenAICompatible("groq", apiKey, baseURL, model, "")
+}
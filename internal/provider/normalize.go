@@ -0,0 +1,76 @@
+package provider
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxToolCallIDLen is the longest tool call ID every API accepts.
+const maxToolCallIDLen = 64
+
+// NormalizeHistory rewrites a conversation recorded with any provider into
+// a form the named provider's API accepts, for sessions that switch
+// providers mid-conversation. Tool call IDs are reissued in the target's
+// format: the function name for Gemini, which matches results by name;
+// nine alphanumeric characters for Mistral; and otherwise the original ID
+// restricted to letters, digits, "_" and "-" and made unique. Tool results
+// whose call is no longer in the history are turned into user messages.
+// The result has as many messages as the input, in the same order.
+func NormalizeHistory(messages []Message, target string) []Message {
+	out := make([]Message, len(messages))
+	issued := make(map[string]bool)
+	// Calls awaiting their result, by original ID; Gemini may reuse one
+	pending := make(map[string][]string)
+	n := 0
+	for i, m := range messages {
+		out[i] = m
+		if len(m.ToolCalls) > 0 {
+			out[i].ToolCalls = make([]ToolCall, len(m.ToolCalls))
+			for j, tc := range m.ToolCalls {
+				n++
+				id := toolCallID(tc, target, n, issued)
+				issued[id] = true
+				pending[tc.ID] = append(pending[tc.ID], id)
+				tc.ID = id
+				out[i].ToolCalls[j] = tc
+			}
+		}
+		if m.ToolCallID == "" {
+			continue
+		}
+		if ids := pending[m.ToolCallID]; len(ids) > 0 {
+			out[i].ToolCallID = ids[0]
+			pending[m.ToolCallID] = ids[1:]
+			continue
+		}
+		out[i].Role = "user"
+		out[i].ToolCallID = ""
+		out[i].Content = "[Earlier tool result] " + m.Content
+	}
+	return out
+}
+
+// toolCallID issues the nth tool call's ID for target, avoiding those
+// already issued where the API needs unique IDs.
+func toolCallID(tc ToolCall, target string, n int, issued map[string]bool) string {
+	switch target {
+	case "gemini":
+		return tc.Name
+	case "mistral":
+		return fmt.Sprintf("call%05d", n%100000)
+	}
+	id := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		}
+		return -1
+	}, tc.ID)
+	if len(id) > maxToolCallIDLen {
+		id = id[:maxToolCallIDLen]
+	}
+	if id == "" || issued[id] {
+		id = fmt.Sprintf("call_%d", n)
+	}
+	return id
+}
@@ -2,6 +2,8 @@ package provider
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -38,38 +40,55 @@ func (p *OllamaProvider) Name() string {
 	return "ollama"
 }
 
-func (p *OllamaProvider) Chat(ctx context.Context, messages []Message) (*Response, error) {
-	var apiMsgs []api.Message
-	for _, m := range messages {
-		apiMsgs = append(apiMsgs, api.Message{
-			Role:    m.Role,
-			Content: m.Content,
-		})
-	}
+func (p *OllamaProvider) Model() string {
+	return p.model
+}
 
-	props := api.NewToolPropertiesMap()
-	props.Set("cmd", api.ToolProperty{
-		Type:        api.PropertyType{"string"},
-		Description: "The command to run",
-	})
-	props.Set("dir", api.ToolProperty{
-		Type:        api.PropertyType{"string"},
-		Description: "The directory to run the command in",
-	})
+// Capabilities reports the model's capabilities; embeddings use the chat model.
+func (p *OllamaProvider) Capabilities() Capabilities {
+	caps := ModelCapabilities("ollama", p.model)
+	caps.Embeddings = true
+	return caps
+}
 
-	tools := []api.Tool{
-		{
+// ListModels returns the models pulled on the Ollama server.
+func (p *OllamaProvider) ListModels(ctx context.Context) ([]string, error) {
+	resp, err := p.client.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(resp.Models))
+	for i, m := range resp.Models {
+		names[i] = m.Name
+	}
+	return names, nil
+}
+
+func (p *OllamaProvider) Chat(ctx context.Context, messages []Message) (*Response, error) {
+	apiMsgs := ollamaMessages(messages)
+
+	specs := ToolsFromContext(ctx)
+	tools := make([]api.Tool, len(specs))
+	for i, t := range specs {
+		props := api.NewToolPropertiesMap()
+		for _, param := range t.Params {
+			props.Set(param.Name, api.ToolProperty{
+				Type:        api.PropertyType{"string"},
+				Description: param.Description,
+			})
+		}
+		tools[i] = api.Tool{
 			Type: "function",
 			Function: api.ToolFunction{
-				Name:        "run_shell",
-				Description: "Execute a shell command",
+				Name:        t.Name,
+				Description: t.Description,
 				Parameters: api.ToolFunctionParameters{
 					Type:       "object",
 					Properties: props,
-					Required:   []string{"cmd"},
+					Required:   t.Required(),
 				},
 			},
-		},
+		}
 	}
 
 	req := &api.ChatRequest{
@@ -77,22 +96,28 @@ func (p *OllamaProvider) Chat(ctx context.Context, messages []Message) (*Respons
 		Messages: apiMsgs,
 		Stream:   new(bool), // false
 		Tools:    tools,
+		Options:  ollamaOptions(ParamsFromContext(ctx)),
 	}
 
 	var respContent string
-	var totalTokens int
+	var promptTokens, completionTokens int
 	var toolCalls []ToolCall
 
 	err := p.client.Chat(ctx, req, func(resp api.ChatResponse) error {
 		respContent += resp.Message.Content
 		if resp.Done {
-			totalTokens = resp.EvalCount + resp.PromptEvalCount
+			promptTokens = resp.PromptEvalCount
+			completionTokens = resp.EvalCount
 		}
 		
 		for _, tc := range resp.Message.ToolCalls {
 			argsBytes, _ := json.Marshal(tc.Function.Arguments)
+			id := tc.ID
+			if id == "" {
+				id = newOllamaCallID(tc.Function.Name, len(toolCalls))
+			}
 			toolCalls = append(toolCalls, ToolCall{
-				ID:   "call_" + tc.Function.Name,
+				ID:   id,
 				Name: tc.Function.Name,
 				Args: string(argsBytes),
 			})
@@ -108,18 +133,90 @@ func (p *OllamaProvider) Chat(ctx context.Context, messages []Message) (*Respons
 	return &Response{
 		Content:   respContent,
 		ToolCalls: toolCalls,
-		Usage:     usageFromTokens(totalTokens),
+		Usage:     ollamaUsage(messages, respContent, promptTokens, completionTokens),
 	}, nil
 }
 
-func usageFromTokens(total int) Usage {
+// ollamaOptions maps sampling parameters to the model options Ollama takes;
+// nil keeps the model's defaults.
+func ollamaOptions(params Params) map[string]any {
+	if params.IsZero() {
+		return nil
+	}
+	options := make(map[string]any)
+	if params.Temperature != nil {
+		options["temperature"] = *params.Temperature
+	}
+	if params.TopP != nil {
+		options["top_p"] = *params.TopP
+	}
+	if params.MaxTokens > 0 {
+		options["num_predict"] = params.MaxTokens
+	}
+	if len(params.Stop) > 0 {
+		options["stop"] = params.Stop
+	}
+	return options
+}
+
+// ollamaMessages converts history for the Ollama API, keeping assistant tool
+// calls and linking each tool result to its call by ID and tool name.
+func ollamaMessages(messages []Message) []api.Message {
+	names := make(map[string]string) // tool call ID -> tool name
+	apiMsgs := make([]api.Message, 0, len(messages))
+	for _, m := range messages {
+		msg := api.Message{Role: m.Role, Content: m.Content}
+		for _, tc := range m.ToolCalls {
+			names[tc.ID] = tc.Name
+			args := api.NewToolCallFunctionArguments()
+			if tc.Args != "" {
+				_ = json.Unmarshal([]byte(tc.Args), &args)
+			}
+			msg.ToolCalls = append(msg.ToolCalls, api.ToolCall{
+				ID:       tc.ID,
+				Function: api.ToolCallFunction{Index: len(msg.ToolCalls), Name: tc.Name, Arguments: args},
+			})
+		}
+		if m.ToolCallID != "" {
+			msg.ToolCallID = m.ToolCallID
+			msg.ToolName = names[m.ToolCallID]
+		}
+		apiMsgs = append(apiMsgs, msg)
+	}
+	return apiMsgs
+}
+
+// newOllamaCallID generates a tool call ID. Ollama does not always assign
+// one, and the name alone collides when a tool is called twice.
+func newOllamaCallID(name string, index int) string {
+	var suffix [4]byte
+	_, _ = rand.Read(suffix[:])
+	return fmt.Sprintf("call_%s_%d_%s", name, index, hex.EncodeToString(suffix[:]))
+}
+
+// ollamaUsage builds usage from Ollama's eval counters. Ollama omits
+// prompt_eval_count when the prompt is served from its KV cache, so
+// missing counts fall back to tokenizer estimates to keep Guard budgets
+// meaningful.
+func ollamaUsage(messages []Message, content string, promptTokens, completionTokens int) Usage {
+	if promptTokens == 0 {
+		promptTokens = EstimateMessagesTokens(messages)
+	}
+	if completionTokens == 0 {
+		completionTokens = EstimateTokens(content)
+	}
 	return Usage{
-		TotalTokens:      total,
-		PromptTokens:     0,
-		CompletionTokens: total,
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      promptTokens + completionTokens,
 	}
 }
 
+// CountTokens estimates the prompt size; Ollama has no token counting endpoint.
+func (p *OllamaProvider) CountTokens(ctx context.Context, messages []Message) (int, error) {
+	return estimateCount(ctx, messages, true)
+}
+
 func (p *OllamaProvider) Embed(ctx context.Context, text string) ([]float32, error) {
 	req := &api.EmbeddingRequest{
 		Model:  p.model,
@@ -134,4 +231,21 @@ func (p *OllamaProvider) Embed(ctx context.Context, text string) ([]float32, err
 		vec[i] = float32(v)
 	}
 	return vec, nil
+}
+
+func (p *OllamaProvider) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	resp, err := p.client.Embed(ctx, &api.EmbedRequest{
+		Model: p.model,
+		Input: texts,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(resp.Embeddings))
+	}
+	return resp.Embeddings, nil
 }
\ No newline at end of file
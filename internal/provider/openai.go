@@ -4,6 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
+	"net/http"
+	"sync/atomic"
 
 	openai "github.com/sashabaranov/go-openai"
 )
@@ -11,31 +14,82 @@ import (
 type OpenAIProvider struct {
 	client *openai.Client
 	model  string
+
+	// name identifies the service for OpenAI-compatible APIs (e.g. "mistral", "groq").
+	name string
+	// embedModel is empty for services without an embeddings endpoint.
+	embedModel openai.EmbeddingModel
+	// noJSONSchema is set once the service rejected a json_schema response
+	// format, or for services known not to take one; requests with a
+	// ResponseFormat are then sent without it.
+	noJSONSchema atomic.Bool
 }
 
+// jsonSchemaServices are the OpenAI-compatible services known to accept a
+// strict json_schema response format. Others get a plain request, and the
+// caller falls back to reading the answer as text.
+var jsonSchemaServices = map[string]bool{"openai": true, "mistral": true, "lmstudio": true, "llamacpp": true}
+
 func NewOpenAIProvider(apiKey, baseURL, model string) (*OpenAIProvider, error) {
+	if model == "" {
+		model = openai.GPT4TurboPreview
+	}
+	return newOpenAICompatible("openai", apiKey, baseURL, model, openai.SmallEmbedding3)
+}
+
+// newOpenAICompatible creates a provider for a service implementing the OpenAI chat API.
+func newOpenAICompatible(name, apiKey, baseURL, model string, embedModel openai.EmbeddingModel) (*OpenAIProvider, error) {
 	if apiKey == "" {
 		return nil, errors.New("API key is required")
 	}
+	return newOpenAIClient(name, apiKey, baseURL, model, embedModel), nil
+}
 
+// newOpenAIClient creates a provider for an OpenAI-compatible API without
+// requiring an API key, as local servers need none.
+func newOpenAIClient(name, apiKey, baseURL, model string, embedModel openai.EmbeddingModel) *OpenAIProvider {
 	config := openai.DefaultConfig(apiKey)
 	if baseURL != "" {
 		config.BaseURL = baseURL
 	}
 
-	client := openai.NewClientWithConfig(config)
-	if model == "" {
-		model = openai.GPT4TurboPreview
+	p := &OpenAIProvider{
+		client:     openai.NewClientWithConfig(config),
+		model:      model,
+		name:       name,
+		embedModel: embedModel,
 	}
+	p.noJSONSchema.Store(!jsonSchemaServices[name])
+	return p
+}
 
-	return &OpenAIProvider{
-		client: client,
-		model:  model,
-	}, nil
+// ListModels returns the IDs of the models the API serves.
+func (p *OpenAIProvider) ListModels(ctx context.Context) ([]string, error) {
+	resp, err := p.client.ListModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(resp.Models))
+	for i, m := range resp.Models {
+		ids[i] = m.ID
+	}
+	return ids, nil
 }
 
 func (p *OpenAIProvider) Name() string {
-	return "openai"
+	return p.name
+}
+
+func (p *OpenAIProvider) Model() string {
+	return p.model
+}
+
+// Capabilities reports embeddings only for services with an embeddings
+// endpoint.
+func (p *OpenAIProvider) Capabilities() Capabilities {
+	caps := ModelCapabilities(p.name, p.model)
+	caps.Embeddings = p.embedModel != ""
+	return caps
 }
 
 func (p *OpenAIProvider) Chat(ctx context.Context, messages []Message) (*Response, error) {
@@ -66,41 +120,46 @@ func (p *OpenAIProvider) Chat(ctx context.Context, messages []Message) (*Respons
 		reqMsgs[i] = msg
 	}
 
-	// Define tools
-	tools := []openai.Tool{
-		{
+	specs := ToolsFromContext(ctx)
+	tools := make([]openai.Tool, len(specs))
+	for i, t := range specs {
+		tools[i] = openai.Tool{
 			Type: openai.ToolTypeFunction,
 			Function: &openai.FunctionDefinition{
-				Name:        "run_shell",
-				Description: "Execute a shell command",
-				Parameters: map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"cmd": map[string]interface{}{
-							"type":        "string",
-							"description": "The command to run",
-						},
-						"dir": map[string]interface{}{
-							"type":        "string",
-							"description": "The directory to run the command in",
-						},
-					},
-					"required": []string{"cmd"},
-				},
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  t.JSONSchema(),
 			},
-		},
+		}
 	}
 
-	resp, err := p.client.CreateChatCompletion(
-		ctx,
-		openai.ChatCompletionRequest{
-			Model:    p.model,
-			Messages: reqMsgs,
-			Tools:    tools,
-		},
-	)
+	req := openai.ChatCompletionRequest{
+		Model:    p.model,
+		Messages: reqMsgs,
+		Tools:    tools,
+	}
+	p.applyParams(&req, ParamsFromContext(ctx))
+	if format, ok := ResponseFormatFromContext(ctx); ok && !p.noJSONSchema.Load() {
+		req.ResponseFormat = &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+				Name:   format.Name,
+				Schema: format.Schema,
+				Strict: true,
+			},
+		}
+	}
+
+	resp, err := p.client.CreateChatCompletion(ctx, req)
+	if err != nil && req.ResponseFormat != nil && isBadRequest(err) {
+		// A compatible server behind base_url may not know json_schema;
+		// ask again without it, and from now on
+		p.noJSONSchema.Store(true)
+		req.ResponseFormat = nil
+		resp, err = p.client.CreateChatCompletion(ctx, req)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("openai completion failed: %w", err)
+		return nil, fmt.Errorf("%s completion failed: %w", p.name, err)
 	}
 
 	choice := resp.Choices[0]
@@ -127,12 +186,52 @@ func (p *OpenAIProvider) Chat(ctx context.Context, messages []Message) (*Respons
 	return result, nil
 }
 
+// isBadRequest reports whether err is the API refusing a request as
+// invalid (HTTP 400).
+func isBadRequest(err error) bool {
+	var apiErr *openai.APIError
+	var reqErr *openai.RequestError
+	return errors.As(err, &apiErr) && apiErr.HTTPStatusCode == http.StatusBadRequest ||
+		errors.As(err, &reqErr) && reqErr.HTTPStatusCode == http.StatusBadRequest
+}
+
+// applyParams sets the sampling parameters of a request.
+func (p *OpenAIProvider) applyParams(req *openai.ChatCompletionRequest, params Params) {
+	if params.Temperature != nil {
+		// The client omits a zero temperature, so 0 is sent as the smallest
+		// one it keeps
+		req.Temperature = max(float32(*params.Temperature), math.SmallestNonzeroFloat32)
+	}
+	if params.TopP != nil {
+		req.TopP = float32(*params.TopP)
+	}
+	if params.MaxTokens > 0 {
+		// OpenAI's reasoning models only take max_completion_tokens, which
+		// the compatible servers don't all know
+		if p.name == "openai" {
+			req.MaxCompletionTokens = params.MaxTokens
+		} else {
+			req.MaxTokens = params.MaxTokens
+		}
+	}
+	req.Stop = params.Stop
+}
+
+// CountTokens estimates the prompt size; OpenAI-compatible APIs have no
+// token counting endpoint.
+func (p *OpenAIProvider) CountTokens(ctx context.Context, messages []Message) (int, error) {
+	return estimateCount(ctx, messages, true)
+}
+
 func (p *OpenAIProvider) Embed(ctx context.Context, text string) ([]float32, error) {
+	if p.embedModel == "" {
+		return nil, fmt.Errorf("%s does not support embeddings", p.name)
+	}
 	resp, err := p.client.CreateEmbeddings(
 		ctx,
 		openai.EmbeddingRequest{
 			Input: []string{text},
-			Model: openai.SmallEmbedding3,
+			Model: p.embedModel,
 		},
 	)
 	if err != nil {
@@ -142,4 +241,34 @@ func (p *OpenAIProvider) Embed(ctx context.Context, text string) ([]float32, err
 		return nil, fmt.Errorf("no embedding returned")
 	}
 	return resp.Data[0].Embedding, nil
+}
+
+func (p *OpenAIProvider) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	if p.embedModel == "" {
+		return nil, fmt.Errorf("%s does not support embeddings", p.name)
+	}
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	resp, err := p.client.CreateEmbeddings(
+		ctx,
+		openai.EmbeddingRequest{
+			Input: texts,
+			Model: p.embedModel,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Data) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(resp.Data))
+	}
+	vectors := make([][]float32, len(texts))
+	for _, d := range resp.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding index %d out of range", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	return vectors, nil
 }
\ No newline at end of file
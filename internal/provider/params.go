@@ -0,0 +1,145 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Params are the sampling parameters of a chat request. Unset fields leave
+// the provider's own default, so an empty Params changes nothing.
+type Params struct {
+	Temperature *float64 `json:"temperature,omitempty" yaml:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty" yaml:"top_p,omitempty"`
+	// MaxTokens caps the tokens of the answer; 0 uses the provider's default.
+	MaxTokens int      `json:"max_tokens,omitempty" yaml:"max_tokens,omitempty"`
+	Stop      []string `json:"stop,omitempty" yaml:"stop,omitempty"`
+}
+
+// IsZero reports whether no parameter is set.
+func (p Params) IsZero() bool {
+	return p.Temperature == nil && p.TopP == nil && p.MaxTokens == 0 && len(p.Stop) == 0
+}
+
+// Merge returns p with the parameters set in over replacing its own.
+func (p Params) Merge(over Params) Params {
+	if over.Temperature != nil {
+		p.Temperature = over.Temperature
+	}
+	if over.TopP != nil {
+		p.TopP = over.TopP
+	}
+	if over.MaxTokens != 0 {
+		p.MaxTokens = over.MaxTokens
+	}
+	if len(over.Stop) > 0 {
+		p.Stop = over.Stop
+	}
+	return p
+}
+
+// Validate checks the parameters are in the ranges providers accept.
+// Anthropic's temperature only goes up to 1; higher ones are sent as 1.
+func (p Params) Validate() error {
+	if p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > 2) {
+		return fmt.Errorf("temperature must be between 0 and 2, got %g", *p.Temperature)
+	}
+	if p.TopP != nil && (*p.TopP <= 0 || *p.TopP > 1) {
+		return fmt.Errorf("top_p must be above 0 and at most 1, got %g", *p.TopP)
+	}
+	if p.MaxTokens < 0 {
+		return fmt.Errorf("max_tokens must not be negative, got %d", p.MaxTokens)
+	}
+	for _, stop := range p.Stop {
+		if stop == "" {
+			return errors.New("stop sequences must not be empty")
+		}
+	}
+	return nil
+}
+
+// Phase is the part of a session a chat request belongs to.
+type Phase string
+
+const (
+	// PhasePlanning drafts the session's plan.
+	PhasePlanning Phase = "planning"
+	// PhaseExecution works on the task; requests without a phase belong to it.
+	PhaseExecution Phase = "execution"
+	// PhaseSummary summarizes the history or the finished session.
+	PhaseSummary Phase = "summary"
+)
+
+// PhaseParams are sampling parameters per phase, e.g. a higher temperature
+// for planning than for execution. Each phase's parameters override Default.
+type PhaseParams struct {
+	Default   Params `json:"default,omitempty" yaml:"default,omitempty"`
+	Planning  Params `json:"planning,omitempty" yaml:"planning,omitempty"`
+	Execution Params `json:"execution,omitempty" yaml:"execution,omitempty"`
+	Summary   Params `json:"summary,omitempty" yaml:"summary,omitempty"`
+}
+
+// For returns the parameters of a phase.
+func (p PhaseParams) For(phase Phase) Params {
+	switch phase {
+	case PhasePlanning:
+		return p.Default.Merge(p.Planning)
+	case PhaseSummary:
+		return p.Default.Merge(p.Summary)
+	default:
+		return p.Default.Merge(p.Execution)
+	}
+}
+
+// IsZero reports whether no phase sets a parameter.
+func (p PhaseParams) IsZero() bool {
+	return p.Default.IsZero() && p.Planning.IsZero() && p.Execution.IsZero() && p.Summary.IsZero()
+}
+
+// Merge returns p with the parameters set in over replacing its own, phase
+// by phase.
+func (p PhaseParams) Merge(over PhaseParams) PhaseParams {
+	return PhaseParams{
+		Default:   p.Default.Merge(over.Default),
+		Planning:  p.Planning.Merge(over.Planning),
+		Execution: p.Execution.Merge(over.Execution),
+		Summary:   p.Summary.Merge(over.Summary),
+	}
+}
+
+// Validate checks the parameters of every phase.
+func (p PhaseParams) Validate() error {
+	for _, phase := range []struct {
+		name   string
+		params Params
+	}{{"default", p.Default}, {"planning", p.Planning}, {"execution", p.Execution}, {"summary", p.Summary}} {
+		if err := phase.params.Validate(); err != nil {
+			return fmt.Errorf("%s: %w", phase.name, err)
+		}
+	}
+	return nil
+}
+
+type paramsKey struct{}
+
+type phaseKey struct{}
+
+// WithParams attaches sampling parameters to a request context; chat calls
+// under it use the parameters of the phase set with WithPhase.
+func WithParams(ctx context.Context, params PhaseParams) context.Context {
+	return context.WithValue(ctx, paramsKey{}, params)
+}
+
+// WithPhase marks the chat requests made under a context as belonging to
+// a phase.
+func WithPhase(ctx context.Context, phase Phase) context.Context {
+	return context.WithValue(ctx, phaseKey{}, phase)
+}
+
+// ParamsFromContext returns the sampling parameters for a chat call: those
+// of the context's phase, execution by default.
+func ParamsFromContext(ctx context.Context) Params {
+	params, _ := ctx.Value(paramsKey{}).(PhaseParams)
+	phase, _ := ctx.Value(phaseKey{}).(Phase)
+	return params.For(phase)
+}
@@ -0,0 +1,73 @@
+package provider
+
+import "strings"
+
+// Pricing holds per-million-token prices in USD.
+type Pricing struct {
+	PromptPerMillion     float64
+	CompletionPerMillion float64
+}
+
+// modelPricing maps model name prefixes to their list prices.
+// Lookups use the longest matching prefix so dated model variants
+// (e.g. "gpt-4o-2024-08-06") resolve to their family price.
+var modelPricing = map[string]Pricing{
+	"gpt-4o-mini":       {PromptPerMillion: 0.15, CompletionPerMillion: 0.60},
+	"gpt-4o":            {PromptPerMillion: 2.50, CompletionPerMillion: 10.00},
+	"gpt-4-turbo":       {PromptPerMillion: 10.00, CompletionPerMillion: 30.00},
+	"gpt-4-1106":        {PromptPerMillion: 10.00, CompletionPerMillion: 30.00},
+	"gpt-4-0125":        {PromptPerMillion: 10.00, CompletionPerMillion: 30.00},
+	"gpt-4":             {PromptPerMillion: 30.00, CompletionPerMillion: 60.00},
+	"gpt-3.5-turbo":     {PromptPerMillion: 0.50, CompletionPerMillion: 1.50},
+	"claude-3-opus":     {PromptPerMillion: 15.00, CompletionPerMillion: 75.00},
+	"claude-3-5-sonnet": {PromptPerMillion: 3.00, CompletionPerMillion: 15.00},
+	"claude-3-sonnet":   {PromptPerMillion: 3.00, CompletionPerMillion: 15.00},
+	"claude-3-5-haiku":  {PromptPerMillion: 0.80, CompletionPerMillion: 4.00},
+	"claude-3-haiku":    {PromptPerMillion: 0.25, CompletionPerMillion: 1.25},
+	"gemini-1.5-pro":    {PromptPerMillion: 1.25, CompletionPerMillion: 5.00},
+	"gemini-1.5-flash":  {PromptPerMillion: 0.075, CompletionPerMillion: 0.30},
+	"mistral-large":     {PromptPerMillion: 2.00, CompletionPerMillion: 6.00},
+	"mistral-small":     {PromptPerMillion: 0.20, CompletionPerMillion: 0.60},
+	"codestral":         {PromptPerMillion: 0.30, CompletionPerMillion: 0.90},
+	"open-mistral-nemo": {PromptPerMillion: 0.15, CompletionPerMillion: 0.15},
+	"llama-3.3-70b":     {PromptPerMillion: 0.59, CompletionPerMillion: 0.79},
+	"llama-3.1-8b":      {PromptPerMillion: 0.05, CompletionPerMillion: 0.08},
+	"mixtral-8x7b":      {PromptPerMillion: 0.24, CompletionPerMillion: 0.24},
+}
+
+// freeProviders run models locally and never incur API cost.
+var freeProviders = map[string]bool{
+	"ollama":   true,
+	"lmstudio": true,
+	"llamacpp": true,
+	"stub":     true,
+}
+
+// LookupPricing returns the pricing for a model, if known.
+func LookupPricing(model string) (Pricing, bool) {
+	model = strings.ToLower(model)
+	best := ""
+	for prefix := range modelPricing {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best == "" {
+		return Pricing{}, false
+	}
+	return modelPricing[best], true
+}
+
+// EstimateCost returns the estimated USD cost of the given usage.
+// Unknown models and local providers are reported as zero cost.
+func EstimateCost(providerName, model string, u Usage) float64 {
+	if freeProviders[providerName] {
+		return 0
+	}
+	pricing, ok := LookupPricing(model)
+	if !ok {
+		return 0
+	}
+	return float64(u.PromptTokens)/1e6*pricing.PromptPerMillion +
+		float64(u.CompletionTokens)/1e6*pricing.CompletionPerMillion
+}
@@ -17,6 +17,9 @@ type Response struct {
 	Content      string `json:"content"`
 	ToolCalls    []ToolCall `json:"tool_calls,omitempty"`
 	Usage        Usage  `json:"usage"`
+	// Reasoning is the model's extended thinking, kept apart from Content
+	// so it never enters the conversation history.
+	Reasoning string `json:"reasoning,omitempty"`
 }
 
 type ToolCall struct {
@@ -29,6 +32,9 @@ type Usage struct {
 	PromptTokens     int `json:"prompt_tokens"`
 	CompletionTokens int `json:"completion_tokens"`
 	TotalTokens      int `json:"total_tokens"`
+	// ThinkingTokens is the part of CompletionTokens spent on extended
+	// thinking.
+	ThinkingTokens int `json:"thinking_tokens,omitempty"`
 }
 
 // Provider defines the interface for AI model interactions.
@@ -38,7 +44,36 @@ type Provider interface {
 	
 	// Embed generates a vector embedding for the given text.
 	Embed(ctx context.Context, text string) ([]float32, error)
+
+	// EmbedBatch generates embeddings for several texts, in input order,
+	// with one request where the provider's API supports it.
+	EmbedBatch(ctx context.Context, texts []string) ([][]float32, error)
 	
 	// Name returns the provider identifier (e.g., "mock", "openai").
 	Name() string
+
+	// Model returns the model identifier used for requests (may be empty).
+	Model() string
+
+	// CountTokens returns the prompt size of messages before they are sent,
+	// using the provider's token counting API where it has one and
+	// EstimateMessagesTokens otherwise.
+	CountTokens(ctx context.Context, messages []Message) (int, error)
+}
+
+// Names lists the providers simon creates by name, as accepted by
+// `simon run --provider` and a spec's provider field.
+var Names = []string{"ollama", "openai", "gemini", "anthropic", "mistral", "groq", "huggingface", "lmstudio", "llamacpp", "plugin", "fixture"}
+
+// EmbedEach embeds texts one at a time, for providers without a batch API.
+func EmbedEach(ctx context.Context, p Provider, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+	for _, text := range texts {
+		vec, err := p.Embed(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, vec)
+	}
+	return vectors, nil
 }
@@ -2,14 +2,41 @@ package provider
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/ollama/ollama/api"
 )
 
 func TestOpenAIProvider(t *testing.T) {
+	var formats []string
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ResponseFormat *struct {
+				Type       string `json:"type"`
+				JSONSchema struct {
+					Name   string `json:"name"`
+					Strict bool   `json:"strict"`
+				} `json:"json_schema"`
+			} `json:"response_format"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if f := req.ResponseFormat; f != nil {
+			formats = append(formats, f.Type+":"+f.JSONSchema.Name)
+			if !f.JSONSchema.Strict {
+				t.Error("Expected a strict schema")
+			}
+		} else {
+			formats = append(formats, "")
+		}
+
 		w.Header().Set("Content-Type", "application/json")
 		w.Write([]byte(`{
 			"choices": [{"message": {"content": "hello", "role": "assistant"}}],
@@ -30,6 +57,50 @@ func TestOpenAIProvider(t *testing.T) {
 	if resp.Content != "hello" {
 		t.Errorf("Expected 'hello', got '%s'", resp.Content)
 	}
+
+	ctx := WithResponseFormat(context.Background(), ResponseFormat{Name: "plan", Schema: json.RawMessage(`{"type": "object"}`)})
+	if _, err := p.Chat(ctx, []Message{{Role: "user", Content: "plan"}}); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if len(formats) != 2 || formats[0] != "" || formats[1] != "json_schema:plan" {
+		t.Errorf("Expected a strict JSON schema only for the structured request, got %q", formats)
+	}
+}
+
+func TestOpenAIProvider_JSONSchemaFallback(t *testing.T) {
+	var formats []bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ResponseFormat json.RawMessage `json:"response_format"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		formats = append(formats, req.ResponseFormat != nil)
+		w.Header().Set("Content-Type", "application/json")
+		if req.ResponseFormat != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": {"message": "unknown field response_format.json_schema", "type": "invalid_request_error"}}`))
+			return
+		}
+		w.Write([]byte(`{"choices": [{"message": {"content": "1. Plan", "role": "assistant"}}]}`))
+	}))
+	defer server.Close()
+
+	ctx := WithResponseFormat(context.Background(), ResponseFormat{Name: "plan", Schema: json.RawMessage(`{"type": "object"}`)})
+	p, _ := NewOpenAIProvider("test-key", server.URL, "local-model")
+	for i := 0; i < 2; i++ {
+		if resp, err := p.Chat(ctx, []Message{{Role: "user", Content: "plan"}}); err != nil || resp.Content != "1. Plan" {
+			t.Fatalf("Expected a plain request after the rejection, got %v, %v", resp, err)
+		}
+	}
+	if fmt.Sprint(formats) != "[true false false]" {
+		t.Errorf("Expected the format to be dropped after a 400 and not sent again, got %v", formats)
+	}
+
+	formats = nil
+	groq, _ := NewGroqProvider("test-key", server.URL, "llama-3.3-70b")
+	if _, err := groq.Chat(ctx, []Message{{Role: "user", Content: "plan"}}); err != nil || fmt.Sprint(formats) != "[false]" {
+		t.Errorf("Expected no json_schema for a service without it, got %v, %v", formats, err)
+	}
 }
 
 func TestOllamaProvider(t *testing.T) {
@@ -57,6 +128,60 @@ func TestOllamaProvider(t *testing.T) {
 	}
 }
 
+func TestOllamaProvider_MultipleToolCalls(t *testing.T) {
+	var lastRequest api.ChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&lastRequest)
+		w.Header().Set("Content-Type", "application/json")
+		// The client reads newline-delimited JSON, so the body stays on one line
+		w.Write([]byte(`{"message": {"content": "", "tool_calls": [` +
+			`{"function": {"name": "run_shell", "arguments": {"command": "ls"}}},` +
+			`{"function": {"name": "run_shell", "arguments": {"command": "pwd"}}}` +
+			`]}, "done": true, "eval_count": 10, "prompt_eval_count": 5}`))
+	}))
+	defer server.Close()
+
+	os.Setenv("OLLAMA_HOST", server.URL)
+	defer os.Unsetenv("OLLAMA_HOST")
+
+	p, _ := NewOllamaProvider("llama3")
+	history := []Message{{Role: "user", Content: "look around"}}
+	resp, err := p.Chat(context.Background(), history)
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if len(resp.ToolCalls) != 2 {
+		t.Fatalf("Expected 2 tool calls, got %d", len(resp.ToolCalls))
+	}
+	first, second := resp.ToolCalls[0], resp.ToolCalls[1]
+	if first.ID == "" || first.ID == second.ID {
+		t.Errorf("Expected unique tool call IDs, got %q and %q", first.ID, second.ID)
+	}
+	if first.Args != `{"command":"ls"}` || second.Args != `{"command":"pwd"}` {
+		t.Errorf("Unexpected args: %s, %s", first.Args, second.Args)
+	}
+
+	// Results answer the calls in reverse order; each must keep its own ID
+	history = append(history,
+		Message{Role: "assistant", ToolCalls: resp.ToolCalls},
+		Message{Role: "tool", Content: "/work", ToolCallID: second.ID},
+		Message{Role: "tool", Content: "main.go", ToolCallID: first.ID},
+	)
+	if _, err := p.Chat(context.Background(), history); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	msgs := lastRequest.Messages
+	if len(msgs) != 4 || len(msgs[1].ToolCalls) != 2 || msgs[1].ToolCalls[1].ID != second.ID {
+		t.Fatalf("Expected the assistant tool calls to be sent back, got %+v", msgs)
+	}
+	if msgs[2].ToolCallID != second.ID || msgs[2].ToolName != "run_shell" || msgs[3].ToolCallID != first.ID {
+		t.Errorf("Tool results not correlated: %+v", msgs[2:])
+	}
+	if cmd, _ := msgs[1].ToolCalls[1].Function.Arguments.Get("command"); cmd != "pwd" {
+		t.Errorf("Expected arguments to round-trip, got %v", cmd)
+	}
+}
+
 func TestAnthropicProvider(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -267,3 +392,825 @@ func TestProvider_Errors(t *testing.T) {
 	
 
 
+
+func TestEstimateCost(t *testing.T) {
+	u := Usage{PromptTokens: 1_000_000, CompletionTokens: 1_000_000}
+
+	if cost := EstimateCost("openai", "gpt-4o-2024-08-06", u); cost != 12.50 {
+		t.Errorf("Expected 12.50 for gpt-4o, got %f", cost)
+	}
+	if cost := EstimateCost("openai", "gpt-4o-mini", u); cost != 0.75 {
+		t.Errorf("Expected longest prefix match for gpt-4o-mini, got %f", cost)
+	}
+	if cost := EstimateCost("ollama", "gpt-4o", u); cost != 0 {
+		t.Errorf("Expected local provider to be free, got %f", cost)
+	}
+	if cost := EstimateCost("openai", "unknown-model", u); cost != 0 {
+		t.Errorf("Expected unknown model to be zero, got %f", cost)
+	}
+}
+
+func TestOllamaProvider_Usage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"message": {"content": "hi from ollama"}, "done": true, "eval_count": 10, "prompt_eval_count": 5}`))
+	}))
+	defer server.Close()
+
+	os.Setenv("OLLAMA_HOST", server.URL)
+	defer os.Unsetenv("OLLAMA_HOST")
+
+	p, _ := NewOllamaProvider("llama3")
+	resp, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}})
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if resp.Usage.PromptTokens != 5 || resp.Usage.CompletionTokens != 10 || resp.Usage.TotalTokens != 15 {
+		t.Errorf("Unexpected usage: %+v", resp.Usage)
+	}
+
+	// Cached prompts omit prompt_eval_count; usage falls back to an estimate
+	usage := ollamaUsage([]Message{{Role: "user", Content: "please build the project"}}, "ok", 0, 3)
+	if usage.PromptTokens == 0 {
+		t.Error("Expected estimated prompt tokens when the count is missing")
+	}
+	if usage.CompletionTokens != 3 {
+		t.Errorf("Expected reported completion tokens to be kept, got %d", usage.CompletionTokens)
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	if n := EstimateTokens(""); n != 0 {
+		t.Errorf("Expected 0 for empty text, got %d", n)
+	}
+	if n := EstimateTokens("abcdefgh"); n != 2 {
+		t.Errorf("Expected 2 tokens for 8 chars, got %d", n)
+	}
+	if n := EstimateTokens("a b c d e"); n != 5 {
+		t.Errorf("Expected word estimate to win for short words, got %d", n)
+	}
+
+	msgs := []Message{
+		{Role: "user", Content: "abcdefgh"},
+		{Role: "assistant", ToolCalls: []ToolCall{{Name: "run_shell", Args: `{"cmd":"ls"}`}}},
+	}
+	if n := EstimateMessagesTokens(msgs); n <= 2*messageOverheadTokens+2 {
+		t.Errorf("Expected tool call arguments to be counted, got %d", n)
+	}
+	if n := EstimatePromptTokens(msgs); n <= EstimateMessagesTokens(msgs) {
+		t.Errorf("Expected tool definitions to be counted, got %d", n)
+	}
+
+	p, _ := NewOpenAIProvider("key", "", "")
+	if n, err := p.CountTokens(context.Background(), msgs); err != nil || n != EstimatePromptTokens(msgs) {
+		t.Errorf("Expected the OpenAI count to be estimated, got %d, %v", n, err)
+	}
+}
+
+func TestAnthropicProvider_CountTokens(t *testing.T) {
+	var path string
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{"input_tokens": 1234}`))
+	}))
+	defer server.Close()
+
+	p, _ := NewAnthropicProvider("test-key", "claude-3")
+	p.SetBaseURL(server.URL + "/v1/messages")
+	n, err := p.CountTokens(context.Background(), []Message{{Role: "user", Content: "hi"}})
+	if err != nil || n != 1234 {
+		t.Fatalf("Expected 1234 tokens, got %d, %v", n, err)
+	}
+	if path != "/v1/messages/count_tokens" {
+		t.Errorf("Expected the count_tokens endpoint, got %s", path)
+	}
+	if _, ok := body["max_tokens"]; ok || body["tools"] == nil || body["model"] != "claude-3" {
+		t.Errorf("Expected model, messages, and tools without max_tokens, got %v", body)
+	}
+}
+
+func TestMistralProvider(t *testing.T) {
+	var models []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		models = append(models, req.Model)
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/chat/completions":
+			w.Write([]byte(`{
+				"choices": [{"message": {"role": "assistant", "content": "", "tool_calls": [
+					{"id": "call_1", "type": "function", "function": {"name": "run_shell", "arguments": "{\"cmd\":\"ls\"}"}}
+				]}}],
+				"usage": {"prompt_tokens": 12, "completion_tokens": 4, "total_tokens": 16}
+			}`))
+		case "/embeddings":
+			w.Write([]byte(`{"data": [{"object": "embedding", "index": 0, "embedding": [0.1, 0.2]}]}`))
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer server.Close()
+
+	p, err := NewMistralProvider("test-key", server.URL, "")
+	if err != nil {
+		t.Fatalf("NewMistralProvider failed: %v", err)
+	}
+	if p.Name() != "mistral" || p.Model() != "mistral-large-latest" {
+		t.Errorf("Unexpected provider identity: %s/%s", p.Name(), p.Model())
+	}
+
+	resp, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "list files"}})
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "run_shell" || resp.Usage.TotalTokens != 16 {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+
+	vec, err := p.Embed(context.Background(), "hello")
+	if err != nil || len(vec) != 2 {
+		t.Fatalf("Embed failed: %v %v", vec, err)
+	}
+	if len(models) != 2 || models[1] != "mistral-embed" {
+		t.Errorf("Expected mistral-embed for embeddings, got %v", models)
+	}
+}
+
+func TestGroqProvider(t *testing.T) {
+	if _, err := NewGroqProvider("", "", ""); err == nil {
+		t.Error("Expected error without API key")
+	}
+
+	p, _ := NewGroqProvider("test-key", "", "")
+	if p.Name() != "groq" || p.Model() != "llama-3.3-70b-versatile" {
+		t.Errorf("Unexpected provider identity: %s/%s", p.Name(), p.Model())
+	}
+	if _, err := p.Embed(context.Background(), "hello"); err == nil {
+		t.Error("Expected Groq embeddings to be unsupported")
+	}
+	if cost := EstimateCost(p.Name(), p.Model(), Usage{PromptTokens: 1_000_000}); cost != 0.59 {
+		t.Errorf("Expected Groq llama pricing, got %f", cost)
+	}
+}
+
+func TestHuggingFaceProvider(t *testing.T) {
+	var auth, models []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/chat/completions":
+			var req struct {
+				Model string `json:"model"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			models = append(models, req.Model)
+			w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "hello"}}], "usage": {"prompt_tokens": 5, "completion_tokens": 1, "total_tokens": 6}}`))
+		case "/embed":
+			var req struct {
+				Inputs []string `json:"inputs"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			if len(req.Inputs) == 2 {
+				w.Write([]byte(`[[0.1, 0.2], [0.3, 0.4]]`))
+				return
+			}
+			// A model without pooling returns a vector per token
+			w.Write([]byte(`[[[1, 2], [3, 4]]]`))
+		case "/broken":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error": "Model is currently loading"}`))
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer server.Close()
+
+	if _, err := NewHuggingFaceProvider("", "hf_token", "", ""); err == nil {
+		t.Error("Expected error without an endpoint")
+	}
+	p, err := NewHuggingFaceProvider(server.URL+"/", "hf_token", server.URL+"/embed", "")
+	if err != nil {
+		t.Fatalf("NewHuggingFaceProvider failed: %v", err)
+	}
+	if p.Name() != "huggingface" || p.Model() != "tgi" {
+		t.Errorf("Unexpected provider identity: %s/%s", p.Name(), p.Model())
+	}
+	resp, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}})
+	if err != nil || resp.Content != "hello" || resp.Usage.TotalTokens != 6 {
+		t.Fatalf("Chat failed: %+v, %v", resp, err)
+	}
+	if !CapabilitiesOf(p).Embeddings {
+		t.Error("Expected embeddings with an embedding endpoint")
+	}
+
+	vectors, err := p.EmbedBatch(context.Background(), []string{"a", "b"})
+	if err != nil || len(vectors) != 2 || vectors[1][0] != 0.3 {
+		t.Fatalf("EmbedBatch failed: %v, %v", vectors, err)
+	}
+	vec, err := p.Embed(context.Background(), "a")
+	if err != nil || len(vec) != 2 || vec[0] != 2 || vec[1] != 3 {
+		t.Errorf("Expected token vectors mean-pooled, got %v, %v", vec, err)
+	}
+	for _, a := range auth {
+		if a != "Bearer hf_token" {
+			t.Errorf("Expected the token on every request, got %q", a)
+		}
+	}
+	if len(models) != 1 || models[0] != "tgi" {
+		t.Errorf("Expected the TGI model name, got %v", models)
+	}
+
+	broken, _ := NewHuggingFaceProvider(server.URL+"/v1", "", server.URL+"/broken", "meta-llama/Llama-3.1-8B-Instruct")
+	if _, err := broken.Embed(context.Background(), "a"); err == nil || !strings.Contains(err.Error(), "currently loading") {
+		t.Errorf("Expected the endpoint's error, got %v", err)
+	}
+	chatOnly, _ := NewHuggingFaceProvider(server.URL, "", "", "")
+	if _, err := chatOnly.Embed(context.Background(), "a"); err == nil || CapabilitiesOf(chatOnly).Embeddings {
+		t.Error("Expected no embeddings without an embedding endpoint")
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	caps := ModelCapabilities("openai", "gpt-4o-2024-08-06")
+	if caps.ContextWindow != 128_000 || !caps.Tools || caps.Pricing == nil || caps.Pricing.PromptPerMillion != 2.50 {
+		t.Errorf("Unexpected gpt-4o capabilities: %+v", caps)
+	}
+	if caps := ModelCapabilities("ollama", "llama2:13b"); caps.Tools || caps.Pricing == nil || *caps.Pricing != (Pricing{}) {
+		t.Errorf("Expected llama2 without tools and free, got %+v", caps)
+	}
+	if caps := ModelCapabilities("plugin", "custom"); caps.ContextWindow != 0 || !caps.Tools || caps.Pricing != nil {
+		t.Errorf("Expected unknown models to be assumed tool-capable, got %+v", caps)
+	}
+
+	groq, _ := NewGroqProvider("test-key", "", "")
+	wrapped := Chain(groq, WithRetry(RetryPolicy{Attempts: 2}), WithUsageEstimate())
+	if caps := CapabilitiesOf(wrapped); caps.Embeddings || !caps.Tools || caps.ContextWindow != 128_000 {
+		t.Errorf("Expected Groq's capabilities through its middleware, got %+v", caps)
+	}
+	openai, _ := NewOpenAIProvider("test-key", "", "gpt-4o")
+	if !CapabilitiesOf(openai).Embeddings {
+		t.Error("Expected OpenAI embeddings")
+	}
+	if !CapabilitiesOf(wrapped).Params {
+		t.Error("Expected Groq to send sampling parameters")
+	}
+	cli, _ := NewCLIProvider("/bin/echo", nil)
+	if CapabilitiesOf(cli).Params {
+		t.Error("Expected the CLI provider to ignore sampling parameters")
+	}
+}
+
+func TestLocalProviders(t *testing.T) {
+	var auth []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/models":
+			w.Write([]byte(`{"object": "list", "data": [{"id": "qwen2.5-coder-7b", "object": "model"}, {"id": "llama-3.2-3b", "object": "model"}]}`))
+		default:
+			w.Write([]byte(`{"choices": [{"message": {"content": "hello", "role": "assistant"}}]}`))
+		}
+	}))
+	defer server.Close()
+
+	p, err := NewLMStudioProvider(server.URL+"/v1", "")
+	if err != nil {
+		t.Fatalf("NewLMStudioProvider failed: %v", err)
+	}
+	if p.Name() != "lmstudio" || p.Model() != "qwen2.5-coder-7b" {
+		t.Errorf("Expected the first listed model to be discovered, got %s/%s", p.Name(), p.Model())
+	}
+	if resp, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}}); err != nil || resp.Content != "hello" {
+		t.Errorf("Chat failed: %v", err)
+	}
+	if cost := EstimateCost(p.Name(), p.Model(), Usage{PromptTokens: 1_000_000}); cost != 0 {
+		t.Errorf("Expected local models to be free, got %f", cost)
+	}
+
+	p, _ = NewLlamaCppProvider(server.URL+"/v1", "custom")
+	if p.Name() != "llamacpp" || p.Model() != "custom" {
+		t.Errorf("Expected the given model to be kept, got %s/%s", p.Name(), p.Model())
+	}
+	if _, err := p.Embed(context.Background(), "hello"); err == nil {
+		t.Error("Expected local embeddings to be unsupported")
+	}
+
+	models, err := ListLocalModels(context.Background(), "llamacpp", server.URL+"/v1")
+	if err != nil || len(models) != 2 || models[1] != "llama-3.2-3b" {
+		t.Errorf("Expected both models to be listed, got %v (%v)", models, err)
+	}
+	for _, a := range auth {
+		if a != "" && a != "Bearer " {
+			t.Errorf("Expected no API key to be sent, got %q", a)
+		}
+	}
+
+	if _, err := NewLMStudioProvider("http://127.0.0.1:1/v1", ""); err == nil {
+		t.Error("Expected discovery to fail without a server")
+	}
+}
+
+// mapCache is an in-memory ResponseCache.
+type mapCache map[string][]byte
+
+func (m mapCache) GetCachedResponse(key string) ([]byte, bool, error) {
+	data, ok := m[key]
+	return data, ok, nil
+}
+
+func (m mapCache) PutCachedResponse(key string, response []byte) error {
+	m[key] = response
+	return nil
+}
+
+func TestCachingProvider(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"choices": [{"message": {"role": "assistant", "content": "", "tool_calls": [
+				{"id": "call_1", "type": "function", "function": {"name": "run_shell", "arguments": "{\"cmd\":\"ls\"}"}}
+			]}}],
+			"usage": {"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15}
+		}`))
+	}))
+	defer server.Close()
+
+	base, _ := NewOpenAIProvider("test-key", server.URL, "gpt-4o")
+	p := NewCachingProvider(base, mapCache{})
+	prompt := []Message{{Role: "user", Content: "list files"}}
+
+	first, err := p.Chat(context.Background(), prompt)
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	second, err := p.Chat(context.Background(), prompt)
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("Expected identical prompt to be served from cache, got %d requests", requests)
+	}
+	if len(second.ToolCalls) != 1 || second.ToolCalls[0].Args != first.ToolCalls[0].Args {
+		t.Errorf("Cached response differs: %+v", second)
+	}
+	if second.Usage.TotalTokens != 0 {
+		t.Errorf("Expected cached response to report no usage, got %+v", second.Usage)
+	}
+
+	p.Chat(context.Background(), []Message{{Role: "user", Content: "read the README"}})
+	if requests != 2 {
+		t.Errorf("Expected a different prompt to miss the cache, got %d requests", requests)
+	}
+	if stats := p.Stats(); stats.Hits != 1 || stats.Misses != 2 {
+		t.Errorf("Unexpected cache stats: %+v", stats)
+	}
+	if p.Name() != "openai" || p.Model() != "gpt-4o" {
+		t.Errorf("Expected wrapped provider identity, got %s/%s", p.Name(), p.Model())
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	msgs := []Message{{Role: "user", Content: "hi"}}
+	a, _ := CacheKey("openai", "gpt-4o", msgs, Tools)
+	b, _ := CacheKey("openai", "gpt-4o-mini", msgs, Tools)
+	c, _ := CacheKey("openai", "gpt-4o", msgs, Tools[:1])
+	if a == b || a == c {
+		t.Error("Expected model and tools to change the cache key")
+	}
+	if again, _ := CacheKey("openai", "gpt-4o", msgs, Tools); again != a {
+		t.Error("Expected cache key to be stable")
+	}
+	d, _ := cacheKey("openai", "gpt-4o", msgs, Tools, &ResponseFormat{Name: "plan", Schema: json.RawMessage(`{}`)}, Params{})
+	if unformatted, _ := cacheKey("openai", "gpt-4o", msgs, Tools, nil, Params{}); d == a || unformatted != a {
+		t.Error("Expected only a response format to change the cache key")
+	}
+	if e, _ := cacheKey("openai", "gpt-4o", msgs, Tools, nil, Params{MaxTokens: 100}); e == a {
+		t.Error("Expected sampling parameters to change the cache key")
+	}
+}
+
+func TestEmbedBatch(t *testing.T) {
+	t.Run("OpenAI", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var req struct {
+				Input []string `json:"input"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			if len(req.Input) != 2 {
+				t.Errorf("Expected one request with 2 inputs, got %v", req.Input)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			// Results may arrive out of order; Index places them
+			w.Write([]byte(`{"data": [{"index": 1, "embedding": [0, 1]}, {"index": 0, "embedding": [1, 0]}]}`))
+		}))
+		defer server.Close()
+
+		p, _ := NewOpenAIProvider("test-key", server.URL, "gpt-4")
+		vectors, err := p.EmbedBatch(context.Background(), []string{"a", "b"})
+		if err != nil {
+			t.Fatalf("EmbedBatch failed: %v", err)
+		}
+		if len(vectors) != 2 || vectors[0][0] != 1 || vectors[1][1] != 1 {
+			t.Errorf("Expected vectors in input order, got %v", vectors)
+		}
+	})
+
+	t.Run("Ollama", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/api/embed" {
+				t.Errorf("Expected /api/embed, got %s", r.URL.Path)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"embeddings": [[1, 0], [0, 1]]}`))
+		}))
+		defer server.Close()
+		os.Setenv("OLLAMA_HOST", server.URL)
+		defer os.Unsetenv("OLLAMA_HOST")
+
+		p, _ := NewOllamaProvider("nomic-embed-text")
+		vectors, err := p.EmbedBatch(context.Background(), []string{"a", "b"})
+		if err != nil {
+			t.Fatalf("EmbedBatch failed: %v", err)
+		}
+		if len(vectors) != 2 || vectors[1][1] != 1 {
+			t.Errorf("Unexpected vectors %v", vectors)
+		}
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		p, _ := NewAnthropicProvider("key", "")
+		if _, err := p.EmbedBatch(context.Background(), []string{"a"}); err == nil {
+			t.Error("Expected an error from a provider without embeddings")
+		}
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error": {"type": "rate_limit_error", "message": "slow down"}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"content": [{"type": "text", "text": "done"}], "usage": {"input_tokens": 0, "output_tokens": 0}}`))
+	}))
+	defer server.Close()
+
+	base, _ := NewAnthropicProvider("key", "claude-3-5-sonnet-20240620")
+	base.SetBaseURL(server.URL)
+	tracker := &UsageTracker{}
+	p := Chain(base,
+		WithUsageTracking(tracker),
+		WithCache(mapCache{}),
+		WithRetry(RetryPolicy{Attempts: 3, Backoff: time.Millisecond}),
+		WithUsageEstimate(),
+	)
+
+	resp, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "hello there"}})
+	if err != nil {
+		t.Fatalf("Expected the rate limited request to be retried, got %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("Expected 2 requests, got %d", requests)
+	}
+	if resp.Usage.PromptTokens == 0 || resp.Usage.TotalTokens != resp.Usage.PromptTokens+resp.Usage.CompletionTokens {
+		t.Errorf("Expected estimated usage, got %+v", resp.Usage)
+	}
+	if calls, usage, _ := tracker.Totals(); calls != 1 || usage.TotalTokens != resp.Usage.TotalTokens {
+		t.Errorf("Unexpected tracked usage: %d calls, %+v", calls, usage)
+	}
+	if p.Name() != "anthropic" {
+		t.Errorf("Expected wrapped provider identity, got %s", p.Name())
+	}
+	if _, ok := Find[*CachingProvider](p); !ok {
+		t.Error("Expected to find the caching provider in the chain")
+	}
+	if _, ok := Find[*OpenAIProvider](p); ok {
+		t.Error("Expected no OpenAI provider in the chain")
+	}
+
+	if IsRetryable(&StatusError{Provider: "anthropic", StatusCode: http.StatusUnauthorized}) {
+		t.Error("Expected 401 not to be retryable")
+	}
+	if IsRetryable(context.Canceled) {
+		t.Error("Expected cancellation not to be retryable")
+	}
+}
+
+type fixedLimiter time.Duration
+
+func (l fixedLimiter) Reserve() time.Duration { return time.Duration(l) }
+func (l fixedLimiter) Cancel()                {}
+
+// queueLimiter makes each request wait a second per slot taken before it.
+type queueLimiter struct{ taken int }
+
+func (l *queueLimiter) Reserve() time.Duration {
+	l.taken++
+	return time.Duration(l.taken) * time.Second
+}
+func (l *queueLimiter) Cancel() { l.taken-- }
+
+func TestWithThrottledRateLimit(t *testing.T) {
+	var throttled time.Duration
+	p := WithThrottledRateLimit(fixedLimiter(time.Millisecond), func(wait time.Duration) error {
+		throttled = wait
+		return nil
+	})(NewStubProvider())
+	if _, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}}); err != nil {
+		t.Fatalf("Expected the throttled request to wait and succeed, got %v", err)
+	}
+	if throttled != time.Millisecond {
+		t.Errorf("Expected onThrottle called with the wait, got %s", throttled)
+	}
+
+	stop := errors.New("blocked")
+	p = WithThrottledRateLimit(fixedLimiter(time.Hour), func(time.Duration) error { return stop })(NewStubProvider())
+	if _, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}}); !errors.Is(err, stop) {
+		t.Errorf("Expected onThrottle's error instead of waiting, got %v", err)
+	}
+
+	// Refused or cancelled requests give their slot back
+	l := &queueLimiter{}
+	p = WithThrottledRateLimit(l, func(time.Duration) error { return stop })(NewStubProvider())
+	p.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := WithRateLimit(l)(NewStubProvider()).Chat(ctx, []Message{{Role: "user", Content: "hi"}}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the cancelled wait to fail, got %v", err)
+	}
+	if wait := l.Reserve(); wait != time.Second {
+		t.Errorf("Expected no wait added by dropped requests, got %s", wait)
+	}
+}
+
+func TestFixture(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"choices": [{"message": {"content": "found key sk-abcdefghijklmnopqrstuvwx", "role": "assistant"}}],
+			"usage": {"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15}
+		}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	base, _ := NewOpenAIProvider("configured-secret-key", server.URL, "gpt-4")
+	rec := NewRecordingProvider(base, dir, NewSanitizer("configured-secret-key"))
+	resp, err := rec.Chat(context.Background(), []Message{{Role: "user", Content: "use configured-secret-key"}})
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if !strings.Contains(resp.Content, "sk-abcdefghijklmnopqrstuvwx") {
+		t.Errorf("Expected the caller to get the unsanitized response, got %q", resp.Content)
+	}
+
+	f, err := LoadFixture(rec.Path())
+	if err != nil {
+		t.Fatalf("LoadFixture failed: %v", err)
+	}
+	if f.Provider != "openai" || f.Model != "gpt-4" || len(f.Exchanges) != 1 {
+		t.Fatalf("Unexpected fixture: %+v", f)
+	}
+	e := f.Exchanges[0]
+	if e.Messages[0].Content != "use [REDACTED]" || e.Response.Content != "found key [REDACTED]" {
+		t.Errorf("Expected secrets to be redacted, got %q and %q", e.Messages[0].Content, e.Response.Content)
+	}
+
+	f.Exchanges = append(f.Exchanges,
+		Exchange{Kind: ExchangeChat, Error: "api error"},
+		Exchange{Kind: ExchangeEmbed, Text: "hello", Vector: []float32{1, 2}},
+	)
+	p := NewFixtureProvider(f)
+	if p.Name() != "fixture" || p.Model() != "gpt-4" {
+		t.Errorf("Unexpected identity %s/%s", p.Name(), p.Model())
+	}
+	if resp, err := p.Chat(context.Background(), nil); err != nil || resp.Usage.TotalTokens != 15 {
+		t.Errorf("Expected the recorded response, got %+v, %v", resp, err)
+	}
+	if _, err := p.Chat(context.Background(), nil); err == nil || err.Error() != "api error" {
+		t.Errorf("Expected the recorded error, got %v", err)
+	}
+	if _, err := p.Chat(context.Background(), nil); !errors.Is(err, ErrFixtureExhausted) {
+		t.Errorf("Expected an exhausted fixture, got %v", err)
+	}
+	if vec, err := p.Embed(context.Background(), "hello"); err != nil || len(vec) != 2 {
+		t.Errorf("Expected the recorded embedding, got %v, %v", vec, err)
+	}
+	if _, err := p.Embed(context.Background(), "other"); err == nil {
+		t.Error("Expected an error for text without a recorded embedding")
+	}
+}
+
+func TestNormalizeHistory(t *testing.T) {
+	history := []Message{
+		{Role: "user", Content: "fix the build"},
+		{Role: "assistant", ToolCalls: []ToolCall{
+			{ID: "toolu_01A.b", Name: "run_shell", Args: `{"cmd":"go build"}`},
+			{ID: "toolu_01A.b", Name: "write_file", Args: `{"path":"a.go"}`},
+		}},
+		{Role: "tool", ToolCallID: "toolu_01A.b", Content: "ok"},
+		{Role: "tool", ToolCallID: "toolu_01A.b", Content: "written"},
+		{Role: "tool", ToolCallID: "summarized", Content: "old output"},
+	}
+
+	openai := NormalizeHistory(history, "openai")
+	if len(openai) != len(history) {
+		t.Fatalf("Expected %d messages, got %d", len(history), len(openai))
+	}
+	calls := openai[1].ToolCalls
+	if calls[0].ID != "toolu_01Ab" || calls[1].ID != "call_2" {
+		t.Errorf("Expected sanitized, unique IDs, got %s and %s", calls[0].ID, calls[1].ID)
+	}
+	if openai[2].ToolCallID != calls[0].ID || openai[3].ToolCallID != calls[1].ID {
+		t.Errorf("Expected results paired with their calls, got %s and %s", openai[2].ToolCallID, openai[3].ToolCallID)
+	}
+	if m := openai[4]; m.Role != "user" || m.ToolCallID != "" || m.Content != "[Earlier tool result] old output" {
+		t.Errorf("Expected the orphan result as a user message, got %+v", m)
+	}
+	if history[1].ToolCalls[0].ID != "toolu_01A.b" {
+		t.Error("Expected the input history to be left unchanged")
+	}
+
+	if gemini := NormalizeHistory(history, "gemini"); gemini[3].ToolCallID != "write_file" {
+		t.Errorf("Expected Gemini results matched by function name, got %s", gemini[3].ToolCallID)
+	}
+	if mistral := NormalizeHistory(history, "mistral"); mistral[2].ToolCallID != "call00001" {
+		t.Errorf("Expected nine-character Mistral IDs, got %s", mistral[2].ToolCallID)
+	}
+}
+
+func TestAnthropicProvider_Thinking(t *testing.T) {
+	var requests []anthropicRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req anthropicRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		requests = append(requests, req)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"id": "msg_1",
+			"content": [
+				{"type": "thinking", "thinking": "Listing files shows the layout.", "signature": "sig"},
+				{"type": "text", "text": "Let me look."},
+				{"type": "tool_use", "id": "tc_1", "name": "run_shell", "input": {"cmd": "ls"}}
+			],
+			"usage": {"input_tokens": 5, "output_tokens": 40}
+		}`))
+	}))
+	defer server.Close()
+
+	p, _ := NewAnthropicProvider("test-key", "claude-sonnet-4")
+	p.SetBaseURL(server.URL)
+	if err := p.SetThinkingBudget(500); err == nil {
+		t.Error("Expected budgets below the minimum to be refused")
+	}
+	if err := p.SetThinkingBudget(2048); err != nil {
+		t.Fatalf("SetThinkingBudget failed: %v", err)
+	}
+
+	history := []Message{{Role: "user", Content: "look around"}}
+	resp, err := p.Chat(context.Background(), history)
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if resp.Content != "Let me look." || resp.Reasoning != "Listing files shows the layout." {
+		t.Errorf("Expected thinking apart from the content, got %q and %q", resp.Content, resp.Reasoning)
+	}
+	if u := resp.Usage; u.ThinkingTokens <= 0 || u.ThinkingTokens > u.CompletionTokens {
+		t.Errorf("Expected thinking tokens within the completion tokens, got %+v", u)
+	}
+	if req := requests[0]; req.Thinking == nil || req.Thinking.BudgetTokens != 2048 || req.MaxTokens != 4096+2048 {
+		t.Errorf("Expected thinking enabled with its budget, got %+v", req)
+	}
+
+	// The thinking goes back with the tool results, ahead of the tool call
+	history = append(history,
+		Message{Role: "assistant", Content: resp.Content, ToolCalls: resp.ToolCalls},
+		Message{Role: "tool", ToolCallID: "tc_1", Content: "main.go"})
+	if _, err := p.Chat(context.Background(), history); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	assistant := requests[1].Messages[1].Content
+	if requests[1].Thinking == nil || len(assistant) != 3 || assistant[0].Type != "thinking" || assistant[0].Signature != "sig" {
+		t.Errorf("Expected the thinking block replayed first, got %+v", assistant)
+	}
+
+	// Tool calls of another process carry no thinking, so it is left off
+	history[1].ToolCalls = []ToolCall{{ID: "tc_other", Name: "run_shell", Args: `{"cmd":"ls"}`}}
+	history[2].ToolCallID = "tc_other"
+	if _, err := p.Chat(context.Background(), history); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if requests[2].Thinking != nil {
+		t.Errorf("Expected thinking disabled without the previous turn's thinking, got %+v", requests[2].Thinking)
+	}
+}
+
+func TestParams(t *testing.T) {
+	zero, warm := 0.0, 0.9
+	phases := PhaseParams{
+		Default:  Params{Temperature: &zero, MaxTokens: 2048},
+		Planning: Params{Temperature: &warm},
+		Summary:  Params{MaxTokens: 256, Stop: []string{"\n\n"}},
+	}
+	if p := phases.For(PhasePlanning); *p.Temperature != warm || p.MaxTokens != 2048 {
+		t.Errorf("Expected planning to override only the temperature, got %+v", p)
+	}
+	if p := phases.For(PhaseSummary); *p.Temperature != zero || p.MaxTokens != 256 || len(p.Stop) != 1 {
+		t.Errorf("Expected summary overrides, got %+v", p)
+	}
+	if p := ParamsFromContext(WithParams(context.Background(), phases)); *p.Temperature != zero || p.MaxTokens != 2048 {
+		t.Errorf("Expected requests without a phase to use execution's parameters, got %+v", p)
+	}
+	if p := ParamsFromContext(context.Background()); !p.IsZero() {
+		t.Errorf("Expected no parameters without WithParams, got %+v", p)
+	}
+	if merged := phases.Merge(PhaseParams{Default: Params{MaxTokens: 512}}); merged.For(PhaseExecution).MaxTokens != 512 || *merged.Default.Temperature != zero {
+		t.Errorf("Expected the override's parameters on top, got %+v", merged)
+	}
+
+	hot := 2.5
+	for _, invalid := range []PhaseParams{
+		{Default: Params{Temperature: &hot}},
+		{Planning: Params{TopP: &zero}},
+		{Summary: Params{MaxTokens: -1}},
+		{Execution: Params{Stop: []string{""}}},
+	} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", invalid)
+		}
+	}
+	if err := phases.Validate(); err != nil {
+		t.Errorf("Expected valid parameters, got %v", err)
+	}
+
+	ctx := WithPhase(WithParams(context.Background(), phases), PhaseSummary)
+	t.Run("OpenAI", func(t *testing.T) {
+		var req map[string]interface{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&req)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "done"}}]}`))
+		}))
+		defer server.Close()
+
+		p, _ := NewOpenAIProvider("test-key", server.URL, "gpt-4o")
+		if _, err := p.Chat(ctx, []Message{{Role: "user", Content: "hi"}}); err != nil {
+			t.Fatalf("Chat failed: %v", err)
+		}
+		if _, ok := req["temperature"]; !ok {
+			t.Error("Expected a zero temperature to be sent")
+		}
+		if req["max_completion_tokens"] != 256.0 || req["stop"] == nil {
+			t.Errorf("Expected the summary parameters, got %v", req)
+		}
+	})
+	t.Run("Anthropic", func(t *testing.T) {
+		var req anthropicRequest
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&req)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"content": [{"type": "text", "text": "done"}], "usage": {"input_tokens": 1, "output_tokens": 1}}`))
+		}))
+		defer server.Close()
+
+		p, _ := NewAnthropicProvider("test-key", "claude-sonnet-4")
+		p.SetBaseURL(server.URL)
+		if _, err := p.Chat(ctx, []Message{{Role: "user", Content: "hi"}}); err != nil {
+			t.Fatalf("Chat failed: %v", err)
+		}
+		if req.MaxTokens != 256 || req.Temperature == nil || *req.Temperature != 0 || len(req.StopSequences) != 1 {
+			t.Errorf("Expected the summary parameters, got %+v", req)
+		}
+
+		hot := 1.5
+		if _, err := p.Chat(WithParams(context.Background(), PhaseParams{Default: Params{Temperature: &hot}}), []Message{{Role: "user", Content: "hi"}}); err != nil {
+			t.Fatalf("Chat failed: %v", err)
+		}
+		if req.Temperature == nil || *req.Temperature != 1 {
+			t.Errorf("Expected the temperature to be capped at 1, got %v", req.Temperature)
+		}
+	})
+	t.Run("Ollama", func(t *testing.T) {
+		options := ollamaOptions(phases.For(PhasePlanning))
+		if options["temperature"] != warm || options["num_predict"] != 2048 {
+			t.Errorf("Expected the planning parameters as options, got %v", options)
+		}
+		if ollamaOptions(Params{}) != nil {
+			t.Error("Expected no options without parameters")
+		}
+	})
+}
@@ -70,10 +70,27 @@ func (m *StubProvider) Chat(ctx context.Context, messages []Message) (*Response,
 	return &resp, nil
 }
 
+func (m *StubProvider) CountTokens(ctx context.Context, messages []Message) (int, error) {
+	return EstimateMessagesTokens(messages), nil
+}
+
 func (m *StubProvider) Embed(ctx context.Context, text string) ([]float32, error) {
 	return []float32{0.1, 0.2, 0.3}, nil
 }
 
+func (m *StubProvider) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	return EmbedEach(ctx, m, texts)
+}
+
 func (m *StubProvider) Name() string {
 	return "stub"
 }
+
+func (m *StubProvider) Model() string {
+	return "stub"
+}
+
+// Capabilities reports every feature, for tests.
+func (m *StubProvider) Capabilities() Capabilities {
+	return Capabilities{Tools: true, Embeddings: true, Params: true, Pricing: &Pricing{}}
+}
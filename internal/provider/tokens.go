@@ -0,0 +1,69 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+)
+
+// charsPerToken approximates BPE tokenizers used by current models,
+// which average roughly four characters of English text or code per token.
+const charsPerToken = 4
+
+// messageOverheadTokens accounts for role markers and separators
+// that chat templates add around every message.
+const messageOverheadTokens = 4
+
+// EstimateTokens approximates the token count of a text when no
+// provider-side tokenizer is available. It takes the larger of a
+// character-based and a word-based estimate so that dense code and
+// short prose are both reasonably covered.
+func EstimateTokens(text string) int {
+	if text == "" {
+		return 0
+	}
+	byChars := (utf8.RuneCountInString(text) + charsPerToken - 1) / charsPerToken
+	byWords := len(strings.Fields(text))
+	if byWords > byChars {
+		return byWords
+	}
+	return byChars
+}
+
+// EstimateMessagesTokens approximates the prompt size of a conversation,
+// including tool call arguments and per-message template overhead.
+func EstimateMessagesTokens(messages []Message) int {
+	total := 0
+	for _, m := range messages {
+		total += messageOverheadTokens
+		total += EstimateTokens(m.Content)
+		for _, tc := range m.ToolCalls {
+			total += EstimateTokens(tc.Name) + EstimateTokens(tc.Args)
+		}
+	}
+	return total
+}
+
+// EstimatePromptTokens approximates a request's prompt size: the messages
+// plus the tool definitions sent with them.
+func EstimatePromptTokens(messages []Message) int {
+	total := EstimateMessagesTokens(messages)
+	for _, t := range Tools {
+		schema, _ := json.Marshal(t.JSONSchema())
+		total += EstimateTokens(t.Name) + EstimateTokens(t.Description) + EstimateTokens(string(schema))
+	}
+	return total
+}
+
+// estimateCount implements CountTokens for providers without a token
+// counting API.
+func estimateCount(ctx context.Context, messages []Message, withTools bool) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	if withTools {
+		return EstimatePromptTokens(messages), nil
+	}
+	return EstimateMessagesTokens(messages), nil
+}
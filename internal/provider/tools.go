@@ -0,0 +1,153 @@
+package provider
+
+import "context"
+
+// ToolParam is a string parameter of a tool.
+type ToolParam struct {
+	Name        string
+	Description string
+	Required    bool
+}
+
+// ToolSpec describes a tool advertised to the model. Calls are executed by the MCP proxy.
+type ToolSpec struct {
+	Name        string
+	Description string
+	Params      []ToolParam
+	// Schema, when set, is the parameters' JSON schema, sent instead of one
+	// built from Params by APIs that take a schema. Params should still
+	// list the parameters for the APIs that don't.
+	Schema map[string]interface{}
+}
+
+type toolsKey struct{}
+
+// WithTools attaches the tools to offer the model to a chat request
+// context, in place of Tools.
+func WithTools(ctx context.Context, tools []ToolSpec) context.Context {
+	return context.WithValue(ctx, toolsKey{}, tools)
+}
+
+// ToolsFromContext returns the tools to offer the model in a chat call:
+// those attached with WithTools, or Tools.
+func ToolsFromContext(ctx context.Context) []ToolSpec {
+	if tools, ok := ctx.Value(toolsKey{}).([]ToolSpec); ok {
+		return tools
+	}
+	return Tools
+}
+
+// Tools lists the tools every provider offers the model.
+var Tools = []ToolSpec{
+	{
+		Name:        "run_shell",
+		Description: "Execute a shell command",
+		Params: []ToolParam{
+			{Name: "cmd", Description: "The command to run", Required: true},
+			{Name: "dir", Description: "The directory to run the command in"},
+		},
+	},
+	{
+		Name:        "write_file",
+		Description: "Create or overwrite a file with the given content",
+		Params: []ToolParam{
+			{Name: "path", Description: "The file path, relative to the working directory", Required: true},
+			{Name: "content", Description: "The complete new file content", Required: true},
+		},
+	},
+	{
+		Name:        "diff_artifacts",
+		Description: "Show a unified diff between two stored tool outputs, e.g. test runs from different iterations",
+		Params: []ToolParam{
+			{Name: "from", Description: "The earlier output, as the path reported in a tool summary", Required: true},
+			{Name: "to", Description: "The later output, as the path reported in a tool summary", Required: true},
+		},
+	},
+	{
+		Name:        "read_artifact",
+		Description: "Read part of a stored tool output when its summary leaves out detail you need; reads count against a per-session budget",
+		Params: []ToolParam{
+			{Name: "artifact", Description: "The output, as the path reported in a tool summary", Required: true},
+			{Name: "start_line", Description: "First line to read, counting from 1"},
+			{Name: "end_line", Description: "Last line to read"},
+			{Name: "offset", Description: "First byte to read, counting from 0; used instead of lines"},
+			{Name: "length", Description: "Number of bytes to read from offset"},
+		},
+	},
+	{
+		Name:        "verify_evidence",
+		Description: "Check which of the task's evidence files exist and which verify commands pass, before claiming completion",
+	},
+	{
+		Name:        "git_status",
+		Description: "Show the current branch and the staged, unstaged, and untracked files as JSON",
+	},
+	{
+		Name:        "git_diff",
+		Description: "Show uncommitted changes as JSON: per-file line counts and the patch",
+		Params: []ToolParam{
+			{Name: "staged", Description: "\"true\" to diff the staged changes instead of the working tree"},
+			{Name: "path", Description: "Limit the diff to this file or directory"},
+		},
+	},
+	{
+		Name:        "git_commit",
+		Description: "Stage and commit changes on the current branch; the policy may forbid commits or protect branches",
+		Params: []ToolParam{
+			{Name: "message", Description: "The commit message", Required: true},
+			{Name: "paths", Description: "Comma-separated paths to commit; all changes when empty"},
+		},
+	},
+	{
+		Name:        "git_branch",
+		Description: "List local branches, or switch to a branch, creating it when asked",
+		Params: []ToolParam{
+			{Name: "name", Description: "The branch to switch to; lists branches when empty"},
+			{Name: "create", Description: "\"true\" to create the branch"},
+		},
+	},
+	{
+		Name:        "spawn_subtask",
+		Description: "Delegate a narrower task to a child session with its own budget and get back its summary",
+		Params: []ToolParam{
+			{Name: "goal", Description: "What the sub-task must achieve", Required: true},
+			{Name: "definition_of_done", Description: "How to tell the sub-task is finished"},
+			{Name: "evidence", Description: "Comma-separated files that must exist when the sub-task is done; evidence or verify is required"},
+			{Name: "constraints", Description: "Rules for the sub-task, one per line"},
+			{Name: "verify", Description: "Commands that must pass, one per line"},
+			{Name: "max_iterations", Description: "Iteration budget for the sub-task"},
+		},
+	},
+}
+
+// Required returns the names of the required parameters. It is never nil,
+// since APIs reject a null "required" list.
+func (t ToolSpec) Required() []string {
+	names := []string{}
+	for _, p := range t.Params {
+		if p.Required {
+			names = append(names, p.Name)
+		}
+	}
+	return names
+}
+
+// JSONSchema returns the tool parameters as a JSON schema object: Schema
+// when set, or one of string parameters built from Params.
+func (t ToolSpec) JSONSchema() map[string]interface{} {
+	if t.Schema != nil {
+		return t.Schema
+	}
+	props := make(map[string]interface{}, len(t.Params))
+	for _, p := range t.Params {
+		props[p.Name] = map[string]interface{}{
+			"type":        "string",
+			"description": p.Description,
+		}
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": props,
+		"required":   t.Required(),
+	}
+}
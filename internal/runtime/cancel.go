@@ -0,0 +1,111 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/provider"
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+// ErrSessionCancelled is returned by ExecuteSession when the session was cancelled.
+var ErrSessionCancelled = errors.New("session cancelled")
+
+var (
+	// cancelPollInterval controls how often the store is checked for cancellation requests.
+	cancelPollInterval = time.Second
+	// cancelGrace is how long an in-flight tool call may keep running once
+	// cancellation is requested before its context is cancelled.
+	cancelGrace = 10 * time.Second
+	// cancelSummaryTimeout bounds the partial summary generated on cancellation.
+	cancelSummaryTimeout = 30 * time.Second
+)
+
+// watchCancellation polls the store for a cancellation request. Once seen it sets
+// the returned flag and, after cancelGrace, cancels the returned context so a
+// stuck tool call is interrupted. The stop function ends the watcher.
+func (r *Runtime) watchCancellation(ctx context.Context, sessionID string) (context.Context, *atomic.Bool, func()) {
+	execCtx, cancel := context.WithCancel(ctx)
+	requested := &atomic.Bool{}
+	done := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(cancelPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if ok, err := r.store.CancelRequested(sessionID); err != nil || !ok {
+					continue
+				}
+				requested.Store(true)
+				r.observe.Log().Info().Str("session", sessionID).Msg("cancellation requested")
+				r.ui.Log("🛑 Cancellation requested, finishing current step...")
+				select {
+				case <-done:
+				case <-time.After(cancelGrace):
+					cancel()
+				}
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			close(done)
+			cancel()
+		})
+	}
+	return execCtx, requested, stop
+}
+
+// saveSummary stores a session's summary as its "summary" artifact.
+func (r *Runtime) saveSummary(sessionID, summary string) {
+	artifact := &store.Artifact{
+		ID:        fmt.Sprintf("art-%s-summary", sessionID),
+		SessionID: sessionID,
+		Path:      fmt.Sprintf("artifacts/%s/summary.txt", sessionID),
+		Type:      "summary",
+		CreatedAt: time.Now(),
+	}
+	if err := r.store.SaveArtifact(artifact, []byte(summary)); err != nil {
+		r.observe.Log().Warn().Err(err).Msg("failed to save session summary")
+	}
+}
+
+// finishCancelled persists the session as cancelled and archives a partial
+// summary of the work done so far, both as an artifact and as memory.
+func (r *Runtime) finishCancelled(ctx context.Context, session *store.Session, goal string, history []provider.Message) error {
+	// The execution context may already be cancelled; the summary gets its own deadline.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cancelSummaryTimeout)
+	defer cancel()
+
+	r.ui.Log("📦 Archiving partial progress...")
+	session.Status = "cancelled"
+	r.ui.UpdateStatus("Cancelled")
+
+	if summary, err := r.summarizeHistory(ctx, session, history); err != nil {
+		r.observe.Log().Warn().Err(err).Msg("failed to summarize cancelled session")
+	} else {
+		summary = "Partial progress (session cancelled): " + summary
+		r.saveSummary(session.ID, summary)
+		meta := map[string]string{"session_id": session.ID, "goal": goal, "status": "cancelled", store.MemoryNamespaceKey: session.Metadata[MetadataMemoryNamespace], store.MemoryKindKey: store.MemoryKindSummary}
+		if err := r.store.AddMemory(summary, r.memoryVector(ctx, goal), meta); err != nil {
+			r.observe.Log().Warn().Err(err).Msg("failed to archive memory")
+		}
+	}
+
+	if err := r.store.UpdateSession(session); err != nil {
+		return err
+	}
+	r.ui.Log("🛑 Session cancelled")
+	return ErrSessionCancelled
+}
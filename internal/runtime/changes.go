@@ -0,0 +1,111 @@
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/mcp"
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+// Artifact types of the file change manifest written at the end of a session.
+const (
+	ArtifactFileManifest  = "file_manifest"
+	ArtifactWorkspaceDiff = "workspace_diff"
+)
+
+// snapshotWorkspace records the working directory before the agent runs.
+// It returns nil when the scan fails; only write tools are tracked then.
+func (r *Runtime) snapshotWorkspace() *mcp.Snapshot {
+	wd, err := os.Getwd()
+	if err != nil {
+		r.observe.Log().Warn().Err(err).Msg("failed to snapshot workspace")
+		return nil
+	}
+	snap, err := mcp.SnapshotWorkspace(wd)
+	if err != nil {
+		r.observe.Log().Warn().Err(err).Msg("failed to snapshot workspace")
+		return nil
+	}
+	if snap.Truncated() {
+		r.observe.Log().Warn().Msg("workspace too large to snapshot fully, new files may be missed")
+	}
+	return snap
+}
+
+// recordChanges lists the files created, modified, or deleted during the
+// session and stores the manifest and the combined diff as artifacts.
+func (r *Runtime) recordChanges(sessionID string, snap *mcp.Snapshot) []mcp.FileChange {
+	changes := r.workspaceChanges(sessionID, snap)
+	manifest := make([]mcp.FileChange, len(changes))
+	var diff strings.Builder
+	for i, c := range changes {
+		manifest[i] = c
+		manifest[i].Diff = ""
+		diff.WriteString(c.Diff)
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return changes
+	}
+	r.saveChangeArtifact(sessionID, "changes", "changes.json", ArtifactFileManifest, data)
+	if diff.Len() > 0 {
+		r.saveChangeArtifact(sessionID, "changes-diff", "changes.diff", ArtifactWorkspaceDiff, []byte(diff.String()))
+	}
+
+	if len(changes) > 0 {
+		r.ui.Log(fmt.Sprintf("📝 %d file(s) changed:", len(changes)))
+		for _, c := range changes {
+			r.ui.Log(fmt.Sprintf("   • %s (%s)", c.Path, c.Action))
+		}
+	}
+	return changes
+}
+
+func (r *Runtime) saveChangeArtifact(sessionID, suffix, name, kind string, content []byte) {
+	artifact := &store.Artifact{
+		ID:        fmt.Sprintf("art-%s-%s", sessionID, suffix),
+		SessionID: sessionID,
+		Path:      fmt.Sprintf("artifacts/%s/%s", sessionID, name),
+		Type:      kind,
+		CreatedAt: time.Now(),
+	}
+	if err := r.store.SaveArtifact(artifact, content); err != nil {
+		r.observe.Log().Warn().Err(err).Str("artifact", artifact.ID).Msg("failed to save change manifest")
+	}
+}
+
+// workspaceChanges lists the files created, modified, or deleted since snap
+// was taken, falling back to the files written by tools when there is no
+// snapshot to compare with.
+func (r *Runtime) workspaceChanges(sessionID string, snap *mcp.Snapshot) []mcp.FileChange {
+	written := r.mcpProxy.WrittenFiles(sessionID)
+	var changes []mcp.FileChange
+	if snap != nil {
+		var err error
+		if changes, err = snap.Changes(written); err != nil {
+			r.observe.Log().Warn().Err(err).Msg("failed to compare workspace")
+		}
+	}
+	if changes == nil {
+		for path := range written {
+			changes = append(changes, mcp.FileChange{Path: path, Action: "modified", Source: "write_file"})
+		}
+	}
+	return changes
+}
+
+// describeChanges renders changes as one line for summaries.
+func describeChanges(changes []mcp.FileChange) string {
+	if len(changes) == 0 {
+		return "No files changed."
+	}
+	parts := make([]string, len(changes))
+	for i, c := range changes {
+		parts[i] = fmt.Sprintf("%s (%s)", c.Path, c.Action)
+	}
+	return "Files changed: " + strings.Join(parts, ", ")
+}
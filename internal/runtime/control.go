@@ -0,0 +1,154 @@
+package runtime
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/provider"
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+// control holds requests from an interactive UI or `simon steer`: a pause
+// between iterations, steering messages for the agent, and a switch of
+// provider.
+type control struct {
+	paused   bool
+	resume   chan struct{}
+	steering []string
+	switchTo *providerChoice
+}
+
+// providerChoice is a provider and model to switch to; empty fields keep
+// the current provider and use its default model.
+type providerChoice struct {
+	name, model string
+}
+
+// Pause stops the session before its next iteration until Resume is called.
+// The current provider or tool call finishes first.
+func (r *Runtime) Pause() {
+	r.controlMu.Lock()
+	defer r.controlMu.Unlock()
+	if !r.control.paused {
+		r.control.paused = true
+		r.control.resume = make(chan struct{})
+	}
+}
+
+// Resume continues a paused session.
+func (r *Runtime) Resume() {
+	r.controlMu.Lock()
+	defer r.controlMu.Unlock()
+	if r.control.paused {
+		r.control.paused = false
+		close(r.control.resume)
+	}
+}
+
+// Paused reports whether the session is paused or about to pause.
+func (r *Runtime) Paused() bool {
+	r.controlMu.Lock()
+	defer r.controlMu.Unlock()
+	return r.control.paused
+}
+
+// Steer queues a user message that is added to the conversation before the
+// next provider call.
+func (r *Runtime) Steer(msg string) {
+	msg = strings.TrimSpace(msg)
+	if msg == "" {
+		return
+	}
+	r.controlMu.Lock()
+	defer r.controlMu.Unlock()
+	r.control.steering = append(r.control.steering, msg)
+}
+
+// steeringMessages drains the queued steering messages as user messages.
+func (r *Runtime) steeringMessages() []provider.Message {
+	r.controlMu.Lock()
+	defer r.controlMu.Unlock()
+	var msgs []provider.Message
+	for _, s := range r.control.steering {
+		msgs = append(msgs, provider.Message{Role: "user", Content: "[User steering] " + s})
+	}
+	r.control.steering = nil
+	return msgs
+}
+
+// SwitchProvider moves the session onto another provider or model from its
+// next iteration, keeping the conversation; an empty name keeps the current
+// provider. Without a provider factory (SetProviderFactory) the request is
+// logged and ignored.
+func (r *Runtime) SwitchProvider(name, model string) {
+	name, model = strings.TrimSpace(name), strings.TrimSpace(model)
+	if name == "" && model == "" {
+		return
+	}
+	r.controlMu.Lock()
+	defer r.controlMu.Unlock()
+	r.control.switchTo = &providerChoice{name: name, model: model}
+}
+
+// takeSwitch returns the requested provider switch, if any, and clears it.
+func (r *Runtime) takeSwitch() *providerChoice {
+	r.controlMu.Lock()
+	defer r.controlMu.Unlock()
+	choice := r.control.switchTo
+	r.control.switchTo = nil
+	return choice
+}
+
+// takeStoredSteering queues the steering requests other processes left in
+// the store for the session (see store.SQLiteStore.SteerSession).
+func (r *Runtime) takeStoredSteering(sessionID string) {
+	taker, ok := r.store.(interface {
+		TakeSteering(sessionID string) ([]*store.SteeringRequest, error)
+	})
+	if !ok {
+		return
+	}
+	reqs, err := taker.TakeSteering(sessionID)
+	if err != nil {
+		r.observe.Log().Warn().Err(err).Str("sessionID", sessionID).Msg("failed to read steering requests")
+		return
+	}
+	for _, req := range reqs {
+		r.Steer(req.Message)
+		r.SwitchProvider(req.Provider, req.Model)
+	}
+}
+
+// waitWhilePaused blocks while the session is paused, until it is resumed,
+// cancelled, or ctx ends. It returns how long it waited, which the caller
+// leaves out of the session's elapsed time.
+func (r *Runtime) waitWhilePaused(ctx context.Context, sessionID string, cancelRequested *atomic.Bool) time.Duration {
+	r.controlMu.Lock()
+	paused, resume := r.control.paused, r.control.resume
+	r.controlMu.Unlock()
+	if !paused {
+		return 0
+	}
+
+	start := time.Now()
+	r.ui.UpdateStatus("Paused")
+	r.ui.Log("⏸  Session paused")
+	r.eventBus.PublishSimple(EventSessionPaused, sessionID)
+	ticker := time.NewTicker(cancelPollInterval)
+	defer ticker.Stop()
+	for !cancelRequested.Load() {
+		select {
+		case <-resume:
+			r.ui.UpdateStatus("Executing Session...")
+			r.ui.Log("▶  Session resumed")
+			r.eventBus.PublishSimple(EventSessionResumed, sessionID)
+			return time.Since(start)
+		case <-ctx.Done():
+			return time.Since(start)
+		case <-ticker.C:
+		}
+	}
+	return time.Since(start)
+}
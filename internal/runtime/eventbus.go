@@ -1,6 +1,7 @@
 package runtime
 
 import (
+	"slices"
 	"sync"
 	"time"
 )
@@ -9,19 +10,29 @@ import (
 type EventType string
 
 const (
-	EventIterationStart   EventType = "iteration_start"
-	EventIterationEnd     EventType = "iteration_end"
-	EventToolCallStart    EventType = "tool_call_start"
-	EventToolCallEnd      EventType = "tool_call_end"
-	EventProviderRequest  EventType = "provider_request"
-	EventProviderResponse EventType = "provider_response"
-	EventGuardViolation   EventType = "guard_violation"
-	EventVerificationPass EventType = "verification_pass"
-	EventVerificationFail EventType = "verification_fail"
-	EventSessionComplete  EventType = "session_complete"
-	EventSessionError     EventType = "session_error"
-	EventMemoryArchived   EventType = "memory_archived"
-	EventContextPruned    EventType = "context_pruned"
+	EventIterationStart    EventType = "iteration_start"
+	EventIterationEnd      EventType = "iteration_end"
+	EventToolCallStart     EventType = "tool_call_start"
+	EventToolCallEnd       EventType = "tool_call_end"
+	EventProviderRequest   EventType = "provider_request"
+	EventProviderResponse  EventType = "provider_response"
+	EventGuardViolation    EventType = "guard_violation"
+	EventVerificationPass  EventType = "verification_pass"
+	EventVerificationFail  EventType = "verification_fail"
+	EventSessionComplete   EventType = "session_complete"
+	EventSessionError      EventType = "session_error"
+	EventMemoryArchived    EventType = "memory_archived"
+	EventContextPruned     EventType = "context_pruned"
+	EventApprovalRequested EventType = "approval_requested"
+	EventSubtaskStart      EventType = "subtask_start"
+	EventSubtaskEnd        EventType = "subtask_end"
+	EventSessionPaused     EventType = "session_paused"
+	EventSessionResumed    EventType = "session_resumed"
+	EventSteering          EventType = "steering"
+	EventHookFail          EventType = "hook_fail"
+	EventReview            EventType = "review"
+	EventProviderSwitch    EventType = "provider_switch"
+	EventContextUsage      EventType = "context_usage"
 )
 
 // Event represents a runtime event with associated data.
@@ -35,56 +46,129 @@ type Event struct {
 // EventHandler is a function that handles events.
 type EventHandler func(Event)
 
+// PanicHandler is told about a handler that panicked on an event.
+type PanicHandler func(event Event, recovered interface{})
+
 // EventBus manages event publication and subscription.
 // It provides a decoupled way for runtime components to communicate.
+//
+// Handlers run on the publishing goroutine, outside the bus's lock, so they
+// may subscribe and unsubscribe. A handler that panics is skipped and
+// reported to the panic handler; the remaining handlers still run.
 type EventBus struct {
-	mu       sync.RWMutex
-	handlers map[EventType][]EventHandler
-	allHandlers []EventHandler
+	mu sync.RWMutex
+	// Both are replaced rather than modified, so Publish can range over
+	// them without holding the lock
+	handlers    map[EventType][]*Subscription
+	allHandlers []*Subscription
+	onPanic     PanicHandler
+}
+
+// Subscription is a handler registered with an EventBus, removed again
+// with Unsubscribe.
+type Subscription struct {
+	bus       *EventBus
+	eventType EventType // Empty for every type
+	sessionID string    // Empty for every session
+	handler   EventHandler
 }
 
 // NewEventBus creates a new event bus.
 func NewEventBus() *EventBus {
 	return &EventBus{
-		handlers: make(map[EventType][]EventHandler),
+		handlers: make(map[EventType][]*Subscription),
 	}
 }
 
 // Subscribe registers a handler for a specific event type.
-func (eb *EventBus) Subscribe(eventType EventType, handler EventHandler) {
+func (eb *EventBus) Subscribe(eventType EventType, handler EventHandler) *Subscription {
+	return eb.add(&Subscription{bus: eb, eventType: eventType, handler: handler})
+}
+
+// SubscribeAll registers a handler for all event types.
+func (eb *EventBus) SubscribeAll(handler EventHandler) *Subscription {
+	return eb.add(&Subscription{bus: eb, handler: handler})
+}
+
+// SubscribeSession registers a handler for every event of one session. It
+// is unsubscribed once the session's session_complete or session_error
+// event has been handled, so a long-lived process doesn't keep handlers of
+// finished sessions.
+func (eb *EventBus) SubscribeSession(sessionID string, handler EventHandler) *Subscription {
+	return eb.add(&Subscription{bus: eb, sessionID: sessionID, handler: handler})
+}
+
+// SetPanicHandler sets what is told about handlers that panic; nil ignores
+// them.
+func (eb *EventBus) SetPanicHandler(h PanicHandler) {
 	eb.mu.Lock()
 	defer eb.mu.Unlock()
-	eb.handlers[eventType] = append(eb.handlers[eventType], handler)
+	eb.onPanic = h
 }
 
-// SubscribeAll registers a handler for all event types.
-func (eb *EventBus) SubscribeAll(handler EventHandler) {
+func (eb *EventBus) add(sub *Subscription) *Subscription {
 	eb.mu.Lock()
 	defer eb.mu.Unlock()
-	eb.allHandlers = append(eb.allHandlers, handler)
+	if sub.eventType == "" {
+		eb.allHandlers = append(slices.Clip(eb.allHandlers), sub)
+	} else {
+		eb.handlers[sub.eventType] = append(slices.Clip(eb.handlers[sub.eventType]), sub)
+	}
+	return sub
+}
+
+// Unsubscribe removes the handler from its bus; events published afterwards
+// don't reach it. It may be called more than once, and from the handler.
+func (s *Subscription) Unsubscribe() {
+	eb := s.bus
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	this := func(sub *Subscription) bool { return sub == s }
+	if s.eventType == "" {
+		eb.allHandlers = slices.DeleteFunc(slices.Clone(eb.allHandlers), this)
+		return
+	}
+	if handlers := slices.DeleteFunc(slices.Clone(eb.handlers[s.eventType]), this); len(handlers) > 0 {
+		eb.handlers[s.eventType] = handlers
+	} else {
+		delete(eb.handlers, s.eventType)
+	}
 }
 
 // Publish sends an event to all registered handlers.
 func (eb *EventBus) Publish(event Event) {
 	eb.mu.RLock()
-	defer eb.mu.RUnlock()
+	handlers, allHandlers, onPanic := eb.handlers[event.Type], eb.allHandlers, eb.onPanic
+	eb.mu.RUnlock()
 
 	// Set timestamp if not already set
 	if event.Timestamp.IsZero() {
 		event.Timestamp = time.Now()
 	}
 
-	// Notify specific handlers
-	if handlers, ok := eb.handlers[event.Type]; ok {
-		for _, handler := range handlers {
-			handler(event)
+	// Notify specific handlers, then all-event handlers
+	finished := event.Type == EventSessionComplete || event.Type == EventSessionError
+	for _, subs := range [][]*Subscription{handlers, allHandlers} {
+		for _, sub := range subs {
+			if sub.sessionID != "" && sub.sessionID != event.SessionID {
+				continue
+			}
+			sub.deliver(event, onPanic)
+			if sub.sessionID != "" && finished {
+				sub.Unsubscribe()
+			}
 		}
 	}
+}
 
-	// Notify all-event handlers
-	for _, handler := range eb.allHandlers {
-		handler(event)
-	}
+// deliver calls the subscription's handler, recovering from a panic.
+func (s *Subscription) deliver(event Event, onPanic PanicHandler) {
+	defer func() {
+		if recovered := recover(); recovered != nil && onPanic != nil {
+			onPanic(event, recovered)
+		}
+	}()
+	s.handler(event)
 }
 
 // PublishSimple is a convenience method for publishing events without additional data.
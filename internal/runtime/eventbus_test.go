@@ -197,3 +197,82 @@ func TestEventType_Constants(t *testing.T) {
 		}
 	}
 }
+
+func TestEventBus_Unsubscribe(t *testing.T) {
+	eb := NewEventBus()
+	var typed, all int
+	sub := eb.Subscribe(EventIterationStart, func(e Event) { typed++ })
+	allSub := eb.SubscribeAll(func(e Event) { all++ })
+
+	eb.Publish(Event{Type: EventIterationStart})
+	sub.Unsubscribe()
+	sub.Unsubscribe()
+	allSub.Unsubscribe()
+	eb.Publish(Event{Type: EventIterationStart})
+
+	if typed != 1 || all != 1 {
+		t.Errorf("expected one call each before unsubscribing, got %d and %d", typed, all)
+	}
+	if len(eb.handlers) != 0 || len(eb.allHandlers) != 0 {
+		t.Errorf("expected no handlers left, got %v and %v", eb.handlers, eb.allHandlers)
+	}
+}
+
+func TestEventBus_UnsubscribeFromHandler(t *testing.T) {
+	eb := NewEventBus()
+	var first, second int
+	var sub *Subscription
+	sub = eb.Subscribe(EventIterationStart, func(e Event) {
+		first++
+		sub.Unsubscribe()
+	})
+	eb.Subscribe(EventIterationStart, func(e Event) { second++ })
+
+	eb.Publish(Event{Type: EventIterationStart})
+	eb.Publish(Event{Type: EventIterationStart})
+
+	if first != 1 || second != 2 {
+		t.Errorf("expected the self-removing handler once and the other twice, got %d and %d", first, second)
+	}
+}
+
+func TestEventBus_SubscribeSession(t *testing.T) {
+	eb := NewEventBus()
+	var received []EventType
+	eb.SubscribeSession("sess-1", func(e Event) { received = append(received, e.Type) })
+
+	eb.PublishSimple(EventIterationStart, "sess-1")
+	eb.PublishSimple(EventIterationStart, "sess-2")
+	eb.PublishSimple(EventSessionComplete, "sess-2")
+	eb.PublishSimple(EventSessionComplete, "sess-1")
+	eb.PublishSimple(EventIterationStart, "sess-1")
+
+	if len(received) != 2 || received[0] != EventIterationStart || received[1] != EventSessionComplete {
+		t.Errorf("expected the session's events up to its completion, got %v", received)
+	}
+	if len(eb.allHandlers) != 0 {
+		t.Errorf("expected the subscription removed once the session finished, got %d handlers", len(eb.allHandlers))
+	}
+}
+
+func TestEventBus_PanicIsolation(t *testing.T) {
+	eb := NewEventBus()
+	var recovered []interface{}
+	eb.SetPanicHandler(func(e Event, r interface{}) { recovered = append(recovered, r) })
+	called := false
+	eb.Subscribe(EventIterationStart, func(e Event) { panic("bad handler") })
+	eb.SubscribeAll(func(e Event) { called = true })
+
+	eb.Publish(Event{Type: EventIterationStart})
+
+	if !called {
+		t.Error("expected the handlers after a panicking one to run")
+	}
+	if len(recovered) != 1 || recovered[0] != "bad handler" {
+		t.Errorf("expected the panic reported, got %v", recovered)
+	}
+
+	// Without a panic handler the panic is still contained
+	eb.SetPanicHandler(nil)
+	eb.Publish(Event{Type: EventIterationStart})
+}
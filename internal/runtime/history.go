@@ -0,0 +1,149 @@
+package runtime
+
+import (
+	"encoding/json"
+
+	"github.com/felixgeelhaar/simon/internal/provider"
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+// transcript persists the conversation history incrementally, so a crash
+// loses at most the current iteration. History replaced by summarization is
+// not rewritten; the compressed prompt is appended after it instead.
+type transcript struct {
+	store     store.Storage
+	sessionID string
+	saved     int                    // Entries of the current history already persisted
+	usage     map[int]provider.Usage // Token counts of unsaved assistant messages, by index
+}
+
+func newTranscript(s store.Storage, sessionID string) *transcript {
+	return &transcript{store: s, sessionID: sessionID, usage: make(map[int]provider.Usage)}
+}
+
+// noteUsage records the token counts of the response stored at history[index].
+func (t *transcript) noteUsage(index int, u provider.Usage) {
+	t.usage[index] = u
+}
+
+// reset marks the history as replaced; the next flush starts from its first entry.
+func (t *transcript) reset() {
+	t.saved = 0
+	t.usage = make(map[int]provider.Usage)
+}
+
+// flush appends the history entries not yet persisted.
+func (t *transcript) flush(history []provider.Message) error {
+	if t.saved >= len(history) {
+		return nil
+	}
+	messages := make([]*store.Message, 0, len(history)-t.saved)
+	for i := t.saved; i < len(history); i++ {
+		m := history[i]
+		msg := &store.Message{Role: m.Role, Content: m.Content, ToolCallID: m.ToolCallID}
+		if len(m.ToolCalls) > 0 {
+			calls, err := json.Marshal(m.ToolCalls)
+			if err != nil {
+				return err
+			}
+			msg.ToolCalls = string(calls)
+		}
+		if u, ok := t.usage[i]; ok {
+			msg.PromptTokens = u.PromptTokens
+			msg.CompletionTokens = u.CompletionTokens
+		}
+		messages = append(messages, msg)
+	}
+	if err := t.store.AppendMessages(t.sessionID, messages); err != nil {
+		return err
+	}
+	t.saved = len(history)
+	t.usage = make(map[int]provider.Usage)
+	return nil
+}
+
+// compactAfterTokens is the estimated history size above which older tool
+// results are replaced by references to their stored outputs.
+var compactAfterTokens = 2000
+
+// compactHistory replaces the digests of tool results that came before the
+// latest assistant message with their artifact references (see
+// mcp.ToolResult.Ref), keyed by tool call ID, once the history is estimated
+// above compactAfterTokens. The narrative is kept; the agent can still read
+// a replaced output with read_artifact. Used references are removed from
+// refs. It returns the number of results replaced.
+func compactHistory(history []provider.Message, refs map[string]string) int {
+	if len(refs) == 0 || provider.EstimateMessagesTokens(history) <= compactAfterTokens {
+		return 0
+	}
+	latest := -1
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == "assistant" {
+			latest = i
+			break
+		}
+	}
+	replaced := 0
+	for i := 0; i < latest; i++ {
+		m := &history[i]
+		if ref, ok := refs[m.ToolCallID]; ok && m.Role == "tool" {
+			m.Content = ref
+			delete(refs, m.ToolCallID)
+			replaced++
+		}
+	}
+	return replaced
+}
+
+// historyFromStore converts persisted messages back into provider messages.
+func historyFromStore(messages []*store.Message) ([]provider.Message, error) {
+	history := make([]provider.Message, 0, len(messages))
+	for _, m := range messages {
+		msg := provider.Message{Role: m.Role, Content: m.Content, ToolCallID: m.ToolCallID}
+		if m.ToolCalls != "" {
+			if err := json.Unmarshal([]byte(m.ToolCalls), &msg.ToolCalls); err != nil {
+				return nil, err
+			}
+		}
+		history = append(history, msg)
+	}
+	return history, nil
+}
+
+// LoadHistory returns a session's persisted conversation in order.
+func (r *Runtime) LoadHistory(sessionID string) ([]provider.Message, error) {
+	messages, err := r.store.LoadMessages(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return historyFromStore(messages)
+}
+
+// publishPruned reports a context reduction, kind "compacted" or
+// "summarized", with the estimated history size before and after it.
+func (r *Runtime) publishPruned(sessionID, kind string, before, after int) {
+	r.eventBus.PublishWithData(EventContextPruned, sessionID, map[string]interface{}{
+		"kind":             kind,
+		"tokens_before":    before,
+		"tokens_after":     after,
+		"reclaimed_tokens": before - after,
+	})
+}
+
+// publishContextUsage reports the prompt size of the iteration's request
+// and, when the model's context window is known, the fraction of it used.
+func (r *Runtime) publishContextUsage(sessionID string, iteration, promptTokens, window int) {
+	data := map[string]interface{}{
+		"iteration":      iteration,
+		"prompt_tokens":  promptTokens,
+		"context_window": window,
+	}
+	entry := r.observe.Log().Info().Str("sessionID", sessionID).Int("iteration", iteration).Int("prompt_tokens", promptTokens)
+	if window > 0 {
+		utilization := float64(promptTokens) / float64(window)
+		data["utilization"] = utilization
+		entry = entry.Int("context_window", window).Float64("utilization", utilization)
+	}
+	entry.Msg("context usage")
+	r.eventBus.PublishWithData(EventContextUsage, sessionID, data)
+}
@@ -0,0 +1,44 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/felixgeelhaar/simon/internal/guard"
+)
+
+// runHooks runs the spec's commands for one hook point through the proxy and
+// describes the failures for the agent; the description is empty when every
+// command passed. Only a halt-level guard violation is returned as an error.
+func (r *Runtime) runHooks(ctx context.Context, sessionID, point string, commands []string) (string, error) {
+	var failures []string
+	for _, command := range commands {
+		r.ui.Log(fmt.Sprintf("🪝 %s: %s", point, command))
+		res, err := r.mcpProxy.RunHook(ctx, sessionID, point, command)
+		for _, v := range res.Violations {
+			r.reportViolation(sessionID, v)
+		}
+		if guard.IsHalt(err) {
+			return "", err
+		}
+
+		var failure string
+		switch {
+		case err != nil:
+			failure = fmt.Sprintf("%q could not run: %v", command, err)
+		case !res.Passed:
+			failure = fmt.Sprintf("%q failed (output at %s):\n%s", command, res.ArtifactPath, res.Excerpt)
+		default:
+			continue
+		}
+		r.observe.Log().Warn().Str("sessionID", sessionID).Str("hook", point).Str("command", command).Msg("hook failed")
+		r.eventBus.PublishWithData(EventHookFail, sessionID, map[string]interface{}{"hook": point, "command": command})
+		r.ui.Log(fmt.Sprintf("   └─ ❌ %s", firstLine(failure)))
+		failures = append(failures, failure)
+	}
+	if len(failures) == 0 {
+		return "", nil
+	}
+	return fmt.Sprintf("The %s hook failed:\n%s", point, strings.Join(failures, "\n")), nil
+}
@@ -0,0 +1,266 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/felixgeelhaar/simon/internal/provider"
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+// memoryRecall is how many memories a session retrieves; a past session
+// archives up to one per kind.
+const memoryRecall = 8
+
+// lessonsPrompt asks for the completion summary once verification passed,
+// in sections parseLessons reads from providers without structured outputs.
+const lessonsPrompt = "The task is complete. For future reference, provide a 1-sentence summary of what was built, " +
+	"followed by the sections \"What worked:\", \"Pitfalls:\" (what a future attempt should avoid), and " +
+	"\"Commands used:\" (commands that proved useful, exactly as run), each a list of \"- \" items. Leave out a section with nothing in it."
+
+// summaryFormat asks a provider with structured outputs for the completion
+// summary as JSON, separating what was built from each kind of lesson.
+var summaryFormat = provider.ResponseFormat{
+	Name: "summary",
+	Schema: json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"summary": {"type": "string", "description": "One sentence on what was built"},
+			"worked": {"type": "array", "items": {"type": "string"}, "description": "Approaches that worked and are worth repeating"},
+			"pitfalls": {"type": "array", "items": {"type": "string"}, "description": "Mistakes, dead ends, and surprises to avoid next time"},
+			"commands": {"type": "array", "items": {"type": "string"}, "description": "Shell commands that proved useful, exactly as run"}
+		},
+		"required": ["summary", "worked", "pitfalls", "commands"],
+		"additionalProperties": false
+	}`),
+}
+
+// lessons is what a completed session reports having learned. The summary
+// and each kind of lesson are archived as memories of their own, so later
+// sessions retrieve pitfalls and commands apart from what was built.
+type lessons struct {
+	Summary  string   `json:"summary"`
+	Worked   []string `json:"worked"`
+	Pitfalls []string `json:"pitfalls"`
+	Commands []string `json:"commands"`
+}
+
+// lessonKinds are the kinds of lessons besides the summary, in the order
+// they are shown.
+var lessonKinds = []struct{ kind, title string }{
+	{store.MemoryKindWorked, "What worked"},
+	{store.MemoryKindPitfalls, "Pitfalls"},
+	{store.MemoryKindCommands, "Commands used"},
+}
+
+// lessonItemPattern matches an item of a lessons section: "- x", "* x",
+// or "1. x".
+var lessonItemPattern = regexp.MustCompile(`^\s*(?:\d+[.)]|[-*])\s+(.+)$`)
+
+// parseLessons reads a completion summary answered in summaryFormat or, from
+// providers without structured outputs, in the sections of lessonsPrompt.
+// Other free text answers are kept as the summary.
+func parseLessons(content string) lessons {
+	var l lessons
+	if err := json.Unmarshal([]byte(trimFence(content)), &l); err != nil || strings.TrimSpace(l.Summary) == "" {
+		if l, ok := parseLessonSections(content); ok {
+			return l
+		}
+		return lessons{Summary: content}
+	}
+	l.Summary = strings.TrimSpace(l.Summary)
+	l.Worked, l.Pitfalls, l.Commands = trimItems(l.Worked), trimItems(l.Pitfalls), trimItems(l.Commands)
+	return l
+}
+
+// trimFence returns content without surrounding space and the ```json
+// fence some models wrap JSON answers in.
+func trimFence(content string) string {
+	content = strings.TrimSpace(content)
+	if body, ok := strings.CutPrefix(content, "```"); ok && strings.HasSuffix(body, "```") {
+		body = strings.TrimSuffix(body, "```")
+		if _, rest, found := strings.Cut(body, "\n"); found {
+			body = rest
+		}
+		content = strings.TrimSpace(body)
+	}
+	return content
+}
+
+// parseLessonSections reads lessons written as a summary followed by the
+// lessonKinds sections, as lessons.String renders them. Headings may carry
+// markdown emphasis. ok is false when no section heading is found.
+func parseLessonSections(content string) (l lessons, ok bool) {
+	var summary []string
+	kind := ""
+	for _, line := range strings.Split(content, "\n") {
+		if k, found := lessonHeading(line); found {
+			kind, ok = k, true
+			continue
+		}
+		if kind == "" {
+			if line = strings.TrimSpace(line); line != "" {
+				summary = append(summary, line)
+			}
+			continue
+		}
+		if m := lessonItemPattern.FindStringSubmatch(line); m != nil {
+			item := strings.TrimSpace(strings.Trim(strings.TrimSpace(m[1]), "`"))
+			switch kind {
+			case store.MemoryKindWorked:
+				l.Worked = append(l.Worked, item)
+			case store.MemoryKindPitfalls:
+				l.Pitfalls = append(l.Pitfalls, item)
+			case store.MemoryKindCommands:
+				l.Commands = append(l.Commands, item)
+			}
+		}
+	}
+	l.Summary = strings.Join(summary, " ")
+	if !ok || l.Summary == "" {
+		return lessons{}, false
+	}
+	l.Worked, l.Pitfalls, l.Commands = trimItems(l.Worked), trimItems(l.Pitfalls), trimItems(l.Commands)
+	return l, true
+}
+
+// lessonHeading returns the kind of lessons a section heading line starts,
+// such as "Pitfalls:" or "## **Commands used**".
+func lessonHeading(line string) (string, bool) {
+	title := strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "#"))
+	title = strings.TrimSpace(strings.Trim(title, "*_"))
+	title = strings.TrimSpace(strings.Trim(strings.TrimSuffix(title, ":"), "*_"))
+	for _, k := range lessonKinds {
+		if strings.EqualFold(title, k.title) {
+			return k.kind, true
+		}
+	}
+	return "", false
+}
+
+// trimItems trims the items of a list and drops the empty ones.
+func trimItems(items []string) []string {
+	var kept []string
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// items returns the lessons of a kind.
+func (l lessons) items(kind string) []string {
+	switch kind {
+	case store.MemoryKindWorked:
+		return l.Worked
+	case store.MemoryKindPitfalls:
+		return l.Pitfalls
+	case store.MemoryKindCommands:
+		return l.Commands
+	}
+	return nil
+}
+
+// String renders the lessons as the session's summary artifact.
+func (l lessons) String() string {
+	var b strings.Builder
+	b.WriteString(l.Summary)
+	for _, k := range lessonKinds {
+		if items := l.items(k.kind); len(items) > 0 {
+			fmt.Fprintf(&b, "\n%s:", k.title)
+			for _, item := range items {
+				fmt.Fprintf(&b, "\n- %s", item)
+			}
+		}
+	}
+	return b.String()
+}
+
+// lessonMemory is a memory archived from a session's lessons.
+type lessonMemory struct {
+	kind    string
+	content string
+}
+
+// memories splits the lessons into the summary, followed by the changed
+// files, and a memory per kind of lesson reported. Lessons name the goal,
+// so keyword search finds them for similar goals.
+func (l lessons) memories(goal, changed string) []lessonMemory {
+	memories := []lessonMemory{{store.MemoryKindSummary, l.Summary + "\n" + changed}}
+	for _, k := range lessonKinds {
+		items := l.items(k.kind)
+		if len(items) == 0 {
+			continue
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "%s for %q:", k.title, goal)
+		for _, item := range items {
+			fmt.Fprintf(&b, "\n- %s", item)
+		}
+		memories = append(memories, lessonMemory{k.kind, b.String()})
+	}
+	return memories
+}
+
+// archiveLessons stores a completed session's lessons as memories, typed by
+// the kind metadata key, and returns how many were archived. They share the
+// goal's embedding, which later goals are matched against.
+func (r *Runtime) archiveLessons(ctx context.Context, sessionID, goal, namespace string, l lessons, changed string) int {
+	vector := r.memoryVector(ctx, goal)
+	archived := 0
+	for _, m := range l.memories(goal, changed) {
+		meta := map[string]string{
+			"session_id":             sessionID,
+			"goal":                   goal,
+			store.MemoryNamespaceKey: namespace,
+			store.MemoryKindKey:      m.kind,
+		}
+		if err := r.store.AddMemory(m.content, vector, meta); err != nil {
+			r.observe.Log().Warn().Str("kind", m.kind).Err(err).Msg("failed to archive memory")
+			continue
+		}
+		archived++
+	}
+	return archived
+}
+
+// memoryKindOrder ranks memory kinds for the prompt: summaries, then
+// lessons in lessonKinds order.
+func memoryKindOrder(meta map[string]string) int {
+	for i, k := range lessonKinds {
+		if meta[store.MemoryKindKey] == k.kind {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// pastExperiences renders retrieved memories for the initial prompt,
+// summaries first and then the lessons by kind, each in retrieval order.
+// The memories of the previous attempt, prevID, are left out; they are
+// shown with the rest of it. It returns "" when nothing is left.
+func pastExperiences(memories []store.MemoryItem, prevID string) string {
+	var shown []store.MemoryItem
+	for _, m := range memories {
+		if prevID == "" || m.Metadata["session_id"] != prevID {
+			shown = append(shown, m)
+		}
+	}
+	if len(shown) == 0 {
+		return ""
+	}
+	sort.SliceStable(shown, func(i, j int) bool {
+		return memoryKindOrder(shown[i].Metadata) < memoryKindOrder(shown[j].Metadata)
+	})
+	var b strings.Builder
+	b.WriteString("Relevant past experiences:\n")
+	for _, m := range shown {
+		fmt.Fprintf(&b, "- %s\n", strings.ReplaceAll(strings.TrimSpace(m.Content), "\n", "\n  "))
+	}
+	return b.String()
+}
@@ -0,0 +1,156 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/felixgeelhaar/simon/internal/coach"
+	"github.com/felixgeelhaar/simon/internal/guard"
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+// MetadataStepsDone records, in a multi-step session's metadata, how many of
+// the spec's steps have completed. A session continuing it starts at the
+// next step.
+const MetadataStepsDone = "steps_done"
+
+// executeMission runs a spec with steps: each step is a sub-task of the
+// session with its own guard budget, started only once the previous one
+// completed. Progress is checkpointed after every step, so a session that
+// continues this one (or this one run again) skips finished steps. Once all
+// steps are done the mission's own evidence and verify commands are checked.
+func (r *Runtime) executeMission(ctx context.Context, session *store.Session, spec *coach.TaskSpec) error {
+	ctx, cancelRequested, stopWatch := r.watchCancellation(ctx, session.ID)
+	defer stopWatch()
+
+	done := r.stepsDone(session, len(spec.Steps))
+	if done > 0 {
+		r.ui.Log(fmt.Sprintf("↩️  Steps 1-%d already done, continuing with step %d", done, done+1))
+	}
+	session.Status = "running"
+	if err := r.store.UpdateSession(session); err != nil {
+		return err
+	}
+
+	preRun, err := r.runHooks(ctx, session.ID, coach.HookPreRun, spec.Hooks.PreRun)
+	if err != nil {
+		session.Status = "halted"
+		_ = r.store.UpdateSession(session)
+		return err
+	}
+
+	var summaries []string
+	for i := done; i < len(spec.Steps); i++ {
+		if cancelRequested.Load() {
+			return r.cancelMission(session, summaries)
+		}
+		if v := r.guard.CheckCost(session.Cost); v != nil && v.Severity != guard.SeverityWarn {
+			r.reportViolation(session.ID, v)
+			session.Status = "halted"
+			_ = r.store.UpdateSession(session)
+			return fmt.Errorf("guard violation: %s", v.Message)
+		}
+
+		r.ui.Log("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		r.ui.Log(fmt.Sprintf("▶ Step %d/%d: %s", i+1, len(spec.Steps), truncateString(spec.Steps[i].Goal, 60)))
+		stepSpec := spec.StepSpec(i)
+		if preRun != "" && i == done {
+			// pre_run failures are left to the first step to fix
+			stepSpec.Constraints = append(stepSpec.Constraints, preRun+"\nFix this before starting on the step.")
+		}
+		res, err := r.SpawnSubtask(ctx, session.ID, stepSpec, spec.Steps[i].MaxIterations)
+		r.absorbSubtasks(session)
+		if err != nil {
+			session.Status = "failed"
+			_ = r.store.UpdateSession(session)
+			return fmt.Errorf("step %d: %w", i+1, err)
+		}
+		if res.Summary != "" {
+			summaries = append(summaries, fmt.Sprintf("Step %d: %s", i+1, res.Summary))
+		}
+		if res.Status != "completed" {
+			if cancelRequested.Load() {
+				return r.cancelMission(session, summaries)
+			}
+			session.Status = res.Status
+			if !store.SessionFinished(res.Status) {
+				session.Status = "failed"
+			}
+			_ = r.store.UpdateSession(session)
+			if res.Err != nil {
+				return fmt.Errorf("step %d %s: %w", i+1, res.Status, res.Err)
+			}
+			return fmt.Errorf("step %d %s", i+1, res.Status)
+		}
+
+		session.Metadata[MetadataStepsDone] = strconv.Itoa(i + 1)
+		if err := r.store.UpdateSession(session); err != nil {
+			return err
+		}
+		r.observe.Log().Info().Str("sessionID", session.ID).Int("step", i+1).Str("subtask", res.SessionID).Msg("mission step completed")
+	}
+
+	r.ui.Log("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	r.ui.Log("🔍 Verifying Mission...")
+	err = r.verifyEvidence(ctx, session.ID, spec)
+	if err == nil {
+		var hookFailed string
+		if hookFailed, err = r.runHooks(ctx, session.ID, coach.HookOnComplete, spec.Hooks.OnComplete); err == nil && hookFailed != "" {
+			err = fmt.Errorf("%s", hookFailed)
+		}
+	}
+	if err != nil {
+		r.eventBus.PublishWithData(EventVerificationFail, session.ID, map[string]interface{}{"error": err.Error()})
+		r.ui.Log(fmt.Sprintf("❌ Verification failed: %s", firstLine(err.Error())))
+		session.Status = "failed"
+		if guard.IsHalt(err) {
+			session.Status = "halted"
+		}
+		_ = r.store.UpdateSession(session)
+		return err
+	}
+
+	r.eventBus.PublishSimple(EventVerificationPass, session.ID)
+	session.Status = "completed"
+	r.saveSummary(session.ID, strings.Join(summaries, "\n"))
+	r.ui.UpdateStatus("Completed")
+	r.ui.Log("🎉 Mission Complete!")
+	return r.store.UpdateSession(session)
+}
+
+// stepsDone returns how many leading steps need not run again: the
+// checkpoint of this session or of the session it continues.
+func (r *Runtime) stepsDone(session *store.Session, steps int) int {
+	done, _ := strconv.Atoi(session.Metadata[MetadataStepsDone])
+	if prevID := session.Metadata[MetadataPreviousSession]; prevID != "" {
+		if prev, err := r.store.GetSession(prevID); err == nil {
+			if n, _ := strconv.Atoi(prev.Metadata[MetadataStepsDone]); n > done {
+				done = n
+			}
+		}
+	}
+	if done > steps {
+		done = steps
+	}
+	if done > 0 {
+		session.Metadata[MetadataStepsDone] = strconv.Itoa(done)
+	}
+	return done
+}
+
+// cancelMission ends a multi-step session as cancelled, keeping the
+// summaries of the steps it finished.
+func (r *Runtime) cancelMission(session *store.Session, summaries []string) error {
+	session.Status = "cancelled"
+	r.ui.UpdateStatus("Cancelled")
+	if len(summaries) > 0 {
+		r.saveSummary(session.ID, "Partial progress (session cancelled):\n"+strings.Join(summaries, "\n"))
+	}
+	if err := r.store.UpdateSession(session); err != nil {
+		return err
+	}
+	r.ui.Log("🛑 Session cancelled")
+	return ErrSessionCancelled
+}
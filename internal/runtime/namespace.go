@@ -0,0 +1,111 @@
+package runtime
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/felixgeelhaar/simon/internal/coach"
+	"github.com/felixgeelhaar/simon/internal/provider"
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+// Session metadata keys for memory namespacing. MetadataMemoryNamespace
+// records the namespace a session's memories are retrieved from and archived
+// under; sub-tasks inherit it. MetadataGlobalMemory ("true") retrieves
+// memories from every namespace.
+const (
+	MetadataMemoryNamespace = "memory_namespace"
+	MetadataGlobalMemory    = "global_memory"
+)
+
+// memoryNamespace returns the namespace declared by the spec, the one the
+// session already has (resumed sessions and sub-tasks), or the
+// ProjectNamespace of the working directory.
+func (r *Runtime) memoryNamespace(session *store.Session, spec *coach.TaskSpec) string {
+	if spec.MemoryNamespace != "" {
+		return spec.MemoryNamespace
+	}
+	if ns := session.Metadata[MetadataMemoryNamespace]; ns != "" {
+		return ns
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	return ProjectNamespace(wd)
+}
+
+// memoryVector embeds text for memory retrieval and archiving, with the
+// provider the runtime was created with even after a switch. It returns nil
+// when the provider cannot embed (e.g. the CLI and Anthropic providers):
+// memories are then archived without a vector and found by keyword search,
+// and simon memory reindex can embed them later. Providers whose
+// capabilities rule out embeddings aren't asked.
+func (r *Runtime) memoryVector(ctx context.Context, text string) []float32 {
+	if !provider.CapabilitiesOf(r.embedder).Embeddings {
+		return nil
+	}
+	vec, err := r.embedder.Embed(ctx, text)
+	if err != nil {
+		r.observe.Log().Warn().Err(err).Msg("embedding failed, falling back to keyword memory search")
+		return nil
+	}
+	return vec
+}
+
+// ProjectNamespace derives a memory namespace from a directory: the origin
+// remote of the git repository containing it without scheme, user, port or
+// ".git" suffix (e.g. "github.com/felixgeelhaar/simon"), so every clone of a
+// repository shares its memories, or else its ProjectRoot.
+func ProjectNamespace(dir string) string {
+	root := ProjectRoot(dir)
+	cmd := exec.Command("git", "config", "--get", "remote.origin.url")
+	cmd.Dir = root
+	out, err := cmd.Output()
+	if err != nil {
+		return root
+	}
+	if ns := remoteNamespace(strings.TrimSpace(string(out))); ns != "" {
+		return ns
+	}
+	return root
+}
+
+// remoteNamespace normalizes a git remote URL, in URL or scp-like
+// ("git@host:owner/repo.git") form, to host/path.
+func remoteNamespace(remote string) string {
+	if u, err := url.Parse(remote); err == nil && u.Scheme != "" && u.Host != "" {
+		remote = u.Hostname() + "/" + strings.TrimPrefix(u.Path, "/")
+	} else if host, path, ok := strings.Cut(remote, ":"); ok && len(host) > 1 && !strings.Contains(host, "/") {
+		if _, h, ok := strings.Cut(host, "@"); ok {
+			host = h
+		}
+		remote = host + "/" + strings.TrimPrefix(path, "/")
+	} else {
+		// A local path remote (including "C:\repo") identifies nothing portable
+		return ""
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(remote, "/"), ".git")
+}
+
+// ProjectRoot returns the root of the git repository containing dir, or dir
+// itself, as an absolute path.
+func ProjectRoot(dir string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	for d := dir; ; {
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			return d
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return dir
+		}
+		d = parent
+	}
+}
@@ -0,0 +1,96 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/felixgeelhaar/simon/internal/coach"
+	"github.com/felixgeelhaar/simon/internal/mcp"
+	"github.com/felixgeelhaar/simon/internal/orchestrate"
+	"github.com/felixgeelhaar/simon/internal/provider"
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+// Session metadata naming the planner and reviewer of an orchestrated
+// session as provider/model; the executor is the session's provider.
+const (
+	MetadataPlanner  = "planner"
+	MetadataReviewer = "reviewer"
+)
+
+// maxReviewDiff caps the diff shown to the reviewer, in bytes.
+const maxReviewDiff = 32 * 1024
+
+// SetOrchestrator splits sessions between the orchestrator's models: its
+// planner writes the step plan before the first iteration, the runtime's
+// provider executes it, and its reviewer must approve the work once the
+// evidence is verified, or the executor continues with the review as
+// feedback. A failed review accepts the verified work. Sub-tasks, and so
+// each step of a mission, are planned and reviewed the same way.
+func (r *Runtime) SetOrchestrator(o *orchestrate.MultiAgentOrchestrator) {
+	r.orchestrator = o
+}
+
+// planSession asks the planner for the session's plan, as JSON when the
+// planner has structured outputs. It returns the planner's response, or nil
+// when planning failed and the executor should plan itself.
+func (r *Runtime) planSession(ctx context.Context, session *store.Session, spec *coach.TaskSpec, background string) *provider.Response {
+	r.ui.Log(fmt.Sprintf("🗺️  Planner (%s) is drafting the plan...", roleName(r.orchestrator.Planner)))
+	resp, err := r.orchestrator.Plan(provider.WithPhase(provider.WithResponseFormat(ctx, planFormat), provider.PhasePlanning), *spec, background)
+	if err != nil {
+		r.observe.Log().Warn().Err(err).Msg("planning failed, the executor plans itself")
+		r.ui.Log("   └─ Planning failed, the executor will plan")
+		return nil
+	}
+	r.recordUsageOf(session, r.orchestrator.Planner, resp.Usage)
+	_ = r.store.UpdateSession(session)
+	return resp
+}
+
+// plannedInstructions replaces planInstructions in the executor's prompt
+// when the planner produced a plan; content is the planner's response.
+func plannedInstructions(plan *Plan, content string) string {
+	var b strings.Builder
+	b.WriteString("A planner has broken the task into these steps:\n")
+	for i, step := range plan.Steps {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, step.Description)
+	}
+	if notes := planNotes(content); notes != "" {
+		fmt.Fprintf(&b, "Planner's notes: %s\n", notes)
+	}
+	b.WriteString("Carry them out in order. When you finish a step, say \"Step N done\".")
+	return b.String()
+}
+
+// reviewWork asks the reviewer to judge the verified work. It returns the
+// review, whose usage is already added to the session.
+func (r *Runtime) reviewWork(ctx context.Context, session *store.Session, spec *coach.TaskSpec, report string, changes []mcp.FileChange) (*orchestrate.Review, error) {
+	r.ui.Log(fmt.Sprintf("🔎 Reviewer (%s) is reviewing the work...", roleName(r.orchestrator.Reviewer)))
+	work := orchestrate.Work{Report: report, Changes: describeChanges(changes)}
+	var diff strings.Builder
+	for _, c := range changes {
+		diff.WriteString(c.Diff)
+	}
+	work.Diff = diff.String()
+	if len(work.Diff) > maxReviewDiff {
+		work.Diff = work.Diff[:maxReviewDiff] + "\n... (diff truncated)"
+	}
+
+	review, err := r.orchestrator.Review(ctx, *spec, work)
+	if err != nil {
+		return nil, err
+	}
+	r.recordUsageOf(session, r.orchestrator.Reviewer, review.Usage)
+	data := map[string]interface{}{"approved": review.Approved}
+	if !review.Approved {
+		data["feedback"] = review.Feedback
+	}
+	r.eventBus.PublishWithData(EventReview, session.ID, data)
+	return review, nil
+}
+
+// roleName describes a role's provider as provider/model.
+func roleName(p provider.Provider) string {
+	return p.Name() + "/" + p.Model()
+}
@@ -0,0 +1,194 @@
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/provider"
+	"github.com/felixgeelhaar/simon/internal/store"
+	"github.com/felixgeelhaar/simon/internal/ui"
+)
+
+// Plan step statuses.
+const (
+	StepPending    = "pending"
+	StepInProgress = "in_progress"
+	StepDone       = "done"
+)
+
+// planInstructions is appended to the initial prompt so the first response carries a plan.
+const planInstructions = "Before acting, outline your approach as a numbered list inside a ```plan fenced block. " +
+	"When you finish a step, say \"Step N done\"."
+
+var (
+	planBlockPattern = regexp.MustCompile("(?s)```plan\\s*\\n(.*?)```")
+	planItemPattern  = regexp.MustCompile(`^\s*(?:\d+[.)]|[-*])\s+(.+)$`)
+	stepDonePattern  = regexp.MustCompile(`(?i)\bstep\s+(\d+)\s+(?:is\s+)?(?:done|complete|completed|finished)\b`)
+)
+
+// planFormat asks a planner with structured outputs for its plan as JSON
+// rather than a ```plan block.
+var planFormat = provider.ResponseFormat{
+	Name: "plan",
+	Schema: json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"steps": {"type": "array", "items": {"type": "string"}, "description": "Concrete, verifiable steps in the order to carry them out"},
+			"notes": {"type": "string", "description": "Risks or pitfalls the executor should watch for; empty if none"}
+		},
+		"required": ["steps", "notes"],
+		"additionalProperties": false
+	}`),
+}
+
+// structuredPlan is a plan answered in planFormat.
+type structuredPlan struct {
+	Steps []string `json:"steps"`
+	Notes string   `json:"notes"`
+}
+
+// decodePlan reads a response in planFormat; ok is false for free text.
+func decodePlan(content string) (plan structuredPlan, ok bool) {
+	err := json.Unmarshal([]byte(strings.TrimSpace(content)), &plan)
+	return plan, err == nil
+}
+
+// PlanStep is a single step of the model's plan.
+type PlanStep struct {
+	Description string `json:"description"`
+	Status      string `json:"status"`
+}
+
+// Plan is the structured step plan requested at iteration 1.
+type Plan struct {
+	Steps     []PlanStep `json:"steps"`
+	UpdatedAt time.Time  `json:"updated_at"`
+
+	version int
+}
+
+// parsePlan extracts a plan from a response in planFormat or, from
+// providers without structured outputs, a ```plan block. It returns nil
+// when the response has no usable plan.
+func parsePlan(content string) *Plan {
+	var steps []PlanStep
+	if structured, ok := decodePlan(content); ok {
+		for _, step := range structured.Steps {
+			if step = strings.TrimSpace(step); step != "" {
+				steps = append(steps, PlanStep{Description: step, Status: StepPending})
+			}
+		}
+	} else if m := planBlockPattern.FindStringSubmatch(content); m != nil {
+		for _, line := range strings.Split(m[1], "\n") {
+			if item := planItemPattern.FindStringSubmatch(line); item != nil {
+				steps = append(steps, PlanStep{Description: strings.TrimSpace(item[1]), Status: StepPending})
+			}
+		}
+	}
+	if len(steps) == 0 {
+		return nil
+	}
+	steps[0].Status = StepInProgress
+	return &Plan{Steps: steps}
+}
+
+// planNotes returns what a plan response says besides its steps.
+func planNotes(content string) string {
+	if structured, ok := decodePlan(content); ok {
+		return strings.TrimSpace(structured.Notes)
+	}
+	return strings.TrimSpace(planBlockPattern.ReplaceAllString(content, ""))
+}
+
+// apply marks steps reported as done in a model response and moves the
+// first pending step to in progress. It reports whether anything changed.
+func (p *Plan) apply(content string) bool {
+	changed := false
+	for _, m := range stepDonePattern.FindAllStringSubmatch(content, -1) {
+		n, err := strconv.Atoi(m[1])
+		if err != nil || n < 1 || n > len(p.Steps) || p.Steps[n-1].Status == StepDone {
+			continue
+		}
+		p.Steps[n-1].Status = StepDone
+		changed = true
+	}
+	if changed {
+		p.advance()
+	}
+	return changed
+}
+
+// advance ensures the first unfinished step is marked in progress.
+func (p *Plan) advance() {
+	for i := range p.Steps {
+		switch p.Steps[i].Status {
+		case StepInProgress:
+			return
+		case StepPending:
+			p.Steps[i].Status = StepInProgress
+			return
+		}
+	}
+}
+
+// complete marks every step done once the session's evidence is verified.
+func (p *Plan) complete() bool {
+	changed := false
+	for i := range p.Steps {
+		if p.Steps[i].Status != StepDone {
+			p.Steps[i].Status = StepDone
+			changed = true
+		}
+	}
+	return changed
+}
+
+// remaining lists unfinished steps for inclusion in re-prompts.
+func (p *Plan) remaining() string {
+	if p == nil {
+		return ""
+	}
+	var b strings.Builder
+	for i, step := range p.Steps {
+		if step.Status != StepDone {
+			fmt.Fprintf(&b, "%d. %s\n", i+1, step.Description)
+		}
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return "Unfinished plan steps:\n" + b.String()
+}
+
+// recordPlan stores a new version of the plan as a structured artifact and
+// refreshes the UI progress pane.
+func (r *Runtime) recordPlan(sessionID string, plan *Plan) {
+	plan.version++
+	plan.UpdatedAt = time.Now()
+
+	steps := make([]ui.PlanStep, len(plan.Steps))
+	for i, s := range plan.Steps {
+		steps[i] = ui.PlanStep{Description: s.Description, Status: s.Status}
+	}
+	r.ui.UpdatePlan(steps)
+
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		r.observe.Log().Warn().Err(err).Msg("failed to encode plan")
+		return
+	}
+	artifact := &store.Artifact{
+		ID:        fmt.Sprintf("art-%s-plan-%d", sessionID, plan.version),
+		SessionID: sessionID,
+		Path:      fmt.Sprintf("artifacts/%s/plan_%d.json", sessionID, plan.version),
+		Type:      "plan",
+		CreatedAt: plan.UpdatedAt,
+	}
+	if err := r.store.SaveArtifact(artifact, data); err != nil {
+		r.observe.Log().Warn().Err(err).Msg("failed to save plan artifact")
+	}
+}
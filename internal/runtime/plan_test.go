@@ -0,0 +1,112 @@
+package runtime
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+func TestPlan(t *testing.T) {
+	content := "Here is my approach.\n```plan\n1. Create the module\n2. Write main.go\n- Run the tests\n```\nStarting now."
+
+	plan := parsePlan(content)
+	if plan == nil || len(plan.Steps) != 3 {
+		t.Fatalf("Expected 3 steps, got %+v", plan)
+	}
+	if plan.Steps[0].Status != StepInProgress || plan.Steps[1].Status != StepPending {
+		t.Errorf("Expected first step in progress, got %+v", plan.Steps)
+	}
+
+	if !plan.apply("Module created. Step 1 done.") {
+		t.Fatal("Expected plan to change")
+	}
+	if plan.Steps[0].Status != StepDone || plan.Steps[1].Status != StepInProgress {
+		t.Errorf("Expected step 2 to be in progress, got %+v", plan.Steps)
+	}
+	if plan.apply("Step 1 is complete, step 9 done") {
+		t.Error("Expected repeated and out-of-range markers to be ignored")
+	}
+
+	remaining := plan.remaining()
+	if strings.Contains(remaining, "Create the module") || !strings.Contains(remaining, "3. Run the tests") {
+		t.Errorf("Unexpected remaining steps:\n%s", remaining)
+	}
+
+	plan.complete()
+	if plan.remaining() != "" {
+		t.Error("Expected no remaining steps after completion")
+	}
+
+	if parsePlan("no plan here") != nil {
+		t.Error("Expected nil plan without a plan block")
+	}
+
+	// Structured outputs answer with the plan as JSON
+	structured := `{"steps": ["Create the module", " ", "Run the tests"], "notes": "Mind the go version."}`
+	if plan := parsePlan(structured); plan == nil || len(plan.Steps) != 2 || plan.Steps[1].Description != "Run the tests" {
+		t.Errorf("Expected 2 steps from the JSON plan, got %+v", plan)
+	}
+	if parsePlan(`{"steps": [], "notes": ""}`) != nil {
+		t.Error("Expected nil plan without steps")
+	}
+	if notes := planNotes(structured); notes != "Mind the go version." {
+		t.Errorf("Expected the JSON plan's notes, got %q", notes)
+	}
+	if notes := planNotes(content); notes != "Here is my approach.\n\nStarting now." {
+		t.Errorf("Expected the text around the plan block, got %q", notes)
+	}
+
+	var none *Plan
+	if none.remaining() != "" {
+		t.Error("Expected nil plan to have no remaining steps")
+	}
+}
+
+func TestParseLessons(t *testing.T) {
+	l := parseLessons(`{"summary": "Built the CLI.", "worked": ["Table tests"], "pitfalls": ["Pin the go version", " "], "commands": ["go vet ./..."]}`)
+	if got := l.String(); got != "Built the CLI.\nWhat worked:\n- Table tests\nPitfalls:\n- Pin the go version\nCommands used:\n- go vet ./..." {
+		t.Errorf("Unexpected rendered summary: %q", got)
+	}
+	if got := parseLessons("Built the CLI; pin the go version."); got.String() != "Built the CLI; pin the go version." || len(got.memories("x", "")) != 1 {
+		t.Errorf("Expected free text to be kept as the summary alone, got %+v", got)
+	}
+	if got := parseLessons(l.String()); got.String() != l.String() {
+		t.Errorf("Expected the sections to read back, got %q", got.String())
+	}
+	sections := parseLessons("Built the CLI.\n\n**What worked:**\n1. Table tests\n\n## Commands used\n- `go vet ./...`\n")
+	if sections.Summary != "Built the CLI." || len(sections.Worked) != 1 || len(sections.Pitfalls) != 0 || sections.Commands[0] != "go vet ./..." {
+		t.Errorf("Expected the markdown sections to be read, got %+v", sections)
+	}
+	if got := parseLessons("```json\n{\"summary\": \"Built the CLI.\", \"worked\": [], \"pitfalls\": [\"x\"], \"commands\": []}\n```"); got.Summary != "Built the CLI." || len(got.Pitfalls) != 1 {
+		t.Errorf("Expected fenced JSON to be read, got %+v", got)
+	}
+
+	memories := l.memories("Build the CLI", "Changed files: main.go")
+	var kinds []string
+	for _, m := range memories {
+		kinds = append(kinds, m.kind)
+	}
+	if strings.Join(kinds, ",") != "summary,worked,pitfalls,commands" {
+		t.Fatalf("Expected a memory per kind, got %v", kinds)
+	}
+	if memories[0].content != "Built the CLI.\nChanged files: main.go" || memories[2].content != "Pitfalls for \"Build the CLI\":\n- Pin the go version" {
+		t.Errorf("Unexpected memories: %+v", memories)
+	}
+}
+
+func TestPastExperiences(t *testing.T) {
+	memories := []store.MemoryItem{
+		{Content: "Pitfalls for \"x\":\n- Pin the go version", Metadata: map[string]string{"session_id": "a", store.MemoryKindKey: store.MemoryKindPitfalls}},
+		{Content: "Built the CLI.", Metadata: map[string]string{"session_id": "a", store.MemoryKindKey: store.MemoryKindSummary}},
+		{Content: "Fixed the parser.", Metadata: map[string]string{"session_id": "prev"}},
+		{Content: "Wrote the docs.", Metadata: map[string]string{"session_id": "b"}},
+	}
+	want := "Relevant past experiences:\n- Built the CLI.\n- Wrote the docs.\n- Pitfalls for \"x\":\n  - Pin the go version\n"
+	if got := pastExperiences(memories, "prev"); got != want {
+		t.Errorf("Expected summaries before lessons without the previous attempt's, got %q", got)
+	}
+	if got := pastExperiences(memories[2:3], "prev"); got != "" {
+		t.Errorf("Expected nothing left, got %q", got)
+	}
+}
@@ -0,0 +1,326 @@
+package runtime
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/coach"
+	"github.com/felixgeelhaar/simon/internal/mcp"
+	"github.com/felixgeelhaar/simon/internal/store"
+	"gopkg.in/yaml.v3"
+)
+
+// Artifact types of a failed session's post-mortem.
+const (
+	// ArtifactPostMortem is the post-mortem itself, as JSON.
+	ArtifactPostMortem = "postmortem"
+	// ArtifactRevisedSpec is the spec the post-mortem recommends running
+	// next, as YAML.
+	ArtifactRevisedSpec = "revised_spec"
+)
+
+// budgetRules are the guard rules that stop a session which ran out of
+// room rather than one that did something wrong.
+var budgetRules = []string{"max_iterations", "max_prompt_tokens", "max_output_tokens", "max_duration", "max_thinking_tokens"}
+
+// PostMortem explains why a session failed and how to run it next time.
+type PostMortem struct {
+	SessionID string `json:"session_id"`
+	Status    string `json:"status"`
+	Goal      string `json:"goal"`
+	// Reason is the error the session stopped with.
+	Reason string `json:"reason"`
+	// Summary is what the agent last reported, or its summary if it left one.
+	Summary string `json:"summary,omitempty"`
+	// Plan is the agent's step plan when it stopped.
+	Plan []PlanStep `json:"plan,omitempty"`
+	// ChangedFiles are the files the session created, modified, or deleted.
+	ChangedFiles []string `json:"changed_files,omitempty"`
+	// Violations are the guard violations reported during the session.
+	Violations []PostMortemViolation `json:"violations,omitempty"`
+	// Unverified are the completion checks that didn't pass the session's
+	// last verification.
+	Unverified []mcp.EvidenceStatus `json:"unverified,omitempty"`
+	// Artifacts are the artifacts worth reading first, by ID or, for verify
+	// command outputs, by path; simon artifact get takes either.
+	Artifacts []string `json:"artifacts,omitempty"`
+	// Recommendations are the changes suggested for the next attempt.
+	Recommendations []string `json:"recommendations"`
+	// RevisedSpec is the ID of the revised spec artifact, empty when the
+	// spec is unknown.
+	RevisedSpec string    `json:"revised_spec,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// PostMortemViolation is a guard violation of a failed session.
+type PostMortemViolation struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Subject  string `json:"subject,omitempty"`
+	Message  string `json:"message"`
+}
+
+// failedSession reports whether a session that ended with err failed, as
+// opposed to completing or being cancelled.
+func failedSession(session *store.Session, err error) bool {
+	if err == nil || errors.Is(err, ErrSessionCancelled) {
+		return false
+	}
+	switch session.Status {
+	case "completed", "cancelled", "interrupted":
+		return false
+	}
+	return true
+}
+
+// recordPostMortem builds the post-mortem of a session that stopped with
+// err and stores it, along with the revised spec, as artifacts. spec is nil
+// when it couldn't be loaded.
+func (r *Runtime) recordPostMortem(session *store.Session, spec *coach.TaskSpec, err error) {
+	pm := r.buildPostMortem(session, spec, err)
+	if spec != nil {
+		revised := reviseSpec(*spec, pm)
+		data, yerr := yaml.Marshal(revised)
+		if yerr == nil {
+			artifact := &store.Artifact{
+				ID:        fmt.Sprintf("art-%s-%s", session.ID, ArtifactRevisedSpec),
+				SessionID: session.ID,
+				Path:      fmt.Sprintf("artifacts/%s/revised_spec.yaml", session.ID),
+				Type:      ArtifactRevisedSpec,
+				CreatedAt: pm.CreatedAt,
+			}
+			if yerr = r.store.SaveArtifact(artifact, data); yerr == nil {
+				pm.RevisedSpec = artifact.ID
+			}
+		}
+		if yerr != nil {
+			r.observe.Log().Warn().Err(yerr).Msg("failed to save revised spec")
+		}
+	}
+	pm.Recommendations = recommend(pm, session.ID)
+
+	data, jerr := json.MarshalIndent(pm, "", "  ")
+	if jerr != nil {
+		r.observe.Log().Warn().Err(jerr).Msg("failed to encode post-mortem")
+		return
+	}
+	artifact := &store.Artifact{
+		ID:        fmt.Sprintf("art-%s-%s", session.ID, ArtifactPostMortem),
+		SessionID: session.ID,
+		Path:      fmt.Sprintf("artifacts/%s/postmortem.json", session.ID),
+		Type:      ArtifactPostMortem,
+		CreatedAt: pm.CreatedAt,
+	}
+	if err := r.store.SaveArtifact(artifact, data); err != nil {
+		r.observe.Log().Warn().Err(err).Msg("failed to save post-mortem")
+		return
+	}
+	r.ui.Log(fmt.Sprintf("🩺 Post-mortem saved; see simon show %s", session.ID))
+}
+
+// buildPostMortem gathers what the store knows about a failed session.
+func (r *Runtime) buildPostMortem(session *store.Session, spec *coach.TaskSpec, err error) *PostMortem {
+	pm := &PostMortem{
+		SessionID: session.ID,
+		Status:    session.Status,
+		Reason:    err.Error(),
+		Summary:   truncateString(r.previousSummary(session.ID), maxPreviousText),
+		CreatedAt: time.Now(),
+	}
+	if spec != nil {
+		pm.Goal = spec.Goal
+	}
+	if pm.Status == "" || pm.Status == "active" || pm.Status == "running" {
+		pm.Status = "failed"
+	}
+
+	if violations, err := r.store.ListViolations(store.ViolationFilter{SessionID: session.ID}); err == nil {
+		for _, v := range violations {
+			pm.Violations = append(pm.Violations, PostMortemViolation{Rule: v.Rule, Severity: v.Severity, Subject: v.Subject, Message: v.Message})
+		}
+	}
+
+	artifacts, _ := r.store.ListArtifacts(session.ID)
+	latest := make(map[string]*store.Artifact)
+	for _, a := range artifacts {
+		latest[a.Type] = a
+	}
+	for _, typ := range []string{ArtifactSpec, "plan", ArtifactVerificationReport, ArtifactFileManifest, ArtifactWorkspaceDiff, ArtifactReasoning} {
+		if a := latest[typ]; a != nil {
+			pm.Artifacts = append(pm.Artifacts, a.ID)
+		}
+	}
+	if a := latest["plan"]; a != nil {
+		if _, data, err := r.store.GetArtifact(a.ID); err == nil {
+			var plan Plan
+			if json.Unmarshal(data, &plan) == nil {
+				pm.Plan = plan.Steps
+			}
+		}
+	}
+	if a := latest[ArtifactFileManifest]; a != nil {
+		if _, data, err := r.store.GetArtifact(a.ID); err == nil {
+			var changes []mcp.FileChange
+			if json.Unmarshal(data, &changes) == nil {
+				for _, c := range changes {
+					pm.ChangedFiles = append(pm.ChangedFiles, c.Path)
+				}
+			}
+		}
+	}
+	if report, err := LatestVerification(r.store, session.ID); err == nil && report != nil {
+		for _, item := range report.Items {
+			if item.Status == mcp.EvidencePass {
+				continue
+			}
+			item.Excerpt = ""
+			pm.Unverified = append(pm.Unverified, item)
+			if item.Output != "" {
+				pm.Artifacts = append(pm.Artifacts, item.Output)
+			}
+		}
+	}
+	return pm
+}
+
+// violated reports whether the post-mortem's session violated one of
+// rules, with a severity other than warn.
+func (pm *PostMortem) violated(rules ...string) bool {
+	for _, v := range pm.Violations {
+		if v.Severity != "warn" && slices.Contains(rules, v.Rule) {
+			return true
+		}
+	}
+	return false
+}
+
+// unfinishedSteps returns the plan steps not done when the session stopped.
+func (pm *PostMortem) unfinishedSteps() []PlanStep {
+	var steps []PlanStep
+	for _, step := range pm.Plan {
+		if step.Status != StepDone {
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+// reviseSpec returns spec changed to avoid the failures of the post-mortem:
+// constraints steering the agent away from blocked commands and files and
+// toward the checks it never passed, and, when it ran out of budget with
+// several plan steps to go, those steps as a mission whose steps each get a
+// budget of their own.
+func reviseSpec(spec coach.TaskSpec, pm *PostMortem) coach.TaskSpec {
+	revised := spec
+	revised.Constraints = slices.Clone(spec.Constraints)
+	add := func(constraint string) {
+		if !slices.Contains(revised.Constraints, constraint) {
+			revised.Constraints = append(revised.Constraints, constraint)
+		}
+	}
+	for _, v := range pm.Violations {
+		if v.Subject == "" || v.Severity == "warn" {
+			continue
+		}
+		switch v.Rule {
+		case "allowed_commands":
+			add(fmt.Sprintf("Do not run `%s`: the policy doesn't allow it", v.Subject))
+		case "allowed_file_globs", "denied_file_globs":
+			add(fmt.Sprintf("Do not read or write %s: it is out of scope", v.Subject))
+		}
+	}
+	for _, item := range pm.Unverified {
+		switch item.Kind {
+		case "evidence":
+			add(fmt.Sprintf("Create %s before claiming completion", item.Item))
+		case "verify":
+			add(fmt.Sprintf("Run `%s` and fix what it reports before claiming completion", item.Item))
+		default:
+			add(fmt.Sprintf("Make sure the %s check %s passes before claiming completion", item.Kind, item.Item))
+		}
+	}
+
+	if remaining := pm.unfinishedSteps(); len(spec.Steps) == 0 && len(remaining) > 1 && pm.violated(budgetRules...) {
+		revised.Steps = make([]coach.Step, len(remaining))
+		for i, step := range remaining {
+			revised.Steps[i] = coach.Step{Goal: step.Description}
+		}
+	}
+	return revised
+}
+
+// recommend lists the changes suggested for the next attempt at the
+// post-mortem's session.
+func recommend(pm *PostMortem, sessionID string) []string {
+	var recs []string
+	seen := make(map[string]bool)
+	var blocked []string
+	for _, v := range pm.Violations {
+		if v.Severity == "warn" {
+			continue
+		}
+		if v.Rule == "allowed_commands" && v.Subject != "" {
+			blocked = append(blocked, v.Subject)
+		}
+		if seen[v.Rule] {
+			continue
+		}
+		seen[v.Rule] = true
+		switch {
+		case slices.Contains(budgetRules, v.Rule):
+			rec := fmt.Sprintf("The session ran out of its %s budget: rerun with a larger --budget", v.Rule)
+			if len(pm.unfinishedSteps()) > 1 {
+				rec += ", or run the revised spec, which splits the unfinished plan steps into steps with budgets of their own"
+			}
+			recs = append(recs, rec)
+		case v.Rule == "max_cost":
+			recs = append(recs, "The session reached its cost cap: raise max_cost or rerun on a cheaper model with --model")
+		case v.Rule == "max_iteration_duration":
+			recs = append(recs, "An iteration took longer than max_iteration_duration: raise it, or split slow commands into faster ones")
+		case v.Rule == "max_verification_retries":
+			recs = append(recs, "Completion claims kept failing verification: the revised spec has the agent run the failing checks itself first; consider escalate in the spec to switch to a stronger model")
+		case v.Rule == "allowed_commands", v.Rule == "max_write_bytes", v.Rule == "max_session_write_bytes":
+		default:
+			recs = append(recs, fmt.Sprintf("The guard stopped %s: %s", v.Rule, v.Message))
+		}
+	}
+	if len(blocked) > 0 {
+		recs = append(recs, fmt.Sprintf("Blocked commands: %s. Add them to allowed_commands in policy.yaml if the task needs them; otherwise the revised spec tells the agent to avoid them", strings.Join(blocked, ", ")))
+	}
+	if len(seen) == 0 && len(pm.Unverified) == 0 {
+		recs = append(recs, "The session stopped on an error, not a guard rule: check the provider configuration (simon models list) and retry, or set escalate in the spec")
+	}
+	if pm.RevisedSpec != "" {
+		recs = append(recs, fmt.Sprintf("Next attempt: simon artifact get %s -o spec.yaml, then simon run spec.yaml --resume %s", pm.RevisedSpec, sessionID))
+	} else {
+		recs = append(recs, fmt.Sprintf("Next attempt: simon run <spec> --resume %s", sessionID))
+	}
+	return recs
+}
+
+// LoadPostMortem returns a session's post-mortem, or nil if it has none.
+func LoadPostMortem(s store.Storage, sessionID string) (*PostMortem, error) {
+	artifacts, err := s.ListArtifacts(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range artifacts {
+		if a.Type != ArtifactPostMortem {
+			continue
+		}
+		_, data, err := s.GetArtifact(a.ID)
+		if err != nil {
+			return nil, err
+		}
+		var pm PostMortem
+		if err := json.Unmarshal(data, &pm); err != nil {
+			return nil, fmt.Errorf("invalid post-mortem %s: %w", a.ID, err)
+		}
+		return &pm, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,117 @@
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/felixgeelhaar/simon/internal/mcp"
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+// MetadataPreviousSession names, in a session's metadata, an earlier session
+// of the same task that this one continues.
+const MetadataPreviousSession = "previous_session"
+
+// maxPreviousText caps each excerpt of the previous attempt in the prompt.
+const maxPreviousText = 2000
+
+// previousAttempt describes an earlier session of the same task for the
+// initial prompt: its outcome, final summary, archived memory, plan state,
+// and changed files. It returns "" when the session can't be loaded.
+func (r *Runtime) previousAttempt(prevID string, memories []store.MemoryItem) string {
+	prev, err := r.store.GetSession(prevID)
+	if err != nil {
+		r.observe.Log().Warn().Str("previous", prevID).Err(err).Msg("failed to load previous session")
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Previous attempt (session %s, ended %s):\n", prev.ID, prev.Status)
+	b.WriteString("This task was attempted before. Continue from where it stopped instead of redoing finished work, " +
+		"but check the current state of the files before relying on it.\n")
+
+	if summary := r.previousSummary(prevID); summary != "" {
+		fmt.Fprintf(&b, "Final state: %s\n", truncateString(summary, maxPreviousText))
+	}
+	if pm, _ := LoadPostMortem(r.store, prevID); pm != nil {
+		fmt.Fprintf(&b, "Why it stopped: %s\n", truncateString(pm.Reason, maxPreviousText))
+	}
+	for _, m := range r.sessionMemories(prevID, memories) {
+		fmt.Fprintf(&b, "Archived memory: %s\n", truncateString(m.Content, maxPreviousText))
+	}
+
+	artifacts, _ := r.store.ListArtifacts(prevID)
+	var plan *Plan
+	planVersion := 0
+	for _, a := range artifacts {
+		switch a.Type {
+		case "plan":
+			v, _ := strconv.Atoi(a.ID[strings.LastIndex(a.ID, "-")+1:])
+			if v < planVersion {
+				continue
+			}
+			if _, data, err := r.store.GetArtifact(a.ID); err == nil {
+				var p Plan
+				if json.Unmarshal(data, &p) == nil {
+					plan, planVersion = &p, v
+				}
+			}
+		case ArtifactFileManifest:
+			if _, data, err := r.store.GetArtifact(a.ID); err == nil {
+				var changes []mcp.FileChange
+				if json.Unmarshal(data, &changes) == nil {
+					fmt.Fprintf(&b, "%s\n", describeChanges(changes))
+				}
+			}
+		}
+	}
+	if plan != nil && len(plan.Steps) > 0 {
+		b.WriteString("Plan when it stopped:\n")
+		for i, step := range plan.Steps {
+			fmt.Fprintf(&b, "%d. [%s] %s\n", i+1, step.Status, step.Description)
+		}
+	}
+	return b.String()
+}
+
+// previousSummary returns a session's summary artifact or, when it has
+// none, its last assistant message.
+func (r *Runtime) previousSummary(sessionID string) string {
+	if _, content, err := r.store.GetArtifact(fmt.Sprintf("art-%s-summary", sessionID)); err == nil {
+		return strings.TrimSpace(string(content))
+	}
+	messages, err := r.store.LoadMessages(sessionID)
+	if err != nil {
+		return ""
+	}
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "assistant" && strings.TrimSpace(messages[i].Content) != "" {
+			return "Last response: " + strings.TrimSpace(messages[i].Content)
+		}
+	}
+	return ""
+}
+
+// sessionMemories returns the memories a session archived, looked up in the
+// store when it supports that and otherwise picked from the retrieved ones,
+// which only hold them when they ranked among the most similar.
+func (r *Runtime) sessionMemories(sessionID string, retrieved []store.MemoryItem) []store.MemoryItem {
+	if lister, ok := r.store.(interface {
+		SessionMemories(sessionID string) ([]store.MemoryItem, error)
+	}); ok {
+		memories, err := lister.SessionMemories(sessionID)
+		if err == nil {
+			return memories
+		}
+		r.observe.Log().Warn().Err(err).Str("previous", sessionID).Msg("failed to load previous session's memories")
+	}
+	var memories []store.MemoryItem
+	for _, m := range retrieved {
+		if m.Metadata["session_id"] == sessionID {
+			memories = append(memories, m)
+		}
+	}
+	return memories
+}
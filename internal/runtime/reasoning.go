@@ -0,0 +1,29 @@
+package runtime
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+// ArtifactReasoning is the artifact type of a response's extended thinking,
+// stored apart from the history so later prompts don't carry it.
+const ArtifactReasoning = "reasoning"
+
+// recordReasoning stores the extended thinking of a response as a text
+// artifact. Resumed sessions count iterations anew, so the name carries the
+// time as well.
+func (r *Runtime) recordReasoning(sessionID string, iteration int, reasoning string) {
+	now := time.Now()
+	artifact := &store.Artifact{
+		ID:        fmt.Sprintf("art-%s-%s-%d-%d", sessionID, ArtifactReasoning, iteration, now.UnixNano()),
+		SessionID: sessionID,
+		Path:      fmt.Sprintf("artifacts/%s/%s_%d_%d.txt", sessionID, ArtifactReasoning, iteration, now.UnixNano()),
+		Type:      ArtifactReasoning,
+		CreatedAt: now,
+	}
+	if err := r.store.SaveArtifact(artifact, []byte(reasoning)); err != nil {
+		r.observe.Log().Warn().Err(err).Msg("failed to save reasoning artifact")
+	}
+}
@@ -2,15 +2,18 @@ package runtime
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/felixgeelhaar/simon/internal/coach"
 	"github.com/felixgeelhaar/simon/internal/guard"
 	"github.com/felixgeelhaar/simon/internal/mcp"
 	"github.com/felixgeelhaar/simon/internal/observe"
+	"github.com/felixgeelhaar/simon/internal/orchestrate"
 	"github.com/felixgeelhaar/simon/internal/provider"
 	"github.com/felixgeelhaar/simon/internal/store"
 	"github.com/felixgeelhaar/simon/internal/ui"
@@ -33,6 +36,30 @@ type Runtime struct {
 	stateManager *StateManager
 	eventBus     *EventBus
 	toolRegistry *ToolRegistry
+
+	// Usage of finished sub-tasks, per parent session
+	subtaskMu    sync.Mutex
+	subtaskUsage map[string]subtaskUsage
+
+	// Pause and steering requests from an interactive UI
+	controlMu sync.Mutex
+	control   control
+
+	// Verify as soon as all evidence exists (SetWatchEvidence)
+	watchEvidence bool
+
+	// Planner and reviewer models (SetOrchestrator)
+	orchestrator *orchestrate.MultiAgentOrchestrator
+
+	// Providers switched to mid-session (SetProviderFactory); memories keep
+	// being embedded by the original provider so their vectors stay comparable
+	providerFactory ProviderFactory
+	providerStops   []func()
+	embedder        provider.Provider
+
+	// Paces the session's chat requests by the policy's
+	// max_requests_per_minute; shared with sub-tasks
+	limiter *guard.RateLimiter
 }
 
 // New creates a new Runtime with the given dependencies.
@@ -43,11 +70,23 @@ func New(s store.Storage, g *guard.Guard, c *coach.Coach, o *observe.Observer, p
 		coach:        c,
 		observe:      o,
 		provider:     p,
+		embedder:     p,
 		mcpProxy:     mp,
 		ui:           ui.SilentUI{},
 		stateManager: NewStateManager(s),
 		eventBus:     NewEventBus(),
 		toolRegistry: NewToolRegistry(),
+		subtaskUsage: make(map[string]subtaskUsage),
+		limiter:      guard.NewRateLimiter(g.Policy().MaxRequestsPerMinute),
+	}
+
+	// Tools registered by callers are held to the same guard as the built-in
+	// ones, and the proxy runs every tool call through the registry
+	r.toolRegistry.SetCheck(r.checkToolCall)
+	if mp != nil {
+		if err := r.toolRegistry.RegisterBuiltins(mp); err == nil {
+			mp.SetTools(r.toolRegistry)
+		}
 	}
 
 	// Set up event handlers for logging
@@ -58,6 +97,15 @@ func New(s store.Storage, g *guard.Guard, c *coach.Coach, o *observe.Observer, p
 
 // setupEventHandlers configures default event handlers for logging and observability.
 func (r *Runtime) setupEventHandlers() {
+	// A panicking handler is logged rather than ending the session
+	r.eventBus.SetPanicHandler(func(e Event, recovered interface{}) {
+		r.observe.Log().Error().
+			Str("event", string(e.Type)).
+			Str("session", e.SessionID).
+			Interface("panic", recovered).
+			Msg("event handler panicked")
+	})
+
 	// Log all events for observability
 	r.eventBus.SubscribeAll(func(e Event) {
 		r.observe.Log().Debug().
@@ -68,6 +116,105 @@ func (r *Runtime) setupEventHandlers() {
 	})
 }
 
+// reportViolation logs a guard violation, surfaces it in the UI, records it
+// for `simon violations`, and publishes it on the event bus.
+func (r *Runtime) reportViolation(sessionID string, v *guard.Violation) {
+	r.observe.Log().Warn().
+		Str("session", sessionID).
+		Str("rule", v.Rule).
+		Str("severity", string(v.Severity)).
+		Msg(v.Message)
+	if v.Severity == guard.SeverityWarn {
+		r.ui.Log(fmt.Sprintf("⚠️  Guard warning (%s): %s", v.Rule, v.Message))
+	} else {
+		r.ui.Log(fmt.Sprintf("🛑 Guard %s (%s): %s", v.Severity, v.Rule, v.Message))
+	}
+	if err := r.store.RecordViolation(&store.ViolationRecord{
+		SessionID: sessionID,
+		Rule:      v.Rule,
+		Severity:  string(v.Severity),
+		Subject:   v.Subject,
+		Message:   v.Message,
+	}); err != nil {
+		r.observe.Log().Warn().Err(err).Msg("failed to record guard violation")
+	}
+	r.eventBus.PublishWithData(EventGuardViolation, sessionID, map[string]interface{}{
+		"rule":     v.Rule,
+		"message":  v.Message,
+		"severity": string(v.Severity),
+	})
+}
+
+// checkToolCall is the check of calls run through the tool registry's
+// Execute: the proxy's, or without one the guard's rules alone. Violations
+// are reported like those of the session's own calls.
+func (r *Runtime) checkToolCall(sessionID string, call provider.ToolCall) error {
+	report := func(v *guard.Violation) { r.reportViolation(sessionID, v) }
+	if r.mcpProxy != nil {
+		return r.mcpProxy.CheckToolCall(sessionID, call, report)
+	}
+	var blocked error
+	for _, v := range r.guard.CheckToolCall(call, guard.SessionState{}) {
+		report(v)
+		switch v.Severity {
+		case guard.SeverityHalt:
+			return &guard.ViolationError{Violation: v}
+		case guard.SeverityBlock:
+			if blocked == nil {
+				blocked = fmt.Errorf("blocked by guard (%s): %s", v.Rule, v.Message)
+			}
+		}
+	}
+	return blocked
+}
+
+// publishOutcome announces how a session ended: session_complete once it
+// reached a final status, session_error when it stopped on an error.
+func (r *Runtime) publishOutcome(session *store.Session, err error) {
+	data := map[string]interface{}{
+		"status":            session.Status,
+		"spec":              session.Metadata["spec"],
+		"prompt_tokens":     session.PromptTokens,
+		"completion_tokens": session.CompletionTokens,
+		"cost":              session.Cost,
+	}
+	if len(session.Tags) > 0 {
+		data["tags"] = session.Tags
+	}
+	if err != nil {
+		data["error"] = err.Error()
+	}
+	if store.SessionFinished(session.Status) && !store.SessionFailed(session.Status) {
+		r.eventBus.PublishWithData(EventSessionComplete, session.ID, data)
+	} else {
+		r.eventBus.PublishWithData(EventSessionError, session.ID, data)
+	}
+}
+
+// SetApprover enables approval mode on the MCP proxy. Each request is
+// published as an approval_requested event before the approver decides.
+func (r *Runtime) SetApprover(a mcp.Approver) {
+	if a == nil {
+		r.mcpProxy.SetApprover(nil)
+		return
+	}
+	r.mcpProxy.SetApprover(mcp.ApproverFunc(func(ctx context.Context, req mcp.ApprovalRequest) bool {
+		data := map[string]interface{}{
+			"tool":       req.Tool,
+			"path":       req.Path,
+			"diff_lines": strings.Count(req.Diff, "\n"),
+		}
+		if req.Command != "" {
+			data["command"] = req.Command
+		}
+		if req.Warning != "" {
+			data["warning"] = req.Warning
+		}
+		r.eventBus.PublishWithData(EventApprovalRequested, req.SessionID, data)
+		return a.Approve(ctx, req)
+	}))
+}
+
 // SetUI sets the UI component for the runtime.
 func (r *Runtime) SetUI(u ui.UI) {
 	if u != nil {
@@ -91,7 +238,7 @@ func (r *Runtime) ToolRegistry() *ToolRegistry {
 }
 
 // ExecuteSession runs the main loop for a session.
-func (r *Runtime) ExecuteSession(ctx context.Context, sessionID string) error {
+func (r *Runtime) ExecuteSession(ctx context.Context, sessionID string) (err error) {
 	ctx, span := r.observe.StartSpan(ctx, "ExecuteSession")
 	defer span.End()
 
@@ -100,15 +247,31 @@ func (r *Runtime) ExecuteSession(ctx context.Context, sessionID string) error {
 		r.observe.Log().Error().Str("sessionID", sessionID).Err(err).Msg("failed to load session")
 		return fmt.Errorf("failed to load session: %w", err)
 	}
+	defer func() { r.publishOutcome(session, err) }()
+	// Deferred before the artifacts are flushed, so it runs after and sees them
+	var spec *coach.TaskSpec
+	defer func() {
+		if failedSession(session, err) {
+			r.recordPostMortem(session, spec, err)
+		}
+	}()
 
-	// Load Spec from metadata
-	specPath, ok := session.Metadata["spec"]
-	if !ok {
-		return fmt.Errorf("session %s has no spec in metadata", sessionID)
-	}
-	spec, err := r.coach.LoadSpec(specPath)
+	spec, err = r.loadSpec(session)
 	if err != nil {
-		return fmt.Errorf("failed to load spec from %s: %w", specPath, err)
+		return err
+	}
+	r.warnNoTools(sessionID, r.provider)
+	// Requests sample with the policy's params, overridden by the spec's
+	params := r.guard.Policy().Params.Merge(spec.Params)
+	r.warnIgnoredParams(sessionID, r.provider, params)
+	ctx = provider.WithParams(ctx, params)
+	if session.Metadata["spec"] != "" {
+		// Snapshot the spec on the first run; a resumed session keeps the original
+		if _, _, err := r.store.GetArtifact(fmt.Sprintf("art-%s-%s", sessionID, ArtifactSpec)); err != nil {
+			if err := r.saveSpec(sessionID, *spec); err != nil {
+				r.observe.Log().Warn().Str("sessionID", sessionID).Err(err).Msg("failed to snapshot spec")
+			}
+		}
 	}
 
 	r.observe.Log().Info().
@@ -116,6 +279,28 @@ func (r *Runtime) ExecuteSession(ctx context.Context, sessionID string) error {
 		Str("goal", spec.Goal).
 		Msg("starting session execution")
 
+	// Memories are retrieved from and archived under the session's project
+	namespace := r.memoryNamespace(session, spec)
+	if session.Metadata == nil {
+		session.Metadata = make(map[string]string)
+	}
+	session.Metadata[MetadataMemoryNamespace] = namespace
+
+	r.mcpProxy.SetScope(sessionID, mcp.Scope{
+		Env:             spec.Env,
+		AllowedCommands: spec.AllowedCommands,
+		DeniedFileGlobs: spec.DeniedFileGlobs,
+		Evidence:        spec.Evidence,
+		Verify:          spec.Verify,
+		Checks:          spec.Checks,
+	})
+
+	// The file change manifest is recorded once, before the completion
+	// summary or on whichever path ends the session
+	workspace := r.snapshotWorkspace()
+	recordChanges := sync.OnceValue(func() []mcp.FileChange { return r.recordChanges(sessionID, workspace) })
+	defer recordChanges()
+
 	// Display mission briefing
 	r.ui.UpdateStatus("Executing Session...")
 	r.ui.Log("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
@@ -123,75 +308,335 @@ func (r *Runtime) ExecuteSession(ctx context.Context, sessionID string) error {
 	r.ui.Log(fmt.Sprintf("  Goal: %s", truncateString(spec.Goal, 60)))
 	r.ui.Log(fmt.Sprintf("  Provider: %s", r.provider.Name()))
 	r.ui.Log(fmt.Sprintf("  Evidence required: %d files", len(spec.Evidence)))
+	if len(spec.Verify) > 0 {
+		r.ui.Log(fmt.Sprintf("  Verify commands: %d", len(spec.Verify)))
+	}
+	if len(spec.Checks) > 0 {
+		r.ui.Log(fmt.Sprintf("  Checks: %d", len(spec.Checks)))
+	}
+	if r.orchestrator != nil {
+		r.ui.Log(fmt.Sprintf("  Planner: %s, Reviewer: %s", roleName(r.orchestrator.Planner), roleName(r.orchestrator.Reviewer)))
+	}
 	r.ui.Log("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 
+	// A provider switched to mid-session lasts for the session only
+	defer r.restoreProvider(r.provider)
+	// Sessions ending mid-iteration still store every tool output, and
+	// their writer stops with them
+	defer func() {
+		if ferr := r.closeArtifacts(sessionID); ferr != nil {
+			r.observe.Log().Warn().Err(ferr).Str("sessionID", sessionID).Msg("failed to store tool outputs")
+		}
+	}()
+	session.Provider = r.provider.Name()
+	session.Model = r.provider.Model()
+	if r.orchestrator != nil {
+		session.Metadata[MetadataPlanner] = roleName(r.orchestrator.Planner)
+		session.Metadata[MetadataReviewer] = roleName(r.orchestrator.Reviewer)
+	}
+
+	if len(spec.Steps) > 0 {
+		return r.executeMission(ctx, session, spec)
+	}
+
+	// `simon cancel` and SIGINT flag the session in the store; the loop stops
+	// at the next checkpoint and in-flight tool calls get a grace period.
+	ctx, cancelRequested, stopWatch := r.watchCancellation(ctx, sessionID)
+	defer stopWatch()
+	evidenceReady := r.startEvidenceWatch(ctx, sessionID, spec.Evidence)
+
 	// State tracking for this run
 	currentIteration := 0
 	totalPromptTokens := 0
 	totalOutputTokens := 0
+	// Extended thinking has a budget of its own
+	totalThinkingTokens := 0
+	// Context management so far, reported with the usage
+	summaries, reclaimedTokens := 0, 0
 	
 	// 0. Retrieve Context (Advanced Context Management)
 	r.ui.Log("🧠 Searching memory for relevant experiences...")
 	var contextContext string
-	if vec, err := r.provider.Embed(ctx, spec.Goal); err == nil {
-		memories, err := r.store.SearchMemory(vec, 3)
-		if err == nil && len(memories) > 0 {
-			var sb strings.Builder
-			sb.WriteString("Relevant past experiences:\n")
-			for _, m := range memories {
-				sb.WriteString(fmt.Sprintf("- %s\n", m.Content))
+	prevID := session.Metadata[MetadataPreviousSession]
+	query := store.MemoryQuery{Text: spec.Goal, Vector: r.memoryVector(ctx, spec.Goal), Limit: memoryRecall, Namespace: namespace}
+	if session.Metadata[MetadataGlobalMemory] == "true" {
+		query.Namespace = ""
+	}
+	memories, searchErr := r.store.QueryMemory(query)
+	switch {
+	case searchErr != nil:
+		r.observe.Log().Warn().Err(searchErr).Msg("failed to search memory")
+		r.ui.Log("   └─ Memory search failed")
+	case len(memories) > 0:
+		contextContext = pastExperiences(memories, prevID)
+		r.observe.Log().Info().Int("count", len(memories)).Msg("retrieved relevant memories")
+		r.ui.Log(fmt.Sprintf("   └─ Found %d relevant memories", len(memories)))
+	default:
+		r.ui.Log("   └─ No prior experiences found")
+	}
+	if prevID != "" {
+		if previous := r.previousAttempt(prevID, memories); previous != "" {
+			contextContext = previous + "\n" + contextContext
+			r.observe.Log().Info().Str("previous", prevID).Msg("continuing from previous session")
+			r.ui.Log(fmt.Sprintf("↩️  Continuing from session %s", prevID))
+		}
+	}
+
+	// pre_run failures don't stop the session; the agent starts by fixing them
+	preRun, err := r.runHooks(ctx, sessionID, coach.HookPreRun, spec.Hooks.PreRun)
+	if err != nil {
+		session.Status = "halted"
+		_ = r.store.UpdateSession(session)
+		return err
+	}
+	if preRun != "" {
+		contextContext += preRun + "\n"
+	}
+
+	// An orchestrated session starts from the planner's plan
+	var plan *Plan
+	instructions := planInstructions
+	if r.orchestrator != nil {
+		if planned := r.planSession(ctx, session, spec, contextContext); planned != nil {
+			totalPromptTokens += planned.Usage.PromptTokens
+			totalOutputTokens += planned.Usage.CompletionTokens
+			if plan = parsePlan(planned.Content); plan != nil {
+				instructions = plannedInstructions(plan, planned.Content)
+				r.ui.Log(fmt.Sprintf("🗺️  Plan: %d steps", len(plan.Steps)))
+				r.recordPlan(sessionID, plan)
+			} else {
+				r.observe.Log().Warn().Msg("planner returned no plan block, the executor plans itself")
 			}
-			contextContext = sb.String()
-			r.observe.Log().Info().Int("count", len(memories)).Msg("retrieved relevant memories")
-			r.ui.Log(fmt.Sprintf("   └─ Found %d relevant memories", len(memories)))
-		} else {
-			r.ui.Log("   └─ No prior experiences found")
 		}
-	} else {
-		// Log warning but continue if embedding fails (e.g. CLI provider)
-		r.observe.Log().Warn().Err(err).Msg("failed to embed goal for context retrieval")
-		r.ui.Log("   └─ Memory search skipped")
 	}
 
 	history := []provider.Message{
-		{Role: "user", Content: fmt.Sprintf("Goal: %s\nDoD: %s\nConstraints: %v\n\n%s\nPlease execute.", spec.Goal, spec.DefinitionOfDone, spec.Constraints, contextContext)},
+		{Role: "user", Content: fmt.Sprintf("Goal: %s\nDoD: %s\nConstraints: %v\n\n%s\n%s\nPlease execute.", spec.Goal, spec.DefinitionOfDone, spec.Constraints, contextContext, instructions)},
 	}
 
+	// Persist the conversation every iteration and on every exit path
+	tr := newTranscript(r.store, sessionID)
+	defer func() { r.flushHistory(tr, history) }()
+
+	// References to the stored outputs of tool results still in the history
+	toolRefs := make(map[string]string)
+
+	// Warn-level budget violations are reported once per rule
+	budgetWarned := make(map[string]bool)
+	var snapshots snapshotDeltas
+
+	// Completion claims that failed verification; with a verification retry
+	// budget they don't count against max_iterations
+	verificationFailures := 0
+	workIterations := func() int {
+		if r.guard.Policy().MaxVerificationRetries > 0 {
+			return currentIteration - verificationFailures
+		}
+		return currentIteration
+	}
+
+	// A spec's escalate switches providers once: after repeated failed
+	// verifications, or at once when a provider call fails for good
+	escalation := providerChoice{name: spec.Escalate.Provider, model: spec.Escalate.Model}
+	escalated := !spec.Escalate.Enabled() || r.providerFactory == nil
+	failedInARow := 0
+	escalateReason := ""
+
+	// Tool usage is recorded with the session, and a tool failing the same
+	// way again and again is pointed out to the agent
+	tools := newToolTracker(session)
+
+	// Constraints are restated every few iterations and after summarization,
+	// which otherwise lets long sessions drift away from them
+	reminder := coach.ConstraintReminder(*spec)
+	remindEvery := spec.ReminderEvery()
+	lastReminder := 1
+
+	// Each iteration's provider and tool calls run under the policy's
+	// per-iteration deadline, capped by what is left of max_duration.
+	started := time.Now()
+	cancelIter := context.CancelFunc(func() {})
+	defer func() { cancelIter() }()
+
 	for {
+		// Time spent paused doesn't count against max_duration
+		started = started.Add(r.waitWhilePaused(ctx, sessionID, cancelRequested))
+		if cancelRequested.Load() {
+			return r.finishCancelled(ctx, session, spec.Goal, history)
+		}
+
 		currentIteration++
 		r.ui.UpdateIteration(currentIteration)
 		iterLog := r.observe.Log().With().Int("iteration", currentIteration).Logger()
 
 		// 1. Guard Check (Pre-Flight)
-		if v := r.guard.CheckBudget(currentIteration, totalPromptTokens, totalOutputTokens); v != nil {
-			iterLog.Warn().Str("violation", v.Rule).Msg("guard violation, stopping")
-			session.Status = "halted"
-			_ = r.store.UpdateSession(session)
-			return fmt.Errorf("guard violation: %s", v.Message)
+		v := r.guard.CheckBudget(workIterations(), totalPromptTokens, totalOutputTokens, time.Since(started))
+		if v == nil {
+			v = r.guard.CheckCost(session.Cost)
+		}
+		if v == nil {
+			v = r.guard.CheckThinking(totalThinkingTokens)
+		}
+		if v != nil {
+			if v.Severity != guard.SeverityWarn {
+				r.reportViolation(sessionID, v)
+				iterLog.Warn().Str("violation", v.Rule).Msg("guard violation, stopping")
+				session.Status = "halted"
+				_ = r.store.UpdateSession(session)
+				return fmt.Errorf("guard violation: %s", v.Message)
+			}
+			if !budgetWarned[v.Rule] {
+				budgetWarned[v.Rule] = true
+				r.reportViolation(sessionID, v)
+			}
+		}
+
+		cancelIter()
+		iterStart := time.Now()
+		var iterCtx context.Context
+		iterCtx, cancelIter = r.iterationContext(ctx, time.Since(started))
+		// timedOut explains a call cut short by a wall-clock limit, or is nil
+		timedOut := func() *guard.Violation {
+			if !errors.Is(iterCtx.Err(), context.DeadlineExceeded) {
+				return nil
+			}
+			if v := r.guard.CheckBudget(workIterations(), totalPromptTokens, totalOutputTokens, time.Since(started)); v != nil {
+				return v
+			}
+			return r.guard.CheckIteration(time.Since(iterStart))
 		}
 
-		// 1.5 Context Management (Summarization)
+		// 1.5 Context Management: older tool outputs shrink to artifact
+		// references first, then the history is summarized
+		before := provider.EstimateMessagesTokens(history)
+		if n := compactHistory(history, toolRefs); n > 0 {
+			after := provider.EstimateMessagesTokens(history)
+			reclaimedTokens += before - after
+			iterLog.Info().Int("results", n).Int("reclaimed_tokens", before-after).Msg("replaced older tool results with artifact references")
+			r.ui.Log(fmt.Sprintf("🗜️  Replaced %d older tool outputs with artifact references", n))
+			r.publishPruned(sessionID, "compacted", before, after)
+			before = after
+		}
 		if len(history) > 20 || totalPromptTokens > 3000 {
-			iterLog.Info().Msg("context limit approaching, summarizing history")
+			iterLog.Info().Int("messages", len(history)).Int("prompt_tokens", totalPromptTokens).Msg("context limit approaching, summarizing history")
 			r.ui.Log("📝 Context limit approaching, summarizing progress...")
-			summary, err := r.summarizeHistory(ctx, history)
+			summary, err := r.summarizeHistory(iterCtx, session, history)
 			if err != nil {
 				iterLog.Error().Err(err).Msg("failed to summarize, continuing without pruning")
 			} else {
 				newHistory := []provider.Message{
 					{
 						Role:    "user",
-						Content: fmt.Sprintf("Goal: %s\nDoD: %s\nConstraints: %v\n\nProgress Summary: %s\n\nPlease continue execution.", spec.Goal, spec.DefinitionOfDone, spec.Constraints, summary),
+						Content: fmt.Sprintf("Goal: %s\nDoD: %s\nConstraints: %v\n\nProgress Summary: %s\n\n%sPlease continue execution.", spec.Goal, spec.DefinitionOfDone, spec.Constraints, summary, plan.remaining()),
 					},
 				}
+				r.flushHistory(tr, history)
 				history = newHistory
+				tr.reset()
+				clear(toolRefs)
+				if reminder != "" {
+					history = append(history, provider.Message{Role: "user", Content: reminder})
+					lastReminder = currentIteration
+				}
+				after := provider.EstimateMessagesTokens(history)
+				summaries++
+				reclaimedTokens += before - after
+				iterLog.Info().Int("reclaimed_tokens", before-after).Msg("summarized history")
+				r.publishPruned(sessionID, "summarized", before, after)
 				r.ui.Log("   └─ Context compressed, continuing...")
 			}
 		}
 
+		if reminder != "" && remindEvery > 0 && currentIteration-lastReminder >= remindEvery {
+			iterLog.Debug().Msg("re-injecting constraints")
+			r.ui.Log("📌 Restating constraints")
+			history = append(history, provider.Message{Role: "user", Content: reminder})
+			lastReminder = currentIteration
+		}
+
+		r.takeStoredSteering(sessionID)
+		for _, msg := range r.steeringMessages() {
+			iterLog.Info().Str("text", msg.Content).Msg("user steering")
+			r.ui.Log("🧭 " + msg.Content)
+			r.eventBus.PublishSimple(EventSteering, sessionID)
+			history = append(history, msg)
+		}
+
+		// Switch providers between iterations, on request or to escalate
+		switchTo, reason := r.takeSwitch(), "requested by the user"
+		if switchTo == nil && escalateReason != "" {
+			switchTo, reason = &escalation, escalateReason
+			escalateReason = ""
+		}
+		if switchTo != nil {
+			if switched, err := r.switchProvider(session, history, toolRefs, *switchTo, reason); err != nil {
+				iterLog.Warn().Err(err).Msg("provider switch failed, continuing on the current provider")
+				r.ui.Log(fmt.Sprintf("⚠️  Provider switch failed: %v", err))
+			} else {
+				history = switched
+				r.warnIgnoredParams(sessionID, r.provider, params)
+			}
+		}
+
+		// Reject a request that would exceed the prompt budget before sending it
+		promptSize, err := r.provider.CountTokens(r.withTools(iterCtx), history)
+		if err != nil {
+			iterLog.Debug().Err(err).Msg("token count failed, estimating")
+			promptSize = provider.EstimateMessagesTokens(history)
+		}
+		contextWindow := provider.CapabilitiesOf(r.provider).ContextWindow
+		r.publishContextUsage(sessionID, currentIteration, promptSize, contextWindow)
+		if v := r.guard.CheckPromptSize(totalPromptTokens, promptSize); v != nil {
+			if v.Severity != guard.SeverityWarn {
+				r.reportViolation(sessionID, v)
+				iterLog.Warn().Str("violation", v.Rule).Int("prompt_tokens", promptSize).Msg("guard violation, stopping")
+				session.Status = "halted"
+				_ = r.store.UpdateSession(session)
+				return fmt.Errorf("guard violation: %s", v.Message)
+			}
+			if !budgetWarned[v.Rule] {
+				budgetWarned[v.Rule] = true
+				r.reportViolation(sessionID, v)
+			}
+		}
+
 		// 2. Execute
 		r.ui.Log(fmt.Sprintf("⏳ [%d/%d] Thinking...", currentIteration, r.guard.Policy().MaxIterations))
-		resp, err := r.provider.Chat(ctx, history)
+		snap := &store.Snapshot{
+			SessionID:     sessionID,
+			Iteration:     currentIteration,
+			Status:        session.Status,
+			PromptTokens:  totalPromptTokens,
+			OutputTokens:  totalOutputTokens,
+			ContextTokens: promptSize,
+		}
+		resp, err := r.chat(iterCtx, sessionID, history)
+		if v := timedOut(); err != nil && v != nil {
+			err = &guard.ViolationError{Violation: v}
+			r.reportViolation(sessionID, v)
+		}
+		r.saveSnapshot(&snapshots, snap, history, resp, err)
+		if guard.IsHalt(err) {
+			iterLog.Warn().Err(err).Msg("guard violation, stopping")
+			session.Status = "halted"
+			_ = r.store.UpdateSession(session)
+			return err
+		}
+		if err != nil && cancelRequested.Load() {
+			return r.finishCancelled(ctx, session, spec.Goal, history)
+		}
+		if err != nil && !escalated {
+			// The next iteration retries the call on the escalation provider
+			escalated = true
+			iterLog.Warn().Err(err).Msg("provider call failed, escalating")
+			switched, serr := r.switchProvider(session, history, toolRefs, escalation, "escalated after a failed provider call")
+			if serr == nil {
+				history = switched
+				r.warnIgnoredParams(sessionID, r.provider, params)
+				continue
+			}
+			iterLog.Warn().Err(serr).Msg("provider switch failed")
+		}
 		if err != nil {
 			iterLog.Error().Err(err).Msg("provider call failed")
 			return err
@@ -199,7 +644,39 @@ func (r *Runtime) ExecuteSession(ctx context.Context, sessionID string) error {
 
 		// 3. Update Usage
 		totalPromptTokens += resp.Usage.PromptTokens
-		totalOutputTokens += resp.Usage.CompletionTokens
+		totalOutputTokens += resp.Usage.CompletionTokens - resp.Usage.ThinkingTokens
+		totalThinkingTokens += resp.Usage.ThinkingTokens
+		r.recordUsage(session, resp.Usage)
+		if resp.Reasoning != "" {
+			r.recordReasoning(sessionID, currentIteration, resp.Reasoning)
+		}
+		if reporter, ok := r.ui.(ui.UsageReporter); ok {
+			policy := r.guard.Policy()
+			reporter.UpdateUsage(ui.Usage{
+				Iteration:       currentIteration,
+				PromptTokens:    totalPromptTokens,
+				MaxPromptTokens: policy.MaxPromptTokens,
+				OutputTokens:    totalOutputTokens,
+				MaxOutputTokens: policy.MaxOutputTokens,
+				Cost:            session.Cost,
+				MaxCost:         policy.MaxCost,
+				ContextTokens:   promptSize,
+				ContextWindow:   contextWindow,
+				Summaries:       summaries,
+				ReclaimedTokens: reclaimedTokens,
+			})
+		}
+
+		// Track the step plan: parsed from the first response, updated from "Step N done" markers
+		if plan == nil && currentIteration == 1 {
+			if plan = parsePlan(resp.Content); plan != nil {
+				plan.apply(resp.Content)
+				r.ui.Log(fmt.Sprintf("🗺️  Plan: %d steps", len(plan.Steps)))
+				r.recordPlan(sessionID, plan)
+			}
+		} else if plan != nil && plan.apply(resp.Content) {
+			r.recordPlan(sessionID, plan)
+		}
 
 		// Show a preview of what the agent is thinking/doing
 		if resp.Content != "" {
@@ -214,6 +691,7 @@ func (r *Runtime) ExecuteSession(ctx context.Context, sessionID string) error {
 			Content:   resp.Content,
 			ToolCalls: resp.ToolCalls,
 		})
+		tr.noteUsage(len(history)-1, resp.Usage)
 
 		// 4. Process Tools
 		if len(resp.ToolCalls) > 0 {
@@ -224,7 +702,40 @@ func (r *Runtime) ExecuteSession(ctx context.Context, sessionID string) error {
 			}
 			r.ui.Log(fmt.Sprintf("🔧 Executing: %s", strings.Join(toolNames, ", ")))
 
-			results, err := r.mcpProxy.HandleToolCalls(ctx, sessionID, resp.ToolCalls)
+			results, err := r.mcpProxy.HandleToolCalls(iterCtx, sessionID, resp.ToolCalls)
+			r.absorbSubtasks(session)
+			var repeated []string
+			for i, res := range results {
+				for _, v := range res.Violations {
+					r.reportViolation(sessionID, v)
+				}
+				label := toolLabel(resp.ToolCalls[i])
+				r.eventBus.PublishWithData(EventToolCallEnd, sessionID, map[string]interface{}{
+					"tool":        res.Name,
+					"label":       label,
+					"duration_ms": res.Duration.Milliseconds(),
+					"error":       res.IsError,
+					"failed":      res.Failed,
+				})
+				if msg := tools.record(label, res); msg != "" {
+					iterLog.Warn().Str("tool", label).Str("reason", res.Reason).Msg("tool keeps failing the same way")
+					r.ui.Log(fmt.Sprintf("🔁 %s keeps failing the same way, telling the agent", label))
+					repeated = append(repeated, msg)
+				}
+			}
+			tools.save(session)
+			if guard.IsHalt(err) {
+				var ve *guard.ViolationError
+				errors.As(err, &ve)
+				r.reportViolation(sessionID, ve.Violation)
+				iterLog.Warn().Str("violation", ve.Violation.Rule).Msg("guard violation, stopping")
+				session.Status = "halted"
+				_ = r.store.UpdateSession(session)
+				return err
+			}
+			if err != nil && cancelRequested.Load() {
+				return r.finishCancelled(ctx, session, spec.Goal, history[:len(history)-1])
+			}
 			if err != nil {
 				iterLog.Error().Err(err).Msg("mcp proxy failed")
 				return err
@@ -239,6 +750,23 @@ func (r *Runtime) ExecuteSession(ctx context.Context, sessionID string) error {
 					Content:    res.Digest,
 					ToolCallID: res.ToolCallID,
 				})
+				if res.Ref != "" {
+					toolRefs[res.ToolCallID] = res.Ref
+				}
+			}
+			if len(repeated) > 0 {
+				history = append(history, provider.Message{Role: "user", Content: strings.Join(repeated, "\n\n")})
+			}
+
+			failed, err := r.runHooks(iterCtx, sessionID, coach.HookPostIteration, spec.Hooks.PostIteration)
+			if err != nil {
+				iterLog.Warn().Err(err).Msg("guard violation, stopping")
+				session.Status = "halted"
+				_ = r.store.UpdateSession(session)
+				return err
+			}
+			if failed != "" {
+				history = append(history, provider.Message{Role: "user", Content: failed + "\nPlease fix this before continuing."})
 			}
 		}
 
@@ -250,46 +778,112 @@ func (r *Runtime) ExecuteSession(ctx context.Context, sessionID string) error {
 			strings.Contains(strings.ToLower(resp.Content), "i have finished") ||
 			strings.Contains(strings.ToLower(resp.Content), "done"))
 
-		if isDoneHint {
-			iterLog.Info().Msg("completion suggested, verifying evidence")
+		// The evidence watcher triggers one verification without a completion claim
+		earlyCheck := evidenceReady.CompareAndSwap(true, false)
+
+		if isDoneHint || earlyCheck {
+			if isDoneHint {
+				iterLog.Info().Msg("completion suggested, verifying evidence")
+			} else {
+				iterLog.Info().Msg("all evidence exists, verifying early")
+			}
 			r.ui.Log("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 			r.ui.Log("🔍 Verifying Evidence...")
 			for _, e := range spec.Evidence {
 				r.ui.Log(fmt.Sprintf("   • Checking: %s", e))
 			}
 
-			if err := r.verifyEvidence(ctx, spec); err != nil {
+			// An orchestrated session's verified work must pass review before
+			// on_complete hooks run as part of completing; a hook failure is
+			// retried like verification
+			err := r.verifyEvidence(iterCtx, sessionID, spec)
+			var review *orchestrate.Review
+			if err == nil && r.orchestrator != nil {
+				// A failed review leaves the verified work unreviewed rather
+				// than failing the session
+				if review, err = r.reviewWork(iterCtx, session, spec, resp.Content, r.workspaceChanges(sessionID, workspace)); err != nil {
+					iterLog.Warn().Err(err).Msg("review failed, accepting the verified work")
+					r.ui.Log("   └─ Review failed, accepting the verified work")
+					review, err = nil, nil
+				} else {
+					totalPromptTokens += review.Usage.PromptTokens
+					totalOutputTokens += review.Usage.CompletionTokens
+				}
+			}
+			var hookFailed string
+			if err == nil && (review == nil || review.Approved) {
+				if hookFailed, err = r.runHooks(iterCtx, sessionID, coach.HookOnComplete, spec.Hooks.OnComplete); err == nil && hookFailed != "" {
+					err = errors.New(hookFailed)
+				}
+			}
+			if guard.IsHalt(err) {
+				iterLog.Warn().Err(err).Msg("guard violation, stopping")
+				session.Status = "halted"
+				_ = r.store.UpdateSession(session)
+				return err
+			} else if err != nil {
 				iterLog.Warn().Err(err).Msg("verification failed")
-				r.ui.Log(fmt.Sprintf("❌ Verification failed: %s", err.Error()))
+				r.eventBus.PublishWithData(EventVerificationFail, sessionID, map[string]interface{}{"error": err.Error()})
+				r.ui.Log(fmt.Sprintf("❌ Verification failed: %s", firstLine(err.Error())))
+				verificationFailures++
+				if v := r.guard.CheckVerificationRetries(verificationFailures); v != nil {
+					if v.Severity != guard.SeverityWarn {
+						r.reportViolation(sessionID, v)
+						iterLog.Warn().Str("violation", v.Rule).Msg("verification retries exhausted, stopping")
+						return r.finishVerificationExhausted(session, v)
+					}
+					if !budgetWarned[v.Rule] {
+						budgetWarned[v.Rule] = true
+						r.reportViolation(sessionID, v)
+					}
+				}
+				if failedInARow++; !escalated && failedInARow >= spec.Escalate.Threshold() {
+					escalated = true
+					escalateReason = fmt.Sprintf("escalated after %d failed verifications", failedInARow)
+				}
 				r.ui.Log("   └─ Agent will retry...")
-				history = append(history, provider.Message{
-					Role:    "user",
-					Content: fmt.Sprintf("Verification failed: %v. Please correct and ensure the Evidence is present.", err),
-				})
+				content := fmt.Sprintf("Verification failed: %v\nPlease correct this and ensure the Evidence is present. Call verify_evidence to check every item before claiming completion again.\n%s", err, plan.remaining())
+				if hookFailed != "" {
+					content = fmt.Sprintf("%s\nThe definition of done is met; please fix this and claim completion again.\n%s", hookFailed, plan.remaining())
+				}
+				history = append(history, provider.Message{Role: "user", Content: strings.TrimSpace(content)})
+				session.Status = "running"
+			} else if review != nil && !review.Approved {
+				failedInARow = 0
+				iterLog.Info().Msg("reviewer requested changes")
+				r.ui.Log(fmt.Sprintf("🔎 Reviewer requested changes: %s", truncateString(review.Feedback, 60)))
+				r.ui.Log("   └─ Agent will revise...")
+				content := fmt.Sprintf("The evidence is verified, but a reviewer rejected the work:\n%s\nPlease address every point and claim completion again.\n%s", review.Feedback, plan.remaining())
+				history = append(history, provider.Message{Role: "user", Content: strings.TrimSpace(content)})
 				session.Status = "running"
 			} else {
 				iterLog.Info().Msg("verification successful")
+				r.eventBus.PublishSimple(EventVerificationPass, sessionID)
 				r.ui.Log("✅ All evidence verified!")
 				r.ui.Log("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 				r.ui.Log("📦 Archiving session memory...")
 				session.Status = "completed"
+				if plan != nil && plan.complete() {
+					r.recordPlan(sessionID, plan)
+				}
 				r.ui.UpdateStatus("Completed")
 				_ = r.store.UpdateSession(session)
 
+				changed := describeChanges(recordChanges())
+
 				// 6. Archive Memory
 				summaryReq := append(history, provider.Message{
 					Role:    "user",
-					Content: "The task is complete. Provide a 1-sentence summary of what was built and key lessons learned for future reference.",
+					Content: lessonsPrompt,
 				})
-				if summaryResp, err := r.provider.Chat(ctx, summaryReq); err == nil {
-					if vec, err := r.provider.Embed(ctx, spec.Goal); err == nil {
-						meta := map[string]string{"session_id": sessionID, "goal": spec.Goal}
-						if err := r.store.AddMemory(summaryResp.Content, vec, meta); err != nil {
-							r.observe.Log().Warn().Err(err).Msg("failed to archive memory")
-						} else {
-							r.observe.Log().Info().Msg("archived session memory")
-							r.ui.Log("✨ Session archived for future reference")
-						}
+				if summaryResp, err := r.chat(provider.WithPhase(provider.WithResponseFormat(ctx, summaryFormat), provider.PhaseSummary), sessionID, summaryReq); err == nil {
+					r.recordUsage(session, summaryResp.Usage)
+					_ = r.store.UpdateSession(session)
+					learned := parseLessons(summaryResp.Content)
+					r.saveSummary(sessionID, withToolUsage(learned.String(), session))
+					if archived := r.archiveLessons(ctx, sessionID, spec.Goal, namespace, learned, changed); archived > 0 {
+						r.observe.Log().Info().Int("memories", archived).Msg("archived session memory")
+						r.ui.Log("✨ Session archived for future reference")
 					}
 				}
 				r.ui.Log("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
@@ -299,7 +893,34 @@ func (r *Runtime) ExecuteSession(ctx context.Context, sessionID string) error {
 		} else {
 			session.Status = "running"
 		}
-		
+
+		// Tool calls and verification absorb timeouts into their output, so a
+		// cut-short iteration is detected here rather than at the call site.
+		v = timedOut()
+		if v == nil {
+			v = r.guard.CheckIteration(time.Since(iterStart))
+		}
+		if v != nil {
+			if v.Severity != guard.SeverityWarn {
+				r.reportViolation(sessionID, v)
+				iterLog.Warn().Str("violation", v.Rule).Msg("guard violation, stopping")
+				session.Status = "halted"
+				_ = r.store.UpdateSession(session)
+				return fmt.Errorf("guard violation: %s", v.Message)
+			}
+			if !budgetWarned[v.Rule] {
+				budgetWarned[v.Rule] = true
+				r.reportViolation(sessionID, v)
+			}
+		}
+
+		// Tool outputs are written in the background; the history referring
+		// to them is persisted once they are stored
+		if err := r.flushArtifacts(sessionID); err != nil {
+			iterLog.Error().Err(err).Msg("failed to store tool outputs")
+			return err
+		}
+		r.flushHistory(tr, history)
 		if err := r.store.UpdateSession(session); err != nil {
 			return err
 		}
@@ -308,7 +929,77 @@ func (r *Runtime) ExecuteSession(ctx context.Context, sessionID string) error {
 	return nil
 }
 
-func (r *Runtime) summarizeHistory(ctx context.Context, history []provider.Message) (string, error) {
+// flushArtifacts waits until the tool outputs of the session's iteration
+// are stored.
+func (r *Runtime) flushArtifacts(sessionID string) error {
+	if r.mcpProxy == nil {
+		return nil
+	}
+	return r.mcpProxy.FlushArtifacts(sessionID)
+}
+
+// closeArtifacts stores the session's remaining tool outputs when it ends
+// and stops their writer.
+func (r *Runtime) closeArtifacts(sessionID string) error {
+	if r.mcpProxy == nil {
+		return nil
+	}
+	return r.mcpProxy.CloseArtifacts(sessionID)
+}
+
+// flushHistory persists new history entries; failures are logged, not fatal.
+func (r *Runtime) flushHistory(tr *transcript, history []provider.Message) {
+	if err := tr.flush(history); err != nil {
+		r.observe.Log().Warn().Err(err).Str("sessionID", tr.sessionID).Msg("failed to persist history")
+	}
+}
+
+// iterationContext bounds one iteration by the guard's deadline, if any.
+func (r *Runtime) iterationContext(ctx context.Context, elapsed time.Duration) (context.Context, context.CancelFunc) {
+	if timeout := r.guard.IterationTimeout(elapsed); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return ctx, func() {}
+}
+
+// chat sends messages to the provider, waiting first if the policy's request
+// rate is exceeded. Throttling is reported as a guard violation; severities
+// above warn stop the call instead of waiting.
+func (r *Runtime) chat(ctx context.Context, sessionID string, messages []provider.Message) (*provider.Response, error) {
+	p := r.provider
+	if r.limiter != nil {
+		p = provider.WithThrottledRateLimit(r.limiter, func(wait time.Duration) error {
+			v := r.guard.CheckThrottle(wait)
+			r.reportViolation(sessionID, v)
+			if v.Severity != guard.SeverityWarn {
+				return &guard.ViolationError{Violation: v}
+			}
+			return nil
+		})(p)
+	}
+	return p.Chat(r.withTools(ctx), messages)
+}
+
+// withTools attaches the registered tools to a provider request context,
+// so the model is offered every tool the registry can execute.
+func (r *Runtime) withTools(ctx context.Context) context.Context {
+	return provider.WithTools(ctx, r.toolRegistry.Specs())
+}
+
+// recordUsage adds a provider response's usage and estimated cost to the session totals.
+func (r *Runtime) recordUsage(session *store.Session, u provider.Usage) {
+	r.recordUsageOf(session, r.provider, u)
+}
+
+// recordUsageOf is recordUsage for a response of another provider, priced
+// by that provider's model.
+func (r *Runtime) recordUsageOf(session *store.Session, p provider.Provider, u provider.Usage) {
+	session.PromptTokens += u.PromptTokens
+	session.CompletionTokens += u.CompletionTokens
+	session.Cost += provider.EstimateCost(p.Name(), p.Model(), u)
+}
+
+func (r *Runtime) summarizeHistory(ctx context.Context, session *store.Session, history []provider.Message) (string, error) {
 	summaryReq := []provider.Message{}
 	summaryReq = append(summaryReq, history...)
 	summaryReq = append(summaryReq, provider.Message{
@@ -316,21 +1007,92 @@ func (r *Runtime) summarizeHistory(ctx context.Context, history []provider.Messa
 		Content: "Summarize the actions taken so far, the current state of the system, and what remains to be done. Be concise.",
 	})
 
-	resp, err := r.provider.Chat(ctx, summaryReq)
+	resp, err := r.chat(provider.WithPhase(ctx, provider.PhaseSummary), session.ID, summaryReq)
 	if err != nil {
 		return "", err
 	}
+	r.recordUsage(session, resp.Usage)
 
 	return resp.Content, nil
 }
 
-func (r *Runtime) verifyEvidence(ctx context.Context, spec *coach.TaskSpec) error {
-	for _, e := range spec.Evidence {
-		if _, err := os.Stat(e); os.IsNotExist(err) {
-			return fmt.Errorf("missing evidence: %s", e)
+// verifyEvidence runs the spec's completion checks itself rather than
+// trusting the agent's claims: evidence files, then verify commands, then
+// the spec's checks. Once a check fails, the rest are skipped except for
+// evidence files. Failures carry an excerpt of the command output for the
+// corrective prompt. The outcome of every item is recorded as a verification
+// report (see LatestVerification).
+func (r *Runtime) verifyEvidence(ctx context.Context, sessionID string, spec *coach.TaskSpec) error {
+	report := &mcp.EvidenceReport{Passed: true, Items: []mcp.EvidenceStatus{}}
+	defer r.recordVerification(sessionID, report)
+
+	var failure error
+	for _, check := range spec.CompletionChecks() {
+		if failure != nil && check.Type != coach.CheckFile {
+			report.Add(mcp.EvidenceStatus{Kind: mcp.CheckKind(check.Type), Item: check.Target, Status: mcp.EvidenceSkipped})
+			continue
+		}
+		if check.Type != coach.CheckFile {
+			r.ui.Log(fmt.Sprintf("   • Running: %s", check.Target))
+		}
+		item, err := r.mcpProxy.RunCheck(ctx, sessionID, check, func(v *guard.Violation) {
+			r.reportViolation(sessionID, v)
+		})
+		report.Add(item)
+		if guard.IsHalt(err) {
+			return err
+		}
+		if failure == nil {
+			failure = checkFailure(check, item)
 		}
 	}
-	return nil
+	return failure
+}
+
+// checkFailure describes a check that did not pass, nil if it did.
+func checkFailure(check coach.Check, item mcp.EvidenceStatus) error {
+	switch {
+	case item.Status == mcp.EvidencePass:
+		return nil
+	case check.Type == coach.CheckFile && item.Status == mcp.EvidenceFail:
+		return fmt.Errorf("missing evidence: %s", check.Target)
+	case check.Type == coach.CheckCommand && item.Status == mcp.EvidenceFail:
+		return fmt.Errorf("verification command %q failed (output at %s):\n%s", check.Target, item.Output, item.Excerpt)
+	case check.Type == coach.CheckCommand:
+		return fmt.Errorf("verification command %q could not run: %s", check.Target, item.Detail)
+	default:
+		return fmt.Errorf("%s check %q %s: %s", check.Type, check.Target, item.Status, item.Detail)
+	}
+}
+
+// firstLine returns s up to its first newline.
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// toolLabel names a tool call for reporting: shell calls are labelled with
+// the first two words of their command (e.g. "run_shell: go test").
+func toolLabel(call provider.ToolCall) string {
+	if call.Name != "run_shell" {
+		return call.Name
+	}
+	var args struct {
+		Cmd string `json:"cmd"`
+	}
+	if err := json.Unmarshal([]byte(call.Args), &args); err != nil {
+		return call.Name
+	}
+	fields := strings.Fields(args.Cmd)
+	if len(fields) == 0 {
+		return call.Name
+	}
+	if len(fields) > 2 {
+		fields = fields[:2]
+	}
+	return call.Name + ": " + strings.Join(fields, " ")
 }
 
 // truncateString shortens a string to maxLen characters, adding "..." if truncated
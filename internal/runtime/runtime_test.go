@@ -2,8 +2,14 @@ package runtime
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -11,6 +17,7 @@ import (
 	"github.com/felixgeelhaar/simon/internal/guard"
 	"github.com/felixgeelhaar/simon/internal/mcp"
 	"github.com/felixgeelhaar/simon/internal/observe"
+	"github.com/felixgeelhaar/simon/internal/orchestrate"
 	"github.com/felixgeelhaar/simon/internal/provider"
 	"github.com/felixgeelhaar/simon/internal/store"
 )
@@ -41,6 +48,9 @@ t.Run("Successful Run", func(t *testing.T) {
 		}
 		s.CreateSession(session)
 
+		var outcome []Event
+		r.EventBus().Subscribe(EventSessionComplete, func(e Event) { outcome = append(outcome, e) })
+
 		if err := r.ExecuteSession(context.Background(), "sess-success"); err != nil {
 			t.Fatalf("ExecuteSession failed: %v", err)
 		}
@@ -49,6 +59,55 @@ t.Run("Successful Run", func(t *testing.T) {
 		if updated.Status != "completed" {
 			t.Errorf("Expected status 'completed', got '%s'", updated.Status)
 		}
+		if len(outcome) != 1 || outcome[0].Data["status"] != "completed" {
+			t.Errorf("Expected one session_complete event, got %+v", outcome)
+		}
+		if _, _, err := s.GetArtifact("art-sess-success-changes"); err != nil {
+			t.Errorf("Expected a file change manifest: %v", err)
+		}
+		if pm, _ := LoadPostMortem(s, "sess-success"); pm != nil {
+			t.Errorf("Expected no post-mortem of a completed session, got %+v", pm)
+		}
+		if stats := ToolStats(updated); len(stats) == 0 || stats[0].Calls == 0 {
+			t.Errorf("Expected the session's tool usage, got %+v", stats)
+		}
+
+		messages, err := s.LoadMessages("sess-success")
+		if err != nil {
+			t.Fatalf("LoadMessages failed: %v", err)
+		}
+		if len(messages) < 3 || messages[0].Role != "user" || messages[1].Role != "assistant" {
+			t.Fatalf("Expected persisted conversation, got %d messages", len(messages))
+		}
+		if messages[1].PromptTokens == 0 {
+			t.Error("Expected token counts on assistant messages")
+		}
+		history, err := r.LoadHistory("sess-success")
+		if err != nil {
+			t.Fatalf("LoadHistory failed: %v", err)
+		}
+		var calls, results int
+		for _, m := range history {
+			calls += len(m.ToolCalls)
+			if m.Role == "tool" && m.ToolCallID != "" {
+				results++
+			}
+		}
+		if calls == 0 || calls != results {
+			t.Errorf("Expected tool calls and their results in history, got %d calls, %d results", calls, results)
+		}
+
+		snaps, _ := s.ListSnapshots("sess-success")
+		if len(snaps) < 2 || snaps[0].Iteration != 1 {
+			t.Fatalf("Expected a snapshot per iteration, got %+v", snaps)
+		}
+		second, err := LoadSnapshot(s, "sess-success", 2)
+		if err != nil {
+			t.Fatalf("LoadSnapshot failed: %v", err)
+		}
+		if len(second.Messages) < 2 || second.Messages[1].Role != "assistant" || second.Response == nil || second.PromptTokens != 100 {
+			t.Errorf("Expected iteration 2 to show the first exchange and its own response, got %+v", second)
+		}
 	})
 
 	t.Run("Verification Failure then Success", func(t *testing.T) {
@@ -94,11 +153,64 @@ t.Run("Successful Run", func(t *testing.T) {
 		}
 	})
 
+	t.Run("Verify Commands", func(t *testing.T) {
+		marker := filepath.Join(tmpDir, "verified.txt")
+		specPath := filepath.Join(tmpDir, "spec_verify_cmd.yaml")
+		os.WriteFile(specPath, []byte("goal: test\nverify: [\"ls "+marker+"\"]"), 0600)
+
+		p := &provider.StubProvider{
+			Responses: []provider.Response{
+				{Content: "Task complete.", Usage: provider.Usage{TotalTokens: 10}},
+				{Content: "Fixed it. Task complete.", Usage: provider.Usage{TotalTokens: 10}},
+			},
+		}
+		mp := mcp.NewProxy(s, g)
+		r := New(s, g, c, o, p, mp)
+
+		var failures []string
+		r.EventBus().Subscribe(EventVerificationFail, func(e Event) {
+			failures = append(failures, e.Data["error"].(string))
+			os.WriteFile(marker, []byte("ok"), 0600)
+		})
+
+		s.CreateSession(&store.Session{
+			ID:        "sess-verify-cmd",
+			CreatedAt: time.Now(),
+			Status:    "active",
+			Metadata:  map[string]string{"spec": specPath},
+		})
+		if err := r.ExecuteSession(context.Background(), "sess-verify-cmd"); err != nil {
+			t.Fatalf("ExecuteSession failed: %v", err)
+		}
+
+		if len(failures) != 1 || !strings.Contains(failures[0], "No such file") {
+			t.Errorf("Expected one failure with the command output excerpt, got %q", failures)
+		}
+		verifications := 0
+		artifacts, _ := s.ListArtifacts("sess-verify-cmd")
+		for _, a := range artifacts {
+			if a.Type == "verification" {
+				verifications++
+			}
+		}
+		if verifications != 2 {
+			t.Errorf("Expected 2 verification artifacts, got %d", verifications)
+		}
+
+		report, err := LatestVerification(s, "sess-verify-cmd")
+		if err != nil || report == nil {
+			t.Fatalf("Expected a verification report, got %v, %v", report, err)
+		}
+		if !report.Passed || len(report.Items) != 1 || report.Items[0].Status != mcp.EvidencePass {
+			t.Errorf("Expected the last verification to have passed, got %+v", report)
+		}
+	})
+
 	t.Run("Guard Violation", func(t *testing.T) {
 		specPath := filepath.Join(tmpDir, "spec_guard.yaml")
 		os.WriteFile(specPath, []byte("goal: test\nevidence: []"), 0600)
 
-		gLimited := guard.New(guard.Policy{MaxIterations: 1})
+		gLimited := guard.New(guard.Policy{MaxIterations: 1, MaxPromptTokens: 100000, MaxOutputTokens: 100000})
 		p := provider.NewStubProvider()
 		mp := mcp.NewProxy(s, gLimited)
 		r := New(s, gLimited, c, o, p, mp)
@@ -115,5 +227,1328 @@ t.Run("Successful Run", func(t *testing.T) {
 		if err == nil {
 			t.Error("Expected guard violation error")
 		}
+
+		records, _ := s.ListViolations(store.ViolationFilter{SessionID: "sess-guard"})
+		recorded := false
+		for _, v := range records {
+			recorded = recorded || (v.Rule == "max_iterations" && v.Severity == "halt")
+		}
+		if !recorded {
+			t.Errorf("Expected the max_iterations violation to be recorded, got %+v", records)
+		}
+		pm, err := LoadPostMortem(s, "sess-guard")
+		if err != nil || pm == nil {
+			t.Fatalf("Expected a post-mortem, got %v", err)
+		}
+		if pm.Status != "halted" || !strings.Contains(pm.Reason, "Iteration limit") || !strings.Contains(pm.Recommendations[0], "max_iterations budget") {
+			t.Errorf("Expected the iteration limit explained, got %+v", pm)
+		}
+	})
+
+	t.Run("Verification Retries", func(t *testing.T) {
+		missing := filepath.Join(tmpDir, "never.txt")
+		specPath := filepath.Join(tmpDir, "spec_retries.yaml")
+		os.WriteFile(specPath, []byte("goal: test\ndefinition_of_done: test\nevidence: ["+missing+"]"), 0600)
+
+		// Failed verifications don't count against the iteration budget,
+		// so the third failure exhausts the retries before max_iterations
+		gRetries := guard.New(guard.Policy{MaxIterations: 2, MaxVerificationRetries: 2, MaxPromptTokens: 100000, MaxOutputTokens: 100000})
+		p := &provider.StubProvider{}
+		mp := mcp.NewProxy(s, gRetries)
+		r := New(s, gRetries, c, o, p, mp)
+		s.CreateSession(&store.Session{ID: "sess-retries", CreatedAt: time.Now(), Status: "active", Metadata: map[string]string{"spec": specPath}})
+
+		var outcome []Event
+		r.EventBus().Subscribe(EventSessionComplete, func(e Event) { outcome = append(outcome, e) })
+
+		err := r.ExecuteSession(context.Background(), "sess-retries")
+		if err == nil || !strings.Contains(err.Error(), "never verified: evidence "+missing+" (fail)") {
+			t.Fatalf("Expected the missing evidence to be reported, got %v", err)
+		}
+		updated, _ := s.GetSession("sess-retries")
+		if updated.Status != "verification_exhausted" {
+			t.Errorf("Expected status verification_exhausted, got %s", updated.Status)
+		}
+		if len(outcome) != 1 || outcome[0].Data["status"] != "verification_exhausted" {
+			t.Errorf("Expected one session_complete event, got %+v", outcome)
+		}
+		records, _ := s.ListViolations(store.ViolationFilter{SessionID: "sess-retries"})
+		if len(records) != 1 || records[0].Rule != "max_verification_retries" {
+			t.Errorf("Expected only the verification retry violation, got %+v", records)
+		}
+		pm, err := LoadPostMortem(s, "sess-retries")
+		if err != nil || pm == nil {
+			t.Fatalf("Expected a post-mortem, got %v", err)
+		}
+		if len(pm.Unverified) != 1 || pm.Unverified[0].Item != missing || pm.RevisedSpec == "" {
+			t.Fatalf("Expected the missing evidence and a revised spec, got %+v", pm)
+		}
+		_, revised, _ := s.GetArtifact(pm.RevisedSpec)
+		if !strings.Contains(string(revised), "Create "+missing+" before claiming completion") {
+			t.Errorf("Expected the revised spec to ask for the evidence, got:\n%s", revised)
+		}
+	})
+
+	t.Run("Evidence Watcher", func(t *testing.T) {
+		evidence := filepath.Join(tmpDir, "watched.txt")
+		specPath := filepath.Join(tmpDir, "spec_watch.yaml")
+		os.WriteFile(specPath, []byte(fmt.Sprintf("goal: test\nevidence: [%q]", evidence)), 0600)
+
+		prevPoll := evidencePollInterval
+		evidencePollInterval = 10 * time.Millisecond
+		defer func() { evidencePollInterval = prevPoll }()
+
+		// Neither response claims completion
+		p := &provider.StubProvider{
+			Responses: []provider.Response{
+				{Content: "Writing the file now.", Usage: provider.Usage{TotalTokens: 10}},
+				{Content: "Wrote watched.txt.", Usage: provider.Usage{TotalTokens: 10}},
+			},
+		}
+		mp := mcp.NewProxy(s, g)
+		r := New(s, g, c, o, p, mp)
+		r.SetWatchEvidence(true)
+		var sources []interface{}
+		r.EventBus().Subscribe(EventVerificationPass, func(e Event) {
+			sources = append(sources, e.Data["source"])
+		})
+
+		s.CreateSession(&store.Session{
+			ID:        "sess-watch",
+			CreatedAt: time.Now(),
+			Status:    "active",
+			Metadata:  map[string]string{"spec": specPath},
+		})
+		time.AfterFunc(100*time.Millisecond, func() { os.WriteFile(evidence, []byte("ok"), 0600) })
+		if err := r.ExecuteSession(context.Background(), "sess-watch"); err != nil {
+			t.Fatalf("ExecuteSession failed: %v", err)
+		}
+
+		updated, _ := s.GetSession("sess-watch")
+		if updated.Status != "completed" || len(p.Responses) != 0 {
+			t.Errorf("Expected completion after the first iteration, got status %s with %d responses left", updated.Status, len(p.Responses))
+		}
+		if fmt.Sprint(sources) != "[watcher <nil>]" {
+			t.Errorf("Expected a watcher pass before the verified pass, got %v", sources)
+		}
+	})
+
+	t.Run("Prompt Size Pre-flight", func(t *testing.T) {
+		specPath := filepath.Join(tmpDir, "spec_preflight.yaml")
+		os.WriteFile(specPath, []byte("goal: "+strings.Repeat("very long goal ", 200)+"\nevidence: []"), 0600)
+
+		policy := guard.DefaultPolicy
+		policy.MaxPromptTokens = 500
+		gSmall := guard.New(policy)
+		p := &provider.StubProvider{Responses: []provider.Response{{Content: "Task complete."}}}
+		r := New(s, gSmall, c, o, p, mcp.NewProxy(s, gSmall))
+
+		s.CreateSession(&store.Session{
+			ID:        "sess-preflight",
+			CreatedAt: time.Now(),
+			Status:    "active",
+			Metadata:  map[string]string{"spec": specPath},
+		})
+		err := r.ExecuteSession(context.Background(), "sess-preflight")
+		if err == nil || !strings.Contains(err.Error(), "would exceed the prompt token budget") {
+			t.Fatalf("Expected a pre-flight prompt budget violation, got %v", err)
+		}
+		if len(p.Responses) != 1 {
+			t.Error("Expected the request not to be sent")
+		}
+		if updated, _ := s.GetSession("sess-preflight"); updated.Status != "halted" {
+			t.Errorf("Expected status 'halted', got '%s'", updated.Status)
+		}
+	})
+
+	t.Run("Constraint Reminders", func(t *testing.T) {
+		specPath := filepath.Join(tmpDir, "spec_reminder.yaml")
+		os.WriteFile(specPath, []byte("goal: test\nconstraints: [never touch main.go]\nreminder_interval: 1\nevidence: []"), 0600)
+
+		p := &provider.StubProvider{
+			Responses: []provider.Response{{Content: "Working on it.", Usage: provider.Usage{TotalTokens: 10}}},
+		}
+		mp := mcp.NewProxy(s, g)
+		r := New(s, g, c, o, p, mp)
+
+		s.CreateSession(&store.Session{
+			ID:        "sess-reminder",
+			CreatedAt: time.Now(),
+			Status:    "active",
+			Metadata:  map[string]string{"spec": specPath},
+		})
+		if err := r.ExecuteSession(context.Background(), "sess-reminder"); err != nil {
+			t.Fatalf("ExecuteSession failed: %v", err)
+		}
+
+		history, _ := r.LoadHistory("sess-reminder")
+		reminders := 0
+		for _, m := range history {
+			if m.Role == "user" && strings.HasPrefix(m.Content, "[System reminder]") && strings.Contains(m.Content, "never touch main.go") {
+				reminders++
+			}
+		}
+		if reminders != 1 {
+			t.Errorf("Expected the constraints to be restated before iteration 2, got %d reminders", reminders)
+		}
+	})
+
+	t.Run("Iteration Deadline", func(t *testing.T) {
+		specPath := filepath.Join(tmpDir, "spec_deadline.yaml")
+		os.WriteFile(specPath, []byte("goal: test\nevidence: []"), 0600)
+
+		policy := guard.DefaultPolicy
+		policy.MaxIterationDuration = 50 * time.Millisecond
+		gDeadline := guard.New(policy)
+		mp := mcp.NewProxy(s, gDeadline)
+		r := New(s, gDeadline, c, o, provider.NewStubProvider(), mp)
+
+		s.CreateSession(&store.Session{
+			ID:        "sess-deadline",
+			CreatedAt: time.Now(),
+			Status:    "active",
+			Metadata:  map[string]string{"spec": specPath},
+		})
+
+		err := r.ExecuteSession(context.Background(), "sess-deadline")
+		var ve *guard.ViolationError
+		if !errors.As(err, &ve) || ve.Violation.Rule != "max_iteration_duration" {
+			t.Fatalf("Expected max_iteration_duration violation, got %v", err)
+		}
+		updated, _ := s.GetSession("sess-deadline")
+		if updated.Status != "halted" {
+			t.Errorf("Expected status 'halted', got '%s'", updated.Status)
+		}
+	})
+
+	t.Run("Cancellation", func(t *testing.T) {
+		specPath := filepath.Join(tmpDir, "spec_cancel.yaml")
+		os.WriteFile(specPath, []byte("goal: test\nevidence: []"), 0600)
+
+		prevPoll := cancelPollInterval
+		cancelPollInterval = 10 * time.Millisecond
+		defer func() { cancelPollInterval = prevPoll }()
+
+		p := &provider.StubProvider{
+			Responses: []provider.Response{
+				{Content: "Working on it.", Usage: provider.Usage{TotalTokens: 10}},
+				{Content: "Inspected the workspace.", Usage: provider.Usage{TotalTokens: 10}},
+			},
+		}
+		mp := mcp.NewProxy(s, g)
+		r := New(s, g, c, o, p, mp)
+
+		s.CreateSession(&store.Session{
+			ID:        "sess-cancel",
+			CreatedAt: time.Now(),
+			Status:    "active",
+			Metadata:  map[string]string{"spec": specPath},
+		})
+		if err := s.RequestCancel("sess-cancel"); err != nil {
+			t.Fatalf("RequestCancel failed: %v", err)
+		}
+
+		err := r.ExecuteSession(context.Background(), "sess-cancel")
+		if !errors.Is(err, ErrSessionCancelled) {
+			t.Fatalf("Expected ErrSessionCancelled, got %v", err)
+		}
+
+		updated, _ := s.GetSession("sess-cancel")
+		if updated.Status != "cancelled" {
+			t.Errorf("Expected status 'cancelled', got '%s'", updated.Status)
+		}
+		artifacts, _ := s.ListArtifacts("sess-cancel")
+		types := make(map[string]int)
+		for _, a := range artifacts {
+			types[a.Type]++
+		}
+		if len(artifacts) != 3 || types["summary"] != 1 || types[ArtifactFileManifest] != 1 || types[ArtifactSpec] != 1 {
+			t.Errorf("Expected a partial summary, a change manifest, and the spec snapshot, got %v", types)
+		}
+	})
+
+	t.Run("Pause And Steer", func(t *testing.T) {
+		specPath := filepath.Join(tmpDir, "spec_steer.yaml")
+		os.WriteFile(specPath, []byte("goal: test\nevidence: []"), 0600)
+
+		p := &provider.StubProvider{
+			Responses: []provider.Response{{Content: "Task complete.", Usage: provider.Usage{TotalTokens: 10}}},
+		}
+		mp := mcp.NewProxy(s, g)
+		r := New(s, g, c, o, p, mp)
+
+		var events []EventType
+		var mu sync.Mutex
+		record := func(e Event) {
+			mu.Lock()
+			events = append(events, e.Type)
+			mu.Unlock()
+		}
+		r.EventBus().Subscribe(EventSessionPaused, func(e Event) {
+			record(e)
+			r.Steer("use the v2 API")
+			r.Resume()
+		})
+		r.EventBus().Subscribe(EventSessionResumed, record)
+		r.EventBus().Subscribe(EventSteering, record)
+
+		s.CreateSession(&store.Session{
+			ID:        "sess-steer",
+			CreatedAt: time.Now(),
+			Status:    "active",
+			Metadata:  map[string]string{"spec": specPath},
+		})
+		r.Pause()
+		r.Steer("  ")
+		if !r.Paused() {
+			t.Fatal("Expected the runtime to be paused")
+		}
+		if err := r.ExecuteSession(context.Background(), "sess-steer"); err != nil {
+			t.Fatalf("ExecuteSession failed: %v", err)
+		}
+
+		mu.Lock()
+		got := fmt.Sprint(events)
+		mu.Unlock()
+		if got != "[session_paused session_resumed steering]" {
+			t.Errorf("Unexpected events %s", got)
+		}
+		history, _ := r.LoadHistory("sess-steer")
+		if len(history) < 2 || history[1].Role != "user" || history[1].Content != "[User steering] use the v2 API" {
+			t.Errorf("Expected the steering message before the first provider call, got %+v", history)
+		}
+	})
+
+	t.Run("Plan Tracking", func(t *testing.T) {
+		specPath := filepath.Join(tmpDir, "spec_plan.yaml")
+		os.WriteFile(specPath, []byte("goal: test\nevidence: []"), 0600)
+
+		p := &provider.StubProvider{
+			Responses: []provider.Response{
+				{Content: "```plan\n1. Inspect\n2. Build\n```", Usage: provider.Usage{TotalTokens: 10}},
+				{Content: "Step 1 done. Task complete.", Usage: provider.Usage{TotalTokens: 10}},
+			},
+		}
+		mp := mcp.NewProxy(s, g)
+		r := New(s, g, c, o, p, mp)
+
+		s.CreateSession(&store.Session{
+			ID:        "sess-plan",
+			CreatedAt: time.Now(),
+			Status:    "active",
+			Metadata:  map[string]string{"spec": specPath},
+		})
+		if err := r.ExecuteSession(context.Background(), "sess-plan"); err != nil {
+			t.Fatalf("ExecuteSession failed: %v", err)
+		}
+
+		var plans []*store.Artifact
+		artifacts, _ := s.ListArtifacts("sess-plan")
+		for _, a := range artifacts {
+			if a.Type == "plan" {
+				plans = append(plans, a)
+			}
+		}
+		if len(plans) != 3 {
+			t.Fatalf("Expected 3 plan versions (parsed, step done, completed), got %d", len(plans))
+		}
+		_, content, _ := s.GetArtifact(plans[len(plans)-1].ID)
+		if strings.Contains(string(content), `"pending"`) || strings.Contains(string(content), `"in_progress"`) {
+			t.Errorf("Expected final plan to be fully done, got %s", content)
+		}
+	})
+
+	t.Run("Orchestrated", func(t *testing.T) {
+		specPath := filepath.Join(tmpDir, "spec_orchestrated.yaml")
+		os.WriteFile(specPath, []byte("goal: test\nevidence: []"), 0600)
+
+		planner := &provider.StubProvider{Responses: []provider.Response{
+			{Content: "```plan\n1. Inspect\n2. Build\n```\nWatch the tests.", Usage: provider.Usage{PromptTokens: 40, CompletionTokens: 10}},
+		}}
+		executor := &provider.StubProvider{Responses: []provider.Response{
+			{Content: "Step 1 done. Step 2 done. Task complete.", Usage: provider.Usage{TotalTokens: 10}},
+			{Content: "Added the docs. Task complete.", Usage: provider.Usage{TotalTokens: 10}},
+		}}
+		reviewer := &provider.StubProvider{Responses: []provider.Response{
+			{Content: "CHANGES REQUESTED\n- document the flag", Usage: provider.Usage{PromptTokens: 30, CompletionTokens: 5}},
+			{Content: "APPROVED", Usage: provider.Usage{PromptTokens: 30, CompletionTokens: 1}},
+		}}
+		mp := mcp.NewProxy(s, g)
+		r := New(s, g, c, o, executor, mp)
+		r.SetOrchestrator(orchestrate.New(planner, executor, reviewer))
+
+		var reviews []Event
+		r.EventBus().Subscribe(EventReview, func(e Event) { reviews = append(reviews, e) })
+
+		s.CreateSession(&store.Session{ID: "sess-orch", CreatedAt: time.Now(), Status: "active", Metadata: map[string]string{"spec": specPath}})
+		if err := r.ExecuteSession(context.Background(), "sess-orch"); err != nil {
+			t.Fatalf("ExecuteSession failed: %v", err)
+		}
+
+		updated, _ := s.GetSession("sess-orch")
+		if updated.Status != "completed" || updated.Metadata[MetadataPlanner] != "stub/stub" || updated.Metadata[MetadataReviewer] != "stub/stub" {
+			t.Errorf("Expected a completed session naming its planner and reviewer, got %s %v", updated.Status, updated.Metadata)
+		}
+		if updated.PromptTokens < 100 {
+			t.Errorf("Expected planner and reviewer usage in the session totals, got %d prompt tokens", updated.PromptTokens)
+		}
+		if len(reviews) != 2 || reviews[0].Data["approved"] != false || reviews[1].Data["approved"] != true {
+			t.Errorf("Expected a rejection, then an approval, got %+v", reviews)
+		}
+
+		messages, _ := s.LoadMessages("sess-orch")
+		if len(messages) == 0 || !strings.Contains(messages[0].Content, "1. Inspect") || !strings.Contains(messages[0].Content, "Watch the tests.") {
+			t.Fatalf("Expected the planner's plan in the executor's prompt, got %+v", messages)
+		}
+		var feedback bool
+		for _, m := range messages {
+			feedback = feedback || (m.Role == "user" && strings.Contains(m.Content, "- document the flag"))
+		}
+		if !feedback {
+			t.Error("Expected the reviewer's feedback to be sent to the executor")
+		}
+	})
+
+	t.Run("Orchestrated Review Failure", func(t *testing.T) {
+		specPath := filepath.Join(tmpDir, "spec_orchestrated_fail.yaml")
+		os.WriteFile(specPath, []byte("goal: test\nevidence: []"), 0600)
+
+		executor := &provider.StubProvider{Responses: []provider.Response{{Content: "Task complete."}}}
+		reviewer := failingProvider{provider.NewStubProvider()}
+		r := New(s, g, c, o, executor, mcp.NewProxy(s, g))
+		r.SetOrchestrator(orchestrate.New(provider.NewStubProvider(), executor, reviewer))
+
+		s.CreateSession(&store.Session{ID: "sess-orch-fail", CreatedAt: time.Now(), Status: "active", Metadata: map[string]string{"spec": specPath}})
+		if err := r.ExecuteSession(context.Background(), "sess-orch-fail"); err != nil {
+			t.Fatalf("Expected a failed review not to fail the session, got %v", err)
+		}
+		if updated, _ := s.GetSession("sess-orch-fail"); updated.Status != "completed" {
+			t.Errorf("Expected the verified work to be accepted, got %s", updated.Status)
+		}
+	})
+
+	t.Run("Orchestrated Mission", func(t *testing.T) {
+		specPath := filepath.Join(tmpDir, "spec_orchestrated_mission.yaml")
+		os.WriteFile(specPath, []byte(fmt.Sprintf("goal: ship\ndefinition_of_done: done\nsteps:\n  - goal: write the changelog\n    evidence: [%q]\n", specPath)), 0600)
+
+		planner := &provider.StubProvider{Responses: []provider.Response{{Content: "```plan\n1. Write it\n```"}}}
+		executor := &provider.StubProvider{Responses: []provider.Response{{Content: "Step 1 done. Task complete."}}}
+		reviewer := &provider.StubProvider{Responses: []provider.Response{{Content: "APPROVED"}}}
+		r := New(s, g, c, o, executor, mcp.NewProxy(s, g))
+		r.SetOrchestrator(orchestrate.New(planner, executor, reviewer))
+
+		s.CreateSession(&store.Session{ID: "sess-orch-mission", CreatedAt: time.Now(), Status: "active", Metadata: map[string]string{"spec": specPath}})
+		if err := r.ExecuteSession(context.Background(), "sess-orch-mission"); err != nil {
+			t.Fatalf("ExecuteSession failed: %v", err)
+		}
+		children, _ := s.ListSessions(store.SessionFilter{ParentID: "sess-orch-mission"})
+		if len(children) != 1 || children[0].Metadata[MetadataReviewer] != "stub/stub" {
+			t.Fatalf("Expected the step to run orchestrated, got %+v", children)
+		}
+		if len(planner.Responses) != 0 || len(reviewer.Responses) != 0 {
+			t.Errorf("Expected the step to be planned and reviewed, %d plans and %d reviews left", len(planner.Responses), len(reviewer.Responses))
+		}
+	})
+
+	t.Run("Sub-task", func(t *testing.T) {
+		specPath := filepath.Join(tmpDir, "spec_parent.yaml")
+		os.WriteFile(specPath, []byte("goal: test\nevidence: []"), 0600)
+
+		// Parent and child share the provider, so responses alternate between them
+		p := &provider.StubProvider{
+			Responses: []provider.Response{
+				{ToolCalls: []provider.ToolCall{{ID: "call_sub", Name: "spawn_subtask", Args: fmt.Sprintf(`{"goal": "write notes", "evidence": %q, "max_iterations": "3"}`, specPath)}},
+					Usage: provider.Usage{PromptTokens: 10, CompletionTokens: 5}},
+				{Content: "Notes written. Task complete.", Usage: provider.Usage{PromptTokens: 20, CompletionTokens: 5}},
+				{Content: "Wrote the release notes.", Usage: provider.Usage{PromptTokens: 1, CompletionTokens: 1}},
+				{Content: "Task complete.", Usage: provider.Usage{PromptTokens: 10, CompletionTokens: 5}},
+			},
+		}
+		mp := mcp.NewProxy(s, g)
+		r := New(s, g, c, o, p, mp)
+		mp.SetSubtaskRunner(r)
+
+		s.CreateSession(&store.Session{
+			ID:        "sess-parent",
+			CreatedAt: time.Now(),
+			Status:    "active",
+			Metadata:  map[string]string{"spec": specPath},
+		})
+		if err := r.ExecuteSession(context.Background(), "sess-parent"); err != nil {
+			t.Fatalf("ExecuteSession failed: %v", err)
+		}
+
+		children, _ := s.ListSessions(store.SessionFilter{ParentID: "sess-parent"})
+		if len(children) != 1 || children[0].ID != "sess-parent-sub1" || children[0].Status != "completed" {
+			t.Fatalf("Expected one completed sub-task, got %+v", children)
+		}
+		parent, _ := s.GetSession("sess-parent")
+		if children[0].PromptTokens != 21 || parent.PromptTokens != 10+21+10 {
+			t.Errorf("Expected the sub-task's usage rolled up into the parent, got %d prompt tokens", parent.PromptTokens)
+		}
+
+		var report string
+		artifacts, _ := s.ListArtifacts("sess-parent")
+		for _, a := range artifacts {
+			if a.Type == "tool_output" {
+				_, content, _ := s.GetArtifact(a.ID)
+				report = string(content)
+			}
+		}
+		if !strings.Contains(report, "Sub-task sess-parent-sub1 completed") || !strings.Contains(report, "Wrote the release notes.") {
+			t.Errorf("Expected the sub-task summary as the tool result, got %q", report)
+		}
+
+		if _, err := r.SpawnSubtask(context.Background(), "sess-parent-sub1", coach.TaskSpec{Goal: "nested"}, 0); !errors.Is(err, ErrNestedSubtask) {
+			t.Errorf("Expected ErrNestedSubtask, got %v", err)
+		}
+	})
+
+	t.Run("Sub-task Allowed Commands", func(t *testing.T) {
+		specPath := filepath.Join(tmpDir, "spec_parent_commands.yaml")
+		os.WriteFile(specPath, []byte("goal: test\nallowed_commands: [echo]\nevidence: []"), 0600)
+
+		p := &provider.StubProvider{
+			Responses: []provider.Response{
+				{ToolCalls: []provider.ToolCall{{ID: "call_sub", Name: "spawn_subtask", Args: fmt.Sprintf(`{"goal": "clean up", "evidence": %q, "max_iterations": "3"}`, specPath)}}},
+				{ToolCalls: []provider.ToolCall{{ID: "call_rm", Name: "run_shell", Args: `{"cmd": "ls"}`}}},
+				{Content: "Task complete."},
+				{Content: "Tried to list the files."},
+				{Content: "Task complete."},
+			},
+		}
+		mp := mcp.NewProxy(s, g)
+		r := New(s, g, c, o, p, mp)
+		mp.SetSubtaskRunner(r)
+
+		s.CreateSession(&store.Session{ID: "sess-parent-cmds", CreatedAt: time.Now(), Status: "active", Metadata: map[string]string{"spec": specPath}})
+		if err := r.ExecuteSession(context.Background(), "sess-parent-cmds"); err != nil {
+			t.Fatalf("ExecuteSession failed: %v", err)
+		}
+
+		var output string
+		artifacts, _ := s.ListArtifacts("sess-parent-cmds-sub1")
+		for _, a := range artifacts {
+			if a.Type == "tool_output" {
+				_, content, _ := s.GetArtifact(a.ID)
+				output += string(content)
+			}
+		}
+		if !strings.Contains(output, "task spec allowed_commands") {
+			t.Errorf("Expected the sub-task's ls refused by the parent's allowed_commands, got %q", output)
+		}
+	})
+
+	t.Run("Mission", func(t *testing.T) {
+		specPath := filepath.Join(tmpDir, "spec_mission.yaml")
+		os.WriteFile(specPath, []byte(fmt.Sprintf(`goal: ship the release
+definition_of_done: both steps done
+steps:
+  - goal: write the changelog
+    evidence: [%[1]q]
+    max_iterations: 3
+  - goal: bump the version
+    evidence: [%[1]q]
+`, specPath)), 0600)
+
+		// Each step completes, then answers the summary request
+		p := &provider.StubProvider{
+			Responses: []provider.Response{
+				{Content: "Task complete.", Usage: provider.Usage{PromptTokens: 10, CompletionTokens: 5}},
+				{Content: "Wrote the changelog."},
+				{Content: "Task complete.", Usage: provider.Usage{PromptTokens: 20, CompletionTokens: 5}},
+				{Content: "Bumped the version."},
+			},
+		}
+		r := New(s, g, c, o, p, mcp.NewProxy(s, g))
+		s.CreateSession(&store.Session{
+			ID:        "sess-mission",
+			CreatedAt: time.Now(),
+			Status:    "active",
+			Metadata:  map[string]string{"spec": specPath},
+		})
+		if err := r.ExecuteSession(context.Background(), "sess-mission"); err != nil {
+			t.Fatalf("ExecuteSession failed: %v", err)
+		}
+
+		children, _ := s.ListSessions(store.SessionFilter{ParentID: "sess-mission"})
+		if len(children) != 2 {
+			t.Fatalf("Expected one sub-task per step, got %+v", children)
+		}
+		mission, _ := s.GetSession("sess-mission")
+		if mission.Status != "completed" || mission.Metadata[MetadataStepsDone] != "2" || mission.PromptTokens != 30 {
+			t.Errorf("Expected a completed mission with both steps' usage, got %s, %v, %d prompt tokens", mission.Status, mission.Metadata, mission.PromptTokens)
+		}
+		_, summary, _ := s.GetArtifact("art-sess-mission-summary")
+		if !strings.Contains(string(summary), "Step 1: Wrote the changelog.") || !strings.Contains(string(summary), "Step 2: Bumped the version.") {
+			t.Errorf("Expected the step summaries, got %q", summary)
+		}
+
+		// A session continuing a mission stopped after step 1 runs only step 2
+		s.CreateSession(&store.Session{ID: "sess-mission-failed", CreatedAt: time.Now(), Status: "failed",
+			Metadata: map[string]string{"spec": specPath, MetadataStepsDone: "1"}})
+		p.Responses = []provider.Response{{Content: "Task complete."}, {Content: "Bumped the version."}}
+		s.CreateSession(&store.Session{
+			ID:        "sess-mission-next",
+			CreatedAt: time.Now(),
+			Status:    "active",
+			Metadata:  map[string]string{"spec": specPath, MetadataPreviousSession: "sess-mission-failed"},
+		})
+		if err := r.ExecuteSession(context.Background(), "sess-mission-next"); err != nil {
+			t.Fatalf("ExecuteSession failed: %v", err)
+		}
+		children, _ = s.ListSessions(store.SessionFilter{ParentID: "sess-mission-next"})
+		if len(children) != 1 {
+			t.Fatalf("Expected only step 2 to run, got %+v", children)
+		}
+		if spec, err := r.loadSpec(children[0]); err != nil || spec.Goal != "bump the version" {
+			t.Errorf("Expected step 2's spec, got %+v, %v", spec, err)
+		}
+	})
+
+	t.Run("Spec Variables", func(t *testing.T) {
+		specPath := filepath.Join(tmpDir, "spec_vars.yaml")
+		os.WriteFile(specPath, []byte("goal: fix ${TICKET} in ${SERVICE}\nevidence: []\nvars:\n  SERVICE: billing\n"), 0600)
+
+		p := &provider.StubProvider{Responses: []provider.Response{{Content: "Task complete."}}}
+		r := New(s, g, c, o, p, mcp.NewProxy(s, g))
+		s.CreateSession(&store.Session{
+			ID:        "sess-vars",
+			CreatedAt: time.Now(),
+			Status:    "active",
+			Metadata:  map[string]string{"spec": specPath, MetadataVarPrefix + "TICKET": "OPS-42"},
+		})
+		if err := r.ExecuteSession(context.Background(), "sess-vars"); err != nil {
+			t.Fatalf("ExecuteSession failed: %v", err)
+		}
+		messages, _ := s.LoadMessages("sess-vars")
+		if len(messages) == 0 || !strings.Contains(messages[0].Content, "Goal: fix OPS-42 in billing") {
+			t.Errorf("Expected the resolved goal in the initial prompt, got %v", messages)
+		}
+
+		s.CreateSession(&store.Session{ID: "sess-vars-missing", CreatedAt: time.Now(), Status: "active", Metadata: map[string]string{"spec": specPath}})
+		if err := r.ExecuteSession(context.Background(), "sess-vars-missing"); err == nil || !strings.Contains(err.Error(), "${TICKET}") {
+			t.Errorf("Expected an undefined variable error, got %v", err)
+		}
+	})
+
+	t.Run("Previous Attempt", func(t *testing.T) {
+		specPath := filepath.Join(tmpDir, "spec_previous.yaml")
+		os.WriteFile(specPath, []byte("goal: test\nevidence: []"), 0600)
+
+		s.CreateSession(&store.Session{
+			ID:        "sess-prev",
+			CreatedAt: time.Now(),
+			Status:    "failed",
+			Metadata:  map[string]string{"spec": specPath},
+		})
+		s.SaveArtifact(&store.Artifact{ID: "art-sess-prev-summary", SessionID: "sess-prev", Path: "artifacts/sess-prev/summary.txt", Type: "summary", CreatedAt: time.Now()},
+			[]byte("Wrote the parser; tests still fail."))
+		s.SaveArtifact(&store.Artifact{ID: "art-sess-prev-plan-1", SessionID: "sess-prev", Path: "artifacts/sess-prev/plan-1.json", Type: "plan", CreatedAt: time.Now()},
+			[]byte(`{"steps":[{"description":"write the parser","status":"done"},{"description":"fix the tests","status":"pending"}]}`))
+		// Outside the namespace searched, so only found by its session
+		s.AddMemory("Pitfalls: the lexer drops trailing newlines", nil, map[string]string{"session_id": "sess-prev", store.MemoryNamespaceKey: "elsewhere"})
+
+		p := &provider.StubProvider{Responses: []provider.Response{{Content: "Task complete."}}}
+		r := New(s, g, c, o, p, mcp.NewProxy(s, g))
+		s.CreateSession(&store.Session{
+			ID:        "sess-next",
+			CreatedAt: time.Now(),
+			Status:    "active",
+			Metadata:  map[string]string{"spec": specPath, MetadataPreviousSession: "sess-prev"},
+		})
+		if err := r.ExecuteSession(context.Background(), "sess-next"); err != nil {
+			t.Fatalf("ExecuteSession failed: %v", err)
+		}
+
+		messages, _ := s.LoadMessages("sess-next")
+		var prompt string
+		for _, m := range messages {
+			if m.Role == "user" {
+				prompt = m.Content
+				break
+			}
+		}
+		for _, want := range []string{
+			"Previous attempt (session sess-prev, ended failed)",
+			"Final state: Wrote the parser; tests still fail.",
+			"1. [done] write the parser",
+			"2. [pending] fix the tests",
+			"Archived memory: Pitfalls: the lexer drops trailing newlines",
+		} {
+			if !strings.Contains(prompt, want) {
+				t.Errorf("Expected the initial prompt to contain %q, got:\n%s", want, prompt)
+			}
+		}
+	})
+
+	t.Run("Hooks", func(t *testing.T) {
+		marker := filepath.Join(tmpDir, "committed.txt")
+		specPath := filepath.Join(tmpDir, "spec_hooks.yaml")
+		os.WriteFile(specPath, []byte(fmt.Sprintf(`goal: test
+evidence: []
+hooks:
+  pre_run: ["ls %[1]s/deps-missing"]
+  post_iteration: ["echo vetted"]
+  on_complete: ["ls %[2]s"]
+`, tmpDir, marker)), 0600)
+
+		p := &provider.StubProvider{
+			Responses: []provider.Response{
+				{ToolCalls: []provider.ToolCall{{ID: "call_1", Name: "run_shell", Args: `{"cmd": "echo working"}`}}},
+				{Content: "Task complete."},
+				{Content: "Committed. Task complete."},
+			},
+		}
+		r := New(s, g, c, o, p, mcp.NewProxy(s, g))
+		var failed []string
+		r.EventBus().Subscribe(EventHookFail, func(e Event) {
+			failed = append(failed, e.Data["hook"].(string))
+			if e.Data["hook"] == coach.HookOnComplete {
+				os.WriteFile(marker, []byte("ok"), 0600)
+			}
+		})
+
+		s.CreateSession(&store.Session{
+			ID:        "sess-hooks",
+			CreatedAt: time.Now(),
+			Status:    "active",
+			Metadata:  map[string]string{"spec": specPath},
+		})
+		if err := r.ExecuteSession(context.Background(), "sess-hooks"); err != nil {
+			t.Fatalf("ExecuteSession failed: %v", err)
+		}
+		if sess, _ := s.GetSession("sess-hooks"); sess.Status != "completed" {
+			t.Errorf("Expected the session to complete once on_complete passed, got %s", sess.Status)
+		}
+		if strings.Join(failed, ",") != "pre_run,on_complete" {
+			t.Errorf("Expected pre_run and the first on_complete to fail, got %v", failed)
+		}
+
+		messages, _ := s.LoadMessages("sess-hooks")
+		var prompts []string
+		for _, m := range messages {
+			if m.Role == "user" {
+				prompts = append(prompts, m.Content)
+			}
+		}
+		if len(prompts) == 0 || !strings.Contains(prompts[0], "The pre_run hook failed") {
+			t.Errorf("Expected the pre_run failure in the initial prompt, got %q", prompts)
+		}
+		if !strings.Contains(strings.Join(prompts, "\n"), "The on_complete hook failed") {
+			t.Errorf("Expected the on_complete failure to be fed back, got %q", prompts)
+		}
+
+		hooks := 0
+		artifacts, _ := s.ListArtifacts("sess-hooks")
+		for _, a := range artifacts {
+			if a.Type == "hook" {
+				hooks++
+			}
+		}
+		if hooks != 4 {
+			t.Errorf("Expected 4 hook artifacts (pre_run, post_iteration, on_complete twice), got %d", hooks)
+		}
+	})
+
+	t.Run("Fixture Playback", func(t *testing.T) {
+		specPath := filepath.Join(tmpDir, "spec_fixture.yaml")
+		os.WriteFile(specPath, []byte("goal: print a greeting\nevidence: []"), 0600)
+
+		f, err := provider.LoadFixture(filepath.Join("testdata", "fixture_echo.json"))
+		if err != nil {
+			t.Fatalf("LoadFixture failed: %v", err)
+		}
+		p := provider.NewFixtureProvider(f)
+		gEcho := guard.New(guard.Policy{MaxIterations: 5, MaxPromptTokens: 8000, MaxOutputTokens: 4000, AllowedCommands: []string{"echo"}})
+		r := New(s, gEcho, c, o, p, mcp.NewProxy(s, gEcho))
+		s.CreateSession(&store.Session{ID: "sess-fixture", CreatedAt: time.Now(), Status: "active", Metadata: map[string]string{"spec": specPath}})
+
+		if err := r.ExecuteSession(context.Background(), "sess-fixture"); err != nil {
+			t.Fatalf("ExecuteSession failed: %v", err)
+		}
+		if updated, _ := s.GetSession("sess-fixture"); updated.Status != "completed" {
+			t.Errorf("Expected status 'completed', got '%s'", updated.Status)
+		}
+		if p.Remaining() != 0 {
+			t.Errorf("Expected every recorded response to be played, %d left", p.Remaining())
+		}
+		history, _ := r.LoadHistory("sess-fixture")
+		var echoed bool
+		for _, m := range history {
+			echoed = echoed || (m.Role == "tool" && strings.Contains(m.Content, "run_shell"))
+		}
+		if !echoed {
+			t.Error("Expected the recorded tool call to be executed")
+		}
+	})
+
+	t.Run("Memory Namespace", func(t *testing.T) {
+		specPath := filepath.Join(tmpDir, "spec_namespace.yaml")
+		os.WriteFile(specPath, []byte("goal: test\nevidence: []\nmemory_namespace: proj-a"), 0600)
+		// Other subtests' memories would crowd the results
+		dir := t.TempDir()
+		s, _ := store.NewSQLiteStore(filepath.Join(dir, "db"), filepath.Join(dir, "artifacts"))
+		defer s.Close()
+		vec := []float32{0.1, 0.2, 0.3}
+		s.AddMemory("Lesson from project A", vec, map[string]string{store.MemoryNamespaceKey: "proj-a"})
+		s.AddMemory("Lesson from project B", vec, map[string]string{store.MemoryNamespaceKey: "proj-b"})
+
+		initialPrompt := func(id string, metadata map[string]string) string {
+			p := &provider.StubProvider{Responses: []provider.Response{{Content: "Task complete."}}}
+			r := New(s, g, c, o, p, mcp.NewProxy(s, g))
+			metadata["spec"] = specPath
+			s.CreateSession(&store.Session{ID: id, CreatedAt: time.Now(), Status: "active", Metadata: metadata})
+			if err := r.ExecuteSession(context.Background(), id); err != nil {
+				t.Fatalf("ExecuteSession failed: %v", err)
+			}
+			messages, _ := s.LoadMessages(id)
+			return messages[0].Content
+		}
+
+		prompt := initialPrompt("sess-ns", map[string]string{})
+		if !strings.Contains(prompt, "project A") || strings.Contains(prompt, "project B") {
+			t.Errorf("Expected only project A's memory, got:\n%s", prompt)
+		}
+		if sess, _ := s.GetSession("sess-ns"); sess.Metadata[MetadataMemoryNamespace] != "proj-a" {
+			t.Errorf("Expected the namespace recorded on the session, got %v", sess.Metadata)
+		}
+		archived, _ := s.QueryMemory(store.MemoryQuery{Vector: vec, Limit: 10, Namespace: "proj-b"})
+		for _, m := range archived {
+			if m.Metadata["session_id"] == "sess-ns" {
+				t.Errorf("Expected the session's memory archived under proj-a, got %v", m.Metadata)
+			}
+		}
+
+		prompt = initialPrompt("sess-ns-global", map[string]string{MetadataGlobalMemory: "true"})
+		if !strings.Contains(prompt, "project B") {
+			t.Errorf("Expected global memory to include project B, got:\n%s", prompt)
+		}
+	})
+
+	t.Run("Keyword Memory", func(t *testing.T) {
+		specPath := filepath.Join(tmpDir, "spec_keyword.yaml")
+		os.WriteFile(specPath, []byte("goal: regenerate the task spec schema\nevidence: []\nmemory_namespace: proj-kw"), 0600)
+		dir := t.TempDir()
+		s, _ := store.NewSQLiteStore(filepath.Join(dir, "db"), filepath.Join(dir, "artifacts"))
+		defer s.Close()
+		s.AddMemory("The schema is generated; run go generate after changing it", nil, map[string]string{store.MemoryNamespaceKey: "proj-kw"})
+
+		p := noEmbedProvider{&provider.StubProvider{Responses: []provider.Response{
+			{Content: "Task complete."},
+			{Content: "Regenerated the schema with go generate."},
+		}}}
+		r := New(s, g, c, o, p, mcp.NewProxy(s, g))
+		s.CreateSession(&store.Session{ID: "sess-kw", CreatedAt: time.Now(), Status: "active", Metadata: map[string]string{"spec": specPath}})
+		if err := r.ExecuteSession(context.Background(), "sess-kw"); err != nil {
+			t.Fatalf("ExecuteSession failed: %v", err)
+		}
+
+		messages, _ := s.LoadMessages("sess-kw")
+		if !strings.Contains(messages[0].Content, "run go generate") {
+			t.Errorf("Expected the memory found by keywords without embeddings, got:\n%s", messages[0].Content)
+		}
+		archived, _ := s.QueryMemory(store.MemoryQuery{Text: "regenerated schema", Limit: 10, Namespace: "proj-kw"})
+		found := false
+		for _, m := range archived {
+			found = found || m.Metadata["session_id"] == "sess-kw"
+		}
+		if !found {
+			t.Errorf("Expected the session archived without a vector, got %+v", archived)
+		}
+	})
+
+	t.Run("Structured Lessons", func(t *testing.T) {
+		specPath := filepath.Join(tmpDir, "spec_lessons.yaml")
+		os.WriteFile(specPath, []byte("goal: migrate the config loader\nevidence: []\nmemory_namespace: proj-lessons"), 0600)
+		dir := t.TempDir()
+		s, _ := store.NewSQLiteStore(filepath.Join(dir, "db"), filepath.Join(dir, "artifacts"))
+		defer s.Close()
+
+		p := &provider.StubProvider{Responses: []provider.Response{
+			{Content: "Task complete."},
+			{Content: `{"summary": "Migrated the config loader.", "worked": ["Golden files"], "pitfalls": ["The loader caches paths"], "commands": ["go test ./config/..."]}`},
+		}}
+		r := New(s, g, c, o, p, mcp.NewProxy(s, g))
+		s.CreateSession(&store.Session{ID: "sess-lessons", CreatedAt: time.Now(), Status: "active", Metadata: map[string]string{"spec": specPath}})
+		if err := r.ExecuteSession(context.Background(), "sess-lessons"); err != nil {
+			t.Fatalf("ExecuteSession failed: %v", err)
+		}
+
+		archived, _ := s.QueryMemory(store.MemoryQuery{Text: "config loader", Limit: 10, Namespace: "proj-lessons"})
+		kinds := make(map[string]string)
+		for _, m := range archived {
+			if m.Metadata["session_id"] == "sess-lessons" {
+				kinds[m.Metadata[store.MemoryKindKey]] = m.Content
+			}
+		}
+		if len(kinds) != 4 || !strings.Contains(kinds[store.MemoryKindPitfalls], "The loader caches paths") || !strings.Contains(kinds[store.MemoryKindCommands], "go test ./config/...") {
+			t.Errorf("Expected a memory per kind, got %v", kinds)
+		}
+
+		p = &provider.StubProvider{Responses: []provider.Response{{Content: "Task complete."}}}
+		r = New(s, g, c, o, p, mcp.NewProxy(s, g))
+		s.CreateSession(&store.Session{ID: "sess-lessons-2", CreatedAt: time.Now(), Status: "active", Metadata: map[string]string{"spec": specPath}})
+		if err := r.ExecuteSession(context.Background(), "sess-lessons-2"); err != nil {
+			t.Fatalf("ExecuteSession failed: %v", err)
+		}
+		messages, _ := s.LoadMessages("sess-lessons-2")
+		if !strings.Contains(messages[0].Content, "The loader caches paths") {
+			t.Errorf("Expected the pitfall in the next session's prompt, got:\n%s", messages[0].Content)
+		}
+	})
+
+	t.Run("Provider Switch", func(t *testing.T) {
+		specPath := filepath.Join(tmpDir, "spec_switch.yaml")
+		os.WriteFile(specPath, []byte("goal: test\nevidence: []"), 0600)
+
+		p := &provider.StubProvider{}
+		mp := mcp.NewProxy(s, g)
+		r := New(s, g, c, o, p, mp)
+		stopped := 0
+		r.SetProviderFactory(func(name, model string) (provider.Provider, func(), error) {
+			return namedProvider{StubProvider: &provider.StubProvider{}, name: name, model: model}, func() { stopped++ }, nil
+		})
+		var switches []Event
+		r.EventBus().Subscribe(EventProviderSwitch, func(e Event) { switches = append(switches, e) })
+
+		s.CreateSession(&store.Session{ID: "sess-switch", CreatedAt: time.Now(), Status: "active", Metadata: map[string]string{"spec": specPath}})
+		// Another process asks for the switch through the store
+		if err := s.SteerSession(&store.SteeringRequest{SessionID: "sess-switch", Provider: "openai", Model: "gpt-4o"}); err != nil {
+			t.Fatalf("SteerSession failed: %v", err)
+		}
+		if err := r.ExecuteSession(context.Background(), "sess-switch"); err != nil {
+			t.Fatalf("ExecuteSession failed: %v", err)
+		}
+
+		updated, _ := s.GetSession("sess-switch")
+		if updated.Provider != "openai" || updated.Model != "gpt-4o" {
+			t.Errorf("Expected the session on openai/gpt-4o, got %s/%s", updated.Provider, updated.Model)
+		}
+		if got := updated.Metadata[MetadataProviderSwitches]; got != "stub/stub -> openai/gpt-4o (requested by the user)" {
+			t.Errorf("Unexpected switch record %q", got)
+		}
+		if len(switches) != 1 || switches[0].Data["to"] != "openai/gpt-4o" {
+			t.Errorf("Expected one provider_switch event, got %+v", switches)
+		}
+		if r.provider != p || stopped != 1 {
+			t.Errorf("Expected the original provider restored and the switched one stopped, got %s and %d stops", r.provider.Name(), stopped)
+		}
+	})
+
+	t.Run("Escalation", func(t *testing.T) {
+		evidence := filepath.Join(tmpDir, "escalated.txt")
+		specPath := filepath.Join(tmpDir, "spec_escalate.yaml")
+		os.WriteFile(specPath, []byte("goal: test\nevidence: ["+evidence+"]\nescalate:\n  provider: anthropic\n  model: claude-big\n  after: 1"), 0600)
+
+		p := &provider.StubProvider{}
+		mp := mcp.NewProxy(s, g)
+		r := New(s, g, c, o, p, mp)
+		r.SetProviderFactory(func(name, model string) (provider.Provider, func(), error) {
+			// The stronger model gets the evidence in place
+			os.WriteFile(evidence, []byte("done"), 0600)
+			return namedProvider{StubProvider: &provider.StubProvider{}, name: name, model: model}, func() {}, nil
+		})
+
+		s.CreateSession(&store.Session{ID: "sess-escalate", CreatedAt: time.Now(), Status: "active", Metadata: map[string]string{"spec": specPath}})
+		if err := r.ExecuteSession(context.Background(), "sess-escalate"); err != nil {
+			t.Fatalf("ExecuteSession failed: %v", err)
+		}
+
+		updated, _ := s.GetSession("sess-escalate")
+		if updated.Status != "completed" || updated.Provider != "anthropic" {
+			t.Errorf("Expected the session completed on anthropic, got %s on %s", updated.Status, updated.Provider)
+		}
+		if got := updated.Metadata[MetadataProviderSwitches]; got != "stub/stub -> anthropic/claude-big (escalated after 1 failed verifications)" {
+			t.Errorf("Unexpected switch record %q", got)
+		}
+	})
+
+	t.Run("Context Metrics", func(t *testing.T) {
+		specPath := filepath.Join(tmpDir, "spec_context.yaml")
+		os.WriteFile(specPath, []byte("goal: test\nevidence: []"), 0600)
+
+		p := &provider.StubProvider{Responses: []provider.Response{
+			{Content: "Looking around.", Usage: provider.Usage{PromptTokens: 4000}},
+			{Content: "Progress: looked around."},
+			{Content: "Task complete."},
+		}}
+		r := New(s, g, c, o, p, mcp.NewProxy(s, g))
+		var usage, pruned []Event
+		r.EventBus().Subscribe(EventContextUsage, func(e Event) { usage = append(usage, e) })
+		r.EventBus().Subscribe(EventContextPruned, func(e Event) { pruned = append(pruned, e) })
+
+		s.CreateSession(&store.Session{ID: "sess-context", CreatedAt: time.Now(), Status: "active", Metadata: map[string]string{"spec": specPath}})
+		if err := r.ExecuteSession(context.Background(), "sess-context"); err != nil {
+			t.Fatalf("ExecuteSession failed: %v", err)
+		}
+
+		if len(usage) != 2 || usage[0].Data["iteration"] != 1 || usage[0].Data["prompt_tokens"].(int) <= 0 {
+			t.Errorf("Expected a context_usage event per iteration, got %+v", usage)
+		}
+		if len(pruned) != 1 || pruned[0].Data["kind"] != "summarized" {
+			t.Fatalf("Expected one summarization event, got %+v", pruned)
+		}
+		if before, after := pruned[0].Data["tokens_before"].(int), pruned[0].Data["tokens_after"].(int); pruned[0].Data["reclaimed_tokens"] != before-after {
+			t.Errorf("Expected the reclaimed tokens to be the difference, got %+v", pruned[0].Data)
+		}
+	})
+
+	t.Run("Reasoning", func(t *testing.T) {
+		specPath := filepath.Join(tmpDir, "spec_reasoning.yaml")
+		os.WriteFile(specPath, []byte("goal: test\nevidence: []"), 0600)
+
+		thinking := provider.Usage{PromptTokens: 100, CompletionTokens: 700, TotalTokens: 800, ThinkingTokens: 600}
+		p := &provider.StubProvider{Responses: []provider.Response{
+			{Content: "Looking around.", Reasoning: "The user wants a test.", Usage: thinking},
+			{Content: "Still looking.", Reasoning: "Maybe look elsewhere.", Usage: thinking},
+			{Content: "Task complete."},
+		}}
+		policy := guard.DefaultPolicy
+		policy.MaxOutputTokens = 500
+		policy.MaxThinkingTokens = 1000
+		tg := guard.New(policy)
+		r := New(s, tg, c, o, p, mcp.NewProxy(s, tg))
+
+		s.CreateSession(&store.Session{ID: "sess-reasoning", CreatedAt: time.Now(), Status: "active", Metadata: map[string]string{"spec": specPath}})
+		err := r.ExecuteSession(context.Background(), "sess-reasoning")
+		if err == nil || !strings.Contains(err.Error(), "Thinking token budget") {
+			t.Fatalf("Expected the thinking budget to halt the session, not the output budget, got %v", err)
+		}
+
+		artifacts, _ := s.ListArtifacts("sess-reasoning")
+		var traces []string
+		for _, a := range artifacts {
+			if a.Type == ArtifactReasoning {
+				_, content, _ := s.GetArtifact(a.ID)
+				traces = append(traces, string(content))
+			}
+		}
+		if len(traces) != 2 || traces[0] != "The user wants a test." {
+			t.Errorf("Expected a reasoning artifact per response, got %q", traces)
+		}
+		history, _ := r.LoadHistory("sess-reasoning")
+		for _, m := range history {
+			if strings.Contains(m.Content, "The user wants a test.") {
+				t.Errorf("Expected reasoning kept out of the history, got %q", m.Content)
+			}
+		}
+	})
+
+	t.Run("Capabilities", func(t *testing.T) {
+		specPath := filepath.Join(tmpDir, "spec_caps.yaml")
+		os.WriteFile(specPath, []byte("goal: test\nevidence: []"), 0600)
+
+		noTools := &capsProvider{StubProvider: &provider.StubProvider{}}
+		r := New(s, g, c, o, noTools, mcp.NewProxy(s, g))
+		s.CreateSession(&store.Session{ID: "sess-no-tools", CreatedAt: time.Now(), Status: "active", Metadata: map[string]string{"spec": specPath}})
+		if err := r.ExecuteSession(context.Background(), "sess-no-tools"); err != nil {
+			t.Errorf("Expected a provider without known tool support to be warned about only, got %v", err)
+		}
+		if noTools.embeds != 0 {
+			t.Errorf("Expected no embedding requests without embedding support, got %d", noTools.embeds)
+		}
+
+		r = New(s, g, c, o, &provider.StubProvider{}, mcp.NewProxy(s, g))
+		stopped := 0
+		r.SetProviderFactory(func(name, model string) (provider.Provider, func(), error) {
+			return &capsProvider{StubProvider: &provider.StubProvider{}}, func() { stopped++ }, nil
+		})
+		session := &store.Session{ID: "sess-caps-switch", Metadata: map[string]string{}}
+		if _, err := r.switchProvider(session, nil, map[string]string{}, providerChoice{name: "ollama", model: "llama2"}, "test"); err != nil {
+			t.Errorf("Expected a switch to a provider without known tool support to go ahead, got %v", err)
+		}
+		r.restoreProvider(r.provider)
+		if stopped != 1 {
+			t.Errorf("Expected the provider switched to stopped with the session, got %d stops", stopped)
+		}
+	})
+
+	t.Run("Custom Tools", func(t *testing.T) {
+		specPath := filepath.Join(tmpDir, "spec_tools.yaml")
+		os.WriteFile(specPath, []byte("goal: test\nevidence: []"), 0600)
+
+		p := &toolsProvider{StubProvider: &provider.StubProvider{Responses: []provider.Response{
+			{ToolCalls: []provider.ToolCall{{ID: "call-1", Name: "lookup_ticket", Args: `{"id": "OPS-42"}`}}},
+			{Content: "Task complete."},
+		}}}
+		r := New(s, g, c, o, p, mcp.NewProxy(s, g))
+		r.ToolRegistry().Register(ToolDefinition{
+			Name:        "lookup_ticket",
+			Description: "Look up a ticket",
+			Parameters: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{"id": map[string]interface{}{"type": "string"}},
+				"required":   []string{"id"},
+			},
+		}, func(ctx context.Context, sessionID string, call provider.ToolCall) (string, error) {
+			return "ticket " + call.Args, nil
+		})
+		s.CreateSession(&store.Session{ID: "sess-tools", CreatedAt: time.Now(), Status: "active", Metadata: map[string]string{"spec": specPath}})
+		if err := r.ExecuteSession(context.Background(), "sess-tools"); err != nil {
+			t.Fatalf("ExecuteSession failed: %v", err)
+		}
+
+		if len(p.advertised) == 0 || !slices.Contains(p.advertised, "lookup_ticket") || !slices.Contains(p.advertised, "run_shell") {
+			t.Errorf("Expected the custom tool advertised with the built-ins, got %v", p.advertised)
+		}
+		history, _ := r.LoadHistory("sess-tools")
+		var result string
+		for _, m := range history {
+			if m.Role == "tool" && m.ToolCallID == "call-1" {
+				result = m.Content
+			}
+		}
+		if !strings.Contains(result, "ticket") || !strings.Contains(result, "OPS-42") {
+			t.Errorf("Expected the registered executor's output as the tool result, got %q", result)
+		}
+	})
+
+	t.Run("Repeated Tool Failures", func(t *testing.T) {
+		specPath := filepath.Join(tmpDir, "spec_failures.yaml")
+		os.WriteFile(specPath, []byte("goal: test\nevidence: []"), 0600)
+
+		lookup := provider.ToolCall{Name: "lookup_ticket", Args: `{"id": "OPS-42"}`}
+		var calls []provider.ToolCall
+		for i := range 3 {
+			call := lookup
+			call.ID = fmt.Sprintf("call-%d", i)
+			calls = append(calls, call)
+		}
+		p := &provider.StubProvider{Responses: []provider.Response{{ToolCalls: calls}, {Content: "Task complete."}}}
+		r := New(s, g, c, o, p, mcp.NewProxy(s, g))
+		r.ToolRegistry().Register(ToolDefinition{Name: "lookup_ticket", Parameters: map[string]interface{}{"type": "object"}},
+			func(ctx context.Context, sessionID string, call provider.ToolCall) (string, error) {
+				return "", errors.New("ticket service unavailable")
+			})
+		s.CreateSession(&store.Session{ID: "sess-failures", CreatedAt: time.Now(), Status: "active", Metadata: map[string]string{"spec": specPath}})
+		if err := r.ExecuteSession(context.Background(), "sess-failures"); err != nil {
+			t.Fatalf("ExecuteSession failed: %v", err)
+		}
+
+		history, _ := r.LoadHistory("sess-failures")
+		var nudged bool
+		for _, m := range history {
+			if m.Role == "user" && strings.Contains(m.Content, "last 3 `lookup_ticket` attempts failed") && strings.Contains(m.Content, "ticket service unavailable") {
+				nudged = true
+			}
+		}
+		if !nudged {
+			t.Error("Expected the agent to be told about the repeated failure")
+		}
+		updated, _ := s.GetSession("sess-failures")
+		stats := ToolStats(updated)
+		if len(stats) != 1 || stats[0].Calls != 3 || stats[0].Failures != 3 {
+			t.Errorf("Expected three failed lookup_ticket calls, got %+v", stats)
+		}
 	})
-}
\ No newline at end of file
+
+	t.Run("Sampling Params", func(t *testing.T) {
+		specPath := filepath.Join(tmpDir, "spec_params.yaml")
+		os.WriteFile(specPath, []byte("goal: test\nevidence: []\nparams:\n  default:\n    temperature: 0.2\n  summary:\n    max_tokens: 128\n"), 0600)
+
+		policy := guard.DefaultPolicy
+		policy.Params = provider.PhaseParams{Default: provider.Params{MaxTokens: 1024}}
+		pg := guard.New(policy)
+		p := &paramsProvider{StubProvider: &provider.StubProvider{Responses: []provider.Response{{Content: "Task complete."}}}}
+		r := New(s, pg, c, o, p, mcp.NewProxy(s, pg))
+		s.CreateSession(&store.Session{ID: "sess-params", CreatedAt: time.Now(), Status: "active", Metadata: map[string]string{"spec": specPath}})
+		if err := r.ExecuteSession(context.Background(), "sess-params"); err != nil {
+			t.Fatalf("ExecuteSession failed: %v", err)
+		}
+
+		if len(p.params) < 2 {
+			t.Fatalf("Expected an execution and a summary request, got %d requests", len(p.params))
+		}
+		execution, summary := p.params[0], p.params[len(p.params)-1]
+		if execution.Temperature == nil || *execution.Temperature != 0.2 || execution.MaxTokens != 1024 {
+			t.Errorf("Expected the spec's temperature over the policy's max_tokens, got %+v", execution)
+		}
+		if summary.Temperature == nil || *summary.Temperature != 0.2 || summary.MaxTokens != 128 {
+			t.Errorf("Expected the summary's max_tokens, got %+v", summary)
+		}
+	})
+}
+
+// paramsProvider is a stub provider recording the sampling parameters of
+// its requests.
+type paramsProvider struct {
+	*provider.StubProvider
+	params []provider.Params
+}
+
+func (p *paramsProvider) Chat(ctx context.Context, messages []provider.Message) (*provider.Response, error) {
+	p.params = append(p.params, provider.ParamsFromContext(ctx))
+	return p.StubProvider.Chat(ctx, messages)
+}
+
+// toolsProvider is a stub provider recording the tools advertised to it.
+type toolsProvider struct {
+	*provider.StubProvider
+	advertised []string
+}
+
+func (p *toolsProvider) Chat(ctx context.Context, messages []provider.Message) (*provider.Response, error) {
+	p.advertised = p.advertised[:0]
+	for _, spec := range provider.ToolsFromContext(ctx) {
+		p.advertised = append(p.advertised, spec.Name)
+	}
+	return p.StubProvider.Chat(ctx, messages)
+}
+
+// capsProvider is a stub provider reporting no tool calling or embeddings.
+type capsProvider struct {
+	*provider.StubProvider
+	embeds int
+}
+
+func (p *capsProvider) Capabilities() provider.Capabilities { return provider.Capabilities{} }
+
+func (p *capsProvider) Embed(ctx context.Context, text string) ([]float32, error) {
+	p.embeds++
+	return p.StubProvider.Embed(ctx, text)
+}
+
+// failingProvider is a stub provider whose requests fail.
+type failingProvider struct {
+	*provider.StubProvider
+}
+
+func (failingProvider) Chat(context.Context, []provider.Message) (*provider.Response, error) {
+	return nil, errors.New("service unavailable")
+}
+
+// namedProvider is a stub provider with another name and model, as created
+// by a provider factory.
+type namedProvider struct {
+	*provider.StubProvider
+	name, model string
+}
+
+func (n namedProvider) Name() string  { return n.name }
+func (n namedProvider) Model() string { return n.model }
+
+// noEmbedProvider is a provider without embeddings, like the CLI provider.
+type noEmbedProvider struct {
+	*provider.StubProvider
+}
+
+func (noEmbedProvider) Embed(context.Context, string) ([]float32, error) {
+	return nil, errors.New("embeddings are not supported")
+}
+
+func TestProjectNamespace(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "cmd", "tool")
+	os.MkdirAll(sub, 0755)
+	if got := ProjectNamespace(sub); got != sub {
+		t.Errorf("Expected the directory itself outside a repository, got %s", got)
+	}
+	os.Mkdir(filepath.Join(root, ".git"), 0755)
+	if got := ProjectRoot(sub); got != root {
+		t.Errorf("Expected the repository root %s, got %s", root, got)
+	}
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	os.RemoveAll(filepath.Join(root, ".git"))
+	for _, args := range [][]string{{"init", "-q"}, {"remote", "add", "origin", "git@github.com:felixgeelhaar/simon.git"}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = root
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	if got := ProjectNamespace(sub); got != "github.com/felixgeelhaar/simon" {
+		t.Errorf("Expected the origin remote, got %s", got)
+	}
+}
+
+func TestRemoteNamespace(t *testing.T) {
+	for remote, want := range map[string]string{
+		"git@github.com:felixgeelhaar/simon.git":           "github.com/felixgeelhaar/simon",
+		"https://github.com/felixgeelhaar/simon.git":       "github.com/felixgeelhaar/simon",
+		"https://user@github.com/felixgeelhaar/simon/":     "github.com/felixgeelhaar/simon",
+		"ssh://git@gitlab.example.com:2222/team/simon.git": "gitlab.example.com/team/simon",
+		"/srv/git/simon.git":                               "",
+	} {
+		if got := remoteNamespace(remote); got != want {
+			t.Errorf("remoteNamespace(%q) = %q, want %q", remote, got, want)
+		}
+	}
+}
+
+func TestCompactHistory(t *testing.T) {
+	defer func(n int) { compactAfterTokens = n }(compactAfterTokens)
+	compactAfterTokens = 50
+
+	long := strings.Repeat("output line\n", 50)
+	history := []provider.Message{
+		{Role: "user", Content: "Goal: test"},
+		{Role: "assistant", ToolCalls: []provider.ToolCall{{ID: "call_1", Name: "run_shell"}}},
+		{Role: "tool", ToolCallID: "call_1", Content: long},
+		{Role: "assistant", ToolCalls: []provider.ToolCall{{ID: "call_2", Name: "run_shell"}}},
+		{Role: "tool", ToolCallID: "call_2", Content: long},
+	}
+	refs := map[string]string{
+		"call_1": "Tool run_shell output stored at artifacts/s/run_shell_1.txt (600B, exit 0)",
+		"call_2": "Tool run_shell output stored at artifacts/s/run_shell_2.txt (600B, exit 1)",
+	}
+
+	if n := compactHistory(history, refs); n != 1 {
+		t.Fatalf("Expected 1 result replaced, got %d", n)
+	}
+	if history[2].Content != "Tool run_shell output stored at artifacts/s/run_shell_1.txt (600B, exit 0)" || history[4].Content != long {
+		t.Errorf("Expected only the older result replaced, got %+v", history)
+	}
+	if _, ok := refs["call_1"]; ok || len(refs) != 1 {
+		t.Errorf("Expected the used reference to be removed, got %v", refs)
+	}
+
+	compactAfterTokens = 1 << 20
+	history = append(history, provider.Message{Role: "assistant", Content: "Done."})
+	if n := compactHistory(history, refs); n != 0 {
+		t.Errorf("Expected a small history to be left alone, got %d replaced", n)
+	}
+}
+
+func TestReviseSpec(t *testing.T) {
+	spec := coach.TaskSpec{Goal: "port the CLI", Constraints: []string{"Keep the flags"}}
+	pm := &PostMortem{
+		Violations: []PostMortemViolation{
+			{Rule: "allowed_commands", Severity: "block", Subject: "curl example.com", Message: "Command not allowed"},
+			{Rule: "max_iterations", Severity: "halt", Message: "Iteration limit exceeded"},
+		},
+		Unverified: []mcp.EvidenceStatus{{Kind: "verify", Item: "go test ./...", Status: mcp.EvidenceFail}},
+		Plan: []PlanStep{
+			{Description: "Read the old CLI", Status: StepDone},
+			{Description: "Port the commands", Status: StepInProgress},
+			{Description: "Port the tests", Status: StepPending},
+		},
+	}
+
+	revised := reviseSpec(spec, pm)
+	want := []string{"Keep the flags", "Do not run `curl example.com`: the policy doesn't allow it",
+		"Run `go test ./...` and fix what it reports before claiming completion"}
+	if !slices.Equal(revised.Constraints, want) {
+		t.Errorf("Expected constraints %q, got %q", want, revised.Constraints)
+	}
+	if len(spec.Constraints) != 1 {
+		t.Error("Expected the original spec to be left unchanged")
+	}
+	if len(revised.Steps) != 2 || revised.Steps[0].Goal != "Port the commands" {
+		t.Errorf("Expected the unfinished plan steps as a mission, got %+v", revised.Steps)
+	}
+
+	recs := recommend(pm, "sess-1")
+	if len(recs) != 3 || !strings.Contains(recs[0], "splits the unfinished plan steps") || !strings.Contains(recs[1], "curl example.com") {
+		t.Errorf("Unexpected recommendations: %q", recs)
+	}
+
+	// A session that failed for other reasons keeps its shape
+	pm.Violations = pm.Violations[:1]
+	if revised := reviseSpec(spec, pm); len(revised.Steps) != 0 {
+		t.Errorf("Expected no mission without a budget violation, got %+v", revised.Steps)
+	}
+}
@@ -0,0 +1,144 @@
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/provider"
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+// Snapshot is the decoded state of a session when an iteration's request
+// was sent: exactly the context the model saw, and what it answered.
+type Snapshot struct {
+	Iteration     int                `json:"iteration"`
+	Status        string             `json:"status"`
+	PromptTokens  int                `json:"prompt_tokens"` // Session totals before the request
+	OutputTokens  int                `json:"output_tokens"`
+	ContextTokens int                `json:"context_tokens"`
+	Messages      []provider.Message `json:"messages"`
+	Response      *provider.Response `json:"response,omitempty"` // nil when the request failed
+	Error         string             `json:"error,omitempty"`
+	CreatedAt     time.Time          `json:"created_at"`
+}
+
+// fullSnapshotInterval is how many iterations may pass between snapshots
+// that store the whole context; the ones in between store only the messages
+// added since the previous iteration.
+const fullSnapshotInterval = 10
+
+// snapshotDeltas remembers a session's previous snapshot so the next one
+// can store only what changed.
+type snapshotDeltas struct {
+	previous  []string // The encoded messages of the previous snapshot
+	sinceFull int
+}
+
+// saveSnapshot stores the state of an iteration after its provider request.
+// Failures are logged; a missing snapshot must not stop the session.
+func (r *Runtime) saveSnapshot(deltas *snapshotDeltas, snap *store.Snapshot, history []provider.Message, resp *provider.Response, chatErr error) {
+	encoded := make([]string, len(history))
+	for i, m := range history {
+		data, err := json.Marshal(m)
+		if err != nil {
+			r.observe.Log().Warn().Err(err).Msg("failed to encode state snapshot")
+			return
+		}
+		encoded[i] = string(data)
+	}
+	// Share the previous iteration's messages unless the history was
+	// compacted or a full snapshot is due, which bounds the chain a load
+	// has to follow.
+	base := 0
+	if deltas.sinceFull+1 < fullSnapshotInterval {
+		for base < len(deltas.previous) && base < len(encoded) && deltas.previous[base] == encoded[base] {
+			base++
+		}
+		if base < len(deltas.previous) {
+			base = 0
+		}
+	}
+	if base == 0 {
+		deltas.sinceFull = 0
+	} else {
+		deltas.sinceFull++
+	}
+	deltas.previous = encoded
+
+	snap.BaseMessages = base
+	snap.Messages = "[" + strings.Join(encoded[base:], ",") + "]"
+	if resp != nil {
+		data, err := json.Marshal(resp)
+		if err != nil {
+			r.observe.Log().Warn().Err(err).Msg("failed to encode state snapshot")
+			return
+		}
+		snap.Response = string(data)
+	}
+	if chatErr != nil {
+		snap.Error = chatErr.Error()
+	}
+	if err := r.store.SaveSnapshot(snap); err != nil {
+		// The next snapshot must not build on one that was not stored
+		deltas.previous = nil
+		r.observe.Log().Warn().Err(err).Int("iteration", snap.Iteration).Msg("failed to save state snapshot")
+	}
+}
+
+// LoadSnapshot returns the state of a session at an iteration, following
+// delta snapshots back to the last full one.
+func LoadSnapshot(s store.Storage, sessionID string, iteration int) (*Snapshot, error) {
+	stored, err := s.GetSnapshot(sessionID, iteration)
+	if err != nil {
+		return nil, err
+	}
+	snap := &Snapshot{
+		Iteration:     stored.Iteration,
+		Status:        stored.Status,
+		PromptTokens:  stored.PromptTokens,
+		OutputTokens:  stored.OutputTokens,
+		ContextTokens: stored.ContextTokens,
+		Error:         stored.Error,
+		CreatedAt:     stored.CreatedAt,
+	}
+	if snap.Messages, err = snapshotMessages(s, stored); err != nil {
+		return nil, err
+	}
+	if stored.Response != "" {
+		snap.Response = &provider.Response{}
+		if err := json.Unmarshal([]byte(stored.Response), snap.Response); err != nil {
+			return nil, fmt.Errorf("invalid snapshot of iteration %d: %w", iteration, err)
+		}
+	}
+	return snap, nil
+}
+
+// snapshotMessages decodes the full context of a stored snapshot, reading
+// the earlier snapshots a delta builds on.
+func snapshotMessages(s store.Storage, stored *store.Snapshot) ([]provider.Message, error) {
+	chain := []*store.Snapshot{stored}
+	for snap := stored; snap.BaseMessages > 0; {
+		var err error
+		if snap, err = s.GetSnapshot(snap.SessionID, snap.Iteration-1); err != nil {
+			return nil, fmt.Errorf("snapshot of iteration %d is incomplete: %w", stored.Iteration, err)
+		}
+		chain = append(chain, snap)
+	}
+
+	var messages []provider.Message
+	for i := len(chain) - 1; i >= 0; i-- {
+		snap := chain[i]
+		if snap.BaseMessages > len(messages) {
+			return nil, fmt.Errorf("invalid snapshot of iteration %d: it builds on %d messages, iteration %d has %d",
+				snap.Iteration, snap.BaseMessages, snap.Iteration-1, len(messages))
+		}
+		var added []provider.Message
+		if err := json.Unmarshal([]byte(snap.Messages), &added); err != nil {
+			return nil, fmt.Errorf("invalid snapshot of iteration %d: %w", snap.Iteration, err)
+		}
+		messages = append(messages[:snap.BaseMessages:snap.BaseMessages], added...)
+	}
+	return messages, nil
+}
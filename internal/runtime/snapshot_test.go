@@ -0,0 +1,59 @@
+package runtime
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/coach"
+	"github.com/felixgeelhaar/simon/internal/guard"
+	"github.com/felixgeelhaar/simon/internal/observe"
+	"github.com/felixgeelhaar/simon/internal/provider"
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+func TestSaveSnapshot_Deltas(t *testing.T) {
+	tmpDir := t.TempDir()
+	s, _ := store.NewSQLiteStore(filepath.Join(tmpDir, "db"), filepath.Join(tmpDir, "artifacts"))
+	defer s.Close()
+	s.CreateSession(&store.Session{ID: "sess-snap", CreatedAt: time.Now()})
+	r := New(s, guard.New(guard.DefaultPolicy), coach.New(), observe.New(io.Discard, false), provider.NewStubProvider(), nil)
+
+	var deltas snapshotDeltas
+	history := []provider.Message{{Role: "user", Content: "Goal: fix the build"}}
+	for i := 1; i <= 15; i++ {
+		if i == 5 {
+			// Compaction rewrites the history, so nothing can be shared
+			history = []provider.Message{{Role: "user", Content: "Summary of iterations 1-4"}}
+		}
+		r.saveSnapshot(&deltas, &store.Snapshot{SessionID: "sess-snap", Iteration: i}, history, &provider.Response{Content: "ok"}, nil)
+		history = append(history, provider.Message{Role: "assistant", Content: fmt.Sprintf("step %d", i)})
+	}
+
+	// Full snapshots at the start, after the compaction, and at the interval
+	snaps, _ := s.ListSnapshots("sess-snap")
+	var full []int
+	for _, snap := range snaps {
+		if snap.BaseMessages == 0 {
+			full = append(full, snap.Iteration)
+		}
+	}
+	if fmt.Sprint(full) != fmt.Sprint([]int{1, 5, 5 + fullSnapshotInterval}) {
+		t.Errorf("Unexpected full snapshots at iterations %v", full)
+	}
+
+	for iteration, want := range map[int]int{1: 1, 4: 4, 5: 1, 13: 9, 15: 11} {
+		snap, err := LoadSnapshot(s, "sess-snap", iteration)
+		if err != nil {
+			t.Fatalf("LoadSnapshot(%d) failed: %v", iteration, err)
+		}
+		if len(snap.Messages) != want || snap.Response == nil {
+			t.Errorf("Iteration %d: expected %d messages, got %+v", iteration, want, snap.Messages)
+		}
+		if iteration > 5 && snap.Messages[0].Content != "Summary of iterations 1-4" {
+			t.Errorf("Iteration %d: expected the compacted history, got %+v", iteration, snap.Messages)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/coach"
+	"github.com/felixgeelhaar/simon/internal/guard"
+	"github.com/felixgeelhaar/simon/internal/mcp"
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+// ArtifactSpec holds the spec a session started with. Sub-tasks have no
+// spec file, so it is their only copy; for other sessions it is a snapshot
+// that later edits to the spec file don't change.
+const ArtifactSpec = "spec"
+
+// DefaultSubtaskIterations is a sub-task's iteration budget when none is
+// requested. Budgets are always capped by the parent's policy.
+const DefaultSubtaskIterations = 10
+
+// ErrNestedSubtask is returned when a sub-task tries to spawn another.
+var ErrNestedSubtask = errors.New("sub-tasks cannot spawn sub-tasks")
+
+// SubtaskResult is the outcome of a sub-task session.
+type SubtaskResult struct {
+	SessionID        string
+	Status           string
+	Summary          string
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64
+	// Err is the error the child session ended with, if any.
+	Err error
+}
+
+// Report renders the result as the spawn_subtask tool output.
+func (res *SubtaskResult) Report() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Sub-task %s %s (%d prompt + %d completion tokens, $%.4f).\n",
+		res.SessionID, res.Status, res.PromptTokens, res.CompletionTokens, res.Cost)
+	if res.Err != nil {
+		fmt.Fprintf(&b, "Error: %v\n", res.Err)
+	}
+	if res.Summary != "" {
+		fmt.Fprintf(&b, "Summary: %s\n", res.Summary)
+	}
+	return b.String()
+}
+
+// subtaskUsage is child usage not yet added to the parent session.
+type subtaskUsage struct {
+	promptTokens     int
+	completionTokens int
+	cost             float64
+}
+
+// SpawnSubtask runs spec as a child session of parentID with its own guard
+// budget: the parent's policy with MaxIterations capped at maxIterations (or
+// DefaultSubtaskIterations when 0). The child's usage is rolled up into the
+// parent. An error is returned only when the child cannot be started; how
+// the child ended is reported in the result.
+func (r *Runtime) SpawnSubtask(ctx context.Context, parentID string, spec coach.TaskSpec, maxIterations int) (*SubtaskResult, error) {
+	parent, err := r.store.GetSession(parentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load parent session: %w", err)
+	}
+	if parent.ParentID != "" {
+		return nil, ErrNestedSubtask
+	}
+	if validation := r.coach.Validate(spec); !validation.Valid {
+		return nil, fmt.Errorf("invalid sub-task spec: %s", strings.Join(validation.Errors, ", "))
+	}
+
+	siblings, err := r.store.ListSessions(store.SessionFilter{ParentID: parentID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sub-tasks: %w", err)
+	}
+	child := &store.Session{
+		ID:        fmt.Sprintf("%s-sub%d", parentID, len(siblings)+1),
+		CreatedAt: time.Now(),
+		Status:    "initialized",
+		Metadata: map[string]string{
+			"env":                   parent.Metadata["env"],
+			MetadataMemoryNamespace: parent.Metadata[MetadataMemoryNamespace],
+			MetadataGlobalMemory:    parent.Metadata[MetadataGlobalMemory],
+		},
+		Tags:     parent.Tags,
+		ParentID: parentID,
+	}
+	if err := r.store.CreateSession(child); err != nil {
+		return nil, fmt.Errorf("failed to create sub-task session: %w", err)
+	}
+	if err := r.saveSpec(child.ID, spec); err != nil {
+		return nil, fmt.Errorf("failed to save sub-task spec: %w", err)
+	}
+
+	policy := r.guard.Policy()
+	if maxIterations <= 0 {
+		maxIterations = DefaultSubtaskIterations
+	}
+	if maxIterations < policy.MaxIterations {
+		policy.MaxIterations = maxIterations
+	}
+	g := guard.New(policy)
+	sub := New(r.store, g, r.coach, r.observe, r.provider, r.mcpProxy.Child(g))
+	sub.providerFactory, sub.embedder, sub.limiter, sub.orchestrator = r.providerFactory, r.embedder, r.limiter, r.orchestrator
+	sub.toolRegistry.inherit(r.toolRegistry)
+
+	r.ui.Log(fmt.Sprintf("🧩 Sub-task %s: %s", child.ID, truncateString(spec.Goal, 60)))
+	r.eventBus.PublishWithData(EventSubtaskStart, parentID, map[string]interface{}{
+		"subtask": child.ID,
+		"goal":    spec.Goal,
+	})
+	execErr := sub.ExecuteSession(ctx, child.ID)
+
+	if reloaded, err := r.store.GetSession(child.ID); err == nil {
+		child = reloaded
+	}
+	if execErr != nil && !store.SessionFinished(child.Status) {
+		child.Status = "failed"
+		_ = r.store.UpdateSession(child)
+	}
+	r.addSubtaskUsage(parentID, child)
+
+	res := &SubtaskResult{
+		SessionID:        child.ID,
+		Status:           child.Status,
+		Summary:          r.subtaskSummary(child.ID),
+		PromptTokens:     child.PromptTokens,
+		CompletionTokens: child.CompletionTokens,
+		Cost:             child.Cost,
+		Err:              execErr,
+	}
+	r.ui.Log(fmt.Sprintf("🧩 Sub-task %s %s ($%.4f)", child.ID, child.Status, child.Cost))
+	r.eventBus.PublishWithData(EventSubtaskEnd, parentID, map[string]interface{}{
+		"subtask": child.ID,
+		"status":  child.Status,
+		"cost":    child.Cost,
+	})
+	return res, nil
+}
+
+// RunSubtask implements mcp.SubtaskRunner for the spawn_subtask tool.
+func (r *Runtime) RunSubtask(ctx context.Context, parentID string, req mcp.SubtaskRequest) (string, error) {
+	spec := coach.TaskSpec{
+		Goal:             req.Goal,
+		DefinitionOfDone: req.DefinitionOfDone,
+		Constraints:      req.Constraints,
+		Evidence:         req.Evidence,
+		Verify:           req.Verify,
+	}
+	if spec.DefinitionOfDone == "" {
+		spec.DefinitionOfDone = req.Goal
+	}
+	// Sub-tasks run with their parent's commands, environment and denied
+	// files, so spawning one can't widen what the agent may do, and sample
+	// like it
+	if parent, err := r.store.GetSession(parentID); err == nil {
+		if parentSpec, err := r.loadSpec(parent); err == nil {
+			spec.AllowedCommands = parentSpec.AllowedCommands
+			spec.Env = parentSpec.Env
+			spec.DeniedFileGlobs = parentSpec.DeniedFileGlobs
+			spec.Params = parentSpec.Params
+		}
+	}
+	res, err := r.SpawnSubtask(ctx, parentID, spec, req.MaxIterations)
+	if err != nil {
+		return "", err
+	}
+	// A halt inside the child must not stop the parent, so only the report is returned
+	return res.Report(), nil
+}
+
+// saveSpec stores the spec a session runs with as its spec artifact.
+func (r *Runtime) saveSpec(sessionID string, spec coach.TaskSpec) error {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	artifact := &store.Artifact{
+		ID:        fmt.Sprintf("art-%s-%s", sessionID, ArtifactSpec),
+		SessionID: sessionID,
+		Path:      fmt.Sprintf("artifacts/%s/spec.json", sessionID),
+		Type:      ArtifactSpec,
+		CreatedAt: time.Now(),
+	}
+	return r.store.SaveArtifact(artifact, data)
+}
+
+// loadSpec returns a session's spec: the spec file named in its metadata,
+// resolved with the session's variables, or, for sub-tasks, the stored spec
+// artifact.
+func (r *Runtime) loadSpec(session *store.Session) (*coach.TaskSpec, error) {
+	if path := session.Metadata["spec"]; path != "" {
+		spec, err := r.coach.LoadSpecWithVars(path, SessionVars(session))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load spec from %s: %w", path, err)
+		}
+		return spec, nil
+	}
+	_, data, err := r.store.GetArtifact(fmt.Sprintf("art-%s-%s", session.ID, ArtifactSpec))
+	if err != nil {
+		return nil, fmt.Errorf("session %s has no spec", session.ID)
+	}
+	var spec coach.TaskSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("invalid spec for session %s: %w", session.ID, err)
+	}
+	return &spec, nil
+}
+
+// subtaskSummary describes what a finished sub-task did: its summary
+// artifact, or else its last response, followed by the files it changed.
+func (r *Runtime) subtaskSummary(sessionID string) string {
+	var summary string
+	if _, content, err := r.store.GetArtifact(fmt.Sprintf("art-%s-summary", sessionID)); err == nil {
+		summary = string(content)
+	} else if messages, err := r.store.LoadMessages(sessionID); err == nil {
+		for i := len(messages) - 1; i >= 0; i-- {
+			if messages[i].Role == "assistant" && messages[i].Content != "" {
+				summary = messages[i].Content
+				break
+			}
+		}
+	}
+	if _, content, err := r.store.GetArtifact(fmt.Sprintf("art-%s-changes", sessionID)); err == nil {
+		var changes []mcp.FileChange
+		if json.Unmarshal(content, &changes) == nil {
+			summary = strings.TrimSpace(summary + "\n" + describeChanges(changes))
+		}
+	}
+	return summary
+}
+
+// addSubtaskUsage queues a finished child's usage for its parent.
+func (r *Runtime) addSubtaskUsage(parentID string, child *store.Session) {
+	r.subtaskMu.Lock()
+	defer r.subtaskMu.Unlock()
+	u := r.subtaskUsage[parentID]
+	u.promptTokens += child.PromptTokens
+	u.completionTokens += child.CompletionTokens
+	u.cost += child.Cost
+	r.subtaskUsage[parentID] = u
+}
+
+// absorbSubtasks adds queued child usage to the parent session's totals. It
+// does not count against the parent's own token budget.
+func (r *Runtime) absorbSubtasks(session *store.Session) {
+	r.subtaskMu.Lock()
+	u, ok := r.subtaskUsage[session.ID]
+	delete(r.subtaskUsage, session.ID)
+	r.subtaskMu.Unlock()
+	if !ok {
+		return
+	}
+	session.PromptTokens += u.promptTokens
+	session.CompletionTokens += u.completionTokens
+	session.Cost += u.cost
+}
+
+var _ mcp.SubtaskRunner = (*Runtime)(nil)
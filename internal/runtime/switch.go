@@ -0,0 +1,106 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/felixgeelhaar/simon/internal/provider"
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+// MetadataProviderSwitches records the provider switches of a session, one
+// "from -> to (reason)" entry each, separated by "; ".
+const MetadataProviderSwitches = "provider_switches"
+
+// ProviderFactory creates the named provider with model; an empty model
+// uses the provider's default. The returned stop function releases the
+// provider and is never nil.
+type ProviderFactory func(name, model string) (provider.Provider, func(), error)
+
+// SetProviderFactory enables switching providers mid-session: through
+// SwitchProvider, `simon steer --provider/--model`, or a spec's escalate.
+func (r *Runtime) SetProviderFactory(f ProviderFactory) {
+	r.providerFactory = f
+}
+
+// switchProvider moves the session onto choice and returns the history
+// normalized for the new provider's API. References in toolRefs follow
+// their tool results to the reissued IDs. A choice naming the current
+// provider and model is a no-op.
+func (r *Runtime) switchProvider(session *store.Session, history []provider.Message, toolRefs map[string]string, choice providerChoice, reason string) ([]provider.Message, error) {
+	if r.providerFactory == nil {
+		return history, errors.New("provider switching is not configured")
+	}
+	name := choice.name
+	if name == "" {
+		name = r.provider.Name()
+	}
+	if name == r.provider.Name() && (choice.model == "" || choice.model == r.provider.Model()) {
+		return history, nil
+	}
+	p, stop, err := r.providerFactory(name, choice.model)
+	if err != nil {
+		return history, fmt.Errorf("failed to create provider %s: %w", name, err)
+	}
+	r.warnNoTools(session.ID, p)
+	r.providerStops = append(r.providerStops, stop)
+
+	from := r.provider.Name() + "/" + r.provider.Model()
+	to := p.Name() + "/" + p.Model()
+	r.provider = p
+	session.Provider, session.Model = p.Name(), p.Model()
+	entry := fmt.Sprintf("%s -> %s (%s)", from, to, reason)
+	if prev := session.Metadata[MetadataProviderSwitches]; prev != "" {
+		entry = prev + "; " + entry
+	}
+	session.Metadata[MetadataProviderSwitches] = entry
+
+	r.observe.Log().Info().Str("sessionID", session.ID).Str("from", from).Str("to", to).Str("reason", reason).Msg("switched provider")
+	r.ui.Log(fmt.Sprintf("🔀 Switched to %s (%s)", to, reason))
+	r.eventBus.PublishWithData(EventProviderSwitch, session.ID, map[string]interface{}{"from": from, "to": to, "reason": reason})
+
+	normalized := provider.NormalizeHistory(history, p.Name())
+	refs := make(map[string]string, len(toolRefs))
+	for i, m := range history {
+		if ref, ok := toolRefs[m.ToolCallID]; ok && normalized[i].ToolCallID != "" {
+			refs[normalized[i].ToolCallID] = ref
+		}
+	}
+	clear(toolRefs)
+	for id, ref := range refs {
+		toolRefs[id] = ref
+	}
+	return normalized, nil
+}
+
+// warnNoTools warns before any request is sent to p when its model is
+// known not to call tools, which the session loop depends on. Support is
+// judged by the model's name (provider.ModelCapabilities), which may be
+// wrong for a fine-tune or a custom tag, so the session goes ahead.
+func (r *Runtime) warnNoTools(sessionID string, p provider.Provider) {
+	if provider.CapabilitiesOf(p).Tools {
+		return
+	}
+	r.observe.Log().Warn().Str("sessionID", sessionID).Str("provider", p.Name()).Str("model", p.Model()).Msg("model may not support tool calling")
+	r.ui.Log(fmt.Sprintf("⚠️  %s model %q may not support tool calling; if the session makes no progress, choose another model", p.Name(), p.Model()))
+}
+
+// warnIgnoredParams warns when the session sets sampling parameters that p
+// doesn't send, such as a CLI agent or a plugin.
+func (r *Runtime) warnIgnoredParams(sessionID string, p provider.Provider, params provider.PhaseParams) {
+	if params.IsZero() || provider.CapabilitiesOf(p).Params {
+		return
+	}
+	r.observe.Log().Warn().Str("sessionID", sessionID).Str("provider", p.Name()).Str("model", p.Model()).Msg("provider ignores sampling parameters")
+	r.ui.Log(fmt.Sprintf("⚠️  %s ignores the session's sampling parameters (temperature, top_p, max_tokens, stop)", p.Name()))
+}
+
+// restoreProvider puts p back as the runtime's provider when a session
+// ends, releasing the providers switched to during it.
+func (r *Runtime) restoreProvider(p provider.Provider) {
+	r.provider = p
+	for _, stop := range r.providerStops {
+		stop()
+	}
+	r.providerStops = nil
+}
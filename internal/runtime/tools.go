@@ -3,8 +3,11 @@ package runtime
 import (
 	"context"
 	"fmt"
+	"maps"
+	"slices"
 	"sync"
 
+	"github.com/felixgeelhaar/simon/internal/mcp"
 	"github.com/felixgeelhaar/simon/internal/provider"
 )
 
@@ -20,12 +23,23 @@ type ToolExecutor func(ctx context.Context, sessionID string, call provider.Tool
 
 // ToolRegistry manages available tools and their execution.
 // It provides a centralized way to register, discover, and execute tools.
+//
+// A runtime's registry starts with the MCP proxy's built-in tools, and the
+// proxy runs every tool call of its sessions through it: tools registered
+// with it are offered to the model and executed like the built-in ones.
 type ToolRegistry struct {
 	mu        sync.RWMutex
 	tools     map[string]ToolDefinition
 	executors map[string]ToolExecutor
+	order     []string // Tool names in registration order
+
+	check ToolCheck
 }
 
+// ToolCheck decides before Execute runs a call whether it may: nil lets it
+// run, and an error fails the call with it.
+type ToolCheck func(sessionID string, call provider.ToolCall) error
+
 // NewToolRegistry creates a new tool registry.
 func NewToolRegistry() *ToolRegistry {
 	return &ToolRegistry{
@@ -45,9 +59,39 @@ func (tr *ToolRegistry) Register(tool ToolDefinition, executor ToolExecutor) err
 
 	tr.tools[tool.Name] = tool
 	tr.executors[tool.Name] = executor
+	tr.order = append(tr.order, tool.Name)
 	return nil
 }
 
+// RegisterBuiltins registers the built-in tools of mp that provider.Tools
+// describes.
+func (tr *ToolRegistry) RegisterBuiltins(mp *mcp.Proxy) error {
+	builtins := mp.BuiltinTools()
+	for _, spec := range provider.Tools {
+		run, ok := builtins[spec.Name]
+		if !ok {
+			continue
+		}
+		tool := ToolDefinition{Name: spec.Name, Description: spec.Description, Parameters: spec.JSONSchema()}
+		if err := tr.Register(tool, ToolExecutor(run)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inherit registers the tools of parent that tr doesn't have yet, so a
+// sub-task can call the tools registered with its parent's runtime.
+func (tr *ToolRegistry) inherit(parent *ToolRegistry) {
+	parent.mu.RLock()
+	defer parent.mu.RUnlock()
+	for _, name := range parent.order {
+		if !tr.HasTool(name) {
+			_ = tr.Register(parent.tools[name], parent.executors[name])
+		}
+	}
+}
+
 // Unregister removes a tool from the registry.
 func (tr *ToolRegistry) Unregister(name string) {
 	tr.mu.Lock()
@@ -55,6 +99,7 @@ func (tr *ToolRegistry) Unregister(name string) {
 
 	delete(tr.tools, name)
 	delete(tr.executors, name)
+	tr.order = slices.DeleteFunc(tr.order, func(n string) bool { return n == name })
 }
 
 // Get returns a tool definition by name.
@@ -66,28 +111,94 @@ func (tr *ToolRegistry) Get(name string) (ToolDefinition, bool) {
 	return tool, ok
 }
 
-// List returns all registered tool definitions.
+// List returns all registered tool definitions, in registration order.
 func (tr *ToolRegistry) List() []ToolDefinition {
 	tr.mu.RLock()
 	defer tr.mu.RUnlock()
 
-	tools := make([]ToolDefinition, 0, len(tr.tools))
-	for _, tool := range tr.tools {
-		tools = append(tools, tool)
+	tools := make([]ToolDefinition, 0, len(tr.order))
+	for _, name := range tr.order {
+		tools = append(tools, tr.tools[name])
 	}
 	return tools
 }
 
-// Execute runs a tool and returns its result.
+// Executor returns the executor of a registered tool, without the guard
+// check Execute adds; it implements mcp.ToolSet for the proxy, which runs
+// the check itself.
+func (tr *ToolRegistry) Executor(name string) (mcp.ToolFunc, bool) {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+
+	executor := tr.executors[name]
+	if executor == nil {
+		return nil, false
+	}
+	return mcp.ToolFunc(executor), true
+}
+
+// Specs returns the registered tools as the provider advertises them, in
+// registration order. The parameters' JSON schema is sent as is; APIs that
+// take a parameter list get its properties as string parameters.
+func (tr *ToolRegistry) Specs() []provider.ToolSpec {
+	specs := make([]provider.ToolSpec, 0, tr.Count())
+	for _, tool := range tr.List() {
+		spec := provider.ToolSpec{Name: tool.Name, Description: tool.Description, Schema: tool.Parameters}
+		props, _ := tool.Parameters["properties"].(map[string]interface{})
+		required := make(map[string]bool)
+		switch req := tool.Parameters["required"].(type) {
+		case []string:
+			for _, name := range req {
+				required[name] = true
+			}
+		case []interface{}:
+			for _, name := range req {
+				if s, ok := name.(string); ok {
+					required[s] = true
+				}
+			}
+		}
+		for _, name := range slices.Sorted(maps.Keys(props)) {
+			param := provider.ToolParam{Name: name, Required: required[name]}
+			if prop, ok := props[name].(map[string]interface{}); ok {
+				param.Description, _ = prop["description"].(string)
+			}
+			spec.Params = append(spec.Params, param)
+		}
+		specs = append(specs, spec)
+	}
+	return specs
+}
+
+// SetCheck makes Execute run check before every call; nil disables the
+// check. A runtime's registry checks calls as its proxy does
+// (mcp.Proxy.CheckToolCall), reporting every violation with the session's.
+func (tr *ToolRegistry) SetCheck(check ToolCheck) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	tr.check = check
+}
+
+// Execute runs a tool and returns its result. A call the check refuses
+// fails without running, with a *guard.ViolationError when the violation
+// halts the session.
 func (tr *ToolRegistry) Execute(ctx context.Context, sessionID string, call provider.ToolCall) (string, error) {
 	tr.mu.RLock()
 	executor, ok := tr.executors[call.Name]
+	check := tr.check
 	tr.mu.RUnlock()
 
 	if !ok {
 		return "", fmt.Errorf("unknown tool: %s", call.Name)
 	}
 
+	if check != nil {
+		if err := check(sessionID, call); err != nil {
+			return "", err
+		}
+	}
+
 	return executor(ctx, sessionID, call)
 }
 
@@ -113,8 +224,9 @@ func (tr *ToolRegistry) GetToolsForProvider() []map[string]interface{} {
 	tr.mu.RLock()
 	defer tr.mu.RUnlock()
 
-	result := make([]map[string]interface{}, 0, len(tr.tools))
-	for _, tool := range tr.tools {
+	result := make([]map[string]interface{}, 0, len(tr.order))
+	for _, name := range tr.order {
+		tool := tr.tools[name]
 		result = append(result, map[string]interface{}{
 			"type": "function",
 			"function": map[string]interface{}{
@@ -3,9 +3,14 @@ package runtime
 import (
 	"context"
 	"errors"
+	"path/filepath"
+	"slices"
 	"testing"
 
+	"github.com/felixgeelhaar/simon/internal/guard"
+	"github.com/felixgeelhaar/simon/internal/mcp"
 	"github.com/felixgeelhaar/simon/internal/provider"
+	"github.com/felixgeelhaar/simon/internal/store"
 )
 
 func TestNewToolRegistry(t *testing.T) {
@@ -248,3 +253,114 @@ func TestToolRegistry_ExecuteWithContext(t *testing.T) {
 		t.Errorf("expected 'done', got %q", result)
 	}
 }
+
+func TestToolRegistry_ExecuteGuarded(t *testing.T) {
+	tr := NewToolRegistry()
+
+	executed := 0
+	executor := func(ctx context.Context, sessionID string, call provider.ToolCall) (string, error) {
+		executed++
+		return "ran", nil
+	}
+	tr.Register(ToolDefinition{Name: "run_shell"}, executor)
+	tr.Register(ToolDefinition{Name: "write_file"}, executor)
+
+	g := guard.New(guard.Policy{
+		AllowedCommands:  []string{"ls"},
+		AllowedFileGlobs: []string{"src/**"},
+		Severities: map[string]guard.Severity{
+			"allowed_file_globs": guard.SeverityWarn,
+			"denied_file_globs":  guard.SeverityHalt,
+		},
+	})
+	mp := mcp.NewProxy(nil, g)
+	mp.SetScope("sess-1", mcp.Scope{DeniedFileGlobs: []string{"sess-1.key"}})
+	var reported []string
+	tr.SetCheck(func(sessionID string, call provider.ToolCall) error {
+		return mp.CheckToolCall(sessionID, call, func(v *guard.Violation) {
+			reported = append(reported, v.Rule+":"+string(v.Severity))
+		})
+	})
+
+	if _, err := tr.Execute(context.Background(), "sess-1", provider.ToolCall{Name: "run_shell", Args: `{"cmd": "ls"}`}); err != nil {
+		t.Errorf("expected an allowed command to run, got %v", err)
+	}
+	if _, err := tr.Execute(context.Background(), "sess-1", provider.ToolCall{Name: "run_shell", Args: `{"cmd": "rm -rf ."}`}); !mcp.IsBlocked(err) {
+		t.Errorf("expected a blocked command, got %v", err)
+	}
+	if _, err := tr.Execute(context.Background(), "sess-1", provider.ToolCall{Name: "write_file", Args: `{"path": "notes.txt", "content": "x"}`}); err != nil {
+		t.Errorf("expected a warned write to run, got %v", err)
+	}
+	if _, err := tr.Execute(context.Background(), "sess-1", provider.ToolCall{Name: "write_file", Args: `{"path": "sess-1.key", "content": "x"}`}); !guard.IsHalt(err) {
+		t.Errorf("expected the session's denied file to halt, got %v", err)
+	}
+	if executed != 2 {
+		t.Errorf("expected the allowed and warned calls to run, ran %d", executed)
+	}
+	want := []string{"allowed_commands:block", "allowed_file_globs:warn", "denied_file_globs:halt"}
+	if !slices.Equal(reported, want) {
+		t.Errorf("expected violations %v to be reported, got %v", want, reported)
+	}
+}
+
+func TestToolRegistry_Specs(t *testing.T) {
+	tr := NewToolRegistry()
+	tr.Register(ToolDefinition{Name: "zeta"}, nil)
+	tr.Register(ToolDefinition{
+		Name:        "alpha",
+		Description: "First letter",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"path": map[string]interface{}{"type": "string", "description": "File path"},
+				"mode": map[string]interface{}{"type": "string"},
+			},
+			"required": []interface{}{"path"},
+		},
+	}, nil)
+
+	specs := tr.Specs()
+	if len(specs) != 2 || specs[0].Name != "zeta" || specs[1].Name != "alpha" {
+		t.Fatalf("expected specs in registration order, got %+v", specs)
+	}
+	alpha := specs[1]
+	if alpha.Schema["type"] != "object" || alpha.JSONSchema()["required"] == nil {
+		t.Errorf("expected the registered schema sent as is, got %v", alpha.JSONSchema())
+	}
+	want := []provider.ToolParam{{Name: "mode"}, {Name: "path", Description: "File path", Required: true}}
+	if len(alpha.Params) != 2 || alpha.Params[0] != want[0] || alpha.Params[1] != want[1] {
+		t.Errorf("expected params %+v, got %+v", want, alpha.Params)
+	}
+
+	if _, ok := tr.Executor("zeta"); ok {
+		t.Error("expected no executor for a tool registered without one")
+	}
+}
+
+func TestToolRegistry_RegisterBuiltins(t *testing.T) {
+	tmpDir := t.TempDir()
+	s, err := store.NewSQLiteStore(filepath.Join(tmpDir, "db"), filepath.Join(tmpDir, "artifacts"))
+	if err != nil {
+		t.Fatalf("NewSQLiteStore failed: %v", err)
+	}
+	defer s.Close()
+
+	tr := NewToolRegistry()
+	if err := tr.RegisterBuiltins(mcp.NewProxy(s, guard.New(guard.DefaultPolicy))); err != nil {
+		t.Fatalf("RegisterBuiltins failed: %v", err)
+	}
+	if tr.Count() != len(provider.Tools) {
+		t.Errorf("expected %d built-in tools, got %d", len(provider.Tools), tr.Count())
+	}
+	for i, tool := range tr.List() {
+		if tool.Name != provider.Tools[i].Name {
+			t.Errorf("expected %s at %d, got %s", provider.Tools[i].Name, i, tool.Name)
+		}
+		if _, ok := tr.Executor(tool.Name); !ok {
+			t.Errorf("expected an executor for %s", tool.Name)
+		}
+	}
+	if err := tr.RegisterBuiltins(mcp.NewProxy(s, guard.New(guard.DefaultPolicy))); err == nil {
+		t.Error("expected registering the built-ins twice to fail")
+	}
+}
@@ -0,0 +1,188 @@
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/mcp"
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+// MetadataToolStats records a session's tool usage as a JSON list of
+// ToolStat, for simon show and simon share.
+const MetadataToolStats = "tool_stats"
+
+// repeatedFailures is how many calls of a tool in a row that fail for the
+// same reason make the runtime tell the agent to change its approach.
+const repeatedFailures = 3
+
+// ToolStat is the usage of one tool in a session. run_shell is counted per
+// command, e.g. "run_shell: go test".
+type ToolStat struct {
+	Tool     string        `json:"tool"`
+	Calls    int           `json:"calls"`
+	Failures int           `json:"failures"`
+	Duration time.Duration `json:"duration"` // Total time spent in the calls
+	Max      time.Duration `json:"max"`
+}
+
+// FailureRate returns the share of the calls that failed.
+func (s ToolStat) FailureRate() float64 {
+	if s.Calls == 0 {
+		return 0
+	}
+	return float64(s.Failures) / float64(s.Calls)
+}
+
+// Average returns the mean duration of the calls.
+func (s ToolStat) Average() time.Duration {
+	if s.Calls == 0 {
+		return 0
+	}
+	return s.Duration / time.Duration(s.Calls)
+}
+
+// ToolStats returns the tool usage recorded for a session, most called
+// first, or nil when none was recorded.
+func ToolStats(session *store.Session) []ToolStat {
+	var stats []ToolStat
+	if err := json.Unmarshal([]byte(session.Metadata[MetadataToolStats]), &stats); err != nil {
+		return nil
+	}
+	return stats
+}
+
+// toolUsageHeading introduces the tool usage table in a completion summary.
+const toolUsageHeading = "\n\nTool usage:\n"
+
+// withToolUsage appends a session's tool usage table to its completion
+// summary.
+func withToolUsage(summary string, session *store.Session) string {
+	stats := ToolStats(session)
+	if len(stats) == 0 {
+		return summary
+	}
+	return summary + toolUsageHeading + FormatToolStats(stats)
+}
+
+// StripToolUsage returns a completion summary without its tool usage table,
+// for views that render the table from ToolStats themselves.
+func StripToolUsage(summary string) string {
+	summary, _, _ = strings.Cut(summary, toolUsageHeading)
+	return summary
+}
+
+// FormatToolStats renders tool usage as a text table.
+func FormatToolStats(stats []ToolStat) string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TOOL\tCALLS\tFAILED\tAVG\tMAX")
+	for _, s := range stats {
+		fmt.Fprintf(w, "%s\t%d\t%d (%.0f%%)\t%s\t%s\n", s.Tool, s.Calls, s.Failures, s.FailureRate()*100,
+			s.Average().Round(time.Millisecond), s.Max.Round(time.Millisecond))
+	}
+	w.Flush()
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// failureStreak is a run of failed calls of one tool.
+type failureStreak struct {
+	reason string // Normalized, see normalizeReason
+	count  int
+}
+
+// toolTracker counts a session's tool calls and notices a tool failing the
+// same way again and again, which agents tend to retry unchanged.
+type toolTracker struct {
+	stats   map[string]*ToolStat
+	streaks map[string]failureStreak
+}
+
+// newToolTracker continues from the usage recorded for a session.
+func newToolTracker(session *store.Session) *toolTracker {
+	t := &toolTracker{stats: make(map[string]*ToolStat), streaks: make(map[string]failureStreak)}
+	for _, s := range ToolStats(session) {
+		t.stats[s.Tool] = &s
+	}
+	return t
+}
+
+// record counts a tool call, labeled with toolLabel. When the call is the
+// repeatedFailures-th in a row to fail for the same reason, it returns a
+// message telling the agent to stop repeating it.
+func (t *toolTracker) record(label string, res mcp.ToolResult) string {
+	s, ok := t.stats[label]
+	if !ok {
+		s = &ToolStat{Tool: label}
+		t.stats[label] = s
+	}
+	s.Calls++
+	s.Duration += res.Duration
+	if res.Duration > s.Max {
+		s.Max = res.Duration
+	}
+	if !res.Failed {
+		delete(t.streaks, label)
+		return ""
+	}
+	s.Failures++
+
+	reason := normalizeReason(res.Reason)
+	streak := t.streaks[label]
+	if streak.reason != reason {
+		streak = failureStreak{reason: reason}
+	}
+	streak.count++
+	if streak.count < repeatedFailures {
+		t.streaks[label] = streak
+		return ""
+	}
+	// Told once per run of failures; repeating it again starts a new one
+	delete(t.streaks, label)
+
+	attempt := label
+	if _, command, ok := strings.Cut(label, "run_shell: "); ok {
+		attempt = command
+	}
+	msg := fmt.Sprintf("Your last %d `%s` attempts failed for the same reason", streak.count, attempt)
+	if res.Reason != "" {
+		msg += ": " + res.Reason
+	}
+	return msg + "\nRepeating the call won't fix it. Read the full output with read_artifact, find the cause, and change your approach before trying again."
+}
+
+// save records the usage in the session metadata, most called first.
+func (t *toolTracker) save(session *store.Session) {
+	stats := make([]ToolStat, 0, len(t.stats))
+	for _, s := range t.stats {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Calls != stats[j].Calls {
+			return stats[i].Calls > stats[j].Calls
+		}
+		return stats[i].Tool < stats[j].Tool
+	})
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return
+	}
+	if session.Metadata == nil {
+		session.Metadata = make(map[string]string)
+	}
+	session.Metadata[MetadataToolStats] = string(data)
+}
+
+// reasonNumbers matches the numbers in a failure reason, such as line
+// numbers and durations, which change between otherwise identical failures.
+var reasonNumbers = regexp.MustCompile(`\d+`)
+
+// normalizeReason reduces a failure reason to what identifies the failure.
+func normalizeReason(reason string) string {
+	return reasonNumbers.ReplaceAllString(strings.ToLower(strings.TrimSpace(reason)), "N")
+}
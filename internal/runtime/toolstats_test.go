@@ -0,0 +1,68 @@
+package runtime
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/mcp"
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+func TestToolTracker(t *testing.T) {
+	session := &store.Session{ID: "sess-tools"}
+	tools := newToolTracker(session)
+	build := "run_shell: go build"
+	failed := func(reason string) mcp.ToolResult {
+		return mcp.ToolResult{Failed: true, Reason: reason, Duration: time.Second}
+	}
+
+	if msg := tools.record("read_file", mcp.ToolResult{Duration: 10 * time.Millisecond}); msg != "" {
+		t.Errorf("Expected no message for a successful call, got %q", msg)
+	}
+	tools.record(build, failed("main.go:3:2: undefined: foo"))
+	tools.record(build, failed("main.go:4:2: undefined: foo"))
+	msg := tools.record(build, failed("main.go:5:2: undefined: foo"))
+	if !strings.Contains(msg, "last 3 `go build` attempts failed") || !strings.Contains(msg, "main.go:5:2: undefined: foo") {
+		t.Errorf("Expected the third identical failure to be pointed out, got %q", msg)
+	}
+	if msg := tools.record(build, failed("main.go:5:2: undefined: foo")); msg != "" {
+		t.Errorf("Expected the streak to start over once pointed out, got %q", msg)
+	}
+
+	// A different reason or a success in between breaks the streak
+	tools.record(build, failed("undefined: bar"))
+	tools.record(build, mcp.ToolResult{})
+	tools.record(build, failed("undefined: bar"))
+	if msg := tools.record(build, failed("undefined: bar")); msg != "" {
+		t.Errorf("Expected no message after a broken streak, got %q", msg)
+	}
+
+	tools.save(session)
+	stats := ToolStats(session)
+	if len(stats) != 2 || stats[0].Tool != build || stats[0].Calls != 8 || stats[0].Failures != 7 || stats[0].Max != time.Second {
+		t.Fatalf("Unexpected stats %+v", stats)
+	}
+	if stats[1].Tool != "read_file" || stats[1].Average() != 10*time.Millisecond {
+		t.Errorf("Unexpected stats %+v", stats[1])
+	}
+
+	// A resumed session continues counting
+	if resumed := newToolTracker(session); resumed.stats[build].Calls != 8 {
+		t.Errorf("Expected the recorded usage to carry over, got %+v", resumed.stats[build])
+	}
+
+	table := FormatToolStats(stats)
+	for _, want := range []string{"TOOL", "run_shell: go build  8      7 (88%)", "10ms"} {
+		if !strings.Contains(table, want) {
+			t.Errorf("Expected %q in:\n%s", want, table)
+		}
+	}
+	summary := withToolUsage("Learned things", session)
+	if !strings.Contains(summary, "Tool usage:\nTOOL") || StripToolUsage(summary) != "Learned things" {
+		t.Errorf("Unexpected summary %q", summary)
+	}
+	if got := withToolUsage("Learned things", &store.Session{}); got != "Learned things" {
+		t.Errorf("Expected no table without usage, got %q", got)
+	}
+}
@@ -0,0 +1,22 @@
+package runtime
+
+import (
+	"strings"
+
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+// MetadataVarPrefix prefixes the spec variables given to a session (simon
+// run --var) in its metadata, e.g. "var.SERVICE".
+const MetadataVarPrefix = "var."
+
+// SessionVars returns the spec variables recorded in a session's metadata.
+func SessionVars(session *store.Session) map[string]string {
+	vars := make(map[string]string)
+	for key, value := range session.Metadata {
+		if name, ok := strings.CutPrefix(key, MetadataVarPrefix); ok {
+			vars[name] = value
+		}
+	}
+	return vars
+}
@@ -0,0 +1,101 @@
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/guard"
+	"github.com/felixgeelhaar/simon/internal/mcp"
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+// ArtifactVerificationReport is the artifact type holding the outcome of one
+// verification of a session's evidence and verify commands.
+const ArtifactVerificationReport = "verification_report"
+
+// recordVerification stores a verification outcome as a JSON artifact.
+func (r *Runtime) recordVerification(sessionID string, report *mcp.EvidenceReport) {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		r.observe.Log().Warn().Err(err).Msg("failed to encode verification report")
+		return
+	}
+	now := time.Now()
+	artifact := &store.Artifact{
+		ID:        fmt.Sprintf("art-%s-%s-%d", sessionID, ArtifactVerificationReport, now.UnixNano()),
+		SessionID: sessionID,
+		Path:      fmt.Sprintf("artifacts/%s/%s_%d.json", sessionID, ArtifactVerificationReport, now.UnixNano()),
+		Type:      ArtifactVerificationReport,
+		CreatedAt: now,
+	}
+	if err := r.store.SaveArtifact(artifact, data); err != nil {
+		r.observe.Log().Warn().Err(err).Msg("failed to save verification report")
+	}
+}
+
+// LatestVerification returns the last recorded verification outcome of a
+// session, or nil if its evidence was never checked.
+func LatestVerification(s store.Storage, sessionID string) (*mcp.EvidenceReport, error) {
+	artifacts, err := s.ListArtifacts(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	var latest *store.Artifact
+	for _, a := range artifacts {
+		if a.Type == ArtifactVerificationReport && (latest == nil || !a.CreatedAt.Before(latest.CreatedAt)) {
+			latest = a
+		}
+	}
+	if latest == nil {
+		return nil, nil
+	}
+	_, data, err := s.GetArtifact(latest.ID)
+	if err != nil {
+		return nil, err
+	}
+	var report mcp.EvidenceReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("invalid verification report %s: %w", latest.ID, err)
+	}
+	return &report, nil
+}
+
+// finishVerificationExhausted ends a session whose completion claims failed
+// verification more often than max_verification_retries allows, with the
+// status "verification_exhausted". The error reports the checks that never
+// passed, taken from the session's last verification.
+func (r *Runtime) finishVerificationExhausted(session *store.Session, v *guard.Violation) error {
+	session.Status = "verification_exhausted"
+	_ = r.store.UpdateSession(session)
+
+	report, err := LatestVerification(r.store, session.ID)
+	if err != nil {
+		r.observe.Log().Warn().Err(err).Msg("failed to load verification report")
+	}
+	unmet := unmetChecks(report)
+	r.ui.Log(fmt.Sprintf("🛑 %s", v.Message))
+	for _, item := range unmet {
+		r.ui.Log(fmt.Sprintf("   • Never verified: %s", item))
+	}
+	if len(unmet) == 0 {
+		return fmt.Errorf("guard violation: %s", v.Message)
+	}
+	return fmt.Errorf("guard violation: %s; never verified: %s", v.Message, strings.Join(unmet, ", "))
+}
+
+// unmetChecks lists the items of a verification report that did not pass,
+// as "kind item (status)".
+func unmetChecks(report *mcp.EvidenceReport) []string {
+	if report == nil {
+		return nil
+	}
+	var unmet []string
+	for _, item := range report.Items {
+		if item.Status != mcp.EvidencePass {
+			unmet = append(unmet, fmt.Sprintf("%s %s (%s)", item.Kind, item.Item, item.Status))
+		}
+	}
+	return unmet
+}
@@ -0,0 +1,64 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// evidencePollInterval controls how often the evidence watcher checks the
+// evidence paths. They are polled rather than watched with fsnotify: evidence
+// may not exist yet, nor its parent directories, which a watch would need.
+var evidencePollInterval = 250 * time.Millisecond
+
+// SetWatchEvidence enables the evidence watcher: while a session runs, its
+// evidence paths are checked in the background, and once all of them exist
+// the session is verified at the end of the current iteration instead of
+// waiting for the agent to claim completion.
+func (r *Runtime) SetWatchEvidence(enabled bool) {
+	r.watchEvidence = enabled
+}
+
+// startEvidenceWatch watches the evidence paths until all exist or ctx ends.
+// It publishes EventVerificationPass and sets the returned flag once they do.
+// The flag stays unset when watching is disabled or there is no evidence.
+func (r *Runtime) startEvidenceWatch(ctx context.Context, sessionID string, evidence []string) *atomic.Bool {
+	ready := &atomic.Bool{}
+	if !r.watchEvidence || len(evidence) == 0 {
+		return ready
+	}
+
+	go func() {
+		ticker := time.NewTicker(evidencePollInterval)
+		defer ticker.Stop()
+		for {
+			if allExist(evidence) {
+				r.observe.Log().Info().Str("session", sessionID).Int("evidence", len(evidence)).Msg("all evidence exists")
+				r.ui.Log(fmt.Sprintf("👀 All %d evidence files exist, verifying after this step", len(evidence)))
+				r.eventBus.PublishWithData(EventVerificationPass, sessionID, map[string]interface{}{
+					"source":   "watcher",
+					"evidence": len(evidence),
+				})
+				ready.Store(true)
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return ready
+}
+
+func allExist(paths []string) bool {
+	for _, p := range paths {
+		if _, err := os.Stat(p); err != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,85 @@
+package schedule
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// maxSubmitBody bounds a submission request body.
+const maxSubmitBody = 64 << 10
+
+// Handler serves the queue API:
+//
+//	GET    /api/queue           queue stats and all jobs
+//	POST   /api/sessions        submit a job (spec, provider, model, priority, tags)
+//	GET    /api/sessions/{id}   one job
+//	DELETE /api/sessions/{id}   cancel a queued or running job
+//
+// validate, when non-nil, vets submissions before they are queued.
+func Handler(s *Scheduler, validate func(*Job) error) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /api/queue", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"stats": s.Stats(),
+			"jobs":  s.Jobs(),
+		})
+	})
+
+	mux.HandleFunc("POST /api/sessions", func(w http.ResponseWriter, r *http.Request) {
+		var job Job
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSubmitBody)).Decode(&job); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+			return
+		}
+		// IDs and timestamps are assigned by the scheduler
+		job = Job{SpecPath: job.SpecPath, Provider: job.Provider, Model: job.Model, Priority: job.Priority, Tags: job.Tags, Vars: job.Vars}
+		if validate != nil {
+			if err := validate(&job); err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+		queued, err := s.Submit(job)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusAccepted, queued)
+	})
+
+	mux.HandleFunc("GET /api/sessions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		job, err := s.Job(r.PathValue("id"))
+		if err != nil {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	})
+
+	mux.HandleFunc("DELETE /api/sessions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		err := s.Cancel(r.PathValue("id"))
+		switch {
+		case errors.Is(err, ErrNotFound):
+			writeError(w, http.StatusNotFound, err.Error())
+		case err != nil:
+			writeError(w, http.StatusConflict, err.Error())
+		default:
+			job, _ := s.Job(r.PathValue("id"))
+			writeJSON(w, http.StatusOK, job)
+		}
+	})
+
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
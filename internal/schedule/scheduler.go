@@ -0,0 +1,326 @@
+// Package schedule queues sessions for the serve daemon. It runs the highest
+// priority jobs first, caps how many run at once, and gives all sessions on
+// a provider one shared request rate limit.
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/guard"
+)
+
+// Job states.
+const (
+	StateQueued    = "queued"
+	StateRunning   = "running"
+	StateCompleted = "completed"
+	StateFailed    = "failed"
+	StateCancelled = "cancelled"
+)
+
+// DefaultMaxConcurrent is the number of sessions run at once when unset.
+const DefaultMaxConcurrent = 2
+
+// ErrNotFound is returned for unknown job IDs.
+var ErrNotFound = errors.New("job not found")
+
+// Job is a queued session. Its ID becomes the session ID.
+type Job struct {
+	ID       string            `json:"id"`
+	SpecPath string            `json:"spec"`
+	Provider string            `json:"provider"`
+	Model    string            `json:"model,omitempty"`
+	Priority int               `json:"priority"`
+	Tags     map[string]string `json:"tags,omitempty"`
+	Vars     map[string]string `json:"vars,omitempty"`
+	// Budget names the budget preset the session's policy is scaled by
+	// (small, medium, large, or a budget.<name>.* config); empty keeps the
+	// policy's limits.
+	Budget string `json:"budget,omitempty"`
+	// Previous is an earlier session of the same task the job continues
+	// from, such as an orphaned session resumed by the daemon.
+	Previous string `json:"previous,omitempty"`
+
+	State       string     `json:"state"`
+	Error       string     `json:"error,omitempty"`
+	SubmittedAt time.Time  `json:"submitted_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+
+	seq    uint64
+	cancel context.CancelFunc
+}
+
+// RunFunc runs a job's session. limiter is the provider's shared request
+// limiter, or nil when the provider has no rate limit.
+type RunFunc func(ctx context.Context, job Job, limiter *guard.RateLimiter) error
+
+// Options configures a Scheduler.
+type Options struct {
+	// MaxConcurrent caps running sessions; 0 uses DefaultMaxConcurrent.
+	MaxConcurrent int
+	// ProviderRates limits provider requests per minute across all of a
+	// provider's sessions; providers without an entry are not limited.
+	ProviderRates map[string]int
+	// OnChange, when set, is called with a copy of a job whenever it starts
+	// or finishes, outside the scheduler's lock.
+	OnChange func(Job)
+}
+
+// Stats summarizes the queue.
+type Stats struct {
+	Queued        int `json:"queued"`
+	Running       int `json:"running"`
+	MaxConcurrent int `json:"max_concurrent"`
+}
+
+// Scheduler runs submitted jobs through RunFunc.
+type Scheduler struct {
+	run  RunFunc
+	opts Options
+
+	mu       sync.Mutex
+	jobs     map[string]*Job
+	queue    []*Job
+	running  int
+	seq      uint64
+	limiters map[string]*guard.RateLimiter
+	wake     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// New creates a scheduler; call Run to start dispatching.
+func New(run RunFunc, opts Options) *Scheduler {
+	if opts.MaxConcurrent <= 0 {
+		opts.MaxConcurrent = DefaultMaxConcurrent
+	}
+	s := &Scheduler{
+		run:      run,
+		opts:     opts,
+		jobs:     make(map[string]*Job),
+		limiters: make(map[string]*guard.RateLimiter),
+		wake:     make(chan struct{}, 1),
+	}
+	for name, perMinute := range opts.ProviderRates {
+		s.limiters[name] = guard.NewRateLimiter(perMinute)
+	}
+	return s
+}
+
+// Submit queues a job. An empty ID is assigned from the submission time.
+func (s *Scheduler) Submit(job Job) (Job, error) {
+	if job.SpecPath == "" {
+		return Job{}, fmt.Errorf("spec is required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	if job.ID == "" {
+		job.ID = fmt.Sprintf("session-%d-%d", time.Now().Unix(), s.seq)
+	}
+	if _, exists := s.jobs[job.ID]; exists {
+		return Job{}, fmt.Errorf("job %s already exists", job.ID)
+	}
+	job.State = StateQueued
+	job.SubmittedAt = time.Now()
+	job.seq = s.seq
+	j := &job
+	s.jobs[j.ID] = j
+	s.queue = append(s.queue, j)
+	s.signal()
+	return j.snapshot(), nil
+}
+
+// Cancel removes a queued job or cancels a running one's context.
+func (s *Scheduler) Cancel(id string) error {
+	s.mu.Lock()
+	j, ok := s.jobs[id]
+	if !ok {
+		s.mu.Unlock()
+		return ErrNotFound
+	}
+	switch j.State {
+	case StateQueued:
+		for i, q := range s.queue {
+			if q == j {
+				s.queue = append(s.queue[:i], s.queue[i+1:]...)
+				break
+			}
+		}
+		j.finish(StateCancelled, nil)
+		cancelled := j.snapshot()
+		s.mu.Unlock()
+		s.notify(cancelled)
+		return nil
+	case StateRunning:
+		j.cancel()
+	default:
+		s.mu.Unlock()
+		return fmt.Errorf("job %s already %s", id, j.State)
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// Job returns a copy of the job with the given ID.
+func (s *Scheduler) Job(id string) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	return j.snapshot(), nil
+}
+
+// Jobs lists all jobs: running first, then queued in dispatch order, then
+// finished jobs, most recent first.
+func (s *Scheduler) Jobs() []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sortQueue()
+	rank := make(map[*Job]int, len(s.queue))
+	for i, j := range s.queue {
+		rank[j] = i
+	}
+	jobs := make([]*Job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j)
+	}
+	group := func(j *Job) int {
+		switch j.State {
+		case StateRunning:
+			return 0
+		case StateQueued:
+			return 1
+		}
+		return 2
+	}
+	sort.Slice(jobs, func(a, b int) bool {
+		ja, jb := jobs[a], jobs[b]
+		if group(ja) != group(jb) {
+			return group(ja) < group(jb)
+		}
+		if ja.State == StateQueued {
+			return rank[ja] < rank[jb]
+		}
+		return ja.seq > jb.seq
+	})
+	out := make([]Job, len(jobs))
+	for i, j := range jobs {
+		out[i] = j.snapshot()
+	}
+	return out
+}
+
+// Stats reports the queue length and running sessions.
+func (s *Scheduler) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Stats{Queued: len(s.queue), Running: s.running, MaxConcurrent: s.opts.MaxConcurrent}
+}
+
+// Run dispatches jobs until ctx is done, then waits for running jobs, whose
+// contexts are cancelled with ctx.
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		s.dispatch(ctx)
+		select {
+		case <-ctx.Done():
+			s.wg.Wait()
+			return
+		case <-s.wake:
+		}
+	}
+}
+
+// dispatch starts queued jobs while there is capacity.
+func (s *Scheduler) dispatch(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ctx.Err() != nil {
+		return
+	}
+	s.sortQueue()
+	for s.running < s.opts.MaxConcurrent && len(s.queue) > 0 {
+		j := s.queue[0]
+		s.queue = s.queue[1:]
+		s.running++
+		now := time.Now()
+		j.State, j.StartedAt = StateRunning, &now
+		var jobCtx context.Context
+		jobCtx, j.cancel = context.WithCancel(ctx)
+		limiter := s.limiters[j.Provider]
+
+		s.wg.Add(1)
+		go func(j *Job, snapshot Job) {
+			defer s.wg.Done()
+			s.notify(snapshot)
+			err := s.run(jobCtx, snapshot, limiter)
+
+			s.mu.Lock()
+			s.running--
+			// a cancelled session may still stop cleanly, so check the
+			// context before the error
+			switch {
+			case jobCtx.Err() != nil:
+				j.finish(StateCancelled, err)
+			case err == nil:
+				j.finish(StateCompleted, nil)
+			default:
+				j.finish(StateFailed, err)
+			}
+			j.cancel()
+			finished := j.snapshot()
+			s.signal()
+			s.mu.Unlock()
+			s.notify(finished)
+		}(j, j.snapshot())
+	}
+}
+
+// sortQueue orders queued jobs by priority, then submission order.
+func (s *Scheduler) sortQueue() {
+	sort.SliceStable(s.queue, func(a, b int) bool {
+		if s.queue[a].Priority != s.queue[b].Priority {
+			return s.queue[a].Priority > s.queue[b].Priority
+		}
+		return s.queue[a].seq < s.queue[b].seq
+	})
+}
+
+// notify passes a job to the OnChange callback, if any.
+func (s *Scheduler) notify(job Job) {
+	if s.opts.OnChange != nil {
+		s.opts.OnChange(job)
+	}
+}
+
+// signal wakes the dispatch loop without blocking.
+func (s *Scheduler) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (j *Job) finish(state string, err error) {
+	now := time.Now()
+	j.State, j.FinishedAt = state, &now
+	if err != nil {
+		j.Error = err.Error()
+	}
+}
+
+// snapshot copies the job for callers outside the scheduler's lock.
+func (j *Job) snapshot() Job {
+	c := *j
+	c.cancel = nil
+	return c
+}
@@ -0,0 +1,220 @@
+package schedule
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/guard"
+)
+
+// waitFor polls until cond holds or fails the test.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	t.Run("Priority And Concurrency", func(t *testing.T) {
+		release := make(chan struct{})
+		var mu sync.Mutex
+		var order []string
+		var limiters []*guard.RateLimiter
+		run := func(ctx context.Context, job Job, limiter *guard.RateLimiter) error {
+			mu.Lock()
+			order = append(order, job.SpecPath)
+			limiters = append(limiters, limiter)
+			mu.Unlock()
+			<-release
+			if job.SpecPath == "bad" {
+				return fmt.Errorf("boom")
+			}
+			return nil
+		}
+		s := New(run, Options{MaxConcurrent: 1, ProviderRates: map[string]int{"openai": 60}})
+		for _, j := range []Job{
+			{SpecPath: "low", Provider: "openai"},
+			{SpecPath: "bad", Provider: "ollama", Priority: 1},
+			{SpecPath: "high", Provider: "openai", Priority: 5},
+		} {
+			if _, err := s.Submit(j); err != nil {
+				t.Fatalf("Submit failed: %v", err)
+			}
+		}
+		if _, err := s.Submit(Job{}); err == nil {
+			t.Error("Expected a job without a spec to be rejected")
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go s.Run(ctx)
+
+		waitFor(t, "first job", func() bool { return s.Stats().Running == 1 })
+		if st := s.Stats(); st.Queued != 2 || st.MaxConcurrent != 1 {
+			t.Errorf("Unexpected stats %+v", st)
+		}
+		jobs := s.Jobs()
+		if jobs[0].SpecPath != "high" || jobs[1].SpecPath != "bad" || jobs[2].SpecPath != "low" {
+			t.Errorf("Expected running then queued by priority, got %s, %s, %s", jobs[0].SpecPath, jobs[1].SpecPath, jobs[2].SpecPath)
+		}
+		close(release)
+		waitFor(t, "queue to drain", func() bool { st := s.Stats(); return st.Queued == 0 && st.Running == 0 })
+
+		if fmt.Sprint(order) != "[high bad low]" {
+			t.Errorf("Expected priority order, got %v", order)
+		}
+		if limiters[0] == nil || limiters[0] != limiters[2] || limiters[1] != nil {
+			t.Error("Expected openai jobs to share one limiter and ollama to have none")
+		}
+		for _, j := range s.Jobs() {
+			want := StateCompleted
+			if j.SpecPath == "bad" {
+				want = StateFailed
+			}
+			if j.State != want || j.FinishedAt == nil {
+				t.Errorf("Expected %s to be %s, got %s", j.SpecPath, want, j.State)
+			}
+		}
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		run := func(ctx context.Context, job Job, limiter *guard.RateLimiter) error {
+			<-ctx.Done()
+			return nil // sessions stop gracefully
+		}
+		s := New(run, Options{MaxConcurrent: 1})
+		running, _ := s.Submit(Job{SpecPath: "a"})
+		queued, _ := s.Submit(Job{SpecPath: "b"})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go s.Run(ctx)
+		waitFor(t, "first job", func() bool { return s.Stats().Running == 1 })
+
+		if err := s.Cancel(queued.ID); err != nil {
+			t.Fatalf("Cancel queued failed: %v", err)
+		}
+		if j, _ := s.Job(queued.ID); j.State != StateCancelled || j.StartedAt != nil {
+			t.Errorf("Expected the queued job to be cancelled without starting, got %+v", j)
+		}
+		if err := s.Cancel(running.ID); err != nil {
+			t.Fatalf("Cancel running failed: %v", err)
+		}
+		waitFor(t, "running job to stop", func() bool { j, _ := s.Job(running.ID); return j.State == StateCancelled })
+		if err := s.Cancel(running.ID); err == nil {
+			t.Error("Expected cancelling a finished job to fail")
+		}
+		if err := s.Cancel("missing"); err != ErrNotFound {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestScheduler_OnChange(t *testing.T) {
+	var mu sync.Mutex
+	var changes []string
+	run := func(ctx context.Context, job Job, limiter *guard.RateLimiter) error { return nil }
+	s := New(run, Options{OnChange: func(j Job) {
+		mu.Lock()
+		defer mu.Unlock()
+		changes = append(changes, j.SpecPath+":"+j.State)
+	}})
+	s.Submit(Job{SpecPath: "a"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	waitFor(t, "job to finish", func() bool { j := s.Jobs()[0]; return j.State == StateCompleted })
+	cancel()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	if fmt.Sprint(changes) != "[a:running a:completed]" {
+		t.Errorf("Expected the start and finish to be reported, got %v", changes)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	s := New(func(ctx context.Context, job Job, limiter *guard.RateLimiter) error { return nil }, Options{})
+	validate := func(j *Job) error {
+		if j.SpecPath == "invalid.yaml" {
+			return fmt.Errorf("invalid spec")
+		}
+		if j.Provider == "" {
+			j.Provider = "ollama"
+		}
+		return nil
+	}
+	srv := httptest.NewServer(Handler(s, validate))
+	defer srv.Close()
+
+	post := func(body string) (*http.Response, map[string]interface{}) {
+		resp, err := http.Post(srv.URL+"/api/sessions", "application/json", bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatalf("POST failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var out map[string]interface{}
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp, out
+	}
+
+	resp, job := post(`{"spec": "task.yaml", "priority": 3, "id": "chosen", "state": "completed"}`)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d: %v", resp.StatusCode, job)
+	}
+	if job["id"] == "chosen" || job["state"] != StateQueued || job["provider"] != "ollama" || job["priority"] != 3.0 {
+		t.Errorf("Expected a queued job with server-assigned fields, got %v", job)
+	}
+	if resp, out := post(`{"spec": "invalid.yaml"}`); resp.StatusCode != http.StatusBadRequest || out["error"] != "invalid spec" {
+		t.Errorf("Expected a validation error, got %d %v", resp.StatusCode, out)
+	}
+
+	resp, err := http.Get(srv.URL + "/api/queue")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	var queue struct {
+		Stats Stats `json:"stats"`
+		Jobs  []Job `json:"jobs"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&queue)
+	resp.Body.Close()
+	if queue.Stats.Queued != 1 || len(queue.Jobs) != 1 || queue.Jobs[0].ID != job["id"] {
+		t.Errorf("Unexpected queue %+v", queue)
+	}
+
+	id := job["id"].(string)
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/api/sessions/"+id, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 on cancel, got %d", resp.StatusCode)
+	}
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("Expected 409 cancelling twice, got %d", resp.StatusCode)
+	}
+	resp, _ = http.Get(srv.URL + "/api/sessions/missing")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,97 @@
+package setup
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+
+	"github.com/felixgeelhaar/simon/internal/credential"
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+// Config keys describing how secrets are encrypted. credential.mode is
+// "passphrase" when secrets use a key derived from a user passphrase and
+// credential.salt; otherwise the machine-derived key is used.
+// credential.check detects a wrong passphrase before any secret is decrypted.
+const (
+	ConfigCredentialMode     = "credential.mode"
+	ConfigCredentialSalt     = "credential.salt"
+	ConfigCredentialCheck    = "credential.check"
+	CredentialModePassphrase = "passphrase"
+
+	// ConfigHistoryEncrypt set to "true" encrypts conversation history and
+	// snapshots at rest under keys derived from the credential key.
+	// Artifacts, memories, and session metadata are not encrypted.
+	ConfigHistoryEncrypt = "history.encrypt"
+
+	// PassphraseEnv holds the credential passphrase in passphrase mode.
+	PassphraseEnv = "SIMON_PASSPHRASE"
+)
+
+// CredentialManager returns the manager for the store's secrets: the
+// machine-derived key or, in passphrase mode, the key derived from the
+// passphrase that passphrase returns, checked against credential.check.
+func CredentialManager(s store.Storage, passphrase func() (string, error)) (*credential.Manager, error) {
+	if mode, _ := s.GetConfig(ConfigCredentialMode); mode != CredentialModePassphrase {
+		return credential.NewManager()
+	}
+	encoded, _ := s.GetConfig(ConfigCredentialSalt)
+	salt, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(salt) == 0 {
+		return nil, fmt.Errorf("%s is missing or invalid", ConfigCredentialSalt)
+	}
+	secret, err := passphrase()
+	if err != nil {
+		return nil, err
+	}
+	m, err := credential.NewPassphraseManager(secret, salt)
+	if err != nil {
+		return nil, err
+	}
+	if check, _ := s.GetConfig(ConfigCredentialCheck); check != "" {
+		if err := m.Verify(check); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// SecretConfig returns a config value, decrypting it with the manager key
+// returns if it was stored encrypted. key is only called then.
+func SecretConfig(s store.Storage, name string, key func() (*credential.Manager, error)) (string, error) {
+	value, _ := s.GetConfig(name)
+	if !credential.IsEncrypted(value) {
+		return value, nil
+	}
+	m, err := key()
+	if err != nil {
+		return "", err
+	}
+	decrypted, err := m.Decrypt(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt %s: %w", name, err)
+	}
+	return decrypted, nil
+}
+
+// secretConfig is SecretConfig with the store's credential key; in
+// passphrase mode the passphrase is read from SIMON_PASSPHRASE.
+func secretConfig(s store.Storage, name string) (string, error) {
+	return SecretConfig(s, name, func() (*credential.Manager, error) {
+		return CredentialManager(s, func() (string, error) {
+			if v := os.Getenv(PassphraseEnv); v != "" {
+				return v, nil
+			}
+			return "", fmt.Errorf("%s is encrypted with a passphrase; set %s", name, PassphraseEnv)
+		})
+	})
+}
+
+// EncryptHistory installs a credential.HistoryCipher keyed by key on s, so
+// encrypted history reads back transparently, and encrypts history as it is
+// written when history.encrypt is true. key is only called once encrypted
+// history is read or written.
+func EncryptHistory(s *store.SQLiteStore, key func() (*credential.Manager, error)) {
+	encrypt, _ := s.GetConfig(ConfigHistoryEncrypt)
+	s.SetMessageCipher(credential.NewHistoryCipher(key), encrypt == "true")
+}
@@ -0,0 +1,81 @@
+package setup
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/felixgeelhaar/simon/internal/provider"
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+// keylessProviders serve models locally without credentials, so they count
+// as configured whether or not they are running.
+var keylessProviders = []string{"ollama", "lmstudio", "llamacpp"}
+
+// ModelInfo is a model a provider serves, with what simon knows of its
+// capabilities.
+type ModelInfo struct {
+	Provider     string
+	Model        string
+	Capabilities provider.Capabilities
+}
+
+// ConfiguredProviders returns the providers whose models can be listed: the
+// default provider, those with an API key configured, and the local ones.
+func ConfiguredProviders(s store.Storage) []string {
+	name, _ := DefaultProvider(s)
+	names := []string{name}
+	for _, key := range apiKeyConfigs {
+		if v, _ := s.GetConfig(key); v != "" {
+			names = append(names, strings.TrimSuffix(key, ".api_key"))
+		}
+	}
+	names = append(names, keylessProviders...)
+	var unique []string
+	for _, n := range names {
+		if !slices.Contains(unique, n) {
+			unique = append(unique, n)
+		}
+	}
+	return unique
+}
+
+// ListModels asks the named provider for the models it serves. Context
+// windows, tool support, and pricing come from provider.ModelCapabilities;
+// embedding support from the provider.
+func ListModels(ctx context.Context, s store.Storage, name string) ([]ModelInfo, error) {
+	var ids []string
+	embeddings := false
+	if slices.Contains(provider.LocalProviders, name) {
+		// Creating a local provider without a model would list them twice
+		baseURL, _ := s.GetConfig(name + ".base_url")
+		var err error
+		if ids, err = provider.ListLocalModels(ctx, name, baseURL); err != nil {
+			return nil, err
+		}
+	} else {
+		p, stop, err := newBackend(s, name, "")
+		if err != nil {
+			return nil, err
+		}
+		defer stop()
+		lister, ok := p.(provider.ModelLister)
+		if !ok {
+			return nil, fmt.Errorf("%s cannot list its models", name)
+		}
+		if ids, err = lister.ListModels(ctx); err != nil {
+			return nil, err
+		}
+		embeddings = provider.CapabilitiesOf(p).Embeddings
+	}
+
+	models := make([]ModelInfo, len(ids))
+	for i, id := range ids {
+		caps := provider.ModelCapabilities(name, id)
+		caps.Embeddings = embeddings
+		models[i] = ModelInfo{Provider: name, Model: id, Capabilities: caps}
+	}
+	return models, nil
+}
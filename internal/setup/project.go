@@ -0,0 +1,180 @@
+package setup
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/felixgeelhaar/simon/internal/coach"
+	"github.com/felixgeelhaar/simon/internal/guard"
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectFile is the project configuration simon looks for in the root of
+// the repository it runs in, so everyone working on it gets the same
+// defaults.
+const ProjectFile = ".simon.yaml"
+
+// Project is a repository's .simon.yaml. Its config keys are defaults the
+// profile's config overrides; its policy can only tighten the profile's.
+type Project struct {
+	// Path is the file the project was read from.
+	Path string `yaml:"-"`
+	// Provider and Model are defaults for provider.default and
+	// provider.model.
+	Provider string `yaml:"provider,omitempty"`
+	Model    string `yaml:"model,omitempty"`
+	// Config holds defaults for other config keys. Credentials and paths of
+	// executables are refused, since the file is shared through the
+	// repository.
+	Config map[string]string `yaml:"config,omitempty"`
+	// Policy holds policy.yaml keys restricting the profile's policy: see
+	// Restrict.
+	Policy yaml.Node `yaml:"policy,omitempty"`
+	// Spec holds conventions added to every spec run in the project, such
+	// as its verify commands and evidence.
+	Spec coach.SpecDefaults `yaml:"spec,omitempty"`
+}
+
+// projectDeniedKeys are config keys a project file may not set: those
+// naming executables simon would start. Credentials are recognized by
+// isCredentialKey and server addresses by isServerKey.
+var projectDeniedKeys = []string{"provider.plugin.path", "provider.cli.path", "verify.plugins", "reducer.plugins"}
+
+// isServerKey reports whether a config key points simon at a server, which
+// would receive the prompts and the credentials sent along with them.
+func isServerKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, suffix := range []string{".base_url", ".host", ".endpoint"} {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isCredentialKey reports whether a config key holds a secret or describes
+// how secrets are encrypted.
+func isCredentialKey(key string) bool {
+	key = strings.ToLower(key)
+	if strings.HasPrefix(key, "credential.") {
+		return true
+	}
+	for _, suffix := range []string{"api_key", "_secret", "_password", "webhook_url"} {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindProject returns the project file of the repository dir is in: the
+// .simon.yaml of dir or its closest parent, searching up to the directory
+// holding .git. It returns nil when there is none.
+func FindProject(dir string) (*Project, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		path := filepath.Join(dir, ProjectFile)
+		if _, err := os.Stat(path); err == nil {
+			return LoadProject(path)
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return nil, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// LoadProject reads and validates a project file.
+func LoadProject(path string) (*Project, error) {
+	data, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project file: %w", err)
+	}
+	p := &Project{Path: path}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(p); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid project file %s: %w", path, err)
+	}
+	for key := range p.Config {
+		if isCredentialKey(key) {
+			return nil, fmt.Errorf("invalid project file %s: %s is a credential; set it with simon config set instead", path, key)
+		}
+		if isServerKey(key) {
+			return nil, fmt.Errorf("invalid project file %s: %s points simon at a server; set it with simon config set instead", path, key)
+		}
+		if slices.Contains(projectDeniedKeys, key) {
+			return nil, fmt.Errorf("invalid project file %s: %s can only be set with simon config set", path, key)
+		}
+	}
+	if _, err := p.Restrict(guard.DefaultPolicy); err != nil {
+		return nil, fmt.Errorf("invalid project file %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// ConfigDefaults returns the config key defaults of the project: its
+// Config with Provider and Model as provider.default and provider.model.
+// A nil project has none.
+func (p *Project) ConfigDefaults() map[string]string {
+	if p == nil {
+		return nil
+	}
+	defaults := make(map[string]string, len(p.Config)+2)
+	maps.Copy(defaults, p.Config)
+	if p.Provider != "" {
+		defaults["provider.default"] = p.Provider
+	}
+	if p.Model != "" {
+		defaults["provider.model"] = p.Model
+	}
+	return defaults
+}
+
+// Restrict returns the user's policy tightened by the project's policy
+// keys (see guard.Policy.Tighten), so a repository can narrow what simon
+// may do in it but never widen it. Its params are defaults under the
+// user's.
+func (p *Project) Restrict(user guard.Policy) (guard.Policy, error) {
+	if p == nil || p.Policy.IsZero() {
+		return user, nil
+	}
+	data, err := yaml.Marshal(&p.Policy)
+	if err != nil {
+		return user, err
+	}
+	over, err := guard.ParsePolicy(user, data)
+	if err != nil {
+		return user, err
+	}
+	own, err := guard.ParsePolicy(guard.Policy{}, data)
+	if err != nil {
+		return user, err
+	}
+	restricted := user.Tighten(over)
+	restricted.Params = own.Params.Merge(user.Params)
+	return restricted, nil
+}
+
+// SpecDefaults returns the conventions the project adds to specs; none for
+// a nil project.
+func (p *Project) SpecDefaults() coach.SpecDefaults {
+	if p == nil {
+		return coach.SpecDefaults{}
+	}
+	return p.Spec
+}
@@ -0,0 +1,446 @@
+// Package setup builds what a session runs with from a profile's store and
+// configuration: the store itself, the model provider with its middleware,
+// and the tool proxy with its sandbox and plugins. The CLI and the pkg/simon
+// SDK share it.
+package setup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/felixgeelhaar/bolt/v3"
+	"github.com/felixgeelhaar/simon/internal/coach"
+	"github.com/felixgeelhaar/simon/internal/credential"
+	"github.com/felixgeelhaar/simon/internal/guard"
+	"github.com/felixgeelhaar/simon/internal/mcp"
+	"github.com/felixgeelhaar/simon/internal/plugin"
+	"github.com/felixgeelhaar/simon/internal/provider"
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+// LocksDir returns the directory of the workspace locks. It is shared by
+// every profile, since profiles still work on the same repositories.
+func LocksDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".simon", "locks")
+}
+
+// OpenStore opens the SQLite store of a profile directory, with the config
+// defaults of project unless it is nil, and applies its memory.search and
+// artifacts.max_size config keys.
+func OpenStore(dir string, project *Project) (*store.SQLiteStore, error) {
+	s, err := store.NewSQLiteStore(
+		filepath.Join(dir, "metadata.db"),
+		filepath.Join(dir, "artifacts"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	s.SetConfigDefaults(project.ConfigDefaults())
+	if mode, _ := s.GetConfig("memory.search"); mode != "" {
+		if err := s.SetMemorySearchMode(store.MemorySearchMode(mode)); err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+	if size, _ := s.GetConfig("artifacts.max_size"); size != "" {
+		n, err := strconv.ParseInt(size, 10, 64)
+		if err == nil {
+			err = s.SetMaxArtifactSize(n)
+		}
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("invalid artifacts.max_size %q: must be a number of bytes", size)
+		}
+	}
+	return s, nil
+}
+
+// LoadPolicy returns the policy.yaml of a profile directory if present,
+// guard.DefaultPolicy otherwise, restricted by project's policy. project
+// may be nil.
+func LoadPolicy(dir string, project *Project) (guard.Policy, error) {
+	user := guard.DefaultPolicy
+	path := filepath.Join(dir, "policy.yaml")
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		var err error
+		if user, err = guard.LoadPolicy(path); err != nil {
+			return user, err
+		}
+	}
+	return project.Restrict(user)
+}
+
+// DefaultProvider returns the provider.default and provider.model config
+// keys, with ollama when no provider is configured.
+func DefaultProvider(s store.Storage) (name, model string) {
+	name, _ = s.GetConfig("provider.default")
+	if name == "" {
+		name = "ollama"
+	}
+	model, _ = s.GetConfig("provider.model")
+	return name, model
+}
+
+// apiKeyConfigs are the config keys holding provider credentials, redacted
+// from recorded fixtures, shared sessions, and the TUI log.
+var apiKeyConfigs = []string{"openai.api_key", "gemini.api_key", "anthropic.api_key", "mistral.api_key", "groq.api_key", "huggingface.api_key"}
+
+// apiKeys returns the configured API keys, decrypted when they are stored
+// encrypted. A key that can't be decrypted is left out; the provider using
+// it fails to start anyway.
+func apiKeys(s store.Storage) []string {
+	var secrets []string
+	for _, key := range apiKeyConfigs {
+		if v, err := secretConfig(s, key); err == nil && v != "" {
+			secrets = append(secrets, v)
+		}
+	}
+	return secrets
+}
+
+// Sanitizer redacts the configured API keys and the extra secrets from
+// text, in addition to values matching well-known credential formats.
+func Sanitizer(s store.Storage, extra ...string) *provider.Sanitizer {
+	return provider.NewSanitizer(append(apiKeys(s), extra...)...)
+}
+
+// Redactor masks the configured API keys and the extra secrets for
+// display, in addition to values matching well-known credential formats.
+func Redactor(s store.Storage, extra ...string) *credential.Redactor {
+	return credential.NewRedactor(append(apiKeys(s), extra...)...)
+}
+
+// ResolveProvider returns the provider and model a session of spec runs
+// with: the explicitly chosen name and model (command-line flags, API
+// requests) first, then the spec's provider and model, then the
+// provider.default and provider.model config keys. A model only carries
+// over to the provider it was chosen for: the spec's applies when the spec
+// names no provider or the chosen one, the configured one when the
+// configured provider is chosen.
+func ResolveProvider(s store.Storage, spec *coach.TaskSpec, name, model string) (string, string) {
+	var specName, specModel string
+	if spec != nil {
+		specName, specModel = spec.Provider, spec.Model
+	}
+	defaultName, defaultModel := DefaultProvider(s)
+	if name == "" {
+		name = specName
+	}
+	if name == "" {
+		name = defaultName
+	}
+	if model == "" && (specName == "" || specName == name) {
+		model = specModel
+	}
+	if model == "" && name == defaultName {
+		model = defaultModel
+	}
+	return name, model
+}
+
+// ProviderOptions selects the middleware that isn't configured by the
+// provider.* config keys.
+type ProviderOptions struct {
+	// Cache answers repeated prompts from the store's response cache.
+	Cache bool
+	// Log, when set, logs every request and the process's usage totals.
+	Log *bolt.Logger
+	// Record, when set, is the directory provider calls are recorded to as
+	// a fixture for the fixture provider.
+	Record string
+	// Secrets are redacted from recorded fixtures along with the
+	// configured API keys, e.g. the spec's coach.TaskSpec.Secrets.
+	Secrets []string
+	// RateLimiter, when set, paces requests in place of the
+	// provider.rate_limit config key, so that concurrent sessions on one
+	// provider share its rate limit.
+	RateLimiter *guard.RateLimiter
+}
+
+// Defaults for the provider.retry.* config keys.
+const (
+	defaultRetryAttempts = 3
+	defaultRetryBackoff  = time.Second
+	maxRetryBackoff      = 30 * time.Second
+)
+
+// NewProvider creates the named API provider from the store's config and
+// wraps it in the middleware chain (see providerMiddleware). The returned
+// stop function releases plugin processes and is never nil.
+func NewProvider(s store.Storage, providerType, modelName string, opts ProviderOptions) (provider.Provider, func(), error) {
+	p, stop, err := newBackend(s, providerType, modelName)
+	if err != nil {
+		return nil, stop, err
+	}
+	return WrapProvider(s, p, stop, opts)
+}
+
+// WrapProvider applies the middleware chain to p, extending stop to log
+// usage totals when logging.
+func WrapProvider(s store.Storage, p provider.Provider, stop func(), opts ProviderOptions) (provider.Provider, func(), error) {
+	middleware, tracker, err := providerMiddleware(s, opts)
+	if err != nil {
+		stop()
+		return nil, func() {}, err
+	}
+	if tracker != nil {
+		backendStop := stop
+		stop = func() {
+			if calls, usage, cost := tracker.Totals(); calls > 0 {
+				opts.Log.Info().Str("provider", p.Name()).Int("calls", calls).Int("prompt_tokens", usage.PromptTokens).
+					Int("completion_tokens", usage.CompletionTokens).Float64("cost_usd", cost).Msg("provider usage")
+			}
+			backendStop()
+		}
+	}
+	return provider.Chain(p, middleware...), stop, nil
+}
+
+// providerMiddleware assembles the middleware chain, outermost first:
+// logging and usage tracking, fixture recording, the response cache, the rate limit from
+// provider.rate_limit (requests per minute, unset for none), retries of
+// transient failures from provider.retry.attempts (default 3, 1 disables)
+// and provider.retry.backoff (default 1s, doubling), and usage estimates for
+// providers that don't report token counts.
+func providerMiddleware(s store.Storage, opts ProviderOptions) ([]provider.Middleware, *provider.UsageTracker, error) {
+	var middleware []provider.Middleware
+	var tracker *provider.UsageTracker
+	if opts.Log != nil {
+		tracker = &provider.UsageTracker{}
+		middleware = append(middleware, provider.WithLogging(opts.Log), provider.WithUsageTracking(tracker))
+	}
+	if opts.Record != "" {
+		if err := os.MkdirAll(opts.Record, 0750); err != nil {
+			return nil, nil, fmt.Errorf("failed to create fixture directory: %w", err)
+		}
+		middleware = append(middleware, provider.WithRecording(opts.Record, Sanitizer(s, opts.Secrets...)))
+	}
+	if cache, ok := s.(provider.ResponseCache); ok && opts.Cache {
+		middleware = append(middleware, provider.WithCache(cache))
+	}
+
+	if opts.RateLimiter != nil {
+		middleware = append(middleware, provider.WithRateLimit(opts.RateLimiter))
+	} else if v, _ := s.GetConfig("provider.rate_limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, nil, fmt.Errorf("invalid provider.rate_limit %q: must be requests per minute", v)
+		}
+		if limiter := guard.NewRateLimiter(n); limiter != nil {
+			middleware = append(middleware, provider.WithRateLimit(limiter))
+		}
+	}
+
+	retry := provider.RetryPolicy{Attempts: defaultRetryAttempts, Backoff: defaultRetryBackoff, MaxBackoff: maxRetryBackoff}
+	if v, _ := s.GetConfig("provider.retry.attempts"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, nil, fmt.Errorf("invalid provider.retry.attempts %q: must be at least 1", v)
+		}
+		retry.Attempts = n
+	}
+	if v, _ := s.GetConfig("provider.retry.backoff"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, nil, fmt.Errorf("invalid provider.retry.backoff %q: must be a duration like 2s", v)
+		}
+		retry.Backoff = d
+	}
+	if opts.Log != nil {
+		retry.OnRetry = func(attempt int, err error, wait time.Duration) {
+			opts.Log.Warn().Int("attempt", attempt).Dur("wait", wait).Err(err).Msg("provider request failed, retrying")
+		}
+	}
+	if retry.Attempts > 1 {
+		middleware = append(middleware, provider.WithRetry(retry))
+	}
+
+	return append(middleware, provider.WithUsageEstimate()), tracker, nil
+}
+
+// newBackend creates the named API provider from the store's config. The
+// returned stop function releases plugin processes and is never nil.
+func newBackend(s store.Storage, providerType, modelName string) (provider.Provider, func(), error) {
+	stop := func() {}
+	var p provider.Provider
+	var err error
+	switch providerType {
+	case "openai":
+		apiKey, _ := s.GetConfig("openai.api_key")
+		baseURL, _ := s.GetConfig("openai.base_url")
+		p, err = provider.NewOpenAIProvider(apiKey, baseURL, modelName)
+	case "ollama":
+		p, err = provider.NewOllamaProvider(modelName)
+	case "gemini":
+		apiKey, _ := s.GetConfig("gemini.api_key")
+		p, err = provider.NewGeminiProvider(apiKey, modelName)
+	case "anthropic":
+		apiKey, _ := s.GetConfig("anthropic.api_key")
+		var ap *provider.AnthropicProvider
+		ap, err = provider.NewAnthropicProvider(apiKey, modelName)
+		if err != nil {
+			return nil, stop, err
+		}
+		if v, _ := s.GetConfig("anthropic.thinking_budget"); v != "" {
+			n, convErr := strconv.Atoi(v)
+			if convErr == nil {
+				convErr = ap.SetThinkingBudget(n)
+			}
+			if convErr != nil {
+				return nil, stop, fmt.Errorf("invalid anthropic.thinking_budget %q: must be 0 or at least %d tokens", v, provider.MinThinkingBudget)
+			}
+		}
+		p = ap
+	case "mistral":
+		apiKey, _ := s.GetConfig("mistral.api_key")
+		baseURL, _ := s.GetConfig("mistral.base_url")
+		p, err = provider.NewMistralProvider(apiKey, baseURL, modelName)
+	case "groq":
+		apiKey, _ := s.GetConfig("groq.api_key")
+		baseURL, _ := s.GetConfig("groq.base_url")
+		p, err = provider.NewGroqProvider(apiKey, baseURL, modelName)
+	case "huggingface":
+		endpoint, _ := s.GetConfig("huggingface.endpoint")
+		embedEndpoint, _ := s.GetConfig("huggingface.embed_endpoint")
+		token, tokenErr := secretConfig(s, "huggingface.api_key")
+		if tokenErr != nil {
+			return nil, stop, tokenErr
+		}
+		p, err = provider.NewHuggingFaceProvider(endpoint, token, embedEndpoint, modelName)
+	case "lmstudio":
+		baseURL, _ := s.GetConfig("lmstudio.base_url")
+		p, err = provider.NewLMStudioProvider(baseURL, modelName)
+	case "llamacpp":
+		baseURL, _ := s.GetConfig("llamacpp.base_url")
+		p, err = provider.NewLlamaCppProvider(baseURL, modelName)
+	case "plugin":
+		pluginPath, _ := s.GetConfig("provider.plugin.path")
+		if pluginPath == "" {
+			return nil, stop, fmt.Errorf("provider.plugin.path is not configured")
+		}
+		var pluginStop func()
+		p, pluginStop, err = plugin.LoadProvider(pluginPath, modelName)
+		if err == nil {
+			stop = pluginStop
+		}
+	case "fixture":
+		fixturePath, _ := s.GetConfig("provider.fixture.path")
+		if fixturePath == "" {
+			return nil, stop, fmt.Errorf("provider.fixture.path is not configured")
+		}
+		p, err = NewFixtureProvider(fixturePath)
+	default:
+		return nil, stop, fmt.Errorf("unknown provider %q", providerType)
+	}
+	return p, stop, err
+}
+
+// NewProxy creates the tool proxy a session or watcher runs with: shell tools
+// confined by the policy's sandbox, and the plugins of LoadProxyPlugins. A
+// sandbox of "auto" that finds none falls back to unsandboxed execution with
+// a warning on log, which may be nil. The returned stop function stops the
+// plugin processes and is never nil.
+func NewProxy(s store.Storage, g *guard.Guard, log *bolt.Logger) (*mcp.Proxy, func(), error) {
+	mp := mcp.NewProxy(s, g)
+	if err := configureSandbox(mp, g.Policy(), log); err != nil {
+		return nil, func() {}, err
+	}
+	stop, err := LoadProxyPlugins(s, mp)
+	if err != nil {
+		return nil, stop, err
+	}
+	return mp, stop, nil
+}
+
+// configureSandbox applies the policy's sandbox to mp's shell tools.
+func configureSandbox(mp *mcp.Proxy, policy guard.Policy, log *bolt.Logger) error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	sb, err := mcp.NewSandbox(policy.Sandbox, mcp.ProfileFromPolicy(policy, wd))
+	if err != nil {
+		return err
+	}
+	if sb == nil {
+		if policy.Sandbox == mcp.SandboxAuto && log != nil {
+			log.Warn().Msg("No sandbox (firejail or sandbox-exec) found, running tools unconfined")
+		}
+		return nil
+	}
+	if log != nil {
+		log.Info().Str("sandbox", sb.Name()).Msg("Shell tools run sandboxed")
+	}
+	mp.SetSandbox(sb)
+	return nil
+}
+
+// LoadProxyPlugins registers the plugins configured for mp: the verifier
+// plugins of the verify.plugins config key, comma-separated type=path pairs
+// (e.g. "staging-health=/usr/local/bin/simon-staging") run for checks of
+// their type, and the reducer plugins of the reducer.plugins key,
+// comma-separated paths tried in order before the built-in digest
+// heuristics. The returned stop function stops the plugin processes and is
+// never nil.
+func LoadProxyPlugins(s store.Storage, mp *mcp.Proxy) (func(), error) {
+	var stops []func()
+	stop := func() {
+		for _, stopPlugin := range stops {
+			stopPlugin()
+		}
+	}
+	fail := func(err error) (func(), error) {
+		stop()
+		return func() {}, err
+	}
+
+	value, _ := s.GetConfig("verify.plugins")
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, path, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || name == "" || path == "" {
+			return fail(fmt.Errorf("invalid verify.plugins entry %q: expected type=path", pair))
+		}
+		v, pluginStop, err := plugin.LoadVerifier(path, name)
+		if err != nil {
+			return fail(err)
+		}
+		stops = append(stops, pluginStop)
+		mp.RegisterVerifier(v)
+	}
+
+	value, _ = s.GetConfig("reducer.plugins")
+	var reducers []mcp.Reducer
+	for _, path := range strings.Split(value, ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		r, pluginStop, err := plugin.LoadReducer(path)
+		if err != nil {
+			return fail(err)
+		}
+		stops = append(stops, pluginStop)
+		reducers = append(reducers, mcp.FromContentReducer(r))
+	}
+	// UseReducer puts each reducer first, so the first listed is added last
+	for i := len(reducers) - 1; i >= 0; i-- {
+		mp.UseReducer(reducers[i])
+	}
+	return stop, nil
+}
+
+// NewFixtureProvider plays back a fixture recorded with `simon run --record`.
+func NewFixtureProvider(path string) (provider.Provider, error) {
+	f, err := provider.LoadFixture(path)
+	if err != nil {
+		return nil, err
+	}
+	return provider.NewFixtureProvider(f), nil
+}
@@ -0,0 +1,246 @@
+package store
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"testing"
+)
+
+func newMemoryStore(t testing.TB) *SQLiteStore {
+	tmpDir := t.TempDir()
+	s, err := NewSQLiteStore(filepath.Join(tmpDir, "meta.db"), filepath.Join(tmpDir, "artifacts"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	return s
+}
+
+func TestSQLiteStore_Memory(t *testing.T) {
+	s := newMemoryStore(t)
+	defer s.Close()
+
+	s.AddMemory("Built a Go CLI with cobra; remember to wire subcommands in init", []float32{1, 0, 0}, map[string]string{"goal": "cli"})
+	s.AddMemory("Fixed flaky sqlite tests by using WAL mode", []float32{0, 1, 0}, map[string]string{"goal": "sqlite"})
+	s.AddMemory("Wrote a React dashboard", []float32{0, 0, 1}, map[string]string{"goal": "web"})
+
+	t.Run("Vector", func(t *testing.T) {
+		results, err := s.QueryMemory(MemoryQuery{Text: "sqlite", Vector: []float32{0.9, 0.1, 0}, Limit: 1})
+		if err != nil {
+			t.Fatalf("QueryMemory failed: %v", err)
+		}
+		if len(results) != 1 || results[0].Metadata["goal"] != "cli" {
+			t.Errorf("Expected vector match on cli memory, got %+v", results)
+		}
+	})
+
+	t.Run("FTS", func(t *testing.T) {
+		s.SetMemorySearchMode(MemorySearchFTS)
+		results, err := s.QueryMemory(MemoryQuery{Text: "why are the SQLite tests flaky?", Limit: 3})
+		if err != nil {
+			t.Fatalf("QueryMemory failed: %v", err)
+		}
+		if len(results) == 0 || results[0].Metadata["goal"] != "sqlite" {
+			t.Errorf("Expected keyword match on sqlite memory, got %+v", results)
+		}
+	})
+
+	t.Run("Hybrid", func(t *testing.T) {
+		s.SetMemorySearchMode(MemorySearchHybrid)
+		// Keywords point at sqlite, the vector slightly prefers cli: keywords should tip it
+		results, err := s.QueryMemory(MemoryQuery{Text: "flaky sqlite WAL", Vector: []float32{0.6, 0.5, 0}, Limit: 2})
+		if err != nil {
+			t.Fatalf("QueryMemory failed: %v", err)
+		}
+		if len(results) != 2 || results[0].Metadata["goal"] != "sqlite" {
+			t.Errorf("Expected hybrid ranking to favor sqlite memory, got %+v", results)
+		}
+	})
+
+	t.Run("Without Vectors", func(t *testing.T) {
+		s.SetMemorySearchMode(MemorySearchVector)
+		if err := s.AddMemory("Retry flaky network calls with backoff", nil, map[string]string{"goal": "retry"}); err != nil {
+			t.Fatalf("AddMemory without a vector failed: %v", err)
+		}
+		results, err := s.QueryMemory(MemoryQuery{Text: "network calls are flaky", Limit: 1})
+		if err != nil {
+			t.Fatalf("QueryMemory failed: %v", err)
+		}
+		if len(results) != 1 || results[0].Metadata["goal"] != "retry" {
+			t.Errorf("Expected a query without a vector to fall back to keywords, got %+v", results)
+		}
+		results, err = s.QueryMemory(MemoryQuery{Text: "network calls are flaky", Vector: []float32{0, 0, 1}, Limit: 10})
+		if err != nil {
+			t.Fatalf("QueryMemory failed: %v", err)
+		}
+		for _, r := range results {
+			if r.Metadata["goal"] == "retry" {
+				t.Errorf("Expected a memory without a vector left out of a vector search, got %+v", results)
+			}
+		}
+		if len(results) != 3 {
+			t.Errorf("Expected the memories with a vector, got %d", len(results))
+		}
+	})
+
+	t.Run("Invalid Mode", func(t *testing.T) {
+		if err := s.SetMemorySearchMode("magic"); err == nil {
+			t.Error("Expected error for unknown mode")
+		}
+	})
+}
+
+func TestSQLiteStore_MemoryNamespace(t *testing.T) {
+	s := newMemoryStore(t)
+	defer s.Close()
+
+	s.AddMemory("api: retry sqlite writes", []float32{1, 0}, map[string]string{MemoryNamespaceKey: "/src/api"})
+	s.AddMemory("web: sqlite is only used in tests", []float32{1, 0.1}, map[string]string{MemoryNamespaceKey: "/src/web"})
+	s.AddMemory("legacy: sqlite needs WAL", []float32{0.9, 0.2}, nil)
+
+	for _, mode := range []MemorySearchMode{MemorySearchVector, MemorySearchFTS, MemorySearchHybrid} {
+		s.SetMemorySearchMode(mode)
+		query := MemoryQuery{Text: "sqlite", Vector: []float32{1, 0}, Limit: 3, Namespace: "/src/api"}
+		results, err := s.QueryMemory(query)
+		if err != nil {
+			t.Fatalf("%s: QueryMemory failed: %v", mode, err)
+		}
+		// Memories without a namespace predate namespacing and belong to
+		// no particular project
+		if len(results) != 1 || results[0].Metadata[MemoryNamespaceKey] != "/src/api" {
+			t.Errorf("%s: expected only the api memory, got %+v", mode, results)
+		}
+
+		query.Namespace = ""
+		if results, _ := s.QueryMemory(query); len(results) != 3 {
+			t.Errorf("%s: expected an empty namespace to search every project, got %+v", mode, results)
+		}
+	}
+
+	if n, err := s.MoveMemories("", "/src/api"); err != nil || n != 1 {
+		t.Fatalf("Expected the legacy memory moved, got %d, %v", n, err)
+	}
+	for _, mode := range []MemorySearchMode{MemorySearchVector, MemorySearchFTS} {
+		s.SetMemorySearchMode(mode)
+		results, _ := s.QueryMemory(MemoryQuery{Text: "sqlite", Vector: []float32{1, 0}, Limit: 3, Namespace: "/src/api"})
+		if len(results) != 2 {
+			t.Errorf("%s: expected the api and moved memories, got %+v", mode, results)
+		}
+	}
+	if n, _ := s.MoveMemories("", "/src/api"); n != 0 {
+		t.Errorf("Expected nothing left to move, got %d", n)
+	}
+}
+
+func TestSQLiteStore_MemoryLazyIndex(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "meta.db")
+	s, _ := NewSQLiteStore(dbPath, filepath.Join(tmpDir, "artifacts"))
+	s.AddMemory("first", []float32{1, 0}, nil)
+	s.Close()
+
+	// A fresh store must not drop older rows when a memory is added before the first search
+	s, _ = NewSQLiteStore(dbPath, filepath.Join(tmpDir, "artifacts"))
+	defer s.Close()
+	if s.memoryIndex.loaded {
+		t.Error("Expected index to load lazily")
+	}
+	s.AddMemory("second", []float32{0, 1}, nil)
+
+	results, err := s.SearchMemory([]float32{1, 0}, 2)
+	if err != nil {
+		t.Fatalf("SearchMemory failed: %v", err)
+	}
+	if len(results) != 2 || results[0].Content != "first" {
+		t.Errorf("Expected both memories with 'first' on top, got %+v", results)
+	}
+}
+
+func TestFTSQuery(t *testing.T) {
+	if got := ftsQuery(`fix "go" tests; OR NOT*`); got != `"fix" OR "go" OR "tests" OR "or" OR "not"` {
+		t.Errorf("Unexpected FTS query: %s", got)
+	}
+	if got := ftsQuery("?!"); got != "" {
+		t.Errorf("Expected empty query, got %s", got)
+	}
+}
+
+// seedMemories fills a store with n memories over a small vocabulary.
+func seedMemories(b *testing.B, s *SQLiteStore, n, dims int) {
+	words := []string{"go", "cli", "sqlite", "react", "docker", "tests", "api", "auth", "cache", "deploy"}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < n; i++ {
+		vec := make([]float32, dims)
+		for d := range vec {
+			vec[d] = rng.Float32()
+		}
+		content := fmt.Sprintf("session %d: %s %s %s", i, words[rng.Intn(len(words))], words[rng.Intn(len(words))], words[rng.Intn(len(words))])
+		if err := s.AddMemory(content, vec, nil); err != nil {
+			b.Fatalf("AddMemory failed: %v", err)
+		}
+	}
+}
+
+func benchmarkMemory(b *testing.B, mode MemorySearchMode) {
+	s := newMemoryStore(b)
+	defer s.Close()
+	seedMemories(b, s, 2000, 256)
+	s.SetMemorySearchMode(mode)
+
+	query := MemoryQuery{Text: "sqlite tests", Vector: make([]float32, 256), Limit: 3}
+	for d := range query.Vector {
+		query.Vector[d] = 0.5
+	}
+	s.QueryMemory(query) // Warm the lazy index
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.QueryMemory(query); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkQueryMemory_Vector(b *testing.B) { benchmarkMemory(b, MemorySearchVector) }
+func BenchmarkQueryMemory_FTS(b *testing.B)    { benchmarkMemory(b, MemorySearchFTS) }
+func BenchmarkQueryMemory_Hybrid(b *testing.B) { benchmarkMemory(b, MemorySearchHybrid) }
+
+// BenchmarkMemoryIndexLoad measures the cold-start cost paid on the first search.
+func BenchmarkMemoryIndexLoad(b *testing.B) {
+	s := newMemoryStore(b)
+	defer s.Close()
+	seedMemories(b, s, 2000, 256)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.memoryIndex.clear()
+		if err := s.loadMemoryIndex(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestSQLiteStore_UpdateMemoryVectors(t *testing.T) {
+	tmpDir := t.TempDir()
+	s, _ := NewSQLiteStore(filepath.Join(tmpDir, "meta.db"), filepath.Join(tmpDir, "artifacts"))
+	defer s.Close()
+	s.AddMemory("first", []float32{1, 0}, nil)
+	s.AddMemory("second", []float32{0, 1}, nil)
+	s.SearchMemory([]float32{1, 0}, 1) // load the index
+
+	records, _ := s.ListMemories()
+	if err := s.UpdateMemoryVectors(map[int64][]float32{
+		records[0].ID: {0, 0, 1},
+		records[1].ID: {1, 0, 0},
+	}); err != nil {
+		t.Fatalf("UpdateMemoryVectors failed: %v", err)
+	}
+
+	results, err := s.SearchMemory([]float32{1, 0, 0}, 1)
+	if err != nil {
+		t.Fatalf("SearchMemory failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Content != "second" {
+		t.Errorf("Expected the new vectors to be searched, got %+v", results)
+	}
+}
@@ -0,0 +1,304 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrSchemaTooNew is returned when the database was migrated by a newer
+// version of simon than the one opening it.
+var ErrSchemaTooNew = errors.New("database schema is newer than this version of simon supports")
+
+// execer is the subset of *sql.DB and *sql.Tx used by migrations.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// migration is one step of the schema history. Steps are applied in order,
+// each in its own transaction, and must never be edited once released;
+// schema changes are made by appending a new step.
+type migration struct {
+	version     int
+	description string
+	up          func(tx execer) error
+}
+
+// migrations is the ordered schema history. Early steps are idempotent so
+// databases created before versioning was introduced upgrade cleanly.
+var migrations = []migration{
+	{1, "initial schema", migrateInitialSchema},
+	{2, "session usage accounting", func(tx execer) error {
+		return addColumns(tx, "sessions", [][2]string{
+			{"provider", "TEXT DEFAULT ''"},
+			{"model", "TEXT DEFAULT ''"},
+			{"prompt_tokens", "INTEGER DEFAULT 0"},
+			{"completion_tokens", "INTEGER DEFAULT 0"},
+			{"cost", "REAL DEFAULT 0"},
+		})
+	}},
+	{3, "session cancellation", func(tx execer) error {
+		return addColumns(tx, "sessions", [][2]string{{"cancel_requested", "INTEGER DEFAULT 0"}})
+	}},
+	{4, "memory full-text index", initMemoryFTS},
+	{5, "session tags", func(tx execer) error {
+		if _, err := tx.Exec(`CREATE TABLE IF NOT EXISTS session_tags (
+			session_id TEXT NOT NULL,
+			key TEXT NOT NULL,
+			value TEXT NOT NULL,
+			PRIMARY KEY(session_id, key),
+			FOREIGN KEY(session_id) REFERENCES sessions(id)
+		);`); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_session_tags_key_value ON session_tags(key, value);`)
+		return err
+	}},
+	{6, "conversation history", func(tx execer) error {
+		_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS messages (
+			session_id TEXT NOT NULL,
+			seq INTEGER NOT NULL,
+			role TEXT NOT NULL,
+			content TEXT,
+			tool_calls TEXT DEFAULT '',
+			tool_call_id TEXT DEFAULT '',
+			prompt_tokens INTEGER DEFAULT 0,
+			completion_tokens INTEGER DEFAULT 0,
+			created_at DATETIME,
+			PRIMARY KEY(session_id, seq),
+			FOREIGN KEY(session_id) REFERENCES sessions(id)
+		);`)
+		return err
+	}},
+	{7, "provider response cache", func(tx execer) error {
+		_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS response_cache (
+			key TEXT PRIMARY KEY,
+			response BLOB NOT NULL,
+			created_at DATETIME,
+			hits INTEGER DEFAULT 0
+		);`)
+		return err
+	}},
+	{8, "session parents", func(tx execer) error {
+		if err := addColumns(tx, "sessions", [][2]string{{"parent_id", "TEXT DEFAULT ''"}}); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_sessions_parent_id ON sessions(parent_id);`)
+		return err
+	}},
+	{9, "content-addressed artifacts", func(tx execer) error {
+		if err := addColumns(tx, "artifacts", [][2]string{
+			{"mime_type", "TEXT DEFAULT ''"},
+			{"size", "INTEGER DEFAULT 0"},
+			{"blob", "TEXT DEFAULT ''"},
+		}); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_artifacts_session_id ON artifacts(session_id);`)
+		return err
+	}},
+	{10, "guard violations", func(tx execer) error {
+		if _, err := tx.Exec(`CREATE TABLE IF NOT EXISTS violations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			session_id TEXT NOT NULL,
+			rule TEXT NOT NULL,
+			severity TEXT NOT NULL,
+			subject TEXT DEFAULT '',
+			message TEXT,
+			created_at DATETIME,
+			FOREIGN KEY(session_id) REFERENCES sessions(id)
+		);`); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_violations_session_id ON violations(session_id);`)
+		return err
+	}},
+	{11, "state snapshots", func(tx execer) error {
+		_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS snapshots (
+			session_id TEXT NOT NULL,
+			iteration INTEGER NOT NULL,
+			status TEXT,
+			prompt_tokens INTEGER DEFAULT 0,
+			output_tokens INTEGER DEFAULT 0,
+			context_tokens INTEGER DEFAULT 0,
+			messages TEXT,
+			response TEXT DEFAULT '',
+			error TEXT DEFAULT '',
+			created_at DATETIME,
+			PRIMARY KEY(session_id, iteration),
+			FOREIGN KEY(session_id) REFERENCES sessions(id)
+		);`)
+		return err
+	}},
+	{12, "session steering", func(tx execer) error {
+		if _, err := tx.Exec(`CREATE TABLE IF NOT EXISTS steering (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			session_id TEXT NOT NULL,
+			message TEXT DEFAULT '',
+			provider TEXT DEFAULT '',
+			model TEXT DEFAULT '',
+			created_at DATETIME,
+			FOREIGN KEY(session_id) REFERENCES sessions(id)
+		);`); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_steering_session_id ON steering(session_id);`)
+		return err
+	}},
+	{13, "session heartbeats", func(tx execer) error {
+		return addColumns(tx, "sessions", [][2]string{
+			{"heartbeat_at", "DATETIME"},
+			{"owner", "TEXT DEFAULT ''"},
+		})
+	}},
+	{14, "artifact integrity", func(tx execer) error {
+		return addColumns(tx, "artifacts", [][2]string{{"missing", "INTEGER DEFAULT 0"}})
+	}},
+	{15, "snapshot deltas", func(tx execer) error {
+		return addColumns(tx, "snapshots", [][2]string{{"base_messages", "INTEGER DEFAULT 0"}})
+	}},
+}
+
+// latestSchemaVersion is the version a fully migrated database has.
+func latestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+func migrateInitialSchema(tx execer) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS sessions (
+			id TEXT PRIMARY KEY,
+			created_at DATETIME,
+			updated_at DATETIME,
+			status TEXT,
+			metadata TEXT
+		);`,
+		`CREATE TABLE IF NOT EXISTS artifacts (
+			id TEXT PRIMARY KEY,
+			session_id TEXT,
+			path TEXT,
+			type TEXT,
+			created_at DATETIME,
+			digest TEXT,
+			FOREIGN KEY(session_id) REFERENCES sessions(id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS configuration (
+			key TEXT PRIMARY KEY,
+			value TEXT
+		);`,
+		`CREATE TABLE IF NOT EXISTS memories (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			content TEXT,
+			vector BLOB,
+			metadata TEXT
+		);`,
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrate brings the database up to latestSchemaVersion. It refuses to open
+// databases whose version is newer than the migrations known to this build.
+func (s *SQLiteStore) migrate() error {
+	if _, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		description TEXT,
+		applied_at DATETIME
+	);`); err != nil {
+		return fmt.Errorf("failed to create schema_version table: %w", err)
+	}
+
+	current, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if latest := latestSchemaVersion(); current > latest {
+		return fmt.Errorf("%w: database is at version %d, this build supports up to %d", ErrSchemaTooNew, current, latest)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := s.applyMigration(m); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.description, err)
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteStore) applyMigration(m migration) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.up(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_version (version, description, applied_at) VALUES (?, ?, ?)`,
+		m.version, m.description, time.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SchemaVersion returns the highest migration applied to the database.
+func (s *SQLiteStore) SchemaVersion() (int, error) {
+	var version sql.NullInt64
+	if err := s.db.QueryRow(`SELECT MAX(version) FROM schema_version`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// addColumns adds columns to a table, skipping those that already exist.
+func addColumns(tx execer, table string, columns [][2]string) error {
+	for _, c := range columns {
+		if err := ensureColumn(tx, table, c[0], c[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureColumn adds a column to a table if it does not already exist.
+func ensureColumn(tx execer, table, column, definition string) error {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMigrations(t *testing.T) {
+	t.Run("Fresh Database", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		dbPath := filepath.Join(tmpDir, "meta.db")
+		s, err := NewSQLiteStore(dbPath, filepath.Join(tmpDir, "artifacts"))
+		if err != nil {
+			t.Fatalf("Failed to create store: %v", err)
+		}
+		if v, _ := s.SchemaVersion(); v != latestSchemaVersion() {
+			t.Errorf("Expected version %d, got %d", latestSchemaVersion(), v)
+		}
+		s.Close()
+
+		// Reopening must not re-apply anything
+		s, err = NewSQLiteStore(dbPath, filepath.Join(tmpDir, "artifacts"))
+		if err != nil {
+			t.Fatalf("Failed to reopen store: %v", err)
+		}
+		defer s.Close()
+		var applied int
+		s.db.QueryRow(`SELECT COUNT(*) FROM schema_version`).Scan(&applied)
+		if applied != len(migrations) {
+			t.Errorf("Expected %d recorded migrations, got %d", len(migrations), applied)
+		}
+	})
+
+	t.Run("Unversioned Legacy Database", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		dbPath := filepath.Join(tmpDir, "meta.db")
+
+		// Schema as created before usage accounting and versioning
+		db, err := sql.Open("sqlite", dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := migrateInitialSchema(db); err != nil {
+			t.Fatal(err)
+		}
+		db.Exec(`INSERT INTO sessions (id, created_at, updated_at, status, metadata) VALUES ('legacy', ?, ?, 'completed', '{}')`, time.Now(), time.Now())
+		db.Exec(`INSERT INTO memories (content, vector, metadata) VALUES ('legacy memory about sqlite', NULL, '{}')`)
+		db.Close()
+
+		s, err := NewSQLiteStore(dbPath, filepath.Join(tmpDir, "artifacts"))
+		if err != nil {
+			t.Fatalf("Failed to migrate legacy database: %v", err)
+		}
+		defer s.Close()
+
+		sess, err := s.GetSession("legacy")
+		if err != nil {
+			t.Fatalf("Expected legacy session to survive migration: %v", err)
+		}
+		if sess.Status != "completed" || sess.PromptTokens != 0 {
+			t.Errorf("Unexpected migrated session: %+v", sess)
+		}
+		sess.Cost = 0.5
+		if err := s.UpdateSession(sess); err != nil {
+			t.Errorf("Expected usage columns after migration: %v", err)
+		}
+		if results, _ := s.searchMemoryFTS("sqlite", 1, ""); len(results) != 1 {
+			t.Errorf("Expected legacy memory to be backfilled into the FTS index, got %d results", len(results))
+		}
+	})
+
+	t.Run("Newer Database Refused", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		dbPath := filepath.Join(tmpDir, "meta.db")
+		s, err := NewSQLiteStore(dbPath, filepath.Join(tmpDir, "artifacts"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.db.Exec(`INSERT INTO schema_version (version, description, applied_at) VALUES (?, 'from the future', ?)`, latestSchemaVersion()+1, time.Now())
+		s.Close()
+
+		if _, err := NewSQLiteStore(dbPath, filepath.Join(tmpDir, "artifacts")); !errors.Is(err, ErrSchemaTooNew) {
+			t.Errorf("Expected ErrSchemaTooNew, got %v", err)
+		}
+	})
+}
@@ -3,9 +3,11 @@ package store
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -15,7 +17,14 @@ import (
 type SQLiteStore struct {
 	db          *sql.DB
 	artifactDir string
-	memoryIndex *vectorIndex // In-memory index for fast vector search
+	memoryIndex *vectorIndex     // In-memory index for fast vector search, loaded on first use
+	memoryMode  MemorySearchMode // Ranking used by QueryMemory
+	maxArtifact int64            // Largest artifact SaveArtifact accepts, in bytes
+
+	cipher         MessageCipher // Decrypts history; see SetMessageCipher
+	encryptHistory bool          // Whether history is encrypted as it is written
+
+	configDefaults map[string]string // Returned by GetConfig for unset keys
 }
 
 func NewSQLiteStore(dbPath, artifactDir string) (*SQLiteStore, error) {
@@ -56,9 +65,12 @@ func NewSQLiteStore(dbPath, artifactDir string) (*SQLiteStore, error) {
 	store := &SQLiteStore{
 		db:          db,
 		artifactDir: artifactDir,
+		memoryIndex: newVectorIndex(),
+		memoryMode:  MemorySearchVector,
+		maxArtifact: DefaultMaxArtifactSize,
 	}
 
-	if err := store.initSchema(); err != nil {
+	if err := store.migrate(); err != nil {
 		db.Close()
 		return nil, err
 	}
@@ -66,44 +78,6 @@ func NewSQLiteStore(dbPath, artifactDir string) (*SQLiteStore, error) {
 	return store, nil
 }
 
-func (s *SQLiteStore) initSchema() error {
-	queries := []string{
-		`CREATE TABLE IF NOT EXISTS sessions (
-			id TEXT PRIMARY KEY,
-			created_at DATETIME,
-			updated_at DATETIME,
-			status TEXT,
-			metadata TEXT
-		);`,
-		`CREATE TABLE IF NOT EXISTS artifacts (
-			id TEXT PRIMARY KEY,
-			session_id TEXT,
-			path TEXT,
-			type TEXT,
-			created_at DATETIME,
-			digest TEXT,
-			FOREIGN KEY(session_id) REFERENCES sessions(id)
-		);`,
-		`CREATE TABLE IF NOT EXISTS configuration (
-			key TEXT PRIMARY KEY,
-			value TEXT
-		);`,
-		`CREATE TABLE IF NOT EXISTS memories (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			content TEXT,
-			vector BLOB,
-			metadata TEXT
-		);`,
-	}
-
-	for _, query := range queries {
-		if _, err := s.db.Exec(query); err != nil {
-			return fmt.Errorf("failed to init schema: %w", err)
-		}
-	}
-	return nil
-}
-
 func (s *SQLiteStore) Close() error {
 	return s.db.Close()
 }
@@ -116,19 +90,42 @@ func (s *SQLiteStore) SetConfig(key, value string) error {
 	return err
 }
 
+// SetConfigs sets several configuration values in one transaction, so
+// either all of them change or none do.
+func (s *SQLiteStore) SetConfigs(values map[string]string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for key, value := range values {
+		if _, err := tx.Exec(`INSERT INTO configuration (key, value) VALUES (?, ?) ON CONFLICT(key) DO UPDATE SET value = excluded.value`, key, value); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
 func (s *SQLiteStore) GetConfig(key string) (string, error) {
 	query := `SELECT value FROM configuration WHERE key = ?`
 	row := s.db.QueryRow(query, key)
 	var value string
 	if err := row.Scan(&value); err != nil {
 		if err == sql.ErrNoRows {
-			return "", nil // Return empty string if not found, or error? Let's return empty for now.
+			return s.configDefaults[key], nil
 		}
 		return "", err
 	}
 	return value, nil
 }
 
+// SetConfigDefaults sets the values GetConfig returns for keys not set in
+// the store, such as those of a project's .simon.yaml. ListConfig lists
+// only the stored keys.
+func (s *SQLiteStore) SetConfigDefaults(defaults map[string]string) {
+	s.configDefaults = defaults
+}
+
 // Session Implementation
 
 func (s *SQLiteStore) CreateSession(session *Session) error {
@@ -137,21 +134,65 @@ func (s *SQLiteStore) CreateSession(session *Session) error {
 		return fmt.Errorf("failed to marshal metadata: %w", err)
 	}
 
-	query := `INSERT INTO sessions (id, created_at, updated_at, status, metadata) VALUES (?, ?, ?, ?, ?)`
-	_, err = s.db.Exec(query, session.ID, session.CreatedAt, session.UpdatedAt, session.Status, string(metaJSON))
-	return err
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO sessions (id, created_at, updated_at, status, metadata, provider, model, prompt_tokens, completion_tokens, cost, parent_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	if _, err := tx.Exec(query, session.ID, session.CreatedAt, session.UpdatedAt, session.Status, string(metaJSON),
+		session.Provider, session.Model, session.PromptTokens, session.CompletionTokens, session.Cost, session.ParentID); err != nil {
+		return err
+	}
+	for key, value := range session.Tags {
+		if _, err := tx.Exec(`INSERT INTO session_tags (session_id, key, value) VALUES (?, ?, ?)`, session.ID, key, value); err != nil {
+			return fmt.Errorf("failed to save tag %s: %w", key, err)
+		}
+	}
+	return tx.Commit()
 }
 
-func (s *SQLiteStore) GetSession(id string) (*Session, error) {
-	query := `SELECT id, created_at, updated_at, status, metadata FROM sessions WHERE id = ?`
-	row := s.db.QueryRow(query, id)
+// loadTags fills in the tags of the given sessions.
+func (s *SQLiteStore) loadTags(sessions ...*Session) error {
+	for _, session := range sessions {
+		rows, err := s.db.Query(`SELECT key, value FROM session_tags WHERE session_id = ?`, session.ID)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var key, value string
+			if err := rows.Scan(&key, &value); err != nil {
+				rows.Close()
+				return err
+			}
+			if session.Tags == nil {
+				session.Tags = make(map[string]string)
+			}
+			session.Tags[key] = value
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sessionColumns is the column list shared by session queries, in scanSession order.
+const sessionColumns = `id, created_at, updated_at, status, metadata, provider, model, prompt_tokens, completion_tokens, cost, parent_id`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
 
+func scanSession(row rowScanner) (*Session, error) {
 	var session Session
 	var metaJSON string
-	if err := row.Scan(&session.ID, &session.CreatedAt, &session.UpdatedAt, &session.Status, &metaJSON); err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("session not found: %s", id)
-		}
+	if err := row.Scan(&session.ID, &session.CreatedAt, &session.UpdatedAt, &session.Status, &metaJSON,
+		&session.Provider, &session.Model, &session.PromptTokens, &session.CompletionTokens, &session.Cost, &session.ParentID); err != nil {
 		return nil, err
 	}
 
@@ -162,17 +203,170 @@ func (s *SQLiteStore) GetSession(id string) (*Session, error) {
 	return &session, nil
 }
 
+func (s *SQLiteStore) GetSession(id string) (*Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE id = ?`
+	session, err := scanSession(s.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("session not found: %s", id)
+		}
+		return nil, err
+	}
+	if err := s.loadTags(session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
 func (s *SQLiteStore) UpdateSession(session *Session) error {
 	metaJSON, err := json.Marshal(session.Metadata)
 	if err != nil {
 		return fmt.Errorf("failed to marshal metadata: %w", err)
 	}
 
-	query := `UPDATE sessions SET updated_at = ?, status = ?, metadata = ? WHERE id = ?`
-	_, err = s.db.Exec(query, time.Now(), session.Status, string(metaJSON), session.ID)
+	query := `UPDATE sessions SET updated_at = ?, status = ?, metadata = ?, provider = ?, model = ?, prompt_tokens = ?, completion_tokens = ?, cost = ? WHERE id = ?`
+	_, err = s.db.Exec(query, time.Now(), session.Status, string(metaJSON),
+		session.Provider, session.Model, session.PromptTokens, session.CompletionTokens, session.Cost, session.ID)
 	return err
 }
 
+// RequestCancel flags a session for cancellation. The flag lives in its own
+// column so UpdateSession calls from a running loop never overwrite it.
+func (s *SQLiteStore) RequestCancel(id string) error {
+	res, err := s.db.Exec(`UPDATE sessions SET cancel_requested = 1 WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("session not found: %s", id)
+	}
+	return nil
+}
+
+// CancelRequested reports whether cancellation was requested for a session.
+func (s *SQLiteStore) CancelRequested(id string) (bool, error) {
+	var flag int
+	if err := s.db.QueryRow(`SELECT cancel_requested FROM sessions WHERE id = ?`, id).Scan(&flag); err != nil {
+		if err == sql.ErrNoRows {
+			return false, fmt.Errorf("session not found: %s", id)
+		}
+		return false, err
+	}
+	return flag != 0, nil
+}
+
+// ListSessions returns sessions matching the filter, newest first.
+// Time filtering happens in Go because timestamps are stored as driver-formatted text;
+// tag filters use the session_tags index.
+func (s *SQLiteStore) ListSessions(filter SessionFilter) ([]*Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions`
+	var conds []string
+	var args []interface{}
+	for key, value := range filter.Tags {
+		conds = append(conds, `id IN (SELECT session_id FROM session_tags WHERE key = ? AND value = ?)`)
+		args = append(args, key, value)
+	}
+	if filter.ParentID != "" {
+		conds = append(conds, `parent_id = ?`)
+		args = append(args, filter.ParentID)
+	}
+	if len(conds) > 0 {
+		query += ` WHERE ` + strings.Join(conds, ` AND `)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		if !filter.Since.IsZero() && session.CreatedAt.Before(filter.Since) {
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.After(sessions[j].CreatedAt)
+	})
+	if filter.Limit > 0 && len(sessions) > filter.Limit {
+		sessions = sessions[:filter.Limit]
+	}
+	if err := s.loadTags(sessions...); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// Conversation History Implementation
+
+func (s *SQLiteStore) AppendMessages(sessionID string, messages []*Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var next int
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(seq) + 1, 0) FROM messages WHERE session_id = ?`, sessionID).Scan(&next); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO messages (session_id, seq, role, content, tool_calls, tool_call_id, prompt_tokens, completion_tokens, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	for _, m := range messages {
+		m.SessionID = sessionID
+		m.Seq = next
+		if m.CreatedAt.IsZero() {
+			m.CreatedAt = time.Now()
+		}
+		content, toolCalls := m.Content, m.ToolCalls
+		if err := s.sealHistory(sessionID, &content, &toolCalls); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(query, sessionID, m.Seq, m.Role, content, toolCalls, m.ToolCallID,
+			m.PromptTokens, m.CompletionTokens, m.CreatedAt); err != nil {
+			return fmt.Errorf("failed to append message %d: %w", m.Seq, err)
+		}
+		next++
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) LoadMessages(sessionID string) ([]*Message, error) {
+	rows, err := s.db.Query(`SELECT session_id, seq, role, content, tool_calls, tool_call_id, prompt_tokens, completion_tokens, created_at
+		FROM messages WHERE session_id = ? ORDER BY seq`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []*Message
+	for rows.Next() {
+		var m Message
+		if err := rows.Scan(&m.SessionID, &m.Seq, &m.Role, &m.Content, &m.ToolCalls, &m.ToolCallID,
+			&m.PromptTokens, &m.CompletionTokens, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := s.openHistory(sessionID, &m.Content, &m.ToolCalls); err != nil {
+			return nil, err
+		}
+		messages = append(messages, &m)
+	}
+	return messages, rows.Err()
+}
+
 // Artifact Implementation
 
 // sanitizeArtifactPath validates and sanitizes an artifact path to prevent path traversal attacks.
@@ -210,58 +404,103 @@ func (s *SQLiteStore) sanitizeArtifactPath(path string) (string, error) {
 	return fullPath, nil
 }
 
+// SaveArtifact persists an artifact's metadata and stores its content in the
+// blob store, keyed by SHA-256 so identical content is kept once. It fills in
+// the artifact's size, blob, and MIME type (when not set), and rejects
+// content over the size limit with ErrArtifactTooLarge.
 func (s *SQLiteStore) SaveArtifact(artifact *Artifact, content []byte) error {
-	// 1. Sanitize and validate the artifact path
-	fullPath, err := s.sanitizeArtifactPath(artifact.Path)
-	if err != nil {
+	// 1. Validate the artifact path, which names the artifact even though the
+	// content lives in the blob store
+	if _, err := s.sanitizeArtifactPath(artifact.Path); err != nil {
 		return fmt.Errorf("invalid artifact path: %w", err)
 	}
+	if s.maxArtifact > 0 && int64(len(content)) > s.maxArtifact {
+		return fmt.Errorf("%w: %s is %d bytes, the limit is %d", ErrArtifactTooLarge, artifact.ID, len(content), s.maxArtifact)
+	}
 
-	// 2. Save content to filesystem
-	if err := os.MkdirAll(filepath.Dir(fullPath), 0750); err != nil {
-		return fmt.Errorf("failed to create artifact dir: %w", err)
+	// 2. Save content to the blob store
+	blob, err := s.writeBlob(content)
+	if err != nil {
+		return err
 	}
-	if err := os.WriteFile(fullPath, content, 0600); err != nil {
-		return fmt.Errorf("failed to write artifact content: %w", err)
+	artifact.Blob = blob
+	artifact.Size = int64(len(content))
+	artifact.Missing = false
+	if artifact.MIMEType == "" {
+		artifact.MIMEType = DetectMIMEType(artifact.Path, content)
 	}
 
 	// 3. Save metadata to DB
-	query := `INSERT INTO artifacts (id, session_id, path, type, created_at, digest) VALUES (?, ?, ?, ?, ?, ?)`
-	_, err = s.db.Exec(query, artifact.ID, artifact.SessionID, artifact.Path, artifact.Type, artifact.CreatedAt, artifact.Digest)
+	query := `INSERT INTO artifacts (` + artifactColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = s.db.Exec(query, artifact.ID, artifact.SessionID, artifact.Path, artifact.Type, artifact.CreatedAt, artifact.Digest,
+		artifact.MIMEType, artifact.Size, artifact.Blob, artifact.Missing)
 	return err
 }
 
+const artifactColumns = `id, session_id, path, type, created_at, digest, mime_type, size, blob, missing`
+
+func scanArtifact(row interface{ Scan(...any) error }) (*Artifact, error) {
+	var a Artifact
+	if err := row.Scan(&a.ID, &a.SessionID, &a.Path, &a.Type, &a.CreatedAt, &a.Digest, &a.MIMEType, &a.Size, &a.Blob, &a.Missing); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
 func (s *SQLiteStore) GetArtifact(id string) (*Artifact, []byte, error) {
 	// 1. Get metadata
-	query := `SELECT id, session_id, path, type, created_at, digest FROM artifacts WHERE id = ?`
-	row := s.db.QueryRow(query, id)
-
-	var artifact Artifact
-	if err := row.Scan(&artifact.ID, &artifact.SessionID, &artifact.Path, &artifact.Type, &artifact.CreatedAt, &artifact.Digest); err != nil {
+	artifact, err := scanArtifact(s.db.QueryRow(`SELECT `+artifactColumns+` FROM artifacts WHERE id = ?`, id))
+	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil, fmt.Errorf("artifact not found: %s", id)
 		}
 		return nil, nil, err
 	}
 
-	// 2. Sanitize and validate the artifact path from database
-	fullPath, err := s.sanitizeArtifactPath(artifact.Path)
+	// 2. Get content
+	content, err := s.readArtifact(artifact)
 	if err != nil {
-		return nil, nil, fmt.Errorf("invalid artifact path in database: %w", err)
+		return nil, nil, err
 	}
+	return artifact, content, nil
+}
 
-	// 3. Get content
-	content, err := os.ReadFile(fullPath)
+// readArtifact returns an artifact's content from the blob store, checked
+// against the SHA-256 naming its blob, or from the artifact path for
+// artifacts saved before content addressing, whose size and MIME type it
+// fills in. Content that is gone fails with ErrArtifactMissing, content
+// that doesn't match its digest with ErrArtifactCorrupt.
+func (s *SQLiteStore) readArtifact(artifact *Artifact) ([]byte, error) {
+	if artifact.Missing {
+		return nil, fmt.Errorf("%w: %s (%s)", ErrArtifactMissing, artifact.ID, artifact.Path)
+	}
+	var content []byte
+	var err error
+	if artifact.Blob != "" {
+		content, err = s.readBlob(artifact.Blob)
+	} else {
+		fullPath, pathErr := s.sanitizeArtifactPath(artifact.Path)
+		if pathErr != nil {
+			return nil, fmt.Errorf("invalid artifact path in database: %w", pathErr)
+		}
+		content, err = os.ReadFile(fullPath)
+		artifact.Size = int64(len(content))
+		artifact.MIMEType = DetectMIMEType(artifact.Path, content)
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s (%s)", ErrArtifactMissing, artifact.ID, artifact.Path)
+	}
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to read artifact content: %w", err)
+		return nil, fmt.Errorf("failed to read artifact content: %w", err)
 	}
-
-	return &artifact, content, nil
+	if err := verifyBlob(artifact, content); err != nil {
+		return nil, err
+	}
+	return content, nil
 }
 
 func (s *SQLiteStore) ListArtifacts(sessionID string) ([]*Artifact, error) {
-	query := `SELECT id, session_id, path, type, created_at, digest FROM artifacts WHERE session_id = ?`
-	rows, err := s.db.Query(query, sessionID)
+	rows, err := s.db.Query(`SELECT `+artifactColumns+` FROM artifacts WHERE session_id = ?`, sessionID)
 	if err != nil {
 		return nil, err
 	}
@@ -269,11 +508,11 @@ func (s *SQLiteStore) ListArtifacts(sessionID string) ([]*Artifact, error) {
 
 	var artifacts []*Artifact
 	for rows.Next() {
-		var a Artifact
-		if err := rows.Scan(&a.ID, &a.SessionID, &a.Path, &a.Type, &a.CreatedAt, &a.Digest); err != nil {
+		a, err := scanArtifact(rows)
+		if err != nil {
 			return nil, err
 		}
-		artifacts = append(artifacts, &a)
+		artifacts = append(artifacts, a)
 	}
 	return artifacts, nil
-}
\ No newline at end of file
+}
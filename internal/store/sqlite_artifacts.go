@@ -0,0 +1,234 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultMaxArtifactSize is the largest artifact a store accepts unless
+// SetMaxArtifactSize changes it.
+const DefaultMaxArtifactSize = 64 << 20
+
+var (
+	// ErrArtifactTooLarge is returned by SaveArtifact for content over the
+	// store's size limit.
+	ErrArtifactTooLarge = errors.New("artifact exceeds the size limit")
+	// ErrArtifactMissing is returned when an artifact's content is gone
+	// from the artifact directory.
+	ErrArtifactMissing = errors.New("artifact content is missing")
+	// ErrArtifactCorrupt is returned when an artifact's content no longer
+	// matches the SHA-256 it was stored under.
+	ErrArtifactCorrupt = errors.New("artifact content is corrupt")
+)
+
+// SetMaxArtifactSize sets the largest artifact SaveArtifact accepts, in
+// bytes; 0 removes the limit.
+func (s *SQLiteStore) SetMaxArtifactSize(n int64) error {
+	if n < 0 {
+		return fmt.Errorf("invalid artifact size limit %d", n)
+	}
+	s.maxArtifact = n
+	return nil
+}
+
+// MaxArtifactSize returns the largest artifact SaveArtifact accepts, in
+// bytes; 0 means no limit.
+func (s *SQLiteStore) MaxArtifactSize() int64 {
+	return s.maxArtifact
+}
+
+// DetectMIMEType returns the MIME type of an artifact, from its file
+// extension when that is known and otherwise by sniffing the content.
+func DetectMIMEType(path string, content []byte) string {
+	if t := mime.TypeByExtension(filepath.Ext(path)); t != "" {
+		return t
+	}
+	return http.DetectContentType(content)
+}
+
+// IsText reports whether the artifact holds text that can be printed or
+// diffed. Artifacts without a recorded MIME type are assumed to be text.
+func (a *Artifact) IsText() bool {
+	t, _, _ := strings.Cut(a.MIMEType, ";")
+	switch {
+	case t == "", strings.HasPrefix(t, "text/"), strings.HasSuffix(t, "+json"), strings.HasSuffix(t, "+xml"):
+		return true
+	}
+	switch t {
+	case "application/json", "application/xml", "application/yaml", "application/x-yaml", "application/javascript":
+		return true
+	}
+	return false
+}
+
+// blobPath returns where content with the given SHA-256 is stored.
+func (s *SQLiteStore) blobPath(blob string) (string, error) {
+	if len(blob) != sha256.Size*2 {
+		return "", fmt.Errorf("invalid blob name: %q", blob)
+	}
+	if _, err := hex.DecodeString(blob); err != nil {
+		return "", fmt.Errorf("invalid blob name: %q", blob)
+	}
+	return filepath.Join(s.artifactDir, "blobs", blob[:2], blob), nil
+}
+
+// writeBlob stores content under its SHA-256 unless it is already present,
+// and returns the hash.
+func (s *SQLiteStore) writeBlob(content []byte) (string, error) {
+	blob := sha256Hex(content)
+	path, err := s.blobPath(blob)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return blob, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return "", fmt.Errorf("failed to create artifact dir: %w", err)
+	}
+
+	// Write through a temp file so a concurrent reader never sees a partial blob
+	tmp, err := os.CreateTemp(filepath.Dir(path), blob+".tmp-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to write artifact content: %w", err)
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write artifact content: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write artifact content: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write artifact content: %w", err)
+	}
+	return blob, nil
+}
+
+// verifyBlob checks content read from the blob store against the SHA-256
+// naming its blob. Legacy artifacts, which have no blob, are checked
+// against their digest, the SHA-256 the proxy recorded, when they have one;
+// recorded verify and hook outputs digest the output without the "$ command"
+// line they start with.
+func verifyBlob(artifact *Artifact, content []byte) error {
+	want := artifact.Blob
+	if want == "" {
+		want = artifact.Digest
+	}
+	if want == "" {
+		return nil
+	}
+	got := sha256Hex(content)
+	if got == want {
+		return nil
+	}
+	if artifact.Blob == "" && strings.HasPrefix(string(content), "$ ") {
+		if _, output, ok := strings.Cut(string(content), "\n"); ok && sha256Hex([]byte(output)) == want {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s (%s) has SHA-256 %s, expected %s", ErrArtifactCorrupt, artifact.ID, artifact.Path, got, want)
+}
+
+func sha256Hex(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *SQLiteStore) readBlob(blob string) ([]byte, error) {
+	path, err := s.blobPath(blob)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+// SearchArtifacts scans the content of text artifacts for lines matching the
+// search. Content shared by several artifacts is read and scanned once. As in
+// ListSessions, time filtering happens in Go because timestamps are stored as
+// driver-formatted text.
+func (s *SQLiteStore) SearchArtifacts(search ArtifactSearch) ([]*ArtifactMatch, error) {
+	if search.Pattern == nil {
+		return nil, errors.New("artifact search needs a pattern")
+	}
+	query := `SELECT ` + artifactColumns + ` FROM artifacts`
+	var args []interface{}
+	if search.SessionID != "" {
+		query += ` WHERE session_id = ?`
+		args = append(args, search.SessionID)
+	}
+	query += ` ORDER BY created_at, id`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	var artifacts []*Artifact
+	for rows.Next() {
+		a, err := scanArtifact(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if !search.Since.IsZero() && a.CreatedAt.Before(search.Since) {
+			continue
+		}
+		if !search.Until.IsZero() && !a.CreatedAt.Before(search.Until) {
+			continue
+		}
+		artifacts = append(artifacts, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	type lineMatch struct {
+		line int
+		text string
+	}
+	scanned := make(map[string][]lineMatch) // By blob
+	var matches []*ArtifactMatch
+	for _, a := range artifacts {
+		if !a.IsText() {
+			continue
+		}
+		found, ok := scanned[a.Blob]
+		if !ok || a.Blob == "" {
+			content, err := s.readArtifact(a)
+			if err != nil {
+				// A missing blob must not hide matches in the others
+				continue
+			}
+			if !a.IsText() {
+				continue
+			}
+			found = nil
+			for i, line := range strings.Split(string(content), "\n") {
+				if search.Pattern.MatchString(line) {
+					found = append(found, lineMatch{line: i + 1, text: strings.TrimRight(line, "\r")})
+				}
+			}
+			if a.Blob != "" {
+				scanned[a.Blob] = found
+			}
+		}
+		for _, m := range found {
+			matches = append(matches, &ArtifactMatch{Artifact: a, Line: m.line, Text: m.text})
+			if search.Limit > 0 && len(matches) >= search.Limit {
+				return matches, nil
+			}
+		}
+	}
+	return matches, nil
+}
@@ -0,0 +1,47 @@
+package store
+
+// MemoryRecord is a stored memory with its embedding, as moved by backups.
+type MemoryRecord struct {
+	ID       int64             `json:"-"` // Not portable across databases
+	Content  string            `json:"content"`
+	Vector   []float32         `json:"vector"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// ListMemories returns every stored memory in insertion order.
+func (s *SQLiteStore) ListMemories() ([]MemoryRecord, error) {
+	rows, err := s.db.Query(`SELECT id, content, vector, metadata FROM memories ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []MemoryRecord
+	for rows.Next() {
+		entry, err := scanMemory(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, MemoryRecord{ID: entry.id, Content: entry.content, Vector: entry.vector, Metadata: entry.metadata})
+	}
+	return records, rows.Err()
+}
+
+// ListConfig returns every configuration entry, with values as stored.
+func (s *SQLiteStore) ListConfig() (map[string]string, error) {
+	rows, err := s.db.Query(`SELECT key, value FROM configuration`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	config := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		config[key] = value
+	}
+	return config, rows.Err()
+}
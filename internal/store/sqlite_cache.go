@@ -0,0 +1,52 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// ResponseCacheStats summarizes the provider response cache.
+type ResponseCacheStats struct {
+	Entries int
+	Hits    int // Lookups answered from the cache, across all entries
+}
+
+// GetCachedResponse returns the response stored under key and counts the hit.
+func (s *SQLiteStore) GetCachedResponse(key string) ([]byte, bool, error) {
+	var data []byte
+	err := s.db.QueryRow(`SELECT response FROM response_cache WHERE key = ?`, key).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if _, err := s.db.Exec(`UPDATE response_cache SET hits = hits + 1 WHERE key = ?`, key); err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// PutCachedResponse stores a response under key, replacing any previous entry.
+func (s *SQLiteStore) PutCachedResponse(key string, response []byte) error {
+	_, err := s.db.Exec(`INSERT INTO response_cache (key, response, created_at) VALUES (?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET response = excluded.response, created_at = excluded.created_at`,
+		key, response, time.Now())
+	return err
+}
+
+// ResponseCacheStats reports the number of cached responses and total hits.
+func (s *SQLiteStore) ResponseCacheStats() (ResponseCacheStats, error) {
+	var stats ResponseCacheStats
+	err := s.db.QueryRow(`SELECT COUNT(*), COALESCE(SUM(hits), 0) FROM response_cache`).Scan(&stats.Entries, &stats.Hits)
+	return stats, err
+}
+
+// ClearResponseCache deletes every cached response and returns how many were removed.
+func (s *SQLiteStore) ClearResponseCache() (int64, error) {
+	res, err := s.db.Exec(`DELETE FROM response_cache`)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
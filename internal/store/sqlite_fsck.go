@@ -0,0 +1,177 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// orphanGrace is how old an unreferenced blob file must be to count as an
+// orphan; younger ones may belong to an artifact being saved.
+const orphanGrace = time.Minute
+
+// FsckOptions selects the repairs CheckArtifacts makes.
+type FsckOptions struct {
+	// MarkMissing marks artifacts whose content is missing or corrupt, so
+	// reads fail fast with ErrArtifactMissing, and removes corrupt blobs so
+	// that saving the same content again rewrites them. The files of
+	// corrupt legacy artifacts are left in place.
+	MarkMissing bool
+	// DeleteOrphans removes blob files no artifact refers to.
+	DeleteOrphans bool
+}
+
+// FsckReport is the outcome of CheckArtifacts.
+type FsckReport struct {
+	Checked  int         // Artifacts whose content was checked
+	Missing  []*Artifact // Artifacts whose content is gone
+	Corrupt  []*Artifact // Artifacts whose content doesn't match its SHA-256
+	Orphans  []string    // Blob files no artifact refers to, relative to the artifact directory
+	Marked   int         // Artifacts marked missing by this check
+	Restored int         // Artifacts marked missing earlier whose content is back and verified
+	Deleted  int         // Orphans and corrupt blobs removed
+}
+
+// Clean reports whether the check found no problem.
+func (r *FsckReport) Clean() bool {
+	return len(r.Missing) == 0 && len(r.Corrupt) == 0 && len(r.Orphans) == 0
+}
+
+// CheckArtifacts reads the content of every artifact and checks it against
+// its SHA-256, then looks for blob files no artifact refers to, repairing
+// what opts selects. Content shared by several artifacts is read once.
+// Artifacts already marked missing are checked again and unmarked when
+// their content is back.
+func (s *SQLiteStore) CheckArtifacts(opts FsckOptions) (*FsckReport, error) {
+	rows, err := s.db.Query(`SELECT ` + artifactColumns + ` FROM artifacts ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	var artifacts []*Artifact
+	for rows.Next() {
+		a, err := scanArtifact(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		artifacts = append(artifacts, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	report := &FsckReport{}
+	referenced := make(map[string]bool)
+	checked := make(map[string]error) // By blob
+	for _, a := range artifacts {
+		if a.Blob != "" {
+			referenced[a.Blob] = true
+		}
+		err, ok := checked[a.Blob]
+		if !ok || a.Blob == "" {
+			wasMissing := a.Missing
+			a.Missing = false
+			_, err = s.readArtifact(a)
+			a.Missing = wasMissing
+			if a.Blob != "" {
+				checked[a.Blob] = err
+			}
+		}
+		report.Checked++
+
+		switch {
+		case err == nil:
+			if a.Missing {
+				if err := s.setArtifactMissing(a.ID, false); err != nil {
+					return nil, err
+				}
+				a.Missing = false
+				report.Restored++
+			}
+			continue
+		case errors.Is(err, ErrArtifactMissing):
+			report.Missing = append(report.Missing, a)
+		case errors.Is(err, ErrArtifactCorrupt):
+			report.Corrupt = append(report.Corrupt, a)
+		default:
+			return nil, fmt.Errorf("failed to check %s: %w", a.ID, err)
+		}
+		if opts.MarkMissing && !a.Missing {
+			if err := s.setArtifactMissing(a.ID, true); err != nil {
+				return nil, err
+			}
+			a.Missing = true
+			report.Marked++
+		}
+	}
+
+	if opts.MarkMissing {
+		removed := make(map[string]bool)
+		for _, a := range report.Corrupt {
+			if a.Blob == "" || removed[a.Blob] {
+				continue
+			}
+			removed[a.Blob] = true
+			path, err := s.blobPath(a.Blob)
+			if err != nil {
+				return nil, err
+			}
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("failed to remove corrupt blob: %w", err)
+			}
+			report.Deleted++
+		}
+	}
+
+	if err := s.findOrphans(report, referenced, opts.DeleteOrphans); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// findOrphans adds the files of the blob store no artifact refers to, such
+// as blobs of deleted sessions and temp files of interrupted writes, to the
+// report, and removes them when remove is set.
+func (s *SQLiteStore) findOrphans(report *FsckReport, referenced map[string]bool, remove bool) error {
+	root := filepath.Join(s.artifactDir, "blobs")
+	cutoff := time.Now().Add(-orphanGrace)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, os.ErrNotExist) && path == root {
+			return filepath.SkipDir
+		}
+		if err != nil || d.IsDir() || referenced[d.Name()] {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			return err
+		}
+		rel, err := filepath.Rel(s.artifactDir, path)
+		if err != nil {
+			return err
+		}
+		report.Orphans = append(report.Orphans, rel)
+		if remove {
+			if err := os.Remove(path); err != nil {
+				return fmt.Errorf("failed to remove orphan %s: %w", rel, err)
+			}
+			report.Deleted++
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan blob store: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) setArtifactMissing(id string, missing bool) error {
+	if _, err := s.db.Exec(`UPDATE artifacts SET missing = ? WHERE id = ?`, missing, id); err != nil {
+		return fmt.Errorf("failed to mark artifact %s: %w", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package store
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// hybridKeywordWeight is the share of the hybrid score taken by FTS5 relevance.
+const hybridKeywordWeight = 0.5
+
+// hybridCandidates is how many candidates each side contributes per requested result.
+const hybridCandidates = 10
+
+var ftsTokenPattern = regexp.MustCompile(`[\pL\pN_]+`)
+
+// initMemoryFTS creates the FTS5 index over memories and backfills it
+// for databases that predate it.
+func initMemoryFTS(tx execer) error {
+	var exists int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'memories_fts'`).Scan(&exists); err != nil {
+		return err
+	}
+
+	queries := []string{
+		`CREATE VIRTUAL TABLE IF NOT EXISTS memories_fts USING fts5(content, content='memories', content_rowid='id');`,
+		`CREATE TRIGGER IF NOT EXISTS memories_fts_insert AFTER INSERT ON memories BEGIN
+			INSERT INTO memories_fts(rowid, content) VALUES (new.id, new.content);
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS memories_fts_delete AFTER DELETE ON memories BEGIN
+			INSERT INTO memories_fts(memories_fts, rowid, content) VALUES ('delete', old.id, old.content);
+		END;`,
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+
+	if exists == 0 {
+		if _, err := tx.Exec(`INSERT INTO memories_fts(memories_fts) VALUES ('rebuild')`); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetMemorySearchMode selects the ranking used by QueryMemory.
+func (s *SQLiteStore) SetMemorySearchMode(mode MemorySearchMode) error {
+	switch mode {
+	case MemorySearchVector, MemorySearchFTS, MemorySearchHybrid:
+		s.memoryMode = mode
+		return nil
+	}
+	return fmt.Errorf("unknown memory search mode %q (use vector, fts, or hybrid)", mode)
+}
+
+// QueryMemory searches memories using the configured mode. A query without
+// a vector, made when the provider cannot embed, falls back to keyword
+// search in every mode.
+func (s *SQLiteStore) QueryMemory(query MemoryQuery) ([]MemoryItem, error) {
+	if len(query.Vector) == 0 {
+		return s.searchMemoryFTS(query.Text, query.Limit, query.Namespace)
+	}
+	switch s.memoryMode {
+	case MemorySearchFTS:
+		return s.searchMemoryFTS(query.Text, query.Limit, query.Namespace)
+	case MemorySearchHybrid:
+		return s.searchMemoryHybrid(query)
+	default:
+		return s.searchMemoryVector(query.Vector, query.Limit, query.Namespace)
+	}
+}
+
+// ftsQuery turns free text into an FTS5 query that matches any of its words.
+// Quoting each token keeps user text from being parsed as FTS5 syntax.
+func ftsQuery(text string) string {
+	tokens := ftsTokenPattern.FindAllString(strings.ToLower(text), -1)
+	if len(tokens) == 0 {
+		return ""
+	}
+	quoted := make([]string, len(tokens))
+	for i, t := range tokens {
+		quoted[i] = `"` + t + `"`
+	}
+	return strings.Join(quoted, " OR ")
+}
+
+// ftsMatch is a memory matched by full-text search with its normalized relevance.
+type ftsMatch struct {
+	entry indexEntry
+	score float32
+}
+
+func (s *SQLiteStore) matchMemoryFTS(text string, limit int, namespace string) ([]ftsMatch, error) {
+	match := ftsQuery(text)
+	if match == "" || limit <= 0 {
+		return nil, nil
+	}
+
+	rows, err := s.db.Query(`SELECT m.id, m.content, m.vector, m.metadata, bm25(memories_fts)
+		FROM memories_fts JOIN memories m ON m.id = memories_fts.rowid
+		WHERE memories_fts MATCH ?
+		AND (? = '' OR json_extract(m.metadata, '$.`+MemoryNamespaceKey+`') = ?)
+		ORDER BY bm25(memories_fts) LIMIT ?`, match, namespace, namespace, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []ftsMatch
+	for rows.Next() {
+		var rank float64
+		entry, err := scanMemory(rows, &rank)
+		if err != nil {
+			continue
+		}
+		// bm25 is negative, lower is better; map it into (0, 1)
+		relevance := -rank
+		if relevance < 0 {
+			relevance = 0
+		}
+		matches = append(matches, ftsMatch{entry: entry, score: float32(relevance / (1 + relevance))})
+	}
+	return matches, rows.Err()
+}
+
+// searchMemoryFTS ranks memories by keyword relevance only.
+func (s *SQLiteStore) searchMemoryFTS(text string, limit int, namespace string) ([]MemoryItem, error) {
+	matches, err := s.matchMemoryFTS(text, limit, namespace)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]MemoryItem, 0, len(matches))
+	for _, m := range matches {
+		results = append(results, MemoryItem{Content: m.entry.content, Metadata: m.entry.metadata, Similarity: m.score})
+	}
+	return results, nil
+}
+
+// searchMemoryHybrid merges FTS5 and vector candidates and re-ranks them
+// by a weighted blend of keyword relevance and cosine similarity.
+func (s *SQLiteStore) searchMemoryHybrid(query MemoryQuery) ([]MemoryItem, error) {
+	if query.Limit <= 0 {
+		return nil, nil
+	}
+	pool := query.Limit * hybridCandidates
+
+	matches, err := s.matchMemoryFTS(query.Text, pool, query.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	type candidate struct {
+		entry   indexEntry
+		keyword float32
+	}
+	candidates := make(map[int64]*candidate)
+	for _, m := range matches {
+		candidates[m.entry.id] = &candidate{entry: m.entry, keyword: m.score}
+	}
+
+	if err := s.loadMemoryIndex(); err != nil {
+		return nil, err
+	}
+	for _, e := range s.memoryIndex.searchEntries(query.Vector, pool, query.Namespace) {
+		if _, ok := candidates[e.id]; !ok {
+			candidates[e.id] = &candidate{entry: e}
+		}
+	}
+
+	queryMag := magnitude(query.Vector)
+	results := make([]MemoryItem, 0, len(candidates))
+	for _, c := range candidates {
+		similarity := cosineSimilarityOptimized(query.Vector, c.entry.vector, queryMag)
+		score := hybridKeywordWeight*c.keyword + (1-hybridKeywordWeight)*similarity
+		results = append(results, MemoryItem{Content: c.entry.content, Metadata: c.entry.metadata, Similarity: score})
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Similarity > results[j].Similarity })
+	if len(results) > query.Limit {
+		results = results[:query.Limit]
+	}
+	return results, nil
+}
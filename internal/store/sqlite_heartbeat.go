@@ -0,0 +1,95 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// StatusOrphaned is the status of a session whose process stopped sending
+// heartbeats without finishing it.
+const StatusOrphaned = "orphaned"
+
+// liveStatuses are the statuses of a session whose loop has not finished.
+const liveStatuses = `('initialized', 'running')`
+
+// Heartbeat records that owner, the process running the session, is still
+// alive. Sessions that never send one, such as those of `simon run`, are
+// never considered stale.
+func (s *SQLiteStore) Heartbeat(sessionID, owner string) error {
+	res, err := s.db.Exec(`UPDATE sessions SET heartbeat_at = ?, owner = ? WHERE id = ?`, time.Now(), owner, sessionID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+	return nil
+}
+
+// StaleSessions returns the unfinished sessions whose last heartbeat is
+// older than before, oldest heartbeat first.
+// Heartbeats are compared in Go because timestamps are stored as
+// driver-formatted text.
+func (s *SQLiteStore) StaleSessions(before time.Time) ([]*Session, error) {
+	rows, err := s.db.Query(`SELECT id, heartbeat_at FROM sessions WHERE heartbeat_at IS NOT NULL AND status IN ` + liveStatuses)
+	if err != nil {
+		return nil, err
+	}
+	type beat struct {
+		id string
+		at time.Time
+	}
+	var stale []beat
+	for rows.Next() {
+		var b beat
+		if err := rows.Scan(&b.id, &b.at); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if b.at.Before(before) {
+			stale = append(stale, b)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(stale, func(a, b beat) int { return a.at.Compare(b.at) })
+	sessions := make([]*Session, 0, len(stale))
+	for _, b := range stale {
+		session, err := s.GetSession(b.id)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+// OrphanSession marks a session StatusOrphaned and clears its owner, if it
+// is still unfinished and its last heartbeat is still older than before. It
+// reports whether the session was orphaned, so a session whose process
+// sent a heartbeat since StaleSessions listed it is left alone.
+func (s *SQLiteStore) OrphanSession(id string, before time.Time) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var beat sql.NullTime
+	err = tx.QueryRow(`SELECT heartbeat_at FROM sessions WHERE id = ? AND status IN `+liveStatuses, id).Scan(&beat)
+	if err == sql.ErrNoRows || (err == nil && (!beat.Valid || !beat.Time.Before(before))) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`UPDATE sessions SET status = ?, owner = '', updated_at = ? WHERE id = ?`, StatusOrphaned, time.Now(), id); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
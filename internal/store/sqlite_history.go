@@ -0,0 +1,170 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/felixgeelhaar/simon/internal/credential"
+)
+
+// MessageCipher encrypts conversation history at rest with a key per
+// session; credential.HistoryCipher implements it.
+type MessageCipher interface {
+	Encrypt(sessionID, plaintext string) (string, error)
+	Decrypt(sessionID, stored string) (string, error)
+}
+
+// ErrHistoryEncrypted is returned when encrypted history is read from a
+// store without a MessageCipher.
+var ErrHistoryEncrypted = errors.New("conversation history is encrypted and no key is configured")
+
+// SetMessageCipher installs c to decrypt encrypted messages and snapshots
+// as they are read and, with encrypt set, to encrypt their content as it is
+// written. History written without encryption stays readable either way.
+func (s *SQLiteStore) SetMessageCipher(c MessageCipher, encrypt bool) {
+	s.cipher = c
+	s.encryptHistory = encrypt && c != nil
+}
+
+// sealHistory encrypts the non-empty values of a session's history when
+// encryption is on.
+func (s *SQLiteStore) sealHistory(sessionID string, values ...*string) error {
+	if !s.encryptHistory {
+		return nil
+	}
+	for _, v := range values {
+		if *v == "" || credential.IsEncrypted(*v) {
+			continue
+		}
+		encrypted, err := s.cipher.Encrypt(sessionID, *v)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt history: %w", err)
+		}
+		*v = encrypted
+	}
+	return nil
+}
+
+// openHistory decrypts the encrypted values of a session's history.
+func (s *SQLiteStore) openHistory(sessionID string, values ...*string) error {
+	for _, v := range values {
+		if !credential.IsEncrypted(*v) {
+			continue
+		}
+		if s.cipher == nil {
+			return ErrHistoryEncrypted
+		}
+		decrypted, err := s.cipher.Decrypt(sessionID, *v)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt history of session %s: %w", sessionID, err)
+		}
+		*v = decrypted
+	}
+	return nil
+}
+
+// ReencryptHistory moves every encrypted message and snapshot from current
+// to next, then applies the config updates, in one transaction: nothing
+// is written unless everything decrypts. It returns the number of rows
+// re-encrypted. Key rotation uses it so history follows the credential key.
+func (s *SQLiteStore) ReencryptHistory(current, next MessageCipher, config map[string]string) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	prefix := credential.EncryptedPrefix + "%"
+	n := 0
+	rotate := func(sessionID string, values ...*string) error {
+		for _, v := range values {
+			if !credential.IsEncrypted(*v) {
+				continue
+			}
+			plaintext, err := current.Decrypt(sessionID, *v)
+			if err != nil {
+				return fmt.Errorf("failed to decrypt history of session %s with the current key: %w", sessionID, err)
+			}
+			if *v, err = next.Encrypt(sessionID, plaintext); err != nil {
+				return err
+			}
+		}
+		n++
+		return nil
+	}
+
+	type message struct {
+		sessionID          string
+		seq                int
+		content, toolCalls string
+	}
+	var messages []message
+	err = eachRow(tx, func(rows *sql.Rows) error {
+		var m message
+		if err := rows.Scan(&m.sessionID, &m.seq, &m.content, &m.toolCalls); err != nil {
+			return err
+		}
+		messages = append(messages, m)
+		return nil
+	}, `SELECT session_id, seq, content, tool_calls FROM messages WHERE content LIKE ? OR tool_calls LIKE ?`, prefix, prefix)
+	if err != nil {
+		return 0, err
+	}
+	for _, m := range messages {
+		if err := rotate(m.sessionID, &m.content, &m.toolCalls); err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(`UPDATE messages SET content = ?, tool_calls = ? WHERE session_id = ? AND seq = ?`, m.content, m.toolCalls, m.sessionID, m.seq); err != nil {
+			return 0, err
+		}
+	}
+
+	type snapshot struct {
+		sessionID          string
+		iteration          int
+		messages, response string
+	}
+	var snaps []snapshot
+	err = eachRow(tx, func(rows *sql.Rows) error {
+		var snap snapshot
+		if err := rows.Scan(&snap.sessionID, &snap.iteration, &snap.messages, &snap.response); err != nil {
+			return err
+		}
+		snaps = append(snaps, snap)
+		return nil
+	}, `SELECT session_id, iteration, messages, response FROM snapshots WHERE messages LIKE ? OR response LIKE ?`, prefix, prefix)
+	if err != nil {
+		return 0, err
+	}
+	for _, snap := range snaps {
+		if err := rotate(snap.sessionID, &snap.messages, &snap.response); err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(`UPDATE snapshots SET messages = ?, response = ? WHERE session_id = ? AND iteration = ?`, snap.messages, snap.response, snap.sessionID, snap.iteration); err != nil {
+			return 0, err
+		}
+	}
+
+	for key, value := range config {
+		if _, err := tx.Exec(`INSERT INTO configuration (key, value) VALUES (?, ?) ON CONFLICT(key) DO UPDATE SET value = excluded.value`, key, value); err != nil {
+			return 0, err
+		}
+	}
+	return n, tx.Commit()
+}
+
+// eachRow runs query in tx and calls fn for every row.
+func eachRow(tx *sql.Tx, fn func(*sql.Rows) error, query string, args ...any) error {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
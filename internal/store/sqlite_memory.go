@@ -15,7 +15,7 @@ import (
 type vectorIndex struct {
 	mu      sync.RWMutex
 	entries []indexEntry
-	dirty   bool
+	loaded  bool // Set once all rows have been read from the database
 }
 
 type indexEntry struct {
@@ -54,7 +54,6 @@ func (h *minHeap) Pop() interface{} {
 func newVectorIndex() *vectorIndex {
 	return &vectorIndex{
 		entries: make([]indexEntry, 0),
-		dirty:   true,
 	}
 }
 
@@ -72,7 +71,31 @@ func (idx *vectorIndex) add(id int64, content string, vector []float32, metadata
 
 // search performs a top-k similarity search using a min-heap
 // This is O(n * log(k)) which is more efficient than O(n * log(n)) for k << n
-func (idx *vectorIndex) search(queryVector []float32, limit int) []MemoryItem {
+func (idx *vectorIndex) search(queryVector []float32, limit int, namespace string) []MemoryItem {
+	scored := idx.topK(queryVector, limit, namespace)
+	results := make([]MemoryItem, len(scored))
+	for i, se := range scored {
+		results[i] = MemoryItem{
+			Content:    se.entry.content,
+			Metadata:   se.entry.metadata,
+			Similarity: se.score,
+		}
+	}
+	return results
+}
+
+// searchEntries returns the top-k entries themselves, for callers that re-rank.
+func (idx *vectorIndex) searchEntries(queryVector []float32, limit int, namespace string) []indexEntry {
+	scored := idx.topK(queryVector, limit, namespace)
+	entries := make([]indexEntry, len(scored))
+	for i, se := range scored {
+		entries[i] = se.entry
+	}
+	return entries
+}
+
+// topK returns the highest-scoring entries visible in namespace in descending order.
+func (idx *vectorIndex) topK(queryVector []float32, limit int, namespace string) []scoredEntry {
 	idx.mu.RLock()
 	defer idx.mu.RUnlock()
 
@@ -91,6 +114,10 @@ func (idx *vectorIndex) search(queryVector []float32, limit int) []MemoryItem {
 	heap.Init(h)
 
 	for _, entry := range idx.entries {
+		// Memories without a vector have nothing to be similar by
+		if len(entry.vector) == 0 || !inNamespace(entry.metadata, namespace) {
+			continue
+		}
 		score := cosineSimilarityOptimized(queryVector, entry.vector, queryMag)
 
 		if h.Len() < limit {
@@ -103,16 +130,10 @@ func (idx *vectorIndex) search(queryVector []float32, limit int) []MemoryItem {
 	}
 
 	// Extract results in descending order
-	results := make([]MemoryItem, h.Len())
+	results := make([]scoredEntry, h.Len())
 	for i := h.Len() - 1; i >= 0; i-- {
-		se := heap.Pop(h).(scoredEntry)
-		results[i] = MemoryItem{
-			Content:    se.entry.content,
-			Metadata:   se.entry.metadata,
-			Similarity: se.score,
-		}
+		results[i] = heap.Pop(h).(scoredEntry)
 	}
-
 	return results
 }
 
@@ -121,7 +142,7 @@ func (idx *vectorIndex) clear() {
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 	idx.entries = make([]indexEntry, 0)
-	idx.dirty = true
+	idx.loaded = false
 }
 
 // size returns the number of entries in the index
@@ -144,111 +165,161 @@ func (s *SQLiteStore) AddMemory(content string, vector []float32, meta map[strin
 		return fmt.Errorf("failed to marshal metadata: %w", err)
 	}
 
+	// The memories_fts index is kept in sync by a trigger
 	query := `INSERT INTO memories (content, vector, metadata) VALUES (?, ?, ?)`
 	result, err := s.db.Exec(query, content, vecBuf.Bytes(), string(metaJSON))
 	if err != nil {
 		return err
 	}
 
-	// Add to in-memory index for fast search
-	if s.memoryIndex == nil {
-		s.memoryIndex = newVectorIndex()
+	// Only extend the in-memory index once it holds every row; otherwise
+	// the next search loads it from the database anyway.
+	s.memoryIndex.mu.Lock()
+	defer s.memoryIndex.mu.Unlock()
+	if s.memoryIndex.loaded {
+		id, _ := result.LastInsertId()
+		s.memoryIndex.entries = append(s.memoryIndex.entries, indexEntry{
+			id:       id,
+			content:  content,
+			vector:   vector,
+			metadata: meta,
+		})
 	}
-	id, _ := result.LastInsertId()
-	s.memoryIndex.add(id, content, vector, meta)
 
 	return nil
 }
 
-func (s *SQLiteStore) SearchMemory(queryVector []float32, limit int) ([]MemoryItem, error) {
-	// Try to use the in-memory index first
-	if s.memoryIndex != nil && s.memoryIndex.size() > 0 {
-		return s.memoryIndex.search(queryVector, limit), nil
-	}
-
-	// Fall back to database scan if index not available
-	// This also rebuilds the index for future queries
-	return s.searchMemoryFromDB(queryVector, limit)
-}
-
-// searchMemoryFromDB performs a database scan and rebuilds the in-memory index
-func (s *SQLiteStore) searchMemoryFromDB(queryVector []float32, limit int) ([]MemoryItem, error) {
-	rows, err := s.db.Query(`SELECT id, content, vector, metadata FROM memories`)
+// SessionMemories returns the memories a session archived, oldest first,
+// whatever their namespace.
+func (s *SQLiteStore) SessionMemories(sessionID string) ([]MemoryItem, error) {
+	rows, err := s.db.Query(`SELECT id, content, vector, metadata FROM memories WHERE json_extract(metadata, '$.session_id') = ? ORDER BY id`, sessionID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	// Initialize or reset the index
-	if s.memoryIndex == nil {
-		s.memoryIndex = newVectorIndex()
-	} else {
-		s.memoryIndex.clear()
+	var items []MemoryItem
+	for rows.Next() {
+		entry, err := scanMemory(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, MemoryItem{Content: entry.content, Metadata: entry.metadata})
 	}
+	return items, rows.Err()
+}
 
-	// Pre-compute query magnitude
-	queryMag := magnitude(queryVector)
-	if queryMag == 0 {
-		return nil, nil
+// MoveMemories moves the memories recorded under namespace from, or without
+// a namespace when from is empty, to namespace to, and returns how many were
+// moved. It assigns memories archived before namespacing, which restricted
+// searches no longer see, to their project.
+func (s *SQLiteStore) MoveMemories(from, to string) (int, error) {
+	result, err := s.db.Exec(`UPDATE memories
+		SET metadata = json_set(CASE WHEN json_type(metadata) = 'object' THEN metadata ELSE '{}' END, '$.`+MemoryNamespaceKey+`', ?)
+		WHERE COALESCE(json_extract(metadata, '$.`+MemoryNamespaceKey+`'), '') = ?`, to, from)
+	if err != nil {
+		return 0, err
 	}
+	n, _ := result.RowsAffected()
+
+	// Reload the in-memory index on the next search
+	s.memoryIndex.mu.Lock()
+	defer s.memoryIndex.mu.Unlock()
+	s.memoryIndex.entries = make([]indexEntry, 0)
+	s.memoryIndex.loaded = false
+	return int(n), nil
+}
 
-	// Use a min-heap for efficient top-k selection
-	h := &minHeap{}
-	heap.Init(h)
-
-	for rows.Next() {
-		var id int64
-		var content string
-		var vecBlob []byte
-		var metaJSON string
-
-		if err := rows.Scan(&id, &content, &vecBlob, &metaJSON); err != nil {
-			continue
+// UpdateMemoryVectors replaces the embeddings of the memories with the given
+// IDs in one transaction, e.g. after switching embedding models.
+func (s *SQLiteStore) UpdateMemoryVectors(vectors map[int64][]float32) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for id, vector := range vectors {
+		vecBuf := new(bytes.Buffer)
+		if err := binary.Write(vecBuf, binary.LittleEndian, vector); err != nil {
+			return fmt.Errorf("failed to encode vector: %w", err)
 		}
-
-		// Decode vector
-		vector := make([]float32, len(vecBlob)/4)
-		if err := binary.Read(bytes.NewReader(vecBlob), binary.LittleEndian, &vector); err != nil {
-			continue
+		if _, err := tx.Exec(`UPDATE memories SET vector = ? WHERE id = ?`, vecBuf.Bytes(), id); err != nil {
+			return err
 		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
 
-		// Decode meta
-		var meta map[string]string
-		json.Unmarshal([]byte(metaJSON), &meta)
+	// Reload the in-memory index on the next search
+	s.memoryIndex.mu.Lock()
+	defer s.memoryIndex.mu.Unlock()
+	s.memoryIndex.entries = make([]indexEntry, 0)
+	s.memoryIndex.loaded = false
+	return nil
+}
 
-		// Add to index for future queries
-		s.memoryIndex.add(id, content, vector, meta)
+// SearchMemory ranks memories of every namespace by cosine similarity
+// against the in-memory index.
+func (s *SQLiteStore) SearchMemory(queryVector []float32, limit int) ([]MemoryItem, error) {
+	return s.searchMemoryVector(queryVector, limit, "")
+}
 
-		// Calculate similarity
-		score := cosineSimilarityOptimized(queryVector, vector, queryMag)
+func (s *SQLiteStore) searchMemoryVector(queryVector []float32, limit int, namespace string) ([]MemoryItem, error) {
+	if err := s.loadMemoryIndex(); err != nil {
+		return nil, err
+	}
+	return s.memoryIndex.search(queryVector, limit, namespace), nil
+}
 
-		// Maintain top-k using min-heap
-		if h.Len() < limit {
-			heap.Push(h, scoredEntry{
-				entry: indexEntry{id: id, content: content, vector: vector, metadata: meta},
-				score: score,
-			})
-		} else if score > (*h)[0].score {
-			heap.Pop(h)
-			heap.Push(h, scoredEntry{
-				entry: indexEntry{id: id, content: content, vector: vector, metadata: meta},
-				score: score,
-			})
-		}
+// loadMemoryIndex reads all memories into the in-memory index on first use,
+// so opening the store stays cheap.
+func (s *SQLiteStore) loadMemoryIndex() error {
+	s.memoryIndex.mu.Lock()
+	defer s.memoryIndex.mu.Unlock()
+	if s.memoryIndex.loaded {
+		return nil
 	}
 
-	// Extract results in descending order
-	results := make([]MemoryItem, h.Len())
-	for i := h.Len() - 1; i >= 0; i-- {
-		se := heap.Pop(h).(scoredEntry)
-		results[i] = MemoryItem{
-			Content:    se.entry.content,
-			Metadata:   se.entry.metadata,
-			Similarity: se.score,
+	rows, err := s.db.Query(`SELECT id, content, vector, metadata FROM memories`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	entries := make([]indexEntry, 0)
+	for rows.Next() {
+		entry, err := scanMemory(rows)
+		if err != nil {
+			continue
 		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	s.memoryIndex.entries = entries
+	s.memoryIndex.loaded = true
+	return nil
+}
+
+// scanMemory decodes a memories row (id, content, vector, metadata).
+func scanMemory(row rowScanner, extra ...interface{}) (indexEntry, error) {
+	var entry indexEntry
+	var vecBlob []byte
+	var metaJSON string
+	dest := append([]interface{}{&entry.id, &entry.content, &vecBlob, &metaJSON}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return entry, err
 	}
 
-	return results, nil
+	entry.vector = make([]float32, len(vecBlob)/4)
+	if err := binary.Read(bytes.NewReader(vecBlob), binary.LittleEndian, &entry.vector); err != nil {
+		return entry, err
+	}
+	_ = json.Unmarshal([]byte(metaJSON), &entry.metadata)
+	return entry, nil
 }
 
 // magnitude calculates the magnitude of a vector
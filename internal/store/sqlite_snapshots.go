@@ -0,0 +1,65 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SaveSnapshot stores the state of a session at an iteration. Saving the
+// same iteration again replaces it. A snapshot with BaseMessages set is a
+// delta: its messages follow that many of the previous iteration's. Messages and Response are encrypted
+// like the rest of the history (see SetMessageCipher).
+func (s *SQLiteStore) SaveSnapshot(snap *Snapshot) error {
+	if snap.CreatedAt.IsZero() {
+		snap.CreatedAt = time.Now()
+	}
+	messages, response := snap.Messages, snap.Response
+	if err := s.sealHistory(snap.SessionID, &messages, &response); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO snapshots (session_id, iteration, status, prompt_tokens, output_tokens, context_tokens, messages, base_messages, response, error, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		snap.SessionID, snap.Iteration, snap.Status, snap.PromptTokens, snap.OutputTokens, snap.ContextTokens, messages, snap.BaseMessages, response, snap.Error, snap.CreatedAt)
+	return err
+}
+
+// GetSnapshot returns the state of a session at an iteration as stored;
+// runtime.LoadSnapshot resolves deltas into the full context.
+func (s *SQLiteStore) GetSnapshot(sessionID string, iteration int) (*Snapshot, error) {
+	snap := &Snapshot{SessionID: sessionID, Iteration: iteration}
+	err := s.db.QueryRow(`SELECT status, prompt_tokens, output_tokens, context_tokens, messages, base_messages, response, error, created_at
+		FROM snapshots WHERE session_id = ? AND iteration = ?`, sessionID, iteration).
+		Scan(&snap.Status, &snap.PromptTokens, &snap.OutputTokens, &snap.ContextTokens, &snap.Messages, &snap.BaseMessages, &snap.Response, &snap.Error, &snap.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no snapshot of iteration %d in session %s", iteration, sessionID)
+		}
+		return nil, err
+	}
+	if err := s.openHistory(sessionID, &snap.Messages, &snap.Response); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// ListSnapshots returns a session's snapshots in iteration order. Messages
+// and Response are left empty; load one with GetSnapshot.
+func (s *SQLiteStore) ListSnapshots(sessionID string) ([]*Snapshot, error) {
+	rows, err := s.db.Query(`SELECT iteration, status, prompt_tokens, output_tokens, context_tokens, base_messages, error, created_at
+		FROM snapshots WHERE session_id = ? ORDER BY iteration`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snaps []*Snapshot
+	for rows.Next() {
+		snap := &Snapshot{SessionID: sessionID}
+		if err := rows.Scan(&snap.Iteration, &snap.Status, &snap.PromptTokens, &snap.OutputTokens, &snap.ContextTokens, &snap.BaseMessages, &snap.Error, &snap.CreatedAt); err != nil {
+			return nil, err
+		}
+		snaps = append(snaps, snap)
+	}
+	return snaps, rows.Err()
+}
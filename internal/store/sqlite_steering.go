@@ -0,0 +1,60 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// SteerSession queues a steering request for a session's running loop,
+// which takes it before its next provider call (see TakeSteering).
+func (s *SQLiteStore) SteerSession(req *SteeringRequest) error {
+	if _, err := s.GetSession(req.SessionID); err != nil {
+		return err
+	}
+	if req.CreatedAt.IsZero() {
+		req.CreatedAt = time.Now()
+	}
+	res, err := s.db.Exec(`INSERT INTO steering (session_id, message, provider, model, created_at) VALUES (?, ?, ?, ?, ?)`,
+		req.SessionID, req.Message, req.Provider, req.Model, req.CreatedAt)
+	if err != nil {
+		return err
+	}
+	req.ID, err = res.LastInsertId()
+	return err
+}
+
+// TakeSteering returns a session's queued steering requests, oldest first,
+// and removes them from the queue.
+func (s *SQLiteStore) TakeSteering(sessionID string) ([]*SteeringRequest, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id, session_id, message, provider, model, created_at FROM steering WHERE session_id = ? ORDER BY id`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	var reqs []*SteeringRequest
+	for rows.Next() {
+		req := &SteeringRequest{}
+		if err := rows.Scan(&req.ID, &req.SessionID, &req.Message, &req.Provider, &req.Model, &req.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		reqs = append(reqs, req)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(reqs) == 0 {
+		return nil, nil
+	}
+
+	if _, err := tx.Exec(`DELETE FROM steering WHERE session_id = ? AND id <= ?`, sessionID, reqs[len(reqs)-1].ID); err != nil {
+		return nil, fmt.Errorf("failed to dequeue steering: %w", err)
+	}
+	return reqs, tx.Commit()
+}
@@ -0,0 +1,49 @@
+package store
+
+import "time"
+
+// RecordViolation stores a guard violation reported during a session.
+func (s *SQLiteStore) RecordViolation(v *ViolationRecord) error {
+	if v.CreatedAt.IsZero() {
+		v.CreatedAt = time.Now()
+	}
+	res, err := s.db.Exec(`INSERT INTO violations (session_id, rule, severity, subject, message, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		v.SessionID, v.Rule, v.Severity, v.Subject, v.Message, v.CreatedAt)
+	if err != nil {
+		return err
+	}
+	v.ID, err = res.LastInsertId()
+	return err
+}
+
+// ListViolations returns violations matching the filter, oldest first. As in
+// ListSessions, time filtering happens in Go because timestamps are stored as
+// driver-formatted text.
+func (s *SQLiteStore) ListViolations(filter ViolationFilter) ([]*ViolationRecord, error) {
+	query := `SELECT id, session_id, rule, severity, subject, message, created_at FROM violations`
+	var args []interface{}
+	if filter.SessionID != "" {
+		query += ` WHERE session_id = ?`
+		args = append(args, filter.SessionID)
+	}
+	query += ` ORDER BY id`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var violations []*ViolationRecord
+	for rows.Next() {
+		v := &ViolationRecord{}
+		if err := rows.Scan(&v.ID, &v.SessionID, &v.Rule, &v.Severity, &v.Subject, &v.Message, &v.CreatedAt); err != nil {
+			return nil, err
+		}
+		if !filter.Since.IsZero() && v.CreatedAt.Before(filter.Since) {
+			continue
+		}
+		violations = append(violations, v)
+	}
+	return violations, rows.Err()
+}
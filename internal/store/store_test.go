@@ -1,10 +1,18 @@
 package store
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/felixgeelhaar/simon/internal/credential"
 )
 
 func TestSQLiteStore(t *testing.T) {
@@ -98,6 +106,31 @@ func TestSQLiteStore(t *testing.T) {
 		}
 	})
 
+	t.Run("Artifact Search", func(t *testing.T) {
+		s.CreateSession(&Session{ID: "s-search", CreatedAt: time.Now()})
+		old := time.Now().Add(-48 * time.Hour)
+		s.SaveArtifact(&Artifact{ID: "as1", SessionID: "s-search", Path: "run.txt", CreatedAt: old}, []byte("ok\nError: connection refused\n"))
+		s.SaveArtifact(&Artifact{ID: "as2", SessionID: "s-search", Path: "again.txt", CreatedAt: time.Now()}, []byte("ok\nError: connection refused\n"))
+		s.SaveArtifact(&Artifact{ID: "as3", SessionID: "s-search", Path: "shot.png", CreatedAt: time.Now()}, []byte("\x89PNG\r\n\x1a\nconnection refused"))
+
+		matches, err := s.SearchArtifacts(ArtifactSearch{Pattern: regexp.MustCompile("connection refused"), SessionID: "s-search"})
+		if err != nil {
+			t.Fatalf("SearchArtifacts failed: %v", err)
+		}
+		if len(matches) != 2 || matches[0].Artifact.ID != "as1" || matches[0].Line != 2 || matches[0].Text != "Error: connection refused" {
+			t.Errorf("Expected a match in both text artifacts, oldest first, got %+v", matches)
+		}
+
+		matches, _ = s.SearchArtifacts(ArtifactSearch{Pattern: regexp.MustCompile("refused"), SessionID: "s-search", Since: time.Now().Add(-time.Hour)})
+		if len(matches) != 1 || matches[0].Artifact.ID != "as2" {
+			t.Errorf("Expected only the recent artifact, got %+v", matches)
+		}
+		matches, _ = s.SearchArtifacts(ArtifactSearch{Pattern: regexp.MustCompile("ok|refused"), SessionID: "s-search", Limit: 3})
+		if len(matches) != 3 {
+			t.Errorf("Expected the limit to apply, got %d matches", len(matches))
+		}
+	})
+
 	t.Run("Config", func(t *testing.T) {
 		if err := s.SetConfig("k1", "v1"); err != nil {
 			t.Fatalf("SetConfig failed: %v", err)
@@ -115,5 +148,644 @@ func TestSQLiteStore(t *testing.T) {
 		if val2 != "" {
 			t.Errorf("Expected empty string for unknown config, got '%s'", val2)
 		}
+
+		s.SetConfigDefaults(map[string]string{"k1": "default", "unknown": "default"})
+		defer s.SetConfigDefaults(nil)
+		if val, _ := s.GetConfig("k1"); val != "v1" {
+			t.Errorf("Expected the stored value to win over the default, got %q", val)
+		}
+		if val, _ := s.GetConfig("unknown"); val != "default" {
+			t.Errorf("Expected the default for an unset key, got %q", val)
+		}
+	})
+
+	t.Run("Violations", func(t *testing.T) {
+		old := &ViolationRecord{SessionID: "s1", Rule: "allowed_commands", Severity: "block", Subject: "curl", Message: "Command not allowed: curl", CreatedAt: time.Now().Add(-48 * time.Hour)}
+		if err := s.RecordViolation(old); err != nil {
+			t.Fatalf("RecordViolation failed: %v", err)
+		}
+		if err := s.RecordViolation(&ViolationRecord{SessionID: "s1", Rule: "max_cost", Severity: "halt", Message: "Cost budget exceeded"}); err != nil {
+			t.Fatalf("RecordViolation failed: %v", err)
+		}
+		if old.ID == 0 {
+			t.Error("Expected the record ID to be set")
+		}
+
+		all, err := s.ListViolations(ViolationFilter{SessionID: "s1"})
+		if err != nil {
+			t.Fatalf("ListViolations failed: %v", err)
+		}
+		if len(all) != 2 || all[0].Subject != "curl" || all[1].Rule != "max_cost" {
+			t.Errorf("Unexpected violations: %+v", all)
+		}
+
+		recent, _ := s.ListViolations(ViolationFilter{Since: time.Now().Add(-time.Hour)})
+		if len(recent) != 1 || recent[0].Rule != "max_cost" {
+			t.Errorf("Expected only the recent violation, got %+v", recent)
+		}
 	})
-}
\ No newline at end of file
+
+	t.Run("Snapshots", func(t *testing.T) {
+		for i := 1; i <= 2; i++ {
+			snap := &Snapshot{SessionID: "s1", Iteration: i, Status: "active", PromptTokens: i * 100, Messages: `[{"role":"user","content":"go"}]`}
+			if err := s.SaveSnapshot(snap); err != nil {
+				t.Fatalf("SaveSnapshot failed: %v", err)
+			}
+		}
+		if err := s.SaveSnapshot(&Snapshot{SessionID: "s1", Iteration: 2, Status: "active", Messages: "[]", Error: "timeout"}); err != nil {
+			t.Fatalf("SaveSnapshot failed: %v", err)
+		}
+
+		snaps, err := s.ListSnapshots("s1")
+		if err != nil {
+			t.Fatalf("ListSnapshots failed: %v", err)
+		}
+		if len(snaps) != 2 || snaps[0].Iteration != 1 || snaps[1].Error != "timeout" || snaps[0].Messages != "" {
+			t.Errorf("Unexpected snapshots: %+v", snaps)
+		}
+
+		snap, err := s.GetSnapshot("s1", 1)
+		if err != nil {
+			t.Fatalf("GetSnapshot failed: %v", err)
+		}
+		if snap.PromptTokens != 100 || snap.Messages != `[{"role":"user","content":"go"}]` {
+			t.Errorf("Unexpected snapshot: %+v", snap)
+		}
+		if _, err := s.GetSnapshot("s1", 9); err == nil {
+			t.Error("Expected an error for a missing iteration")
+		}
+	})
+}
+func TestSessionFinished(t *testing.T) {
+	for status, want := range map[string][2]bool{
+		"completed":   {true, false},
+		"halted":      {true, false},
+		"cancelled":   {true, false},
+		"failed":      {true, true},
+		"interrupted": {true, true},
+		"orphaned":    {true, true},
+		"running":     {false, false},
+		"initialized": {false, false},
+	} {
+		if got := [2]bool{SessionFinished(status), SessionFailed(status)}; got != want {
+			t.Errorf("%s: expected finished, failed = %v, got %v", status, want, got)
+		}
+	}
+}
+
+func TestSQLiteStore_SessionUsage(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "store-test-*")
+	defer os.RemoveAll(tmpDir)
+
+	s, err := NewSQLiteStore(filepath.Join(tmpDir, "meta.db"), filepath.Join(tmpDir, "artifacts"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	old := &Session{ID: "old", CreatedAt: time.Now().Add(-48 * time.Hour), Metadata: map[string]string{}}
+	recent := &Session{ID: "recent", CreatedAt: time.Now(), Metadata: map[string]string{}, Provider: "openai", Model: "gpt-4o"}
+	s.CreateSession(old)
+	s.CreateSession(recent)
+
+	recent.PromptTokens = 1000
+	recent.CompletionTokens = 200
+	recent.Cost = 0.0045
+	if err := s.UpdateSession(recent); err != nil {
+		t.Fatalf("UpdateSession failed: %v", err)
+	}
+
+	got, _ := s.GetSession("recent")
+	if got.PromptTokens != 1000 || got.CompletionTokens != 200 || got.Model != "gpt-4o" {
+		t.Errorf("Usage not persisted: %+v", got)
+	}
+
+	all, err := s.ListSessions(SessionFilter{})
+	if err != nil {
+		t.Fatalf("ListSessions failed: %v", err)
+	}
+	if len(all) != 2 || all[0].ID != "recent" {
+		t.Errorf("Expected 2 sessions newest first, got %d", len(all))
+	}
+
+	since, _ := s.ListSessions(SessionFilter{Since: time.Now().Add(-24 * time.Hour)})
+	if len(since) != 1 {
+		t.Errorf("Expected 1 session in window, got %d", len(since))
+	}
+}
+
+func TestSQLiteStore_SessionTags(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "store-test-*")
+	defer os.RemoveAll(tmpDir)
+
+	s, err := NewSQLiteStore(filepath.Join(tmpDir, "meta.db"), filepath.Join(tmpDir, "artifacts"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	s.CreateSession(&Session{ID: "pay", CreatedAt: time.Now(), Metadata: map[string]string{},
+		Tags: map[string]string{"team": "payments", "ticket": "JIRA-123"}})
+	s.CreateSession(&Session{ID: "search", CreatedAt: time.Now(), Metadata: map[string]string{},
+		Tags: map[string]string{"team": "search"}})
+	s.CreateSession(&Session{ID: "untagged", CreatedAt: time.Now(), Metadata: map[string]string{}})
+
+	got, err := s.GetSession("pay")
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	if got.Tags["team"] != "payments" || got.Tags["ticket"] != "JIRA-123" {
+		t.Errorf("Tags not persisted: %v", got.Tags)
+	}
+
+	tagged, err := s.ListSessions(SessionFilter{Tags: map[string]string{"ticket": "JIRA-123"}})
+	if err != nil {
+		t.Fatalf("ListSessions failed: %v", err)
+	}
+	if len(tagged) != 1 || tagged[0].ID != "pay" || tagged[0].Tags["team"] != "payments" {
+		t.Errorf("Expected only the payments session, got %+v", tagged)
+	}
+
+	none, _ := s.ListSessions(SessionFilter{Tags: map[string]string{"team": "search", "ticket": "JIRA-123"}})
+	if len(none) != 0 {
+		t.Errorf("Expected tags to be ANDed, got %d sessions", len(none))
+	}
+
+	all, _ := s.ListSessions(SessionFilter{})
+	if len(all) != 3 {
+		t.Errorf("Expected 3 sessions without a tag filter, got %d", len(all))
+	}
+}
+
+func TestSQLiteStore_SessionParents(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "store-test-*")
+	defer os.RemoveAll(tmpDir)
+
+	s, err := NewSQLiteStore(filepath.Join(tmpDir, "meta.db"), filepath.Join(tmpDir, "artifacts"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	s.CreateSession(&Session{ID: "parent", CreatedAt: time.Now(), Metadata: map[string]string{}})
+	s.CreateSession(&Session{ID: "parent-sub1", CreatedAt: time.Now(), Metadata: map[string]string{}, ParentID: "parent"})
+	s.CreateSession(&Session{ID: "other", CreatedAt: time.Now(), Metadata: map[string]string{}})
+
+	got, err := s.GetSession("parent-sub1")
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	if got.ParentID != "parent" {
+		t.Errorf("ParentID not persisted: %q", got.ParentID)
+	}
+
+	children, err := s.ListSessions(SessionFilter{ParentID: "parent"})
+	if err != nil {
+		t.Fatalf("ListSessions failed: %v", err)
+	}
+	if len(children) != 1 || children[0].ID != "parent-sub1" {
+		t.Errorf("Expected only the sub-task, got %+v", children)
+	}
+}
+
+func TestSQLiteStore_Messages(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "store-test-*")
+	defer os.RemoveAll(tmpDir)
+
+	s, err := NewSQLiteStore(filepath.Join(tmpDir, "meta.db"), filepath.Join(tmpDir, "artifacts"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	s.CreateSession(&Session{ID: "chat", CreatedAt: time.Now(), Metadata: map[string]string{}})
+
+	if err := s.AppendMessages("chat", []*Message{
+		{Role: "user", Content: "Goal: test"},
+		{Role: "assistant", Content: "Listing files", ToolCalls: `[{"id":"call_1","name":"run_shell","args":"{}"}]`, PromptTokens: 100, CompletionTokens: 20},
+	}); err != nil {
+		t.Fatalf("AppendMessages failed: %v", err)
+	}
+	if err := s.AppendMessages("chat", []*Message{{Role: "tool", Content: "main.go", ToolCallID: "call_1"}}); err != nil {
+		t.Fatalf("AppendMessages failed: %v", err)
+	}
+
+	messages, err := s.LoadMessages("chat")
+	if err != nil {
+		t.Fatalf("LoadMessages failed: %v", err)
+	}
+	if len(messages) != 3 {
+		t.Fatalf("Expected 3 messages, got %d", len(messages))
+	}
+	for i, m := range messages {
+		if m.Seq != i {
+			t.Errorf("Expected seq %d, got %d", i, m.Seq)
+		}
+	}
+	if messages[1].PromptTokens != 100 || messages[1].ToolCalls == "" || messages[2].ToolCallID != "call_1" {
+		t.Errorf("Message fields not persisted: %+v, %+v", messages[1], messages[2])
+	}
+
+	if empty, _ := s.LoadMessages("other"); len(empty) != 0 {
+		t.Errorf("Expected no messages for unknown session, got %d", len(empty))
+	}
+}
+
+func TestSQLiteStore_EncryptedHistory(t *testing.T) {
+	tmpDir := t.TempDir()
+	s, err := NewSQLiteStore(filepath.Join(tmpDir, "meta.db"), filepath.Join(tmpDir, "artifacts"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	m, _ := credential.NewPassphraseManager("secret", []byte("0123456789abcdef"))
+	s.SetMessageCipher(credential.NewHistoryCipher(func() (*credential.Manager, error) { return m, nil }), true)
+	s.CreateSession(&Session{ID: "chat", CreatedAt: time.Now(), Metadata: map[string]string{}})
+
+	if err := s.AppendMessages("chat", []*Message{{Role: "assistant", Content: "the password is hunter2", ToolCalls: `[{"id":"call_1"}]`}}); err != nil {
+		t.Fatalf("AppendMessages failed: %v", err)
+	}
+	if err := s.SaveSnapshot(&Snapshot{SessionID: "chat", Iteration: 1, Status: "active", Messages: `[{"role":"user","content":"hunter2"}]`, Response: "ok"}); err != nil {
+		t.Fatalf("SaveSnapshot failed: %v", err)
+	}
+
+	var content, toolCalls, snapMessages string
+	s.db.QueryRow("SELECT content, tool_calls FROM messages WHERE session_id = 'chat'").Scan(&content, &toolCalls)
+	s.db.QueryRow("SELECT messages FROM snapshots WHERE session_id = 'chat'").Scan(&snapMessages)
+	for _, raw := range []string{content, toolCalls, snapMessages} {
+		if !credential.IsEncrypted(raw) || strings.Contains(raw, "hunter2") {
+			t.Errorf("Expected history encrypted at rest, got %q", raw)
+		}
+	}
+
+	messages, err := s.LoadMessages("chat")
+	if err != nil || len(messages) != 1 || messages[0].Content != "the password is hunter2" || messages[0].ToolCalls != `[{"id":"call_1"}]` {
+		t.Errorf("Expected decrypted messages, got %+v (%v)", messages, err)
+	}
+	snap, err := s.GetSnapshot("chat", 1)
+	if err != nil || snap.Messages != `[{"role":"user","content":"hunter2"}]` || snap.Response != "ok" {
+		t.Errorf("Expected a decrypted snapshot, got %+v (%v)", snap, err)
+	}
+
+	// Without a key, encrypted history can't be read
+	s.SetMessageCipher(nil, false)
+	if _, err := s.LoadMessages("chat"); !errors.Is(err, ErrHistoryEncrypted) {
+		t.Errorf("Expected ErrHistoryEncrypted, got %v", err)
+	}
+	if _, err := s.GetSnapshot("chat", 1); !errors.Is(err, ErrHistoryEncrypted) {
+		t.Errorf("Expected ErrHistoryEncrypted for the snapshot, got %v", err)
+	}
+}
+
+func TestSQLiteStore_ResponseCache(t *testing.T) {
+	tmpDir := t.TempDir()
+	s, err := NewSQLiteStore(filepath.Join(tmpDir, "meta.db"), filepath.Join(tmpDir, "artifacts"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	if _, ok, err := s.GetCachedResponse("k1"); ok || err != nil {
+		t.Fatalf("Expected miss on empty cache, got ok=%v err=%v", ok, err)
+	}
+	if err := s.PutCachedResponse("k1", []byte(`{"content":"hi"}`)); err != nil {
+		t.Fatalf("PutCachedResponse failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		data, ok, err := s.GetCachedResponse("k1")
+		if err != nil || !ok || string(data) != `{"content":"hi"}` {
+			t.Fatalf("Expected cached response, got %q ok=%v err=%v", data, ok, err)
+		}
+	}
+
+	stats, err := s.ResponseCacheStats()
+	if err != nil || stats.Entries != 1 || stats.Hits != 2 {
+		t.Errorf("Unexpected stats %+v (err %v)", stats, err)
+	}
+	if n, err := s.ClearResponseCache(); err != nil || n != 1 {
+		t.Errorf("Expected 1 entry cleared, got %d (err %v)", n, err)
+	}
+	if _, ok, _ := s.GetCachedResponse("k1"); ok {
+		t.Error("Expected cache to be empty after clear")
+	}
+}
+
+func TestSQLiteStore_CancelRequest(t *testing.T) {
+	tmpDir := t.TempDir()
+	s, err := NewSQLiteStore(filepath.Join(tmpDir, "meta.db"), filepath.Join(tmpDir, "artifacts"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	sess := &Session{ID: "running", CreatedAt: time.Now(), Status: "running", Metadata: map[string]string{}}
+	s.CreateSession(sess)
+
+	if ok, _ := s.CancelRequested("running"); ok {
+		t.Error("Expected no cancellation request initially")
+	}
+	if err := s.RequestCancel("running"); err != nil {
+		t.Fatalf("RequestCancel failed: %v", err)
+	}
+
+	// Updates from the running loop must not clear the flag
+	s.UpdateSession(sess)
+	if ok, _ := s.CancelRequested("running"); !ok {
+		t.Error("Expected cancellation request to survive UpdateSession")
+	}
+
+	if err := s.RequestCancel("missing"); err == nil {
+		t.Error("Expected error for unknown session")
+	}
+}
+
+func TestSQLiteStore_Steering(t *testing.T) {
+	tmpDir := t.TempDir()
+	s, err := NewSQLiteStore(filepath.Join(tmpDir, "meta.db"), filepath.Join(tmpDir, "artifacts"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	s.CreateSession(&Session{ID: "running", CreatedAt: time.Now(), Status: "running", Metadata: map[string]string{}})
+	if reqs, err := s.TakeSteering("running"); err != nil || len(reqs) != 0 {
+		t.Fatalf("Expected no steering initially, got %v, %v", reqs, err)
+	}
+
+	s.SteerSession(&SteeringRequest{SessionID: "running", Message: "use the v2 API"})
+	s.SteerSession(&SteeringRequest{SessionID: "running", Provider: "anthropic", Model: "claude-sonnet-4-5"})
+	reqs, err := s.TakeSteering("running")
+	if err != nil {
+		t.Fatalf("TakeSteering failed: %v", err)
+	}
+	if len(reqs) != 2 || reqs[0].Message != "use the v2 API" || reqs[1].Provider != "anthropic" || reqs[1].Model != "claude-sonnet-4-5" {
+		t.Errorf("Expected both requests in order, got %+v", reqs)
+	}
+	if reqs, _ := s.TakeSteering("running"); len(reqs) != 0 {
+		t.Errorf("Expected taken requests to be removed, got %+v", reqs)
+	}
+
+	if err := s.SteerSession(&SteeringRequest{SessionID: "missing", Message: "hi"}); err == nil {
+		t.Error("Expected error for unknown session")
+	}
+}
+
+func TestSQLiteStore_Heartbeats(t *testing.T) {
+	tmpDir := t.TempDir()
+	s, err := NewSQLiteStore(filepath.Join(tmpDir, "meta.db"), filepath.Join(tmpDir, "artifacts"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	for _, id := range []string{"dead", "alive", "cli", "done"} {
+		s.CreateSession(&Session{ID: id, CreatedAt: time.Now(), Status: "running", Metadata: map[string]string{}})
+	}
+	start := time.Now()
+	for _, id := range []string{"dead", "alive", "done"} {
+		if err := s.Heartbeat(id, "host:1"); err != nil {
+			t.Fatalf("Heartbeat failed: %v", err)
+		}
+	}
+	done, _ := s.GetSession("done")
+	done.Status = "completed"
+	s.UpdateSession(done)
+	if err := s.Heartbeat("missing", "host:1"); err == nil {
+		t.Error("Expected error for unknown session")
+	}
+
+	if stale, err := s.StaleSessions(start); err != nil || len(stale) != 0 {
+		t.Errorf("Expected no heartbeats older than the first, got %d, %v", len(stale), err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	cutoff := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	s.Heartbeat("alive", "host:1")
+	if stale, _ := s.StaleSessions(cutoff); len(stale) != 1 || stale[0].ID != "dead" {
+		t.Errorf("Expected only the session without a recent heartbeat, got %+v", stale)
+	}
+	if stale, _ := s.StaleSessions(time.Now().Add(time.Second)); len(stale) != 2 || stale[0].ID != "dead" || stale[1].ID != "alive" {
+		t.Errorf("Expected the unfinished sessions with heartbeats, oldest first, got %+v", stale)
+	}
+
+	if ok, err := s.OrphanSession("dead", cutoff); err != nil || !ok {
+		t.Fatalf("Expected the dead session orphaned, got %v, %v", ok, err)
+	}
+	if sess, _ := s.GetSession("dead"); sess.Status != StatusOrphaned {
+		t.Errorf("Expected status %s, got %s", StatusOrphaned, sess.Status)
+	}
+	if ok, _ := s.OrphanSession("dead", cutoff); ok {
+		t.Error("Expected an orphaned session not to be orphaned again")
+	}
+	if ok, _ := s.OrphanSession("alive", cutoff); ok {
+		t.Error("Expected a session with a newer heartbeat to be left alone")
+	}
+	if ok, _ := s.OrphanSession("cli", cutoff); ok {
+		t.Error("Expected a session without heartbeats to be left alone")
+	}
+}
+
+func TestSQLiteStore_Artifacts(t *testing.T) {
+	tmpDir := t.TempDir()
+	artDir := filepath.Join(tmpDir, "artifacts")
+	s, err := NewSQLiteStore(filepath.Join(tmpDir, "meta.db"), artDir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+	s.CreateSession(&Session{ID: "sess-art", CreatedAt: time.Now(), Metadata: map[string]string{}})
+
+	t.Run("Identical Content Stored Once", func(t *testing.T) {
+		for _, id := range []string{"a", "b"} {
+			a := &Artifact{ID: "art-sess-art-" + id, SessionID: "sess-art", Path: "artifacts/sess-art/out_" + id + ".txt", Type: "tool_output"}
+			if err := s.SaveArtifact(a, []byte("ok\n")); err != nil {
+				t.Fatalf("SaveArtifact failed: %v", err)
+			}
+		}
+		a, content, err := s.GetArtifact("art-sess-art-b")
+		if err != nil || string(content) != "ok\n" {
+			t.Fatalf("GetArtifact = %q, %v", content, err)
+		}
+		if a.Size != 3 || !strings.HasPrefix(a.MIMEType, "text/plain") || !a.IsText() {
+			t.Errorf("Unexpected metadata: %+v", a)
+		}
+		blobs, _ := filepath.Glob(filepath.Join(artDir, "blobs", "*", "*"))
+		if len(blobs) != 1 || filepath.Base(blobs[0]) != a.Blob {
+			t.Errorf("Expected one blob named by the content hash, got %v", blobs)
+		}
+	})
+
+	t.Run("Binary Content", func(t *testing.T) {
+		png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 32)...)
+		a := &Artifact{ID: "art-sess-art-img", SessionID: "sess-art", Path: "artifacts/sess-art/screenshot", Type: "tool_output"}
+		if err := s.SaveArtifact(a, png); err != nil {
+			t.Fatalf("SaveArtifact failed: %v", err)
+		}
+		got, content, err := s.GetArtifact(a.ID)
+		if err != nil || !bytes.Equal(content, png) {
+			t.Fatalf("Expected the binary content back, got %v", err)
+		}
+		if got.MIMEType != "image/png" || got.IsText() {
+			t.Errorf("Expected a binary image/png artifact, got %q", got.MIMEType)
+		}
+	})
+
+	t.Run("Size Limit", func(t *testing.T) {
+		s.SetMaxArtifactSize(4)
+		defer s.SetMaxArtifactSize(DefaultMaxArtifactSize)
+		err := s.SaveArtifact(&Artifact{ID: "art-sess-art-big", SessionID: "sess-art", Path: "artifacts/sess-art/big.txt"}, []byte("too big"))
+		if !errors.Is(err, ErrArtifactTooLarge) {
+			t.Fatalf("Expected ErrArtifactTooLarge, got %v", err)
+		}
+		if _, _, err := s.GetArtifact("art-sess-art-big"); err == nil {
+			t.Error("Expected the oversized artifact not to be recorded")
+		}
+	})
+
+	t.Run("Legacy Artifact", func(t *testing.T) {
+		os.MkdirAll(filepath.Join(artDir, "artifacts", "sess-art"), 0750)
+		os.WriteFile(filepath.Join(artDir, "artifacts", "sess-art", "old.json"), []byte(`{"ok":true}`), 0600)
+		s.db.Exec(`INSERT INTO artifacts (id, session_id, path, type, created_at, digest) VALUES ('art-sess-art-old', 'sess-art', 'artifacts/sess-art/old.json', 'plan', ?, '')`, time.Now())
+
+		a, content, err := s.GetArtifact("art-sess-art-old")
+		if err != nil || string(content) != `{"ok":true}` {
+			t.Fatalf("Expected the legacy artifact from its path, got %q, %v", content, err)
+		}
+		if a.MIMEType != "application/json" || a.Size != int64(len(content)) || !a.IsText() {
+			t.Errorf("Expected detected metadata, got %+v", a)
+		}
+
+		// A legacy artifact's digest is checked in place of a blob
+		sum := sha256.Sum256([]byte("before\n"))
+		os.WriteFile(filepath.Join(artDir, "artifacts", "sess-art", "old.txt"), []byte("after\n"), 0600)
+		s.db.Exec(`INSERT INTO artifacts (id, session_id, path, type, created_at, digest) VALUES ('art-sess-art-old-rot', 'sess-art', 'artifacts/sess-art/old.txt', 'tool_output', ?, ?)`, time.Now(), hex.EncodeToString(sum[:]))
+		if _, _, err := s.GetArtifact("art-sess-art-old-rot"); !errors.Is(err, ErrArtifactCorrupt) {
+			t.Errorf("Expected ErrArtifactCorrupt for a legacy artifact not matching its digest, got %v", err)
+		}
+		os.WriteFile(filepath.Join(artDir, "artifacts", "sess-art", "old.txt"), []byte("before\n"), 0600)
+		if _, content, err := s.GetArtifact("art-sess-art-old-rot"); err != nil || string(content) != "before\n" {
+			t.Errorf("Expected the legacy artifact matching its digest, got %q, %v", content, err)
+		}
+		os.WriteFile(filepath.Join(artDir, "artifacts", "sess-art", "old_verify.txt"), []byte("$ go test\nbefore\n"), 0600)
+		s.db.Exec(`INSERT INTO artifacts (id, session_id, path, type, created_at, digest) VALUES ('art-sess-art-old-verify', 'sess-art', 'artifacts/sess-art/old_verify.txt', 'verify_output', ?, ?)`, time.Now(), hex.EncodeToString(sum[:]))
+		if _, _, err := s.GetArtifact("art-sess-art-old-verify"); err != nil {
+			t.Errorf("Expected a recorded command output checked without its command line, got %v", err)
+		}
+	})
+
+	t.Run("Corrupt Content", func(t *testing.T) {
+		a := &Artifact{ID: "art-sess-art-rot", SessionID: "sess-art", Path: "artifacts/sess-art/rot.txt", Type: "tool_output"}
+		if err := s.SaveArtifact(a, []byte("before\n")); err != nil {
+			t.Fatalf("SaveArtifact failed: %v", err)
+		}
+		path, _ := s.blobPath(a.Blob)
+		os.WriteFile(path, []byte("after\n"), 0600)
+		if _, _, err := s.GetArtifact(a.ID); !errors.Is(err, ErrArtifactCorrupt) || !strings.Contains(err.Error(), a.Blob) {
+			t.Errorf("Expected ErrArtifactCorrupt naming the expected digest, got %v", err)
+		}
+		os.Remove(path)
+		if _, _, err := s.GetArtifact(a.ID); !errors.Is(err, ErrArtifactMissing) {
+			t.Errorf("Expected ErrArtifactMissing, got %v", err)
+		}
+	})
+}
+
+func TestSQLiteStore_CheckArtifacts(t *testing.T) {
+	tmpDir := t.TempDir()
+	artDir := filepath.Join(tmpDir, "artifacts")
+	s, err := NewSQLiteStore(filepath.Join(tmpDir, "meta.db"), artDir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+	s.CreateSession(&Session{ID: "sess-fsck", CreatedAt: time.Now(), Metadata: map[string]string{}})
+
+	save := func(id, content string) *Artifact {
+		a := &Artifact{ID: "art-sess-fsck-" + id, SessionID: "sess-fsck", Path: "artifacts/sess-fsck/" + id + ".txt", Type: "tool_output"}
+		if err := s.SaveArtifact(a, []byte(content)); err != nil {
+			t.Fatalf("SaveArtifact failed: %v", err)
+		}
+		return a
+	}
+	save("ok", "fine\n")
+	gone := save("gone", "lost\n")
+	rot := save("rot", "before\n")
+	save("rot-copy", "before\n")
+	gonePath, _ := s.blobPath(gone.Blob)
+	os.Remove(gonePath)
+	rotPath, _ := s.blobPath(rot.Blob)
+	os.WriteFile(rotPath, []byte("after\n"), 0600)
+
+	orphan := filepath.Join(artDir, "blobs", "ab", strings.Repeat("ab", 32))
+	os.MkdirAll(filepath.Dir(orphan), 0750)
+	os.WriteFile(orphan, []byte("nobody's"), 0600)
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(orphan, old, old)
+	// A legacy artifact whose file no longer matches its digest
+	legacyPath := filepath.Join(artDir, "artifacts", "sess-fsck", "legacy.txt")
+	os.MkdirAll(filepath.Dir(legacyPath), 0750)
+	os.WriteFile(legacyPath, []byte("tampered\n"), 0600)
+	sum := sha256.Sum256([]byte("legacy\n"))
+	s.db.Exec(`INSERT INTO artifacts (id, session_id, path, type, created_at, digest) VALUES ('art-sess-fsck-legacy', 'sess-fsck', 'artifacts/sess-fsck/legacy.txt', 'tool_output', ?, ?)`, time.Now(), hex.EncodeToString(sum[:]))
+	fresh := filepath.Join(artDir, "blobs", "cd", strings.Repeat("cd", 32)+".tmp-1")
+	os.MkdirAll(filepath.Dir(fresh), 0750)
+	os.WriteFile(fresh, []byte("being written"), 0600)
+
+	report, err := s.CheckArtifacts(FsckOptions{})
+	if err != nil {
+		t.Fatalf("CheckArtifacts failed: %v", err)
+	}
+	if report.Checked != 5 || len(report.Missing) != 1 || report.Missing[0].ID != gone.ID || len(report.Corrupt) != 3 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+	if len(report.Orphans) != 1 || report.Orphans[0] != filepath.Join("blobs", "ab", strings.Repeat("ab", 32)) {
+		t.Errorf("Expected only the old unreferenced blob as an orphan, got %v", report.Orphans)
+	}
+	if report.Marked != 0 || report.Deleted != 0 || report.Clean() {
+		t.Errorf("Expected a dry run to repair nothing, got %+v", report)
+	}
+
+	report, err = s.CheckArtifacts(FsckOptions{MarkMissing: true, DeleteOrphans: true})
+	if err != nil {
+		t.Fatalf("CheckArtifacts failed: %v", err)
+	}
+	if report.Marked != 4 || report.Deleted != 2 {
+		t.Errorf("Expected 4 artifacts marked and the corrupt blob and orphan removed, got %+v", report)
+	}
+	if _, err := os.Stat(legacyPath); err != nil {
+		t.Error("Expected the corrupt legacy artifact's file to be kept")
+	}
+	if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+		t.Error("Expected the orphan to be deleted")
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Error("Expected a file younger than the grace period to be kept")
+	}
+	if _, _, err := s.GetArtifact(rot.ID); !errors.Is(err, ErrArtifactMissing) {
+		t.Errorf("Expected a marked artifact to read as missing, got %v", err)
+	}
+	if artifacts, _ := s.ListArtifacts("sess-fsck"); len(artifacts) != 5 {
+		t.Errorf("Expected marked artifacts to stay listed, got %d", len(artifacts))
+	} else {
+		for _, a := range artifacts {
+			if a.Missing != (a.ID != "art-sess-fsck-ok") {
+				t.Errorf("Unexpected missing flag on %s: %v", a.ID, a.Missing)
+			}
+		}
+	}
+
+	// Saving the same content again brings the marked copies back
+	save("rot-again", "before\n")
+	os.WriteFile(legacyPath, []byte("legacy\n"), 0600)
+	report, err = s.CheckArtifacts(FsckOptions{MarkMissing: true})
+	if err != nil {
+		t.Fatalf("CheckArtifacts failed: %v", err)
+	}
+	if report.Restored != 3 || len(report.Missing) != 1 || len(report.Corrupt) != 0 || report.Marked != 0 {
+		t.Errorf("Expected the corrupt artifacts restored, got %+v", report)
+	}
+	if _, content, err := s.GetArtifact(rot.ID); err != nil || string(content) != "before\n" {
+		t.Errorf("Expected the restored content, got %q, %v", content, err)
+	}
+}
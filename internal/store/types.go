@@ -1,6 +1,9 @@
 package store
 
-import "time"
+import (
+	"regexp"
+	"time"
+)
 
 // Session represents a unique execution run
 type Session struct {
@@ -9,16 +12,134 @@ type Session struct {
 	UpdatedAt time.Time
 	Status    string
 	Metadata  map[string]string // simplified for now
+	Tags      map[string]string // User-supplied key=value labels, indexed for filtering
+	ParentID  string            // Set on sub-task sessions spawned by another session
+
+	// Usage accounting, updated by the runtime every iteration
+	Provider         string
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64 // Estimated cost in USD
+}
+
+// SessionFinished reports whether status is final: the session ended and
+// no process is running it. Any other status means it may still run.
+func SessionFinished(status string) bool {
+	switch status {
+	case "completed", "halted", "verification_exhausted", "cancelled", "failed", "interrupted", "orphaned":
+		return true
+	}
+	return false
+}
+
+// SessionFailed reports whether a final status means the session broke
+// off on an error, a signal, or a crashed process, rather than completing
+// or being stopped by the guard, its verification, or the user.
+func SessionFailed(status string) bool {
+	switch status {
+	case "failed", "interrupted", "orphaned":
+		return true
+	}
+	return false
+}
+
+// SessionFilter narrows the sessions returned by ListSessions.
+type SessionFilter struct {
+	Since    time.Time         // Only sessions created at or after this time (zero means no limit)
+	Limit    int               // Maximum number of sessions (0 means no limit)
+	Tags     map[string]string // Only sessions carrying every one of these tags
+	ParentID string            // Only sub-tasks of this session
 }
 
 // Artifact represents a file or data blob generated during execution
 type Artifact struct {
 	ID        string
 	SessionID string
-	Path      string    // Relative path in the artifact store
-	Type      string    // e.g., "tool_output", "log", "summary"
+	Path      string // Relative path in the artifact store
+	Type      string // e.g., "tool_output", "log", "summary"
 	CreatedAt time.Time
-	Digest    string    // Content hash
+	Digest    string // Content hash
+	MIMEType  string // Detected from the path and content when not set
+	Size      int64  // Content length in bytes
+	Blob      string // SHA-256 of the content, naming its file in the blob store; empty for legacy artifacts
+	Missing   bool   // Content found missing or corrupt and marked by CheckArtifacts
+}
+
+// ArtifactSearch selects the artifacts searched by SearchArtifacts and the
+// lines to find in them.
+type ArtifactSearch struct {
+	Pattern   *regexp.Regexp // Lines to find; substring searches quote their query
+	SessionID string         // Only artifacts of this session
+	Since     time.Time      // Only artifacts created at or after this time (zero means no limit)
+	Until     time.Time      // Only artifacts created before this time (zero means no limit)
+	Limit     int            // Maximum number of matching lines (0 means no limit)
+}
+
+// ArtifactMatch is a line of an artifact matching a search.
+type ArtifactMatch struct {
+	Artifact *Artifact
+	Line     int // Line number, counting from 1
+	Text     string
+}
+
+// ViolationRecord is a guard violation reported during a session, kept for
+// `simon violations`.
+type ViolationRecord struct {
+	ID        int64
+	SessionID string
+	Rule      string // Policy rule, e.g. allowed_commands
+	Severity  string // warn, block, or halt
+	Subject   string // The command or file path at fault; empty for budget rules
+	Message   string
+	CreatedAt time.Time
+}
+
+// ViolationFilter narrows the records returned by ListViolations.
+type ViolationFilter struct {
+	Since     time.Time // Only violations at or after this time (zero means no limit)
+	SessionID string    // Only violations of this session
+}
+
+// Snapshot is a session's state when an iteration's request was sent to the
+// provider, kept for `simon inspect`.
+type Snapshot struct {
+	SessionID     string
+	Iteration     int
+	Status        string
+	PromptTokens  int    // Session totals before the request
+	OutputTokens  int    // Session totals before the request
+	ContextTokens int    // Size of the context sent, as counted before sending
+	Messages      string // JSON-encoded messages sent to the model, after the first BaseMessages
+	BaseMessages  int    // Leading messages shared with the previous iteration's snapshot, not stored again
+	Response      string // JSON-encoded response; empty when the request failed
+	Error         string // Why the request failed
+	CreatedAt     time.Time
+}
+
+// SteeringRequest is a change asked of a running session from outside its
+// process, such as `simon steer`: a message for the agent, a switch of
+// provider or model, or both.
+type SteeringRequest struct {
+	ID        int64
+	SessionID string
+	Message   string
+	Provider  string // Empty keeps the current provider
+	Model     string // Empty keeps the current model, or uses the new provider's default
+	CreatedAt time.Time
+}
+
+// Message is one entry of a session's conversation history.
+type Message struct {
+	SessionID        string
+	Seq              int    // Position in the conversation, assigned by AppendMessages
+	Role             string // user, assistant, or tool
+	Content          string
+	ToolCalls        string // JSON-encoded tool calls requested by an assistant message
+	ToolCallID       string // Set on tool results
+	PromptTokens     int
+	CompletionTokens int
+	CreatedAt        time.Time
 }
 
 // Storage defines the interface for persistence
@@ -27,20 +148,50 @@ type Storage interface {
 	CreateSession(session *Session) error
 	GetSession(id string) (*Session, error)
 	UpdateSession(session *Session) error
+	ListSessions(filter SessionFilter) ([]*Session, error)
+	// RequestCancel flags a session so its running loop stops gracefully.
+	RequestCancel(id string) error
+	CancelRequested(id string) (bool, error)
+
+	// Conversation History
+	// AppendMessages adds messages after the session's existing history.
+	AppendMessages(sessionID string, messages []*Message) error
+	// LoadMessages returns a session's history in conversation order.
+	LoadMessages(sessionID string) ([]*Message, error)
 
 	// Artifact Management
 	// SaveArtifact persists the metadata and the content
 	SaveArtifact(artifact *Artifact, content []byte) error
 	GetArtifact(id string) (*Artifact, []byte, error)
 	ListArtifacts(sessionID string) ([]*Artifact, error)
+	// SearchArtifacts returns the lines of text artifacts matching a search,
+	// oldest artifact first.
+	SearchArtifacts(search ArtifactSearch) ([]*ArtifactMatch, error)
+
+	// Guard Telemetry
+	RecordViolation(v *ViolationRecord) error
+	// ListViolations returns matching violations, oldest first.
+	ListViolations(filter ViolationFilter) ([]*ViolationRecord, error)
+
+	// State Snapshots
+	// SaveSnapshot stores an iteration's state, replacing an earlier one.
+	SaveSnapshot(snap *Snapshot) error
+	GetSnapshot(sessionID string, iteration int) (*Snapshot, error)
+	// ListSnapshots returns a session's snapshots by iteration, without
+	// messages and response.
+	ListSnapshots(sessionID string) ([]*Snapshot, error)
 
 	// Configuration Management
 	SetConfig(key, value string) error
 	GetConfig(key string) (string, error)
 
 	// Memory Management
+	// AddMemory stores a memory; vector may be nil when the provider cannot
+	// embed, leaving the memory to keyword search.
 	AddMemory(content string, vector []float32, meta map[string]string) error
 	SearchMemory(vector []float32, limit int) ([]MemoryItem, error)
+	// QueryMemory searches using the store's configured MemorySearchMode.
+	QueryMemory(query MemoryQuery) ([]MemoryItem, error)
 
 	Close() error
 }
@@ -50,3 +201,50 @@ type MemoryItem struct {
 	Metadata   map[string]string
 	Similarity float32
 }
+
+// MemorySearchMode selects how QueryMemory ranks memories.
+type MemorySearchMode string
+
+const (
+	// MemorySearchVector ranks by cosine similarity over the in-memory index.
+	MemorySearchVector MemorySearchMode = "vector"
+	// MemorySearchFTS ranks by SQLite FTS5 keyword relevance (bm25); no embeddings needed.
+	MemorySearchFTS MemorySearchMode = "fts"
+	// MemorySearchHybrid blends FTS5 relevance with vector similarity.
+	MemorySearchHybrid MemorySearchMode = "hybrid"
+)
+
+// MemoryNamespaceKey is the memory metadata key holding the project a
+// memory was recorded in.
+const MemoryNamespaceKey = "namespace"
+
+// MemoryKindKey is the memory metadata key holding what a memory records,
+// one of the MemoryKind values. Memories archived without one are
+// summaries.
+const MemoryKindKey = "kind"
+
+// Kinds of archived memories.
+const (
+	MemoryKindSummary  = "summary"  // What a session built or got done
+	MemoryKindWorked   = "worked"   // Approaches that worked
+	MemoryKindPitfalls = "pitfalls" // Mistakes and dead ends to avoid
+	MemoryKindCommands = "commands" // Commands that proved useful
+)
+
+// MemoryQuery describes a memory lookup. Text is used by the fts and hybrid
+// modes, Vector by the vector and hybrid modes.
+type MemoryQuery struct {
+	Text   string
+	Vector []float32
+	Limit  int
+	// Namespace restricts the search to one project's memories; empty
+	// searches every project, including memories recorded without a
+	// namespace (see SQLiteStore.MoveMemories).
+	Namespace string
+}
+
+// inNamespace reports whether a memory with the given metadata is visible
+// to a search restricted to namespace.
+func inNamespace(meta map[string]string, namespace string) bool {
+	return namespace == "" || meta[MemoryNamespaceKey] == namespace
+}
@@ -0,0 +1,362 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/felixgeelhaar/simon/internal/coach"
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+// pane identifies which part of the browser has keyboard focus.
+type pane int
+
+const (
+	paneSessions pane = iota
+	paneArtifacts
+	paneViewer
+)
+
+var (
+	paneStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("#444444")).
+			Padding(0, 1)
+
+	focusedPaneStyle = paneStyle.
+				BorderForeground(lipgloss.Color("#7D56F4"))
+
+	selectedStyle = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("#FAFAFA")).
+			Background(lipgloss.Color("#7D56F4"))
+
+	dimStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#888888"))
+
+	warnStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FFB000"))
+
+	keyStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#5FAFFF"))
+)
+
+// BrowserModel is a read-only multi-pane view over stored sessions:
+// a session list, a detail pane with evidence status and artifacts,
+// and a scrollable artifact viewer.
+type BrowserModel struct {
+	store store.Storage
+
+	sessions  []*store.Session
+	artifacts []*store.Artifact
+	evidence  []evidenceStatus
+
+	sessionCursor  int
+	artifactCursor int
+	focus          pane
+
+	viewer     viewport.Model
+	viewerName string
+
+	err    error
+	width  int
+	height int
+	ready  bool
+}
+
+type evidenceStatus struct {
+	Path   string
+	Exists bool
+}
+
+// NewBrowserModel loads the most recent sessions from the store.
+func NewBrowserModel(s store.Storage, limit int) BrowserModel {
+	m := BrowserModel{store: s}
+	sessions, err := s.ListSessions(store.SessionFilter{Limit: limit})
+	if err != nil {
+		m.err = err
+		return m
+	}
+	m.sessions = sessions
+	m.loadSelection()
+	return m
+}
+
+func (m BrowserModel) Init() tea.Cmd {
+	return nil
+}
+
+// loadSelection refreshes the detail pane for the selected session.
+func (m *BrowserModel) loadSelection() {
+	m.artifacts = nil
+	m.evidence = nil
+	m.artifactCursor = 0
+
+	sess := m.selectedSession()
+	if sess == nil {
+		return
+	}
+
+	artifacts, err := m.store.ListArtifacts(sess.ID)
+	if err != nil {
+		m.err = err
+		return
+	}
+	m.artifacts = artifacts
+	m.evidence = evidenceFor(sess)
+}
+
+// evidenceFor checks the evidence declared by the session's spec, if it can still be loaded.
+func evidenceFor(sess *store.Session) []evidenceStatus {
+	specPath, ok := sess.Metadata["spec"]
+	if !ok {
+		return nil
+	}
+	spec, err := coach.New().LoadSpec(specPath)
+	if err != nil {
+		return nil
+	}
+	statuses := make([]evidenceStatus, 0, len(spec.Evidence))
+	for _, e := range spec.Evidence {
+		_, err := os.Stat(e)
+		statuses = append(statuses, evidenceStatus{Path: e, Exists: err == nil})
+	}
+	return statuses
+}
+
+func (m BrowserModel) selectedSession() *store.Session {
+	if m.sessionCursor < 0 || m.sessionCursor >= len(m.sessions) {
+		return nil
+	}
+	return m.sessions[m.sessionCursor]
+}
+
+func (m *BrowserModel) openArtifact() {
+	if m.artifactCursor < 0 || m.artifactCursor >= len(m.artifacts) {
+		return
+	}
+	a := m.artifacts[m.artifactCursor]
+	stored, content, err := m.store.GetArtifact(a.ID)
+	if err != nil {
+		m.viewer.SetContent(errorStyle.Render(err.Error()))
+	} else if !stored.IsText() {
+		m.viewer.SetContent(fmt.Sprintf("Binary artifact (%s, %d bytes). Save it with: simon artifact get %s --output <file>", stored.MIMEType, stored.Size, a.ID))
+	} else {
+		m.viewer.SetContent(highlight(a.Path, string(content)))
+	}
+	m.viewerName = filepath.Base(a.Path)
+	m.viewer.GotoTop()
+	m.focus = paneViewer
+}
+
+func (m BrowserModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		m.viewer = viewport.New(msg.Width-4, msg.Height-6)
+		m.ready = true
+		return m, nil
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "q":
+			return m, tea.Quit
+		case "tab":
+			if m.focus == paneSessions && len(m.artifacts) > 0 {
+				m.focus = paneArtifacts
+			} else if m.focus == paneArtifacts {
+				m.focus = paneSessions
+			}
+			return m, nil
+		case "esc", "backspace", "left", "h":
+			if m.focus > paneSessions {
+				m.focus--
+			}
+			return m, nil
+		}
+
+		switch m.focus {
+		case paneSessions:
+			switch msg.String() {
+			case "up", "k":
+				if m.sessionCursor > 0 {
+					m.sessionCursor--
+					m.loadSelection()
+				}
+			case "down", "j":
+				if m.sessionCursor < len(m.sessions)-1 {
+					m.sessionCursor++
+					m.loadSelection()
+				}
+			case "enter", "right", "l":
+				if len(m.artifacts) > 0 {
+					m.focus = paneArtifacts
+				}
+			}
+		case paneArtifacts:
+			switch msg.String() {
+			case "up", "k":
+				if m.artifactCursor > 0 {
+					m.artifactCursor--
+				}
+			case "down", "j":
+				if m.artifactCursor < len(m.artifacts)-1 {
+					m.artifactCursor++
+				}
+			case "enter", "right", "l":
+				m.openArtifact()
+			}
+		case paneViewer:
+			var cmd tea.Cmd
+			m.viewer, cmd = m.viewer.Update(msg)
+			return m, cmd
+		}
+	}
+	return m, nil
+}
+
+func (m BrowserModel) View() string {
+	if !m.ready {
+		return "\n  Loading sessions..."
+	}
+
+	header := titleStyle.Render(" Simon Session Browser ")
+	footer := dimStyle.Render("↑/↓ move • enter open • tab switch pane • esc back • q quit")
+
+	if m.err != nil {
+		return fmt.Sprintf("%s\n\n%s\n", header, errorStyle.Render("Error: "+m.err.Error()))
+	}
+
+	if m.focus == paneViewer {
+		title := keyStyle.Render(m.viewerName) + dimStyle.Render(fmt.Sprintf("  %3.f%%", m.viewer.ScrollPercent()*100))
+		return fmt.Sprintf("%s %s\n%s\n%s", header, title, focusedPaneStyle.Render(m.viewer.View()), footer)
+	}
+
+	listWidth := m.width / 3
+	if listWidth < 24 {
+		listWidth = 24
+	}
+	detailWidth := m.width - listWidth - 6
+	bodyHeight := m.height - 5
+
+	left := m.renderSessions(listWidth, bodyHeight)
+	right := m.renderDetail(detailWidth, bodyHeight)
+
+	leftStyle, rightStyle := focusedPaneStyle, paneStyle
+	if m.focus == paneArtifacts {
+		leftStyle, rightStyle = paneStyle, focusedPaneStyle
+	}
+
+	body := lipgloss.JoinHorizontal(lipgloss.Top,
+		leftStyle.Width(listWidth).Height(bodyHeight).Render(left),
+		rightStyle.Width(detailWidth).Height(bodyHeight).Render(right),
+	)
+	return fmt.Sprintf("%s\n%s\n%s", header, body, footer)
+}
+
+func (m BrowserModel) renderSessions(width, height int) string {
+	if len(m.sessions) == 0 {
+		return dimStyle.Render("No sessions recorded yet.")
+	}
+
+	var b strings.Builder
+	start := 0
+	if m.sessionCursor >= height {
+		start = m.sessionCursor - height + 1
+	}
+	for i := start; i < len(m.sessions) && i < start+height; i++ {
+		sess := m.sessions[i]
+		line := truncate(fmt.Sprintf("%-10s %s", sess.Status, sess.ID), width-2)
+		if i == m.sessionCursor {
+			line = selectedStyle.Render(line)
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
+
+func (m BrowserModel) renderDetail(width, height int) string {
+	sess := m.selectedSession()
+	if sess == nil {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(keyStyle.Render("Session  ") + sess.ID + "\n")
+	b.WriteString(keyStyle.Render("Status   ") + sess.Status + "\n")
+	b.WriteString(keyStyle.Render("Created  ") + sess.CreatedAt.Format("2006-01-02 15:04:05") + "\n")
+	if sess.Provider != "" {
+		b.WriteString(keyStyle.Render("Provider ") + sess.Provider + " " + dimStyle.Render(sess.Model) + "\n")
+	}
+	b.WriteString(keyStyle.Render("Tokens   ") + fmt.Sprintf("%d prompt / %d completion ($%.4f)", sess.PromptTokens, sess.CompletionTokens, sess.Cost) + "\n")
+	if spec, ok := sess.Metadata["spec"]; ok {
+		b.WriteString(keyStyle.Render("Spec     ") + truncate(spec, width-12) + "\n")
+	}
+
+	b.WriteString("\n" + keyStyle.Render("Evidence") + "\n")
+	if len(m.evidence) == 0 {
+		b.WriteString(dimStyle.Render("  (spec unavailable)") + "\n")
+	}
+	for _, e := range m.evidence {
+		mark := infoStyle.Render("✓")
+		if !e.Exists {
+			mark = errorStyle.Render("✗")
+		}
+		b.WriteString(fmt.Sprintf("  %s %s\n", mark, truncate(e.Path, width-6)))
+	}
+
+	b.WriteString("\n" + keyStyle.Render(fmt.Sprintf("Artifacts (%d)", len(m.artifacts))) + "\n")
+	for i, a := range m.artifacts {
+		line := truncate(fmt.Sprintf("%-12s %s", a.Type, filepath.Base(a.Path)), width-4)
+		if m.focus == paneArtifacts && i == m.artifactCursor {
+			line = selectedStyle.Render(line)
+		}
+		b.WriteString("  " + line + "\n")
+	}
+	return b.String()
+}
+
+// highlight applies lightweight, line-based syntax highlighting to artifact content.
+// It recognizes diffs, JSON-ish keys, and common failure/success markers in tool output.
+func highlight(path, content string) string {
+	lines := strings.Split(content, "\n")
+	isDiff := strings.HasSuffix(path, ".diff") || strings.HasSuffix(path, ".patch")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		lower := strings.ToLower(trimmed)
+		switch {
+		case isDiff && strings.HasPrefix(line, "+"):
+			lines[i] = infoStyle.Render(line)
+		case isDiff && strings.HasPrefix(line, "-"):
+			lines[i] = errorStyle.Render(line)
+		case isDiff && strings.HasPrefix(line, "@@"):
+			lines[i] = keyStyle.Render(line)
+		case strings.HasPrefix(lower, "error") || strings.Contains(lower, "[error]") ||
+			strings.HasPrefix(lower, "--- fail") || strings.HasPrefix(lower, "fail") || strings.HasPrefix(lower, "panic:"):
+			lines[i] = errorStyle.Render(line)
+		case strings.HasPrefix(lower, "warn"):
+			lines[i] = warnStyle.Render(line)
+		case strings.HasPrefix(lower, "ok ") || strings.HasPrefix(lower, "--- pass") || lower == "pass":
+			lines[i] = infoStyle.Render(line)
+		case strings.HasPrefix(trimmed, `"`) && strings.Contains(trimmed, `":`):
+			idx := strings.Index(line, `":`) + 1
+			lines[i] = keyStyle.Render(line[:idx]) + line[idx:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// truncate shortens s to at most n runes.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if n <= 3 || len(r) <= n {
+		return s
+	}
+	return string(r[:n-3]) + "..."
+}
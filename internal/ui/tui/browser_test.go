@@ -0,0 +1,49 @@
+package tui
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/felixgeelhaar/simon/internal/store"
+)
+
+func TestBrowserModel_Navigation(t *testing.T) {
+	tmpDir := t.TempDir()
+	s, err := store.NewSQLiteStore(filepath.Join(tmpDir, "db"), filepath.Join(tmpDir, "artifacts"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	s.CreateSession(&store.Session{ID: "older", CreatedAt: time.Now().Add(-time.Hour), Status: "completed", Metadata: map[string]string{}})
+	s.CreateSession(&store.Session{ID: "newer", CreatedAt: time.Now(), Status: "halted", Metadata: map[string]string{}})
+	s.SaveArtifact(&store.Artifact{ID: "a1", SessionID: "older", Path: "older/out.txt", Type: "tool_output", CreatedAt: time.Now()}, []byte("ok  \tpkg\nFAIL\tother"))
+
+	var model tea.Model = NewBrowserModel(s, 10)
+	model, _ = model.Update(tea.WindowSizeMsg{Width: 100, Height: 30})
+
+	if view := model.View(); !strings.Contains(view, "newer") || !strings.Contains(view, "older") {
+		t.Fatalf("Expected both sessions in view, got:\n%s", view)
+	}
+
+	// Move to the older session, focus artifacts, open the first one
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyDown})
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	b := model.(BrowserModel)
+	if b.focus != paneViewer {
+		t.Fatalf("Expected viewer focus, got %v", b.focus)
+	}
+	if !strings.Contains(b.View(), "FAIL") {
+		t.Errorf("Expected artifact content in viewer")
+	}
+
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if model.(BrowserModel).focus != paneArtifacts {
+		t.Error("Expected esc to return to the artifact list")
+	}
+}
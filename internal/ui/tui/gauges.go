@@ -0,0 +1,115 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Gauges turn amber and red as consumption passes these fractions of a limit.
+const (
+	gaugeAmber = 0.75
+	gaugeRed   = 0.90
+	gaugeWidth = 20
+)
+
+// gauge is one budget limited by the policy.
+type gauge struct {
+	label       string
+	used, limit float64
+	text        string // used/limit as shown
+}
+
+// gauges lists the limited budgets of the reported usage.
+func (m Model) gauges() []gauge {
+	u := m.Usage
+	var gs []gauge
+	if u.MaxPromptTokens > 0 {
+		gs = append(gs, gauge{"prompt", float64(u.PromptTokens), float64(u.MaxPromptTokens),
+			fmt.Sprintf("%d/%d tokens", u.PromptTokens, u.MaxPromptTokens)})
+	}
+	if u.MaxOutputTokens > 0 {
+		gs = append(gs, gauge{"output", float64(u.OutputTokens), float64(u.MaxOutputTokens),
+			fmt.Sprintf("%d/%d tokens", u.OutputTokens, u.MaxOutputTokens)})
+	}
+	if u.MaxCost > 0 {
+		gs = append(gs, gauge{"cost", u.Cost, u.MaxCost, fmt.Sprintf("$%.4f/$%.2f", u.Cost, u.MaxCost)})
+	}
+	return gs
+}
+
+// view renders the gauge as a bar colored by how much of the limit is used.
+func (g gauge) view() string {
+	frac := g.used / g.limit
+	filled := int(frac*gaugeWidth + 0.5)
+	if filled > gaugeWidth {
+		filled = gaugeWidth
+	}
+	bar := strings.Repeat("█", filled) + strings.Repeat("░", gaugeWidth-filled)
+	switch {
+	case frac >= gaugeRed:
+		bar = errorStyle.Render(bar)
+	case frac >= gaugeAmber:
+		bar = warnStyle.Render(bar)
+	default:
+		bar = infoStyle.Render(bar)
+	}
+	return fmt.Sprintf(" %-6s %s %3.0f%%  %s", g.label, bar, frac*100, g.text)
+}
+
+// remainingIterations predicts how many more iterations fit in the budget
+// at the average consumption so far, and which limit runs out first. It
+// returns -1 before the first iteration has been reported.
+func (m Model) remainingIterations() (int, string) {
+	if m.Usage.Iteration == 0 {
+		return -1, ""
+	}
+	iterations := float64(m.Usage.Iteration)
+	best, limit, found := 0, "", false
+	if m.MaxIter > 0 {
+		best, limit, found = m.MaxIter-m.Usage.Iteration, "iteration", true
+	}
+	for _, g := range m.gauges() {
+		perIteration := g.used / iterations
+		if perIteration <= 0 {
+			continue
+		}
+		if n := int((g.limit - g.used) / perIteration); !found || n < best {
+			best, limit, found = n, g.label, true
+		}
+	}
+	if !found {
+		return -1, ""
+	}
+	if best < 0 {
+		best = 0
+	}
+	return best, limit
+}
+
+// usageView renders the gauges and the budget forecast footer.
+func (m Model) usageView() string {
+	gs := m.gauges()
+	if len(gs) == 0 {
+		return ""
+	}
+	lines := make([]string, 0, len(gs)+1)
+	for _, g := range gs {
+		lines = append(lines, g.view())
+	}
+
+	n, limit := m.remainingIterations()
+	switch {
+	case n < 0:
+		lines = append(lines, dimStyle.Render(" Forecast available after the first iteration"))
+	case n == 0:
+		lines = append(lines, errorStyle.Render(fmt.Sprintf(" The next iteration will likely exceed the %s budget", limit)))
+	default:
+		line := fmt.Sprintf(" ~%d more iterations fit in the remaining budget (%s runs out first)", n, limit)
+		if n <= 2 {
+			lines = append(lines, warnStyle.Render(line))
+		} else {
+			lines = append(lines, dimStyle.Render(line))
+		}
+	}
+	return "\n" + strings.Join(lines, "\n")
+}
@@ -0,0 +1,77 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+)
+
+// sparklineWidth is the number of recent iterations the context sparkline
+// shows.
+const sparklineWidth = 30
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline renders values as block characters, the full block standing for
+// scale, or for the largest value when scale is 0.
+func sparkline(values []int, scale int) string {
+	if scale <= 0 {
+		for _, v := range values {
+			if v > scale {
+				scale = v
+			}
+		}
+	}
+	if scale <= 0 {
+		return ""
+	}
+	var b strings.Builder
+	for _, v := range values {
+		i := v * (len(sparkBlocks) - 1) / scale
+		if i >= len(sparkBlocks) {
+			i = len(sparkBlocks) - 1
+		}
+		if i < 0 {
+			i = 0
+		}
+		b.WriteRune(sparkBlocks[i])
+	}
+	return b.String()
+}
+
+// recordContext keeps the prompt size of the latest reported iteration for
+// the sparkline.
+func (m *Model) recordContext(tokens int) {
+	if tokens <= 0 {
+		return
+	}
+	m.Context = append(m.Context, tokens)
+	if len(m.Context) > sparklineWidth {
+		m.Context = m.Context[len(m.Context)-sparklineWidth:]
+	}
+}
+
+// contextView renders the prompt size per iteration as a sparkline, scaled
+// to the model's context window when it is known, with the latest
+// utilization and what summarization and compaction reclaimed.
+func (m Model) contextView() string {
+	if len(m.Context) == 0 {
+		return ""
+	}
+	u := m.Usage
+	line := fmt.Sprintf(" context %s  %d tokens", sparkline(m.Context, u.ContextWindow), u.ContextTokens)
+	frac := 0.0
+	if u.ContextWindow > 0 {
+		frac = float64(u.ContextTokens) / float64(u.ContextWindow)
+		line = fmt.Sprintf(" context %s  %d/%d tokens (%.0f%%)", sparkline(m.Context, u.ContextWindow), u.ContextTokens, u.ContextWindow, frac*100)
+	}
+	if u.Summaries > 0 || u.ReclaimedTokens > 0 {
+		line += fmt.Sprintf("  · summaries: %d, reclaimed: ~%d tokens", u.Summaries, u.ReclaimedTokens)
+	}
+	switch {
+	case frac >= gaugeRed:
+		return "\n" + errorStyle.Render(line)
+	case frac >= gaugeAmber:
+		return "\n" + warnStyle.Render(line)
+	}
+	return "\n" + dimStyle.Render(line)
+}
@@ -1,6 +1,7 @@
 package tui
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -8,6 +9,8 @@ import (
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/felixgeelhaar/simon/internal/credential"
+	"github.com/felixgeelhaar/simon/internal/ui"
 )
 
 type TUI struct {
@@ -30,6 +33,32 @@ func (t *TUI) Log(msg string) {
 	t.program.Send(LogMsg(msg))
 }
 
+func (t *TUI) UpdatePlan(steps []ui.PlanStep) {
+	t.program.Send(PlanMsg(steps))
+}
+
+// UpdateUsage refreshes the token and cost gauges.
+func (t *TUI) UpdateUsage(u ui.Usage) {
+	t.program.Send(UsageMsg(u))
+}
+
+// RequestApproval shows the diff in the TUI and waits for the user to press y or n.
+func (t *TUI) RequestApproval(ctx context.Context, title, diff string) bool {
+	reply := make(chan bool, 1)
+	t.program.Send(ApprovalMsg{Title: title, Diff: diff, Reply: reply})
+	select {
+	case ok := <-reply:
+		return ok
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// SetController enables the pause, cancel, and steering keys.
+func (t *TUI) SetController(c ui.Controller) {
+	t.program.Send(ControllerMsg{Controller: c})
+}
+
 var (
 	titleStyle = lipgloss.NewStyle().
 		Bold(true).
@@ -49,18 +78,45 @@ type Model struct {
 	Status     string
 	Iteration  int
 	MaxIter    int
-	Log        []string
+	Log        []string             // Log lines as received
+	Redactor   *credential.Redactor // Masks secrets in the displayed log; nil shows it as is
+	Reveal     bool                 // Show the log unredacted, toggled with r for debugging
+	Plan       []ui.PlanStep
+	Usage      ui.Usage      // Budget consumption shown as gauges
+	Context    []int         // Prompt size of the recent iterations, for the sparkline
+	Approval   *ApprovalMsg  // Pending change awaiting the user's decision
+	Controller ui.Controller // Session controls; nil hides the control keys
+	Paused     bool
+	Cancelling bool
+	Steering   bool   // The steering input has focus
+	Input      string // Steering message being typed
 	Progress   progress.Model
 	Viewport   viewport.Model
 	Quitting   bool
 	Ready      bool
 	Width      int
 	Height     int
+
+	redacted []string // Log as displayed while not revealed
 }
 
 type LogMsg string
 type StatusMsg string
 type IterMsg int
+type PlanMsg []ui.PlanStep
+type UsageMsg ui.Usage
+
+// ControllerMsg attaches the running session's controls.
+type ControllerMsg struct {
+	Controller ui.Controller
+}
+
+// ApprovalMsg asks the user to approve a proposed change; the answer is sent on Reply.
+type ApprovalMsg struct {
+	Title string
+	Diff  string
+	Reply chan<- bool
+}
 
 func NewModel(title string, maxIter int) Model {
 	p := progress.New(progress.WithDefaultGradient())
@@ -68,6 +124,7 @@ func NewModel(title string, maxIter int) Model {
 		Title:    title,
 		Status:   "Initializing...",
 		MaxIter:  maxIter,
+		Redactor: credential.NewRedactor(),
 		Progress: p,
 	}
 }
@@ -81,32 +138,82 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		if msg.Type == tea.KeyCtrlC || msg.String() == "q" {
+		if msg.Type == tea.KeyCtrlC {
+			m.resolveApproval(false)
+			m.Quitting = true
+			return m, tea.Quit
+		}
+		if m.Steering {
+			m.steeringKey(msg)
+			return m, nil
+		}
+		if msg.String() == "q" {
+			m.resolveApproval(false)
 			m.Quitting = true
 			return m, tea.Quit
 		}
+		if m.Approval != nil {
+			switch msg.String() {
+			case "y", "Y":
+				m.resolveApproval(true)
+				return m, nil
+			case "n", "N", "esc":
+				m.resolveApproval(false)
+				return m, nil
+			}
+		}
+		if msg.String() == "r" && m.Approval == nil {
+			m.Reveal = !m.Reveal
+			m.Viewport.SetContent(m.logContent())
+			return m, nil
+		}
+		if m.Controller != nil && m.controlKey(msg.String()) {
+			return m, nil
+		}
+
+	case ControllerMsg:
+		m.Controller = msg.Controller
+		if m.Ready {
+			m.Viewport.Height = m.logHeight()
+		}
 
 	case tea.WindowSizeMsg:
 		m.Width = msg.Width
 		m.Height = msg.Height
 		if !m.Ready {
-			m.Viewport = viewport.New(msg.Width, msg.Height-10)
+			m.Viewport = viewport.New(msg.Width, m.logHeight())
 			m.Ready = true
 		} else {
 			m.Viewport.Width = msg.Width
-			m.Viewport.Height = msg.Height - 10
+			m.Viewport.Height = m.logHeight()
 		}
 
 	case LogMsg:
-		m.Log = append(m.Log, string(msg))
-		m.Viewport.SetContent(strings.Join(m.Log, "\n"))
-		m.Viewport.GotoBottom()
+		m.appendLog(string(msg))
+
+	case ApprovalMsg:
+		m.Approval = &msg
+		m.Viewport.SetContent(highlight("change.diff", msg.Diff))
+		m.Viewport.GotoTop()
 
 	case StatusMsg:
 		m.Status = string(msg)
 
 	case IterMsg:
 		m.Iteration = int(msg)
+
+	case PlanMsg:
+		m.Plan = msg
+		if m.Ready {
+			m.Viewport.Height = m.logHeight()
+		}
+
+	case UsageMsg:
+		m.Usage = ui.Usage(msg)
+		m.recordContext(msg.ContextTokens)
+		if m.Ready {
+			m.Viewport.Height = m.logHeight()
+		}
 	}
 
 	var cmd tea.Cmd
@@ -123,14 +230,27 @@ func (m Model) View() string {
 
 	header := titleStyle.Render(" Simon AI Agent Governance ")
 	status := infoStyle.Render(fmt.Sprintf(" Status: %s ", m.Status))
+	if m.Reveal {
+		status += warnStyle.Render(" secrets shown (r to hide) ")
+	}
 	iter := fmt.Sprintf(" Iteration: %d/%d ", m.Iteration, m.MaxIter)
 	
 	prog := m.Progress.ViewAs(float64(m.Iteration) / float64(m.MaxIter))
 
-	view := fmt.Sprintf("%s%s%s\n\n%s\n\n%s", 
+	view := fmt.Sprintf("%s%s%s\n\n%s%s\n\n%s%s%s",
 		header, status, iter,
+		m.planView(),
 		m.Viewport.View(),
-		prog)
+		prog,
+		m.usageView()+m.contextView(),
+		m.controlView())
+	if m.Approval != nil {
+		view = fmt.Sprintf("%s%s%s\n\n%s\n%s\n\n%s",
+			header, status, iter,
+			warnStyle.Render("Approve change: "+m.Approval.Title),
+			m.Viewport.View(),
+			keyStyle.Render("y approve • n reject • ↑/↓ scroll"))
+	}
 
 	if m.Quitting {
 		return view + "\n  Quitting...\n"
@@ -138,3 +258,146 @@ func (m Model) View() string {
 
 	return view
 }
+
+// resolveApproval answers the pending approval, if any, and restores the log view.
+func (m *Model) resolveApproval(approved bool) {
+	if m.Approval == nil {
+		return
+	}
+	m.Approval.Reply <- approved
+	m.Approval = nil
+	if approved {
+		m.appendLog(infoStyle.Render("✓ Change approved"))
+	} else {
+		m.appendLog(errorStyle.Render("✗ Change rejected"))
+	}
+}
+
+// controlKey handles the session control keys and reports whether key was one.
+func (m *Model) controlKey(key string) bool {
+	switch key {
+	case "p", " ":
+		if m.Paused {
+			m.Controller.Resume()
+		} else {
+			m.Controller.Pause()
+			m.appendLog(warnStyle.Render("⏸  Pausing after the current step..."))
+		}
+		m.Paused = !m.Paused
+	case "c":
+		if !m.Cancelling {
+			m.Cancelling = true
+			m.Controller.Cancel()
+			m.appendLog(errorStyle.Render("🛑 Cancelling after the current step..."))
+		}
+	case "s", "i":
+		m.Steering = true
+	default:
+		return false
+	}
+	return true
+}
+
+// steeringKey edits the steering input; enter sends it and esc discards it.
+func (m *Model) steeringKey(msg tea.KeyMsg) {
+	switch msg.Type {
+	case tea.KeyEnter:
+		if text := strings.TrimSpace(m.Input); text != "" {
+			m.Controller.Steer(text)
+			m.appendLog(infoStyle.Render("🧭 Queued: " + text))
+		}
+		m.Input, m.Steering = "", false
+	case tea.KeyEsc:
+		m.Input, m.Steering = "", false
+	case tea.KeyBackspace:
+		if r := []rune(m.Input); len(r) > 0 {
+			m.Input = string(r[:len(r)-1])
+		}
+	case tea.KeySpace:
+		m.Input += " "
+	case tea.KeyRunes:
+		m.Input += string(msg.Runes)
+	}
+}
+
+// appendLog adds a line to the log and shows it unless an approval is open.
+func (m *Model) appendLog(line string) {
+	m.Log = append(m.Log, line)
+	if m.Redactor != nil {
+		line = m.Redactor.String(line)
+	}
+	m.redacted = append(m.redacted, line)
+	if m.Approval == nil {
+		m.Viewport.SetContent(m.logContent())
+		m.Viewport.GotoBottom()
+	}
+}
+
+// logContent is the log as displayed: with secrets masked unless revealed.
+func (m Model) logContent() string {
+	if m.Reveal {
+		return strings.Join(m.Log, "\n")
+	}
+	return strings.Join(m.redacted, "\n")
+}
+
+// controlView renders the steering input or the control key help.
+func (m Model) controlView() string {
+	switch {
+	case m.Controller == nil:
+		return ""
+	case m.Steering:
+		return "\n" + keyStyle.Render("Steer: ") + m.Input + "█\n" + dimStyle.Render("enter send • esc discard")
+	case m.Cancelling:
+		return "\n" + dimStyle.Render("cancelling • q quit")
+	}
+	pause := "p pause"
+	if m.Paused {
+		pause = "p resume"
+	}
+	return "\n" + dimStyle.Render(pause+" • s steer • c cancel • r reveal • q quit")
+}
+
+// logHeight is the space left for the log viewport below the plan pane.
+func (m Model) logHeight() int {
+	h := m.Height - 10
+	if len(m.Plan) > 0 {
+		h -= len(m.Plan) + 2
+	}
+	if m.Controller != nil {
+		h -= 2
+	}
+	if n := len(m.gauges()); n > 0 {
+		h -= n + 2
+	}
+	if len(m.Context) > 0 {
+		h--
+	}
+	if h < 3 {
+		h = 3
+	}
+	return h
+}
+
+// planView renders the step plan with a marker per status.
+func (m Model) planView() string {
+	if len(m.Plan) == 0 {
+		return ""
+	}
+	done := 0
+	var b strings.Builder
+	for i, step := range m.Plan {
+		line := fmt.Sprintf("%d. %s", i+1, step.Description)
+		switch step.Status {
+		case "done":
+			done++
+			line = infoStyle.Render(" ✓ " + line)
+		case "in_progress":
+			line = " ▶ " + line
+		default:
+			line = " ○ " + line
+		}
+		b.WriteString(line + "\n")
+	}
+	return fmt.Sprintf("Plan (%d/%d)\n%s\n", done, len(m.Plan), b.String())
+}
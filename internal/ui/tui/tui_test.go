@@ -0,0 +1,183 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/felixgeelhaar/simon/internal/ui"
+)
+
+func TestModel_Approval(t *testing.T) {
+	var model tea.Model = NewModel("test", 10)
+	model, _ = model.Update(tea.WindowSizeMsg{Width: 80, Height: 30})
+	model, _ = model.Update(LogMsg("working"))
+
+	reply := make(chan bool, 1)
+	model, _ = model.Update(ApprovalMsg{Title: "main.go", Diff: "--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-old\n+new\n", Reply: reply})
+
+	view := model.View()
+	if !strings.Contains(view, "Approve change: main.go") || !strings.Contains(view, "+new") {
+		t.Fatalf("Expected diff preview, got:\n%s", view)
+	}
+
+	// Keys other than y/n leave the request pending
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+	if model.(Model).Approval == nil {
+		t.Fatal("Expected approval to stay pending")
+	}
+
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	if ok := <-reply; !ok {
+		t.Error("Expected approval")
+	}
+	m := model.(Model)
+	if m.Approval != nil || !strings.Contains(m.View(), "working") {
+		t.Errorf("Expected log view to be restored, got:\n%s", m.View())
+	}
+}
+
+// recordingController records the controls the model invokes.
+type recordingController struct {
+	calls []string
+}
+
+func (c *recordingController) Pause()           { c.calls = append(c.calls, "pause") }
+func (c *recordingController) Resume()          { c.calls = append(c.calls, "resume") }
+func (c *recordingController) Cancel()          { c.calls = append(c.calls, "cancel") }
+func (c *recordingController) Steer(msg string) { c.calls = append(c.calls, "steer:"+msg) }
+
+func TestModel_Controls(t *testing.T) {
+	keys := func(model tea.Model, s string) tea.Model {
+		for _, r := range s {
+			model, _ = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		}
+		return model
+	}
+
+	var model tea.Model = NewModel("test", 10)
+	model, _ = model.Update(tea.WindowSizeMsg{Width: 80, Height: 30})
+	model = keys(model, "p")
+	if model.(Model).Paused {
+		t.Fatal("Expected control keys to do nothing without a controller")
+	}
+
+	c := &recordingController{}
+	model, _ = model.Update(ControllerMsg{Controller: c})
+	if !strings.Contains(model.View(), "p pause • s steer • c cancel") {
+		t.Errorf("Expected control help, got:\n%s", model.View())
+	}
+
+	model = keys(model, "pp")
+	model = keys(model, "s")
+	if !model.(Model).Steering {
+		t.Fatal("Expected the steering input to open")
+	}
+	// q, p, and c are typed into the input rather than acting
+	model = keys(model, "skip")
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeySpace})
+	model = keys(model, "cqx")
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyBackspace})
+	if !strings.Contains(model.View(), "Steer: skip cq") {
+		t.Errorf("Expected the typed message, got:\n%s", model.View())
+	}
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	model = keys(model, "s")
+	model = keys(model, "discard me")
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	model = keys(model, "cc")
+
+	m := model.(Model)
+	if m.Quitting || m.Steering || !m.Cancelling {
+		t.Errorf("Unexpected state: quitting %v, steering %v, cancelling %v", m.Quitting, m.Steering, m.Cancelling)
+	}
+	if got := strings.Join(c.calls, ","); got != "pause,resume,steer:skip cq,cancel" {
+		t.Errorf("Unexpected controls %s", got)
+	}
+	if !strings.Contains(m.View(), "Queued: skip cq") {
+		t.Errorf("Expected the queued message in the log, got:\n%s", m.View())
+	}
+}
+
+func TestModel_UsageGauges(t *testing.T) {
+	var model tea.Model = NewModel("test", 10)
+	model, _ = model.Update(tea.WindowSizeMsg{Width: 100, Height: 40})
+	if strings.Contains(model.View(), "prompt") {
+		t.Fatal("Expected no gauges before usage is reported")
+	}
+
+	model, _ = model.Update(UsageMsg(ui.Usage{
+		Iteration:       2,
+		PromptTokens:    6000,
+		MaxPromptTokens: 8000,
+		OutputTokens:    200,
+		MaxOutputTokens: 4000,
+	}))
+	view := model.View()
+	for _, want := range []string{"prompt", " 75%  6000/8000 tokens", "output"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected %q in the view, got:\n%s", want, view)
+		}
+	}
+	// 3000 prompt tokens per iteration leave no room for another
+	if n, limit := model.(Model).remainingIterations(); n != 0 || limit != "prompt" {
+		t.Errorf("Expected the prompt budget to run out first, got %d (%s)", n, limit)
+	}
+	if !strings.Contains(view, "likely exceed the prompt budget") {
+		t.Errorf("Expected an exhausted-budget warning, got:\n%s", view)
+	}
+
+	model, _ = model.Update(UsageMsg(ui.Usage{Iteration: 1, PromptTokens: 1000, MaxPromptTokens: 8000, Cost: 0.01, MaxCost: 1}))
+	if n, limit := model.(Model).remainingIterations(); n != 7 || limit != "prompt" {
+		t.Errorf("Expected 7 more iterations bounded by prompt tokens, got %d (%s)", n, limit)
+	}
+	if !strings.Contains(model.View(), "~7 more iterations fit in the remaining budget (prompt runs out first)") {
+		t.Errorf("Expected the forecast footer, got:\n%s", model.View())
+	}
+}
+
+func TestModel_ContextSparkline(t *testing.T) {
+	if got := sparkline([]int{0, 4, 8}, 8); got != "▁▄█" {
+		t.Errorf("Expected the values scaled to 8, got %q", got)
+	}
+	if got := sparkline([]int{1, 2}, 0); got != "▄█" {
+		t.Errorf("Expected the values scaled to the largest, got %q", got)
+	}
+
+	var model tea.Model = NewModel("test", 10)
+	model, _ = model.Update(tea.WindowSizeMsg{Width: 100, Height: 40})
+	if strings.Contains(model.View(), "context") {
+		t.Fatal("Expected no sparkline before usage is reported")
+	}
+	model, _ = model.Update(UsageMsg(ui.Usage{Iteration: 1, ContextTokens: 1000, ContextWindow: 8000}))
+	model, _ = model.Update(UsageMsg(ui.Usage{Iteration: 2, ContextTokens: 4000, ContextWindow: 8000, Summaries: 1, ReclaimedTokens: 2500}))
+	view := model.View()
+	for _, want := range []string{"context ▁▄  4000/8000 tokens (50%)", "summaries: 1, reclaimed: ~2500 tokens"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected %q in the view, got:\n%s", want, view)
+		}
+	}
+}
+
+func TestModel_Redaction(t *testing.T) {
+	var model tea.Model = NewModel("test", 10)
+	model, _ = model.Update(tea.WindowSizeMsg{Width: 120, Height: 30})
+	model, _ = model.Update(LogMsg("export OPENAI_API_KEY=sk-proj1234567890abcdef"))
+
+	if view := model.View(); strings.Contains(view, "sk-proj1234567890abcdef") || !strings.Contains(view, "sk-p...cdef") {
+		t.Fatalf("Expected the key masked, got:\n%s", view)
+	}
+	if m := model.(Model); m.Log[0] != "export OPENAI_API_KEY=sk-proj1234567890abcdef" {
+		t.Errorf("Expected the log to keep the line as received, got %q", m.Log[0])
+	}
+
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+	if view := model.View(); !strings.Contains(view, "sk-proj1234567890abcdef") || !strings.Contains(view, "secrets shown") {
+		t.Errorf("Expected r to reveal the key, got:\n%s", view)
+	}
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+	model, _ = model.Update(LogMsg("next"))
+	if view := model.View(); strings.Contains(view, "sk-proj1234567890abcdef") || !strings.Contains(view, "next") {
+		t.Errorf("Expected r to mask the key again, got:\n%s", view)
+	}
+}
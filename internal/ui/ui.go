@@ -1,12 +1,72 @@
 package ui
 
+import "context"
+
 type UI interface {
 	UpdateStatus(status string)
 	UpdateIteration(iter int)
 	Log(msg string)
+	// UpdatePlan replaces the displayed step plan.
+	UpdatePlan(steps []PlanStep)
+}
+
+// PlanStep is a display-only view of a runtime plan step.
+type PlanStep struct {
+	Description string
+	Status      string // pending, in_progress, or done
+}
+
+// Approver is implemented by UIs that can ask the user to confirm a proposed
+// change, shown as a unified diff. It blocks until the user answers or ctx ends.
+type Approver interface {
+	RequestApproval(ctx context.Context, title, diff string) bool
+}
+
+// Controller lets a UI steer a running session.
+type Controller interface {
+	// Pause stops the session before its next iteration; Resume continues it.
+	Pause()
+	Resume()
+	// Cancel requests a graceful cancellation, like `simon cancel`.
+	Cancel()
+	// Steer adds a user message to the conversation before the next provider call.
+	Steer(msg string)
+}
+
+// Controllable is implemented by UIs that offer session controls.
+type Controllable interface {
+	SetController(c Controller)
+}
+
+// Usage is a session's consumption against its policy limits; a zero
+// limit means the resource is not limited.
+type Usage struct {
+	Iteration       int
+	PromptTokens    int
+	MaxPromptTokens int
+	OutputTokens    int
+	MaxOutputTokens int
+	Cost            float64 // Estimated, in USD
+	MaxCost         float64
+	// ContextTokens is the prompt size of the latest request and
+	// ContextWindow the model's context size, 0 if unknown.
+	ContextTokens int
+	ContextWindow int
+	// Summaries and ReclaimedTokens count the history summarizations and
+	// the estimated tokens removed by them and by compaction.
+	Summaries       int
+	ReclaimedTokens int
+}
+
+// UsageReporter is implemented by UIs that display budget consumption. The
+// runtime reports usage after every provider response.
+type UsageReporter interface {
+	UpdateUsage(u Usage)
 }
 
 type SilentUI struct{}
-func (s SilentUI) UpdateStatus(status string) {}
-func (s SilentUI) UpdateIteration(iter int)   {}
-func (s SilentUI) Log(msg string)             {}
+
+func (s SilentUI) UpdateStatus(status string)  {}
+func (s SilentUI) UpdateIteration(iter int)    {}
+func (s SilentUI) Log(msg string)              {}
+func (s SilentUI) UpdatePlan(steps []PlanStep) {}
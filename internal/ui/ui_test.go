@@ -36,6 +36,7 @@ type MockUI struct {
 	StatusUpdates    []string
 	IterationUpdates []int
 	LogMessages      []string
+	PlanUpdates      [][]PlanStep
 }
 
 func (m *MockUI) UpdateStatus(status string) {
@@ -50,6 +51,10 @@ func (m *MockUI) Log(msg string) {
 	m.LogMessages = append(m.LogMessages, msg)
 }
 
+func (m *MockUI) UpdatePlan(steps []PlanStep) {
+	m.PlanUpdates = append(m.PlanUpdates, steps)
+}
+
 func TestMockUI_UpdateStatus(t *testing.T) {
 	ui := &MockUI{}
 
@@ -115,5 +120,6 @@ func TestUI_InterfaceMethods(t *testing.T) {
 		ui.UpdateStatus("test")
 		ui.UpdateIteration(1)
 		ui.Log("test")
+		ui.UpdatePlan([]PlanStep{{Description: "step", Status: "pending"}})
 	}
 }
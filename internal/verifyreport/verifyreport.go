@@ -0,0 +1,319 @@
+// Package verifyreport renders sessions' verification outcomes in formats
+// CI systems read without custom parsing: SARIF 2.1.0 for code-scanning UIs
+// and JUnit XML for test result views.
+package verifyreport
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/felixgeelhaar/simon/internal/mcp"
+)
+
+// Report formats accepted by Write.
+const (
+	FormatSARIF = "sarif"
+	FormatJUnit = "junit"
+)
+
+// Session is the verification outcome of one session.
+type Session struct {
+	ID     string
+	Spec   string
+	Status string
+	// Report is the session's last verification, nil if its evidence was
+	// never checked (e.g. it halted first).
+	Report *mcp.EvidenceReport
+}
+
+// Target is a report to write, as given to --report format=path.
+type Target struct {
+	Format string
+	Path   string
+}
+
+// ParseTargets parses format=path pairs.
+func ParseTargets(pairs []string) ([]Target, error) {
+	targets := make([]Target, 0, len(pairs))
+	for _, pair := range pairs {
+		format, path, ok := strings.Cut(pair, "=")
+		if !ok || path == "" {
+			return nil, fmt.Errorf("invalid report %q: expected format=path", pair)
+		}
+		if format != FormatSARIF && format != FormatJUnit {
+			return nil, fmt.Errorf("unknown report format %q (want %s or %s)", format, FormatSARIF, FormatJUnit)
+		}
+		targets = append(targets, Target{Format: format, Path: path})
+	}
+	return targets, nil
+}
+
+// WriteFile writes the report to the target's path.
+func (t Target) WriteFile(sessions []Session) error {
+	f, err := os.Create(t.Path)
+	if err != nil {
+		return err
+	}
+	if err := Write(f, t.Format, sessions); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Write renders sessions in the given format.
+func Write(w io.Writer, format string, sessions []Session) error {
+	switch format {
+	case FormatSARIF:
+		return SARIF(w, sessions)
+	case FormatJUnit:
+		return JUnit(w, sessions)
+	}
+	return fmt.Errorf("unknown report format %q", format)
+}
+
+// check is one reported item; a session without a verification is reported
+// as a single failed "session" check.
+type check struct {
+	kind, name, status, message, output string
+}
+
+func (s Session) checks() []check {
+	if s.Report == nil {
+		return []check{{
+			kind:    "session",
+			name:    s.ID,
+			status:  mcp.EvidenceFail,
+			message: fmt.Sprintf("session ended %s before its evidence was verified", s.Status),
+		}}
+	}
+	checks := make([]check, 0, len(s.Report.Items))
+	for _, item := range s.Report.Items {
+		c := check{kind: item.Kind, name: item.Item, status: item.Status, output: item.Output}
+		switch item.Status {
+		case mcp.EvidencePass:
+			c.message = item.Kind + " passed: " + item.Item
+		case mcp.EvidenceSkipped:
+			c.message = item.Kind + " skipped after an earlier failure: " + item.Item
+		default:
+			c.message = item.Kind + " " + item.Status + ": " + item.Item
+			if item.Detail != "" {
+				c.message += "\n" + item.Detail
+			}
+		}
+		checks = append(checks, c)
+	}
+	return checks
+}
+
+// rules describes the SARIF rules a result can refer to.
+var rules = []sarifRule{
+	{ID: "evidence", ShortDescription: sarifText{Text: "Evidence file required by the task spec exists"}},
+	{ID: "verify", ShortDescription: sarifText{Text: "Verify command from the task spec succeeds"}},
+	{ID: "session", ShortDescription: sarifText{Text: "Session reached verification"}},
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool              sarifTool         `json:"tool"`
+	AutomationDetails sarifAutomation   `json:"automationDetails"`
+	Results           []sarifResult     `json:"results"`
+	Properties        map[string]string `json:"properties,omitempty"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string    `json:"id"`
+	ShortDescription sarifText `json:"shortDescription"`
+}
+
+type sarifText struct {
+	Text string `json:"text"`
+}
+
+type sarifAutomation struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Kind      string          `json:"kind"`
+	Level     string          `json:"level"`
+	Message   sarifText       `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// SARIF writes one SARIF run per session. Passing checks are kept as "pass"
+// results so code-scanning UIs show what was verified, not only failures.
+func SARIF(w io.Writer, sessions []Session) error {
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    make([]sarifRun, 0, len(sessions)),
+	}
+	for _, s := range sessions {
+		run := sarifRun{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "simon",
+				InformationURI: "https://github.com/felixgeelhaar/simon",
+				Rules:          rules,
+			}},
+			AutomationDetails: sarifAutomation{ID: "simon/" + s.ID},
+			Results:           []sarifResult{},
+			Properties:        map[string]string{"session": s.ID, "spec": s.Spec, "status": s.Status},
+		}
+		for _, c := range s.checks() {
+			res := sarifResult{RuleID: c.kind, Kind: "fail", Level: "error", Message: sarifText{Text: c.message}}
+			switch c.status {
+			case mcp.EvidencePass:
+				res.Kind, res.Level = "pass", "none"
+			case mcp.EvidenceSkipped:
+				res.Kind, res.Level = "notApplicable", "none"
+			}
+			if c.kind == "evidence" {
+				res.Locations = []sarifLocation{location(c.name)}
+			}
+			if c.output != "" {
+				res.Message.Text += "\n(output at " + c.output + ")"
+			}
+			run.Results = append(run.Results, res)
+		}
+		log.Runs = append(log.Runs, run)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
+
+func location(uri string) sarifLocation {
+	return sarifLocation{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: uri}}}
+}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitCase     `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// JUnit writes one test suite per session with a test case per evidence
+// file and verify command.
+func JUnit(w io.Writer, sessions []Session) error {
+	suites := junitSuites{Name: "simon"}
+	for _, s := range sessions {
+		suite := junitSuite{
+			Name: s.ID,
+			Properties: []junitProperty{
+				{Name: "spec", Value: s.Spec},
+				{Name: "status", Value: s.Status},
+			},
+		}
+		for _, c := range s.checks() {
+			tc := junitCase{ClassName: c.kind, Name: c.name}
+			if c.output != "" {
+				tc.SystemOut = "output: " + c.output
+			}
+			msg := &junitMessage{Message: firstLine(c.message), Text: c.message}
+			switch c.status {
+			case mcp.EvidencePass:
+			case mcp.EvidenceSkipped:
+				tc.Skipped = msg
+				suite.Skipped++
+			case mcp.EvidenceError:
+				tc.Error = msg
+				suite.Errors++
+			default:
+				tc.Failure = msg
+				suite.Failures++
+			}
+			suite.Cases = append(suite.Cases, tc)
+		}
+		suite.Tests = len(suite.Cases)
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+		suites.Skipped += suite.Skipped
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
@@ -0,0 +1,103 @@
+package verifyreport
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/felixgeelhaar/simon/internal/mcp"
+)
+
+func testSessions() []Session {
+	return []Session{
+		{
+			ID:     "sess-1",
+			Spec:   "task.yaml",
+			Status: "failed",
+			Report: &mcp.EvidenceReport{Items: []mcp.EvidenceStatus{
+				{Kind: "evidence", Item: "out.txt", Status: mcp.EvidencePass},
+				{Kind: "verify", Item: "go test ./...", Status: mcp.EvidenceFail, Detail: "FAIL foo", Output: "artifacts/sess-1/verification_verify-1.txt"},
+				{Kind: "verify", Item: "go vet ./...", Status: mcp.EvidenceSkipped},
+			}},
+		},
+		{ID: "sess-2", Spec: "task.yaml", Status: "halted"},
+	}
+}
+
+func TestParseTargets(t *testing.T) {
+	targets, err := ParseTargets([]string{"sarif=out.sarif", "junit=reports/junit.xml"})
+	if err != nil {
+		t.Fatalf("ParseTargets failed: %v", err)
+	}
+	if len(targets) != 2 || targets[0] != (Target{FormatSARIF, "out.sarif"}) || targets[1] != (Target{FormatJUnit, "reports/junit.xml"}) {
+		t.Errorf("unexpected targets: %+v", targets)
+	}
+
+	for _, bad := range []string{"sarif", "sarif=", "html=out.html"} {
+		if _, err := ParseTargets([]string{bad}); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestSARIF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := SARIF(&buf, testSessions()); err != nil {
+		t.Fatalf("SARIF failed: %v", err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 2 {
+		t.Fatalf("unexpected log: version %q, %d runs", log.Version, len(log.Runs))
+	}
+
+	results := log.Runs[0].Results
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	if results[0].Kind != "pass" || results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI != "out.txt" {
+		t.Errorf("unexpected evidence result: %+v", results[0])
+	}
+	if results[1].Kind != "fail" || results[1].Level != "error" || !strings.Contains(results[1].Message.Text, "FAIL foo") {
+		t.Errorf("unexpected verify result: %+v", results[1])
+	}
+	if results[2].Kind != "notApplicable" {
+		t.Errorf("expected skipped command to be notApplicable, got %q", results[2].Kind)
+	}
+
+	halted := log.Runs[1].Results
+	if len(halted) != 1 || halted[0].RuleID != "session" || !strings.Contains(halted[0].Message.Text, "halted") {
+		t.Errorf("expected an unverified session to fail its session rule, got %+v", halted)
+	}
+}
+
+func TestJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	if err := JUnit(&buf, testSessions()); err != nil {
+		t.Fatalf("JUnit failed: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "<?xml") {
+		t.Error("expected an XML header")
+	}
+
+	var suites junitSuites
+	if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+		t.Fatalf("invalid XML: %v", err)
+	}
+	if suites.Tests != 4 || suites.Failures != 2 || suites.Skipped != 1 || len(suites.Suites) != 2 {
+		t.Errorf("unexpected totals: %+v", suites)
+	}
+
+	cases := suites.Suites[0].Cases
+	if cases[0].Failure != nil || cases[1].Failure == nil || cases[2].Skipped == nil {
+		t.Errorf("unexpected test cases: %+v", cases)
+	}
+	if cases[1].Name != "go test ./..." || cases[1].SystemOut != "output: artifacts/sess-1/verification_verify-1.txt" {
+		t.Errorf("unexpected verify case: %+v", cases[1])
+	}
+}
@@ -0,0 +1,162 @@
+// Package workspace keeps sessions from mutating the same repository at the
+// same time. A workspace is locked with an advisory lock on a file named
+// after its path, in a directory shared by every simon process of the user.
+package workspace
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// PollInterval is how often Wait retries a held lock.
+const PollInterval = 500 * time.Millisecond
+
+// errHeld reports that another lock holds the file.
+var errHeld = errors.New("lock is held")
+
+// Holder describes the process holding a workspace lock.
+type Holder struct {
+	Workspace string    `json:"workspace"`
+	Owner     string    `json:"owner"` // What holds the lock, e.g. "session session-1712345678"
+	PID       int       `json:"pid"`
+	Host      string    `json:"host"`
+	Since     time.Time `json:"since"`
+}
+
+func (h Holder) String() string {
+	return fmt.Sprintf("%s (pid %d on %s, since %s)", h.Owner, h.PID, h.Host, h.Since.Format(time.RFC3339))
+}
+
+// LockedError is returned when a workspace is locked by someone else.
+type LockedError struct {
+	Workspace string
+	// Holder is nil when the lock file couldn't be read, e.g. while its
+	// holder is still writing it.
+	Holder *Holder
+}
+
+func (e *LockedError) Error() string {
+	if e.Holder == nil {
+		return fmt.Sprintf("workspace %s is locked by another simon process", e.Workspace)
+	}
+	return fmt.Sprintf("workspace %s is locked by %s", e.Workspace, e.Holder)
+}
+
+// Locker hands out workspace locks, kept as files in Dir.
+type Locker struct {
+	Dir string
+}
+
+// Lock is a held workspace lock.
+type Lock struct {
+	file *os.File
+	path string
+}
+
+// path returns the lock file of a workspace.
+func (l Locker) path(workspace string) string {
+	sum := sha256.Sum256([]byte(filepath.Clean(workspace)))
+	return filepath.Join(l.Dir, hex.EncodeToString(sum[:8])+".lock")
+}
+
+// TryLock locks workspace for owner, or returns a *LockedError naming the
+// holder when it is already locked.
+func (l Locker) TryLock(workspace, owner string) (*Lock, error) {
+	if err := os.MkdirAll(l.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+	path := l.path(workspace)
+	f, err := openLocked(path)
+	if errors.Is(err, errHeld) {
+		holder, _ := l.Holder(workspace)
+		return nil, &LockedError{Workspace: workspace, Holder: holder}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock workspace: %w", err)
+	}
+
+	host, _ := os.Hostname()
+	data, _ := json.Marshal(Holder{Workspace: workspace, Owner: owner, PID: os.Getpid(), Host: host, Since: time.Now()})
+	if err := f.Truncate(0); err == nil {
+		_, err = f.WriteAt(data, 0)
+	}
+	if err != nil {
+		lock := &Lock{file: f, path: path}
+		_ = lock.Release()
+		return nil, fmt.Errorf("failed to record lock holder: %w", err)
+	}
+	return &Lock{file: f, path: path}, nil
+}
+
+// Wait locks workspace for owner once its holder releases it, retrying
+// every PollInterval until ctx ends. waiting is called with the holder's
+// *LockedError before the first wait.
+func (l Locker) Wait(ctx context.Context, workspace, owner string, waiting func(*LockedError)) (*Lock, error) {
+	ticker := time.NewTicker(PollInterval)
+	defer ticker.Stop()
+	for first := true; ; first = false {
+		lock, err := l.TryLock(workspace, owner)
+		var locked *LockedError
+		if !errors.As(err, &locked) {
+			return lock, err
+		}
+		if first && waiting != nil {
+			waiting(locked)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %w", locked, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// Steal locks workspace for owner even when it is locked, and returns the
+// holder it was taken from, if any. The previous holder keeps running
+// unaware; it only loses the lock.
+func (l Locker) Steal(workspace, owner string) (*Lock, *Holder, error) {
+	previous, _ := l.Holder(workspace)
+	if err := os.Remove(l.path(workspace)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, fmt.Errorf("failed to remove workspace lock: %w", err)
+	}
+	lock, err := l.TryLock(workspace, owner)
+	if err != nil {
+		return nil, nil, err
+	}
+	return lock, previous, nil
+}
+
+// Holder returns the holder recorded for workspace, or nil when it isn't
+// locked.
+func (l Locker) Holder(workspace string) (*Holder, error) {
+	data, err := os.ReadFile(l.path(workspace))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var h Holder
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, fmt.Errorf("unreadable lock file: %w", err)
+	}
+	return &h, nil
+}
+
+// Release unlocks the workspace. The lock file is removed unless another
+// process stole the lock and replaced it.
+func (lk *Lock) Release() error {
+	if lk == nil || lk.file == nil {
+		return nil
+	}
+	err := unlock(lk.file, lk.path)
+	lk.file = nil
+	return err
+}
@@ -0,0 +1,65 @@
+//go:build !unix
+
+package workspace
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+)
+
+// openLocked creates the lock file at path, failing when it exists. Without
+// flock the lock is the file itself, so a holder that exits without
+// releasing it (os.Exit, a crash) leaves it behind; it is taken over once
+// its process is gone, or by a Steal.
+func openLocked(path string) (*os.File, error) {
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o600) // #nosec G304 -- path is derived from a hash
+		if !errors.Is(err, os.ErrExist) {
+			return f, err
+		}
+		if !stale(path) {
+			return nil, errHeld
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+}
+
+// stale reports whether the lock file at path was left behind by a process
+// of this host that no longer runs. Where the process can't be looked up,
+// as everywhere but Windows, the lock is assumed held.
+func stale(path string) bool {
+	data, err := os.ReadFile(path) // #nosec G304 -- path is derived from a hash
+	if err != nil {
+		return false
+	}
+	var h Holder
+	if err := json.Unmarshal(data, &h); err != nil {
+		// Still being written by its holder
+		return false
+	}
+	host, _ := os.Hostname()
+	if h.Host != host || h.PID == os.Getpid() {
+		return false
+	}
+	p, err := os.FindProcess(h.PID)
+	if err != nil {
+		return true
+	}
+	_ = p.Release()
+	return false
+}
+
+// unlock closes the lock file and removes it, unless a steal replaced it.
+func unlock(f *os.File, path string) error {
+	open, statErr := f.Stat()
+	err := f.Close()
+	if named, nameErr := os.Stat(path); statErr == nil && nameErr == nil && os.SameFile(open, named) {
+		if rerr := os.Remove(path); err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,121 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestTryLock(t *testing.T) {
+	l := Locker{Dir: t.TempDir()}
+	lock, err := l.TryLock("/src/app", "session session-1")
+	if err != nil {
+		t.Fatalf("TryLock failed: %v", err)
+	}
+
+	holder, err := l.Holder("/src/app")
+	if err != nil || holder == nil {
+		t.Fatalf("Holder = %v, %v", holder, err)
+	}
+	if holder.Owner != "session session-1" || holder.PID != os.Getpid() || holder.Workspace != "/src/app" {
+		t.Errorf("unexpected holder: %+v", holder)
+	}
+
+	_, err = l.TryLock("/src/app", "session session-2")
+	var locked *LockedError
+	if !errors.As(err, &locked) || locked.Holder == nil || locked.Holder.Owner != "session session-1" {
+		t.Fatalf("expected a LockedError naming the holder, got %v", err)
+	}
+	other, err := l.TryLock("/src/lib", "session session-2")
+	if err != nil {
+		t.Fatalf("expected another workspace to lock, got %v", err)
+	}
+	other.Release()
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if holder, _ := l.Holder("/src/app"); holder != nil {
+		t.Errorf("expected no holder after release, got %+v", holder)
+	}
+	lock, err = l.TryLock("/src/app", "session session-2")
+	if err != nil {
+		t.Fatalf("expected the released workspace to lock, got %v", err)
+	}
+	lock.Release()
+}
+
+func TestWait(t *testing.T) {
+	l := Locker{Dir: t.TempDir()}
+	lock, err := l.TryLock("/src/app", "session session-1")
+	if err != nil {
+		t.Fatalf("TryLock failed: %v", err)
+	}
+
+	t.Run("Timeout", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := l.Wait(ctx, "/src/app", "session session-2", nil)
+		var locked *LockedError
+		if !errors.As(err, &locked) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected the wait to time out on the lock, got %v", err)
+		}
+	})
+
+	t.Run("Released", func(t *testing.T) {
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			lock.Release()
+		}()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		var waited *LockedError
+		got, err := l.Wait(ctx, "/src/app", "session session-2", func(e *LockedError) { waited = e })
+		if err != nil {
+			t.Fatalf("Wait failed: %v", err)
+		}
+		defer got.Release()
+		if waited == nil || waited.Holder == nil || waited.Holder.Owner != "session session-1" {
+			t.Errorf("expected waiting to be called with the holder, got %v", waited)
+		}
+		if holder, _ := l.Holder("/src/app"); holder == nil || holder.Owner != "session session-2" {
+			t.Errorf("expected the waiter to hold the lock, got %+v", holder)
+		}
+	})
+}
+
+func TestSteal(t *testing.T) {
+	l := Locker{Dir: t.TempDir()}
+	lock, err := l.TryLock("/src/app", "session session-1")
+	if err != nil {
+		t.Fatalf("TryLock failed: %v", err)
+	}
+
+	stolen, previous, err := l.Steal("/src/app", "session session-2")
+	if err != nil {
+		t.Fatalf("Steal failed: %v", err)
+	}
+	if previous == nil || previous.Owner != "session session-1" {
+		t.Errorf("expected the previous holder, got %+v", previous)
+	}
+
+	// The robbed holder's release leaves the new lock in place
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if holder, _ := l.Holder("/src/app"); holder == nil || holder.Owner != "session session-2" {
+		t.Errorf("expected the lock to stay stolen, got %+v", holder)
+	}
+	if _, err := l.TryLock("/src/app", "session session-3"); err == nil {
+		t.Error("expected the stolen lock to be held")
+	}
+	stolen.Release()
+
+	lock, previous, err = l.Steal("/src/app", "session session-3")
+	if err != nil || previous != nil {
+		t.Errorf("expected stealing a free lock to just lock it, got %+v, %v", previous, err)
+	}
+	lock.Release()
+}
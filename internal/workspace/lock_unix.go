@@ -0,0 +1,58 @@
+//go:build unix
+
+package workspace
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// openLocked opens the lock file at path with an exclusive flock, which the
+// kernel releases when the process dies, so crashed holders leave no stale
+// lock behind.
+func openLocked(path string) (*os.File, error) {
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600) // #nosec G304 -- path is derived from a hash
+		if err != nil {
+			return nil, err
+		}
+		if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+			f.Close()
+			if errors.Is(err, syscall.EWOULDBLOCK) {
+				return nil, errHeld
+			}
+			return nil, err
+		}
+		// The holder may have released the lock, removing the file, or a
+		// steal replaced it between the open and the flock; the lock then
+		// belongs to a file nobody else will open
+		if sameFile(f, path) {
+			return f, nil
+		}
+		f.Close()
+	}
+}
+
+// unlock removes the lock file while still holding it, so that a process
+// that opened it meanwhile sees it replaced and retries, then releases it.
+func unlock(f *os.File, path string) error {
+	var err error
+	if sameFile(f, path) {
+		err = os.Remove(path)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// sameFile reports whether path still names the open file f.
+func sameFile(f *os.File, path string) bool {
+	open, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	named, err := os.Stat(path)
+	return err == nil && os.SameFile(open, named)
+}
@@ -0,0 +1,186 @@
+package simon
+
+import (
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/guard"
+	"github.com/felixgeelhaar/simon/internal/provider"
+)
+
+// Policy holds the limits and scopes every session of a client runs under.
+// Its fields are those of policy.yaml; limits of 0 mean unlimited.
+type Policy struct {
+	MaxIterations          int
+	MaxPromptTokens        int
+	MaxOutputTokens        int
+	MaxThinkingTokens      int
+	MaxDigestTokens        int // Token budget for tool output digests
+	MaxArtifactReadBytes   int
+	MaxWriteBytes          int64 // Per tool call
+	MaxSessionWriteBytes   int64
+	MaxRequestsPerMinute   int
+	MaxToolCallsPerMinute  int
+	MaxCost                float64 // Estimated cost in USD
+	MaxDuration            time.Duration
+	MaxIterationDuration   time.Duration
+	MaxVerificationRetries int
+
+	AllowedCommands   []string
+	AllowedFileGlobs  []string
+	DeniedFileGlobs   []string
+	BlockDangerousCmd bool
+
+	// Sandbox is "none", "auto", "firejail", or "sandbox-exec".
+	Sandbox        string
+	SandboxNetwork bool
+
+	Env     EnvPolicy
+	Git     GitPolicy
+	Content ContentPolicy
+
+	// Params are the default sampling parameters of sessions' requests.
+	Params PhaseParams
+	// Severities overrides the reaction per rule (e.g. "allowed_commands").
+	Severities map[string]Severity
+}
+
+// EnvPolicy selects the variables passed from the environment to tools.
+type EnvPolicy struct {
+	Allow []string
+	Deny  []string // Overrides Allow
+	Path  []string // Directories added in front of PATH
+}
+
+// GitPolicy restricts what git may do to the repository.
+type GitPolicy struct {
+	AllowPush         bool
+	AllowCommit       bool
+	ProtectedBranches []string
+}
+
+// ContentPolicy checks what write_file is about to write.
+type ContentPolicy struct {
+	Builtins       bool
+	Deny           []string // Regular expressions
+	MaxBase64Bytes int
+	Approve        bool
+}
+
+// Severity is the reaction to a policy violation.
+type Severity string
+
+// The reactions a policy may give a rule.
+const (
+	SeverityWarn  Severity = "warn"
+	SeverityBlock Severity = "block"
+	SeverityHalt  Severity = "halt"
+)
+
+// PhaseParams are sampling parameters per phase of a session, each over
+// Default.
+type PhaseParams struct {
+	Default   Params
+	Planning  Params
+	Execution Params
+	Summary   Params
+}
+
+// Params are the sampling parameters of a request; unset ones keep the
+// provider's defaults.
+type Params struct {
+	Temperature *float64
+	TopP        *float64
+	MaxTokens   int
+	Stop        []string
+}
+
+// DefaultPolicy returns the policy used when a profile has no policy.yaml.
+func DefaultPolicy() Policy {
+	return toPolicy(guard.DefaultPolicy)
+}
+
+func toPolicy(p guard.Policy) Policy {
+	policy := Policy{
+		MaxIterations:          p.MaxIterations,
+		MaxPromptTokens:        p.MaxPromptTokens,
+		MaxOutputTokens:        p.MaxOutputTokens,
+		MaxThinkingTokens:      p.MaxThinkingTokens,
+		MaxDigestTokens:        p.MaxDigestTokens,
+		MaxArtifactReadBytes:   p.MaxArtifactReadBytes,
+		MaxWriteBytes:          p.MaxWriteBytes,
+		MaxSessionWriteBytes:   p.MaxSessionWriteBytes,
+		MaxRequestsPerMinute:   p.MaxRequestsPerMinute,
+		MaxToolCallsPerMinute:  p.MaxToolCallsPerMinute,
+		MaxCost:                p.MaxCost,
+		MaxDuration:            p.MaxDuration,
+		MaxIterationDuration:   p.MaxIterationDuration,
+		MaxVerificationRetries: p.MaxVerificationRetries,
+		AllowedCommands:        p.AllowedCommands,
+		AllowedFileGlobs:       p.AllowedFileGlobs,
+		DeniedFileGlobs:        p.DeniedFileGlobs,
+		BlockDangerousCmd:      p.BlockDangerousCmd,
+		Sandbox:                p.Sandbox,
+		SandboxNetwork:         p.SandboxNetwork,
+		Env:                    EnvPolicy{Allow: p.Env.Allow, Deny: p.Env.Deny, Path: p.Env.Path},
+		Git:                    GitPolicy{AllowPush: p.Git.AllowPush, AllowCommit: p.Git.AllowCommit, ProtectedBranches: p.Git.ProtectedBranches},
+		Content:                ContentPolicy{Builtins: p.Content.Builtins, Deny: p.Content.Deny, MaxBase64Bytes: p.Content.MaxBase64Bytes, Approve: p.Content.Approve},
+		Params:                 toPhaseParams(p.Params),
+	}
+	if p.Severities != nil {
+		policy.Severities = make(map[string]Severity, len(p.Severities))
+		for rule, sev := range p.Severities {
+			policy.Severities[rule] = Severity(sev)
+		}
+	}
+	return policy
+}
+
+// guardPolicy returns p as the guard enforces it.
+func (p Policy) guardPolicy() guard.Policy {
+	policy := guard.Policy{
+		MaxIterations:          p.MaxIterations,
+		MaxPromptTokens:        p.MaxPromptTokens,
+		MaxOutputTokens:        p.MaxOutputTokens,
+		MaxThinkingTokens:      p.MaxThinkingTokens,
+		MaxDigestTokens:        p.MaxDigestTokens,
+		MaxArtifactReadBytes:   p.MaxArtifactReadBytes,
+		MaxWriteBytes:          p.MaxWriteBytes,
+		MaxSessionWriteBytes:   p.MaxSessionWriteBytes,
+		MaxRequestsPerMinute:   p.MaxRequestsPerMinute,
+		MaxToolCallsPerMinute:  p.MaxToolCallsPerMinute,
+		MaxCost:                p.MaxCost,
+		MaxDuration:            p.MaxDuration,
+		MaxIterationDuration:   p.MaxIterationDuration,
+		MaxVerificationRetries: p.MaxVerificationRetries,
+		AllowedCommands:        p.AllowedCommands,
+		AllowedFileGlobs:       p.AllowedFileGlobs,
+		DeniedFileGlobs:        p.DeniedFileGlobs,
+		BlockDangerousCmd:      p.BlockDangerousCmd,
+		Sandbox:                p.Sandbox,
+		SandboxNetwork:         p.SandboxNetwork,
+		Env:                    guard.EnvPolicy{Allow: p.Env.Allow, Deny: p.Env.Deny, Path: p.Env.Path},
+		Git:                    guard.GitPolicy{AllowPush: p.Git.AllowPush, AllowCommit: p.Git.AllowCommit, ProtectedBranches: p.Git.ProtectedBranches},
+		Content:                guard.ContentPolicy{Builtins: p.Content.Builtins, Deny: p.Content.Deny, MaxBase64Bytes: p.Content.MaxBase64Bytes, Approve: p.Content.Approve},
+		Params:                 p.Params.providerParams(),
+	}
+	if p.Severities != nil {
+		policy.Severities = make(map[string]guard.Severity, len(p.Severities))
+		for rule, sev := range p.Severities {
+			policy.Severities[rule] = guard.Severity(sev)
+		}
+	}
+	return policy
+}
+
+func toPhaseParams(p provider.PhaseParams) PhaseParams {
+	return PhaseParams{Default: Params(p.Default), Planning: Params(p.Planning), Execution: Params(p.Execution), Summary: Params(p.Summary)}
+}
+
+func (p PhaseParams) providerParams() provider.PhaseParams {
+	return provider.PhaseParams{
+		Default:   provider.Params(p.Default),
+		Planning:  provider.Params(p.Planning),
+		Execution: provider.Params(p.Execution),
+		Summary:   provider.Params(p.Summary),
+	}
+}
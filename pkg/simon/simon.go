@@ -0,0 +1,442 @@
+// Package simon embeds Simon's governed agent runtime in other Go programs.
+// A Client runs task specs as sessions under a guard policy, with the same
+// store, providers, and verification as the simon CLI, without shelling out
+// to it:
+//
+//	c, err := simon.New(simon.Options{Provider: "anthropic"})
+//	if err != nil {
+//		return err
+//	}
+//	defer c.Close()
+//	stop := c.Subscribe(func(e simon.Event) { log.Println(e.Type, e.SessionID) })
+//	defer stop()
+//	sess, err := c.RunSpec(ctx, "task.yaml", simon.RunOptions{})
+//
+// Sessions are stored in the profile directory like CLI sessions, so
+// `simon show`, `simon logs`, and `simon list` see them too.
+package simon
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/coach"
+	"github.com/felixgeelhaar/simon/internal/credential"
+	"github.com/felixgeelhaar/simon/internal/guard"
+	"github.com/felixgeelhaar/simon/internal/observe"
+	"github.com/felixgeelhaar/simon/internal/provider"
+	"github.com/felixgeelhaar/simon/internal/runtime"
+	"github.com/felixgeelhaar/simon/internal/setup"
+	"github.com/felixgeelhaar/simon/internal/store"
+	"github.com/felixgeelhaar/simon/internal/workspace"
+)
+
+// Options configures a Client.
+type Options struct {
+	// Dir is the profile directory holding the store, artifacts, and
+	// policy.yaml; empty uses ~/.simon, the CLI's default profile.
+	Dir string
+	// Provider and Model select the model backend ("openai", "anthropic",
+	// "ollama", ...), with credentials from the profile's config. An empty
+	// Provider uses the provider.default and provider.model config keys.
+	// A session whose spec names a provider or model runs on that instead,
+	// unless it is set here.
+	Provider string
+	Model    string
+	// Policy governs every session; nil uses the profile's policy.yaml or
+	// DefaultPolicy. As with the CLI, the .simon.yaml of the repository
+	// the program runs in supplies config defaults and spec conventions,
+	// and its policy tightens this one.
+	Policy *Policy
+	// Logs receives the runtime's JSON log; nil discards it.
+	Logs io.Writer
+	// Passphrase unlocks a profile whose secrets use a passphrase-derived
+	// key (see simon config rotate-key); empty reads SIMON_PASSPHRASE. The
+	// key decrypts encrypted history and, with history.encrypt set,
+	// encrypts the history of new sessions.
+	Passphrase string
+}
+
+// RunOptions configures one session.
+type RunOptions struct {
+	// SessionID, when set, is used instead of a timestamp-based ID.
+	SessionID string
+	// Vars resolve ${NAME} placeholders in the spec.
+	Vars map[string]string
+	// Tags are key=value labels recorded with the session.
+	Tags map[string]string
+}
+
+// ListOptions narrows the sessions returned by ListSessions.
+type ListOptions struct {
+	// Since keeps sessions created at or after it; zero means no limit.
+	Since time.Time
+	// Limit caps the number of sessions, newest first; 0 means no limit.
+	Limit int
+	// Tags keeps sessions carrying every one of these tags.
+	Tags map[string]string
+}
+
+// Session is a stored session and its usage.
+type Session struct {
+	ID string
+	// Status is "initialized" or "running" while the session runs, then
+	// "completed", "halted", "verification_exhausted", "cancelled", "failed",
+	// "interrupted", or "orphaned".
+	Status    string
+	Spec      string // Path of the spec file the session ran
+	ParentID  string // Set on sub-tasks spawned by another session
+	Tags      map[string]string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	Provider         string
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64 // Estimated cost in USD
+}
+
+// Event is a runtime event of a session, such as "iteration_start",
+// "tool_call_end", "guard_violation", "verification_fail", or
+// "session_complete". Data holds the event's fields, as in `simon logs`.
+type Event struct {
+	Type      string
+	SessionID string
+	Time      time.Time
+	Data      map[string]interface{}
+}
+
+// Client runs sessions in-process. Its methods are safe for concurrent use;
+// concurrent sessions share the policy, and the provider unless their spec
+// names another. Each session holds the workspace lock of the repository
+// the program runs in, so sessions there, the CLI's included, take turns.
+type Client struct {
+	store    *store.SQLiteStore
+	provider provider.Provider
+	stop     func()
+	policy   guard.Policy
+	obs      *observe.Observer
+	// opts are the Options the client was created with, which choose the
+	// provider of sessions whose spec names one too
+	opts Options
+	// specDefaults are the project's conventions added to every spec
+	specDefaults coach.SpecDefaults
+	// locker hands out the workspace locks sessions hold, nil for none
+	locker *workspace.Locker
+
+	mu          sync.Mutex
+	subscribers map[int]func(Event)
+	nextID      int
+}
+
+// New opens the profile's store and creates the provider sessions run with.
+// Close releases them.
+func New(opts Options) (*Client, error) {
+	dir := opts.Dir
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(home, ".simon")
+	}
+	logs := opts.Logs
+	if logs == nil {
+		logs = io.Discard
+	}
+	obs := observe.NewJSON(logs, false)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	project, err := setup.FindProject(wd)
+	if err != nil {
+		return nil, err
+	}
+	s, err := setup.OpenStore(dir, project)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open store: %w", err)
+	}
+	setup.EncryptHistory(s, func() (*credential.Manager, error) {
+		return setup.CredentialManager(s, func() (string, error) {
+			if opts.Passphrase != "" {
+				return opts.Passphrase, nil
+			}
+			if v := os.Getenv(setup.PassphraseEnv); v != "" {
+				return v, nil
+			}
+			return "", fmt.Errorf("a passphrase is required; set Options.Passphrase or %s", setup.PassphraseEnv)
+		})
+	})
+	var policy guard.Policy
+	if opts.Policy != nil {
+		policy, err = project.Restrict(opts.Policy.guardPolicy())
+	} else {
+		policy, err = setup.LoadPolicy(dir, project)
+	}
+	if err != nil {
+		s.Close()
+		return nil, fmt.Errorf("failed to load policy: %w", err)
+	}
+
+	name, model := setup.ResolveProvider(s, nil, opts.Provider, opts.Model)
+	cache, _ := s.GetConfig("cache.enabled")
+	p, stop, err := setup.NewProvider(s, name, model, setup.ProviderOptions{Cache: cache == "true" && name != "fixture", Log: obs.Log()})
+	if err != nil {
+		s.Close()
+		return nil, fmt.Errorf("failed to create provider: %w", err)
+	}
+	c := newClient(s, p, stop, policy, obs)
+	c.opts = opts
+	c.specDefaults = project.SpecDefaults()
+	c.locker = &workspace.Locker{Dir: setup.LocksDir()}
+	return c, nil
+}
+
+func newClient(s *store.SQLiteStore, p provider.Provider, stop func(), policy guard.Policy, obs *observe.Observer) *Client {
+	return &Client{store: s, provider: p, stop: stop, policy: policy, obs: obs, subscribers: make(map[int]func(Event))}
+}
+
+// Close stops the provider and closes the store.
+func (c *Client) Close() error {
+	c.stop()
+	c.obs.Close()
+	return c.store.Close()
+}
+
+// RunSpec runs the spec file at path as a new session and returns it once
+// it ended. An error means the session did not complete; the returned
+// session, when not nil, tells how it ended. Cancelling ctx aborts the
+// session at once; Cancel stops it gracefully.
+func (c *Client) RunSpec(ctx context.Context, path string, opts RunOptions) (*Session, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	return c.start(ctx, abs, "", opts)
+}
+
+// ResumeSession runs the spec of an earlier session again as a new session
+// that continues from where it stopped, as `simon run --resume` does. The
+// earlier session's variables apply unless opts overrides them.
+func (c *Client) ResumeSession(ctx context.Context, id string, opts RunOptions) (*Session, error) {
+	prev, err := c.store.GetSession(id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resume %s: %w", id, err)
+	}
+	specPath := prev.Metadata["spec"]
+	if specPath == "" {
+		return nil, fmt.Errorf("session %s has no spec file", id)
+	}
+	vars := runtime.SessionVars(prev)
+	for name, value := range opts.Vars {
+		vars[name] = value
+	}
+	opts.Vars = vars
+	return c.start(ctx, specPath, prev.ID, opts)
+}
+
+// lockWorkspace takes the workspace lock of the repository the program runs
+// in for a session, as simon run does, waiting for its holder (a CLI
+// session or another of the client's) until ctx ends.
+func (c *Client) lockWorkspace(ctx context.Context, sessionID string) (*workspace.Lock, error) {
+	if c.locker == nil {
+		return nil, nil
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	return c.locker.Wait(ctx, runtime.ProjectRoot(wd), "session "+sessionID, func(locked *workspace.LockedError) {
+		c.obs.Log().Warn().Str("sessionID", sessionID).Str("workspace", locked.Workspace).Msg("Waiting for the workspace lock")
+	})
+}
+
+// start validates the spec, creates the session, and runs it.
+func (c *Client) start(ctx context.Context, specPath, previous string, opts RunOptions) (*Session, error) {
+	co := c.coach()
+	spec, err := c.loadSpec(co, specPath, opts.Vars)
+	if err != nil {
+		return nil, err
+	}
+
+	p, stopProvider, err := c.sessionProvider(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create provider: %w", err)
+	}
+	defer stopProvider()
+
+	g := guard.New(c.policy)
+	mp, stopPlugins, err := setup.NewProxy(c.store, g, c.obs.Log())
+	if err != nil {
+		return nil, err
+	}
+	defer stopPlugins()
+	rt := runtime.New(c.store, g, co, c.obs, p, mp)
+	mp.SetSubtaskRunner(rt)
+	rt.SetProviderFactory(c.newProvider)
+	rt.EventBus().SubscribeAll(c.publish)
+
+	// Timestamps in nanoseconds keep IDs unique when a program starts
+	// several sessions at once
+	id := opts.SessionID
+	if id == "" {
+		id = fmt.Sprintf("session-%d", time.Now().UnixNano())
+	}
+	lock, err := c.lockWorkspace(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Release()
+	session := &store.Session{
+		ID:        id,
+		CreatedAt: time.Now(),
+		Status:    "initialized",
+		Metadata:  map[string]string{"spec": specPath},
+		Tags:      opts.Tags,
+	}
+	if previous != "" {
+		session.Metadata[runtime.MetadataPreviousSession] = previous
+	}
+	for name, value := range opts.Vars {
+		session.Metadata[runtime.MetadataVarPrefix+name] = value
+	}
+	if err := c.store.CreateSession(session); err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	runErr := rt.ExecuteSession(ctx, id)
+	final, err := c.store.GetSession(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+	if runErr != nil && !store.SessionFinished(final.Status) {
+		// A session stopped by an error is not left "running"
+		final.Status = "failed"
+		if err := c.store.UpdateSession(final); err != nil {
+			c.obs.Log().Warn().Err(err).Msg("failed to mark session as failed")
+		}
+	}
+	return toSession(final), runErr
+}
+
+// sessionProvider returns the provider a session of spec runs on: the
+// client's, unless the spec names another provider or model that Options
+// doesn't override. The returned stop function is never nil.
+func (c *Client) sessionProvider(spec *coach.TaskSpec) (provider.Provider, func(), error) {
+	if spec.Provider == "" && spec.Model == "" {
+		return c.provider, func() {}, nil
+	}
+	name, model := setup.ResolveProvider(c.store, spec, c.opts.Provider, c.opts.Model)
+	if name == c.provider.Name() && (model == "" || model == c.provider.Model()) {
+		return c.provider, func() {}, nil
+	}
+	return c.newProvider(name, model)
+}
+
+// newProvider creates a provider from the store's config, for sessions that
+// run on or switch to another provider than the client's.
+func (c *Client) newProvider(name, model string) (provider.Provider, func(), error) {
+	cache, _ := c.store.GetConfig("cache.enabled")
+	return setup.NewProvider(c.store, name, model, setup.ProviderOptions{Cache: cache == "true" && name != "fixture", Log: c.obs.Log()})
+}
+
+// Cancel asks a running session to stop gracefully after its current
+// iteration, as `simon cancel` does.
+func (c *Client) Cancel(id string) error {
+	return c.store.RequestCancel(id)
+}
+
+// Steering is a change asked of a running session; see Client.Steer.
+type Steering struct {
+	// Message is added to the conversation before the next provider call.
+	Message string
+	// Provider and Model switch the session from its next iteration,
+	// keeping its conversation; an empty Provider keeps the current one.
+	Provider string
+	Model    string
+}
+
+// Steer asks a running session, of this or another process, to take a
+// message or switch providers before its next iteration, as `simon steer`
+// does.
+func (c *Client) Steer(id string, steering Steering) error {
+	return c.store.SteerSession(&store.SteeringRequest{SessionID: id, Message: steering.Message, Provider: steering.Provider, Model: steering.Model})
+}
+
+// Subscribe calls handler with every event of the sessions the client runs,
+// on the goroutine running the session, until the returned function is
+// called.
+func (c *Client) Subscribe(handler func(Event)) (unsubscribe func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id := c.nextID
+	c.nextID++
+	c.subscribers[id] = handler
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.subscribers, id)
+	}
+}
+
+func (c *Client) publish(e runtime.Event) {
+	c.mu.Lock()
+	handlers := make([]func(Event), 0, len(c.subscribers))
+	for _, h := range c.subscribers {
+		handlers = append(handlers, h)
+	}
+	c.mu.Unlock()
+
+	event := Event{Type: string(e.Type), SessionID: e.SessionID, Time: e.Timestamp, Data: e.Data}
+	for _, h := range handlers {
+		h(event)
+	}
+}
+
+// GetSession returns a stored session.
+func (c *Client) GetSession(id string) (*Session, error) {
+	sess, err := c.store.GetSession(id)
+	if err != nil {
+		return nil, err
+	}
+	return toSession(sess), nil
+}
+
+// ListSessions returns the stored sessions, newest first, including those
+// run by the CLI.
+func (c *Client) ListSessions(opts ListOptions) ([]Session, error) {
+	sessions, err := c.store.ListSessions(store.SessionFilter{Since: opts.Since, Limit: opts.Limit, Tags: opts.Tags})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Session, len(sessions))
+	for i, sess := range sessions {
+		out[i] = *toSession(sess)
+	}
+	return out, nil
+}
+
+func toSession(s *store.Session) *Session {
+	return &Session{
+		ID:               s.ID,
+		Status:           s.Status,
+		Spec:             s.Metadata["spec"],
+		ParentID:         s.ParentID,
+		Tags:             s.Tags,
+		CreatedAt:        s.CreatedAt,
+		UpdatedAt:        s.UpdatedAt,
+		Provider:         s.Provider,
+		Model:            s.Model,
+		PromptTokens:     s.PromptTokens,
+		CompletionTokens: s.CompletionTokens,
+		Cost:             s.Cost,
+	}
+}
@@ -0,0 +1,151 @@
+package simon
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/felixgeelhaar/simon/internal/guard"
+	"github.com/felixgeelhaar/simon/internal/observe"
+	"github.com/felixgeelhaar/simon/internal/provider"
+	"github.com/felixgeelhaar/simon/internal/runtime"
+	"github.com/felixgeelhaar/simon/internal/setup"
+	"github.com/felixgeelhaar/simon/internal/workspace"
+)
+
+func TestClient(t *testing.T) {
+	tmpDir := t.TempDir()
+	s, err := setup.OpenStore(filepath.Join(tmpDir, "profile"), nil)
+	if err != nil {
+		t.Fatalf("OpenStore failed: %v", err)
+	}
+	c := newClient(s, provider.NewStubProvider(), func() {}, DefaultPolicy().guardPolicy(), observe.NewJSON(io.Discard, false))
+	c.locker = &workspace.Locker{Dir: filepath.Join(tmpDir, "locks")}
+	defer c.Close()
+
+	work := filepath.Join(tmpDir, "work")
+	os.MkdirAll(work, 0750)
+	t.Chdir(work)
+	os.WriteFile("task.yaml", []byte("goal: test\ndefinition_of_done: test\nevidence: [task.yaml]\n"), 0600)
+
+	var events []Event
+	unsubscribe := c.Subscribe(func(e Event) { events = append(events, e) })
+
+	sess, err := c.RunSpec(context.Background(), "task.yaml", RunOptions{SessionID: "sess-sdk", Vars: map[string]string{"ENV": "staging"}, Tags: map[string]string{"team": "infra"}})
+	if err != nil {
+		t.Fatalf("RunSpec failed: %v", err)
+	}
+	if sess.Status != "completed" || sess.Spec != filepath.Join(work, "task.yaml") || sess.Tags["team"] != "infra" {
+		t.Errorf("Expected a completed session of the spec, got %+v", sess)
+	}
+	if len(events) == 0 || events[len(events)-1].Type != "session_complete" || events[len(events)-1].SessionID != "sess-sdk" {
+		t.Errorf("Expected the session's events ending with session_complete, got %+v", events)
+	}
+
+	// Resuming starts a new session continuing the old one, with its variables
+	unsubscribe()
+	seen := len(events)
+	resumed, err := c.ResumeSession(context.Background(), "sess-sdk", RunOptions{SessionID: "sess-sdk-2"})
+	if err != nil {
+		t.Fatalf("ResumeSession failed: %v", err)
+	}
+	if resumed.Status != "completed" || resumed.Spec != sess.Spec {
+		t.Errorf("Expected the resumed session to run the same spec, got %+v", resumed)
+	}
+	stored, _ := s.GetSession("sess-sdk-2")
+	if stored.Metadata[runtime.MetadataPreviousSession] != "sess-sdk" || runtime.SessionVars(stored)["ENV"] != "staging" {
+		t.Errorf("Expected the previous session and its variables, got %v", stored.Metadata)
+	}
+	if len(events) != seen {
+		t.Errorf("Expected no events after unsubscribing, got %d", len(events)-seen)
+	}
+
+	sessions, err := c.ListSessions(ListOptions{Tags: map[string]string{"team": "infra"}})
+	if err != nil || len(sessions) != 1 || sessions[0].ID != "sess-sdk" {
+		t.Errorf("Expected the tagged session, got %+v (%v)", sessions, err)
+	}
+	if _, err := c.GetSession("sess-missing"); err == nil {
+		t.Error("Expected an error for an unknown session")
+	}
+
+	// A spec naming a provider runs on it instead of the client's
+	os.WriteFile("fixture.json", []byte(`{"provider": "openai", "model": "gpt-long", "exchanges": [{"kind": "chat", "response": {"content": "Task complete."}}]}`), 0600)
+	s.SetConfig("provider.fixture.path", filepath.Join(work, "fixture.json"))
+	os.WriteFile("pinned.yaml", []byte("goal: test\ndefinition_of_done: test\nevidence: [pinned.yaml]\nprovider: fixture\n"), 0600)
+	pinned, err := c.RunSpec(context.Background(), "pinned.yaml", RunOptions{SessionID: "sess-pinned"})
+	if err != nil {
+		t.Fatalf("RunSpec failed: %v", err)
+	}
+	if pinned.Provider != "fixture" || pinned.Model != "gpt-long" {
+		t.Errorf("Expected the spec's provider, got %s/%s", pinned.Provider, pinned.Model)
+	}
+
+	spec, err := c.LoadSpec("pinned.yaml", nil)
+	if err != nil || spec.Provider != "fixture" || len(spec.Evidence) != 1 {
+		t.Errorf("Expected the spec as RunSpec reads it, got %+v, %v", spec, err)
+	}
+
+	// An invalid spec fails before a session is created
+	os.WriteFile("bad.yaml", []byte("goal: test\n"), 0600)
+	if _, err := c.LoadSpec("bad.yaml", nil); err == nil {
+		t.Error("Expected LoadSpec to reject an invalid spec")
+	}
+	if _, err := c.RunSpec(context.Background(), "bad.yaml", RunOptions{SessionID: "sess-bad"}); err == nil {
+		t.Error("Expected an invalid spec to be rejected")
+	}
+	if _, err := c.GetSession("sess-bad"); err == nil {
+		t.Error("Expected no session for an invalid spec")
+	}
+
+	// A session waits for the holder of the workspace lock
+	wd, _ := os.Getwd()
+	if holder, _ := c.locker.Holder(runtime.ProjectRoot(wd)); holder != nil {
+		t.Errorf("Expected finished sessions to release the workspace lock, got %+v", holder)
+	}
+	held, err := c.locker.TryLock(runtime.ProjectRoot(wd), "session sess-cli")
+	if err != nil {
+		t.Fatalf("TryLock failed: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var locked *workspace.LockedError
+	if _, err := c.RunSpec(ctx, "task.yaml", RunOptions{SessionID: "sess-locked"}); !errors.As(err, &locked) {
+		t.Errorf("Expected the session to wait for the workspace lock, got %v", err)
+	}
+	held.Release()
+}
+
+func TestPolicy(t *testing.T) {
+	temperature := 0.2
+	want := guard.Policy{
+		MaxIterations: 10, MaxPromptTokens: 1000, MaxOutputTokens: 500, MaxThinkingTokens: 200, MaxDigestTokens: 300,
+		MaxArtifactReadBytes: 4096, MaxWriteBytes: 1 << 20, MaxSessionWriteBytes: 1 << 30,
+		MaxRequestsPerMinute: 60, MaxToolCallsPerMinute: 30, MaxCost: 2.5,
+		MaxDuration: time.Hour, MaxIterationDuration: time.Minute, MaxVerificationRetries: 3,
+		AllowedCommands: []string{"go"}, AllowedFileGlobs: []string{"**/*.go"}, DeniedFileGlobs: []string{"**/.env"}, BlockDangerousCmd: true,
+		Sandbox: "auto", SandboxNetwork: true,
+		Env:        guard.EnvPolicy{Allow: []string{"PATH"}, Deny: []string{"*_TOKEN"}, Path: []string{"/opt/bin"}},
+		Git:        guard.GitPolicy{AllowPush: true, AllowCommit: true, ProtectedBranches: []string{"main"}},
+		Content:    guard.ContentPolicy{Builtins: true, Deny: []string{"TODO"}, MaxBase64Bytes: 1024, Approve: true},
+		Params:     provider.PhaseParams{Planning: provider.Params{Temperature: &temperature, Stop: []string{"END"}}},
+		Severities: map[string]guard.Severity{"allowed_commands": guard.SeverityHalt},
+	}
+	// Every field is set, so one the conversion misses shows up
+	v := reflect.ValueOf(want)
+	for i := 0; i < v.NumField(); i++ {
+		if v.Field(i).IsZero() {
+			t.Fatalf("Expected every policy field set, %s is not", v.Type().Field(i).Name)
+		}
+	}
+	if got := toPolicy(want).guardPolicy(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the policy to survive conversion, got %+v", got)
+	}
+	if DefaultPolicy().MaxIterations != guard.DefaultPolicy.MaxIterations {
+		t.Error("Expected DefaultPolicy to be the guard's default")
+	}
+}
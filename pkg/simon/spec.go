@@ -0,0 +1,144 @@
+package simon
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/felixgeelhaar/simon/internal/coach"
+)
+
+// TaskSpec is the structured task a session works on, as read from a spec
+// file; its fields are those of the spec schema.
+type TaskSpec struct {
+	Goal             string
+	DefinitionOfDone string
+	Constraints      []string
+	Evidence         []string // Paths that must exist on completion
+	Verify           []string // Commands the verifier runs
+	Checks           []Check
+
+	Env              map[string]string
+	AllowedCommands  []string
+	DeniedFileGlobs  []string
+	ReminderInterval int
+	MemoryNamespace  string
+
+	Provider string
+	Model    string
+	Escalate Escalation
+	Params   PhaseParams
+
+	Vars  map[string]string
+	Hooks Hooks
+	// Steps make the spec a mission, each step running as a sub-task.
+	Steps []Step
+}
+
+// Check is a further completion check, run by the verifier named by Type
+// (e.g. "file", "command", "http", or a plugin's).
+type Check struct {
+	Type   string
+	Target string
+	Expect string
+	Params map[string]string
+}
+
+// Hooks are commands run at points of a session's lifecycle.
+type Hooks struct {
+	PreRun        []string
+	PostIteration []string
+	OnComplete    []string
+}
+
+// Escalation names the provider and model a stuck session switches to.
+type Escalation struct {
+	Provider string
+	Model    string
+	After    int // Consecutive failed verifications
+}
+
+// Step is one sub-goal of a mission.
+type Step struct {
+	Goal             string
+	DefinitionOfDone string
+	Evidence         []string
+	Verify           []string
+	Checks           []Check
+	MaxIterations    int
+}
+
+// LoadSpec reads the spec file at path as RunSpec would, with vars resolving
+// its ${NAME} placeholders and the project's conventions applied, and
+// validates it.
+func (c *Client) LoadSpec(path string, vars map[string]string) (*TaskSpec, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := c.loadSpec(c.coach(), abs, vars)
+	if err != nil {
+		return nil, err
+	}
+	return toTaskSpec(spec), nil
+}
+
+// coach returns a coach applying the project's spec conventions.
+func (c *Client) coach() *coach.Coach {
+	co := coach.New()
+	co.SetDefaults(c.specDefaults)
+	return co
+}
+
+// loadSpec reads and validates the spec file at path with co.
+func (c *Client) loadSpec(co *coach.Coach, path string, vars map[string]string) (*coach.TaskSpec, error) {
+	spec, err := co.LoadSpecWithVars(path, vars)
+	if err != nil {
+		return nil, err
+	}
+	if res := co.Validate(*spec); !res.Valid {
+		return nil, fmt.Errorf("invalid spec: %s", strings.Join(res.Errors, ", "))
+	}
+	return spec, nil
+}
+
+func toTaskSpec(s *coach.TaskSpec) *TaskSpec {
+	spec := &TaskSpec{
+		Goal:             s.Goal,
+		DefinitionOfDone: s.DefinitionOfDone,
+		Constraints:      s.Constraints,
+		Evidence:         s.Evidence,
+		Verify:           s.Verify,
+		Checks:           toChecks(s.Checks),
+		Env:              s.Env,
+		AllowedCommands:  s.AllowedCommands,
+		DeniedFileGlobs:  s.DeniedFileGlobs,
+		ReminderInterval: s.ReminderInterval,
+		MemoryNamespace:  s.MemoryNamespace,
+		Provider:         s.Provider,
+		Model:            s.Model,
+		Escalate:         Escalation(s.Escalate),
+		Params:           toPhaseParams(s.Params),
+		Vars:             s.Vars,
+		Hooks:            Hooks(s.Hooks),
+	}
+	for _, step := range s.Steps {
+		spec.Steps = append(spec.Steps, Step{
+			Goal:             step.Goal,
+			DefinitionOfDone: step.DefinitionOfDone,
+			Evidence:         step.Evidence,
+			Verify:           step.Verify,
+			Checks:           toChecks(step.Checks),
+			MaxIterations:    step.MaxIterations,
+		})
+	}
+	return spec
+}
+
+func toChecks(checks []coach.Check) []Check {
+	var out []Check
+	for _, check := range checks {
+		out = append(out, Check(check))
+	}
+	return out
+}
@@ -24,10 +24,20 @@ type SmartStub struct {
 
 func (s *SmartStub) Name() string { return "smart-stub" }
 
+func (s *SmartStub) Model() string { return "smart-stub" }
+
+func (s *SmartStub) CountTokens(ctx context.Context, messages []provider.Message) (int, error) {
+	return provider.EstimateMessagesTokens(messages), nil
+}
+
 func (s *SmartStub) Embed(ctx context.Context, text string) ([]float32, error) {
 	return []float32{0.1, 0.2, 0.3}, nil
 }
 
+func (s *SmartStub) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	return provider.EmbedEach(ctx, s, texts)
+}
+
 func (s *SmartStub) Chat(ctx context.Context, messages []provider.Message) (*provider.Response, error) {
 
 	s.iteration++